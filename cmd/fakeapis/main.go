@@ -0,0 +1,107 @@
+// Command fakeapis serves fake GitHub and Telegram APIs from a scenario
+// file, so the monitor can be run end to end without either service:
+//
+//	fakeapis -addr :9000 -scenario scenario.json &
+//	GITHUB_API_URL=http://localhost:9000/github \
+//	TELEGRAM_API_URL=http://localhost:9000/telegram \
+//	GITHUB_TOKEN=test TELEGRAM_BOT_TOKEN=test TELEGRAM_CHAT_ID=1 monitor
+//	curl http://localhost:9000/telegram/_messages
+//
+// Besides the APIs it serves endpoints to script and inspect a run:
+//
+//	GET  /github/_requests        requests the monitor made to GitHub
+//	POST /github/_notifications   add or update a notification thread
+//	GET  /telegram/_messages      messages the monitor sent
+//	POST /telegram/_commands      send a command, {"chat_id": 1, "text": "/list"}
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/bot/telegramtest"
+	"github.com/erkineren/repository-monitor/internal/github/githubtest"
+	"github.com/google/go-github/v57/github"
+)
+
+// scenario is the scenario file: the GitHub state to serve and the
+// commands users send, each after a delay from the start.
+type scenario struct {
+	GitHub   githubtest.Scenario `json:"github"`
+	Telegram struct {
+		Commands []command `json:"commands"`
+	} `json:"telegram"`
+}
+
+type command struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+	After  string `json:"after"`
+}
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	scenarioPath := flag.String("scenario", "", "scenario file, empty to start without notifications")
+	flag.Parse()
+
+	var sc scenario
+	if *scenarioPath != "" {
+		data, err := os.ReadFile(*scenarioPath)
+		if err != nil {
+			log.Fatalf("Failed to read scenario: %v", err)
+		}
+		if err := json.Unmarshal(data, &sc); err != nil {
+			log.Fatalf("Invalid scenario: %v", err)
+		}
+	}
+
+	githubServer := githubtest.NewServer(sc.GitHub)
+	telegramServer := telegramtest.NewServer()
+	for _, cmd := range sc.Telegram.Commands {
+		delay, err := time.ParseDuration(cmd.After)
+		if cmd.After != "" && err != nil {
+			log.Fatalf("Invalid delay %q of command %q: %v", cmd.After, cmd.Text, err)
+		}
+		time.AfterFunc(delay, func() { telegramServer.SendCommand(cmd.ChatID, cmd.Text) })
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /github/_requests", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, githubServer.Requests())
+	})
+	mux.HandleFunc("POST /github/_notifications", func(w http.ResponseWriter, r *http.Request) {
+		var notification github.Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		githubServer.AddNotification(&notification)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /telegram/_messages", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, telegramServer.Messages())
+	})
+	mux.HandleFunc("POST /telegram/_commands", func(w http.ResponseWriter, r *http.Request) {
+		var cmd command
+		if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		telegramServer.SendCommand(cmd.ChatID, cmd.Text)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.Handle("/github/", http.StripPrefix("/github", githubServer))
+	mux.Handle("/telegram/", http.StripPrefix("/telegram", telegramServer))
+
+	log.Printf("Serving fake GitHub API at %s/github and Telegram API at %s/telegram", *addr, *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,648 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/backup"
+	"github.com/erkineren/repository-monitor/internal/bot"
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/postgres"
+	"github.com/erkineren/repository-monitor/internal/store/sqlite"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands = []command{
+	{"serve", "run the monitor (default)", runServe},
+	{"migrate", "create or upgrade the database schema, or check it, and exit", runMigrate},
+	{"export", "write an encrypted backup of all users", runExport},
+	{"import", "restore a backup written by export", runImport},
+	{"tenant", "add, list or remove tenants", runTenant},
+	{"flag", "set, list or remove feature flags", runFlag},
+	{"outbox", "list queued notifications or requeue dead ones", runOutbox},
+	{"doctor", "check the configuration, database, Redis, bots and tokens", runDoctor},
+	{"send-test", "send a test message to a chat", runSendTest},
+}
+
+// runCommand runs the named subcommand and exits when it fails.
+func runCommand(name string, args []string) {
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(os.Stdout)
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		if err := cmd.run(args); err != nil {
+			log.Fatalf("%s: %v", name, err)
+		}
+		return
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: monitor <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'monitor <command> -h' for the flags of a command.")
+}
+
+func runServe(args []string) error {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "poll and log the notifications that would be sent, without sending them or writing to the database")
+	flags.Parse(args)
+
+	serve(*dryRun)
+	return nil
+}
+
+// runMigrate brings the database schema up to date without starting the
+// monitor, so upgrades can run as a separate deployment step. With
+// --check it only reports the pending migrations, and fails when this
+// version could not serve on the schema as it is.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	check := flags.Bool("check", false, "report pending migrations without applying them")
+	contract := flags.Bool("contract", false, "also apply contract migrations, once every instance runs this version")
+	flags.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	log.Printf("Connecting to database: %s", maskDatabaseURL(cfg.DatabaseURL))
+	if sqlite.IsURL(cfg.DatabaseURL) {
+		return migrateSQLite(cfg.DatabaseURL, *check)
+	}
+	migrator, err := postgres.OpenMigrator(cfg.DatabaseURL, dbPoolConfig(cfg))
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	ctx := context.Background()
+	if !*check {
+		applied, err := migrator.Migrate(ctx, *contract)
+		for _, migration := range applied {
+			log.Printf("Applied %s", describeMigration(migration))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Schema version: %d\n", status.Version)
+	for _, migration := range status.Pending {
+		fmt.Printf("Pending: %s\n", describeMigration(migration))
+	}
+	for _, migration := range status.Unknown {
+		fmt.Printf("Unknown: %s, applied by a newer version\n", describeMigration(migration))
+	}
+	return status.Check()
+}
+
+// migrateSQLite is runMigrate for a SQLite database, which serves a single
+// instance and so has no contract migrations.
+func migrateSQLite(dbURL string, check bool) error {
+	status, err := sqlite.ReadSchemaStatus(dbURL)
+	if err != nil {
+		return err
+	}
+	if !check && status.Version < status.Latest {
+		sqliteStore, err := sqlite.New(dbURL, true)
+		if err != nil {
+			return err
+		}
+		sqliteStore.Close()
+		for version := status.Version + 1; version <= status.Latest; version++ {
+			log.Printf("Applied migration %d", version)
+		}
+		status.Version = status.Latest
+	}
+
+	fmt.Printf("Schema version: %d\n", status.Version)
+	for version := status.Version + 1; version <= status.Latest; version++ {
+		fmt.Printf("Pending: migration %d\n", version)
+	}
+	return status.Check()
+}
+
+func describeMigration(migration postgres.Migration) string {
+	kind := "expand"
+	if migration.Contract {
+		kind = "contract"
+	}
+	return fmt.Sprintf("%d %s (%s)", migration.Version, migration.Name, kind)
+}
+
+// runDoctor checks everything the monitor depends on and prints one line
+// per check. It fails when any check failed; tokens that are about to
+// expire, lack scopes or run out of requests are only reported.
+func runDoctor(args []string) error {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	expiryWarning := flags.Duration("expiry-warning", 7*24*time.Hour, "report GitHub tokens that expire within this time")
+	skipTokens := flags.Bool("skip-tokens", false, "do not check the stored GitHub tokens with GitHub")
+	flags.Parse(args)
+
+	failed := 0
+	report := func(check, detail string, err error) {
+		switch {
+		case err != nil:
+			failed++
+			fmt.Printf("FAIL  %s: %v\n", check, err)
+		case detail != "":
+			fmt.Printf("OK    %s: %s\n", check, detail)
+		default:
+			fmt.Printf("OK    %s\n", check)
+		}
+	}
+	warn := func(format string, args ...any) {
+		fmt.Printf("WARN  "+format+"\n", args...)
+	}
+
+	cfg, err := config.Load()
+	report("config", "", err)
+	if err != nil {
+		return fmt.Errorf("1 check failed")
+	}
+
+	// Look at the schema without migrating it
+	ctx := context.Background()
+	if sqlite.IsURL(cfg.DatabaseURL) {
+		status, err := sqlite.ReadSchemaStatus(cfg.DatabaseURL)
+		report("database", cfg.DatabaseURL, err)
+		if err != nil {
+			return fmt.Errorf("1 check failed")
+		}
+		err = status.Check()
+		report("schema", fmt.Sprintf("version %d", status.Version), err)
+		if err != nil {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+	} else {
+		migrator, err := postgres.OpenMigrator(cfg.DatabaseURL, dbPoolConfig(cfg))
+		report("database", maskDatabaseURL(cfg.DatabaseURL), err)
+		if err != nil {
+			return fmt.Errorf("1 check failed")
+		}
+		status, err := migrator.Status(ctx)
+		migrator.Close()
+		if err == nil {
+			err = status.Check()
+		}
+		report("schema", fmt.Sprintf("version %d", status.Version), err)
+		if err != nil {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		for _, migration := range status.Pending {
+			warn("migration %s is pending, apply it with 'monitor migrate --contract' once every instance runs this version", describeMigration(migration))
+		}
+	}
+
+	cfg.MigrateOnStart = false
+	dbStore, err := connectStore(cfg)
+	detail := ""
+	if cfg.DatabaseReadURL != "" {
+		detail = "read replica " + maskDatabaseURL(cfg.DatabaseReadURL)
+	}
+	report("store", detail, err)
+	if err != nil {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	defer dbStore.Close()
+
+	if cfg.RedisURL != "" {
+		redisCache, err := cache.NewRedis(cfg.RedisURL)
+		report("redis", "", err)
+		if err == nil {
+			redisCache.Close()
+		}
+	}
+
+	tenants, err := dbStore.GetTenants(ctx)
+	report("tenants", fmt.Sprintf("%d configured", len(tenants)), err)
+
+	checkBot := func(tenantID, token string) {
+		telegramBot, err := bot.New(token, telegramClient(cfg))
+		detail := ""
+		if err == nil {
+			detail = "@" + telegramBot.API.Self.UserName
+		}
+		report("telegram bot of tenant "+tenantID, detail, err)
+	}
+	checkBot(store.DefaultTenant, cfg.TelegramBotToken)
+	for _, tenant := range tenants {
+		checkBot(tenant.ID, tenant.BotToken)
+	}
+
+	configureGitHub(cfg)
+	accounts := 0
+	deadline := time.Now().Add(*expiryWarning)
+	err = store.ForEachUser(ctx, dbStore, func(user *models.User) error {
+		for _, account := range user.Accounts {
+			accounts++
+			name := fmt.Sprintf("github token of %s (chat %d)", account.Username, user.ChatID)
+
+			metadata := account.GitHubAccountMetadata
+			if !*skipTokens {
+				client := github.NewClient(account.Token)
+				login, live, err := client.GetAuthenticatedUser(ctx)
+				if err != nil {
+					report(name, "", err)
+					continue
+				}
+				metadata = live
+				if !strings.EqualFold(login, account.Username) {
+					warn("%s belongs to %s", name, login)
+				}
+				if len(metadata.Scopes) > 0 && !slices.Contains(metadata.Scopes, "notifications") && !slices.Contains(metadata.Scopes, "repo") {
+					warn("%s has neither the notifications nor the repo scope, so notifications cannot be read", name)
+				}
+
+				rateLimit, limit, err := client.GetRateLimit(ctx)
+				if err != nil {
+					report(name, "", err)
+					continue
+				}
+				if rateLimit.Remaining < limit/10 {
+					warn("%s has %d of %d requests left until %s", name, rateLimit.Remaining, limit, rateLimit.Reset.Format(time.RFC3339))
+				}
+
+				scopes := "fine-grained"
+				if len(metadata.Scopes) > 0 {
+					scopes = "scopes " + strings.Join(metadata.Scopes, ", ")
+				}
+				report(name, fmt.Sprintf("%s, %d of %d requests left", scopes, rateLimit.Remaining, limit), nil)
+			}
+
+			if metadata.ExpiresAt.IsZero() || metadata.ExpiresAt.After(deadline) {
+				continue
+			}
+			state := "expires"
+			if metadata.ExpiresAt.Before(time.Now()) {
+				state = "expired"
+			}
+			warn("%s %s %s", name, state, metadata.ExpiresAt.Format(time.RFC3339))
+		}
+		return nil
+	})
+	report("github accounts", fmt.Sprintf("%d configured", accounts), err)
+
+	if failed > 0 {
+		return fmt.Errorf("%d checks failed", failed)
+	}
+	return nil
+}
+
+// runSendTest sends a message to a chat through the bot of the tenant the
+// chat belongs to, to verify that notifications can be delivered.
+func runSendTest(args []string) error {
+	flags := flag.NewFlagSet("send-test", flag.ExitOnError)
+	message := flags.String("message", "Test message from the GitHub Repository Monitor.", "text to send")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monitor send-test [--message <text>] <chat_id>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the chat ID as the only argument")
+	}
+	chatID, err := strconv.ParseInt(flags.Arg(0), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID %q", flags.Arg(0))
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	ctx := context.Background()
+	token := cfg.TelegramBotToken
+	tenantID, ok, err := dbStore.ChatTenant(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if ok && tenantID != store.DefaultTenant {
+		tenants, err := dbStore.GetTenants(ctx)
+		if err != nil {
+			return err
+		}
+		token = ""
+		for _, tenant := range tenants {
+			if tenant.ID == tenantID {
+				token = tenant.BotToken
+			}
+		}
+		if token == "" {
+			return fmt.Errorf("chat %d belongs to unknown tenant %s", chatID, tenantID)
+		}
+	}
+
+	telegramBot, err := bot.New(token, telegramClient(cfg))
+	if err != nil {
+		return err
+	}
+	if _, err := telegramBot.Send(ctx, tgbotapi.NewMessage(chatID, *message)); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	log.Printf("Sent test message to chat %d as @%s", chatID, telegramBot.API.Self.UserName)
+	return nil
+}
+
+// runExport writes a backup of all users. Secrets in the backup are
+// encrypted with the passphrase from BACKUP_PASSPHRASE.
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	out := flags.String("out", "backup.json", "file to write the backup to")
+	flags.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+	passphrase := cfg.BackupPassphrase
+	if passphrase == "" {
+		return fmt.Errorf("BACKUP_PASSPHRASE must be set to encrypt the account secrets")
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	data, err := backup.Export(context.Background(), dbStore, passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := data.WriteFile(*out); err != nil {
+		return err
+	}
+
+	log.Printf("Exported %d users to %s", len(data.Users), *out)
+	return nil
+}
+
+// runImport restores a backup written by runExport, decrypting secrets with
+// the passphrase from BACKUP_PASSPHRASE.
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	onConflict := flags.String("on-conflict", string(backup.ConflictSkip), "how to handle users that already exist: skip, overwrite or merge")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: monitor import [--on-conflict skip|overwrite|merge] <backup.json>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected the backup file as the only argument")
+	}
+
+	mode, err := backup.ParseConflictMode(*onConflict)
+	if err != nil {
+		return err
+	}
+
+	data, err := backup.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	result, err := backup.Import(context.Background(), dbStore, data, cfg.BackupPassphrase, mode)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Imported %s: %d users created, %d updated, %d skipped", flags.Arg(0), result.Created, result.Updated, result.Skipped)
+	return nil
+}
+
+// runTenant manages the tenants served by this deployment. Tenants are
+// loaded at startup, so the monitor has to be restarted after changes.
+func runTenant(args []string) error {
+	const usage = "usage: monitor tenant add <id> --bot-token <token> [--api-token <token>] [--name <name>] | list | remove <id>"
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "add":
+		flags := flag.NewFlagSet("tenant add", flag.ExitOnError)
+		botToken := flags.String("bot-token", "", "Telegram bot token of the tenant")
+		apiToken := flags.String("api-token", "", "API token scoped to the tenant")
+		name := flags.String("name", "", "display name of the tenant")
+		if len(args) < 2 {
+			return fmt.Errorf(usage)
+		}
+		flags.Parse(args[2:])
+
+		if *botToken == "" {
+			return fmt.Errorf("--bot-token is required")
+		}
+
+		tenant := models.Tenant{ID: args[1], Name: *name, BotToken: *botToken, APIToken: *apiToken}
+		if err := dbStore.AddTenant(ctx, tenant); err != nil {
+			return err
+		}
+		log.Printf("Saved tenant %s, restart the monitor to start its bot", tenant.ID)
+	case "list":
+		tenants, err := dbStore.GetTenants(ctx)
+		if err != nil {
+			return err
+		}
+		for _, tenant := range tenants {
+			fmt.Printf("%s\t%s\tcreated %s\n", tenant.ID, tenant.Name, tenant.CreatedAt.Format("2006-01-02"))
+		}
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		if err := dbStore.RemoveTenant(ctx, args[1]); err != nil {
+			return err
+		}
+		log.Printf("Removed tenant %s", args[1])
+	default:
+		return fmt.Errorf(usage)
+	}
+
+	return nil
+}
+
+// runFlag manages feature flags. The monitor reads them at the start of
+// every poll cycle, so changes apply without a restart.
+func runFlag(args []string) error {
+	const usage = "usage: monitor flag set <name> [--enabled] [--percent <0-100>] [--chats <id,id>] | list | remove <name>"
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "set":
+		flags := flag.NewFlagSet("flag set", flag.ExitOnError)
+		enabled := flags.Bool("enabled", false, "enable the feature for every chat")
+		percent := flags.Int("percent", 0, "share of chats to enable the feature for")
+		chats := flags.String("chats", "", "comma-separated chat IDs to enable the feature for")
+		if len(args) < 2 {
+			return fmt.Errorf(usage)
+		}
+		flags.Parse(args[2:])
+
+		featureFlag := models.FeatureFlag{Name: args[1], Enabled: *enabled, Percent: *percent}
+		for _, field := range strings.Split(*chats, ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			chatID, err := strconv.ParseInt(field, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid chat ID %q", field)
+			}
+			featureFlag.ChatIDs = append(featureFlag.ChatIDs, chatID)
+		}
+
+		if err := dbStore.SetFeatureFlag(ctx, featureFlag); err != nil {
+			return err
+		}
+		log.Printf("Saved feature flag %s", featureFlag.Name)
+	case "list":
+		featureFlags, err := dbStore.GetFeatureFlags(ctx)
+		if err != nil {
+			return err
+		}
+		for _, featureFlag := range featureFlags {
+			rollout := fmt.Sprintf("%d%%", featureFlag.Percent)
+			if featureFlag.Enabled {
+				rollout = "enabled"
+			}
+			fmt.Printf("%s\t%s\tchats %v\tupdated %s\n", featureFlag.Name, rollout, featureFlag.ChatIDs, featureFlag.UpdatedAt.Format(time.RFC3339))
+		}
+	case "remove":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		if err := dbStore.RemoveFeatureFlag(ctx, args[1]); err != nil {
+			return err
+		}
+		log.Printf("Removed feature flag %s", args[1])
+	default:
+		return fmt.Errorf(usage)
+	}
+
+	return nil
+}
+
+// runOutbox shows what the dispatcher is doing with queued notifications
+// and hands dead ones back to it.
+func runOutbox(args []string) error {
+	const usage = "usage: monitor outbox list [--state pending|sent|dead] [--chat <id>] [--limit <n>] | requeue <id>"
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load config: %v", err)
+	}
+
+	dbStore := openStore(cfg)
+	defer dbStore.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "list":
+		flags := flag.NewFlagSet("outbox list", flag.ExitOnError)
+		state := flags.String("state", "", "only list entries in this state: pending, sent or dead")
+		chatID := flags.Int64("chat", 0, "only list entries of this chat")
+		limit := flags.Int("limit", 50, "maximum number of entries to list")
+		flags.Parse(args[1:])
+
+		entries, err := dbStore.GetOutbox(ctx, store.OutboxQuery{ChatID: *chatID, State: *state, Limit: *limit})
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			fmt.Printf("%d\t%s\tchat %d\t%s\t%d attempts\t%s\t%s\n", entry.ID, entry.State(), entry.ChatID,
+				entry.Notification.Type, entry.Attempts, entry.CreatedAt.Format(time.RFC3339), entry.LastError)
+		}
+	case "requeue":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid outbox entry ID %q", args[1])
+		}
+		if err := dbStore.RequeueOutbox(ctx, id); err != nil {
+			return err
+		}
+		log.Printf("Requeued outbox entry %d", id)
+	default:
+		return fmt.Errorf(usage)
+	}
+
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/metrics"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+const (
+	// leaderRetryInterval is how often a standby tries to take over a job.
+	leaderRetryInterval = 10 * time.Second
+
+	// leaderCheckInterval is how often the leader verifies that it still
+	// holds the job's lock.
+	leaderCheckInterval = 10 * time.Second
+)
+
+// Lock IDs of the jobs that must run on exactly one instance.
+// Poll shard n uses pollShardLock + n.
+const (
+	retentionLock  int32 = 1
+	botUpdatesLock int32 = 2
+	pollShardLock  int32 = 1000
+)
+
+// pollShard is the share of users one notification worker polls. Users
+// are assigned to shards by chat ID.
+type pollShard struct {
+	index int
+	count int
+}
+
+func (s pollShard) owns(chatID int64) bool {
+	shard := chatID % int64(s.count)
+	if shard < 0 {
+		shard += int64(s.count)
+	}
+	return int(shard) == s.index
+}
+
+func (s pollShard) String() string {
+	return fmt.Sprintf("poll shard %d/%d", s.index+1, s.count)
+}
+
+// runElected runs job on the one instance that holds the lock id,
+// while the other instances stand by and try to take over every
+// leaderRetryInterval. The job's context is cancelled when the lock is
+// lost, and the job is expected to return promptly. runElected returns
+// when ctx is done, reporting whether this instance was leading the job
+// until then. Without a shared database there is only one instance, which
+// runs the job itself.
+func runElected(ctx context.Context, locker store.Locker, name string, id int32, job func(ctx context.Context)) bool {
+	if locker == nil {
+		job(ctx)
+		return false
+	}
+
+	for {
+		lock, err := locker.TryLock(ctx, id)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Error electing leader for %s: %v", name, err)
+		}
+		if lock != nil {
+			log.Printf("Acquired leadership of %s", name)
+			metrics.Leader.WithLabelValues(name).Set(1)
+			lead(ctx, lock, name, job)
+			metrics.Leader.WithLabelValues(name).Set(0)
+			if err := lock.Release(context.Background()); err != nil {
+				log.Printf("Error releasing leadership of %s: %v", name, err)
+			}
+			log.Printf("Released leadership of %s", name)
+			if ctx.Err() != nil {
+				return true
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(leaderRetryInterval):
+		}
+	}
+}
+
+// lead runs job until it returns, ctx is done or the lock is lost.
+func lead(ctx context.Context, lock store.Lock, name string, job func(ctx context.Context)) {
+	jobCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		job(jobCtx)
+	}()
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := lock.Check(ctx); err != nil {
+				log.Printf("Stopping %s: %v", name, err)
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
+
+// lease is a job this instance led until it started shutting down.
+type lease struct {
+	name string
+	id   int32
+}
+
+// handOff waits until other instances took over the released leases, so
+// that polling carries on while this instance exits. It gives up after
+// timeout, e.g. when no other replica is running.
+func handOff(locker store.Locker, leases []lease, timeout time.Duration) {
+	if locker == nil || len(leases) == 0 || timeout <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Printf("Waiting up to %v for other instances to take over %d jobs...", timeout, len(leases))
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		pending := leases[:0]
+		for _, l := range leases {
+			held, err := locker.LockHeld(ctx, l.id)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("Error checking hand-off of %s: %v", l.name, err)
+			}
+			if held {
+				log.Printf("Another instance took over %s", l.name)
+				continue
+			}
+			pending = append(pending, l)
+		}
+		leases = pending
+		if len(leases) == 0 {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, l := range leases {
+				log.Printf("No instance took over %s before shutdown", l.name)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// phase is where the instance is in its lifecycle, as reported by /ready.
+type phase int
+
+const (
+	phaseStarting phase = iota
+	phaseRunning
+	phaseDraining
+)
+
+// lifecycle tracks the instance from startup, which includes the schema
+// migrations, until it drains on shutdown. Only a running instance is
+// ready to receive traffic.
+type lifecycle struct {
+	mu    sync.Mutex
+	phase phase
+
+	// shutdownDelay is how long a draining instance keeps serving so load
+	// balancers stop routing to it first.
+	shutdownDelay time.Duration
+}
+
+func newLifecycle(shutdownDelay time.Duration) *lifecycle {
+	return &lifecycle{shutdownDelay: shutdownDelay}
+}
+
+func (l *lifecycle) current() phase {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.phase
+}
+
+// running marks startup as complete.
+func (l *lifecycle) running() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.phase == phaseStarting {
+		l.phase = phaseRunning
+	}
+}
+
+// drain fails readiness and waits for the shutdown delay. Only the first
+// call waits, so a SIGTERM following a preStop hook that already drained
+// the instance shuts it down right away.
+func (l *lifecycle) drain() {
+	l.mu.Lock()
+	draining := l.phase == phaseDraining
+	l.phase = phaseDraining
+	l.mu.Unlock()
+
+	if draining || l.shutdownDelay <= 0 {
+		return
+	}
+	log.Printf("Draining for %v before shutting down...", l.shutdownDelay)
+	time.Sleep(l.shutdownDelay)
+}
+
+// readyHandler serves /ready. It fails while the instance starts up or
+// drains, and otherwise asks healthy, which reports why the instance
+// cannot serve or an empty string.
+func (l *lifecycle) readyHandler(healthy func() string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch l.current() {
+		case phaseStarting:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("STARTING: connecting to the database and migrating the schema"))
+			return
+		case phaseDraining:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte("DRAINING: shutting down"))
+			return
+		}
+		if reason := healthy(); reason != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(reason))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	}
+}
+
+// preStopHandler serves /prestop for an httpGet preStop hook, which
+// Kubernetes runs before sending SIGTERM. It drains the instance and
+// returns once the shutdown delay has passed.
+func (l *lifecycle) preStopHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Received preStop hook")
+	l.drain()
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
@@ -2,25 +2,74 @@ package main
 
 import (
 	"context"
-	"crypto/sha256"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"regexp"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/erkineren/repository-monitor/internal/api"
 	"github.com/erkineren/repository-monitor/internal/bot"
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/calendar"
 	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/features"
 	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/metrics"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/proxy"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/sink"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/source/githubsrc"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/cached"
+	"github.com/erkineren/repository-monitor/internal/store/dryrun"
+	"github.com/erkineren/repository-monitor/internal/store/instrumented"
 	"github.com/erkineren/repository-monitor/internal/store/postgres"
+	"github.com/erkineren/repository-monitor/internal/store/sqlite"
+	"github.com/erkineren/repository-monitor/internal/systemd"
+	"github.com/erkineren/repository-monitor/internal/tracing"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// retentionPurgeInterval is how often old notification history is purged.
+const retentionPurgeInterval = 6 * time.Hour
+
+// databaseMonitorInterval is how often the database connection is checked
+// while it is healthy.
+const databaseMonitorInterval = 15 * time.Second
+
+// outboxDispatchInterval is how often the outbox is drained when idle.
+const outboxDispatchInterval = 5 * time.Second
+
+// botRetryDelay is how long a bot worker waits after fetching updates
+// failed.
+const botRetryDelay = 3 * time.Second
+
 func main() {
+	// Keep tokens out of the logs, including the Telegram API client's
+	log.SetOutput(redact.Writer(os.Stderr))
+	tgbotapi.SetLogger(log.New(redact.Writer(os.Stderr), "", log.LstdFlags))
+
+	// Without a command, or with only flags, the monitor is served
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || args[0] == "-h" || args[0] == "--help") {
+		command, args = args[0], args[1:]
+	}
+	runCommand(command, args)
+}
+
+// serve runs the monitor until it receives SIGINT or SIGTERM. In a dry run
+// it only polls, logging the notifications it would send, and leaves the
+// database and Telegram alone.
+func serve(dryRun bool) {
 	log.Println("Starting GitHub Repository Monitor...")
 
 	// Load configuration
@@ -28,103 +77,414 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-	log.Printf("Configuration loaded successfully. Poll interval: %d seconds, Renotify interval: %d seconds", cfg.PollInterval, cfg.RenotifyInterval)
+	log.Printf("Configuration loaded successfully. Poll interval: %v, Renotify interval: %v", cfg.PollInterval, cfg.RenotifyInterval)
 
-	// Initialize store
-	log.Printf("Connecting to database: %s", maskDatabaseURL(cfg.DatabaseURL))
-	store, err := postgres.New(cfg.DatabaseURL)
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := tracing.Setup(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to initialize store: %v", err)
+		log.Fatalf("Failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Error flushing traces: %v", err)
+		}
+	}()
+
+	// Send GitHub and Telegram requests through their proxies, if any, and
+	// bound each GitHub request
+	configureGitHub(cfg)
+	if cfg.GitHubProxy != nil {
+		log.Printf("Using proxy %s for GitHub", cfg.GitHubProxy.Redacted())
+	}
+	if cfg.TelegramProxy != nil {
+		log.Printf("Using proxy %s for Telegram", cfg.TelegramProxy.Redacted())
 	}
-	log.Println("Database connection established successfully")
-	defer store.Close()
 
-	// Start health check endpoint
+	// Serve health checks while the database is opened and migrated.
+	// /ready fails until startup completes and again while draining.
+	var pgStore *postgres.Store
+	life := newLifecycle(cfg.ShutdownDelay)
+	databaseHealth := func() string {
+		if life.current() == phaseStarting || pgStore == nil {
+			return ""
+		}
+		if health := pgStore.Health(); health.Degraded {
+			return fmt.Sprintf("DEGRADED: database unavailable since %s: %s", health.Since.Format(time.RFC3339), health.Error)
+		}
+		return ""
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if reason := databaseHealth(); reason != "" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(reason))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+	mux.HandleFunc("/ready", life.readyHandler(databaseHealth))
+	mux.HandleFunc("/prestop", life.preStopHandler)
+	mux.Handle("GET /metrics", metrics.Handler())
 	go func() {
-		http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte("OK"))
-		})
 		log.Println("Starting health check endpoint on :8080...")
-		if err := http.ListenAndServe(":8080", nil); err != nil {
+		if err := http.ListenAndServe(":8080", mux); err != nil {
 			log.Printf("Health check server error: %v", err)
 		}
 	}()
 
-	// Initialize Telegram bot
-	log.Println("Initializing Telegram bot...")
-	telegramBot, err := bot.New(cfg.TelegramBotToken)
-	if err != nil {
-		log.Fatalf("Failed to initialize Telegram bot: %v", err)
+	// Initialize store. Standalone mode keeps its state in a file for a
+	// single instance and leaves locker nil. Instances sharing a Postgres
+	// or SQLite database elect the leaders of their jobs through its
+	// locks; SQLite leaves pgStore nil.
+	var baseStore store.Store
+	var locker store.Locker
+	if cfg.Standalone() {
+		baseStore = openStandaloneStore(cfg)
+	} else {
+		baseStore = openStore(cfg)
+		defer baseStore.Close()
+		pgStore, _ = baseStore.(*postgres.Store)
+		locker, _ = baseStore.(store.Locker)
 	}
-	log.Println("Telegram bot initialized successfully")
 
-	// Send startup message to all users
-	users, err := store.GetAllUsers()
-	if err != nil {
-		log.Printf("Warning: Failed to get users for startup notification: %v", err)
-	} else {
-		startupMsg := "🚀 GitHub Repository Monitor has started!\n\nI'm now monitoring your repositories for notifications."
-		for _, user := range users {
-			msg := tgbotapi.NewMessage(user.ChatID, startupMsg)
-			if _, err := telegramBot.API.Send(msg); err != nil {
-				log.Printf("Warning: Failed to send startup message to user %d: %v", user.ChatID, err)
-			}
+	// Record store latency and errors, then add the cache for dedup
+	// lookups, ETags and rate-limit state in front
+	var store store.Store = instrumented.New(baseStore)
+	var responseCache cache.Cache = cache.NewMemory()
+	if cfg.RedisURL != "" {
+		redisCache, err := cache.NewRedis(cfg.RedisURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize Redis cache: %v", err)
 		}
+		responseCache = redisCache
+		store = cached.New(store, redisCache, cfg.RenotifyInterval)
+		log.Println("Redis cache enabled")
 	}
+	defer responseCache.Close()
+	github.SetCache(responseCache)
 
-	// Initialize bot handler
-	handler := bot.NewHandler(telegramBot, store)
+	if dryRun {
+		store = dryrun.New(store)
+		log.Println("Dry run: notifications are logged instead of sent, and polling state is kept in memory")
+	}
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Handle system signals
+	tenants, err := store.GetTenants(ctx)
+	if err != nil {
+		log.Fatalf("Failed to load tenants: %v", err)
+	}
+
+	// Add calendar feeds and the API
+	mux.Handle("GET /calendar/{feed}", calendar.NewHandler(store))
+	if !dryRun && (cfg.APIToken != "" || hasTenantAPITokens(tenants)) {
+		apiServer, err := api.New(store, cfg.APIToken)
+		if err != nil {
+			log.Fatalf("Failed to initialize API: %v", err)
+		}
+		for _, tenant := range tenants {
+			apiServer.AddTenant(tenant)
+		}
+		apiServer.SetPoller(githubPoller(store, cfg, github.NewAPI))
+		apiServer.Register(mux)
+		log.Println("REST and GraphQL API enabled under /api/v1")
+	}
+
+	// Initialize Telegram bots, one for the default tenant and one per
+	// configured tenant
+	var bots map[string]*bot.Bot
+	if !dryRun {
+		log.Println("Initializing Telegram bots...")
+		bots, err = newBots(cfg, tenants)
+		if err != nil {
+			log.Fatalf("Failed to initialize Telegram bot: %v", err)
+		}
+		log.Printf("%d Telegram bots initialized successfully", len(bots))
+	}
+
+	// Open the delivery channels besides Telegram
+	sinks, err := sink.Open(cfg.Sinks)
+	if err != nil {
+		log.Fatalf("Failed to initialize sinks: %v", err)
+	}
+	for _, s := range sinks {
+		log.Printf("Delivering notifications to sink %s", s.Name)
+	}
+
+	// Handle system signals once the workers are started
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		sig := <-sigChan
-		log.Printf("Received signal %v, initiating shutdown...", sig)
-		cancel()
-	}()
 
-	// Start workers
-	var wg sync.WaitGroup
+	// Start workers. Elected jobs run under their own context, so that on
+	// shutdown their leases are handed off before everything else stops.
+	var wg, electedWG sync.WaitGroup
+	electedCtx, cancelElected := context.WithCancel(ctx)
+	defer cancelElected()
+	var releasedMu sync.Mutex
+	var released []lease
+	elect := func(name string, id int32, job func(ctx context.Context)) {
+		electedWG.Add(1)
+		go func() {
+			defer electedWG.Done()
+			if runElected(electedCtx, locker, name, id, job) {
+				releasedMu.Lock()
+				released = append(released, lease{name: name, id: id})
+				releasedMu.Unlock()
+			}
+		}()
+	}
+	watch := newPollWatch()
+
+	// Watch the database connection for /health
+	if pgStore != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pgStore.Monitor(ctx, databaseMonitorInterval)
+		}()
+	}
 
-	// Start notification worker
-	log.Println("Starting notification worker...")
+	// Keep leased secrets alive. A secret that changed when its lease ran
+	// out was read into the config at startup, so restart to apply it.
+	cfg.Secrets.OnChange(func(ref string) {
+		log.Printf("Restarting to apply a changed secret")
+		select {
+		case sigChan <- syscall.SIGTERM:
+		default:
+		}
+	})
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		notificationWorker(ctx, store, cfg)
+		cfg.Secrets.RenewLeases(ctx)
 	}()
 
-	// Start bot update worker
-	log.Println("Starting bot update worker...")
+	// Start one notification worker per poll shard. Replicas share the
+	// shards, each shard being polled by the instance holding its lock.
+	log.Printf("Starting notification workers for %d poll shards...", cfg.PollShards)
+	for i := 0; i < cfg.PollShards; i++ {
+		shard := pollShard{index: i, count: cfg.PollShards}
+		if dryRun {
+			// A dry run polls every shard without taking its lock, so it
+			// never keeps a shard from the instances that deliver.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				supervise(ctx, shard.String(), func(ctx context.Context) {
+					notificationWorker(ctx, store, cfg, github.NewAPI, shard, watch)
+				})
+			}()
+			continue
+		}
+		elect(shard.String(), pollShardLock+int32(i), func(ctx context.Context) {
+			supervise(ctx, shard.String(), func(ctx context.Context) {
+				notificationWorker(ctx, store, cfg, github.NewAPI, shard, watch)
+			})
+		})
+	}
+
+	// Purging history and delivering notifications are left to the
+	// instances that are not dry runs
+	if !dryRun {
+		// Start retention purge job
+		elect("retention", retentionLock, func(ctx context.Context) {
+			supervise(ctx, "retention", func(ctx context.Context) {
+				retentionWorker(ctx, store, cfg)
+			})
+		})
+
+		// Start outbox dispatcher
+		log.Println("Starting notification dispatcher...")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dispatcher := bot.NewDispatcher(bots, store, sinks, cfg.OutboxShedThreshold)
+			supervise(ctx, "dispatcher", func(ctx context.Context) {
+				dispatcher.Run(ctx, outboxDispatchInterval)
+			})
+		}()
+
+		// Start bot update workers. Telegram hands updates to one consumer
+		// per bot, so only the elected instance receives them; it also sends
+		// the startup message the first time it is elected.
+		var startupOnce sync.Once
+		elect("bot updates", botUpdatesLock, func(ctx context.Context) {
+			startupOnce.Do(func() { sendStartupMessages(ctx, store, bots) })
+
+			var botWG sync.WaitGroup
+			for tenantID, telegramBot := range bots {
+				log.Printf("Starting bot update worker for tenant %s...", tenantID)
+				handler := bot.NewHandler(telegramBot, store, cfg)
+				botWG.Add(1)
+				go func() {
+					defer botWG.Done()
+					supervise(ctx, "bot updates of tenant "+tenantID, func(ctx context.Context) {
+						botWorker(ctx, tenantID, handler, cfg)
+					})
+				}()
+			}
+			botWG.Wait()
+		})
+	}
+
+	// On SIGINT or SIGTERM, drain, give up the elected jobs and wait for
+	// other replicas to take them over, then stop the rest
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal %v, initiating shutdown...", sig)
+		if err := systemd.Notify("STOPPING=1"); err != nil {
+			log.Printf("Error notifying systemd: %v", err)
+		}
+		life.drain()
+		cancelElected()
+		electedWG.Wait()
+		handOff(locker, released, cfg.HandoffTimeout)
+		cancel()
+	}()
+
+	// Tell systemd the service is up, and keep its watchdog fed while the
+	// poll loops make progress
+	if err := systemd.Notify("READY=1"); err != nil {
+		log.Printf("Error notifying systemd: %v", err)
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		botWorker(ctx, handler, cfg)
+		runWatchdog(ctx, watch, cfg.PollInterval)
 	}()
 
+	life.running()
 	log.Println("Application is now running. Press Ctrl+C to stop.")
 
 	// Wait for workers to finish
+	electedWG.Wait()
 	wg.Wait()
 	log.Println("Application shutdown complete")
 }
 
+// configureGitHub applies the API URL, proxy and request timeout to
+// GitHub clients created afterwards.
+func configureGitHub(cfg *config.Config) {
+	if err := github.SetBaseURL(cfg.GitHubAPIURL); err != nil {
+		log.Fatalf("Failed to configure GitHub: %v", err)
+	}
+	github.SetTransport(proxy.Transport(cfg.GitHubProxy))
+	github.SetTimeout(cfg.GitHubTimeout)
+}
+
+func openStore(cfg *config.Config) store.Store {
+	dbStore, err := connectStore(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize store: %v", err)
+	}
+	return dbStore
+}
+
+// connectStore opens the database, upgrading the schema unless
+// MIGRATE_ON_START is off, and the read replica if one is configured. A
+// sqlite:// DATABASE_URL opens a SQLite file instead of Postgres.
+func connectStore(cfg *config.Config) (store.Store, error) {
+	log.Printf("Connecting to database: %s", maskDatabaseURL(cfg.DatabaseURL))
+	if sqlite.IsURL(cfg.DatabaseURL) {
+		if cfg.DatabaseReadURL != "" {
+			return nil, fmt.Errorf("DATABASE_READ_URL is not supported with SQLite")
+		}
+		sqliteStore, err := sqlite.New(cfg.DatabaseURL, cfg.MigrateOnStart)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Database opened successfully")
+		return sqliteStore, nil
+	}
+
+	poolConfig := dbPoolConfig(cfg)
+	pgStore, err := postgres.New(cfg.DatabaseURL, poolConfig, cfg.MigrateOnStart)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Database connection established successfully")
+
+	if cfg.DatabaseReadURL != "" {
+		log.Printf("Connecting to read replica: %s", maskDatabaseURL(cfg.DatabaseReadURL))
+		if err := pgStore.OpenReadReplica(cfg.DatabaseReadURL, poolConfig); err != nil {
+			pgStore.Close()
+			return nil, fmt.Errorf("failed to connect to read replica: %v", err)
+		}
+	}
+
+	return pgStore, nil
+}
+
+func dbPoolConfig(cfg *config.Config) postgres.PoolConfig {
+	return postgres.PoolConfig{
+		MaxConns:          int32(cfg.DBMaxConns),
+		MinConns:          int32(cfg.DBMinConns),
+		MaxConnLifetime:   cfg.DBMaxConnLifetime,
+		MaxConnIdleTime:   cfg.DBMaxConnIdleTime,
+		HealthCheckPeriod: cfg.DBHealthCheckPeriod,
+		StatementTimeout:  cfg.DBStatementTimeout,
+	}
+}
+
+func newBots(cfg *config.Config, tenants []models.Tenant) (map[string]*bot.Bot, error) {
+	defaultBot, err := bot.New(cfg.TelegramBotToken, telegramClient(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	bots := map[string]*bot.Bot{store.DefaultTenant: defaultBot}
+	for _, tenant := range tenants {
+		tenantBot, err := bot.New(tenant.BotToken, telegramClient(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %v", tenant.ID, err)
+		}
+		bots[tenant.ID] = tenantBot
+	}
+
+	return bots, nil
+}
+
+// telegramClient is how bots reach Telegram: at TELEGRAM_API_URL, through
+// TELEGRAM_PROXY, with each request bounded by TELEGRAM_TIMEOUT.
+func telegramClient(cfg *config.Config) bot.ClientConfig {
+	return bot.ClientConfig{
+		Transport:      proxy.Transport(cfg.TelegramProxy),
+		Timeout:        cfg.TelegramTimeout,
+		PollingTimeout: cfg.PollingTimeout,
+		APIURL:         cfg.TelegramAPIURL,
+	}
+}
+
+func hasTenantAPITokens(tenants []models.Tenant) bool {
+	for _, tenant := range tenants {
+		if tenant.APIToken != "" {
+			return true
+		}
+	}
+	return false
+}
+
 func maskDatabaseURL(url string) string {
 	// Simple masking to hide sensitive information while keeping the structure visible
 	return regexp.MustCompile(`://[^:]+:[^@]+@`).ReplaceAllString(url, "://*****:*****@")
 }
 
-func notificationWorker(ctx context.Context, store *postgres.Store, cfg *config.Config) {
-	log.Printf("Notification worker started with %d seconds interval", cfg.PollInterval)
-	ticker := time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
+func notificationWorker(ctx context.Context, store store.Store, cfg *config.Config, clients github.ClientFactory, shard pollShard, watch *pollWatch) {
+	log.Printf("Notification worker for %s started with %v interval", shard, cfg.PollInterval)
+	watch.beat(shard.String())
+	defer watch.stop(shard.String())
+	ticker := time.NewTicker(cfg.PollInterval)
 	defer ticker.Stop()
 
+	// Each cycle is paced by the number of jobs the previous one had, so
+	// only the first cycle has to count them up front.
+	expected := -1
+	sources := source.New()
 	for {
 		select {
 		case <-ctx.Done():
@@ -132,98 +492,264 @@ func notificationWorker(ctx context.Context, store *postgres.Store, cfg *config.
 			return
 		case <-ticker.C:
 			log.Println("Starting notification check cycle...")
-			if err := processNotifications(ctx, store, cfg); err != nil {
+			if expected < 0 {
+				count, err := countPollJobs(ctx, store, shard, sources)
+				if err != nil {
+					log.Printf("Error counting accounts to poll: %v", err)
+				}
+				expected = count
+			}
+			jobs, err := processNotifications(ctx, store, cfg, clients, shard, sources, expected)
+			if err != nil {
 				log.Printf("Error processing notifications: %v", err)
+			} else {
+				expected = jobs
 			}
+			watch.beat(shard.String())
 			log.Println("Notification check cycle completed")
 		}
 	}
 }
 
-func processNotifications(ctx context.Context, store *postgres.Store, cfg *config.Config) error {
-	users, err := store.GetAllUsers()
+// retentionWorker purges notification history older than RETENTION_DAYS
+// and users that were deleted more than USER_GRACE_DAYS ago.
+// History is never purged before the renotify interval has passed, since
+// it is what prevents duplicate notifications; the store keeps it longer
+// for chats with a longer interval for the type, and for good for types
+// they never renotify.
+func retentionWorker(ctx context.Context, store store.Store, cfg *config.Config) {
+	retention := time.Duration(cfg.RetentionDays) * 24 * time.Hour
+	if retention < cfg.RenotifyInterval {
+		log.Printf("Warning: RETENTION_DAYS is shorter than the renotify interval, keeping history for %v", cfg.RenotifyInterval)
+		retention = cfg.RenotifyInterval
+	}
+
+	ticker := time.NewTicker(retentionPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		log.Printf("Purging notification history older than %d days...", cfg.RetentionDays)
+		if err := store.CleanOldNotifications(ctx, time.Now().Add(-retention)); err != nil {
+			log.Printf("Error purging notification history: %v", err)
+		}
+
+		gracePeriod := time.Duration(cfg.UserGraceDays) * 24 * time.Hour
+		if purged, err := store.PurgeDeletedUsers(ctx, time.Now().Add(-gracePeriod)); err != nil {
+			log.Printf("Error purging deleted users: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d users deleted more than %d days ago", purged, cfg.UserGraceDays)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Retention worker shutting down...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sendStartupMessages(ctx context.Context, s store.Store, bots map[string]*bot.Bot) {
+	startupMsg := "🚀 GitHub Repository Monitor has started!\n\nI'm now monitoring your repositories for notifications."
+	err := store.ForEachUser(ctx, s, func(user *models.User) error {
+		telegramBot, ok := bots[user.TenantID]
+		if !ok {
+			return nil
+		}
+		msg := tgbotapi.NewMessage(user.ChatID, startupMsg)
+		if _, err := telegramBot.Send(ctx, msg); err != nil {
+			log.Printf("Warning: Failed to send startup message to user %d: %v", user.ChatID, err)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get users: %v", err)
+		log.Printf("Warning: Failed to get users for startup notification: %v", err)
 	}
-	log.Printf("Processing notifications for %d users", len(users))
+}
 
-	for _, user := range users {
-		activeAccounts := 0
-		for _, account := range user.Accounts {
-			if !account.IsActive {
-				continue
-			}
-			activeAccounts++
+// processNotifications polls every account with a pool of POLL_WORKERS
+// goroutines, so a slow account only holds up its own worker. Users are
+// read a page at a time while the workers drain the queue, and the
+// expected number of jobs is spread evenly over the poll interval. It
+// returns how many jobs were queued.
+func processNotifications(ctx context.Context, s store.Store, cfg *config.Config, clients github.ClientFactory, shard pollShard, sources []source.Instance, expected int) (int, error) {
+	ctx, span := tracing.Start(ctx, "poll.cycle", attribute.Int("shard", shard.index))
 
-			log.Printf("Checking GitHub notifications for user %s", account.Username)
-			githubClient := github.NewClient(account.Token)
-			notifications, err := githubClient.GetNotifications(ctx, account.Username)
-			if err != nil {
-				log.Printf("Error getting notifications for %s: %v", account.Username, err)
-				continue
+	// Flags are read once per cycle, so changes apply from the next poll
+	flags, err := features.Load(ctx, s)
+	if err != nil {
+		err = fmt.Errorf("failed to load feature flags: %v", err)
+		tracing.End(span, err)
+		return 0, err
+	}
+	env := &source.Env{Store: s, Config: cfg, Flags: flags, GitHub: clients}
+
+	// A job that panics is logged and skipped, so the other accounts are
+	// still polled
+	jobs := make(chan source.Job)
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.PollWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				recovered("poll", job.Name, func() { job.Run(ctx, env) })
 			}
-			log.Printf("Found %d notifications for user %s", len(notifications), account.Username)
+		}()
+	}
 
-			notificationsSent := 0
-			for _, notification := range notifications {
-				contentHash := fmt.Sprintf("%x", sha256.Sum256([]byte(notification.Message)))
-				shouldNotify, err := store.ShouldNotify(user.ChatID, notification.URL, notification.Type, contentHash, cfg.RenotifyInterval)
-				if err != nil {
-					log.Printf("Error checking notification status: %v", err)
-					continue
-				}
+	pacer := newPollPacer(cfg.PollInterval, expected)
+	users, queued := 0, 0
+	err = store.ForEachUser(ctx, s, func(user *models.User) error {
+		if !shard.owns(user.ChatID) {
+			return nil
+		}
+		// Activity outside the poll window is picked up by the first poll
+		// inside it
+		if !source.InPollWindow(ctx, s, user.ChatID) {
+			return nil
+		}
 
-				if shouldNotify {
-					telegramBot, err := bot.New(cfg.TelegramBotToken)
-					if err != nil {
-						log.Printf("Error creating Telegram bot: %v", err)
-						continue
-					}
-
-					if err := telegramBot.SendNotification(user.ChatID, notification); err != nil {
-						log.Printf("Error sending notification: %v", err)
-						continue
-					}
-
-					if err := store.RecordNotification(user.ChatID, notification.URL, notification.Type, contentHash); err != nil {
-						log.Printf("Error recording notification: %v", err)
-						continue
-					}
-					notificationsSent++
+		for _, src := range sources {
+			for _, job := range src.Jobs(user) {
+				if err := pacer.wait(ctx); err != nil {
+					return err
+				}
+				select {
+				case jobs <- job:
+				case <-ctx.Done():
+					return ctx.Err()
 				}
+				queued++
 			}
-			log.Printf("Sent %d new notifications for user %s", notificationsSent, account.Username)
 		}
-		log.Printf("Processed %d active accounts for user %d", activeAccounts, user.ChatID)
+		users++
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	span.SetAttributes(attribute.Int("users", users), attribute.Int("jobs", queued))
+	if err != nil {
+		err = fmt.Errorf("failed to get users: %v", err)
+		tracing.End(span, err)
+		return queued, err
 	}
+	tracing.End(span, nil)
+	log.Printf("Ran %d poll jobs for %d users in %s", queued, users, shard)
+	return queued, nil
+}
 
-	log.Println("Cleaning old notifications...")
-	if err := store.CleanOldNotifications(cfg.RenotifyInterval); err != nil {
-		log.Printf("Error cleaning old notifications: %v", err)
+// countPollJobs returns how many jobs the sources would give a poll cycle
+// of the shard.
+func countPollJobs(ctx context.Context, s store.Store, shard pollShard, sources []source.Instance) (int, error) {
+	count := 0
+	err := store.ForEachUser(ctx, s, func(user *models.User) error {
+		if !shard.owns(user.ChatID) {
+			return nil
+		}
+		for _, src := range sources {
+			count += len(src.Jobs(user))
+		}
+		return nil
+	})
+	return count, err
+}
+
+// githubPoller polls accounts on demand for the API, regardless of their
+// error backoff and rate-limit pacing.
+func githubPoller(s store.Store, cfg *config.Config, clients github.ClientFactory) api.Poller {
+	limiter := githubsrc.NewRateLimiter()
+	return func(ctx context.Context, chatID int64, username string) (int, error) {
+		user, exists := s.GetUser(ctx, chatID)
+		if !exists {
+			return 0, store.ErrUserNotFound
+		}
+		account := user.Accounts[username]
+		if account == nil {
+			return 0, store.ErrAccountNotFound
+		}
+		if !account.IsActive {
+			return 0, api.ErrAccountInactive
+		}
+
+		ctx, span := tracing.Start(ctx, "poll.github_account", attribute.Int64("chat_id", chatID), attribute.String("account", username), attribute.Bool("forced", true))
+		defer span.End()
+
+		flags, err := features.Load(ctx, s)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load feature flags: %v", err)
+		}
+		state, err := s.GetAccountState(ctx, chatID, models.AccountKindGitHub, username)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get polling state: %v", err)
+		}
+		env := &source.Env{Store: s, Config: cfg, Flags: flags, GitHub: clients}
+		return githubsrc.Fetch(ctx, env, limiter, user, account, state)
 	}
-	return nil
 }
 
-func botWorker(ctx context.Context, handler *bot.Handler, cfg *config.Config) {
-	log.Printf("Bot worker started with %d seconds polling timeout", cfg.PollingTimeout)
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = cfg.PollingTimeout
+// botWorker handles the updates of one tenant's bot, scoping every store
+// call it makes to that tenant. Updates are fetched one long poll at a time
+// rather than through GetUpdatesChan, which keeps polling after the worker
+// stops and cannot be restarted once stopped.
+func botWorker(ctx context.Context, tenantID string, handler *bot.Handler, cfg *config.Config) {
+	ctx = store.WithTenant(ctx, tenantID)
+	log.Printf("Bot worker for tenant %s started with %v polling timeout", tenantID, cfg.PollingTimeout)
 
-	updates := handler.Bot.API.GetUpdatesChan(u)
-	log.Println("Bot is now listening for updates")
+	type result struct {
+		updates []tgbotapi.Update
+		err     error
+	}
 
+	offset := 0
 	for {
+		updateConfig := tgbotapi.NewUpdate(offset)
+		updateConfig.Timeout = int(cfg.PollingTimeout.Seconds())
+		results := make(chan result, 1)
+		go func() {
+			updates, err := handler.Bot.API.GetUpdates(updateConfig)
+			results <- result{updates, err}
+		}()
+
+		// A poll still in flight when the worker stops is abandoned. It
+		// does not confirm the updates it receives, so the next leader
+		// gets them again.
+		var r result
 		select {
 		case <-ctx.Done():
-			log.Println("Bot worker shutting down...")
+			log.Printf("Bot worker for tenant %s shutting down...", tenantID)
 			return
-		case update := <-updates:
+		case r = <-results:
+		}
+
+		if r.err != nil {
+			log.Printf("Error getting updates for tenant %s: %v", tenantID, r.err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(botRetryDelay):
+			}
+			continue
+		}
+
+		for _, update := range r.updates {
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+			}
+			if cfg.Standalone() {
+				if chat := update.FromChat(); chat == nil || chat.ID != cfg.TelegramChatID {
+					continue
+				}
+			}
 			if update.Message != nil && update.Message.IsCommand() {
 				log.Printf("Received command: %s from user %d", update.Message.Command(), update.Message.From.ID)
 			}
-			if err := handler.HandleUpdate(update); err != nil {
-				log.Printf("Error handling update: %v", err)
-			}
+			// A panicking update is skipped rather than fetched again
+			recovered("bot update", fmt.Sprintf("update %d of tenant %s", update.UpdateID, tenantID), func() {
+				if err := handler.HandleUpdate(ctx, update); err != nil {
+					log.Printf("Error handling update: %v", err)
+				}
+			})
 		}
 	}
 }
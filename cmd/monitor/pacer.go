@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// pollSpread is the share of POLL_INTERVAL that a cycle's polls are spread
+// over. The rest leaves room for the last polls to finish before the next
+// cycle starts.
+const pollSpread = 0.8
+
+// pollPacer hands out evenly spaced start times for the jobs of a poll
+// cycle, so API calls and database writes are spread over the interval
+// instead of bursting at every tick.
+type pollPacer struct {
+	start   time.Time
+	spacing time.Duration
+	next    int
+}
+
+// newPollPacer spreads expected jobs over interval. With no expected jobs
+// the pacer does not wait at all.
+func newPollPacer(interval time.Duration, expected int) *pollPacer {
+	pacer := &pollPacer{start: time.Now()}
+	if expected > 0 {
+		pacer.spacing = time.Duration(float64(interval) * pollSpread / float64(expected))
+	}
+	return pacer
+}
+
+// wait blocks until the next job's slot, shifted by up to half a slot in
+// either direction so polls do not line up across instances and cycles.
+func (p *pollPacer) wait(ctx context.Context) error {
+	if p.spacing <= 0 {
+		return ctx.Err()
+	}
+
+	slot := p.start.Add(time.Duration(p.next) * p.spacing)
+	jitter := time.Duration(rand.Int63n(int64(p.spacing))) - p.spacing/2
+	p.next++
+
+	delay := time.Until(slot.Add(jitter))
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
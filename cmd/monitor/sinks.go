@@ -0,0 +1,7 @@
+package main
+
+// The delivery channels compiled into the monitor besides Telegram. Each
+// registers itself with the sink package and is enabled through SINKS.
+import (
+	_ "github.com/erkineren/repository-monitor/internal/sink/execsink"
+)
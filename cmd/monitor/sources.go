@@ -0,0 +1,20 @@
+package main
+
+// The event sources compiled into the monitor. Each registers itself with
+// the source package; the poll cycle runs every registered source.
+import (
+	_ "github.com/erkineren/repository-monitor/internal/source/changelogsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/commitsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/depsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/gerritsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/githubsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/imagesrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/mentionsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/pathsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/reposrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/reviewsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/securitysrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/teamsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/triagesrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/usagesrc"
+)
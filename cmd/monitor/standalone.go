@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+)
+
+// openStandaloneStore opens the state file and registers the account from
+// GITHUB_TOKEN for TELEGRAM_CHAT_ID, looking up its username unless
+// GITHUB_USERNAME is set.
+func openStandaloneStore(cfg *config.Config) *memory.Store {
+	log.Printf("Running standalone for chat %d, keeping state in %s", cfg.TelegramChatID, cfg.StateFile)
+	memStore, err := memory.Open(cfg.StateFile)
+	if err != nil {
+		log.Fatalf("Failed to open state file: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	username := cfg.GitHubUsername
+	login, metadata, err := github.NewClient(cfg.GitHubToken).GetAuthenticatedUser(ctx)
+	if err != nil {
+		if username == "" {
+			log.Fatalf("Failed to look up the owner of GITHUB_TOKEN, set GITHUB_USERNAME to skip the lookup: %v", err)
+		}
+		log.Printf("Warning: could not fetch token metadata: %v", err)
+	}
+	if username == "" {
+		username = login
+	}
+
+	if err := memStore.AddGitHubAccount(ctx, cfg.TelegramChatID, cfg.GitHubToken, username, metadata); err != nil {
+		log.Fatalf("Failed to add GitHub account: %v", err)
+	}
+	log.Printf("Monitoring GitHub account %s", username)
+
+	return memStore
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/metrics"
+)
+
+const (
+	// supervisorMinBackoff and supervisorMaxBackoff bound how long a
+	// supervisor waits before restarting a worker that panicked. The wait
+	// doubles with every panic and is reset once the worker ran for
+	// supervisorMaxBackoff without one.
+	supervisorMinBackoff = time.Second
+	supervisorMaxBackoff = time.Minute
+)
+
+// supervise runs worker until ctx is done, restarting it with backoff
+// when it panics. A worker that returns on its own is not restarted.
+func supervise(ctx context.Context, name string, worker func(ctx context.Context)) {
+	backoff := supervisorMinBackoff
+	for {
+		started := time.Now()
+		if !recovered(name, name, func() { worker(ctx) }) || ctx.Err() != nil {
+			return
+		}
+
+		if time.Since(started) >= supervisorMaxBackoff {
+			backoff = supervisorMinBackoff
+		}
+		log.Printf("Restarting %s in %v", name, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, supervisorMaxBackoff)
+	}
+}
+
+// recovered runs fn, recovering and logging a panic in it. worker names
+// the kind of work in metrics, and detail what was being worked on in the
+// log. It reports whether fn panicked.
+func recovered(worker, detail string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s: %v\n%s", detail, r, debug.Stack())
+			metrics.WorkerPanics.WithLabelValues(worker).Inc()
+			panicked = true
+		}
+	}()
+
+	fn()
+	return false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/systemd"
+)
+
+// pollStallCycles is how many poll intervals a poll loop may go without
+// completing a cycle before it is considered wedged.
+const pollStallCycles = 3
+
+// pollWatch tracks when each running poll loop last completed a cycle.
+// Instances that stand by for every shard run no poll loop and are never
+// considered wedged.
+type pollWatch struct {
+	mu       sync.Mutex
+	progress map[string]time.Time
+}
+
+func newPollWatch() *pollWatch {
+	return &pollWatch{progress: make(map[string]time.Time)}
+}
+
+// beat records progress of the named poll loop, registering it on the
+// first call.
+func (w *pollWatch) beat(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.progress[name] = time.Now()
+}
+
+// stop unregisters a poll loop that returned.
+func (w *pollWatch) stop(name string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.progress, name)
+}
+
+// stalled returns a poll loop that made no progress within threshold.
+func (w *pollWatch) stalled(threshold time.Duration) (string, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for name, last := range w.progress {
+		if time.Since(last) > threshold {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// runWatchdog pings the systemd watchdog twice per WatchdogSec while every
+// poll loop is making progress. When one is wedged the pings stop, and
+// systemd restarts the service once WatchdogSec has passed.
+func runWatchdog(ctx context.Context, watch *pollWatch, pollInterval time.Duration) {
+	interval := systemd.WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	log.Printf("Pinging the systemd watchdog every %v", interval/2)
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if name, stalled := watch.stalled(pollStallCycles * pollInterval); stalled {
+			log.Printf("Warning: %s completed no poll cycle in %d intervals, withholding watchdog ping", name, pollStallCycles)
+			continue
+		}
+		if err := systemd.Notify("WATCHDOG=1"); err != nil {
+			log.Printf("Error pinging systemd watchdog: %v", err)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// ErrAccountInactive is returned by a Poller for accounts whose
+// notifications are turned off.
+var ErrAccountInactive = errors.New("account is inactive")
+
+// Poller polls a user's GitHub account right away, outside the poll cycle,
+// and returns how many notifications it queued.
+type Poller func(ctx context.Context, chatID int64, username string) (int, error)
+
+type userResponse struct {
+	ChatID         int64    `json:"chat_id"`
+	TenantID       string   `json:"tenant_id"`
+	Accounts       []string `json:"accounts"`
+	GerritAccounts []string `json:"gerrit_accounts"`
+}
+
+type usersResponse struct {
+	Users []userResponse `json:"users"`
+	// NextAfter is the after parameter of the next page, absent on the
+	// last page.
+	NextAfter *int64 `json:"next_after,omitempty"`
+}
+
+type accountStateResponse struct {
+	Username           string     `json:"username"`
+	LastCheckedAt      *time.Time `json:"last_checked_at,omitempty"`
+	LastNotificationAt *time.Time `json:"last_notification_at,omitempty"`
+	ConsecutiveErrors  int        `json:"consecutive_errors"`
+	LastError          string     `json:"last_error,omitempty"`
+	PollInterval       int        `json:"poll_interval,omitempty"`
+}
+
+type pollResponse struct {
+	Queued int `json:"queued"`
+}
+
+// SetPoller enables forcing polls through the API.
+func (s *Server) SetPoller(poller Poller) {
+	s.poller = poller
+}
+
+// handleListUsers lists users in chat ID order, a page at a time. The
+// after query parameter continues after the given chat ID.
+func (s *Server) handleListUsers(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	after, limit := store.FirstChatID, defaultPageSize
+
+	var err error
+	if value := params.Get("after"); value != "" {
+		if after, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid after: %q", value))
+			return
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: must be between 1 and %d", maxPageSize))
+			return
+		}
+	}
+
+	users, err := s.store.ListUsers(r.Context(), after, limit)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := usersResponse{Users: make([]userResponse, 0, len(users))}
+	for _, user := range users {
+		entry := userResponse{
+			ChatID:         user.ChatID,
+			TenantID:       user.TenantID,
+			Accounts:       []string{},
+			GerritAccounts: []string{},
+		}
+		for username := range user.Accounts {
+			entry.Accounts = append(entry.Accounts, username)
+		}
+		sort.Strings(entry.Accounts)
+		for _, account := range user.GerritAccounts {
+			entry.GerritAccounts = append(entry.GerritAccounts, account.Key())
+		}
+		response.Users = append(response.Users, entry)
+	}
+	if len(users) == limit {
+		next := users[len(users)-1].ChatID
+		response.NextAfter = &next
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleGetAccountState shows how polling the account went last time.
+func (s *Server) handleGetAccountState(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	username := r.PathValue("username")
+	user, exists := s.store.GetUser(r.Context(), chatID)
+	if !exists {
+		writeError(w, http.StatusNotFound, store.ErrUserNotFound)
+		return
+	}
+	if user.Accounts[username] == nil {
+		writeError(w, http.StatusNotFound, store.ErrAccountNotFound)
+		return
+	}
+
+	state, err := s.store.GetAccountState(r.Context(), chatID, models.AccountKindGitHub, username)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := accountStateResponse{
+		Username:          username,
+		ConsecutiveErrors: state.ConsecutiveErrors,
+		LastError:         state.LastError,
+		PollInterval:      state.PollInterval,
+	}
+	if !state.LastCheckedAt.IsZero() {
+		response.LastCheckedAt = &state.LastCheckedAt
+	}
+	if !state.LastNotificationAt.IsZero() {
+		response.LastNotificationAt = &state.LastNotificationAt
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handlePollAccount polls the account right away, regardless of its error
+// backoff and rate-limit pacing.
+func (s *Server) handlePollAccount(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if s.poller == nil {
+		writeError(w, http.StatusServiceUnavailable, fmt.Errorf("polling is not available on this instance"))
+		return
+	}
+
+	queued, err := s.poller(r.Context(), chatID, r.PathValue("username"))
+	switch {
+	case errors.Is(err, ErrAccountInactive):
+		writeError(w, http.StatusConflict, err)
+		return
+	case errors.Is(err, store.ErrUserNotFound) || errors.Is(err, store.ErrAccountNotFound):
+		writeStoreError(w, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, pollResponse{Queued: queued})
+}
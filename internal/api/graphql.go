@@ -0,0 +1,233 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/graphql-go/graphql"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// errPageFull stops iterating users once a page is complete.
+var errPageFull = errors.New("page full")
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+func (s *Server) newSchema() (graphql.Schema, error) {
+	accountType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.Fields{
+			"username":  &graphql.Field{Type: graphql.String},
+			"isActive":  &graphql.Field{Type: graphql.Boolean},
+			"scopes":    &graphql.Field{Type: graphql.NewList(graphql.String)},
+			"expiresAt": &graphql.Field{Type: graphql.DateTime},
+			"note":      &graphql.Field{Type: graphql.String},
+			"addedAt":   &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	notificationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Notification",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.ID},
+			"itemUrl":   &graphql.Field{Type: graphql.String},
+			"type":      &graphql.Field{Type: graphql.String},
+			"createdAt": &graphql.Field{Type: graphql.DateTime},
+			"eventId":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	userType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"chatId": &graphql.Field{
+				Type: graphql.ID,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return strconv.FormatInt(p.Source.(*models.User).ChatID, 10), nil
+				},
+			},
+			"accounts": &graphql.Field{
+				Type: graphql.NewList(accountType),
+				Args: graphql.FieldConfigArgument{
+					"active": &graphql.ArgumentConfig{Type: graphql.Boolean},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					active, filter := p.Args["active"].(bool)
+					var accounts []map[string]interface{}
+					for username, account := range p.Source.(*models.User).Accounts {
+						if filter && account.IsActive != active {
+							continue
+						}
+						fields := map[string]interface{}{
+							"username": username,
+							"isActive": account.IsActive,
+							"scopes":   account.Scopes,
+							"note":     account.Note,
+						}
+						if !account.ExpiresAt.IsZero() {
+							fields["expiresAt"] = account.ExpiresAt
+						}
+						if !account.AddedAt.IsZero() {
+							fields["addedAt"] = account.AddedAt
+						}
+						accounts = append(accounts, fields)
+					}
+					sort.Slice(accounts, func(i, j int) bool {
+						return accounts[i]["username"].(string) < accounts[j]["username"].(string)
+					})
+					return accounts, nil
+				},
+			},
+			"mutedRepos": &graphql.Field{
+				Type: graphql.NewList(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					var repos []string
+					for repo := range p.Source.(*models.User).MutedRepos {
+						repos = append(repos, repo)
+					}
+					sort.Strings(repos)
+					return repos, nil
+				},
+			},
+			"notifications": &graphql.Field{
+				Type: graphql.NewList(notificationType),
+				Args: graphql.FieldConfigArgument{
+					"type":   &graphql.ArgumentConfig{Type: graphql.String},
+					"since":  &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					query := store.HistoryQuery{ChatID: p.Source.(*models.User).ChatID}
+					query.Type, _ = p.Args["type"].(string)
+					if since, ok := p.Args["since"].(time.Time); ok {
+						query.Since = since
+					}
+					query.Limit, query.Offset = pageArgs(p.Args)
+
+					records, err := s.store.GetNotificationHistory(p.Context, query)
+					if err != nil {
+						return nil, err
+					}
+
+					result := make([]map[string]interface{}, 0, len(records))
+					for _, record := range records {
+						result = append(result, map[string]interface{}{
+							"id":        strconv.FormatInt(record.ID, 10),
+							"itemUrl":   record.ItemURL,
+							"type":      record.NotificationType,
+							"createdAt": record.CreatedAt,
+							"eventId":   record.EventID,
+						})
+					}
+					return result, nil
+				},
+			},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"chatId": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					chatID, err := strconv.ParseInt(p.Args["chatId"].(string), 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("invalid chat ID: %q", p.Args["chatId"])
+					}
+					user, exists := s.store.GetUser(p.Context, chatID)
+					if !exists {
+						return nil, nil
+					}
+					return user, nil
+				},
+			},
+			"users": &graphql.Field{
+				Type: graphql.NewList(userType),
+				Args: graphql.FieldConfigArgument{
+					"first":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					limit, offset := pageArgs(p.Args)
+					users := []*models.User{}
+					err := store.ForEachUser(p.Context, s.store, func(user *models.User) error {
+						if offset > 0 {
+							offset--
+							return nil
+						}
+						users = append(users, user)
+						if len(users) == limit {
+							return errPageFull
+						}
+						return nil
+					})
+					if err != nil && err != errPageFull {
+						return nil, err
+					}
+					return users, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphqlRequest
+	if r.Method == http.MethodGet {
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if variables := r.URL.Query().Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid variables: %v", err))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func pageArgs(args map[string]interface{}) (limit, offset int) {
+	limit, _ = args["first"].(int)
+	offset, _ = args["offset"].(int)
+	if limit <= 0 || limit > maxPageSize {
+		limit = maxPageSize
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
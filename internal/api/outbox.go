@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+type outboxEntryResponse struct {
+	ID            int64      `json:"id"`
+	ChatID        int64      `json:"chat_id"`
+	TenantID      string     `json:"tenant_id"`
+	Type          string     `json:"type"`
+	Repo          string     `json:"repo,omitempty"`
+	URL           string     `json:"url"`
+	State         string     `json:"state"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	DeadAt        *time.Time `json:"dead_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+
+	EventID  string `json:"event_id,omitempty"`
+	Severity string `json:"severity,omitempty"`
+}
+
+// handleListOutbox lists outbox entries newest first, filtered by the
+// state, chat_id, limit and offset query parameters.
+func (s *Server) handleListOutbox(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := store.OutboxQuery{State: params.Get("state"), Limit: defaultPageSize}
+
+	var err error
+	if value := params.Get("chat_id"); value != "" {
+		if query.ChatID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid chat_id: %q", value))
+			return
+		}
+	}
+	if value := params.Get("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil || query.Limit < 1 || query.Limit > maxPageSize {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid limit: must be between 1 and %d", maxPageSize))
+			return
+		}
+	}
+	if value := params.Get("offset"); value != "" {
+		if query.Offset, err = strconv.Atoi(value); err != nil || query.Offset < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid offset: %q", value))
+			return
+		}
+	}
+	switch query.State {
+	case "", models.OutboxPending, models.OutboxSent, models.OutboxDead:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid state: must be pending, sent or dead"))
+		return
+	}
+
+	entries, err := s.store.GetOutbox(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	response := make([]outboxEntryResponse, 0, len(entries))
+	for _, entry := range entries {
+		response = append(response, newOutboxEntryResponse(entry))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleRequeueOutbox gives a dead entry a fresh set of delivery attempts.
+func (s *Server) handleRequeueOutbox(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid outbox entry ID: %q", r.PathValue("id")))
+		return
+	}
+
+	if err := s.store.RequeueOutbox(r.Context(), id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newOutboxEntryResponse(entry models.OutboxEntry) outboxEntryResponse {
+	response := outboxEntryResponse{
+		ID:        entry.ID,
+		ChatID:    entry.ChatID,
+		TenantID:  entry.TenantID,
+		Type:      entry.Notification.Type,
+		Repo:      entry.Notification.Repo,
+		URL:       entry.Notification.URL,
+		State:     entry.State(),
+		Attempts:  entry.Attempts,
+		LastError: entry.LastError,
+		CreatedAt: entry.CreatedAt,
+		EventID:   entry.Notification.EventID,
+		Severity:  entry.Notification.Severity,
+	}
+	switch response.State {
+	case models.OutboxPending:
+		response.NextAttemptAt = &entry.NextAttemptAt
+	case models.OutboxSent:
+		response.SentAt = &entry.SentAt
+	case models.OutboxDead:
+		response.DeadAt = &entry.DeadAt
+	}
+	return response
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// registerProfiling mounts the runtime profiles of net/http/pprof under
+// /debug/pprof/. Profiles expose the whole process, so only the admin
+// token may fetch them.
+func (s *Server) registerProfiling(mux *http.ServeMux) {
+	mux.Handle("GET /debug/pprof/", s.adminAuth(pprof.Index))
+	mux.Handle("GET /debug/pprof/cmdline", s.adminAuth(pprof.Cmdline))
+	mux.Handle("GET /debug/pprof/profile", s.adminAuth(pprof.Profile))
+	mux.Handle("GET /debug/pprof/symbol", s.adminAuth(pprof.Symbol))
+	mux.Handle("POST /debug/pprof/symbol", s.adminAuth(pprof.Symbol))
+	mux.Handle("GET /debug/pprof/trace", s.adminAuth(pprof.Trace))
+}
+
+// adminAuth rejects requests without the admin token. Tenant tokens are
+// not accepted.
+func (s *Server) adminAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		tenantID, valid := s.tenantForToken(token)
+		if !ok || !valid || tenantID != "" {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing admin token"))
+			return
+		}
+		next(w, r)
+	})
+}
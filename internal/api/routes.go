@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+type routeResponse struct {
+	ID                int64     `json:"id"`
+	Type              string    `json:"type,omitempty"`
+	Repo              string    `json:"repo,omitempty"`
+	Label             string    `json:"label,omitempty"`
+	Keyword           string    `json:"keyword,omitempty"`
+	Severity          string    `json:"severity,omitempty"`
+	DestinationChatID int64     `json:"destination_chat_id,omitempty"`
+	Silent            bool      `json:"silent"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type addRouteRequest struct {
+	Type              string `json:"type"`
+	Repo              string `json:"repo"`
+	Label             string `json:"label"`
+	Keyword           string `json:"keyword"`
+	Severity          string `json:"severity"`
+	DestinationChatID int64  `json:"destination_chat_id"`
+	Silent            bool   `json:"silent"`
+}
+
+// handleListRoutes lists the chat's routing rules in the order they apply.
+func (s *Server) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rules, err := s.store.GetRoutingRules(r.Context(), chatID)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	response := make([]routeResponse, len(rules))
+	for i, rule := range rules {
+		response[i] = newRouteResponse(rule)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleAddRoute adds a routing rule after the chat's other rules. Unlike
+// /route, the API does not check that the user administers the
+// destination chat, but a tenant's token may only route to its own chats.
+func (s *Server) handleAddRoute(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req addRouteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	rule := models.RoutingRule{
+		ChatID:            chatID,
+		Conditions:        models.Conditions{Type: req.Type, Repo: req.Repo, Label: req.Label, Keyword: req.Keyword},
+		Severity:          req.Severity,
+		DestinationChatID: req.DestinationChatID,
+		Silent:            req.Silent,
+	}
+	if err := rule.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if tenantID, ok := store.TenantFromContext(r.Context()); ok && rule.DestinationChatID != 0 {
+		owner, found, err := s.store.ChatTenant(r.Context(), rule.DestinationChatID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if !found || owner != tenantID {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("destination chat %d is not a chat of the tenant", rule.DestinationChatID))
+			return
+		}
+	}
+
+	rule.ID, err = s.store.AddRoutingRule(r.Context(), rule)
+	if err != nil {
+		writeStoreError(w, err)
+		return
+	}
+	rule.CreatedAt = time.Now()
+
+	writeJSON(w, http.StatusCreated, newRouteResponse(rule))
+}
+
+func (s *Server) handleRemoveRoute(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid routing rule ID: %q", r.PathValue("id")))
+		return
+	}
+
+	if err := s.store.RemoveRoutingRule(r.Context(), chatID, id); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newRouteResponse(rule models.RoutingRule) routeResponse {
+	return routeResponse{
+		ID:                rule.ID,
+		Type:              rule.Type,
+		Repo:              rule.Repo,
+		Label:             rule.Label,
+		Keyword:           rule.Keyword,
+		Severity:          rule.Severity,
+		DestinationChatID: rule.DestinationChatID,
+		Silent:            rule.Silent,
+		CreatedAt:         rule.CreatedAt,
+	}
+}
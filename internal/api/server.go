@@ -0,0 +1,374 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/graphql-go/graphql"
+)
+
+type Server struct {
+	store  store.Store
+	tokens map[string]string
+	schema graphql.Schema
+	poller Poller
+}
+
+type accountResponse struct {
+	Username  string     `json:"username"`
+	IsActive  bool       `json:"is_active"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Note      string     `json:"note,omitempty"`
+	AddedAt   *time.Time `json:"added_at,omitempty"`
+}
+
+type subscriptionsResponse struct {
+	ChatID     int64             `json:"chat_id"`
+	Accounts   []accountResponse `json:"accounts"`
+	MutedRepos []string          `json:"muted_repos"`
+}
+
+type addAccountRequest struct {
+	Username  string    `json:"username"`
+	Token     string    `json:"token"`
+	Scopes    []string  `json:"scopes"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Note      string    `json:"note"`
+}
+
+type muteRequest struct {
+	Repo string `json:"repo"`
+}
+
+func New(store store.Store, token string) (*Server, error) {
+	s := &Server{
+		store:  store,
+		tokens: make(map[string]string),
+	}
+	if token != "" {
+		s.tokens[token] = ""
+	}
+
+	schema, err := s.newSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build GraphQL schema: %v", err)
+	}
+	s.schema = schema
+
+	return s, nil
+}
+
+// AddTenant accepts the tenant's API token, scoping requests made with it
+// to the tenant's users.
+func (s *Server) AddTenant(tenant models.Tenant) {
+	if tenant.APIToken != "" {
+		s.tokens[tenant.APIToken] = tenant.ID
+	}
+}
+
+// Register mounts the API routes on mux under /api/v1, and the profiling
+// endpoints under /debug/pprof.
+func (s *Server) Register(mux *http.ServeMux) {
+	mux.Handle("GET /api/v1/users", s.auth(s.handleListUsers))
+	mux.Handle("GET /api/v1/users/{chatID}/accounts", s.auth(s.handleListAccounts))
+	mux.Handle("POST /api/v1/users/{chatID}/accounts", s.auth(s.handleAddAccount))
+	mux.Handle("DELETE /api/v1/users/{chatID}/accounts/{username}", s.auth(s.handleRemoveAccount))
+	mux.Handle("POST /api/v1/users/{chatID}/accounts/{username}/toggle", s.auth(s.handleToggleAccount))
+	mux.Handle("GET /api/v1/users/{chatID}/accounts/{username}/state", s.auth(s.handleGetAccountState))
+	mux.Handle("POST /api/v1/users/{chatID}/accounts/{username}/poll", s.auth(s.handlePollAccount))
+	mux.Handle("GET /api/v1/users/{chatID}/subscriptions", s.auth(s.handleListSubscriptions))
+	mux.Handle("POST /api/v1/users/{chatID}/mutes", s.auth(s.handleMute))
+	mux.Handle("DELETE /api/v1/users/{chatID}/mutes/{owner}/{repo}", s.auth(s.handleUnmute))
+	mux.Handle("GET /api/v1/users/{chatID}/routes", s.auth(s.handleListRoutes))
+	mux.Handle("POST /api/v1/users/{chatID}/routes", s.auth(s.handleAddRoute))
+	mux.Handle("DELETE /api/v1/users/{chatID}/routes/{id}", s.auth(s.handleRemoveRoute))
+	mux.Handle("GET /api/v1/outbox", s.auth(s.handleListOutbox))
+	mux.Handle("POST /api/v1/outbox/{id}/requeue", s.auth(s.handleRequeueOutbox))
+	mux.Handle("GET /api/v1/graphql", s.auth(s.handleGraphQL))
+	mux.Handle("POST /api/v1/graphql", s.auth(s.handleGraphQL))
+	s.registerProfiling(mux)
+}
+
+func (s *Server) auth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		tenantID, valid := s.tenantForToken(token)
+		if !ok || !valid {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing API token"))
+			return
+		}
+
+		if tenantID != "" {
+			ctx := store.WithTenant(r.Context(), tenantID)
+			if chatID, err := parseChatID(r); err == nil {
+				owner, found, err := s.store.ChatTenant(ctx, chatID)
+				if err != nil {
+					writeError(w, http.StatusInternalServerError, err)
+					return
+				}
+				if found && owner != tenantID {
+					writeError(w, http.StatusNotFound, store.ErrUserNotFound)
+					return
+				}
+			}
+			r = r.WithContext(ctx)
+		}
+		next(w, r)
+	})
+}
+
+// tenantForToken returns the tenant a token is scoped to, or "" for the
+// admin token, which sees every tenant.
+func (s *Server) tenantForToken(token string) (string, bool) {
+	var tenantID string
+	valid := false
+	for candidate, id := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			tenantID, valid = id, true
+		}
+	}
+	return tenantID, valid
+}
+
+func (s *Server) handleListAccounts(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	subscriptions, ok := s.subscriptions(r.Context(), chatID)
+	if !ok {
+		writeError(w, http.StatusNotFound, store.ErrUserNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscriptions.Accounts)
+}
+
+func (s *Server) handleAddAccount(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req addAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if req.Username == "" || req.Token == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("username and token are required"))
+		return
+	}
+
+	metadata := models.GitHubAccountMetadata{Scopes: req.Scopes, ExpiresAt: req.ExpiresAt, Note: req.Note}
+	previous := s.accountToken(r.Context(), chatID, req.Username)
+	if err := s.store.AddGitHubAccount(r.Context(), chatID, req.Token, req.Username, metadata); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if previous != "" && previous != req.Token {
+		store.ForgetToken(r.Context(), s.store, previous)
+	}
+
+	user, exists := s.store.GetUser(r.Context(), chatID)
+	if !exists || user.Accounts[req.Username] == nil {
+		writeError(w, http.StatusNotFound, store.ErrAccountNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newAccountResponse(user.Accounts[req.Username]))
+}
+
+// accountToken returns the token of the chat's GitHub account, or "" when
+// the chat has no such account.
+func (s *Server) accountToken(ctx context.Context, chatID int64, username string) string {
+	user, exists := s.store.GetUser(ctx, chatID)
+	if !exists || user.Accounts[username] == nil {
+		return ""
+	}
+	return user.Accounts[username].Token
+}
+
+func (s *Server) handleRemoveAccount(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	username := r.PathValue("username")
+	token := s.accountToken(r.Context(), chatID, username)
+	if err := s.store.RemoveGitHubAccount(r.Context(), chatID, username); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if token != "" {
+		store.ForgetToken(r.Context(), s.store, token)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleToggleAccount(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := s.store.ToggleGitHubAccount(r.Context(), chatID, username); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	user, exists := s.store.GetUser(r.Context(), chatID)
+	if !exists || user.Accounts[username] == nil {
+		writeError(w, http.StatusNotFound, store.ErrAccountNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newAccountResponse(user.Accounts[username]))
+}
+
+func (s *Server) handleListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	subscriptions, ok := s.subscriptions(r.Context(), chatID)
+	if !ok {
+		writeError(w, http.StatusNotFound, store.ErrUserNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscriptions)
+}
+
+func (s *Server) handleMute(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req muteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %v", err))
+		return
+	}
+	if !isRepoName(req.Repo) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("repo must be in owner/name format"))
+		return
+	}
+
+	if err := s.store.MuteRepo(r.Context(), chatID, req.Repo); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleUnmute(w http.ResponseWriter, r *http.Request) {
+	chatID, err := parseChatID(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	repo := r.PathValue("owner") + "/" + r.PathValue("repo")
+	if err := s.store.UnmuteRepo(r.Context(), chatID, repo); err != nil {
+		writeStoreError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newAccountResponse(account *models.GitHubAccount) accountResponse {
+	response := accountResponse{
+		Username: account.Username,
+		IsActive: account.IsActive,
+		Scopes:   account.Scopes,
+		Note:     account.Note,
+	}
+	if !account.ExpiresAt.IsZero() {
+		response.ExpiresAt = &account.ExpiresAt
+	}
+	if !account.AddedAt.IsZero() {
+		response.AddedAt = &account.AddedAt
+	}
+	return response
+}
+
+func (s *Server) subscriptions(ctx context.Context, chatID int64) (*subscriptionsResponse, bool) {
+	user, exists := s.store.GetUser(ctx, chatID)
+	if !exists {
+		return nil, false
+	}
+
+	response := &subscriptionsResponse{
+		ChatID:     chatID,
+		Accounts:   []accountResponse{},
+		MutedRepos: []string{},
+	}
+	for _, account := range user.Accounts {
+		response.Accounts = append(response.Accounts, newAccountResponse(account))
+	}
+	for repo := range user.MutedRepos {
+		response.MutedRepos = append(response.MutedRepos, repo)
+	}
+	sort.Slice(response.Accounts, func(i, j int) bool { return response.Accounts[i].Username < response.Accounts[j].Username })
+	sort.Strings(response.MutedRepos)
+
+	return response, true
+}
+
+func parseChatID(r *http.Request) (int64, error) {
+	chatID, err := strconv.ParseInt(r.PathValue("chatID"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid chat ID: %q", r.PathValue("chatID"))
+	}
+	return chatID, nil
+}
+
+func isRepoName(repo string) bool {
+	owner, name, ok := strings.Cut(repo, "/")
+	return ok && owner != "" && name != "" && !strings.Contains(name, "/")
+}
+
+func writeStoreError(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrUserNotFound) || errors.Is(err, store.ErrAccountNotFound) || errors.Is(err, store.ErrOutboxNotFound) || errors.Is(err, store.ErrRuleNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": redact.String(err.Error())})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,382 @@
+package backup
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+const formatVersion = 1
+
+// Backup is the JSON document written by "monitor export". Tokens,
+// passwords and API keys are encrypted; everything else is plain text.
+type Backup struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Salt      string    `json:"salt"`
+	Users     []User    `json:"users"`
+}
+
+type User struct {
+	ChatID                  int64                    `json:"chat_id"`
+	TenantID                string                   `json:"tenant_id,omitempty"`
+	GitHubAccounts          []GitHubAccount          `json:"github_accounts,omitempty"`
+	GerritAccounts          []GerritAccount          `json:"gerrit_accounts,omitempty"`
+	MutedRepos              []string                 `json:"muted_repos,omitempty"`
+	ImageSubscriptions      []ImageSubscription      `json:"image_subscriptions,omitempty"`
+	DependencySubscriptions []DependencySubscription `json:"dependency_subscriptions,omitempty"`
+	RepoSubscriptions       []RepoSubscription       `json:"repo_subscriptions,omitempty"`
+	PathSubscriptions       []PathSubscription       `json:"path_subscriptions,omitempty"`
+	RoutingRules            []RoutingRule            `json:"routing_rules,omitempty"`
+	Jira                    *JiraConfig              `json:"jira,omitempty"`
+	Linear                  *LinearConfig            `json:"linear,omitempty"`
+	Preferences             *Preferences             `json:"preferences,omitempty"`
+}
+
+type GitHubAccount struct {
+	Username       string     `json:"username"`
+	EncryptedToken string     `json:"encrypted_token"`
+	IsActive       bool       `json:"is_active"`
+	Scopes         []string   `json:"scopes,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	Note           string     `json:"note,omitempty"`
+	// DisabledReasons are the reasons of the account's preferences.
+	DisabledReasons []string `json:"disabled_reasons,omitempty"`
+	SecurityAlerts  bool     `json:"security_alerts,omitempty"`
+}
+
+type GerritAccount struct {
+	BaseURL           string `json:"base_url"`
+	Username          string `json:"username"`
+	EncryptedPassword string `json:"encrypted_password"`
+	IsActive          bool   `json:"is_active"`
+}
+
+type ImageSubscription struct {
+	Image     string   `json:"image"`
+	TagFilter string   `json:"tag_filter,omitempty"`
+	SeenTags  []string `json:"seen_tags,omitempty"`
+}
+
+type DependencySubscription struct {
+	Repo         string   `json:"repo"`
+	SeenVersions []string `json:"seen_versions,omitempty"`
+}
+
+type RepoSubscription struct {
+	Repo              string             `json:"repo"`
+	EventTypes        []string           `json:"event_types,omitempty"`
+	Filters           models.RepoFilters `json:"filters"`
+	DestinationChatID int64              `json:"destination_chat_id,omitempty"`
+}
+
+type PathSubscription struct {
+	Repo    string `json:"repo"`
+	Pattern string `json:"pattern"`
+}
+
+type RoutingRule struct {
+	Type              string `json:"type,omitempty"`
+	Repo              string `json:"repo,omitempty"`
+	Label             string `json:"label,omitempty"`
+	Keyword           string `json:"keyword,omitempty"`
+	Severity          string `json:"severity,omitempty"`
+	DestinationChatID int64  `json:"destination_chat_id,omitempty"`
+	Silent            bool   `json:"silent,omitempty"`
+}
+
+type JiraConfig struct {
+	BaseURL           string `json:"base_url"`
+	Email             string `json:"email"`
+	EncryptedAPIToken string `json:"encrypted_api_token"`
+	ProjectKey        string `json:"project_key"`
+	IssueType         string `json:"issue_type"`
+}
+
+type LinearConfig struct {
+	EncryptedAPIKey string                  `json:"encrypted_api_key"`
+	Default         LinearTarget            `json:"default"`
+	Mappings        map[string]LinearTarget `json:"mappings,omitempty"`
+}
+
+type Preferences struct {
+	QuietHoursStart    string            `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd      string            `json:"quiet_hours_end,omitempty"`
+	Timezone           string            `json:"timezone"`
+	DigestMode         string            `json:"digest_mode"`
+	DigestTime         string            `json:"digest_time,omitempty"`
+	Language           string            `json:"language"`
+	ParseMode          string            `json:"parse_mode"`
+	Priorities         map[string]string `json:"priorities,omitempty"`
+	RenotifyIntervals  map[string]int    `json:"renotify_intervals,omitempty"`
+	Paused             bool              `json:"paused,omitempty"`
+	PausedUntil        *time.Time        `json:"paused_until,omitempty"`
+	PollWindow         string            `json:"poll_window,omitempty"`
+	Filter             string            `json:"filter,omitempty"`
+	TeamDigest         string            `json:"team_digest,omitempty"`
+	TeamRepos          []string          `json:"team_repos,omitempty"`
+	MentionSLA         int               `json:"mention_sla,omitempty"`
+	TriageRepos        []string          `json:"triage_repos,omitempty"`
+	TriageAfter        int               `json:"triage_after_hours,omitempty"`
+	TriageLabels       []string          `json:"triage_labels,omitempty"`
+	Summaries          bool              `json:"summaries,omitempty"`
+	ChangelogRepos     []string          `json:"changelog_repos,omitempty"`
+	ChangelogAt        string            `json:"changelog_at,omitempty"`
+	UsageThreshold     int               `json:"usage_threshold,omitempty"`
+	UsageOrgs          []string          `json:"usage_orgs,omitempty"`
+	CommitMentionRepos []string          `json:"commit_mention_repos,omitempty"`
+}
+
+type LinearTarget struct {
+	TeamID    string `json:"team_id"`
+	ProjectID string `json:"project_id,omitempty"`
+}
+
+// Export collects every active user from the store.
+func Export(ctx context.Context, s store.Store, passphrase string) (*Backup, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	sealer, err := newSealer(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	backup := &Backup{
+		Version:   formatVersion,
+		CreatedAt: time.Now().UTC(),
+		Salt:      base64.StdEncoding.EncodeToString(salt),
+		Users:     []User{},
+	}
+	err = store.ForEachUser(ctx, s, func(user *models.User) error {
+		exported, err := exportUser(ctx, s, sealer, user)
+		if err != nil {
+			return fmt.Errorf("failed to export user %d: %v", user.ChatID, err)
+		}
+		backup.Users = append(backup.Users, *exported)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+func exportUser(ctx context.Context, s store.Store, sealer *sealer, user *models.User) (*User, error) {
+	exported := &User{ChatID: user.ChatID, TenantID: user.TenantID}
+
+	for _, account := range user.Accounts {
+		token, err := sealer.seal(account.Token)
+		if err != nil {
+			return nil, err
+		}
+		exportedAccount := GitHubAccount{
+			Username:       account.Username,
+			EncryptedToken: token,
+			IsActive:       account.IsActive,
+			Scopes:         account.Scopes,
+			Note:           account.Note,
+		}
+		if !account.ExpiresAt.IsZero() {
+			exportedAccount.ExpiresAt = &account.ExpiresAt
+		}
+		preferences, err := s.GetAccountPreferences(ctx, user.ChatID, account.Username)
+		if err != nil {
+			return nil, err
+		}
+		exportedAccount.DisabledReasons = preferences.DisabledReasons
+		exportedAccount.SecurityAlerts = preferences.SecurityAlerts
+		exported.GitHubAccounts = append(exported.GitHubAccounts, exportedAccount)
+	}
+	sort.Slice(exported.GitHubAccounts, func(i, j int) bool {
+		return exported.GitHubAccounts[i].Username < exported.GitHubAccounts[j].Username
+	})
+
+	for _, account := range user.GerritAccounts {
+		password, err := sealer.seal(account.Password)
+		if err != nil {
+			return nil, err
+		}
+		exported.GerritAccounts = append(exported.GerritAccounts, GerritAccount{
+			BaseURL:           account.BaseURL,
+			Username:          account.Username,
+			EncryptedPassword: password,
+			IsActive:          account.IsActive,
+		})
+	}
+
+	for repo := range user.MutedRepos {
+		exported.MutedRepos = append(exported.MutedRepos, repo)
+	}
+	sort.Strings(exported.MutedRepos)
+
+	images, err := s.GetImageSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, image := range images {
+		seen, err := s.GetSeenImageTags(ctx, image.ID)
+		if err != nil {
+			return nil, err
+		}
+		exported.ImageSubscriptions = append(exported.ImageSubscriptions, ImageSubscription{
+			Image:     image.Image,
+			TagFilter: image.TagFilter,
+			SeenTags:  sortedKeys(seen),
+		})
+	}
+
+	dependencies, err := s.GetDependencySubscriptions(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		seen, err := s.GetSeenDependencyVersions(ctx, dependency.ID)
+		if err != nil {
+			return nil, err
+		}
+		exported.DependencySubscriptions = append(exported.DependencySubscriptions, DependencySubscription{
+			Repo:         dependency.Repo,
+			SeenVersions: sortedKeys(seen),
+		})
+	}
+
+	repos, err := s.GetRepoSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		exported.RepoSubscriptions = append(exported.RepoSubscriptions, RepoSubscription{
+			Repo:              repo.Repo,
+			EventTypes:        repo.EventTypes,
+			Filters:           repo.Filters,
+			DestinationChatID: repo.DestinationChatID,
+		})
+	}
+
+	paths, err := s.GetPathSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		exported.PathSubscriptions = append(exported.PathSubscriptions, PathSubscription{Repo: path.Repo, Pattern: path.Pattern})
+	}
+
+	rules, err := s.GetRoutingRules(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		exported.RoutingRules = append(exported.RoutingRules, RoutingRule{
+			Type:              rule.Type,
+			Repo:              rule.Repo,
+			Label:             rule.Label,
+			Keyword:           rule.Keyword,
+			Severity:          rule.Severity,
+			DestinationChatID: rule.DestinationChatID,
+			Silent:            rule.Silent,
+		})
+	}
+
+	if jira, ok := s.GetJiraConfig(ctx, user.ChatID); ok {
+		token, err := sealer.seal(jira.APIToken)
+		if err != nil {
+			return nil, err
+		}
+		exported.Jira = &JiraConfig{
+			BaseURL:           jira.BaseURL,
+			Email:             jira.Email,
+			EncryptedAPIToken: token,
+			ProjectKey:        jira.ProjectKey,
+			IssueType:         jira.IssueType,
+		}
+	}
+
+	if linear, ok := s.GetLinearConfig(ctx, user.ChatID); ok {
+		apiKey, err := sealer.seal(linear.APIKey)
+		if err != nil {
+			return nil, err
+		}
+		exported.Linear = &LinearConfig{
+			EncryptedAPIKey: apiKey,
+			Default:         LinearTarget(linear.Default),
+		}
+		for repo, target := range linear.Mappings {
+			if exported.Linear.Mappings == nil {
+				exported.Linear.Mappings = make(map[string]LinearTarget)
+			}
+			exported.Linear.Mappings[repo] = LinearTarget(target)
+		}
+	}
+
+	preferences, err := s.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	exported.Preferences = &Preferences{
+		QuietHoursStart:    preferences.QuietHoursStart,
+		QuietHoursEnd:      preferences.QuietHoursEnd,
+		Timezone:           preferences.Timezone,
+		DigestMode:         preferences.DigestMode,
+		DigestTime:         preferences.DigestTime,
+		Language:           preferences.Language,
+		ParseMode:          preferences.ParseMode,
+		Priorities:         preferences.Priorities,
+		RenotifyIntervals:  preferences.RenotifyIntervals,
+		Paused:             preferences.Paused,
+		PollWindow:         preferences.PollWindow,
+		Filter:             preferences.Filter,
+		TeamDigest:         preferences.TeamDigest,
+		TeamRepos:          preferences.TeamRepos,
+		MentionSLA:         preferences.MentionSLA,
+		TriageRepos:        preferences.Triage.Repos,
+		TriageAfter:        preferences.Triage.After,
+		TriageLabels:       preferences.Triage.Labels,
+		Summaries:          preferences.Summaries,
+		ChangelogRepos:     preferences.Changelog.Repos,
+		ChangelogAt:        preferences.Changelog.At,
+		UsageThreshold:     preferences.Usage.Threshold,
+		UsageOrgs:          preferences.Usage.Orgs,
+		CommitMentionRepos: preferences.CommitMentionRepos,
+	}
+	if !preferences.PausedUntil.IsZero() {
+		exported.Preferences.PausedUntil = &preferences.PausedUntil
+	}
+
+	return exported, nil
+}
+
+// WriteFile writes the backup readable by the owner only, since it holds
+// the chat IDs and account names of every user.
+func (b *Backup) WriteFile(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode backup: %v", err)
+	}
+
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write backup: %v", err)
+	}
+
+	return nil
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+)
+
+const testChatID = 42
+
+// newTestStore returns a store with one user holding a secret of every
+// kind, and some settings without secrets.
+func newTestStore(t *testing.T) *memory.Store {
+	t.Helper()
+	ctx := context.Background()
+	s := memory.New()
+	if err := s.AddGitHubAccount(ctx, testChatID, "ghp_backup-test-token", "alice", models.GitHubAccountMetadata{Scopes: []string{"repo"}, Note: "work"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddGerritAccount(ctx, testChatID, models.GerritAccount{BaseURL: "https://review.example.com", Username: "alice", Password: "gerrit-password", IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetJiraConfig(ctx, models.JiraConfig{ChatID: testChatID, BaseURL: "https://acme.atlassian.net", Email: "alice@example.com", APIToken: "jira-token", ProjectKey: "OPS", IssueType: "Task"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.MuteRepo(ctx, testChatID, "acme/noisy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddRepoSubscription(ctx, models.RepoSubscription{ChatID: testChatID, Repo: "acme/api", EventTypes: []string{"release"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddRoutingRule(ctx, models.RoutingRule{ChatID: testChatID, Conditions: models.Conditions{Repo: "acme/*"}, Silent: true}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// withoutSecrets returns the users of the backup with their encrypted
+// values cleared, as those differ between exports.
+func withoutSecrets(backup *Backup) []User {
+	var users []User
+	for _, user := range backup.Users {
+		user.GitHubAccounts = append([]GitHubAccount(nil), user.GitHubAccounts...)
+		for i := range user.GitHubAccounts {
+			user.GitHubAccounts[i].EncryptedToken = ""
+		}
+		user.GerritAccounts = append([]GerritAccount(nil), user.GerritAccounts...)
+		for i := range user.GerritAccounts {
+			user.GerritAccounts[i].EncryptedPassword = ""
+		}
+		if user.Jira != nil {
+			jira := *user.Jira
+			jira.EncryptedAPIToken = ""
+			user.Jira = &jira
+		}
+		users = append(users, user)
+	}
+	return users
+}
+
+// TestRoundTrip exports a store to a file and imports it into an empty
+// store, which then exports the same users with the same secrets.
+func TestRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	exported, err := Export(ctx, newTestStore(t), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.json")
+	if err := exported.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"ghp_backup-test-token", "gerrit-password", "jira-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("backup contains %q in plain text", secret)
+		}
+	}
+
+	backup, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := memory.New()
+	result, err := Import(ctx, restored, backup, "correct horse", ConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ImportResult{Created: 1}) {
+		t.Errorf("Import = %+v, want one user created", result)
+	}
+
+	user, ok := restored.GetUser(ctx, testChatID)
+	if !ok {
+		t.Fatal("restored store has no user")
+	}
+	if account := user.Accounts["alice"]; account == nil || account.Token != "ghp_backup-test-token" {
+		t.Errorf("restored GitHub account = %+v", account)
+	}
+	if len(user.GerritAccounts) != 1 || user.GerritAccounts[0].Password != "gerrit-password" {
+		t.Errorf("restored Gerrit accounts = %+v", user.GerritAccounts)
+	}
+	if jira, ok := restored.GetJiraConfig(ctx, testChatID); !ok || jira.APIToken != "jira-token" {
+		t.Errorf("restored Jira config = %+v", jira)
+	}
+
+	reexported, err := Export(ctx, restored, "another passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Compared as JSON, as the backup does not tell nil and empty lists
+	// apart.
+	got, _ := json.Marshal(withoutSecrets(reexported))
+	want, _ := json.Marshal(withoutSecrets(exported))
+	if string(got) != string(want) {
+		t.Errorf("restored store exports %s, want %s", got, want)
+	}
+}
+
+func TestImportWrongPassphrase(t *testing.T) {
+	ctx := context.Background()
+	backup, err := Export(ctx, newTestStore(t), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := memory.New()
+	_, err = Import(ctx, restored, backup, "battery staple", ConflictSkip)
+	if want := "failed to import user 42: failed to decrypt secret, wrong passphrase?"; err == nil || err.Error() != want {
+		t.Errorf("Import = %v, want error %q", err, want)
+	}
+	// The GitHub accounts come first and create the user, so nothing
+	// was imported.
+	if _, ok := restored.GetUser(ctx, testChatID); ok {
+		t.Error("Import with the wrong passphrase created the user")
+	}
+
+	if _, err := Import(ctx, restored, backup, "", ConflictSkip); err == nil || err.Error() != "a passphrase is required to encrypt or decrypt secrets" {
+		t.Errorf("Import without a passphrase = %v", err)
+	}
+}
@@ -0,0 +1,418 @@
+package backup
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// ConflictMode decides what happens to users that already exist in the
+// target store.
+type ConflictMode string
+
+const (
+	// ConflictSkip leaves existing users untouched.
+	ConflictSkip ConflictMode = "skip"
+	// ConflictOverwrite replaces everything an existing user has with the
+	// backup.
+	ConflictOverwrite ConflictMode = "overwrite"
+	// ConflictMerge adds what the existing user is missing and keeps what
+	// they already have.
+	ConflictMerge ConflictMode = "merge"
+)
+
+func ParseConflictMode(mode string) (ConflictMode, error) {
+	switch ConflictMode(mode) {
+	case ConflictSkip, ConflictOverwrite, ConflictMerge:
+		return ConflictMode(mode), nil
+	default:
+		return "", fmt.Errorf("invalid conflict mode %q, expected skip, overwrite or merge", mode)
+	}
+}
+
+type ImportResult struct {
+	Created int
+	Updated int
+	Skipped int
+}
+
+func ReadFile(path string) (*Backup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %v", err)
+	}
+
+	var backup Backup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to decode backup: %v", err)
+	}
+	if backup.Version != formatVersion {
+		return nil, fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+
+	return &backup, nil
+}
+
+// Import restores the users of the backup into the store.
+func Import(ctx context.Context, s store.Store, backup *Backup, passphrase string, mode ConflictMode) (ImportResult, error) {
+	var result ImportResult
+
+	salt, err := base64.StdEncoding.DecodeString(backup.Salt)
+	if err != nil {
+		return result, fmt.Errorf("invalid backup salt: %v", err)
+	}
+
+	sealer, err := newSealer(passphrase, salt)
+	if err != nil {
+		return result, err
+	}
+
+	for _, user := range backup.Users {
+		existing, exists := s.GetUser(ctx, user.ChatID)
+		switch {
+		case !exists:
+			existing = nil
+			result.Created++
+		case mode == ConflictSkip:
+			result.Skipped++
+			continue
+		case mode == ConflictOverwrite:
+			if err := clearUser(ctx, s, existing); err != nil {
+				return result, fmt.Errorf("failed to clear user %d: %v", user.ChatID, err)
+			}
+			existing = nil
+			result.Updated++
+		default:
+			result.Updated++
+		}
+
+		userCtx := ctx
+		if user.TenantID != "" {
+			userCtx = store.WithTenant(ctx, user.TenantID)
+		}
+		if err := importUser(userCtx, s, sealer, user, existing); err != nil {
+			return result, fmt.Errorf("failed to import user %d: %v", user.ChatID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// importUser adds the backed up user. When existing is set, items the user
+// already has are kept as they are.
+func importUser(ctx context.Context, s store.Store, sealer *sealer, user User, existing *models.User) error {
+	chatID := user.ChatID
+
+	hasGitHub := func(username string) bool {
+		return existing != nil && existing.Accounts[username] != nil
+	}
+	hasGerrit := func(baseURL, username string) bool {
+		if existing == nil {
+			return false
+		}
+		for _, account := range existing.GerritAccounts {
+			if account.BaseURL == baseURL && account.Username == username {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Accounts come first since they create the user.
+	for _, account := range user.GitHubAccounts {
+		if hasGitHub(account.Username) {
+			continue
+		}
+		token, err := sealer.open(account.EncryptedToken)
+		if err != nil {
+			return err
+		}
+		metadata := models.GitHubAccountMetadata{Scopes: account.Scopes, Note: account.Note}
+		if account.ExpiresAt != nil {
+			metadata.ExpiresAt = *account.ExpiresAt
+		}
+		if err := s.AddGitHubAccount(ctx, chatID, token, account.Username, metadata); err != nil {
+			return err
+		}
+		if !account.IsActive {
+			if err := s.ToggleGitHubAccount(ctx, chatID, account.Username); err != nil {
+				return err
+			}
+		}
+		if len(account.DisabledReasons) > 0 || account.SecurityAlerts {
+			preferences := models.AccountPreferences{ChatID: chatID, Username: account.Username, DisabledReasons: account.DisabledReasons, SecurityAlerts: account.SecurityAlerts}
+			if err := s.SetAccountPreferences(ctx, preferences); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, account := range user.GerritAccounts {
+		if hasGerrit(account.BaseURL, account.Username) {
+			continue
+		}
+		password, err := sealer.open(account.EncryptedPassword)
+		if err != nil {
+			return err
+		}
+		gerritAccount := models.GerritAccount{BaseURL: account.BaseURL, Username: account.Username, Password: password, IsActive: true}
+		if err := s.AddGerritAccount(ctx, chatID, gerritAccount); err != nil {
+			return err
+		}
+	}
+
+	for _, repo := range user.MutedRepos {
+		if err := s.MuteRepo(ctx, chatID, repo); err != nil {
+			return err
+		}
+	}
+
+	images, err := s.GetImageSubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	subscribedImages := make(map[string]bool)
+	for _, image := range images {
+		subscribedImages[image.Image] = true
+	}
+	for _, image := range user.ImageSubscriptions {
+		if subscribedImages[image.Image] {
+			continue
+		}
+		if err := s.AddImageSubscription(ctx, chatID, image.Image, image.TagFilter, image.SeenTags); err != nil {
+			return err
+		}
+	}
+
+	dependencies, err := s.GetDependencySubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	subscribedRepos := make(map[string]bool)
+	for _, dependency := range dependencies {
+		subscribedRepos[dependency.Repo] = true
+	}
+	for _, dependency := range user.DependencySubscriptions {
+		if subscribedRepos[dependency.Repo] {
+			continue
+		}
+		if err := s.AddDependencySubscription(ctx, chatID, dependency.Repo, dependency.SeenVersions); err != nil {
+			return err
+		}
+	}
+
+	repos, err := s.GetRepoSubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	watchedRepos := make(map[string]bool)
+	for _, repo := range repos {
+		watchedRepos[repo.Repo] = true
+	}
+	for _, repo := range user.RepoSubscriptions {
+		if watchedRepos[repo.Repo] {
+			continue
+		}
+		subscription := models.RepoSubscription{
+			ChatID:            chatID,
+			Repo:              repo.Repo,
+			EventTypes:        repo.EventTypes,
+			Filters:           repo.Filters,
+			DestinationChatID: repo.DestinationChatID,
+		}
+		if _, err := s.AddRepoSubscription(ctx, subscription); err != nil {
+			return err
+		}
+	}
+
+	// Adding an existing path subscription keeps it as it is.
+	for _, path := range user.PathSubscriptions {
+		subscription := models.PathSubscription{ChatID: chatID, Repo: path.Repo, Pattern: path.Pattern}
+		if _, err := s.AddPathSubscription(ctx, subscription); err != nil {
+			return err
+		}
+	}
+
+	// Rules apply in order, so they are only restored for users without
+	// rules of their own rather than merged.
+	rules, err := s.GetRoutingRules(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		for _, rule := range user.RoutingRules {
+			routingRule := models.RoutingRule{
+				ChatID:            chatID,
+				Conditions:        models.Conditions{Type: rule.Type, Repo: rule.Repo, Label: rule.Label, Keyword: rule.Keyword},
+				Severity:          rule.Severity,
+				DestinationChatID: rule.DestinationChatID,
+				Silent:            rule.Silent,
+			}
+			if _, err := s.AddRoutingRule(ctx, routingRule); err != nil {
+				return err
+			}
+		}
+	}
+
+	if user.Jira != nil {
+		if _, ok := s.GetJiraConfig(ctx, chatID); !ok {
+			token, err := sealer.open(user.Jira.EncryptedAPIToken)
+			if err != nil {
+				return err
+			}
+			config := models.JiraConfig{
+				ChatID:     chatID,
+				BaseURL:    user.Jira.BaseURL,
+				Email:      user.Jira.Email,
+				APIToken:   token,
+				ProjectKey: user.Jira.ProjectKey,
+				IssueType:  user.Jira.IssueType,
+			}
+			if err := s.SetJiraConfig(ctx, config); err != nil {
+				return err
+			}
+		}
+	}
+
+	if user.Linear != nil {
+		config, configured := s.GetLinearConfig(ctx, chatID)
+		if !configured {
+			apiKey, err := sealer.open(user.Linear.EncryptedAPIKey)
+			if err != nil {
+				return err
+			}
+			if err := s.SetLinearConfig(ctx, chatID, apiKey, models.LinearTarget(user.Linear.Default)); err != nil {
+				return err
+			}
+		}
+		for repo, target := range user.Linear.Mappings {
+			if configured {
+				if _, mapped := config.Mappings[repo]; mapped {
+					continue
+				}
+			}
+			if err := s.SetLinearMapping(ctx, chatID, repo, models.LinearTarget(target)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Preferences always exist with defaults, so a merge keeps the ones the
+	// existing user has.
+	if user.Preferences != nil && existing == nil {
+		preferences := models.Preferences{
+			ChatID:            chatID,
+			QuietHoursStart:   user.Preferences.QuietHoursStart,
+			QuietHoursEnd:     user.Preferences.QuietHoursEnd,
+			Timezone:          user.Preferences.Timezone,
+			DigestMode:        user.Preferences.DigestMode,
+			DigestTime:        user.Preferences.DigestTime,
+			Language:          user.Preferences.Language,
+			ParseMode:         user.Preferences.ParseMode,
+			Priorities:        user.Preferences.Priorities,
+			RenotifyIntervals: user.Preferences.RenotifyIntervals,
+			Paused:            user.Preferences.Paused,
+			PollWindow:        user.Preferences.PollWindow,
+			Filter:            user.Preferences.Filter,
+			TeamDigest:        user.Preferences.TeamDigest,
+			TeamRepos:         user.Preferences.TeamRepos,
+			MentionSLA:        user.Preferences.MentionSLA,
+			Triage: models.Triage{
+				Repos:  user.Preferences.TriageRepos,
+				After:  user.Preferences.TriageAfter,
+				Labels: user.Preferences.TriageLabels,
+			},
+			Summaries:          user.Preferences.Summaries,
+			Changelog:          models.Changelog{Repos: user.Preferences.ChangelogRepos, At: user.Preferences.ChangelogAt},
+			Usage:              models.UsageAlerts{Threshold: user.Preferences.UsageThreshold, Orgs: user.Preferences.UsageOrgs},
+			CommitMentionRepos: user.Preferences.CommitMentionRepos,
+		}
+		if user.Preferences.PausedUntil != nil {
+			preferences.PausedUntil = *user.Preferences.PausedUntil
+		}
+		if err := s.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// clearUser removes the subscriptions, mutes and integrations of an
+// existing user so the backup can replace them. Accounts are removed last
+// since removing the last one soft-deletes the user, which adding the
+// backed up accounts undoes.
+func clearUser(ctx context.Context, s store.Store, user *models.User) error {
+	chatID := user.ChatID
+
+	images, err := s.GetImageSubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if err := s.RemoveImageSubscription(ctx, chatID, image.Image); err != nil {
+			return err
+		}
+	}
+
+	dependencies, err := s.GetDependencySubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	for _, dependency := range dependencies {
+		if err := s.RemoveDependencySubscription(ctx, chatID, dependency.Repo); err != nil {
+			return err
+		}
+	}
+
+	repos, err := s.GetRepoSubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	for _, repo := range repos {
+		if err := s.RemoveRepoSubscription(ctx, chatID, repo.Repo); err != nil {
+			return err
+		}
+	}
+
+	paths, err := s.GetPathSubscriptions(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := s.RemovePathSubscription(ctx, chatID, path.Repo, path.Pattern); err != nil {
+			return err
+		}
+	}
+
+	for repo := range user.MutedRepos {
+		if err := s.UnmuteRepo(ctx, chatID, repo); err != nil {
+			return err
+		}
+	}
+
+	if err := s.RemoveJiraConfig(ctx, chatID); err != nil {
+		return err
+	}
+	if err := s.RemoveLinearConfig(ctx, chatID); err != nil {
+		return err
+	}
+
+	for username := range user.Accounts {
+		if err := s.RemoveGitHubAccount(ctx, chatID, username); err != nil {
+			return err
+		}
+	}
+	for _, account := range user.GerritAccounts {
+		if err := s.RemoveGerritAccount(ctx, chatID, account.BaseURL, account.Username); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
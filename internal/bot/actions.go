@@ -0,0 +1,168 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// The callback data of the buttons below a notification starts with the
+// action and carries the ID of the outbox entry the notification was sent
+// from, which the button's handler looks the notification up by. Telegram
+// limits callback data to 64 bytes.
+const (
+	// callbackJira and callbackLinear are followed by the entry ID.
+	callbackJira   = "jira:"
+	callbackLinear = "linear:"
+	// callbackTriage is followed by the entry ID and the label to apply.
+	callbackTriage = "triage:"
+	// callbackRead is followed by the entry ID of a GitHub notification
+	// thread to mark as read.
+	callbackRead = "read:"
+	// callbackReview is followed by the review event and the entry ID of
+	// a review button, callbackConfirmReview by those of the button
+	// confirming it.
+	callbackReview        = "review:"
+	callbackConfirmReview = "confirm:"
+)
+
+// NotificationActions returns the inline buttons offered below the
+// notification of an outbox entry, depending on the integrations the chat
+// has configured and, for untriaged issues, the chat's triage labels.
+// Notifications read from GitHub's notifications can be marked as read
+// there, and review requests reviewed.
+func NotificationActions(ctx context.Context, store store.Store, entry models.OutboxEntry) []tgbotapi.InlineKeyboardButton {
+	var actions []tgbotapi.InlineKeyboardButton
+	chatID, notification := entry.ChatID, entry.Notification
+	id := strconv.FormatInt(entry.ID, 10)
+
+	if notification.ThreadID != "" && notification.Account != "" {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("✅ Mark as read", callbackRead+id))
+	}
+	actions = append(actions, reviewActions(entry)...)
+
+	if notification.Type == "triage" {
+		preferences, err := store.GetPreferences(ctx, chatID)
+		if err != nil {
+			log.Printf("Error getting triage labels of chat %d: %v", chatID, err)
+		}
+		for _, label := range preferences.Triage.Labels {
+			if data := callbackTriage + id + ":" + label; len(data) <= 64 {
+				actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("🏷 "+label, data))
+			}
+		}
+	}
+
+	if _, ok := store.GetJiraConfig(ctx, chatID); ok {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("📝 Create Jira ticket", callbackJira+id))
+	}
+	if _, ok := store.GetLinearConfig(ctx, chatID); ok {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("📐 Create Linear issue", callbackLinear+id))
+	}
+
+	return actions
+}
+
+// buttonNotification returns the notification a button was sent with,
+// looked up by the outbox entry ID in its callback data. The entry must
+// belong to the chat the button was pressed in.
+func (h *Handler) buttonNotification(ctx context.Context, query *tgbotapi.CallbackQuery, entryID string) (models.Notification, error) {
+	id, err := strconv.ParseInt(entryID, 10, 64)
+	if err != nil {
+		return models.Notification{}, fmt.Errorf("this button is from an older version of the bot, open the notification on GitHub instead")
+	}
+
+	entry, err := h.store.GetOutboxEntry(ctx, id)
+	if errors.Is(err, store.ErrOutboxNotFound) || (err == nil && entry.ChatID != query.Message.Chat.ID) {
+		return models.Notification{}, fmt.Errorf("the notification is no longer stored, open it on GitHub instead")
+	}
+	if err != nil {
+		return models.Notification{}, err
+	}
+	return entry.Notification, nil
+}
+
+// summary returns the first line of the notification's message, e.g. as
+// the title of a ticket created from it.
+func summary(notification models.Notification) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(notification.Message), "\n")
+	return line
+}
+
+// replaceAction swaps the button identified by callbackData on the message
+// the callback query belongs to, e.g. with a link to the created ticket.
+func (h *Handler) replaceAction(query *tgbotapi.CallbackQuery, callbackData string, button tgbotapi.InlineKeyboardButton) {
+	if query.Message.ReplyMarkup == nil {
+		return
+	}
+
+	markup := *query.Message.ReplyMarkup
+	for i, row := range markup.InlineKeyboard {
+		for j, existing := range row {
+			if existing.CallbackData != nil && *existing.CallbackData == callbackData {
+				markup.InlineKeyboard[i][j] = button
+			}
+		}
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, markup)
+	_, _ = h.Bot.API.Request(edit)
+}
+
+// activeAccounts returns the chat's active GitHub accounts by username, the
+// order the buttons acting on GitHub try them in.
+func (h *Handler) activeAccounts(ctx context.Context, chatID int64) []*models.GitHubAccount {
+	user, exists := h.store.GetUser(ctx, chatID)
+	if !exists {
+		return nil
+	}
+	var accounts []*models.GitHubAccount
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			accounts = append(accounts, account)
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+	return accounts
+}
+
+// handleReadCallback marks the GitHub notification thread of the message
+// as read with the token of the account that received it.
+func (h *Handler) handleReadCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data string) (string, error) {
+	notification, err := h.buttonNotification(ctx, query, data)
+	if err != nil {
+		return "", err
+	}
+	username := notification.Account
+
+	user, exists := h.store.GetUser(ctx, query.Message.Chat.ID)
+	if !exists {
+		return "", fmt.Errorf("GitHub account %s not found", username)
+	}
+	account, ok := user.Accounts[username]
+	if !ok {
+		return "", fmt.Errorf("GitHub account %s not found", username)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := github.NewClient(account.Token).MarkThreadRead(ctx, notification.ThreadID); err != nil {
+		return "", err
+	}
+
+	if notification.URL != "" {
+		h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL("✅ Read", notification.URL))
+	}
+	return "Marked as read", nil
+}
@@ -0,0 +1,134 @@
+package bot
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recordedRequests is a fake GitHub API recording the requests made to it.
+type recordedRequests struct {
+	mu       sync.Mutex
+	requests []string
+}
+
+func (r *recordedRequests) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.requests = append(r.requests, req.Method+" "+req.URL.Path)
+	r.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{}`))
+}
+
+func (r *recordedRequests) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.requests...)
+}
+
+// sentEntry queues the notification for the chat and returns its outbox
+// entry as the dispatcher would deliver it.
+func sentEntry(t *testing.T, h *Handler, chatID int64, notification models.Notification) models.OutboxEntry {
+	t.Helper()
+	ctx := context.Background()
+	if err := h.store.AddGitHubAccount(ctx, chatID, "actions-test-token", "alice", models.GitHubAccountMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.store.EnqueueNotification(ctx, chatID, notification, notification.URL, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := h.store.ClaimOutbox(ctx, 10, time.Minute)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ClaimOutbox = %+v, %v", entries, err)
+	}
+	if err := h.store.MarkOutboxSent(ctx, entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	return entries[0]
+}
+
+// press returns the callback query of pressing the button with the label
+// below a message whose text is text.
+func press(t *testing.T, chatID int64, text string, actions []tgbotapi.InlineKeyboardButton, label string) *tgbotapi.CallbackQuery {
+	t.Helper()
+	for _, action := range actions {
+		if action.Text == label && action.CallbackData != nil {
+			return &tgbotapi.CallbackQuery{
+				ID:      "1",
+				From:    &tgbotapi.User{ID: 7},
+				Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID}, Text: text},
+				Data:    *action.CallbackData,
+			}
+		}
+	}
+	t.Fatalf("no %q button among %+v", label, actions)
+	return nil
+}
+
+// TestActionsLookUpNotification checks that the buttons act on the stored
+// notification, not on what the message text says, which templates,
+// formats and summaries change.
+func TestActionsLookUpNotification(t *testing.T) {
+	github := &recordedRequests{}
+	h := newTestHandler(t, github)
+	ctx := context.Background()
+
+	entry := sentEntry(t, h, 5, models.Notification{
+		Type:     "review_requested",
+		Repo:     "acme/api",
+		Message:  "[acme/api] Add login",
+		URL:      "https://api.github.com/repos/acme/api/pulls/42",
+		ThreadID: "99",
+		Account:  "alice",
+	})
+	actions := NotificationActions(ctx, h.store, entry)
+	text := "👀 Review requested on Add login\nSummary: the last line is not a URL"
+
+	if err := h.handleCallback(ctx, press(t, 5, text, actions, "✅ Mark as read")); err != nil {
+		t.Fatal(err)
+	}
+	query := press(t, 5, text, actions, "✅ Approve")
+	if err := h.handleCallback(ctx, query); err != nil {
+		t.Fatal(err)
+	}
+	query.Data = callbackConfirmReview + strings.TrimPrefix(query.Data, callbackReview)
+	if err := h.handleCallback(ctx, query); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"PATCH /notifications/threads/99", "POST /repos/acme/api/pulls/42/reviews"}
+	if got := github.list(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("GitHub requests = %q, want %q", got, want)
+	}
+}
+
+// TestActionsRejectForeignEntries checks that a button cannot act on the
+// notification of another chat, or on one from an older version of the
+// bot whose callback data holds no entry ID.
+func TestActionsRejectForeignEntries(t *testing.T) {
+	github := &recordedRequests{}
+	h := newTestHandler(t, github)
+	ctx := context.Background()
+
+	entry := sentEntry(t, h, 6, models.Notification{Type: "mention", Repo: "acme/api", Message: "[acme/api] Hi", URL: "https://api.github.com/repos/acme/api/issues/1", ThreadID: "7", Account: "alice"})
+	query := press(t, 6, "", NotificationActions(ctx, h.store, entry), "✅ Mark as read")
+
+	query.Message.Chat.ID = 8
+	if err := h.handleCallback(ctx, query); err == nil {
+		t.Error("expected the entry of another chat to be rejected")
+	}
+	query.Message.Chat.ID = 6
+	query.Data = callbackRead + "7:alice"
+	if err := h.handleCallback(ctx, query); err == nil {
+		t.Error("expected callback data of an older version to be rejected")
+	}
+	if got := github.list(); len(got) != 0 {
+		t.Errorf("GitHub requests = %q, want none", got)
+	}
+}
@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// analyticsPeriod is how far back /analytics looks.
+const analyticsPeriod = 30 * 24 * time.Hour
+
+// handleAnalytics reports how quickly the chat's accounts review the pull
+// requests they are asked to review.
+func (h *Handler) handleAnalytics(ctx context.Context, message *tgbotapi.Message) error {
+	now := time.Now()
+	since := now.Add(-analyticsPeriod)
+	requests, err := h.store.GetReviewRequests(ctx, message.Chat.ID, since)
+	if err != nil {
+		return err
+	}
+
+	text := "Review turnaround over the last 30 days\n\n" + describeReviewStats(models.SummarizeReviews(requests, since, now))
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeReviewStats(stats models.ReviewStats) string {
+	if stats.Reviewed == 0 && stats.Pending == 0 && len(stats.BusiestRepos) == 0 {
+		return "No review requests yet. Requests are tracked from the review_requested notifications of your GitHub accounts."
+	}
+
+	var text strings.Builder
+	if stats.Reviewed > 0 {
+		fmt.Fprintf(&text, "Reviewed: %d, median turnaround %s\n", stats.Reviewed, formatTurnaround(stats.MedianTurnaround))
+	} else {
+		text.WriteString("Reviewed: none\n")
+	}
+	if stats.Pending > 0 {
+		fmt.Fprintf(&text, "Pending: %d, oldest waiting %s\n", stats.Pending, formatTurnaround(stats.OldestPending))
+	} else {
+		text.WriteString("Pending: none\n")
+	}
+	if len(stats.BusiestRepos) > 0 {
+		text.WriteString("Busiest repositories:\n")
+		for _, repo := range stats.BusiestRepos {
+			fmt.Fprintf(&text, "%s: %d\n", repo.Repo, repo.Count)
+		}
+	}
+	return strings.TrimSuffix(text.String(), "\n")
+}
+
+// formatTurnaround shows a duration in its two largest units, e.g. 1d 4h.
+func formatTurnaround(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	case minutes > 0:
+		return fmt.Sprintf("%dm", minutes)
+	default:
+		return "under a minute"
+	}
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const changelogUsage = "usage: /changelog <HH:MM> <owner/repo>... or /changelog off, e.g. /changelog 18:00 acme/api acme/web acme/billing"
+
+// handleChangelog sets when the chat gets its daily changelog and which
+// repositories it covers, or shows the setting without arguments.
+func (h *Handler) handleChangelog(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 0:
+		reply := tgbotapi.NewMessage(message.Chat.ID, describeChangelog(preferences))
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case len(args) == 1 && args[0] == "off":
+		preferences.Changelog = models.Changelog{}
+		text = "The daily changelog is off"
+	case len(args) >= 2:
+		preferences.Changelog = models.Changelog{At: args[0], Repos: args[1:]}
+		text = describeChangelog(preferences)
+	default:
+		return fmt.Errorf(changelogUsage)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+	if preferences.Changelog.At != "" {
+		// Collect releases from now, so the first changelog does not
+		// reach back to before it was set up.
+		state := models.AccountState{ChatID: message.Chat.ID, Kind: models.AccountKindChangelog, LastCheckedAt: time.Now()}
+		if err := h.store.SaveAccountState(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeChangelog(preferences models.Preferences) string {
+	if preferences.Changelog.At == "" {
+		return "No daily changelog. Set one up with e.g. /changelog 18:00 acme/api acme/web"
+	}
+	return fmt.Sprintf("Releases of %s are combined into a changelog sent every day at %s (%s)",
+		strings.Join(preferences.Changelog.Repos, ", "), preferences.Changelog.At, preferences.Timezone)
+}
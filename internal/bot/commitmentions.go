@@ -0,0 +1,55 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const commitMentionsUsage = "usage: /commitmentions <owner/repo>... or /commitmentions off, e.g. /commitmentions acme/api acme/web"
+
+// handleCommitMentions sets the repositories whose commits are scanned for
+// mentions of the chat's accounts, or shows them without arguments.
+func (h *Handler) handleCommitMentions(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 0:
+		reply := tgbotapi.NewMessage(message.Chat.ID, describeCommitMentions(preferences.CommitMentionRepos))
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case len(args) == 1 && args[0] == "off":
+		preferences.CommitMentionRepos = nil
+		text = "Commit mentions are off"
+	default:
+		for _, arg := range args {
+			if !strings.Contains(arg, "/") {
+				return fmt.Errorf(commitMentionsUsage)
+			}
+		}
+		preferences.CommitMentionRepos = args
+		text = describeCommitMentions(args)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeCommitMentions(repos []string) string {
+	if len(repos) == 0 {
+		return "Commit mentions are off. Turn them on with e.g. /commitmentions acme/api to be notified when a commit message there @mentions one of your accounts"
+	}
+	return fmt.Sprintf("You are notified when new commits in %s @mention one of your accounts", strings.Join(repos, ", "))
+}
@@ -0,0 +1,100 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/redact"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// conversationTimeout is how long the bot waits for the reply to one of
+// its prompts before forgetting the conversation.
+const conversationTimeout = 10 * time.Minute
+
+// step handles the reply to a prompt. It returns the step that handles
+// the next reply, or nil when the conversation is over.
+type step func(ctx context.Context, message *tgbotapi.Message) (step, error)
+
+// conversationKey identifies a conversation by its chat and the user who
+// started it, so in a group only that user's messages continue it.
+type conversationKey struct {
+	chatID int64
+	userID int64
+}
+
+func keyOf(message *tgbotapi.Message) conversationKey {
+	key := conversationKey{chatID: message.Chat.ID}
+	if message.From != nil {
+		key.userID = message.From.ID
+	}
+	return key
+}
+
+type conversation struct {
+	next    step
+	expires time.Time
+}
+
+// conversations holds the commands waiting for a reply. They are kept in
+// memory, so a restart forgets them and the user starts over.
+type conversations struct {
+	mu      sync.Mutex
+	pending map[conversationKey]conversation
+}
+
+func newConversations() *conversations {
+	return &conversations{pending: make(map[conversationKey]conversation)}
+}
+
+// wait makes next handle the user's next message in the chat.
+func (c *conversations) wait(key conversationKey, next step) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending[key] = conversation{next: next, expires: time.Now().Add(conversationTimeout)}
+}
+
+// take removes and returns the step waiting for the user's message, if
+// it has not expired.
+func (c *conversations) take(key conversationKey) (step, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pending, ok := c.pending[key]
+	delete(c.pending, key)
+	if !ok || time.Now().After(pending.expires) {
+		return nil, false
+	}
+	return pending.next, true
+}
+
+// handleReply continues the conversation the message answers, if any.
+// Messages outside a conversation are ignored.
+func (h *Handler) handleReply(ctx context.Context, message *tgbotapi.Message) error {
+	key := keyOf(message)
+	current, ok := h.conversations.take(key)
+	if !ok {
+		return nil
+	}
+
+	next, err := current(ctx, message)
+	if next != nil {
+		h.conversations.wait(key, next)
+	}
+	if err != nil {
+		reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Error: %v", redact.Error(err)))
+		_, _ = h.Bot.Send(ctx, reply)
+	}
+	return err
+}
+
+func (h *Handler) handleCancel(ctx context.Context, message *tgbotapi.Message) error {
+	text := "Nothing to cancel"
+	if _, ok := h.conversations.take(keyOf(message)); ok {
+		text = "Cancelled"
+	}
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
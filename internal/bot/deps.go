@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/deps"
+	"github.com/erkineren/repository-monitor/internal/github"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const maxListedUpdates = 10
+
+func (h *Handler) handleWatchDeps(ctx context.Context, message *tgbotapi.Message) error {
+	repo := strings.TrimSpace(message.CommandArguments())
+	if strings.Count(repo, "/") != 1 {
+		return fmt.Errorf("usage: /watchdeps <owner/repo>")
+	}
+
+	user, exists := h.store.GetUser(ctx, message.Chat.ID)
+	if !exists {
+		return fmt.Errorf("add a GitHub account with /add first")
+	}
+	var githubClient *github.Client
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			githubClient = github.NewClient(account.Token)
+			break
+		}
+	}
+	if githubClient == nil {
+		return fmt.Errorf("an active GitHub account is required to read %s", repo)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	dependencies, err := deps.FetchDependencies(ctx, githubClient, repo)
+	if err != nil {
+		return err
+	}
+
+	// Versions that are already out are recorded as seen so only releases
+	// published from now on are announced.
+	updates := deps.NewClient().Updates(ctx, dependencies)
+	seen := make([]string, 0, len(updates))
+	for _, update := range updates {
+		seen = append(seen, update.Key(update.Latest))
+	}
+
+	if err := h.store.AddDependencySubscription(ctx, message.Chat.ID, repo, seen); err != nil {
+		return err
+	}
+
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Watching %d direct dependencies of %s for new releases.\n", len(dependencies), repo))
+	if len(updates) > 0 {
+		text.WriteString(fmt.Sprintf("\nAlready outdated (%d):\n", len(updates)))
+		for i, update := range updates {
+			if i == maxListedUpdates {
+				text.WriteString(fmt.Sprintf("…and %d more\n", len(updates)-maxListedUpdates))
+				break
+			}
+			text.WriteString(fmt.Sprintf("• %s %s → %s\n", update.Name, update.Version, update.Latest))
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text.String())
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleUnwatchDeps(ctx context.Context, message *tgbotapi.Message) error {
+	repo := strings.TrimSpace(message.CommandArguments())
+	if repo == "" {
+		return fmt.Errorf("usage: /unwatchdeps <owner/repo>")
+	}
+
+	if err := h.store.RemoveDependencySubscription(ctx, message.Chat.ID, repo); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Stopped watching dependencies of %s", repo))
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const digestUsage = "usage: /digest hourly, /digest daily [HH:MM] or /digest off, e.g. /digest daily 09:00"
+
+// handleDigest batches the chat's notifications into an hourly or daily
+// digest, or shows the setting without arguments.
+func (h *Handler) handleDigest(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 0 {
+		switch {
+		case len(args) == 1 && (args[0] == models.DigestOff || args[0] == models.DigestHourly):
+			preferences.DigestMode = args[0]
+		case args[0] == models.DigestDaily && len(args) <= 2:
+			preferences.DigestMode = models.DigestDaily
+			if len(args) == 2 {
+				if _, err := time.Parse("15:04", args[1]); err != nil {
+					return fmt.Errorf(digestUsage)
+				}
+				preferences.DigestTime = args[1]
+			}
+		default:
+			return fmt.Errorf(digestUsage)
+		}
+		if err := h.store.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, describeDigest(preferences))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeDigest(preferences models.Preferences) string {
+	switch preferences.DigestMode {
+	case models.DigestHourly:
+		return "Notifications are sent as a digest at the top of every hour, grouped by repository. Critical notifications are sent right away"
+	case models.DigestDaily:
+		at := preferences.DigestTime
+		if at == "" {
+			at = models.DefaultDigestTime
+		}
+		return fmt.Sprintf("Notifications are sent as a digest every day at %s (%s), grouped by repository. Critical notifications are sent right away", at, preferences.Timezone)
+	default:
+		return "Notifications are sent right away. Batch them with /digest hourly or /digest daily [HH:MM]"
+	}
+}
@@ -0,0 +1,465 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/metrics"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/sink"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	outboxBatchSize   = 50
+	outboxLease       = 5 * time.Minute
+	outboxMaxAttempts = 10
+	outboxRetryBase   = 30 * time.Second
+	outboxRetryMax    = time.Hour
+	// outboxPauseRecheck bounds how long entries of a paused chat are held
+	// back before the pause is checked again, so resuming takes effect
+	// without waiting for the end of a vacation.
+	outboxPauseRecheck = time.Minute
+	// digestMaxLength keeps digests below Telegram's limit of 4096
+	// characters per message, leaving room for the heading.
+	digestMaxLength = 3900
+	// While shedding load, larger batches are claimed so summaries cover
+	// more of a chat's backlog, and low-priority entries wait this long.
+	outboxShedBatchSize = 500
+	outboxShedDelay     = 15 * time.Minute
+)
+
+const (
+	digestWindowHeading = "%d notifications from outside your poll window:"
+	digestShedHeading   = "%d notifications, summarized while delivery catches up:"
+	// digestScheduledHeading is completed with the chat's digest mode.
+	digestScheduledHeading = "Your %s digest, %%d notifications:"
+)
+
+// Dispatcher drains the notification outbox and delivers the entries
+// through its transport, Telegram unless created with
+// NewTransportDispatcher, retrying failed sends with
+// exponential backoff. Entries for chats that paused delivery stay queued
+// until delivery resumes, and activity from outside a chat's poll window is
+// delivered as a digest. Chats with a digest mode get their notifications,
+// except critical ones, batched into an hourly or daily digest: entries are
+// held back until the chat's next digest is due, then sent together,
+// grouped by repository.
+//
+// When more than shedThreshold entries are pending, e.g. after a Telegram
+// outage, the dispatcher sheds load: low-priority entries are held back and
+// the others are sent as one summary per chat, so the backlog shrinks
+// instead of flooding users once delivery recovers.
+//
+// The chat's routing rules are applied to every entry before delivery, so
+// they can raise its severity, send it to another chat or silence it.
+//
+// Every notification delivered through the transport is also handed to
+// the sinks. Sinks are best effort: a failure is logged and counted but not
+// retried, so a broken sink neither holds back nor repeats messages.
+type Dispatcher struct {
+	transport     Transport
+	store         store.Store
+	sinks         []sink.Named
+	shedThreshold int
+	shedding      bool
+}
+
+// Transport sends outbox entries to the chat they are destined for. An
+// error has the entries retried, so a digest is sent again as a whole.
+type Transport interface {
+	Send(ctx context.Context, format *render.Format, entry models.OutboxEntry) error
+	SendDigest(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) error
+}
+
+// NewDispatcher returns a dispatcher delivering to Telegram through the
+// bot of each entry's tenant.
+func NewDispatcher(bots map[string]*Bot, store store.Store, sinks []sink.Named, shedThreshold int) *Dispatcher {
+	return NewTransportDispatcher(&telegramTransport{bots: bots, store: store}, store, sinks, shedThreshold)
+}
+
+// NewTransportDispatcher returns a dispatcher delivering through transport.
+func NewTransportDispatcher(transport Transport, store store.Store, sinks []sink.Named, shedThreshold int) *Dispatcher {
+	return &Dispatcher{
+		transport:     transport,
+		store:         store,
+		sinks:         sinks,
+		shedThreshold: shedThreshold,
+	}
+}
+
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.Dispatch(ctx); err != nil {
+			log.Printf("Error claiming outbox entries: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Dispatch delivers the outbox entries that are due until none are left,
+// returning an error when they cannot be claimed.
+func (d *Dispatcher) Dispatch(ctx context.Context) error {
+	shedding := d.checkBacklog(ctx)
+	batchSize := outboxBatchSize
+	heading := digestWindowHeading
+	if shedding {
+		batchSize = outboxShedBatchSize
+		heading = digestShedHeading
+	}
+
+	for ctx.Err() == nil {
+		entries, err := d.store.ClaimOutbox(ctx, batchSize, outboxLease)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		chats := map[int64]models.Preferences{}
+		rules := map[int64][]models.RoutingRule{}
+		digests := map[digestKey][]models.OutboxEntry{}
+		var digestKeys []digestKey
+		collect := func(key digestKey, entry models.OutboxEntry) {
+			if _, ok := digests[key]; !ok {
+				digestKeys = append(digestKeys, key)
+			}
+			digests[key] = append(digests[key], entry)
+		}
+		for _, entry := range entries {
+			entry = models.Apply(d.rules(ctx, entry.ChatID, rules), entry)
+			preferences := d.preferences(ctx, entry.ChatID, chats)
+			if resume, ok := preferences.DeliveryPaused(now); ok {
+				d.deferEntry(ctx, entry, pauseRecheck(resume, now))
+				continue
+			}
+			if shedding && preferences.Priority(entry.Notification) == models.PriorityLow {
+				d.deferEntry(ctx, entry, now.Add(outboxShedDelay))
+				continue
+			}
+			if due, ok := scheduledDigest(preferences, entry); ok {
+				if now.Before(due) {
+					d.deferEntry(ctx, entry, due)
+					continue
+				}
+				collect(digestKey{chatID: entry.ChatID, destination: entry.Destination(), heading: fmt.Sprintf(digestScheduledHeading, preferences.DigestMode)}, entry)
+				continue
+			}
+			if shedding || inDigest(preferences, entry) {
+				collect(digestKey{chatID: entry.ChatID, destination: entry.Destination(), heading: heading}, entry)
+				continue
+			}
+			d.deliver(ctx, entry, d.send(ctx, messageFormat(preferences), entry))
+		}
+		for _, key := range digestKeys {
+			d.sendDigests(ctx, messageFormat(chats[key.chatID]), key.heading, digests[key])
+		}
+
+		if len(entries) < batchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// preferences returns the chat's preferences, looked up once per batch
+// and kept in chats. Chats whose preferences cannot be read get the
+// defaults, so their notifications are delivered right away.
+func (d *Dispatcher) preferences(ctx context.Context, chatID int64, chats map[int64]models.Preferences) models.Preferences {
+	if preferences, ok := chats[chatID]; ok {
+		return preferences
+	}
+
+	preferences, err := d.store.GetPreferences(ctx, chatID)
+	if err != nil {
+		log.Printf("Error getting preferences for chat %d, delivering anyway: %v", chatID, err)
+		preferences = models.DefaultPreferences(chatID)
+	}
+	chats[chatID] = preferences
+	return preferences
+}
+
+// rules returns the chat's routing rules, looked up once per batch and
+// kept in chats. Chats whose rules cannot be read get none, so their
+// notifications are delivered as if they had no rules.
+func (d *Dispatcher) rules(ctx context.Context, chatID int64, chats map[int64][]models.RoutingRule) []models.RoutingRule {
+	if rules, ok := chats[chatID]; ok {
+		return rules
+	}
+
+	rules, err := d.store.GetRoutingRules(ctx, chatID)
+	if err != nil {
+		log.Printf("Error getting routing rules for chat %d, delivering unrouted: %v", chatID, err)
+	}
+	chats[chatID] = rules
+	return rules
+}
+
+// digestKey groups digest entries by the chat they belong to, the chat
+// they are routed to and the kind of digest they are sent in.
+type digestKey struct {
+	chatID      int64
+	destination int64
+	heading     string
+}
+
+// messageFormat returns the format of the chat's parse mode. Parse modes
+// are validated when saved, so an unknown one only comes from an older
+// version and falls back to MarkdownV2.
+func messageFormat(preferences models.Preferences) *render.Format {
+	format, err := render.Lookup(preferences.ParseMode)
+	if err != nil {
+		format, _ = render.Lookup(render.MarkdownV2)
+	}
+	return format
+}
+
+// checkBacklog reports whether the outbox backlog is above the shedding
+// threshold, logging when shedding starts and stops.
+func (d *Dispatcher) checkBacklog(ctx context.Context) bool {
+	if d.shedThreshold == 0 {
+		return false
+	}
+
+	pending, err := d.store.CountPendingOutbox(ctx)
+	if err != nil {
+		log.Printf("Error counting pending outbox entries: %v", err)
+		return d.shedding
+	}
+	metrics.OutboxBacklog.Set(float64(pending))
+
+	shedding := pending > d.shedThreshold
+	if shedding != d.shedding {
+		if shedding {
+			log.Printf("Outbox backlog of %d notifications exceeds %d, sending summaries and holding back low-priority notifications", pending, d.shedThreshold)
+		} else {
+			log.Printf("Outbox backlog is down to %d notifications, delivering normally again", pending)
+		}
+		d.shedding = shedding
+	}
+	if shedding {
+		metrics.Shedding.Set(1)
+	} else {
+		metrics.Shedding.Set(0)
+	}
+	return shedding
+}
+
+// pauseRecheck returns when to try the entry of a paused chat again: when
+// delivery resumes, but after outboxPauseRecheck at the latest.
+func pauseRecheck(resume, now time.Time) time.Time {
+	if recheck := now.Add(outboxPauseRecheck); resume.IsZero() || resume.After(recheck) {
+		return recheck
+	}
+	return resume
+}
+
+// deferEntry hands the entry back until the given time without counting
+// the claim as a delivery attempt.
+func (d *Dispatcher) deferEntry(ctx context.Context, entry models.OutboxEntry, until time.Time) {
+	if err := d.store.DeferOutbox(ctx, entry.ID, until); err != nil {
+		log.Printf("Error deferring outbox entry %d: %v", entry.ID, err)
+	}
+}
+
+// deliver records the outcome of sending the entry, scheduling a retry or
+// giving up after outboxMaxAttempts.
+func (d *Dispatcher) deliver(ctx context.Context, entry models.OutboxEntry, sendErr error) {
+	// Telegram API errors carry the request URL, and with it the bot token
+	sendErr = redact.Error(sendErr)
+
+	var err error
+	switch {
+	case sendErr == nil:
+		observeDelivery(entry)
+		err = d.store.MarkOutboxSent(ctx, entry.ID)
+		d.deliverToSinks(ctx, entry)
+	case entry.Attempts >= outboxMaxAttempts:
+		log.Printf("Giving up on notification %d (event %s) for chat %d after %d attempts: %v", entry.ID, entry.Notification.EventID, entry.ChatID, entry.Attempts, sendErr)
+		err = d.store.MarkOutboxDead(ctx, entry.ID, sendErr.Error())
+	default:
+		log.Printf("Error sending notification %d (event %s) for chat %d, will retry: %v", entry.ID, entry.Notification.EventID, entry.ChatID, sendErr)
+		err = d.store.MarkOutboxRetry(ctx, entry.ID, sendErr.Error(), time.Now().Add(retryDelay(entry.Attempts)))
+	}
+	if err != nil {
+		log.Printf("Error updating outbox entry %d: %v", entry.ID, err)
+	}
+}
+
+// deliverToSinks hands the entry's notification to every sink, recording
+// failures without retrying them.
+func (d *Dispatcher) deliverToSinks(ctx context.Context, entry models.OutboxEntry) {
+	for _, s := range d.sinks {
+		ctx, span := tracing.Start(ctx, "outbox.sink",
+			attribute.String("sink", s.Name),
+			attribute.Int64("outbox_id", entry.ID),
+			attribute.String("event_id", entry.Notification.EventID),
+		)
+		err := redact.Error(s.Deliver(ctx, entry.ChatID, entry.Notification))
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("Error delivering notification %d (event %s) to sink %s: %v", entry.ID, entry.Notification.EventID, s.Name, err)
+			metrics.SinkErrors.WithLabelValues(s.Name).Inc()
+		}
+	}
+}
+
+// inDigest reports whether the entry's activity happened outside the
+// chat's poll window, so it is sent in a digest with the other activity
+// that accumulated there.
+func inDigest(preferences models.Preferences, entry models.OutboxEntry) bool {
+	occurredAt := entry.Notification.OccurredAt
+	return !occurredAt.IsZero() && !preferences.InPollWindow(occurredAt)
+}
+
+// scheduledDigest returns when the chat's digest including the entry is
+// due, and false when the entry is delivered without waiting for one:
+// when the chat has no digest mode or the notification is critical.
+func scheduledDigest(preferences models.Preferences, entry models.OutboxEntry) (time.Time, bool) {
+	if entry.Notification.Severity == models.SeverityCritical {
+		return time.Time{}, false
+	}
+	due := preferences.NextDigest(entry.CreatedAt)
+	return due, !due.IsZero()
+}
+
+// sendDigests sends the entries of one chat and destination in as few digest messages as
+// fit Telegram's message size, grouped by repository. A single entry is
+// sent on its own.
+func (d *Dispatcher) sendDigests(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) {
+	if len(entries) == 1 {
+		d.deliver(ctx, entries[0], d.send(ctx, format, entries[0]))
+		return
+	}
+
+	entries = slices.Clone(entries)
+	slices.SortStableFunc(entries, func(a, b models.OutboxEntry) int {
+		return strings.Compare(a.Notification.Repo, b.Notification.Repo)
+	})
+
+	var digest []models.OutboxEntry
+	length := 0
+	for i, entry := range entries {
+		item, _ := format.Notification(entry.Notification)
+		entryLength := len(item)
+		if i == 0 || entry.Notification.Repo != entries[i-1].Notification.Repo {
+			// Room for the repository's heading.
+			entryLength += len(format.Escape(digestRepoHeading(entry.Notification.Repo, 0)))
+		}
+		if len(digest) > 0 && length+entryLength > digestMaxLength {
+			d.sendDigest(ctx, format, heading, digest)
+			digest, length = nil, 0
+		}
+		digest = append(digest, entry)
+		length += entryLength
+	}
+	d.sendDigest(ctx, format, heading, digest)
+}
+
+func (d *Dispatcher) sendDigest(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) {
+	err := d.sendDigestMessage(ctx, format, fmt.Sprintf(heading, len(entries)), entries)
+	for _, entry := range entries {
+		d.deliver(ctx, entry, err)
+	}
+}
+
+// sendDigestMessage sends the entries as one digest, silently only when
+// every entry is to be delivered silently.
+func (d *Dispatcher) sendDigestMessage(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) (err error) {
+	chatID := entries[0].Destination()
+	ctx, span := tracing.Start(ctx, "outbox.send_digest",
+		attribute.Int64("chat_id", entries[0].ChatID),
+		attribute.Int64("destination_chat_id", chatID),
+		attribute.Int("entries", len(entries)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return d.transport.SendDigest(ctx, format, heading, entries)
+}
+
+// telegramTransport sends entries through the bot of their tenant.
+type telegramTransport struct {
+	bots  map[string]*Bot
+	store store.Store
+}
+
+func (t *telegramTransport) SendDigest(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) error {
+	bot, ok := t.bots[entries[0].TenantID]
+	if !ok {
+		return fmt.Errorf("no bot running for tenant %s", entries[0].TenantID)
+	}
+
+	notifications := make([]models.Notification, len(entries))
+	silent := true
+	for i, entry := range entries {
+		notifications[i] = entry.Notification
+		silent = silent && entry.Silent
+	}
+	return bot.SendDigest(ctx, entries[0].Destination(), format, heading, notifications, silent)
+}
+
+func (d *Dispatcher) send(ctx context.Context, format *render.Format, entry models.OutboxEntry) (err error) {
+	ctx, span := tracing.Start(ctx, "outbox.send",
+		attribute.Int64("outbox_id", entry.ID),
+		attribute.String("event_id", entry.Notification.EventID),
+		attribute.Int64("chat_id", entry.ChatID),
+		attribute.Int64("destination_chat_id", entry.Destination()),
+		attribute.Bool("silent", entry.Silent),
+		attribute.Int("attempt", entry.Attempts),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return d.transport.Send(ctx, format, entry)
+}
+
+func (t *telegramTransport) Send(ctx context.Context, format *render.Format, entry models.OutboxEntry) error {
+	bot, ok := t.bots[entry.TenantID]
+	if !ok {
+		return fmt.Errorf("no bot running for tenant %s", entry.TenantID)
+	}
+
+	// The actions act on the chat they are pressed in, so notifications
+	// routed to another chat are sent without them
+	var actions []tgbotapi.InlineKeyboardButton
+	if entry.Destination() == entry.ChatID {
+		actions = NotificationActions(ctx, t.store, entry)
+	}
+	return bot.SendNotification(ctx, entry.Destination(), format, entry.Notification, entry.Silent, actions...)
+}
+
+// observeDelivery records how long the notification took from the
+// activity on the source, and from being queued, until it was delivered.
+func observeDelivery(entry models.OutboxEntry) {
+	notification := entry.Notification
+	if !notification.OccurredAt.IsZero() {
+		metrics.DeliveryLatency.WithLabelValues(notification.Type).Observe(time.Since(notification.OccurredAt).Seconds())
+	}
+	metrics.QueueLatency.WithLabelValues(notification.Type).Observe(time.Since(entry.CreatedAt).Seconds())
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := outboxRetryBase << min(attempts-1, 10)
+	if delay > outboxRetryMax {
+		delay = outboxRetryMax
+	}
+	return delay
+}
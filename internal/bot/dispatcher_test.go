@@ -0,0 +1,84 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+)
+
+// recordingTransport records the entries sent through it.
+type recordingTransport struct {
+	sent []models.OutboxEntry
+}
+
+func (t *recordingTransport) Send(ctx context.Context, format *render.Format, entry models.OutboxEntry) error {
+	t.sent = append(t.sent, entry)
+	return nil
+}
+
+func (t *recordingTransport) SendDigest(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) error {
+	return fmt.Errorf("unexpected digest %q", heading)
+}
+
+// TestDispatchRouting checks that every routing rule matching an entry
+// applies, in the order the rules were added.
+func TestDispatchRouting(t *testing.T) {
+	const chatID = 42
+	ctx := context.Background()
+	s := memory.New()
+	if err := s.AddGitHubAccount(ctx, chatID, "dispatcher-test-token", "alice", models.GitHubAccountMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range []models.RoutingRule{
+		{Conditions: models.Conditions{Repo: "acme/*"}, DestinationChatID: -100},
+		{Conditions: models.Conditions{Type: "ci_activity"}, Silent: true},
+		{Conditions: models.Conditions{Repo: "acme/api", Type: "ci_activity"}, DestinationChatID: -200, Severity: models.SeverityHigh},
+		{Conditions: models.Conditions{Label: "Security"}, Severity: models.SeverityCritical},
+	} {
+		rule.ChatID = chatID
+		if _, err := s.AddRoutingRule(ctx, rule); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		notification models.Notification
+		destination  int64
+		silent       bool
+		severity     string
+	}{
+		{"no rule", models.Notification{Type: "mention", Repo: "other/api", Severity: models.SeverityNormal}, chatID, false, models.SeverityNormal},
+		{"destination", models.Notification{Type: "mention", Repo: "acme/web", Severity: models.SeverityNormal}, -100, false, models.SeverityNormal},
+		{"silent", models.Notification{Type: "ci_activity", Repo: "other/api", Severity: models.SeverityLow}, chatID, true, models.SeverityLow},
+		{"all matching rules", models.Notification{Type: "ci_activity", Repo: "acme/web", Severity: models.SeverityLow}, -100, true, models.SeverityLow},
+		{"later rule overrides", models.Notification{Type: "ci_activity", Repo: "acme/api", Severity: models.SeverityLow}, -200, true, models.SeverityHigh},
+		{"label", models.Notification{Type: "ci_activity", Repo: "acme/api", Labels: []string{"security"}, Severity: models.SeverityLow}, -200, true, models.SeverityCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.notification.URL = "https://github.com/" + tt.notification.Repo + "/" + tt.name
+			if _, err := s.EnqueueNotification(ctx, chatID, tt.notification, tt.notification.URL, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+
+			transport := &recordingTransport{}
+			if err := NewTransportDispatcher(transport, s, nil, 0).Dispatch(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if len(transport.sent) != 1 {
+				t.Fatalf("sent %d entries, want 1", len(transport.sent))
+			}
+			entry := transport.sent[0]
+			if entry.ChatID != chatID || entry.Destination() != tt.destination || entry.Silent != tt.silent || entry.Notification.Severity != tt.severity {
+				t.Errorf("sent entry for chat %d to %d, silent %v, severity %q, want to %d, silent %v, severity %q",
+					entry.ChatID, entry.Destination(), entry.Silent, entry.Notification.Severity, tt.destination, tt.silent, tt.severity)
+			}
+		})
+	}
+}
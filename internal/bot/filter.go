@@ -0,0 +1,47 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleFilter sets the expression notifications must match to be queued,
+// or shows it without arguments.
+func (h *Handler) handleFilter(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.TrimSpace(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch args {
+	case "":
+		if preferences.Filter == "" {
+			text = "All notifications are sent. Set a filter with e.g. /filter notification.Repo startsWith \"acme/\" && !notification.Author.IsBot"
+		} else {
+			text = fmt.Sprintf("Only notifications matching this filter are sent:\n%s", preferences.Filter)
+		}
+		reply := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case "off":
+		preferences.Filter = ""
+		text = "All notifications are sent"
+	default:
+		// Telegram clients may turn straight quotes into curly ones
+		preferences.Filter = strings.NewReplacer("“", `"`, "”", `"`).Replace(args)
+		text = fmt.Sprintf("Only notifications matching this filter are sent:\n%s", preferences.Filter)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
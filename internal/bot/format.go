@@ -0,0 +1,46 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/render"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const formatUsage = "usage: /format <plain|markdown|html>"
+
+// parseModes maps the /format arguments to the parse modes kept in the
+// preferences, where plain text is the empty parse mode.
+var parseModes = map[string]string{
+	"plain":    "",
+	"markdown": render.MarkdownV2,
+	"html":     render.HTML,
+}
+
+// handleFormat sets the format notifications are sent to the chat in, or
+// shows it without arguments.
+func (h *Handler) handleFormat(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	if args != "" {
+		parseMode, ok := parseModes[args]
+		if !ok {
+			return fmt.Errorf(formatUsage)
+		}
+		preferences.ParseMode = parseMode
+		if err := h.store.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	format := messageFormat(preferences)
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Notifications are sent as %s", format.Name()))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const gerritUsage = "usage: /gerrit add <base_url> <username> <http_password> or /gerrit remove <base_url> <username>"
+
+func (h *Handler) handleGerrit(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return fmt.Errorf(gerritUsage)
+	}
+
+	var text string
+	switch args[0] {
+	case "add":
+		// The command carries an HTTP password, so it should not stay in the chat history.
+		h.deleteMessage(message)
+
+		if len(args) != 4 {
+			return fmt.Errorf(gerritUsage)
+		}
+		account := models.GerritAccount{
+			BaseURL:  strings.TrimSuffix(args[1], "/"),
+			Username: args[2],
+			Password: args[3],
+		}
+		if err := h.store.AddGerritAccount(ctx, message.Chat.ID, account); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Successfully added Gerrit account %s on %s", account.Username, account.BaseURL)
+	case "remove":
+		if len(args) != 3 {
+			return fmt.Errorf(gerritUsage)
+		}
+		if err := h.store.RemoveGerritAccount(ctx, message.Chat.ID, strings.TrimSuffix(args[1], "/"), args[2]); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Successfully removed Gerrit account %s on %s", args[2], args[1])
+	default:
+		return fmt.Errorf(gerritUsage)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
@@ -1,125 +1,446 @@
 package bot
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"strings"
+	"time"
 
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/redact"
 	"github.com/erkineren/repository-monitor/internal/store"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type Handler struct {
-	Bot   *Bot
-	store store.Store
+	Bot           *Bot
+	store         store.Store
+	cfg           *config.Config
+	conversations *conversations
 }
 
-func NewHandler(bot *Bot, store store.Store) *Handler {
+func NewHandler(bot *Bot, store store.Store, cfg *config.Config) *Handler {
 	return &Handler{
-		Bot:   bot,
-		store: store,
+		Bot:           bot,
+		store:         store,
+		cfg:           cfg,
+		conversations: newConversations(),
 	}
 }
 
-func (h *Handler) HandleUpdate(update tgbotapi.Update) error {
-	if update.Message == nil || !update.Message.IsCommand() {
+func (h *Handler) HandleUpdate(ctx context.Context, update tgbotapi.Update) error {
+	if chat := update.FromChat(); chat != nil {
+		if err := h.checkTenant(ctx, chat.ID); err != nil {
+			return err
+		}
+	}
+
+	if update.CallbackQuery != nil {
+		return h.handleCallback(ctx, update.CallbackQuery)
+	}
+
+	if update.Message == nil {
 		return nil
 	}
+	if !update.Message.IsCommand() {
+		return h.handleReply(ctx, update.Message)
+	}
+
+	// A command ends the conversation the user was in, except /cancel,
+	// which reports whether there was one.
+	if update.Message.Command() != "cancel" {
+		h.conversations.take(keyOf(update.Message))
+	}
 
 	var err error
 	switch update.Message.Command() {
 	case "start":
-		err = h.handleStart(update.Message)
+		err = h.handleStart(ctx, update.Message)
 	case "add":
-		err = h.handleAdd(update.Message)
+		err = h.handleAdd(ctx, update.Message)
+	case "cancel":
+		err = h.handleCancel(ctx, update.Message)
 	case "remove":
-		err = h.handleRemove(update.Message)
+		err = h.handleRemove(ctx, update.Message)
 	case "toggle":
-		err = h.handleToggle(update.Message)
+		err = h.handleToggle(ctx, update.Message)
+	case "prefs":
+		err = h.handlePrefs(ctx, update.Message)
+	case "security":
+		err = h.handleSecurity(ctx, update.Message)
+	case "gerrit":
+		err = h.handleGerrit(ctx, update.Message)
+	case "watch":
+		err = h.handleWatch(ctx, update.Message)
+	case "watchreleases":
+		err = h.handleWatchReleases(ctx, update.Message)
+	case "unwatch":
+		err = h.handleUnwatch(ctx, update.Message)
+	case "watchimage":
+		err = h.handleWatchImage(ctx, update.Message)
+	case "unwatchimage":
+		err = h.handleUnwatchImage(ctx, update.Message)
+	case "watchdeps":
+		err = h.handleWatchDeps(ctx, update.Message)
+	case "unwatchdeps":
+		err = h.handleUnwatchDeps(ctx, update.Message)
+	case "watchpath":
+		err = h.handleWatchPath(ctx, update.Message)
+	case "unwatchpath":
+		err = h.handleUnwatchPath(ctx, update.Message)
+	case "mute":
+		err = h.handleMute(ctx, update.Message)
+	case "unmute":
+		err = h.handleUnmute(ctx, update.Message)
+	case "jira":
+		err = h.handleJira(ctx, update.Message)
+	case "linear":
+		err = h.handleLinear(ctx, update.Message)
+	case "renotify":
+		err = h.handleRenotify(ctx, update.Message)
+	case "priority":
+		err = h.handlePriority(ctx, update.Message)
+	case "filter":
+		err = h.handleFilter(ctx, update.Message)
+	case "route":
+		err = h.handleRoute(ctx, update.Message)
+	case "pauseall":
+		err = h.handlePauseAll(ctx, update.Message)
+	case "vacation":
+		err = h.handleVacation(ctx, update.Message)
+	case "resumeall":
+		err = h.handleResumeAll(ctx, update.Message)
+	case "digest":
+		err = h.handleDigest(ctx, update.Message)
+	case "quiet":
+		err = h.handleQuiet(ctx, update.Message)
+	case "window":
+		err = h.handleWindow(ctx, update.Message)
+	case "format":
+		err = h.handleFormat(ctx, update.Message)
+	case "calendar":
+		err = h.handleCalendar(ctx, update.Message)
+	case "analytics":
+		err = h.handleAnalytics(ctx, update.Message)
+	case "teamdigest":
+		err = h.handleTeamDigest(ctx, update.Message)
+	case "sla":
+		err = h.handleSLA(ctx, update.Message)
+	case "triage":
+		err = h.handleTriage(ctx, update.Message)
+	case "summaries":
+		err = h.handleSummaries(ctx, update.Message)
+	case "changelog":
+		err = h.handleChangelog(ctx, update.Message)
+	case "usage":
+		err = h.handleUsage(ctx, update.Message)
+	case "commitmentions":
+		err = h.handleCommitMentions(ctx, update.Message)
 	case "list":
-		err = h.handleList(update.Message)
+		err = h.handleList(ctx, update.Message)
 	case "help":
-		err = h.handleHelp(update.Message)
+		err = h.handleHelp(ctx, update.Message)
+	default:
+		err = h.handleUnknown(ctx, update.Message)
+	}
+
+	if err != nil {
+		reply := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Error: %v", redact.Error(err)))
+		_, _ = h.Bot.Send(ctx, reply)
+	}
+
+	return err
+}
+
+// checkTenant ignores chats that are registered with another tenant's bot,
+// so a bot can never act on users it does not own.
+func (h *Handler) checkTenant(ctx context.Context, chatID int64) error {
+	tenantID, ok := store.TenantFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	owner, found, err := h.store.ChatTenant(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if found && owner != tenantID {
+		return store.ErrTenantMismatch
+	}
+
+	return nil
+}
+
+func (h *Handler) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) error {
+	if query.Message == nil {
+		return nil
+	}
+
+	action, data, _ := strings.Cut(query.Data, ":")
+	var text string
+	var err error
+	switch action + ":" {
+	case callbackJira:
+		text, err = h.handleJiraCallback(ctx, query, data)
+	case callbackLinear:
+		text, err = h.handleLinearCallback(ctx, query, data)
+	case callbackTriage:
+		text, err = h.handleTriageCallback(ctx, query, data)
+	case callbackRead:
+		text, err = h.handleReadCallback(ctx, query, data)
+	case callbackReview:
+		text, err = h.handleReviewCallback(ctx, query, data)
+	case callbackConfirmReview:
+		text, err = h.handleConfirmReviewCallback(ctx, query, data)
 	default:
-		err = h.handleUnknown(update.Message)
+		text = "Unknown action"
 	}
 
+	callback := tgbotapi.NewCallback(query.ID, text)
 	if err != nil {
-		reply := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Error: %v", err))
-		_, _ = h.Bot.API.Send(reply)
+		callback = tgbotapi.NewCallbackWithAlert(query.ID, fmt.Sprintf("Error: %v", redact.Error(err)))
 	}
+	_, _ = h.Bot.API.Request(callback)
 
 	return err
 }
 
-func (h *Handler) handleStart(message *tgbotapi.Message) error {
+func (h *Handler) deleteMessage(message *tgbotapi.Message) {
+	deleteMsg := tgbotapi.NewDeleteMessage(message.Chat.ID, message.MessageID)
+	_, _ = h.Bot.API.Request(deleteMsg)
+}
+
+func (h *Handler) handleStart(ctx context.Context, message *tgbotapi.Message) error {
 	text := `Welcome to GitHub Repository Monitor!
 	
 Available commands:
-/add <username> <token> - Add a GitHub account to monitor
+/add - Add a GitHub account to monitor, asking for its token
+/cancel - Stop a command waiting for your reply
 /remove <username> - Remove a GitHub account
 /toggle <username> - Toggle notifications for a GitHub account
-/list - List monitored GitHub accounts
+/prefs <username> [<reason>=on|off...] - Show or choose the notification reasons sent for a GitHub account, e.g. ci_activity=off
+/security <username> [on|off] - Send new Dependabot and code scanning alerts on a GitHub account's repositories
+/gerrit add <base_url> <username> <http_password> - Add a Gerrit account to monitor
+/gerrit remove <base_url> <username> - Remove a Gerrit account
+/watch <owner/repo> [pull_request] [issue] [release] - Get notified about a repository's pull requests, issues and releases
+/watchreleases <owner/repo> - Get notified about a repository's releases, e.g. /watchreleases golang/go
+/unwatch <owner/repo> - Stop watching a repository
+/watchimage <image> [tag_glob] - Get notified about new container image tags
+/unwatchimage <image> - Stop watching a container image
+/watchdeps <owner/repo> - Get notified about new releases and vulnerabilities of a repository's dependencies
+/unwatchdeps <owner/repo> - Stop watching a repository's dependencies
+/watchpath <owner/repo> <path> - Get notified about commits changing files matching the path, e.g. api/**/*.proto
+/unwatchpath <owner/repo> <path> - Stop watching a path
+/mute <owner/repo> - Stop notifications from a repository
+/unmute <owner/repo> - Resume notifications from a repository
+/jira <base_url> <email> <api_token> <project_key> - Enable "Create Jira ticket" on notifications
+/linear <api_key> <team_id> [project_id] - Enable "Create Linear issue" on notifications
+/renotify [<type> <hours|never|default>] - Show or set how often unchanged items of a type are sent again
+/priority [<type|owner/repo> <low|normal|high|default>] - Show or set which notifications are held back first when delivery falls behind
+/filter [<expression>] - Show or set a filter notifications must match, e.g. /filter notification.Repo startsWith "acme/" && !notification.Author.IsBot (/filter off to disable)
+/route [add <conditions> -> <actions>|remove <id>] - Show or change the rules routing notifications to other chats, silencing them or changing their severity
+/pauseall [duration] - Pause all notifications, e.g. for 2h
+/vacation <YYYY-MM-DD> - Pause all notifications until a day
+/resumeall - Resume paused notifications
+/quiet <HH:MM> <HH:MM> [timezone] - Hold back notifications during these hours every day, e.g. /quiet 22:00 08:00 Europe/Istanbul (/quiet off to disable)
+/digest [hourly|daily [HH:MM]|off] - Show or set whether notifications are batched into an hourly or daily digest grouped by repository
+/window [<cron expression>] - Show or set when accounts are polled, e.g. /window * 8-19 * * 1-5 (/window off to disable)
+/format [plain|markdown|html] - Show or set the format notifications are sent in
+/calendar - Get an iCal feed of milestones and releases
+/analytics - Show how quickly you review the pull requests you are asked to review
+/teamdigest [<cron expression> <owner/repo>...] - Show or schedule a group chat's digest of pending reviews, stale pull requests and merges (/teamdigest off to disable)
+/sla [<business hours>] - Show or set how long you have to reply to mentions before you are reminded (/sla off to disable)
+/triage [<hours> <owner/repo>... labels=<label>,...] - Show or set which new issues are sent for triage when left without labels or assignees, with buttons applying the labels (/triage off to disable)
+/summaries [on|off] - Show or set whether notifications about long threads come with a summary of the discussion
+/changelog [<HH:MM> <owner/repo>...] - Show or set a daily changelog combining the releases of several repositories (/changelog off to disable)
+/usage [<percent> <org>...] - Show or set when you are warned that the Actions minutes, storage or Git LFS quotas of your accounts and organizations run out, or repositories grow too large (/usage off to disable)
+/commitmentions [<owner/repo>...] - Show or set the repositories whose new commits are scanned for @mentions of your accounts (/commitmentions off to disable)
+/list - List monitored accounts
 /help - Show this help message`
 
 	reply := tgbotapi.NewMessage(message.Chat.ID, text)
-	_, err := h.Bot.API.Send(reply)
+	_, err := h.Bot.Send(ctx, reply)
 	return err
 }
 
-func (h *Handler) handleAdd(message *tgbotapi.Message) error {
+// handleAdd asks for the token of the account to add, or adds it right
+// away from the legacy /add <username> <token> [note].
+func (h *Handler) handleAdd(ctx context.Context, message *tgbotapi.Message) error {
 	args := strings.Fields(message.CommandArguments())
-	if len(args) != 2 {
-		return fmt.Errorf("usage: /add <username> <token>")
+	if len(args) == 0 {
+		h.conversations.wait(keyOf(message), h.addToken)
+		reply := tgbotapi.NewMessage(message.Chat.ID, "Send the token of the GitHub account to add, optionally followed by a note. The message is deleted as soon as it arrives. /cancel to stop")
+		_, err := h.Bot.Send(ctx, reply)
+		return err
+	}
+
+	// The command may carry a token, so it should not stay in the chat
+	// history.
+	h.deleteMessage(message)
+	if len(args) < 2 {
+		return fmt.Errorf("usage: /add, or /add <username> <token> [note]")
 	}
 
 	username, token := args[0], args[1]
-	err := h.store.AddGitHubAccount(message.Chat.ID, token, username)
+	redact.Add(token)
+
+	// The metadata only feeds expiry warnings and /list, so a failed lookup
+	// must not keep the account from being added.
+	metadata, err := github.NewClient(token).GetTokenMetadata(ctx)
+	if err != nil {
+		log.Printf("Failed to get token metadata for %s: %v", username, err)
+	}
+	metadata.Note = strings.Join(args[2:], " ")
+
+	previous := h.accountToken(ctx, message.Chat.ID, username)
+	err = h.store.AddGitHubAccount(ctx, message.Chat.ID, token, username, metadata)
 	if err != nil {
 		return err
 	}
+	if previous != "" && previous != token {
+		store.ForgetToken(ctx, h.store, previous)
+	}
 
 	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Successfully added GitHub account: %s", username))
-	_, err = h.Bot.API.Send(reply)
+	_, err = h.Bot.Send(ctx, reply)
 	return err
 }
 
-func (h *Handler) handleRemove(message *tgbotapi.Message) error {
+// addToken deletes the message with the token, and adds the account of
+// the user GitHub reports as the token's owner. A token GitHub rejects
+// leaves the conversation waiting for another one.
+func (h *Handler) addToken(ctx context.Context, message *tgbotapi.Message) (step, error) {
+	h.deleteMessage(message)
+
+	fields := strings.Fields(message.Text)
+	if len(fields) == 0 {
+		return h.addToken, fmt.Errorf("send the token as text, or /cancel")
+	}
+	token := fields[0]
+
+	// Only registered once GitHub accepts it, so a word sent by mistake is
+	// not masked in every log line from now on.
+	username, metadata, err := github.CheckToken(ctx, token)
+	if err != nil {
+		return h.addToken, fmt.Errorf("GitHub did not accept the token, send another one or /cancel: %v", err)
+	}
+	metadata.Note = strings.Join(fields[1:], " ")
+
+	redact.Add(token)
+	previous := h.accountToken(ctx, message.Chat.ID, username)
+	if err := h.store.AddGitHubAccount(ctx, message.Chat.ID, token, username, metadata); err != nil {
+		return nil, err
+	}
+	if previous != "" && previous != token {
+		store.ForgetToken(ctx, h.store, previous)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Successfully added GitHub account: %s", username))
+	_, err = h.Bot.Send(ctx, reply)
+	return nil, err
+}
+
+// accountToken returns the token of the chat's GitHub account, or "" when
+// the chat has no such account.
+func (h *Handler) accountToken(ctx context.Context, chatID int64, username string) string {
+	user, exists := h.store.GetUser(ctx, chatID)
+	if !exists || user.Accounts[username] == nil {
+		return ""
+	}
+	return user.Accounts[username].Token
+}
+
+func (h *Handler) handleRemove(ctx context.Context, message *tgbotapi.Message) error {
 	username := strings.TrimSpace(message.CommandArguments())
 	if username == "" {
 		return fmt.Errorf("usage: /remove <username>")
 	}
 
-	err := h.store.RemoveGitHubAccount(message.Chat.ID, username)
+	token := h.accountToken(ctx, message.Chat.ID, username)
+	err := h.store.RemoveGitHubAccount(ctx, message.Chat.ID, username)
 	if err != nil {
 		return err
 	}
+	if token != "" {
+		store.ForgetToken(ctx, h.store, token)
+	}
 
 	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Successfully removed GitHub account: %s", username))
-	_, err = h.Bot.API.Send(reply)
+	_, err = h.Bot.Send(ctx, reply)
 	return err
 }
 
-func (h *Handler) handleToggle(message *tgbotapi.Message) error {
+func (h *Handler) handleToggle(ctx context.Context, message *tgbotapi.Message) error {
 	username := strings.TrimSpace(message.CommandArguments())
 	if username == "" {
 		return fmt.Errorf("usage: /toggle <username>")
 	}
 
-	err := h.store.ToggleGitHubAccount(message.Chat.ID, username)
+	err := h.store.ToggleGitHubAccount(ctx, message.Chat.ID, username)
 	if err != nil {
 		return err
 	}
 
 	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Toggled notifications for GitHub account: %s", username))
-	_, err = h.Bot.API.Send(reply)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleMute(ctx context.Context, message *tgbotapi.Message) error {
+	repo := strings.TrimSpace(message.CommandArguments())
+	if strings.Count(repo, "/") != 1 {
+		return fmt.Errorf("usage: /mute <owner/repo>")
+	}
+
+	err := h.store.MuteRepo(ctx, message.Chat.ID, repo)
+	if err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Muted notifications from %s", repo))
+	_, err = h.Bot.Send(ctx, reply)
 	return err
 }
 
-func (h *Handler) handleList(message *tgbotapi.Message) error {
-	user, exists := h.store.GetUser(message.Chat.ID)
-	if !exists || len(user.Accounts) == 0 {
+func (h *Handler) handleUnmute(ctx context.Context, message *tgbotapi.Message) error {
+	repo := strings.TrimSpace(message.CommandArguments())
+	if strings.Count(repo, "/") != 1 {
+		return fmt.Errorf("usage: /unmute <owner/repo>")
+	}
+
+	err := h.store.UnmuteRepo(ctx, message.Chat.ID, repo)
+	if err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Unmuted notifications from %s", repo))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleCalendar(ctx context.Context, message *tgbotapi.Message) error {
+	if h.cfg.PublicURL == "" {
+		return fmt.Errorf("calendar feeds are not available: PUBLIC_URL is not configured")
+	}
+
+	token, err := h.store.GetCalendarToken(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Subscribe to this URL in your calendar app to see milestone due dates and releases:\n\n%s/calendar/%s.ics\n\nKeep it private, anyone with the link can read the feed.", h.cfg.PublicURL, token)
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleList(ctx context.Context, message *tgbotapi.Message) error {
+	user, exists := h.store.GetUser(ctx, message.Chat.ID)
+	if !exists || len(user.Accounts)+len(user.GerritAccounts) == 0 {
 		reply := tgbotapi.NewMessage(message.Chat.ID, "No GitHub accounts configured.")
-		_, err := h.Bot.API.Send(reply)
+		_, err := h.Bot.Send(ctx, reply)
 		return err
 	}
 
@@ -130,20 +451,81 @@ func (h *Handler) handleList(message *tgbotapi.Message) error {
 		if !account.IsActive {
 			status = "🔴 Inactive"
 		}
-		text.WriteString(fmt.Sprintf("%s: %s\n", username, status))
+		text.WriteString(fmt.Sprintf("%s: %s", username, status))
+		if account.Note != "" {
+			text.WriteString(fmt.Sprintf(" (%s)", account.Note))
+		}
+		if !account.ExpiresAt.IsZero() {
+			text.WriteString(fmt.Sprintf(", token expires %s", account.ExpiresAt.Format("2006-01-02")))
+		}
+		text.WriteString("\n")
+	}
+
+	if len(user.GerritAccounts) > 0 {
+		text.WriteString("\nMonitored Gerrit accounts:\n\n")
+		for _, account := range user.GerritAccounts {
+			text.WriteString(fmt.Sprintf("%s on %s\n", account.Username, account.BaseURL))
+		}
+	}
+
+	if subscriptions, err := h.store.GetRepoSubscriptions(ctx, message.Chat.ID); err == nil && len(subscriptions) > 0 {
+		text.WriteString("\nWatched repositories:\n\n")
+		for _, subscription := range subscriptions {
+			if len(subscription.EventTypes) > 0 {
+				text.WriteString(fmt.Sprintf("👀 %s (%s)\n", subscription.Repo, strings.Join(subscription.EventTypes, ", ")))
+			} else {
+				text.WriteString(fmt.Sprintf("👀 %s\n", subscription.Repo))
+			}
+		}
+	}
+
+	if images, err := h.store.GetImageSubscriptions(ctx, message.Chat.ID); err == nil && len(images) > 0 {
+		text.WriteString("\nWatched container images:\n\n")
+		for _, image := range images {
+			if image.TagFilter != "" {
+				text.WriteString(fmt.Sprintf("📦 %s (%s)\n", image.Image, image.TagFilter))
+			} else {
+				text.WriteString(fmt.Sprintf("📦 %s\n", image.Image))
+			}
+		}
+	}
+
+	if subscriptions, err := h.store.GetDependencySubscriptions(ctx, message.Chat.ID); err == nil && len(subscriptions) > 0 {
+		text.WriteString("\nWatched dependencies:\n\n")
+		for _, subscription := range subscriptions {
+			text.WriteString(fmt.Sprintf("🧩 %s\n", subscription.Repo))
+		}
+	}
+
+	if subscriptions, err := h.store.GetPathSubscriptions(ctx, message.Chat.ID); err == nil && len(subscriptions) > 0 {
+		text.WriteString("\nWatched paths:\n\n")
+		for _, subscription := range subscriptions {
+			text.WriteString(fmt.Sprintf("📁 %s %s\n", subscription.Repo, subscription.Pattern))
+		}
+	}
+
+	if preferences, err := h.store.GetPreferences(ctx, message.Chat.ID); err == nil && preferences.PausedAt(time.Now()) {
+		text.WriteString(fmt.Sprintf("\n⏸ %s\n", describePause(preferences)))
+	}
+
+	if len(user.MutedRepos) > 0 {
+		text.WriteString("\nMuted repositories:\n\n")
+		for repo := range user.MutedRepos {
+			text.WriteString(fmt.Sprintf("🔇 %s\n", repo))
+		}
 	}
 
 	reply := tgbotapi.NewMessage(message.Chat.ID, text.String())
-	_, err := h.Bot.API.Send(reply)
+	_, err := h.Bot.Send(ctx, reply)
 	return err
 }
 
-func (h *Handler) handleHelp(message *tgbotapi.Message) error {
-	return h.handleStart(message)
+func (h *Handler) handleHelp(ctx context.Context, message *tgbotapi.Message) error {
+	return h.handleStart(ctx, message)
 }
 
-func (h *Handler) handleUnknown(message *tgbotapi.Message) error {
+func (h *Handler) handleUnknown(ctx context.Context, message *tgbotapi.Message) error {
 	reply := tgbotapi.NewMessage(message.Chat.ID, "Unknown command. Use /help to see available commands.")
-	_, err := h.Bot.API.Send(reply)
+	_, err := h.Bot.Send(ctx, reply)
 	return err
 }
@@ -0,0 +1,193 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/erkineren/repository-monitor/internal/bot/telegramtest"
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newTestHandler returns a handler whose bot talks to a fake Telegram API
+// and whose GitHub clients talk to githubAPI.
+func newTestHandler(t *testing.T, githubAPI http.Handler) *Handler {
+	t.Helper()
+	telegram := telegramtest.NewServer().Start()
+	t.Cleanup(telegram.Close)
+	githubServer := httptest.NewServer(githubAPI)
+	t.Cleanup(githubServer.Close)
+	setGitHubURL(t, githubServer.URL)
+
+	bot, err := New("123456:handler-test-bot-token-0000000000", ClientConfig{APIURL: telegram.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(bot, memory.New(), &config.Config{})
+}
+
+func setGitHubURL(t *testing.T, url string) {
+	t.Helper()
+	if err := github.SetBaseURL(url); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { github.SetBaseURL("") })
+}
+
+func textMessage(chatID int64, text string) *tgbotapi.Message {
+	return &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID}, Text: text}
+}
+
+// TestAddTokenRejected checks that text GitHub does not accept as a token
+// is not registered for redaction, and the conversation waits for another
+// token.
+func TestAddTokenRejected(t *testing.T) {
+	h := newTestHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message": "Bad credentials"}`)
+	}))
+
+	input := "notifications-by-mistake"
+	next, err := h.addToken(context.Background(), textMessage(1, input))
+	if err == nil {
+		t.Fatal("expected the token to be rejected")
+	}
+	if next == nil {
+		t.Error("expected the conversation to wait for another token")
+	}
+	if got := redact.String("log line: " + input); got != "log line: "+input {
+		t.Errorf("rejected input was redacted: %q", got)
+	}
+	if _, exists := h.store.GetUser(context.Background(), 1); exists {
+		t.Error("expected no account to be added")
+	}
+}
+
+// TestAddTokenAccepted checks that a token GitHub accepts is registered
+// for redaction and its account added.
+func TestAddTokenAccepted(t *testing.T) {
+	h := newTestHandler(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"login": "octocat"}`)
+	}))
+
+	token := "accepted-handler-test-token"
+	next, err := h.addToken(context.Background(), textMessage(2, token+" work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != nil {
+		t.Error("expected the conversation to end")
+	}
+	if got := redact.String(token); got == token {
+		t.Error("expected the accepted token to be redacted")
+	}
+	user, exists := h.store.GetUser(context.Background(), 2)
+	if !exists || user.Accounts["octocat"] == nil {
+		t.Fatal("expected the account to be added")
+	}
+	if note := user.Accounts["octocat"].Note; note != "work" {
+		t.Errorf("note = %q, want %q", note, "work")
+	}
+}
+
+func commandMessage(chatID int64, command, args string) *tgbotapi.Message {
+	message := textMessage(chatID, "/"+command+" "+args)
+	message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command) + 1}}
+	return message
+}
+
+// TestRemoveForgetsToken checks that removing an account stops redacting
+// its token, unless an account of another chat still uses it.
+func TestRemoveForgetsToken(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t, http.NotFoundHandler())
+
+	shared, own := "shared-handler-test-token", "own-handler-test-token"
+	for _, account := range []struct {
+		chatID   int64
+		token    string
+		username string
+	}{
+		{3, own, "alice"},
+		{3, shared, "bob"},
+		{4, shared, "bob"},
+	} {
+		if err := h.store.AddGitHubAccount(ctx, account.chatID, account.token, account.username, models.GitHubAccountMetadata{}); err != nil {
+			t.Fatal(err)
+		}
+		redact.Add(account.token)
+	}
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := h.handleRemove(ctx, commandMessage(3, "remove", username)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := redact.String(own); got != own {
+		t.Errorf("token of the removed account is still redacted: %q", got)
+	}
+	if got := redact.String(shared); got == shared {
+		t.Error("token used by another chat is no longer redacted")
+	}
+}
+
+// TestFilter checks that /filter saves valid filters, normalizing curly
+// quotes, and rejects malformed ones with the parser's error, keeping the
+// filter set before.
+func TestFilter(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t, http.NotFoundHandler())
+	if err := h.store.AddGitHubAccount(ctx, 5, "filter-handler-test-token", "alice", models.GitHubAccountMetadata{}); err != nil {
+		t.Fatal(err)
+	}
+	filter := func() string {
+		preferences, err := h.store.GetPreferences(ctx, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return preferences.Filter
+	}
+
+	if err := h.handleFilter(ctx, commandMessage(5, "filter", `notification.Repo startsWith “acme/” && !notification.Author.IsBot`)); err != nil {
+		t.Fatal(err)
+	}
+	want := `notification.Repo startsWith "acme/" && !notification.Author.IsBot`
+	if got := filter(); got != want {
+		t.Errorf("filter = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		args string
+		want string
+	}{
+		{`notification.Repo`, `invalid filter "notification.Repo": the filter is a string, expected a condition`},
+		{`notification.Owner == "acme"`, `invalid filter "notification.Owner == \"acme\"": unknown field "notification.Owner"`},
+		{`(notification.Type == "mention"`, `invalid filter "(notification.Type == \"mention\"": expected ), got end of filter`},
+		{`notification.Labels == "bug"`, `invalid filter "notification.Labels == \"bug\"": == needs two strings or two bools, got a list and a string`},
+	}
+	for _, tt := range tests {
+		err := h.handleFilter(ctx, commandMessage(5, "filter", tt.args))
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("/filter %s = %v, want %s", tt.args, err, tt.want)
+		}
+		if got := filter(); got != want {
+			t.Errorf("filter after /filter %s = %q, want it unchanged", tt.args, got)
+		}
+	}
+
+	if err := h.handleFilter(ctx, commandMessage(5, "filter", "off")); err != nil {
+		t.Fatal(err)
+	}
+	if got := filter(); got != "" {
+		t.Errorf("filter after /filter off = %q, want none", got)
+	}
+}
@@ -0,0 +1,83 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/jira"
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const jiraSummaryLimit = 255
+
+func (h *Handler) handleJira(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+
+	if len(args) == 1 && args[0] == "off" {
+		if err := h.store.RemoveJiraConfig(ctx, message.Chat.ID); err != nil {
+			return err
+		}
+		reply := tgbotapi.NewMessage(message.Chat.ID, "Jira integration disabled.")
+		_, err := h.Bot.Send(ctx, reply)
+		return err
+	}
+
+	if len(args) < 4 || len(args) > 5 {
+		return fmt.Errorf("usage: /jira <base_url> <email> <api_token> <project_key> [issue_type] or /jira off")
+	}
+
+	// The command carries an API token, so it should not stay in the chat history.
+	h.deleteMessage(message)
+
+	config := models.JiraConfig{
+		ChatID:     message.Chat.ID,
+		BaseURL:    strings.TrimSuffix(args[0], "/"),
+		Email:      args[1],
+		APIToken:   args[2],
+		ProjectKey: args[3],
+		IssueType:  "Task",
+	}
+	if len(args) == 5 {
+		config.IssueType = args[4]
+	}
+
+	if err := h.store.SetJiraConfig(ctx, config); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Jira integration enabled for project %s. Notifications will now offer a \"Create Jira ticket\" button.", config.ProjectKey))
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleJiraCallback(ctx context.Context, query *tgbotapi.CallbackQuery, entryID string) (string, error) {
+	config, ok := h.store.GetJiraConfig(ctx, query.Message.Chat.ID)
+	if !ok {
+		return "", fmt.Errorf("Jira is not configured, use /jira first")
+	}
+
+	notification, err := h.buttonNotification(ctx, query, entryID)
+	if err != nil {
+		return "", err
+	}
+	summary := summary(notification)
+	if len(summary) > jiraSummaryLimit {
+		summary = summary[:jiraSummaryLimit]
+	}
+	description := fmt.Sprintf("%s\n\nGitHub: %s", notification.Message, notification.URL)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	client := jira.NewClient(config.BaseURL, config.Email, config.APIToken)
+	issue, err := client.CreateIssue(ctx, config.ProjectKey, config.IssueType, summary, description)
+	if err != nil {
+		return "", err
+	}
+
+	h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL("🔗 "+issue.Key, issue.URL))
+	return fmt.Sprintf("Created %s", issue.Key), nil
+}
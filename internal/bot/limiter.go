@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Telegram allows a bot about 30 messages a second overall, one message a
+// second per chat and 20 messages a minute per group.
+const (
+	globalSendInterval = time.Second / 30
+	chatSendInterval   = time.Second
+	groupSendInterval  = 3 * time.Second
+
+	// sendRetries is how often a message is retried after Telegram
+	// answered with 429 Too Many Requests.
+	sendRetries = 3
+)
+
+// sendLimiter books send slots so a bot stays within Telegram's flood
+// limits. Every message waits for the next free global slot and the next
+// free slot of its chat.
+type sendLimiter struct {
+	mu       sync.Mutex
+	next     time.Time
+	chatNext map[int64]time.Time
+}
+
+func newSendLimiter() *sendLimiter {
+	return &sendLimiter{chatNext: make(map[int64]time.Time)}
+}
+
+// reserve books the next slot for chatID and returns how long the caller
+// has to wait for it.
+func (l *sendLimiter) reserve(chatID int64) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	at := now
+	if l.next.After(at) {
+		at = l.next
+	}
+	if chatNext := l.chatNext[chatID]; chatNext.After(at) {
+		at = chatNext
+	}
+
+	interval := chatSendInterval
+	if chatID < 0 {
+		interval = groupSendInterval
+	}
+	l.next = at.Add(globalSendInterval)
+	l.chatNext[chatID] = at.Add(interval)
+
+	// Forget chats whose slots have passed so the map does not grow with
+	// every chat the bot ever wrote to.
+	if len(l.chatNext) > 1000 {
+		for id, next := range l.chatNext {
+			if next.Before(now) {
+				delete(l.chatNext, id)
+			}
+		}
+	}
+
+	return at.Sub(now)
+}
+
+// pause holds back every message until the given time, after Telegram
+// asked the bot to slow down.
+func (l *sendLimiter) pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.next) {
+		l.next = until
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
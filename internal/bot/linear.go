@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/linear"
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const linearUsage = "usage: /linear <api_key> <team_id> [project_id], /linear map <owner/repo> <team_id> [project_id], /linear unmap <owner/repo> or /linear off"
+
+func (h *Handler) handleLinear(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return fmt.Errorf(linearUsage)
+	}
+
+	var text string
+	switch args[0] {
+	case "off":
+		if err := h.store.RemoveLinearConfig(ctx, message.Chat.ID); err != nil {
+			return err
+		}
+		text = "Linear integration disabled."
+	case "map":
+		if len(args) < 3 || len(args) > 4 {
+			return fmt.Errorf(linearUsage)
+		}
+		if err := h.store.SetLinearMapping(ctx, message.Chat.ID, args[1], linearTarget(args[2:])); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Issues for %s will be created in team %s.", args[1], args[2])
+	case "unmap":
+		if len(args) != 2 {
+			return fmt.Errorf(linearUsage)
+		}
+		if err := h.store.RemoveLinearMapping(ctx, message.Chat.ID, args[1]); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Issues for %s will use the default team.", args[1])
+	default:
+		if len(args) > 3 {
+			return fmt.Errorf(linearUsage)
+		}
+
+		// The command carries an API key, so it should not stay in the chat history.
+		h.deleteMessage(message)
+
+		if len(args) < 2 {
+			return fmt.Errorf(linearUsage)
+		}
+		if err := h.store.SetLinearConfig(ctx, message.Chat.ID, args[0], linearTarget(args[1:])); err != nil {
+			return err
+		}
+		text = "Linear integration enabled. Notifications will now offer a \"Create Linear issue\" button."
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleLinearCallback(ctx context.Context, query *tgbotapi.CallbackQuery, entryID string) (string, error) {
+	config, ok := h.store.GetLinearConfig(ctx, query.Message.Chat.ID)
+	if !ok {
+		return "", fmt.Errorf("Linear is not configured, use /linear first")
+	}
+
+	notification, err := h.buttonNotification(ctx, query, entryID)
+	if err != nil {
+		return "", err
+	}
+	target := config.Target(notification.Repo)
+	description := fmt.Sprintf("%s\n\n[View on GitHub](%s)", notification.Message, notification.URL)
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	issue, err := linear.NewClient(config.APIKey).CreateIssue(ctx, target.TeamID, target.ProjectID, summary(notification), description)
+	if err != nil {
+		return "", err
+	}
+
+	h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL("🔗 "+issue.Identifier, issue.URL))
+	return fmt.Sprintf("Created %s", issue.Identifier), nil
+}
+
+func linearTarget(args []string) models.LinearTarget {
+	target := models.LinearTarget{TeamID: args[0]}
+	if len(args) > 1 {
+		target.ProjectID = args[1]
+	}
+	return target
+}
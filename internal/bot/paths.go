@@ -0,0 +1,44 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const watchPathUsage = "usage: /watchpath <owner/repo> <path>, e.g. /watchpath acme/api api/**/*.proto or /watchpath acme/api deploy/helm/"
+
+func (h *Handler) handleWatchPath(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 {
+		return fmt.Errorf(watchPathUsage)
+	}
+
+	subscription := models.PathSubscription{ChatID: message.Chat.ID, Repo: args[0], Pattern: args[1]}
+	if _, err := h.store.AddPathSubscription(ctx, subscription); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Watching %s in %s. Commits on the default branch changing matching files are sent with the list of files and a link comparing the commits.", subscription.Pattern, subscription.Repo)
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleUnwatchPath(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 2 {
+		return fmt.Errorf("usage: /unwatchpath <owner/repo> <path>")
+	}
+
+	if err := h.store.RemovePathSubscription(ctx, message.Chat.ID, args[0], args[1]); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Stopped watching %s in %s", args[1], args[0]))
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
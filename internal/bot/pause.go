@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlePauseAll pauses delivery to the chat, until /resumeall or for the
+// given duration. Accounts keep being polled, so nothing is missed.
+func (h *Handler) handlePauseAll(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) > 1 {
+		return fmt.Errorf("usage: /pauseall [duration], e.g. /pauseall 2h")
+	}
+
+	var until time.Time
+	if len(args) == 1 {
+		duration, err := time.ParseDuration(args[0])
+		if err != nil || duration <= 0 {
+			return fmt.Errorf("usage: /pauseall [duration], e.g. /pauseall 2h")
+		}
+		until = time.Now().Add(duration)
+	}
+
+	return h.setPause(ctx, message, true, until)
+}
+
+// handleVacation pauses delivery until the start of the given day in the
+// chat's timezone.
+func (h *Handler) handleVacation(ctx context.Context, message *tgbotapi.Message) error {
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(preferences.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+
+	until, err := time.ParseInLocation("2006-01-02", strings.TrimSpace(message.CommandArguments()), location)
+	if err != nil {
+		return fmt.Errorf("usage: /vacation <YYYY-MM-DD>, the day delivery resumes")
+	}
+	if !until.After(time.Now()) {
+		return fmt.Errorf("the vacation must end in the future")
+	}
+
+	return h.setPause(ctx, message, true, until)
+}
+
+func (h *Handler) handleResumeAll(ctx context.Context, message *tgbotapi.Message) error {
+	return h.setPause(ctx, message, false, time.Time{})
+}
+
+func (h *Handler) setPause(ctx context.Context, message *tgbotapi.Message, paused bool, until time.Time) error {
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	preferences.Paused = paused
+	preferences.PausedUntil = until
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, describePause(preferences))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+// handleQuiet sets the daily quiet hours, during which delivery is paused,
+// and optionally the chat's timezone.
+func (h *Handler) handleQuiet(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 1 && args[0] == "off":
+		preferences.QuietHoursStart = ""
+		preferences.QuietHoursEnd = ""
+		text = "Quiet hours are off"
+	case len(args) == 2 || len(args) == 3:
+		preferences.QuietHoursStart = args[0]
+		preferences.QuietHoursEnd = args[1]
+		// The timezone is the chat's, so it also applies to the poll
+		// window, digests and the other schedules.
+		if len(args) == 3 {
+			preferences.Timezone = args[2]
+		}
+		text = fmt.Sprintf("Notifications are held back from %s to %s (%s) and sent when the quiet hours end", args[0], args[1], preferences.Timezone)
+	default:
+		return fmt.Errorf("usage: /quiet <HH:MM> <HH:MM> [timezone] or /quiet off, e.g. /quiet 22:00 08:00 Europe/Istanbul")
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describePause(preferences models.Preferences) string {
+	switch {
+	case !preferences.Paused:
+		return "Notifications are delivered again"
+	case preferences.PausedUntil.IsZero():
+		return "Notifications are paused until /resumeall. They are kept and sent when you resume"
+	default:
+		location, err := time.LoadLocation(preferences.Timezone)
+		if err != nil {
+			location = time.UTC
+		}
+		return fmt.Sprintf("Notifications are paused until %s. They are kept and sent then, or earlier with /resumeall",
+			preferences.PausedUntil.In(location).Format("2006-01-02 15:04 MST"))
+	}
+}
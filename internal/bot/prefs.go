@@ -0,0 +1,61 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const prefsUsage = "usage: /prefs <username> [<reason>=on|off...], e.g. /prefs octocat mention=on ci_activity=off"
+
+// handlePrefs turns notification reasons of a GitHub account on or off,
+// or lists the disabled ones with only the username.
+func (h *Handler) handlePrefs(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return fmt.Errorf(prefsUsage)
+	}
+
+	username := args[0]
+	user, exists := h.store.GetUser(ctx, message.Chat.ID)
+	if !exists || user.Accounts[username] == nil {
+		return store.ErrAccountNotFound
+	}
+	preferences, err := h.store.GetAccountPreferences(ctx, message.Chat.ID, username)
+	if err != nil {
+		return err
+	}
+
+	if len(args) > 1 {
+		for _, arg := range args[1:] {
+			reason, value, ok := strings.Cut(arg, "=")
+			if !ok || (value != "on" && value != "off") {
+				return fmt.Errorf(prefsUsage)
+			}
+			preferences.DisabledReasons = slices.DeleteFunc(preferences.DisabledReasons, func(disabled string) bool { return disabled == reason })
+			if value == "off" {
+				preferences.DisabledReasons = append(preferences.DisabledReasons, reason)
+			}
+		}
+		slices.Sort(preferences.DisabledReasons)
+		if err := h.store.SetAccountPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, describeAccountPreferences(preferences))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeAccountPreferences(preferences models.AccountPreferences) string {
+	if len(preferences.DisabledReasons) == 0 {
+		return fmt.Sprintf("Notifications of %s are sent for every reason.", preferences.Username)
+	}
+	return fmt.Sprintf("Notifications of %s are sent for every reason except %s.", preferences.Username, strings.Join(preferences.DisabledReasons, ", "))
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const priorityUsage = "usage: /priority <type|owner/repo> <low|normal|high|default>, e.g. /priority octo/docs low"
+
+// handlePriority sets the priority of a notification type or repository,
+// or lists the settings without arguments.
+func (h *Handler) handlePriority(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 0 && len(args) != 2 {
+		return fmt.Errorf(priorityUsage)
+	}
+
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if len(args) == 0 {
+		text = describePriorities(preferences)
+	} else {
+		key, priority := args[0], args[1]
+		if preferences.Priorities == nil {
+			preferences.Priorities = map[string]string{}
+		}
+		switch priority {
+		case "default":
+			delete(preferences.Priorities, key)
+			text = fmt.Sprintf("%s notifications have their default priority", key)
+		case models.PriorityLow, models.PriorityNormal, models.PriorityHigh:
+			preferences.Priorities[key] = priority
+			text = fmt.Sprintf("%s notifications have %s priority", key, priority)
+		default:
+			return fmt.Errorf(priorityUsage)
+		}
+		if err := h.store.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describePriorities(preferences models.Preferences) string {
+	if len(preferences.Priorities) == 0 {
+		return "All notifications have their default priority."
+	}
+
+	keys := make([]string, 0, len(preferences.Priorities))
+	for key := range preferences.Priorities {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var text strings.Builder
+	text.WriteString("Notification priorities:\n")
+	for _, key := range keys {
+		text.WriteString(fmt.Sprintf("\n%s: %s", key, preferences.Priorities[key]))
+	}
+	return text.String()
+}
@@ -0,0 +1,67 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/registry"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (h *Handler) handleWatchImage(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: /watchimage <image> [tag_glob], e.g. /watchimage ghcr.io/owner/app v1.*")
+	}
+
+	image, err := registry.ParseImage(args[0])
+	if err != nil {
+		return err
+	}
+	tagFilter := ""
+	if len(args) == 2 {
+		tagFilter = args[1]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Existing tags are recorded as seen so only tags published from now on
+	// are announced.
+	tags, err := registry.NewClient().ListTags(ctx, image)
+	if err != nil {
+		return fmt.Errorf("failed to read tags of %s: %v", image, err)
+	}
+	seenTags := registry.MatchTags(tags, tagFilter)
+
+	if err := h.store.AddImageSubscription(ctx, message.Chat.ID, image.String(), tagFilter, seenTags); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Watching %s for new tags", image)
+	if tagFilter != "" {
+		text += fmt.Sprintf(" matching %s", tagFilter)
+	}
+	text += fmt.Sprintf(" (%d existing tags skipped).", len(seenTags))
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleUnwatchImage(ctx context.Context, message *tgbotapi.Message) error {
+	image, err := registry.ParseImage(message.CommandArguments())
+	if err != nil {
+		return fmt.Errorf("usage: /unwatchimage <image>")
+	}
+
+	if err := h.store.RemoveImageSubscription(ctx, message.Chat.ID, image.String()); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Stopped watching %s", image))
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
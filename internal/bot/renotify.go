@@ -0,0 +1,95 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const renotifyUsage = "usage: /renotify <type> <hours|never|default>, e.g. /renotify review_requested 4"
+
+// handleRenotify sets how often an unchanged item of a notification type
+// is sent again, or lists the settings without arguments.
+func (h *Handler) handleRenotify(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 0 && len(args) != 2 {
+		return fmt.Errorf(renotifyUsage)
+	}
+
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	if len(args) == 0 {
+		text = h.describeRenotifyIntervals(preferences)
+	} else {
+		notificationType := args[0]
+		if preferences.RenotifyIntervals == nil {
+			preferences.RenotifyIntervals = map[string]int{}
+		}
+		switch args[1] {
+		case "default":
+			delete(preferences.RenotifyIntervals, notificationType)
+			text = fmt.Sprintf("%s notifications are sent again after %s", notificationType, describeRenotifyInterval(h.cfg.RenotifyInterval))
+		case "never":
+			preferences.RenotifyIntervals[notificationType] = models.RenotifyNever
+			text = fmt.Sprintf("%s notifications are sent once", notificationType)
+		default:
+			hours, err := strconv.Atoi(args[1])
+			if err != nil || hours < 1 {
+				return fmt.Errorf(renotifyUsage)
+			}
+			preferences.RenotifyIntervals[notificationType] = hours
+			text = fmt.Sprintf("%s notifications are sent again after %s", notificationType, describeRenotifyInterval(preferences.RenotifyInterval(notificationType, 0)))
+		}
+		if err := h.store.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) describeRenotifyIntervals(preferences models.Preferences) string {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("Unchanged items are sent again after %s", describeRenotifyInterval(h.cfg.RenotifyInterval)))
+	if len(preferences.RenotifyIntervals) == 0 {
+		text.WriteString(".")
+		return text.String()
+	}
+
+	types := make([]string, 0, len(preferences.RenotifyIntervals))
+	for notificationType := range preferences.RenotifyIntervals {
+		types = append(types, notificationType)
+	}
+	sort.Strings(types)
+
+	text.WriteString(", except:\n")
+	for _, notificationType := range types {
+		text.WriteString(fmt.Sprintf("\n%s: %s", notificationType, describeRenotifyInterval(preferences.RenotifyInterval(notificationType, 0))))
+	}
+	return text.String()
+}
+
+func describeRenotifyInterval(interval time.Duration) string {
+	switch {
+	case interval < 0:
+		return "never"
+	case interval == time.Hour:
+		return "1 hour"
+	case interval%time.Hour == 0:
+		return fmt.Sprintf("%d hours", interval/time.Hour)
+	default:
+		return interval.String()
+	}
+}
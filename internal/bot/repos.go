@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const watchUsage = "usage: /watch <owner/repo> [pull_request] [issue] [release], e.g. /watch acme/api release"
+
+// watchedEvents are the event types /watch reports.
+var watchedEvents = []string{models.EventPullRequest, models.EventIssue, models.EventRelease}
+
+func (h *Handler) handleWatch(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		return fmt.Errorf(watchUsage)
+	}
+
+	subscription := models.RepoSubscription{ChatID: message.Chat.ID, Repo: args[0]}
+	if subscription.IsOrgWide() {
+		return fmt.Errorf("watching every repository of an owner is not supported, watch each repository instead")
+	}
+	for _, eventType := range args[1:] {
+		if !slices.Contains(watchedEvents, eventType) {
+			return fmt.Errorf("unknown event type %q, expected one of %s", eventType, strings.Join(watchedEvents, ", "))
+		}
+		subscription.EventTypes = append(subscription.EventTypes, eventType)
+	}
+	if _, err := h.store.AddRepoSubscription(ctx, subscription); err != nil {
+		return err
+	}
+
+	events := "pull requests, issues and releases"
+	if len(subscription.EventTypes) > 0 {
+		events = strings.Join(subscription.EventTypes, ", ") + " events"
+	}
+	text := fmt.Sprintf("Watching %s of %s, whether or not you are involved in them.", events, subscription.Repo)
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
+
+// handleWatchReleases watches the releases of a repository, adding them to
+// the events of a repository already watched with /watch.
+func (h *Handler) handleWatchReleases(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 1 {
+		return fmt.Errorf("usage: /watchreleases <owner/repo>, e.g. /watchreleases golang/go")
+	}
+
+	subscription := models.RepoSubscription{ChatID: message.Chat.ID, Repo: args[0]}
+	if subscription.IsOrgWide() {
+		return fmt.Errorf("watching every repository of an owner is not supported, watch each repository instead")
+	}
+	subscriptions, err := h.store.GetRepoSubscriptions(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+	// GitHub's repository names are case-insensitive; the subscription
+	// keeps the spelling it was created with.
+	found := false
+	for _, existing := range subscriptions {
+		if strings.EqualFold(existing.Repo, subscription.Repo) {
+			subscription, found = existing, true
+		}
+	}
+
+	// A new subscription watches only releases; one without event types
+	// already receives every event, releases included.
+	if !found {
+		subscription.EventTypes = []string{models.EventRelease}
+	} else if !subscription.Wants(models.EventRelease) {
+		subscription.EventTypes = append(subscription.EventTypes, models.EventRelease)
+	}
+	if _, err := h.store.AddRepoSubscription(ctx, subscription); err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("Watching releases of %s. Each release is sent with the first paragraph of its notes and, for semantic versions, whether it is a major, minor or patch update.", subscription.Repo)
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func (h *Handler) handleUnwatch(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 1 {
+		return fmt.Errorf("usage: /unwatch <owner/repo>")
+	}
+
+	if err := h.store.RemoveRepoSubscription(ctx, message.Chat.ID, args[0]); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("Stopped watching %s", args[0]))
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// reviewEvent is a review the buttons below review requests submit.
+type reviewEvent struct {
+	Event string
+	Label string
+	Done  string
+	// Confirm labels the button confirming a review without text. Prompt
+	// asks for the text instead, which GitHub requires for every review
+	// but approvals.
+	Confirm string
+	Prompt  string
+}
+
+var reviewEvents = []reviewEvent{
+	{Event: "APPROVE", Label: "✅ Approve", Done: "✅ Approved", Confirm: "⚠️ Confirm approval"},
+	{Event: "COMMENT", Label: "💬 Comment", Done: "💬 Commented", Prompt: "Send your review comment"},
+	{Event: "REQUEST_CHANGES", Label: "🛑 Request changes", Done: "🛑 Changes requested", Prompt: "Send the changes you request"},
+}
+
+func findReviewEvent(event string) (reviewEvent, bool) {
+	for _, e := range reviewEvents {
+		if e.Event == event {
+			return e, true
+		}
+	}
+	return reviewEvent{}, false
+}
+
+// reviewActions returns the buttons reviewing the pull request of a review
+// request as the account it was requested from.
+func reviewActions(entry models.OutboxEntry) []tgbotapi.InlineKeyboardButton {
+	notification := entry.Notification
+	if notification.Type != "review_requested" || notification.Account == "" || !strings.Contains(notification.URL, "/pulls/") {
+		return nil
+	}
+
+	var actions []tgbotapi.InlineKeyboardButton
+	for _, e := range reviewEvents {
+		data := callbackReview + e.Event + ":" + strconv.FormatInt(entry.ID, 10)
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonData(e.Label, data))
+	}
+	return actions
+}
+
+// handleReviewCallback starts a review from its button. An approval asks
+// to press the button again, the other reviews ask for their text, so
+// nothing is submitted by a single stray tap.
+func (h *Handler) handleReviewCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data string) (string, error) {
+	name, entryID, _ := strings.Cut(data, ":")
+	event, ok := findReviewEvent(name)
+	if !ok {
+		return "Unknown action", nil
+	}
+	notification, err := h.buttonNotification(ctx, query, entryID)
+	if err != nil {
+		return "", err
+	}
+
+	if event.Prompt == "" {
+		h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonData(event.Confirm, callbackConfirmReview+data))
+		return "Press again to confirm", nil
+	}
+
+	account, pullURL := notification.Account, notification.URL
+	key := conversationKey{chatID: query.Message.Chat.ID, userID: query.From.ID}
+	h.conversations.wait(key, func(ctx context.Context, message *tgbotapi.Message) (step, error) {
+		return h.reviewBody(ctx, query, event, account, pullURL, message)
+	})
+
+	reply := tgbotapi.NewMessage(query.Message.Chat.ID, fmt.Sprintf("%s for %s as %s. /cancel to stop", event.Prompt, pullURL, account))
+	if _, err := h.Bot.Send(ctx, reply); err != nil {
+		return "", err
+	}
+	return "Waiting for your review", nil
+}
+
+// handleConfirmReviewCallback submits the review confirmed by pressing its
+// button a second time.
+func (h *Handler) handleConfirmReviewCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data string) (string, error) {
+	name, entryID, _ := strings.Cut(data, ":")
+	event, ok := findReviewEvent(name)
+	if !ok || event.Prompt != "" {
+		return "Unknown action", nil
+	}
+	notification, err := h.buttonNotification(ctx, query, entryID)
+	if err != nil {
+		return "", err
+	}
+
+	pullURL := notification.URL
+	if err := h.submitReview(ctx, query.Message.Chat.ID, notification.Account, pullURL, event.Event, ""); err != nil {
+		return "", err
+	}
+
+	h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL(event.Done, pullURL))
+	return event.Done, nil
+}
+
+// reviewBody submits the review with the text the user sent. A failed
+// submission keeps waiting for the text, so it can be sent again.
+func (h *Handler) reviewBody(ctx context.Context, query *tgbotapi.CallbackQuery, event reviewEvent, account, pullURL string, message *tgbotapi.Message) (step, error) {
+	retry := func(ctx context.Context, message *tgbotapi.Message) (step, error) {
+		return h.reviewBody(ctx, query, event, account, pullURL, message)
+	}
+
+	body := strings.TrimSpace(message.Text)
+	if body == "" {
+		return retry, fmt.Errorf("send the review as text, or /cancel")
+	}
+	if err := h.submitReview(ctx, message.Chat.ID, account, pullURL, event.Event, body); err != nil {
+		return retry, err
+	}
+
+	h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL(event.Done, pullURL))
+	reply := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("%s on %s", event.Done, pullURL))
+	_, err := h.Bot.Send(ctx, reply)
+	return nil, err
+}
+
+// submitReview reviews the pull request with the token of the chat's
+// account the review was requested from.
+func (h *Handler) submitReview(ctx context.Context, chatID int64, username, pullURL, event, body string) error {
+	user, exists := h.store.GetUser(ctx, chatID)
+	if !exists {
+		return fmt.Errorf("GitHub account %s not found", username)
+	}
+	account, ok := user.Accounts[username]
+	if !ok {
+		return fmt.Errorf("GitHub account %s not found", username)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return github.NewClient(account.Token).SubmitReview(ctx, pullURL, event, body)
+}
@@ -0,0 +1,206 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const routeUsage = `usage: /route add <conditions> -> <actions> or /route remove <id>, e.g. /route add repo=acme/* type=ci_activity -> chat=-100123 silent
+Conditions are type=, repo=, label= and keyword=, quoted when they contain spaces. Actions are chat=<id>, silent and severity=<critical|high|normal|low>`
+
+// handleRoute adds or removes a routing rule, or lists the rules without
+// arguments.
+func (h *Handler) handleRoute(ctx context.Context, message *tgbotapi.Message) error {
+	args := splitArgs(message.CommandArguments())
+
+	var text string
+	switch {
+	case len(args) == 0:
+		rules, err := h.store.GetRoutingRules(ctx, message.Chat.ID)
+		if err != nil {
+			return err
+		}
+		text = describeRoutes(rules)
+	case args[0] == "add":
+		rule, err := parseRoute(args[1:])
+		if err != nil {
+			return err
+		}
+		rule.ChatID = message.Chat.ID
+		if err := h.checkDestination(ctx, message, rule.DestinationChatID); err != nil {
+			return err
+		}
+		id, err := h.store.AddRoutingRule(ctx, rule)
+		if err != nil {
+			return err
+		}
+		rule.ID = id
+		text = fmt.Sprintf("Added routing rule %s", describeRoute(rule))
+	case args[0] == "remove" && len(args) == 2:
+		id, err := strconv.ParseInt(strings.TrimPrefix(args[1], "#"), 10, 64)
+		if err != nil {
+			return fmt.Errorf(routeUsage)
+		}
+		if err := h.store.RemoveRoutingRule(ctx, message.Chat.ID, id); err != nil {
+			return err
+		}
+		text = fmt.Sprintf("Removed routing rule #%d", id)
+	default:
+		return fmt.Errorf(routeUsage)
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err := h.Bot.Send(ctx, reply)
+	return err
+}
+
+// checkDestination makes sure notifications are only routed to chats the
+// user administers, so a rule cannot post into somebody else's group.
+func (h *Handler) checkDestination(ctx context.Context, message *tgbotapi.Message, destination int64) error {
+	if destination == 0 || destination == message.Chat.ID {
+		return nil
+	}
+	if message.From == nil {
+		return fmt.Errorf("cannot verify that you administer chat %d", destination)
+	}
+
+	member, err := h.Bot.API.GetChatMember(tgbotapi.GetChatMemberConfig{
+		ChatConfigWithUser: tgbotapi.ChatConfigWithUser{ChatID: destination, UserID: message.From.ID},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot route to chat %d, is the bot a member of it? %v", destination, err)
+	}
+	if !member.IsCreator() && !member.IsAdministrator() {
+		return fmt.Errorf("you need to be an administrator of chat %d to route notifications to it", destination)
+	}
+	return nil
+}
+
+// parseRoute parses the conditions and actions of /route add, separated
+// by an arrow.
+func parseRoute(args []string) (models.RoutingRule, error) {
+	var rule models.RoutingRule
+	actions := false
+	for _, arg := range args {
+		if arg == "->" {
+			if actions {
+				return rule, fmt.Errorf(routeUsage)
+			}
+			actions = true
+			continue
+		}
+		if actions && arg == "silent" {
+			rule.Silent = true
+			continue
+		}
+
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf(routeUsage)
+		}
+		switch {
+		case !actions && key == "type":
+			rule.Type = value
+		case !actions && key == "repo":
+			rule.Repo = value
+		case !actions && key == "label":
+			rule.Label = value
+		case !actions && key == "keyword":
+			rule.Keyword = value
+		case actions && key == "severity":
+			rule.Severity = value
+		case actions && key == "chat":
+			chatID, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || chatID == 0 {
+				return rule, fmt.Errorf("invalid chat %q, expected a chat ID such as -100123", value)
+			}
+			rule.DestinationChatID = chatID
+		default:
+			return rule, fmt.Errorf(routeUsage)
+		}
+	}
+	if !actions {
+		return rule, fmt.Errorf(routeUsage)
+	}
+	return rule, rule.Validate()
+}
+
+// splitArgs splits command arguments at spaces, keeping double-quoted
+// text together, so keyword="prod down" is one argument.
+func splitArgs(text string) []string {
+	var args []string
+	var arg strings.Builder
+	quoted, started := false, false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			started = true
+		case r == ' ' && !quoted:
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+func describeRoutes(rules []models.RoutingRule) string {
+	if len(rules) == 0 {
+		return "No routing rules. Add one with /route add, e.g. /route add label=security -> severity=critical"
+	}
+
+	var text strings.Builder
+	text.WriteString("Routing rules, applied in order:\n")
+	for _, rule := range rules {
+		text.WriteString("\n")
+		text.WriteString(describeRoute(rule))
+	}
+	return text.String()
+}
+
+func describeRoute(rule models.RoutingRule) string {
+	var parts []string
+	for _, condition := range []struct{ key, value string }{
+		{"type", rule.Type},
+		{"repo", rule.Repo},
+		{"label", rule.Label},
+		{"keyword", rule.Keyword},
+	} {
+		switch {
+		case condition.value == "":
+		case strings.Contains(condition.value, " "):
+			parts = append(parts, fmt.Sprintf("%s=%q", condition.key, condition.value))
+		default:
+			parts = append(parts, condition.key+"="+condition.value)
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "all")
+	}
+
+	parts = append(parts, "->")
+	if rule.Severity != "" {
+		parts = append(parts, "severity="+rule.Severity)
+	}
+	if rule.DestinationChatID != 0 {
+		parts = append(parts, fmt.Sprintf("chat=%d", rule.DestinationChatID))
+	}
+	if rule.Silent {
+		parts = append(parts, "silent")
+	}
+	return fmt.Sprintf("#%d %s", rule.ID, strings.Join(parts, " "))
+}
@@ -0,0 +1,63 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const securityUsage = "usage: /security <username> [on|off], e.g. /security octocat on"
+
+// handleSecurity turns the Dependabot and code scanning alerts of a GitHub
+// account on or off, or shows whether they are on with only the username.
+func (h *Handler) handleSecurity(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		return fmt.Errorf(securityUsage)
+	}
+
+	username := args[0]
+	user, exists := h.store.GetUser(ctx, message.Chat.ID)
+	if !exists || user.Accounts[username] == nil {
+		return store.ErrAccountNotFound
+	}
+	preferences, err := h.store.GetAccountPreferences(ctx, message.Chat.ID, username)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 2 {
+		switch args[1] {
+		case "on":
+			if !preferences.SecurityAlerts {
+				// Alerts count as new from now on, not from when they
+				// were last turned off.
+				state := models.AccountState{ChatID: message.Chat.ID, Kind: models.AccountKindSecurity, Account: username, LastCheckedAt: time.Now()}
+				if err := h.store.SaveAccountState(ctx, state); err != nil {
+					return err
+				}
+			}
+			preferences.SecurityAlerts = true
+		case "off":
+			preferences.SecurityAlerts = false
+		default:
+			return fmt.Errorf(securityUsage)
+		}
+		if err := h.store.SetAccountPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	}
+
+	text := fmt.Sprintf("Security alerts of %s are off. Turn them on with /security %s on", username, username)
+	if preferences.SecurityAlerts {
+		text = fmt.Sprintf("New Dependabot and code scanning alerts on the repositories of %s are sent, checked hourly. The token needs the security_events scope, or for fine-grained tokens read access to Dependabot and code scanning alerts", username)
+	}
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
@@ -0,0 +1,53 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const slaUsage = "usage: /sla <business hours> or /sla off, e.g. /sla 8 to reply to mentions within 8 business hours"
+
+// handleSLA sets how many business hours the chat's accounts have to reply
+// to mentions, or shows the SLA without arguments.
+func (h *Handler) handleSLA(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.TrimSpace(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch args {
+	case "":
+		if preferences.MentionSLA == 0 {
+			text = "No mention SLA. Set one with e.g. /sla 8 to be reminded of mentions left unanswered for 8 business hours"
+		} else {
+			text = fmt.Sprintf("Mentions left unanswered for %d business hours (09:00-17:00 on weekdays, %s) are sent as reminders", preferences.MentionSLA, preferences.Timezone)
+		}
+		reply := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case "off":
+		preferences.MentionSLA = 0
+		text = "No mention SLA. Mentions are no longer tracked"
+	default:
+		hours, err := strconv.Atoi(args)
+		if err != nil || hours < 1 {
+			return fmt.Errorf(slaUsage)
+		}
+		preferences.MentionSLA = hours
+		text = fmt.Sprintf("Mentions left unanswered for %d business hours (09:00-17:00 on weekdays, %s) are sent as reminders. Mentions are tracked from now on", hours, preferences.Timezone)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
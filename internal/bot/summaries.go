@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const summariesUsage = "usage: /summaries on or /summaries off"
+
+// handleSummaries opts the chat in to or out of summaries of long threads,
+// or shows the setting without arguments.
+func (h *Handler) handleSummaries(ctx context.Context, message *tgbotapi.Message) error {
+	if h.cfg.LLMURL == "" {
+		return fmt.Errorf("thread summaries are not available, the operator did not configure a language model")
+	}
+
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	switch args := strings.TrimSpace(message.CommandArguments()); args {
+	case "":
+	case "on", "off":
+		preferences.Summaries = args == "on"
+		if err := h.store.SetPreferences(ctx, preferences); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf(summariesUsage)
+	}
+
+	text := "Thread summaries are off. Turn them on with /summaries on"
+	if preferences.Summaries {
+		text = fmt.Sprintf("Notifications about issues and pull requests with at least %d comments come with a summary of the discussion, written by a language model from the thread", h.cfg.SummaryMinComments)
+	}
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
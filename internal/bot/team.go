@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const teamDigestUsage = "usage: /teamdigest <minute> <hour> <day> <month> <weekday> [owner/repo...] or /teamdigest off, e.g. /teamdigest 0 9 * * 1-5 acme/api acme/web for weekdays at 09:00"
+
+// handleTeamDigest sets when a group chat gets its team digest and which
+// repositories it covers, or shows the setting without arguments.
+func (h *Handler) handleTeamDigest(ctx context.Context, message *tgbotapi.Message) error {
+	if !message.Chat.IsGroup() && !message.Chat.IsSuperGroup() {
+		return fmt.Errorf("team digests are only sent to group chats, add the bot to your team's group and use /teamdigest there")
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 0:
+		reply := tgbotapi.NewMessage(message.Chat.ID, describeTeamDigest(preferences))
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case len(args) == 1 && args[0] == "off":
+		preferences.TeamDigest = ""
+		preferences.TeamRepos = nil
+		text = "Team digests are off"
+	case len(args) >= 5:
+		preferences.TeamDigest = strings.Join(args[:5], " ")
+		preferences.TeamRepos = args[5:]
+		text = describeTeamDigest(preferences)
+	default:
+		return fmt.Errorf(teamDigestUsage)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+	if preferences.TeamDigest != "" {
+		// Count the schedule from now, so a digest last sent long ago is
+		// not sent right away.
+		state := models.AccountState{ChatID: message.Chat.ID, Kind: models.AccountKindTeamDigest, LastCheckedAt: time.Now()}
+		if err := h.store.SaveAccountState(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeTeamDigest(preferences models.Preferences) string {
+	if preferences.TeamDigest == "" {
+		return "No team digest. Schedule one with e.g. /teamdigest 0 9 * * 1-5 acme/api acme/web"
+	}
+	text := fmt.Sprintf("The team digest is sent at %s (%s) with the review requests waiting on this chat's accounts", preferences.TeamDigest, preferences.Timezone)
+	if len(preferences.TeamRepos) > 0 {
+		text += fmt.Sprintf(", and stale pull requests and merges in %s", strings.Join(preferences.TeamRepos, ", "))
+	}
+	return text
+}
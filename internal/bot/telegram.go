@@ -1,61 +1,210 @@
 package bot
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/tracing"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Bot struct {
-	API *tgbotapi.BotAPI
+	API     *tgbotapi.BotAPI
+	limiter *sendLimiter
 }
 
-func New(token string) (*Bot, error) {
-	bot, err := tgbotapi.NewBotAPI(token)
+// ClientConfig is how a bot reaches the Telegram API.
+type ClientConfig struct {
+	// Transport sends the requests, the default transport when nil.
+	Transport http.RoundTripper
+
+	// Timeout bounds each request. Long polls for updates may take
+	// PollingTimeout longer. Requests are not bounded when it is 0.
+	Timeout        time.Duration
+	PollingTimeout time.Duration
+
+	// APIURL is the Bot API's base URL, api.telegram.org when empty.
+	APIURL string
+}
+
+// New connects to the bot with the given token.
+func New(token string, clientConfig ClientConfig) (*Bot, error) {
+	redact.Add(token)
+
+	transport := clientConfig.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := &timeoutClient{
+		client:         &http.Client{Transport: transport},
+		timeout:        clientConfig.Timeout,
+		pollingTimeout: clientConfig.PollingTimeout,
+	}
+	endpoint := tgbotapi.APIEndpoint
+	if clientConfig.APIURL != "" {
+		endpoint = clientConfig.APIURL + "/bot%s/%s"
+	}
+	bot, err := tgbotapi.NewBotAPIWithClient(token, endpoint, client)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %v", err)
 	}
 
 	return &Bot{
-		API: bot,
+		API:     bot,
+		limiter: newSendLimiter(),
 	}, nil
 }
 
-func (b *Bot) SendNotification(chatID int64, notification models.Notification) error {
-	message := fmt.Sprintf("%s\n%s", notification.Message, notification.URL)
-	msg := tgbotapi.NewMessage(chatID, escapeMarkdown(message))
-	msg.ParseMode = tgbotapi.ModeMarkdownV2
+// timeoutClient applies a deadline to every Telegram API request, as the
+// API client makes its requests without a context. A request that hangs
+// would otherwise stall its caller, e.g. the outbox dispatcher, forever.
+type timeoutClient struct {
+	client         *http.Client
+	timeout        time.Duration
+	pollingTimeout time.Duration
+}
+
+func (c *timeoutClient) Do(req *http.Request) (*http.Response, error) {
+	if c.timeout <= 0 {
+		return c.client.Do(req)
+	}
 
-	_, err := b.API.Send(msg)
+	timeout := c.timeout
+	if strings.HasSuffix(req.URL.Path, "/getUpdates") {
+		timeout += c.pollingTimeout
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := c.client.Do(req.WithContext(ctx))
 	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline covers reading the body, so it ends when the caller
+	// closes it
+	resp.Body = cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// Send delivers a message within Telegram's flood limits. It waits for a
+// free slot and, when Telegram still answers with 429 Too Many Requests,
+// holds back every message of the bot for the requested time and retries.
+func (b *Bot) Send(ctx context.Context, msg tgbotapi.MessageConfig) (sent tgbotapi.Message, err error) {
+	ctx, span := tracing.Start(ctx, "telegram.send", attribute.Int64("chat_id", msg.ChatID))
+	defer func() { tracing.End(span, err) }()
+
+	for attempt := 0; ; attempt++ {
+		if err := sleep(ctx, b.limiter.reserve(msg.ChatID)); err != nil {
+			return tgbotapi.Message{}, err
+		}
+
+		sent, err = b.API.Send(msg)
+		var apiErr *tgbotapi.Error
+		if !errors.As(err, &apiErr) || apiErr.RetryAfter <= 0 || attempt >= sendRetries {
+			return sent, err
+		}
+		span.AddEvent("rate limited", trace.WithAttributes(attribute.Int("retry_after", apiErr.RetryAfter)))
+
+		retryAfter := time.Duration(apiErr.RetryAfter) * time.Second
+		log.Printf("Telegram rate limit hit for chat %d, pausing sends for %v", msg.ChatID, retryAfter)
+		b.limiter.pause(time.Now().Add(retryAfter))
+	}
+}
+
+// actionsPerRow is how many buttons are put side by side below a
+// notification before starting another row.
+const actionsPerRow = 3
+
+// SendNotification sends one notification rendered in the format, with
+// the actions as buttons below it. Silent notifications arrive without
+// sound.
+func (b *Bot) SendNotification(ctx context.Context, chatID int64, format *render.Format, notification models.Notification, silent bool, actions ...tgbotapi.InlineKeyboardButton) error {
+	text, err := format.Notification(notification)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = format.ParseMode()
+	msg.DisableNotification = silent
+	if len(actions) > 0 {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		for len(actions) > actionsPerRow {
+			rows = append(rows, actions[:actionsPerRow])
+			actions = actions[actionsPerRow:]
+		}
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(append(rows, actions)...)
+	}
+
+	if _, err := b.Send(ctx, msg); err != nil {
 		return fmt.Errorf("failed to send message: %v", err)
 	}
 
 	return nil
 }
 
-func escapeMarkdown(text string) string {
-	replacer := strings.NewReplacer(
-		"_", "\\_",
-		"*", "\\*",
-		"[", "\\[",
-		"]", "\\]",
-		"(", "\\(",
-		")", "\\)",
-		"~", "\\~",
-		"`", "\\`",
-		">", "\\>",
-		"#", "\\#",
-		"+", "\\+",
-		"-", "\\-",
-		"=", "\\=",
-		"|", "\\|",
-		"{", "\\{",
-		"}", "\\}",
-		".", "\\.",
-		"!", "\\!",
-	)
-	return replacer.Replace(text)
+// SendDigest sends several notifications as one message under a heading,
+// without the actions of single notifications. Consecutive notifications
+// of the same repository are listed under the repository's name, so
+// callers sort them by repository to group them.
+func (b *Bot) SendDigest(ctx context.Context, chatID int64, format *render.Format, heading string, notifications []models.Notification, silent bool) error {
+	var message strings.Builder
+	message.WriteString(format.Escape(heading))
+	for i, notification := range notifications {
+		text, err := format.Notification(notification)
+		if err != nil {
+			return err
+		}
+		if i == 0 || notification.Repo != notifications[i-1].Repo {
+			count := 1
+			for _, next := range notifications[i+1:] {
+				if next.Repo != notification.Repo {
+					break
+				}
+				count++
+			}
+			message.WriteString("\n\n")
+			message.WriteString(format.Escape(digestRepoHeading(notification.Repo, count)))
+		}
+		message.WriteString("\n\n")
+		message.WriteString(text)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, message.String())
+	msg.ParseMode = format.ParseMode()
+	msg.DisableNotification = silent
+	if _, err := b.Send(ctx, msg); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	return nil
+}
+
+// digestRepoHeading introduces the notifications of a repository in a
+// digest.
+func digestRepoHeading(repo string, count int) string {
+	if repo == "" {
+		repo = "Other"
+	}
+	return fmt.Sprintf("%s (%d)", repo, count)
 }
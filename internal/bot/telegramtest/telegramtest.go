@@ -0,0 +1,213 @@
+// Package telegramtest provides a fake Telegram Bot API for tests that run
+// the monitor end to end. It records the messages bots send and hands
+// them scripted commands as updates:
+//
+//	server := telegramtest.NewServer()
+//	api := server.Start()
+//	defer api.Close()
+//	// point the monitor at api.URL with TELEGRAM_API_URL
+//	server.SendCommand(123, "/list")
+//	messages := server.WaitForMessages(1, 5*time.Second)
+package telegramtest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLongPoll bounds how long getUpdates waits for updates, so tests are
+// not held up by the bots' long polling when they shut down.
+const maxLongPoll = time.Second
+
+// Message is a message a bot sent or edited.
+type Message struct {
+	Method              string          `json:"method"`
+	ChatID              int64           `json:"chat_id"`
+	Text                string          `json:"text"`
+	ParseMode           string          `json:"parse_mode,omitempty"`
+	DisableNotification bool            `json:"disable_notification,omitempty"`
+	ReplyMarkup         json.RawMessage `json:"reply_markup,omitempty"`
+}
+
+// Server is a fake Telegram Bot API for any bot token. Start it with
+// httptest or mount it as an http.Handler, and point bots at it with
+// TELEGRAM_API_URL.
+type Server struct {
+	mu       sync.Mutex
+	messages []Message
+	updates  []map[string]any
+	nextID   int
+	changed  chan struct{}
+}
+
+// NewServer returns a server with no messages or pending updates.
+func NewServer() *Server {
+	return &Server{nextID: 1, changed: make(chan struct{})}
+}
+
+// Start serves the server on a local port until the returned server is
+// closed.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// SendCommand queues a text message from the chat's user, such as
+// "/list", for the bots' next getUpdates.
+func (s *Server) SendCommand(chatID int64, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	message := map[string]any{
+		"message_id": id,
+		"date":       time.Now().Unix(),
+		"chat":       map[string]any{"id": chatID, "type": "private"},
+		"from":       map[string]any{"id": chatID, "is_bot": false, "first_name": "Test"},
+		"text":       text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command, _, _ := strings.Cut(text, " ")
+		message["entities"] = []map[string]any{{"type": "bot_command", "offset": 0, "length": len(command)}}
+	}
+	s.updates = append(s.updates, map[string]any{"update_id": id, "message": message})
+	s.notify()
+}
+
+// Messages returns the messages sent so far, in order.
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Message(nil), s.messages...)
+}
+
+// WaitForMessages waits until at least n messages were sent or the
+// timeout passes, and returns the messages sent by then.
+func (s *Server) WaitForMessages(n int, timeout time.Duration) []Message {
+	deadline := time.After(timeout)
+	for {
+		s.mu.Lock()
+		if len(s.messages) >= n {
+			messages := append([]Message(nil), s.messages...)
+			s.mu.Unlock()
+			return messages
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return s.Messages()
+		}
+	}
+}
+
+// notify wakes up the waiters; s.mu must be held.
+func (s *Server) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Requests are made to /bot<token>/<method>
+	path := strings.Trim(r.URL.Path, "/")
+	token, method, ok := strings.Cut(path, "/")
+	if !ok || !strings.HasPrefix(token, "bot") {
+		writeResult(w, http.StatusNotFound, nil, "Not Found")
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeResult(w, http.StatusBadRequest, nil, "Bad Request: "+err.Error())
+		return
+	}
+
+	switch {
+	case method == "getMe":
+		writeResult(w, http.StatusOK, map[string]any{"id": 1, "is_bot": true, "first_name": "Monitor", "username": "monitor_test_bot"}, "")
+	case method == "getUpdates":
+		writeResult(w, http.StatusOK, s.getUpdates(r), "")
+	case strings.HasPrefix(method, "send") || strings.HasPrefix(method, "edit"):
+		writeResult(w, http.StatusOK, s.record(method, r), "")
+	default:
+		writeResult(w, http.StatusOK, true, "")
+	}
+}
+
+// getUpdates returns the updates from offset on, waiting up to the
+// request's timeout, at most maxLongPoll, for one to arrive.
+func (s *Server) getUpdates(r *http.Request) []map[string]any {
+	offset, _ := strconv.Atoi(r.Form.Get("offset"))
+	timeout, _ := strconv.Atoi(r.Form.Get("timeout"))
+	ctx, cancel := context.WithTimeout(r.Context(), min(time.Duration(timeout)*time.Second, maxLongPoll))
+	defer cancel()
+
+	for {
+		s.mu.Lock()
+		updates := []map[string]any{}
+		for _, update := range s.updates {
+			if update["update_id"].(int) >= offset {
+				updates = append(updates, update)
+			}
+		}
+		changed := s.changed
+		s.mu.Unlock()
+
+		if len(updates) > 0 {
+			return updates
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return updates
+		}
+	}
+}
+
+// record keeps a sent or edited message and returns it as Telegram would.
+func (s *Server) record(method string, r *http.Request) map[string]any {
+	chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+	message := Message{
+		Method:    method,
+		ChatID:    chatID,
+		Text:      r.Form.Get("text"),
+		ParseMode: r.Form.Get("parse_mode"),
+	}
+	message.DisableNotification, _ = strconv.ParseBool(r.Form.Get("disable_notification"))
+	if markup := r.Form.Get("reply_markup"); markup != "" {
+		message.ReplyMarkup = json.RawMessage(markup)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, message)
+	id := s.nextID
+	s.nextID++
+	s.notify()
+
+	return map[string]any{
+		"message_id": id,
+		"date":       time.Now().Unix(),
+		"chat":       map[string]any{"id": chatID, "type": "private"},
+		"text":       message.Text,
+	}
+}
+
+func writeResult(w http.ResponseWriter, status int, result any, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	response := map[string]any{"ok": status == http.StatusOK}
+	if status == http.StatusOK {
+		response["result"] = result
+	} else {
+		response["error_code"] = status
+		response["description"] = description
+	}
+	json.NewEncoder(w).Encode(response)
+}
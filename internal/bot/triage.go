@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const triageUsage = "usage: /triage <hours> <owner/repo>... [labels=<label>,...] or /triage off, e.g. /triage 24 acme/api labels=bug,enhancement,question"
+
+// handleTriage turns maintainer triage mode on or off, or shows it without
+// arguments.
+func (h *Handler) handleTriage(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 0:
+		reply := tgbotapi.NewMessage(message.Chat.ID, describeTriage(preferences.Triage))
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case len(args) == 1 && args[0] == "off":
+		preferences.Triage.After = 0
+		preferences.Triage.Repos = nil
+		text = "Triage mode is off"
+	case len(args) >= 2:
+		hours, err := strconv.Atoi(args[0])
+		if err != nil || hours < 1 {
+			return fmt.Errorf(triageUsage)
+		}
+		// Without labels=, the labels set before are kept.
+		triage := models.Triage{After: hours, Labels: preferences.Triage.Labels}
+		for _, arg := range args[1:] {
+			if labels, ok := strings.CutPrefix(arg, "labels="); ok {
+				triage.Labels = strings.Split(labels, ",")
+				continue
+			}
+			triage.Repos = append(triage.Repos, arg)
+		}
+		preferences.Triage = triage
+		text = describeTriage(triage)
+	default:
+		return fmt.Errorf(triageUsage)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeTriage(triage models.Triage) string {
+	if triage.After == 0 {
+		return "Triage mode is off. Turn it on with e.g. /triage 24 acme/api labels=bug,enhancement,question"
+	}
+	text := fmt.Sprintf("New issues in %s without labels or assignees after %d hours are sent for triage", strings.Join(triage.Repos, ", "), triage.After)
+	if len(triage.Labels) > 0 {
+		text += fmt.Sprintf(", with buttons to label them %s", strings.Join(triage.Labels, ", "))
+	}
+	return text
+}
+
+// handleTriageCallback applies a triage label to the issue of the message,
+// with the first of the chat's active accounts allowed to.
+func (h *Handler) handleTriageCallback(ctx context.Context, query *tgbotapi.CallbackQuery, data string) (string, error) {
+	entryID, label, _ := strings.Cut(data, ":")
+	accounts := h.activeAccounts(ctx, query.Message.Chat.ID)
+	if len(accounts) == 0 {
+		return "", fmt.Errorf("no active GitHub account to label the issue with")
+	}
+
+	notification, err := h.buttonNotification(ctx, query, entryID)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	for _, account := range accounts {
+		err = github.NewClient(account.Token).AddLabels(ctx, notification.URL, []string{label})
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	h.replaceAction(query, query.Data, tgbotapi.NewInlineKeyboardButtonURL("✅ "+label, notification.URL))
+	return fmt.Sprintf("Labeled %s", label), nil
+}
@@ -0,0 +1,60 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const usageUsage = "usage: /usage <percent> [org...] or /usage off, e.g. /usage 80 acme to be warned when 80 percent of the included Actions minutes or storage are used"
+
+// handleUsage sets the usage alert threshold and the organizations checked
+// besides the chat's accounts, or shows the setting without arguments.
+func (h *Handler) handleUsage(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.Fields(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch {
+	case len(args) == 0:
+		reply := tgbotapi.NewMessage(message.Chat.ID, describeUsageAlerts(preferences.Usage))
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case len(args) == 1 && args[0] == "off":
+		preferences.Usage = models.UsageAlerts{}
+		text = "Usage alerts are off"
+	default:
+		threshold, err := strconv.Atoi(strings.TrimSuffix(args[0], "%"))
+		if err != nil || threshold < 1 {
+			return fmt.Errorf(usageUsage)
+		}
+		preferences.Usage = models.UsageAlerts{Threshold: threshold, Orgs: args[1:]}
+		text = describeUsageAlerts(preferences.Usage)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
+
+func describeUsageAlerts(usage models.UsageAlerts) string {
+	if usage.Threshold == 0 {
+		return "Usage alerts are off. Turn them on with e.g. /usage 80 to be warned when 80% of the included Actions minutes or storage are used"
+	}
+	owners := "your accounts"
+	if len(usage.Orgs) > 0 {
+		owners += " and " + strings.Join(usage.Orgs, ", ")
+	}
+	return fmt.Sprintf("You are warned when %s used %d%% of the Actions minutes, storage or Git LFS quotas their plan includes, or of the 5 GB recommended repository size, and again when it is used up", owners, usage.Threshold)
+}
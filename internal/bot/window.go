@@ -0,0 +1,51 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const windowUsage = "usage: /window <minute> <hour> <day> <month> <weekday> or /window off, e.g. /window * 8-19 * * 1-5 for weekdays 08:00-20:00"
+
+// handleWindow sets the cron expression selecting when the chat's accounts
+// are polled and notifications delivered, or shows it without arguments.
+func (h *Handler) handleWindow(ctx context.Context, message *tgbotapi.Message) error {
+	args := strings.TrimSpace(message.CommandArguments())
+	preferences, err := h.store.GetPreferences(ctx, message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	var text string
+	switch args {
+	case "":
+		if preferences.PollWindow == "" {
+			text = "Accounts are polled at all times"
+		} else {
+			text = fmt.Sprintf("Accounts are polled during %s (%s)", preferences.PollWindow, preferences.Timezone)
+		}
+		reply := tgbotapi.NewMessage(message.Chat.ID, text)
+		_, err = h.Bot.Send(ctx, reply)
+		return err
+	case "off":
+		preferences.PollWindow = ""
+		text = "Accounts are polled at all times"
+	default:
+		if len(strings.Fields(args)) != 5 {
+			return fmt.Errorf(windowUsage)
+		}
+		preferences.PollWindow = strings.Join(strings.Fields(args), " ")
+		text = fmt.Sprintf("Accounts are polled during %s (%s). Activity from outside the window is sent as a digest once it opens", preferences.PollWindow, preferences.Timezone)
+	}
+
+	if err := h.store.SetPreferences(ctx, preferences); err != nil {
+		return err
+	}
+
+	reply := tgbotapi.NewMessage(message.Chat.ID, text)
+	_, err = h.Bot.Send(ctx, reply)
+	return err
+}
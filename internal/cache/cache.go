@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache is a key/value store with expiry used for hot paths such as dedup
+// lookups, HTTP ETags and rate-limit state.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Close() error
+}
+
+// sweepInterval is how many writes the memory cache takes between sweeps
+// of its expired entries.
+const sweepInterval = 1024
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// Memory is an in-process Cache used when no Redis instance is configured.
+type Memory struct {
+	mu      sync.Mutex
+	entries map[string]entry
+	writes  int
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		entries: make(map[string]entry),
+	}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !e.expiresAt.IsZero() && time.Now().After(e.expiresAt) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e := entry{value: value}
+	if ttl > 0 {
+		e.expiresAt = time.Now().Add(ttl)
+	}
+	m.entries[key] = e
+
+	// Expired entries are only dropped lazily on Get, so sweep every
+	// sweepInterval writes to keep keys that are never read again from
+	// accumulating, whatever size the map settles on.
+	m.writes++
+	if m.writes%sweepInterval == 0 {
+		now := time.Now()
+		for k, e := range m.entries {
+			if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
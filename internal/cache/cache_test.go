@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryExpiry(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	m.Set(ctx, "short", []byte("a"), time.Millisecond)
+	m.Set(ctx, "forever", []byte("b"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, found, _ := m.Get(ctx, "short"); found {
+		t.Error("expired entry was returned")
+	}
+	if value, found, _ := m.Get(ctx, "forever"); !found || string(value) != "b" {
+		t.Errorf("entry without expiry: %q, %v", value, found)
+	}
+}
+
+// TestMemorySweep checks that expired entries that are never read again
+// are dropped even when the number of entries does not change, e.g. when
+// the same keys are overwritten.
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory()
+	for i := 0; i < 10; i++ {
+		m.Set(ctx, fmt.Sprintf("stale-%d", i), []byte("x"), time.Millisecond)
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	for i := 0; i < sweepInterval; i++ {
+		m.Set(ctx, fmt.Sprintf("live-%d", i%3), []byte("x"), time.Hour)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.entries) != 3 {
+		t.Errorf("%d entries left after a sweep, want the 3 live ones", len(m.entries))
+	}
+}
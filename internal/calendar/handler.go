@@ -0,0 +1,118 @@
+package calendar
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+const (
+	cacheTTL       = time.Hour
+	releaseHorizon = 90 * 24 * time.Hour
+)
+
+type cachedFeed struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+// Handler serves per-user iCalendar feeds at /calendar/{token}.ics. The
+// feed token in the URL is the only credential since calendar apps cannot
+// send custom headers.
+type Handler struct {
+	store store.Store
+	mu    sync.Mutex
+	cache map[string]cachedFeed
+}
+
+func NewHandler(store store.Store) *Handler {
+	return &Handler{
+		store: store,
+		cache: make(map[string]cachedFeed),
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimSuffix(r.PathValue("feed"), ".ics")
+
+	h.mu.Lock()
+	cached, ok := h.cache[token]
+	h.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		writeFeed(w, cached.body)
+		return
+	}
+
+	user, exists := h.store.GetUserByCalendarToken(r.Context(), token)
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+
+	var events []Event
+	since := time.Now().Add(-releaseHorizon)
+	for _, account := range user.Accounts {
+		if !account.IsActive {
+			continue
+		}
+
+		entries, err := github.NewClient(account.Token).GetCalendarEntries(r.Context(), since)
+		if err != nil {
+			log.Printf("Error getting calendar entries for %s: %v", account.Username, err)
+			continue
+		}
+
+		for _, entry := range entries {
+			if user.MutedRepos[entry.Repo] {
+				continue
+			}
+			events = append(events, Event{
+				UID:         uid(entry.Kind, entry.ID),
+				Summary:     entry.Title,
+				Description: entry.Description,
+				URL:         entry.URL,
+				Date:        entry.Date,
+			})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+
+	var buf bytes.Buffer
+	if err := Write(&buf, "GitHub milestones and releases", dedupe(events)); err != nil {
+		http.Error(w, "failed to render calendar", http.StatusInternalServerError)
+		return
+	}
+
+	h.mu.Lock()
+	h.cache[token] = cachedFeed{body: buf.Bytes(), expiresAt: time.Now().Add(cacheTTL)}
+	h.mu.Unlock()
+
+	writeFeed(w, buf.Bytes())
+}
+
+// dedupe drops events surfaced by more than one account of the same user.
+func dedupe(events []Event) []Event {
+	seen := make(map[string]bool)
+	result := events[:0]
+	for _, event := range events {
+		if seen[event.UID] {
+			continue
+		}
+		seen[event.UID] = true
+		result = append(result, event)
+	}
+	return result
+}
+
+func writeFeed(w http.ResponseWriter, body []byte) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="github.ics"`)
+	_, _ = w.Write(body)
+}
@@ -1,45 +1,396 @@
 package config
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/erkineren/repository-monitor/internal/proxy"
+	"github.com/erkineren/repository-monitor/internal/secrets"
+	"github.com/erkineren/repository-monitor/internal/severity"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
 	TelegramBotToken string
 	DatabaseURL      string
-	RenotifyInterval int
-	PollInterval     int
-	PollingTimeout   int
+	DatabaseReadURL  string
+	RenotifyInterval time.Duration
+	PollInterval     time.Duration
+	PollWorkers      int
+	PollTimeout      time.Duration
+	PollShards       int
+	ShutdownDelay    time.Duration
+	HandoffTimeout   time.Duration
+	RetentionDays    int
+	UserGraceDays    int
+	PollingTimeout   time.Duration
+	GitHubTimeout    time.Duration
+	TelegramTimeout  time.Duration
 	Debug            bool
+	APIToken         string
+	PublicURL        string
+	RedisURL         string
+	Secrets          *secrets.Manager
+
+	// BackupPassphrase encrypts the account secrets of backups written
+	// by monitor export and decrypts them on import.
+	BackupPassphrase string
+
+	// Proxies for outbound requests. When nil, HTTPS_PROXY, HTTP_PROXY
+	// and NO_PROXY apply.
+	GitHubProxy   *url.URL
+	TelegramProxy *url.URL
+
+	// API endpoints, such as a GitHub Enterprise Server or the fake
+	// servers of end-to-end tests. The public APIs are used when empty.
+	GitHubAPIURL   string
+	TelegramAPIURL string
+
+	// OutboxShedThreshold is the number of pending notifications above
+	// which delivery sheds load, or 0 to never shed.
+	OutboxShedThreshold int
+
+	// Sinks are the delivery channels besides Telegram, each a sink name
+	// optionally followed by a colon and its configuration.
+	Sinks []string
+
+	// SeverityRules classify notifications before the defaults of their
+	// types, see the severity package.
+	SeverityRules []severity.Rule
+
+	// UrgencyMarkers raise the severity of notifications whose message
+	// contains them, see the severity package. Empty to disable.
+	UrgencyMarkers []string
+
+	// Thread summaries, for chats that opt in with /summaries: an
+	// OpenAI-compatible API such as https://api.openai.com/v1, its key and
+	// model. Summaries are off when LLMURL is empty. Threads are
+	// summarized from SummaryMinComments comments.
+	LLMURL             string
+	LLMAPIKey          string
+	LLMModel           string
+	SummaryMinComments int
+
+	DBMaxConns          int
+	DBMinConns          int
+	DBMaxConnLifetime   time.Duration
+	DBMaxConnIdleTime   time.Duration
+	DBHealthCheckPeriod time.Duration
+	DBStatementTimeout  time.Duration
+
+	// MigrateOnStart applies pending expand migrations when the store is
+	// opened. Without it the schema must be migrated with 'monitor
+	// migrate' beforehand.
+	MigrateOnStart bool
+
+	// Standalone mode serves a single chat without a database, see
+	// Standalone.
+	GitHubToken    string
+	GitHubUsername string
+	TelegramChatID int64
+	StateFile      string
 }
 
 func Load() (*Config, error) {
-	if err := godotenv.Load(); err != nil {
+	// The .env file is optional when everything is set in the environment
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("error loading .env file: %v", err)
 	}
 
-	renotifyInterval, err := strconv.Atoi(getEnvWithDefault("RENOTIFY_INTERVAL", "3600"))
+	// RENOTIFY_INTERVAL needs a unit: plain numbers were documented as
+	// both seconds and hours, so neither reading is safe.
+	renotifyInterval, err := getDuration("RENOTIFY_INTERVAL", "24h", 0, time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	pollInterval, err := getDuration("POLL_INTERVAL", "60s", time.Second, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	pollWorkers, err := strconv.Atoi(getEnvWithDefault("POLL_WORKERS", "4"))
+	if err != nil || pollWorkers < 1 {
+		return nil, fmt.Errorf("invalid POLL_WORKERS: must be a positive integer")
+	}
+
+	pollTimeout, err := getDuration("POLL_TIMEOUT", "60s", time.Second, time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	pollShards, err := strconv.Atoi(getEnvWithDefault("POLL_SHARDS", "1"))
+	if err != nil || pollShards < 1 {
+		return nil, fmt.Errorf("invalid POLL_SHARDS: must be a positive integer")
+	}
+
+	githubTimeout, err := getDuration("GITHUB_TIMEOUT", "30s", time.Second, time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("invalid RENOTIFY_INTERVAL: %v", err)
+		return nil, err
 	}
 
-	pollInterval, err := strconv.Atoi(getEnvWithDefault("POLL_INTERVAL", "60"))
+	telegramTimeout, err := getDuration("TELEGRAM_TIMEOUT", "30s", time.Second, time.Second)
 	if err != nil {
-		return nil, fmt.Errorf("invalid POLL_INTERVAL: %v", err)
+		return nil, err
+	}
+
+	shutdownDelay, err := getDuration("SHUTDOWN_DELAY", "0s", time.Second, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	handoffTimeout, err := getDuration("HANDOFF_TIMEOUT", "0s", time.Second, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	userGraceDays, err := strconv.Atoi(getEnvWithDefault("USER_GRACE_DAYS", "30"))
+	if err != nil || userGraceDays < 0 {
+		return nil, fmt.Errorf("invalid USER_GRACE_DAYS: must be a non-negative integer")
+	}
+
+	retentionDays, err := strconv.Atoi(getEnvWithDefault("RETENTION_DAYS", "30"))
+	if err != nil || retentionDays < 1 {
+		return nil, fmt.Errorf("invalid RETENTION_DAYS: must be a positive integer")
+	}
+
+	outboxShedThreshold, err := strconv.Atoi(getEnvWithDefault("OUTBOX_SHED_THRESHOLD", "1000"))
+	if err != nil || outboxShedThreshold < 0 {
+		return nil, fmt.Errorf("invalid OUTBOX_SHED_THRESHOLD: must be a non-negative integer")
+	}
+
+	migrateOnStart, err := strconv.ParseBool(getEnvWithDefault("MIGRATE_ON_START", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MIGRATE_ON_START: must be true or false")
+	}
+
+	summaryMinComments, err := strconv.Atoi(getEnvWithDefault("SUMMARY_MIN_COMMENTS", "20"))
+	if err != nil || summaryMinComments < 1 {
+		return nil, fmt.Errorf("invalid SUMMARY_MIN_COMMENTS: must be a positive integer")
+	}
+
+	var telegramChatID int64
+	if value := os.Getenv("TELEGRAM_CHAT_ID"); value != "" {
+		if telegramChatID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid TELEGRAM_CHAT_ID: %v", err)
+		}
+	}
+
+	dbMaxConns, err := strconv.Atoi(getEnvWithDefault("DB_MAX_CONNS", "10"))
+	if err != nil || dbMaxConns < 0 {
+		return nil, fmt.Errorf("invalid DB_MAX_CONNS: must be a non-negative integer")
+	}
+
+	dbMinConns, err := strconv.Atoi(getEnvWithDefault("DB_MIN_CONNS", "0"))
+	if err != nil || dbMinConns < 0 {
+		return nil, fmt.Errorf("invalid DB_MIN_CONNS: must be a non-negative integer")
 	}
 
-	return &Config{
+	dbDurations := map[string]time.Duration{}
+	for name, defaultValue := range map[string]string{
+		"DB_MAX_CONN_LIFETIME":   "1h",
+		"DB_MAX_CONN_IDLE_TIME":  "30m",
+		"DB_HEALTH_CHECK_PERIOD": "1m",
+		"DB_STATEMENT_TIMEOUT":   "30s",
+	} {
+		value, err := getDuration(name, defaultValue, time.Second, 0)
+		if err != nil {
+			return nil, err
+		}
+		dbDurations[name] = value
+	}
+
+	cfg := &Config{
 		TelegramBotToken: os.Getenv("TELEGRAM_BOT_TOKEN"),
 		DatabaseURL:      os.Getenv("DATABASE_URL"),
+		DatabaseReadURL:  os.Getenv("DATABASE_READ_URL"),
 		RenotifyInterval: renotifyInterval,
 		PollInterval:     pollInterval,
-		PollingTimeout:   60,    // Default Telegram polling timeout
-		Debug:            false, // Debug mode disabled by default
-	}, nil
+		PollWorkers:      pollWorkers,
+		PollTimeout:      pollTimeout,
+		PollShards:       pollShards,
+		ShutdownDelay:    shutdownDelay,
+		HandoffTimeout:   handoffTimeout,
+		RetentionDays:    retentionDays,
+		UserGraceDays:    userGraceDays,
+		GitHubTimeout:    githubTimeout,
+		TelegramTimeout:  telegramTimeout,
+		PollingTimeout:   60 * time.Second, // Default Telegram polling timeout
+		Debug:            false,            // Debug mode disabled by default
+		APIToken:         os.Getenv("API_TOKEN"),
+		PublicURL:        strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/"),
+		RedisURL:         os.Getenv("REDIS_URL"),
+		Secrets:          secrets.NewManager(),
+
+		BackupPassphrase: os.Getenv("BACKUP_PASSPHRASE"),
+
+		OutboxShedThreshold: outboxShedThreshold,
+
+		Sinks: splitSinks(os.Getenv("SINKS")),
+
+		LLMURL:             strings.TrimSuffix(os.Getenv("LLM_URL"), "/"),
+		LLMAPIKey:          os.Getenv("LLM_API_KEY"),
+		LLMModel:           getEnvWithDefault("LLM_MODEL", "gpt-4o-mini"),
+		SummaryMinComments: summaryMinComments,
+
+		DBMaxConns:          dbMaxConns,
+		DBMinConns:          dbMinConns,
+		DBMaxConnLifetime:   dbDurations["DB_MAX_CONN_LIFETIME"],
+		DBMaxConnIdleTime:   dbDurations["DB_MAX_CONN_IDLE_TIME"],
+		DBHealthCheckPeriod: dbDurations["DB_HEALTH_CHECK_PERIOD"],
+		DBStatementTimeout:  dbDurations["DB_STATEMENT_TIMEOUT"],
+
+		MigrateOnStart: migrateOnStart,
+
+		GitHubToken:    os.Getenv("GITHUB_TOKEN"),
+		GitHubUsername: os.Getenv("GITHUB_USERNAME"),
+		TelegramChatID: telegramChatID,
+		StateFile:      getEnvWithDefault("STATE_FILE", "monitor-state.json"),
+	}
+
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, err
+	}
+
+	for name, target := range map[string]**url.URL{"GITHUB_PROXY": &cfg.GitHubProxy, "TELEGRAM_PROXY": &cfg.TelegramProxy} {
+		proxyURL, err := proxy.Parse(os.Getenv(name))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", name, err)
+		}
+		*target = proxyURL
+	}
+
+	cfg.SeverityRules, err = severity.ParseRules(os.Getenv("SEVERITY_RULES"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SEVERITY_RULES: %v", err)
+	}
+	cfg.UrgencyMarkers = parseUrgencyMarkers(os.Getenv("URGENCY_MARKERS"))
+
+	for name, target := range map[string]*string{"GITHUB_API_URL": &cfg.GitHubAPIURL, "TELEGRAM_API_URL": &cfg.TelegramAPIURL, "LLM_URL": &cfg.LLMURL} {
+		value := strings.TrimSuffix(os.Getenv(name), "/")
+		if value != "" {
+			if parsed, err := url.Parse(value); err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return nil, fmt.Errorf("invalid %s: must be an absolute URL", name)
+			}
+		}
+		*target = value
+	}
+
+	if cfg.Standalone() && cfg.TelegramChatID == 0 {
+		return nil, fmt.Errorf("TELEGRAM_CHAT_ID is required when GITHUB_TOKEN is set without DATABASE_URL")
+	}
+
+	return cfg, nil
+}
+
+// Standalone reports whether the monitor serves the single chat in
+// TELEGRAM_CHAT_ID for the account in GITHUB_TOKEN, keeping its state in
+// STATE_FILE instead of a database.
+func (c *Config) Standalone() bool {
+	return c.DatabaseURL == "" && c.GitHubToken != ""
+}
+
+// resolveSecrets replaces settings that reference Vault or AWS Secrets
+// Manager with the secret values.
+func (c *Config) resolveSecrets() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	settings := map[string]*string{
+		"TELEGRAM_BOT_TOKEN": &c.TelegramBotToken,
+		"DATABASE_URL":       &c.DatabaseURL,
+		"DATABASE_READ_URL":  &c.DatabaseReadURL,
+		"API_TOKEN":          &c.APIToken,
+		"REDIS_URL":          &c.RedisURL,
+		"GITHUB_TOKEN":       &c.GitHubToken,
+		"LLM_API_KEY":        &c.LLMAPIKey,
+		"BACKUP_PASSPHRASE":  &c.BackupPassphrase,
+	}
+	for name, value := range settings {
+		if !secrets.IsReference(*value) {
+			continue
+		}
+		resolved, err := c.Secrets.Resolve(ctx, *value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %v", name, err)
+		}
+		*value = resolved
+	}
+
+	return nil
+}
+
+// getDuration reads a duration setting such as "90s", "15m" or "24h".
+// Plain numbers, from before settings took durations, are read in unit;
+// with a unit of 0 they are rejected. Durations below minimum are rejected.
+func getDuration(key, defaultValue string, unit, minimum time.Duration) (time.Duration, error) {
+	value := getEnvWithDefault(key, defaultValue)
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		number, numberErr := strconv.Atoi(value)
+		switch {
+		case numberErr != nil:
+			return 0, fmt.Errorf("invalid %s: %q is not a duration such as 90s, 15m or 24h", key, value)
+		case unit == 0:
+			return 0, fmt.Errorf("invalid %s: %q needs a unit: use %s if it meant seconds or %s if it meant hours",
+				key, value, shortDuration(time.Duration(number)*time.Second), shortDuration(time.Duration(number)*time.Hour))
+		}
+		duration = time.Duration(number) * unit
+	}
+	if duration < minimum {
+		return 0, fmt.Errorf("invalid %s: must be at least %v", key, minimum)
+	}
+	return duration, nil
+}
+
+// shortDuration formats d without trailing zero units, as in 24h rather
+// than 24h0m0s.
+func shortDuration(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// parseUrgencyMarkers reads the comma-separated URGENCY_MARKERS, with the
+// defaults when unset and none when set to off.
+func parseUrgencyMarkers(value string) []string {
+	switch strings.TrimSpace(value) {
+	case "":
+		return severity.DefaultUrgencyMarkers
+	case "off":
+		return nil
+	}
+	var markers []string
+	for _, marker := range strings.Split(value, ",") {
+		if marker = strings.TrimSpace(marker); marker != "" {
+			markers = append(markers, marker)
+		}
+	}
+	return markers
+}
+
+// splitSinks splits SINKS into its semicolon-separated entries, dropping
+// empty ones.
+func splitSinks(value string) []string {
+	var sinks []string
+	for _, entry := range strings.Split(value, ";") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			sinks = append(sinks, entry)
+		}
+	}
+	return sinks
 }
 
 func getEnvWithDefault(key, defaultValue string) string {
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/severity"
+)
+
+func TestGetDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		unset   bool
+		unit    time.Duration
+		minimum time.Duration
+		want    time.Duration
+		wantErr string
+	}{
+		{name: "default", unset: true, unit: time.Second, want: time.Minute},
+		{name: "empty", value: "", unit: time.Second, wantErr: `invalid TEST_INTERVAL: "" is not a duration such as 90s, 15m or 24h`},
+		{name: "duration", value: "90s", unit: time.Second, want: 90 * time.Second},
+		{name: "hours", value: "24h", want: 24 * time.Hour},
+		{name: "compound", value: "1h30m", unit: time.Second, want: 90 * time.Minute},
+		{name: "legacy seconds", value: "90", unit: time.Second, want: 90 * time.Second},
+		{name: "legacy zero", value: "0", unit: time.Second, want: 0},
+		{name: "unit required", value: "86400", wantErr: `invalid TEST_INTERVAL: "86400" needs a unit: use 24h if it meant seconds or 86400h if it meant hours`},
+		{name: "unit required for hours", value: "24", wantErr: `invalid TEST_INTERVAL: "24" needs a unit: use 24s if it meant seconds or 24h if it meant hours`},
+		{name: "not a duration", value: "soon", unit: time.Second, wantErr: `invalid TEST_INTERVAL: "soon" is not a duration such as 90s, 15m or 24h`},
+		{name: "negative number", value: "-5", unit: time.Second, wantErr: "invalid TEST_INTERVAL: must be at least 0s"},
+		{name: "below minimum", value: "30s", unit: time.Second, minimum: time.Minute, wantErr: "invalid TEST_INTERVAL: must be at least 1m0s"},
+		{name: "legacy below minimum", value: "30", unit: time.Second, minimum: time.Minute, wantErr: "invalid TEST_INTERVAL: must be at least 1m0s"},
+		{name: "at minimum", value: "1m", minimum: time.Minute, want: time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_INTERVAL", tt.value)
+			if tt.unset {
+				os.Unsetenv("TEST_INTERVAL")
+			}
+			got, err := getDuration("TEST_INTERVAL", "60s", tt.unit, tt.minimum)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("getDuration = %v, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("getDuration = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		24 * time.Hour:   "24h",
+		90 * time.Minute: "1h30m",
+		time.Minute:      "1m",
+		90 * time.Second: "1m30s",
+		0:                "0s",
+	} {
+		if got := shortDuration(d); got != want {
+			t.Errorf("shortDuration(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestParseUrgencyMarkers(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", severity.DefaultUrgencyMarkers},
+		{" off ", nil},
+		{"sev1, p0 ,,customer down", []string{"sev1", "p0", "customer down"}},
+	}
+	for _, tt := range tests {
+		if got := parseUrgencyMarkers(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseUrgencyMarkers(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
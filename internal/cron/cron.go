@@ -0,0 +1,176 @@
+// Package cron parses five-field cron expressions, such as "* 8-19 * * 1-5"
+// for weekdays from 08:00 to 20:00, and matches times against them.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression. It matches every minute the
+// expression selects.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// With both day fields restricted, a day matches when either does,
+	// as in cron.
+	domAny, dowAny bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = []field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse parses an expression of minute, hour, day of month, month and day
+// of week. Fields are "*", numbers, ranges such as "1-5" and lists of
+// them, each optionally with a step such as "*/15". A step after a number,
+// as in "5/20", runs to the end of the field. Sunday is 0 or 7.
+func Parse(expression string) (Schedule, error) {
+	parts := strings.Fields(expression)
+	if len(parts) != len(fields) {
+		return Schedule{}, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expression, len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return Schedule{}, fmt.Errorf("invalid cron expression %q: %v", expression, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be given as 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return Schedule{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: parts[2] == "*",
+		dowAny: parts[4] == "*",
+	}, nil
+}
+
+func parseField(value string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(value, ",") {
+		step := 1
+		base, stepValue, hasStep := strings.Cut(item, "/")
+		if hasStep {
+			n, err := strconv.Atoi(stepValue)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s", stepValue, f.name)
+			}
+			item, step = base, n
+		}
+
+		low, high := f.min, f.max
+		if item != "*" {
+			lowValue, highValue, isRange := strings.Cut(item, "-")
+			var err error
+			if low, err = parseValue(lowValue, f); err != nil {
+				return 0, err
+			}
+			high = low
+			if hasStep {
+				high = f.max
+			}
+			if isRange {
+				if high, err = parseValue(highValue, f); err != nil {
+					return 0, err
+				}
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range %q in %s", item, f.name)
+			}
+		}
+
+		for i := low; i <= high; i += step {
+			set |= 1 << i
+		}
+	}
+	return set, nil
+}
+
+func parseValue(value string, f field) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s %q, expected %d-%d", f.name, value, f.min, f.max)
+	}
+	return n, nil
+}
+
+// Matches reports whether the minute of t is selected, in t's location.
+func (s Schedule) Matches(t time.Time) bool {
+	return s.month&(1<<int(t.Month())) != 0 &&
+		s.dayMatches(t) &&
+		s.hour&(1<<t.Hour()) != 0 &&
+		s.minute&(1<<t.Minute()) != 0
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first selected minute after t, in t's location, or the
+// zero time when none is selected within five years, e.g. for February 30.
+// Across daylight saving changes it follows the wall clock: minutes that
+// are skipped when clocks go forward are never selected, and those that
+// repeat when clocks go back are selected once, so a daily schedule runs
+// once a day.
+func (s Schedule) Next(t time.Time) time.Time {
+	location := t.Location()
+	start := wallClock(t)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	// time.Date may normalize a wall-clock time skipped by daylight
+	// saving to an earlier one, so never step back.
+	advance := func(next time.Time) time.Time {
+		if next.After(t) {
+			return next
+		}
+		return t.Add(time.Minute)
+	}
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = advance(time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location))
+		case !s.dayMatches(t):
+			t = advance(time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location))
+		case s.hour&(1<<t.Hour()) == 0:
+			t = advance(time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location))
+		case s.minute&(1<<t.Minute()) == 0 || !wallClock(t).After(start):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// wallClock returns the date and time t shows in its location, as a time
+// in UTC, so that times repeated by daylight saving compare equal.
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
@@ -0,0 +1,138 @@
+package cron
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"* * * *", "expected 5 fields, got 4"},
+		{"* * * * * *", "expected 5 fields, got 6"},
+		{"60 * * * *", `invalid minute "60", expected 0-59`},
+		{"* 24 * * *", `invalid hour "24", expected 0-23`},
+		{"* * 0 * *", `invalid day of month "0", expected 1-31`},
+		{"* * * 13 *", `invalid month "13", expected 1-12`},
+		{"* * * * 8", `invalid day of week "8", expected 0-7`},
+		{"* 17-9 * * *", `invalid range "17-9" in hour`},
+		{"*/0 * * * *", `invalid step "0" in minute`},
+		{"*/x * * * *", `invalid step "x" in minute`},
+		{"a * * * *", `invalid minute "a", expected 0-59`},
+		{"1- * * * *", `invalid minute "", expected 0-59`},
+		{"1,,2 * * * *", `invalid minute "", expected 0-59`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := Parse(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse(%q) = %v, want an error containing %q", tt.expression, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	// 2024-06-03 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.June, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name       string
+		expression string
+		t          time.Time
+		want       bool
+	}{
+		{"every minute", "* * * * *", at(3, 12, 34), true},
+		{"range start", "* 8-19 * * 1-5", at(3, 8, 0), true},
+		{"range end", "* 8-19 * * 1-5", at(3, 19, 59), true},
+		{"after range", "* 8-19 * * 1-5", at(3, 20, 0), false},
+		{"weekend", "* 8-19 * * 1-5", at(8, 12, 0), false},
+		{"step", "*/15 * * * *", at(3, 12, 45), true},
+		{"off step", "*/15 * * * *", at(3, 12, 50), false},
+		{"range step", "10-40/10 * * * *", at(3, 12, 30), true},
+		{"range step beyond end", "10-40/10 * * * *", at(3, 12, 50), false},
+		{"value step", "5/20 * * * *", at(3, 12, 45), true},
+		{"value step of one", "58/1 * * * *", at(3, 12, 59), true},
+		{"list", "0,30 9,17 * * *", at(3, 17, 30), true},
+		{"not in list", "0,30 9,17 * * *", at(3, 12, 30), false},
+		{"sunday as 0", "* * * * 0", at(9, 12, 0), true},
+		{"sunday as 7", "* * * * 7", at(9, 12, 0), true},
+		{"month", "* * * 6 *", at(3, 0, 0), true},
+		{"other month", "* * * 1-5 *", at(3, 0, 0), false},
+		// With both day fields restricted either may match, as in cron.
+		{"day of month or week, by day of month", "0 9 15 * 1", at(15, 9, 0), true},
+		{"day of month or week, by day of week", "0 9 15 * 1", at(10, 9, 0), true},
+		{"day of month or week, neither", "0 9 15 * 1", at(11, 9, 0), false},
+		// With one of them *, the other alone decides.
+		{"day of month only", "0 9 15 * *", at(10, 9, 0), false},
+		{"day of week only", "0 9 * * 1", at(15, 9, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Matches(tt.t); got != tt.want {
+				t.Errorf("Parse(%q).Matches(%v) = %v, want %v", tt.expression, tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2024, month, day, hour, minute, 0, 0, newYork)
+	}
+	// Clocks went back from 02:00 EDT to 01:00 EST on 2024-11-03.
+	firstOneThirty := time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC).In(newYork)
+
+	tests := []struct {
+		name       string
+		expression string
+		t          time.Time
+		want       time.Time
+	}{
+		{"next minute", "* * * * *", utc(time.June, 3, 12, 34).Add(30 * time.Second), utc(time.June, 3, 12, 35)},
+		{"strictly after", "30 9 * * *", utc(time.June, 3, 9, 30), utc(time.June, 4, 9, 30)},
+		{"later today", "30 9 * * *", utc(time.June, 3, 8, 0), utc(time.June, 3, 9, 30)},
+		{"next step", "*/15 * * * *", utc(time.June, 3, 12, 46), utc(time.June, 3, 13, 0)},
+		{"next weekday", "0 9 * * 1-5", utc(time.June, 7, 10, 0), utc(time.June, 10, 9, 0)},
+		{"day of month or week", "0 9 15 * 1", utc(time.June, 11, 0, 0), utc(time.June, 15, 9, 0)},
+		{"next month", "0 0 1 * *", utc(time.June, 3, 0, 0), utc(time.July, 1, 0, 0)},
+		{"next year", "0 0 1 1 *", utc(time.June, 3, 0, 0), time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"leap day", "0 0 29 2 *", utc(time.March, 1, 0, 0), time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"never", "0 0 30 2 *", utc(time.June, 3, 0, 0), time.Time{}},
+		{"in location", "0 9 * * *", local(time.June, 3, 10, 0), local(time.June, 4, 9, 0)},
+		// Clocks went forward from 02:00 EST to 03:00 EDT on 2024-03-10.
+		{"skipped by daylight saving", "30 2 * * *", local(time.March, 10, 0, 0), local(time.March, 11, 2, 30)},
+		{"hour skipped by daylight saving", "* 2 * * *", local(time.March, 10, 0, 0), local(time.March, 11, 2, 0)},
+		{"across daylight saving", "0 9 * * *", local(time.March, 9, 9, 0), local(time.March, 10, 9, 0)},
+		{"repeated by daylight saving", "30 1 * * *", local(time.November, 3, 0, 0), firstOneThirty},
+		{"repeated hour runs once", "30 1 * * *", firstOneThirty, local(time.November, 4, 1, 30)},
+		{"repeated hour steps run once", "*/30 1 * * *", firstOneThirty, local(time.November, 4, 1, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tt.t); !got.Equal(tt.want) {
+				t.Errorf("Parse(%q).Next(%v) = %v, want %v", tt.expression, tt.t, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package deps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	EcosystemGo  = "go"
+	EcosystemNPM = "npm"
+)
+
+// Manifests lists the manifest files looked up in a watched repository.
+var Manifests = []string{"go.mod", "package.json"}
+
+type Dependency struct {
+	Ecosystem string
+	Name      string
+	Version   string
+	// Pinned is whether the manifest requires exactly Version rather than
+	// a range starting at it.
+	Pinned bool
+}
+
+// Key identifies a released version of the dependency.
+func (d Dependency) Key(version string) string {
+	return fmt.Sprintf("%s:%s@%s", d.Ecosystem, d.Name, version)
+}
+
+func ParseManifest(path string, data []byte) ([]Dependency, error) {
+	switch path {
+	case "go.mod":
+		return ParseGoMod(data)
+	case "package.json":
+		return ParsePackageJSON(data)
+	default:
+		return nil, fmt.Errorf("unsupported manifest %q", path)
+	}
+}
+
+// ParseGoMod returns the direct requirements of a go.mod file.
+func ParseGoMod(data []byte) ([]Dependency, error) {
+	file, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go.mod: %v", err)
+	}
+
+	var dependencies []Dependency
+	for _, require := range file.Require {
+		if require.Indirect {
+			continue
+		}
+		dependencies = append(dependencies, Dependency{
+			Ecosystem: EcosystemGo,
+			Name:      require.Mod.Path,
+			Version:   require.Mod.Version,
+			Pinned:    true,
+		})
+	}
+
+	return dependencies, nil
+}
+
+// ParsePackageJSON returns the dependencies and devDependencies of a
+// package.json file. Range operators are stripped so "^1.2.3" compares as
+// 1.2.3; non-version specs such as git URLs or workspaces are skipped.
+func ParsePackageJSON(data []byte) ([]Dependency, error) {
+	var manifest struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %v", err)
+	}
+
+	var dependencies []Dependency
+	for _, specs := range []map[string]string{manifest.Dependencies, manifest.DevDependencies} {
+		for name, spec := range specs {
+			spec = strings.TrimSpace(spec)
+			version := strings.TrimLeft(spec, "^~=v")
+			if version == "" || !isDigit(version[0]) || strings.ContainsAny(version, " |<>*x") {
+				continue
+			}
+			dependencies = append(dependencies, Dependency{
+				Ecosystem: EcosystemNPM,
+				Name:      name,
+				Version:   version,
+				Pinned:    !strings.ContainsAny(spec, "^~"),
+			})
+		}
+	}
+	sort.Slice(dependencies, func(i, j int) bool { return dependencies[i].Name < dependencies[j].Name })
+
+	return dependencies, nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// FetchDependencies reads and parses every known manifest of an owner/name
+// repository. Missing manifests are skipped.
+func FetchDependencies(ctx context.Context, client github.API, repo string) ([]Dependency, error) {
+	owner, name, ok := strings.Cut(repo, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid repository %q, expected owner/name", repo)
+	}
+
+	var dependencies []Dependency
+	found := false
+	for _, path := range Manifests {
+		data, err := client.GetFileContent(ctx, owner, name, path)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		found = true
+
+		parsed, err := ParseManifest(path, data)
+		if err != nil {
+			return nil, err
+		}
+		dependencies = append(dependencies, parsed...)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no supported manifest (%s) found in %s", strings.Join(Manifests, ", "), repo)
+	}
+	return dependencies, nil
+}
@@ -0,0 +1,75 @@
+package deps
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readManifest returns the manifest file of a case in testdata.
+func readManifest(t *testing.T, name, manifest string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name, manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		manifest string
+		want     []Dependency
+	}{
+		// Indirect requirements are skipped.
+		{"go.mod", []Dependency{
+			{Ecosystem: EcosystemGo, Name: "github.com/google/uuid", Version: "v1.6.0", Pinned: true},
+			{Ecosystem: EcosystemGo, Name: "golang.org/x/mod", Version: "v0.17.0", Pinned: true},
+			{Ecosystem: EcosystemGo, Name: "github.com/lib/pq", Version: "v1.10.9", Pinned: true},
+		}},
+		// Git URLs, workspaces and compound ranges are skipped.
+		{"package.json", []Dependency{
+			{Ecosystem: EcosystemNPM, Name: "lodash", Version: "4.17.21", Pinned: true},
+			{Ecosystem: EcosystemNPM, Name: "prettier", Version: "3.2.5", Pinned: true},
+			{Ecosystem: EcosystemNPM, Name: "react", Version: "18.2.0"},
+			{Ecosystem: EcosystemNPM, Name: "typescript", Version: "5.4.5"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.manifest, func(t *testing.T) {
+			got, err := ParseManifest(tt.manifest, readManifest(t, "valid", tt.manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseManifest = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMalformedManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{"unclosed-require", "go.mod", "failed to parse go.mod: go.mod:5: syntax error (unterminated block started at go.mod:3:1)"},
+		{"bad-version", "go.mod", `failed to parse go.mod: go.mod:3: require github.com/google/uuid: version "latest" invalid: must be of the form v1.2.3`},
+		{"truncated", "package.json", "failed to parse package.json: unexpected end of JSON input"},
+		{"dependencies-list", "package.json", "failed to parse package.json: json: cannot unmarshal array into Go struct field .dependencies of type map[string]string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseManifest(tt.manifest, readManifest(t, tt.name, tt.manifest))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ParseManifest = %+v, %v, want error %q", got, err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseManifest("Cargo.toml", nil); err == nil || err.Error() != `unsupported manifest "Cargo.toml"` {
+		t.Errorf("ParseManifest(Cargo.toml) = %v, want an unsupported manifest error", err)
+	}
+}
@@ -0,0 +1,82 @@
+package deps
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+)
+
+type Update struct {
+	Dependency
+	Latest string
+}
+
+// Updates resolves the dependencies that have a newer upstream release.
+// Lookup failures are logged and skipped so one unknown package does not
+// hide updates for the rest of the manifest.
+func (c *Client) Updates(ctx context.Context, dependencies []Dependency) []Update {
+	var updates []Update
+	for _, dependency := range dependencies {
+		latest, err := c.LatestVersion(ctx, dependency)
+		if err != nil {
+			log.Printf("Error resolving latest version of %s: %v", dependency.Name, err)
+			continue
+		}
+		if IsNewer(dependency.Version, latest) {
+			updates = append(updates, Update{Dependency: dependency, Latest: latest})
+		}
+	}
+	return updates
+}
+
+// GetNotifications returns a notification for every update that has not
+// been announced for the watched repository yet, along with its seen key.
+func GetNotifications(repo string, updates []Update, seen map[string]bool) ([]models.Notification, []string) {
+	var notifications []models.Notification
+	var keys []string
+	for _, update := range updates {
+		key := update.Key(update.Latest)
+		if seen[key] {
+			continue
+		}
+
+		notification := models.Notification{
+			Type:   "dependency_release",
+			Repo:   repo,
+			Fields: models.Fields{Title: update.Name, Version: update.Latest, Previous: update.Version},
+			URL:    ReleaseURL(update.Dependency, update.Latest),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+		keys = append(keys, key)
+	}
+	return notifications, keys
+}
+
+// GetVulnerabilityNotifications returns a notification for every
+// vulnerability found in the watched repository's dependencies, along with
+// its seen key.
+func GetVulnerabilityNotifications(repo string, vulnerabilities []Vulnerability) ([]models.Notification, []string) {
+	var notifications []models.Notification
+	var keys []string
+	for _, vulnerability := range vulnerabilities {
+		id := vulnerability.ID
+		if len(vulnerability.Aliases) > 0 {
+			id += " (" + strings.Join(vulnerability.Aliases, ", ") + ")"
+		}
+
+		notification := models.Notification{
+			Type:   "dependency_vulnerability",
+			Repo:   repo,
+			Fields: models.Fields{Title: vulnerability.Name, Version: vulnerability.Version, Advisory: id, Details: vulnerability.Summary},
+			URL:    vulnerability.URL(),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+		keys = append(keys, vulnerability.Key())
+	}
+	return notifications, keys
+}
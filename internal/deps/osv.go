@@ -0,0 +1,133 @@
+package deps
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// osvURL is the API of the OSV vulnerability database.
+const osvURL = "https://api.osv.dev/v1"
+
+// osvBatchSize is the most queries OSV answers in one batch.
+const osvBatchSize = 1000
+
+// osvEcosystems maps ecosystems to their names in OSV.
+var osvEcosystems = map[string]string{
+	EcosystemGo:  "Go",
+	EcosystemNPM: "npm",
+}
+
+// Vulnerability is a published vulnerability affecting the version of a
+// dependency.
+type Vulnerability struct {
+	Dependency
+	// ID is the OSV identifier, such as GHSA-xxxx-xxxx-xxxx or GO-2024-1234.
+	ID string
+	// Aliases are the other identifiers of the vulnerability, such as its
+	// CVE.
+	Aliases []string
+	Summary string
+}
+
+// Key identifies the vulnerability in the dependency, so it is reported
+// once however often the dependency is bumped to another affected version.
+func (v Vulnerability) Key() string {
+	return fmt.Sprintf("vuln:%s:%s:%s", v.ID, v.Ecosystem, v.Name)
+}
+
+// URL links to the vulnerability's page on osv.dev.
+func (v Vulnerability) URL() string {
+	return "https://osv.dev/vulnerability/" + url.PathEscape(v.ID)
+}
+
+// Vulnerabilities looks the pinned dependencies up in OSV and returns the
+// vulnerabilities affecting their versions, except those whose keys were
+// seen already. Dependencies required by range are skipped, as the version
+// actually installed is not known. Details that cannot be fetched are
+// logged and left out of the vulnerability.
+func (c *Client) Vulnerabilities(ctx context.Context, dependencies []Dependency, seen map[string]bool) ([]Vulnerability, error) {
+	type osvQuery struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+
+	var pinned []Dependency
+	var queries []osvQuery
+	for _, dependency := range dependencies {
+		ecosystem, ok := osvEcosystems[dependency.Ecosystem]
+		if !ok || !dependency.Pinned {
+			continue
+		}
+		var query osvQuery
+		query.Package.Name = dependency.Name
+		query.Package.Ecosystem = ecosystem
+		// OSV lists Go versions without the v prefix.
+		query.Version = strings.TrimSuffix(strings.TrimPrefix(dependency.Version, "v"), "+incompatible")
+		pinned = append(pinned, dependency)
+		queries = append(queries, query)
+	}
+
+	var vulnerabilities []Vulnerability
+	for start := 0; start < len(queries); start += osvBatchSize {
+		end := min(start+osvBatchSize, len(queries))
+		var response struct {
+			Results []struct {
+				Vulns []struct {
+					ID string `json:"id"`
+				} `json:"vulns"`
+			} `json:"results"`
+		}
+		body := map[string]interface{}{"queries": queries[start:end]}
+		if err := c.postJSON(ctx, osvURL+"/querybatch", body, &response); err != nil {
+			return nil, fmt.Errorf("failed to query OSV: %v", err)
+		}
+
+		for i, result := range response.Results {
+			if start+i >= end {
+				break
+			}
+			for _, found := range result.Vulns {
+				vulnerability := Vulnerability{Dependency: pinned[start+i], ID: found.ID}
+				if seen[vulnerability.Key()] {
+					continue
+				}
+				withdrawn, err := c.describe(ctx, &vulnerability)
+				if err != nil {
+					log.Printf("Error getting details of %s: %v", found.ID, err)
+				}
+				if !withdrawn {
+					vulnerabilities = append(vulnerabilities, vulnerability)
+				}
+			}
+		}
+	}
+	return vulnerabilities, nil
+}
+
+// describe fills in the summary and aliases of the vulnerability, and
+// reports whether it was withdrawn.
+func (c *Client) describe(ctx context.Context, vulnerability *Vulnerability) (bool, error) {
+	var details struct {
+		Summary   string   `json:"summary"`
+		Details   string   `json:"details"`
+		Aliases   []string `json:"aliases"`
+		Withdrawn string   `json:"withdrawn"`
+	}
+	if err := c.getJSON(ctx, osvURL+"/vulns/"+url.PathEscape(vulnerability.ID), &details); err != nil {
+		return false, err
+	}
+	vulnerability.Aliases = details.Aliases
+	vulnerability.Summary = details.Summary
+	if vulnerability.Summary == "" {
+		// Some databases only provide details, whose first line usually
+		// reads as a summary.
+		vulnerability.Summary, _, _ = strings.Cut(strings.TrimSpace(details.Details), "\n")
+	}
+	return details.Withdrawn != "", nil
+}
@@ -0,0 +1,170 @@
+package deps
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeOSV answers batch queries with the vulnerabilities in its map, keyed
+// by package name and version, and details with those in vulns.
+type fakeOSV struct {
+	mu       sync.Mutex
+	affected map[string][]string
+	vulns    map[string]string
+	status   int
+	queries  []string
+}
+
+func (o *fakeOSV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/querybatch" {
+		var request struct {
+			Queries []struct {
+				Package struct {
+					Name      string `json:"name"`
+					Ecosystem string `json:"ecosystem"`
+				} `json:"package"`
+				Version string `json:"version"`
+			} `json:"queries"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		type vuln struct {
+			ID string `json:"id"`
+		}
+		type result struct {
+			Vulns []vuln `json:"vulns,omitempty"`
+		}
+		var response struct {
+			Results []result `json:"results"`
+		}
+		for _, query := range request.Queries {
+			key := query.Package.Ecosystem + ":" + query.Package.Name + "@" + query.Version
+			o.queries = append(o.queries, key)
+			var found result
+			for _, id := range o.affected[key] {
+				found.Vulns = append(found.Vulns, vuln{ID: id})
+			}
+			response.Results = append(response.Results, found)
+		}
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	details, ok := o.vulns[strings.TrimPrefix(r.URL.Path, "/v1/vulns/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write([]byte(details))
+}
+
+// redirectTransport sends every request to the test server instead.
+type redirectTransport struct {
+	target *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func newOSVClient(t *testing.T, osv *fakeOSV) *Client {
+	t.Helper()
+	server := httptest.NewServer(osv)
+	t.Cleanup(server.Close)
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Client{http: &http.Client{Transport: redirectTransport{target}}}
+}
+
+func TestVulnerabilities(t *testing.T) {
+	osv := &fakeOSV{
+		affected: map[string][]string{
+			"Go:golang.org/x/net@0.17.0": {"GO-2024-2687", "GO-2023-2102"},
+			"npm:lodash@4.17.20":         {"GHSA-35jh-r3h4-6jhm", "GHSA-withdrawn"},
+			"npm:minimist@1.2.5":         {"GHSA-missing"},
+		},
+		vulns: map[string]string{
+			"GO-2024-2687":        `{"id": "GO-2024-2687", "details": "\nHTTP/2 CONTINUATION flood in net/http\n\nMore details.", "aliases": ["CVE-2023-45288"]}`,
+			"GHSA-35jh-r3h4-6jhm": `{"id": "GHSA-35jh-r3h4-6jhm", "summary": "Command Injection in lodash", "aliases": ["CVE-2021-23337"]}`,
+			"GHSA-withdrawn":      `{"id": "GHSA-withdrawn", "summary": "Not a vulnerability", "withdrawn": "2024-01-01T00:00:00Z"}`,
+		},
+	}
+	client := newOSVClient(t, osv)
+	golangNet := Dependency{Ecosystem: EcosystemGo, Name: "golang.org/x/net", Version: "v0.17.0", Pinned: true}
+	lodash := Dependency{Ecosystem: EcosystemNPM, Name: "lodash", Version: "4.17.20", Pinned: true}
+	minimist := Dependency{Ecosystem: EcosystemNPM, Name: "minimist", Version: "1.2.5", Pinned: true}
+	dependencies := []Dependency{
+		golangNet,
+		lodash,
+		minimist,
+		{Ecosystem: EcosystemNPM, Name: "react", Version: "18.2.0"},
+		{Ecosystem: "cargo", Name: "serde", Version: "1.0.0", Pinned: true},
+	}
+	seen := map[string]bool{Vulnerability{Dependency: golangNet, ID: "GO-2023-2102"}.Key(): true}
+
+	got, err := client.Vulnerabilities(context.Background(), dependencies, seen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Vulnerability{
+		{Dependency: golangNet, ID: "GO-2024-2687", Aliases: []string{"CVE-2023-45288"}, Summary: "HTTP/2 CONTINUATION flood in net/http"},
+		{Dependency: lodash, ID: "GHSA-35jh-r3h4-6jhm", Aliases: []string{"CVE-2021-23337"}, Summary: "Command Injection in lodash"},
+		// Details that cannot be fetched are left out.
+		{Dependency: minimist, ID: "GHSA-missing"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Vulnerabilities = %+v, want %+v", got, want)
+	}
+	// Dependencies required by range and of other ecosystems are not
+	// looked up.
+	if wantQueries := []string{"Go:golang.org/x/net@0.17.0", "npm:lodash@4.17.20", "npm:minimist@1.2.5"}; !reflect.DeepEqual(osv.queries, wantQueries) {
+		t.Errorf("queried %v, want %v", osv.queries, wantQueries)
+	}
+}
+
+func TestVulnerabilitiesNone(t *testing.T) {
+	osv := &fakeOSV{}
+	client := newOSVClient(t, osv)
+	ctx := context.Background()
+
+	got, err := client.Vulnerabilities(ctx, []Dependency{{Ecosystem: EcosystemNPM, Name: "react", Version: "18.2.0"}}, nil)
+	if err != nil || got != nil || osv.queries != nil {
+		t.Errorf("Vulnerabilities of unpinned dependencies = %+v, %v after queries %v, want no query", got, err, osv.queries)
+	}
+
+	got, err = client.Vulnerabilities(ctx, []Dependency{{Ecosystem: EcosystemNPM, Name: "react", Version: "18.2.0", Pinned: true}}, nil)
+	if err != nil || got != nil {
+		t.Errorf("Vulnerabilities without results = %+v, %v, want none", got, err)
+	}
+}
+
+func TestVulnerabilitiesError(t *testing.T) {
+	client := newOSVClient(t, &fakeOSV{status: http.StatusServiceUnavailable})
+	dependencies := []Dependency{{Ecosystem: EcosystemNPM, Name: "lodash", Version: "4.17.20", Pinned: true}}
+
+	_, err := client.Vulnerabilities(context.Background(), dependencies, nil)
+	if want := "failed to query OSV: api.osv.dev returned 503 Service Unavailable"; err == nil || err.Error() != want {
+		t.Errorf("Vulnerabilities = %v, want error %q", err, want)
+	}
+}
@@ -0,0 +1,3 @@
+module example.com/app
+
+require github.com/google/uuid latest
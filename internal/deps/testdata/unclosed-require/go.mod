@@ -0,0 +1,4 @@
+module example.com/app
+
+require (
+	github.com/google/uuid v1.6.0
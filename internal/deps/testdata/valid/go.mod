@@ -0,0 +1,15 @@
+module example.com/app
+
+go 1.22
+
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/mod v0.17.0
+	golang.org/x/sys v0.20.0 // indirect
+)
+
+require github.com/lib/pq v1.10.9
+
+replace github.com/lib/pq => ../pq
+
+toolchain go1.22.3
@@ -0,0 +1,116 @@
+package deps
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+type Client struct {
+	http *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// LatestVersion returns the newest stable version published upstream.
+func (c *Client) LatestVersion(ctx context.Context, dependency Dependency) (string, error) {
+	switch dependency.Ecosystem {
+	case EcosystemGo:
+		escaped, err := module.EscapePath(dependency.Name)
+		if err != nil {
+			return "", fmt.Errorf("invalid module path %q: %v", dependency.Name, err)
+		}
+		var info struct {
+			Version string `json:"Version"`
+		}
+		if err := c.getJSON(ctx, fmt.Sprintf("https://proxy.golang.org/%s/@latest", escaped), &info); err != nil {
+			return "", err
+		}
+		return info.Version, nil
+	case EcosystemNPM:
+		var info struct {
+			Version string `json:"version"`
+		}
+		if err := c.getJSON(ctx, fmt.Sprintf("https://registry.npmjs.org/%s/latest", url.PathEscape(dependency.Name)), &info); err != nil {
+			return "", err
+		}
+		return info.Version, nil
+	default:
+		return "", fmt.Errorf("unsupported ecosystem %q", dependency.Ecosystem)
+	}
+}
+
+// IsNewer reports whether latest is a stable release above current.
+func IsNewer(current, latest string) bool {
+	current, latest = canonical(current), canonical(latest)
+	if !semver.IsValid(current) || !semver.IsValid(latest) || semver.Prerelease(latest) != "" {
+		return false
+	}
+	return semver.Compare(latest, current) > 0
+}
+
+// ReleaseURL links to the package page of the given version.
+func ReleaseURL(dependency Dependency, version string) string {
+	if dependency.Ecosystem == EcosystemNPM {
+		return fmt.Sprintf("https://www.npmjs.com/package/%s/v/%s", dependency.Name, version)
+	}
+	return fmt.Sprintf("https://pkg.go.dev/%s@%s", dependency.Name, version)
+}
+
+func canonical(version string) string {
+	version = strings.TrimSuffix(version, "+incompatible")
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	return version
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	return c.do(req, v)
+}
+
+func (c *Client) postJSON(ctx context.Context, endpoint string, body, v interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, v)
+}
+
+func (c *Client) do(req *http.Request, v interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to query %s: %v", req.URL.Host, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", req.URL.Host, resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %v", err)
+	}
+	return nil
+}
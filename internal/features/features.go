@@ -0,0 +1,46 @@
+package features
+
+import (
+	"context"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// Set is a snapshot of the feature flags in the store.
+type Set map[string]models.FeatureFlag
+
+// Load reads every feature flag from the store.
+func Load(ctx context.Context, s store.Store) (Set, error) {
+	flags, err := s.GetFeatureFlags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(Set, len(flags))
+	for _, flag := range flags {
+		set[flag.Name] = flag
+	}
+	return set, nil
+}
+
+// Enabled reports whether a new feature is on for the chat. Features
+// without a flag are off, so code can check a flag before it exists.
+func (s Set) Enabled(name string, chatID int64) bool {
+	flag, ok := s[name]
+	return ok && flag.EnabledFor(chatID)
+}
+
+// Allowed reports whether an existing feature is on for the chat.
+// Features without a flag are on; adding a flag restricts them to the
+// chats it is enabled for.
+func (s Set) Allowed(name string, chatID int64) bool {
+	flag, ok := s[name]
+	return !ok || flag.EnabledFor(chatID)
+}
+
+// NotificationFlag is the name of the flag that gates notifications of
+// the given type, e.g. "notification.gerrit_label".
+func NotificationFlag(notificationType string) string {
+	return "notification." + notificationType
+}
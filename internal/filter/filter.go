@@ -0,0 +1,424 @@
+// Package filter parses small boolean expressions over named fields, such
+// as
+//
+//	notification.Repo startsWith "acme/" && !notification.Author.IsBot
+//
+// and evaluates them against values looked up by field name. Expressions
+// are type checked when parsed, so evaluating one cannot fail.
+//
+// Values are strings, booleans and lists of strings. Expressions combine
+// them with ! && || and parentheses, and compare them with:
+//
+//	a == b, a != b      equal strings or booleans
+//	a contains b        a string containing b, or a list with the element b
+//	a startsWith b      a string starting with b
+//	a endsWith b        a string ending with b
+//	a matches "re"      a string matching the regular expression
+//	a in ["x", "y"]     a string that is an element of the list
+//
+// Strings are double-quoted with Go's escapes.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Kind is the type of a field or value.
+type Kind int
+
+const (
+	String Kind = iota
+	Bool
+	List
+)
+
+func (k Kind) String() string {
+	switch k {
+	case String:
+		return "string"
+	case Bool:
+		return "bool"
+	default:
+		return "list"
+	}
+}
+
+// Expr is a parsed expression.
+type Expr struct {
+	source string
+	root   node
+}
+
+// Parse parses the expression, accepting the fields with the given kinds.
+// The expression must be a boolean.
+func Parse(source string, fields map[string]Kind) (*Expr, error) {
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", source, err)
+	}
+	p := &parser{tokens: tokens, fields: fields}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %s", p.peek())
+	}
+	if err == nil && root.kind() != Bool {
+		err = fmt.Errorf("the filter is a %s, expected a condition", root.kind())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter %q: %v", source, err)
+	}
+	return &Expr{source: source, root: root}, nil
+}
+
+// Match evaluates the expression with field values from lookup, which
+// must return a string, bool or []string according to the field's kind.
+func (e *Expr) Match(lookup func(field string) any) bool {
+	return e.root.eval(lookup).(bool)
+}
+
+func (e *Expr) String() string {
+	return e.source
+}
+
+type node interface {
+	kind() Kind
+	eval(lookup func(string) any) any
+}
+
+type literal struct {
+	k     Kind
+	value any
+}
+
+func (l literal) kind() Kind                { return l.k }
+func (l literal) eval(func(string) any) any { return l.value }
+
+type field struct {
+	k    Kind
+	name string
+}
+
+func (f field) kind() Kind { return f.k }
+
+func (f field) eval(lookup func(string) any) any {
+	value := lookup(f.name)
+	// A lookup that does not know the field gets the zero value, so a
+	// missing value never panics.
+	switch f.k {
+	case String:
+		s, _ := value.(string)
+		return s
+	case Bool:
+		b, _ := value.(bool)
+		return b
+	default:
+		l, _ := value.([]string)
+		return l
+	}
+}
+
+type not struct {
+	x node
+}
+
+func (n not) kind() Kind                       { return Bool }
+func (n not) eval(lookup func(string) any) any { return !n.x.eval(lookup).(bool) }
+
+type logical struct {
+	and  bool
+	x, y node
+}
+
+func (l logical) kind() Kind { return Bool }
+
+func (l logical) eval(lookup func(string) any) any {
+	x := l.x.eval(lookup).(bool)
+	if l.and {
+		return x && l.y.eval(lookup).(bool)
+	}
+	return x || l.y.eval(lookup).(bool)
+}
+
+type comparison struct {
+	op   string
+	x, y node
+}
+
+func (c comparison) kind() Kind { return Bool }
+
+func (c comparison) eval(lookup func(string) any) any {
+	x, y := c.x.eval(lookup), c.y.eval(lookup)
+	switch c.op {
+	case "==":
+		return x == y
+	case "!=":
+		return x != y
+	case "contains":
+		if list, ok := x.([]string); ok {
+			return slices.Contains(list, y.(string))
+		}
+		return strings.Contains(x.(string), y.(string))
+	case "startsWith":
+		return strings.HasPrefix(x.(string), y.(string))
+	case "endsWith":
+		return strings.HasSuffix(x.(string), y.(string))
+	default: // in
+		return slices.Contains(y.([]string), x.(string))
+	}
+}
+
+type match struct {
+	x  node
+	re *regexp.Regexp
+}
+
+func (m match) kind() Kind                       { return Bool }
+func (m match) eval(lookup func(string) any) any { return m.re.MatchString(m.x.eval(lookup).(string)) }
+
+type parser struct {
+	tokens []token
+	pos    int
+	fields map[string]Kind
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.peek().is(tokenOperator, "||") {
+		p.next()
+		var y node
+		if y, err = p.parseAnd(); err == nil {
+			x, err = logicalOf(false, x, y)
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseAnd() (node, error) {
+	x, err := p.parseUnary()
+	for err == nil && p.peek().is(tokenOperator, "&&") {
+		p.next()
+		var y node
+		if y, err = p.parseUnary(); err == nil {
+			x, err = logicalOf(true, x, y)
+		}
+	}
+	return x, err
+}
+
+func logicalOf(and bool, x, y node) (node, error) {
+	op := "||"
+	if and {
+		op = "&&"
+	}
+	if x.kind() != Bool || y.kind() != Bool {
+		return nil, fmt.Errorf("%s needs conditions, got a %s and a %s", op, x.kind(), y.kind())
+	}
+	return logical{and: and, x: x, y: y}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().is(tokenOperator, "!") {
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != Bool {
+			return nil, fmt.Errorf("! needs a condition, got a %s", x.kind())
+		}
+		return not{x: x}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	x, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind != tokenOperator && t.kind != tokenIdent {
+		return x, nil
+	}
+	op := t.text
+	switch op {
+	case "==", "!=", "contains", "startsWith", "endsWith", "matches", "in":
+	default:
+		if t.kind == tokenIdent {
+			return nil, fmt.Errorf("unknown operator %q", op)
+		}
+		return x, nil
+	}
+	p.next()
+
+	y, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	want := func(xKind, yKind Kind) error {
+		if x.kind() != xKind || y.kind() != yKind {
+			return fmt.Errorf("%s needs a %s and a %s, got a %s and a %s", op, xKind, yKind, x.kind(), y.kind())
+		}
+		return nil
+	}
+
+	switch op {
+	case "==", "!=":
+		if x.kind() != y.kind() || x.kind() == List {
+			return nil, fmt.Errorf("%s needs two strings or two bools, got a %s and a %s", op, x.kind(), y.kind())
+		}
+	case "contains":
+		if x.kind() == List {
+			err = want(List, String)
+		} else {
+			err = want(String, String)
+		}
+	case "startsWith", "endsWith":
+		err = want(String, String)
+	case "in":
+		err = want(String, List)
+	case "matches":
+		lit, ok := y.(literal)
+		if !ok || lit.k != String || x.kind() != String {
+			return nil, fmt.Errorf("matches needs a string and a quoted regular expression")
+		}
+		re, err := regexp.Compile(lit.value.(string))
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression %q: %v", lit.value, err)
+		}
+		return match{x: x, re: re}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return comparison{op: op, x: x, y: y}, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokenString:
+		return literal{k: String, value: t.text}, nil
+	case t.is(tokenIdent, "true"), t.is(tokenIdent, "false"):
+		return literal{k: Bool, value: t.text == "true"}, nil
+	case t.kind == tokenIdent:
+		k, ok := p.fields[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown field %q", t.text)
+		}
+		return field{k: k, name: t.text}, nil
+	case t.is(tokenOperator, "("):
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); !t.is(tokenOperator, ")") {
+			return nil, fmt.Errorf("expected ), got %s", t)
+		}
+		return x, nil
+	case t.is(tokenOperator, "["):
+		list := []string{}
+		for !p.peek().is(tokenOperator, "]") {
+			if len(list) > 0 {
+				if t := p.next(); !t.is(tokenOperator, ",") {
+					return nil, fmt.Errorf("expected , or ], got %s", t)
+				}
+			}
+			t := p.next()
+			if t.kind != tokenString {
+				return nil, fmt.Errorf("lists hold quoted strings, got %s", t)
+			}
+			list = append(list, t.text)
+		}
+		p.next()
+		return literal{k: List, value: list}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) is(kind tokenKind, text string) bool {
+	return t.kind == kind && t.text == text
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			quoted, err := strconv.QuotedPrefix(source[i:])
+			if err != nil {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			text, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token{tokenString, text})
+			i += len(quoted)
+		case strings.HasPrefix(source[i:], "&&"), strings.HasPrefix(source[i:], "||"),
+			strings.HasPrefix(source[i:], "=="), strings.HasPrefix(source[i:], "!="):
+			tokens = append(tokens, token{tokenOperator, source[i : i+2]})
+			i += 2
+		case strings.ContainsRune("!()[],", rune(c)):
+			tokens = append(tokens, token{tokenOperator, string(c)})
+			i++
+		case isIdent(rune(c)):
+			start := i
+			for i < len(source) && (isIdent(rune(source[i])) || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenIdent, source[start:i]})
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
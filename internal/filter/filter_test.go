@@ -0,0 +1,123 @@
+package filter
+
+import "testing"
+
+var testFields = map[string]Kind{
+	"repo":   String,
+	"type":   String,
+	"bot":    Bool,
+	"labels": List,
+}
+
+func testLookup(field string) any {
+	switch field {
+	case "repo":
+		return "acme/api"
+	case "type":
+		return "mention"
+	case "bot":
+		return true
+	case "labels":
+		return []string{"bug", "p1"}
+	}
+	return nil
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{`repo == "acme/api"`, true},
+		{`repo != "acme/api"`, false},
+		{`bot == true`, true},
+		{`bot`, true},
+		{`repo startsWith "acme/"`, true},
+		{`repo endsWith "/web"`, false},
+		{`repo contains "me/a"`, true},
+		{`labels contains "bug"`, true},
+		{`labels contains "bu"`, false},
+		{`type in ["mention", "team_mention"]`, true},
+		{`type in []`, false},
+		{`repo matches "^acme/(api|web)$"`, true},
+
+		// && binds tighter than ||, so this is true || (false && false).
+		{`bot || type == "issue" && repo == "other"`, true},
+		{`(bot || type == "issue") && repo == "other"`, false},
+		{`repo == "other" && bot || type == "mention"`, true},
+		{`repo == "other" && (bot || type == "mention")`, false},
+
+		// ! applies to the condition right after it.
+		{`!bot`, false},
+		{`!!bot`, true},
+		{`!bot || type == "mention"`, true},
+		{`!(bot || type == "mention")`, false},
+		{`!repo startsWith "acme/"`, false},
+		{`!bot && !labels contains "wontfix"`, false},
+		{`!(bot && labels contains "wontfix")`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			expr, err := Parse(tt.source, testFields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.Match(testLookup); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMatchMissingFields checks that fields the lookup does not know get
+// their zero value instead of panicking.
+func TestMatchMissingFields(t *testing.T) {
+	expr, err := Parse(`repo == "" && !bot && !(labels contains "bug")`, testFields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expr.Match(func(string) any { return nil }) {
+		t.Error("Match with no values = false, want true")
+	}
+}
+
+// TestParseErrors checks the errors /filter replies with for expressions
+// that do not parse or type check.
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{``, `invalid filter "": unexpected end of filter`},
+		{`repo`, `invalid filter "repo": the filter is a string, expected a condition`},
+		{`labels`, `invalid filter "labels": the filter is a list, expected a condition`},
+		{`author == "x"`, `invalid filter "author == \"x\"": unknown field "author"`},
+		{`repo is "x"`, `invalid filter "repo is \"x\"": unknown operator "is"`},
+		{`repo == "x`, `invalid filter "repo == \"x": unterminated string at 8`},
+		{`repo == 'x'`, `invalid filter "repo == 'x'": unexpected '\'' at 8`},
+		{`repo == "x" &&`, `invalid filter "repo == \"x\" &&": unexpected end of filter`},
+		{`repo == "x" bot`, `invalid filter "repo == \"x\" bot": unexpected "bot"`},
+		{`(bot`, `invalid filter "(bot": expected ), got end of filter`},
+		{`bot)`, `invalid filter "bot)": unexpected ")"`},
+		{`repo == bot`, `invalid filter "repo == bot": == needs two strings or two bools, got a string and a bool`},
+		{`labels == "bug"`, `invalid filter "labels == \"bug\"": == needs two strings or two bools, got a list and a string`},
+		{`bot contains "x"`, `invalid filter "bot contains \"x\"": contains needs a string and a string, got a bool and a string`},
+		{`repo startsWith labels`, `invalid filter "repo startsWith labels": startsWith needs a string and a string, got a string and a list`},
+		{`repo in "acme/api"`, `invalid filter "repo in \"acme/api\"": in needs a string and a list, got a string and a string`},
+		{`type in ["a" "b"]`, `invalid filter "type in [\"a\" \"b\"]": expected , or ], got "b"`},
+		{`type in [bot]`, `invalid filter "type in [bot]": lists hold quoted strings, got "bot"`},
+		{`repo matches type`, `invalid filter "repo matches type": matches needs a string and a quoted regular expression`},
+		{`repo matches "("`, "invalid filter \"repo matches \\\"(\\\"\": invalid regular expression \"(\": error parsing regexp: missing closing ): `(`"},
+		{`!repo`, `invalid filter "!repo": ! needs a condition, got a string`},
+		{`bot && repo`, `invalid filter "bot && repo": && needs conditions, got a bool and a string`},
+		{`repo || bot`, `invalid filter "repo || bot": || needs conditions, got a string and a bool`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source, testFields)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
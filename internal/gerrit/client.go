@@ -0,0 +1,104 @@
+package gerrit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Gerrit prefixes JSON responses with this line to prevent XSSI.
+var xssiPrefix = []byte(")]}'")
+
+type Client struct {
+	baseURL  string
+	username string
+	password string
+	http     *http.Client
+}
+
+type accountInfo struct {
+	Name     string `json:"name"`
+	Username string `json:"username"`
+}
+
+type labelInfo struct {
+	Approved    *accountInfo `json:"approved"`
+	Rejected    *accountInfo `json:"rejected"`
+	Recommended *accountInfo `json:"recommended"`
+	Disliked    *accountInfo `json:"disliked"`
+}
+
+type revisionInfo struct {
+	Number   int          `json:"_number"`
+	Created  string       `json:"created"`
+	Uploader *accountInfo `json:"uploader"`
+}
+
+type changeInfo struct {
+	Number          int                     `json:"_number"`
+	Project         string                  `json:"project"`
+	Subject         string                  `json:"subject"`
+	Owner           accountInfo             `json:"owner"`
+	Updated         string                  `json:"updated"`
+	Labels          map[string]labelInfo    `json:"labels"`
+	CurrentRevision string                  `json:"current_revision"`
+	Revisions       map[string]revisionInfo `json:"revisions"`
+}
+
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		username: username,
+		password: password,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) queryChanges(ctx context.Context, query string, options ...string) ([]changeInfo, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("n", "100")
+	for _, option := range options {
+		params.Add("o", option)
+	}
+
+	// The /a/ prefix selects authenticated access in the Gerrit REST API.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/a/changes/?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query changes: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Gerrit returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var changes []changeInfo
+	if err := json.Unmarshal(bytes.TrimPrefix(body, xssiPrefix), &changes); err != nil {
+		return nil, fmt.Errorf("failed to decode changes: %v", err)
+	}
+
+	return changes, nil
+}
+
+func (c *Client) changeURL(change changeInfo) string {
+	return fmt.Sprintf("%s/c/%s/+/%d", c.baseURL, change.Project, change.Number)
+}
@@ -0,0 +1,166 @@
+package gerrit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+)
+
+const timestampLayout = "2006-01-02 15:04:05.000000000"
+
+// parseTimestamp reads a Gerrit timestamp, which is in UTC. It returns the
+// zero time when the timestamp is missing or malformed.
+func parseTimestamp(value string) time.Time {
+	t, err := time.Parse(timestampLayout, value)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func (c *Client) GetNotifications(ctx context.Context) ([]models.Notification, error) {
+	var notifications []models.Notification
+
+	reviews, err := c.checkReviewRequests(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check review requests: %v", err)
+	}
+	notifications = append(notifications, reviews...)
+
+	patchSets, err := c.checkPatchSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check patch sets: %v", err)
+	}
+	notifications = append(notifications, patchSets...)
+
+	labels, err := c.checkLabels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check labels: %v", err)
+	}
+	notifications = append(notifications, labels...)
+
+	return notifications, nil
+}
+
+func (c *Client) checkReviewRequests(ctx context.Context) ([]models.Notification, error) {
+	var notifications []models.Notification
+
+	changes, err := c.queryChanges(ctx, "is:open reviewer:self -owner:self")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		notification := models.Notification{
+			Type:       "gerrit_review_requested",
+			Repo:       change.Project,
+			Fields:     models.Fields{Title: change.Subject, Number: change.Number, Actor: change.Owner.Name},
+			URL:        c.changeURL(change),
+			OccurredAt: parseTimestamp(change.Updated),
+			EventID:    models.NewEventID("gerrit", c.changeURL(change), "gerrit_review_requested", parseTimestamp(change.Updated)),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (c *Client) checkPatchSets(ctx context.Context) ([]models.Notification, error) {
+	var notifications []models.Notification
+
+	changes, err := c.queryChanges(ctx, "is:open reviewer:self -owner:self", "CURRENT_REVISION", "DETAILED_ACCOUNTS")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		revision, ok := change.Revisions[change.CurrentRevision]
+		if !ok || revision.Number <= 1 {
+			continue
+		}
+
+		// Only notify about patch sets uploaded in the last 24 hours
+		created, err := time.Parse(timestampLayout, revision.Created)
+		if err != nil || time.Since(created) > 24*time.Hour {
+			continue
+		}
+
+		uploader := change.Owner.Name
+		if revision.Uploader != nil {
+			uploader = revision.Uploader.Name
+		}
+
+		notification := models.Notification{
+			Type:       "gerrit_patch_set",
+			Repo:       change.Project,
+			Fields:     models.Fields{Title: change.Subject, Number: change.Number, Actor: uploader, Version: strconv.Itoa(revision.Number)},
+			URL:        fmt.Sprintf("%s/%d", c.changeURL(change), revision.Number),
+			OccurredAt: created,
+			EventID:    models.NewEventID("gerrit", c.changeURL(change), "gerrit_patch_set", created),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (c *Client) checkLabels(ctx context.Context) ([]models.Notification, error) {
+	var notifications []models.Notification
+
+	changes, err := c.queryChanges(ctx, "is:open owner:self", "LABELS")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, change := range changes {
+		votes := labelVotes(change.Labels)
+		if len(votes) == 0 {
+			continue
+		}
+
+		// The vote summary is part of the message, so every label change
+		// produces a new content hash and is delivered again.
+		notification := models.Notification{
+			Type:       "gerrit_label",
+			Repo:       change.Project,
+			Fields:     models.Fields{Title: change.Subject, Number: change.Number, Details: strings.Join(votes, ", ")},
+			URL:        c.changeURL(change),
+			OccurredAt: parseTimestamp(change.Updated),
+			EventID:    models.NewEventID("gerrit", c.changeURL(change), "gerrit_label", parseTimestamp(change.Updated)),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func labelVotes(labels map[string]labelInfo) []string {
+	var votes []string
+	for name, label := range labels {
+		var vote string
+		switch {
+		case label.Rejected != nil:
+			vote = fmt.Sprintf("%s rejected by %s", name, label.Rejected.Name)
+		case label.Approved != nil:
+			vote = fmt.Sprintf("%s approved by %s", name, label.Approved.Name)
+		case label.Disliked != nil:
+			vote = fmt.Sprintf("%s disliked by %s", name, label.Disliked.Name)
+		case label.Recommended != nil:
+			vote = fmt.Sprintf("%s recommended by %s", name, label.Recommended.Name)
+		default:
+			continue
+		}
+		votes = append(votes, vote)
+	}
+	sort.Strings(votes)
+	return votes
+}
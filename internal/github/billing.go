@@ -0,0 +1,100 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// includedStorageGB is the Actions and Packages storage each plan
+// includes. GitHub does not report it through the API.
+var includedStorageGB = map[string]float64{
+	"free":          0.5,
+	"pro":           1,
+	"team":          2,
+	"business":      50,
+	"business_plus": 50,
+	"enterprise":    50,
+}
+
+// GetActionsQuotas returns the Actions minutes and the Actions and
+// Packages storage the user or organization used in the current billing
+// cycle, against what its plan includes. Quotas the plan does not limit
+// are left out. Reading them needs the user scope for users, and the
+// admin:org scope or the billing manager role for organizations.
+func (c *Client) GetActionsQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error) {
+	var actions *github.ActionBilling
+	var storage *github.StorageBilling
+	var err error
+	if org {
+		if actions, _, err = c.client.Billing.GetActionsBillingOrg(ctx, owner); err != nil {
+			return nil, fmt.Errorf("failed to get Actions billing of %s: %v", owner, err)
+		}
+		if storage, _, err = c.client.Billing.GetStorageBillingOrg(ctx, owner); err != nil {
+			return nil, fmt.Errorf("failed to get storage billing of %s: %v", owner, err)
+		}
+	} else {
+		if actions, _, err = c.client.Billing.GetActionsBillingUser(ctx, owner); err != nil {
+			return nil, fmt.Errorf("failed to get Actions billing of %s: %v", owner, err)
+		}
+		if storage, _, err = c.client.Billing.GetStorageBillingUser(ctx, owner); err != nil {
+			return nil, fmt.Errorf("failed to get storage billing of %s: %v", owner, err)
+		}
+	}
+	plan, err := c.plan(ctx, owner, org)
+	if err != nil {
+		return nil, err
+	}
+	billingURL := billingSummaryURL(owner, org)
+
+	var quotas []models.Quota
+	if actions.IncludedMinutes > 0 {
+		quotas = append(quotas, models.Quota{
+			Owner:    owner,
+			Name:     "Actions minutes",
+			Used:     actions.TotalMinutesUsed,
+			Included: actions.IncludedMinutes,
+			Unit:     "minutes",
+			URL:      billingURL,
+			DaysLeft: storage.DaysLeftInBillingCycle,
+		})
+	}
+	if included, ok := includedStorageGB[plan]; ok {
+		quotas = append(quotas, models.Quota{
+			Owner:    owner,
+			Name:     "Actions and Packages storage",
+			Used:     storage.EstimatedStorageForMonth,
+			Included: included,
+			Unit:     "GB",
+			URL:      billingURL,
+			DaysLeft: storage.DaysLeftInBillingCycle,
+		})
+	}
+	return quotas, nil
+}
+
+// plan returns the name of the user's or organization's plan, such as
+// "free" or "team".
+func (c *Client) plan(ctx context.Context, owner string, org bool) (string, error) {
+	if org {
+		organization, _, err := c.client.Organizations.Get(ctx, owner)
+		if err != nil {
+			return "", fmt.Errorf("failed to get organization %s: %v", owner, err)
+		}
+		return organization.GetPlan().GetName(), nil
+	}
+	user, _, err := c.client.Users.Get(ctx, owner)
+	if err != nil {
+		return "", fmt.Errorf("failed to get user %s: %v", owner, err)
+	}
+	return user.GetPlan().GetName(), nil
+}
+
+func billingSummaryURL(owner string, org bool) string {
+	if org {
+		return fmt.Sprintf("https://github.com/organizations/%s/settings/billing/summary", owner)
+	}
+	return "https://github.com/settings/billing/summary"
+}
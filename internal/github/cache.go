@@ -0,0 +1,229 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const etagTTL = 24 * time.Hour
+
+// detailTTL is how long the status of an issue or pull request looked up
+// for a single notification, mention or review request is reused. It is
+// shorter than the 15 minutes between the checks of a chat, so each check
+// sees fresh data, and saves the lookups repeated within a cycle, such as
+// the thread of several notifications about the same issue or the status
+// of a pull request watched by several chats of an account.
+const detailTTL = 5 * time.Minute
+
+var responseCache cache.Cache
+
+// SetCache enables conditional requests with ETags and rate-limit tracking
+// for clients created afterwards. GitHub does not count 304 responses
+// against the rate limit, so unchanged endpoints become free to poll.
+// Requests that carry their own conditional headers, such as the first
+// page of notifications, bypass the cache and get GitHub's 304 as it is,
+// so their callers can skip unchanged data instead of parsing it again.
+func SetCache(c cache.Cache) {
+	responseCache = c
+}
+
+type cachedResponse struct {
+	ETag   string      `json:"etag"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// RateLimit is the last rate-limit state GitHub reported for a token.
+type RateLimit struct {
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+type cachingTransport struct {
+	next     http.RoundTripper
+	cache    cache.Cache
+	tokenKey string
+}
+
+func newCachingTransport(next http.RoundTripper, cache cache.Cache, token string) *cachingTransport {
+	return &cachingTransport{
+		next:     next,
+		cache:    cache,
+		tokenKey: tokenKey(token),
+	}
+}
+
+// tokenKey identifies a token in cache keys without storing the token itself.
+func tokenKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracing.Start(req.Context(), "github.request",
+		attribute.String("http.method", req.Method),
+		attribute.String("http.path", req.URL.Path),
+	)
+	resp, err := t.roundTrip(req.WithContext(ctx), span)
+	if resp != nil {
+		span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+func (t *cachingTransport) roundTrip(req *http.Request, span trace.Span) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.next.RoundTrip(req)
+	}
+	if req.Header.Get("If-None-Match") != "" || req.Header.Get("If-Modified-Since") != "" {
+		resp, err := t.next.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		t.recordRateLimit(req.Context(), resp)
+		return resp, nil
+	}
+
+	key := "etag:" + t.tokenKey + ":" + req.URL.String()
+	var cached *cachedResponse
+	if data, found, err := t.cache.Get(req.Context(), key); err == nil && found {
+		cached = &cachedResponse{}
+		if err := json.Unmarshal(data, cached); err != nil {
+			cached = nil
+		}
+	}
+
+	if cached != nil {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.recordRateLimit(req.Context(), resp)
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		span.SetAttributes(attribute.Bool("cache_hit", true))
+		resp.Body.Close()
+		header := cached.Header.Clone()
+		for _, name := range []string{"X-Ratelimit-Limit", "X-Ratelimit-Remaining", "X-Ratelimit-Reset", "X-Poll-Interval"} {
+			if value := resp.Header.Get(name); value != "" {
+				header.Set(name, value)
+			}
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         resp.Proto,
+			ProtoMajor:    resp.ProtoMajor,
+			ProtoMinor:    resp.ProtoMinor,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader(cached.Body)),
+			ContentLength: int64(len(cached.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || etag == "" {
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	data, err := json.Marshal(cachedResponse{ETag: etag, Header: resp.Header, Body: body})
+	if err == nil {
+		if err := t.cache.Set(req.Context(), key, data, etagTTL); err != nil {
+			log.Printf("Warning: failed to cache GitHub response: %v", err)
+		}
+	}
+
+	return resp, nil
+}
+
+func (t *cachingTransport) recordRateLimit(ctx context.Context, resp *http.Response) {
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	state := RateLimit{Remaining: remaining, Reset: time.Unix(reset, 0)}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := t.cache.Set(ctx, "ratelimit:"+t.tokenKey, data, time.Until(state.Reset)+time.Minute); err != nil {
+		log.Printf("Warning: failed to cache rate limit state: %v", err)
+	}
+}
+
+// CachedRateLimit returns the last rate-limit state recorded for token, if
+// a cache is configured and GitHub has been queried with it recently.
+func CachedRateLimit(ctx context.Context, token string) (RateLimit, bool) {
+	if responseCache == nil {
+		return RateLimit{}, false
+	}
+
+	data, found, err := responseCache.Get(ctx, "ratelimit:"+tokenKey(token))
+	if err != nil || !found {
+		return RateLimit{}, false
+	}
+
+	var state RateLimit
+	if err := json.Unmarshal(data, &state); err != nil {
+		return RateLimit{}, false
+	}
+	return state, true
+}
+
+// cachedDetail returns the value cached under key for the client's token,
+// or the one fetch returns, cached for detailTTL. Errors are not cached.
+func cachedDetail[T any](ctx context.Context, c *Client, key string, fetch func() (T, error)) (T, error) {
+	if c.details == nil {
+		return fetch()
+	}
+
+	key = "detail:" + c.tokenKey + ":" + key
+	if data, found, err := c.details.Get(ctx, key); err == nil && found {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			return value, nil
+		}
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	if data, err := json.Marshal(value); err == nil {
+		if err := c.details.Set(ctx, key, data, detailTTL); err != nil {
+			log.Printf("Warning: failed to cache GitHub details: %v", err)
+		}
+	}
+	return value, nil
+}
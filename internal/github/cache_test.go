@@ -0,0 +1,150 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+const testLastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// newCachedTestClient returns a client with a response cache talking to
+// handler.
+func newCachedTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	if err := SetBaseURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	SetCache(cache.NewMemory())
+	t.Cleanup(func() {
+		SetBaseURL("")
+		SetCache(nil)
+	})
+	return NewClient("cache-test-token")
+}
+
+// TestCacheNotificationsNotModified checks that the 304 GitHub answers to
+// the notifications' If-Modified-Since reaches GetNotifications, which then
+// returns nothing instead of the cached inbox.
+func TestCacheNotificationsNotModified(t *testing.T) {
+	var notModified atomic.Int32
+	client := newCachedTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Modified-Since") == testLastModified || r.Header.Get("If-None-Match") == `"inbox"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", testLastModified)
+		w.Header().Set("ETag", `"inbox"`)
+		fmt.Fprint(w, `[{"id": "1", "unread": true, "reason": "mention", "updated_at": "2006-01-02T15:04:05Z",
+			"subject": {"title": "Fix it", "url": "https://api.github.com/repos/acme/api/issues/1", "type": "Issue"},
+			"repository": {"full_name": "acme/api"}}]`)
+	}))
+
+	ctx := context.Background()
+	var state models.AccountState
+	notifications, err := client.GetNotifications(ctx, "octocat", &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 1 || state.LastModified != testLastModified {
+		t.Fatalf("first poll: %d notifications, Last-Modified %q", len(notifications), state.LastModified)
+	}
+
+	notifications, err = client.GetNotifications(ctx, "octocat", &state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notifications) != 0 {
+		t.Errorf("unchanged inbox returned %d notifications, want none", len(notifications))
+	}
+	if notModified.Load() != 1 {
+		t.Errorf("GitHub answered 304 %d times, want 1", notModified.Load())
+	}
+}
+
+// TestCacheETag checks that requests without conditional headers of their
+// own are answered from the cache when GitHub reports them unchanged.
+func TestCacheETag(t *testing.T) {
+	var notModified atomic.Int32
+	client := newCachedTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"repo"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"repo"`)
+		fmt.Fprint(w, `{"full_name": "acme/api"}`)
+	}))
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		repo, resp, err := client.client.Repositories.Get(ctx, "acme", "api")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK || repo.GetFullName() != "acme/api" {
+			t.Errorf("request %d: status %d, repository %q", i+1, resp.StatusCode, repo.GetFullName())
+		}
+	}
+	if notModified.Load() != 1 {
+		t.Errorf("GitHub answered 304 %d times, want 1", notModified.Load())
+	}
+}
+
+// TestCacheDetails checks that the per-item lookups of a pull request are
+// answered from the cache within detailTTL, and that a lookup for another
+// user is not.
+func TestCacheDetails(t *testing.T) {
+	var requests atomic.Int32
+	client := newCachedTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/repos/acme/api/pulls/42":
+			fmt.Fprint(w, `{"state": "open", "requested_reviewers": [{"login": "octocat"}]}`)
+		case "/repos/acme/api/issues/42":
+			fmt.Fprint(w, `{"title": "Fix it", "state": "open", "comments": 0}`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}))
+
+	ctx := context.Background()
+	pullURL := "https://api.github.com/repos/acme/api/pulls/42"
+	requestedAt := time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		status, err := client.GetReviewStatus(ctx, pullURL, "octocat", requestedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !status.Open || !status.Requested {
+			t.Errorf("lookup %d: status %+v, want open and requested", i+1, status)
+		}
+		if _, err := client.GetThread(ctx, pullURL, 10); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A pull request and its reviews, then the issue of the thread.
+	if requests.Load() != 3 {
+		t.Errorf("GitHub got %d requests, want 3", requests.Load())
+	}
+
+	if _, err := client.GetReviewStatus(ctx, pullURL, "hubot", requestedAt); err != nil {
+		t.Fatal(err)
+	}
+	if requests.Load() != 5 {
+		t.Errorf("GitHub got %d requests after a lookup for another user, want 5", requests.Load())
+	}
+}
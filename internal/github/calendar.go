@@ -0,0 +1,128 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetCalendarEntries returns milestone due dates and releases published
+// since the given time for repositories owned by the authenticated user.
+func (c *Client) GetCalendarEntries(ctx context.Context, since time.Time) ([]models.CalendarEntry, error) {
+	var entries []models.CalendarEntry
+
+	opts := &github.RepositoryListByAuthenticatedUserOptions{
+		Affiliation: "owner",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		repos, resp, err := c.client.Repositories.ListByAuthenticatedUser(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %v", err)
+		}
+
+		for _, repo := range repos {
+			if repo.GetArchived() {
+				continue
+			}
+
+			milestones, err := c.getMilestones(ctx, repo)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list milestones for %s: %v", repo.GetFullName(), err)
+			}
+			entries = append(entries, milestones...)
+
+			releases, err := c.getReleaseEntries(ctx, repo, since)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list releases for %s: %v", repo.GetFullName(), err)
+			}
+			entries = append(entries, releases...)
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return entries, nil
+}
+
+func (c *Client) getMilestones(ctx context.Context, repo *github.Repository) ([]models.CalendarEntry, error) {
+	var entries []models.CalendarEntry
+
+	opts := &github.MilestoneListOptions{
+		State: "all",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	milestones, _, err := c.client.Issues.ListMilestones(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, milestone := range milestones {
+		if milestone.DueOn == nil {
+			continue
+		}
+
+		entries = append(entries, models.CalendarEntry{
+			Kind:        "milestone",
+			ID:          milestone.GetID(),
+			Repo:        repo.GetFullName(),
+			Title:       fmt.Sprintf("[%s] Milestone: %s (%d open, %d closed)", repo.GetFullName(), milestone.GetTitle(), milestone.GetOpenIssues(), milestone.GetClosedIssues()),
+			Description: milestone.GetDescription(),
+			URL:         milestone.GetHTMLURL(),
+			Date:        milestone.GetDueOn().Time,
+		})
+	}
+
+	return entries, nil
+}
+
+func (c *Client) getReleaseEntries(ctx context.Context, repo *github.Repository, since time.Time) ([]models.CalendarEntry, error) {
+	var entries []models.CalendarEntry
+
+	opts := &github.ListOptions{
+		PerPage: 20,
+	}
+
+	releases, _, err := c.client.Repositories.ListReleases(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, release := range releases {
+		// Drafts have no publish date yet, so they are placed on their creation date.
+		date := release.GetPublishedAt().Time
+		title := fmt.Sprintf("[%s] Release %s", repo.GetFullName(), release.GetTagName())
+		if release.GetDraft() {
+			date = release.GetCreatedAt().Time
+			title = fmt.Sprintf("[%s] Planned release %s (draft)", repo.GetFullName(), release.GetTagName())
+		}
+		if date.Before(since) {
+			continue
+		}
+
+		entries = append(entries, models.CalendarEntry{
+			Kind:        "release",
+			ID:          release.GetID(),
+			Repo:        repo.GetFullName(),
+			Title:       title,
+			Description: strings.Split(release.GetBody(), "\n")[0],
+			URL:         release.GetHTMLURL(),
+			Date:        date,
+		})
+	}
+
+	return entries, nil
+}
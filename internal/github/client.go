@@ -2,23 +2,131 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
 	"github.com/google/go-github/v57/github"
 	"golang.org/x/oauth2"
 )
 
+var (
+	baseTransport  http.RoundTripper
+	requestTimeout time.Duration
+	apiBaseURL     *url.URL
+)
+
+// SetTransport sends the requests of clients created afterwards through
+// transport, e.g. to reach GitHub through a proxy.
+func SetTransport(transport http.RoundTripper) {
+	baseTransport = transport
+}
+
+// SetTimeout bounds each request of clients created afterwards, on top of
+// the deadline of the caller's context.
+func SetTimeout(timeout time.Duration) {
+	requestTimeout = timeout
+}
+
+// SetBaseURL points clients created afterwards at another GitHub API,
+// such as a fake server in tests. An empty URL restores api.github.com.
+func SetBaseURL(baseURL string) error {
+	if baseURL == "" {
+		apiBaseURL = nil
+		return nil
+	}
+	parsed, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/")
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("invalid GitHub API URL %q", baseURL)
+	}
+	apiBaseURL = parsed
+	return nil
+}
+
+// API is what the monitor uses of GitHub. Workers are handed a
+// ClientFactory rather than calling NewClient, so tests can swap in a fake
+// such as githubtest.Client.
+type API interface {
+	GetNotifications(ctx context.Context, username string, state *models.AccountState) ([]models.Notification, error)
+	GetRepoEvents(ctx context.Context, owner, repo string) ([]models.Notification, error)
+	GetCalendarEntries(ctx context.Context, since time.Time) ([]models.CalendarEntry, error)
+	GetFileContent(ctx context.Context, owner, repo, path string) ([]byte, error)
+	GetTokenMetadata(ctx context.Context) (models.GitHubAccountMetadata, error)
+	GetAuthenticatedUser(ctx context.Context) (string, models.GitHubAccountMetadata, error)
+	GetRateLimit(ctx context.Context) (RateLimit, int, error)
+	GetReviewStatus(ctx context.Context, pullURL, username string, requestedAt time.Time) (ReviewStatus, error)
+	GetPullRequests(ctx context.Context, owner, repo string, closedSince time.Time) ([]models.PullRequest, error)
+	GetReplyStatus(ctx context.Context, subjectURL, username string, since time.Time) (ReplyStatus, error)
+	GetIssues(ctx context.Context, owner, repo string, since time.Time) ([]models.Issue, error)
+	GetThread(ctx context.Context, subjectURL string, minComments int) (models.Thread, error)
+	GetReleases(ctx context.Context, owner, repo string, since time.Time) ([]models.Release, error)
+	GetActionsQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error)
+	GetStorageQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error)
+	GetCommits(ctx context.Context, owner, repo string, since time.Time) ([]models.Commit, error)
+	GetHead(ctx context.Context, owner, repo string) (models.Commit, error)
+	Compare(ctx context.Context, owner, repo, base, head string) (models.Comparison, bool, error)
+	GetSecurityAlerts(ctx context.Context, since time.Time) ([]models.SecurityAlert, error)
+}
+
+// ClientFactory creates the client of an account from its token.
+type ClientFactory func(token string) API
+
+// NewAPI is the ClientFactory of clients talking to GitHub.
+func NewAPI(token string) API {
+	return NewClient(token)
+}
+
+var _ API = (*Client)(nil)
+
 type Client struct {
 	client *github.Client
+	// details caches the per-item lookups of issues and pull requests for
+	// detailTTL, nil without a response cache.
+	details  cache.Cache
+	tokenKey string
 }
 
+// NewClient returns a client authenticating with the token, which is
+// redacted from logs from now on.
 func NewClient(token string) *Client {
+	redact.Add(token)
+	return newClient(token)
+}
+
+// CheckToken returns the user GitHub reports as the token's owner and the
+// token's metadata. Unlike NewClient it does not register the token for
+// redaction, so text that GitHub rejects is not masked in logs.
+func CheckToken(ctx context.Context, token string) (string, models.GitHubAccountMetadata, error) {
+	return newClient(token).GetAuthenticatedUser(ctx)
+}
+
+func newClient(token string) *Client {
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
-	tc := oauth2.NewClient(context.Background(), ts)
+	ctx := context.Background()
+	if baseTransport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: baseTransport})
+	}
+	tc := oauth2.NewClient(ctx, ts)
+	tc.Timeout = requestTimeout
+	if responseCache != nil {
+		tc.Transport = newCachingTransport(tc.Transport, responseCache, token)
+	}
 	client := github.NewClient(tc)
+	if apiBaseURL != nil {
+		baseURL := *apiBaseURL
+		client.BaseURL = &baseURL
+	}
 
 	return &Client{
-		client: client,
+		client:   client,
+		details:  responseCache,
+		tokenKey: tokenKey(token),
 	}
 }
@@ -0,0 +1,88 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetCommits returns the commits on the repository's default branch
+// committed since the given time, newest first.
+func (c *Client) GetCommits(ctx context.Context, owner, repo string, since time.Time) ([]models.Commit, error) {
+	var commits []models.Commit
+
+	opts := &github.CommitsListOptions{Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.Repositories.ListCommits(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commits of %s/%s: %v", owner, repo, err)
+		}
+		for _, commit := range page {
+			commits = append(commits, models.Commit{
+				Repo:        owner + "/" + repo,
+				SHA:         commit.GetSHA(),
+				Message:     commit.GetCommit().GetMessage(),
+				Author:      commit.GetAuthor().GetLogin(),
+				URL:         commit.GetHTMLURL(),
+				CommittedAt: commit.GetCommit().GetCommitter().GetDate().Time,
+			})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return commits, nil
+}
+
+// GetHead returns the newest commit on the repository's default branch.
+func (c *Client) GetHead(ctx context.Context, owner, repo string) (models.Commit, error) {
+	commits, _, err := c.client.Repositories.ListCommits(ctx, owner, repo, &github.CommitsListOptions{ListOptions: github.ListOptions{PerPage: 1}})
+	if err != nil {
+		return models.Commit{}, fmt.Errorf("failed to get the head of %s/%s: %v", owner, repo, err)
+	}
+	if len(commits) == 0 {
+		return models.Commit{}, fmt.Errorf("%s/%s has no commits", owner, repo)
+	}
+	commit := commits[0]
+	return models.Commit{
+		Repo:        owner + "/" + repo,
+		SHA:         commit.GetSHA(),
+		Message:     commit.GetCommit().GetMessage(),
+		Author:      commit.GetAuthor().GetLogin(),
+		URL:         commit.GetHTMLURL(),
+		CommittedAt: commit.GetCommit().GetCommitter().GetDate().Time,
+	}, nil
+}
+
+// Compare returns the changes from base to head. ok is false when base no
+// longer exists, for example after a force push.
+func (c *Client) Compare(ctx context.Context, owner, repo, base, head string) (comparison models.Comparison, ok bool, err error) {
+	result, resp, err := c.client.Repositories.CompareCommits(ctx, owner, repo, base, head, &github.ListOptions{PerPage: 100})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return models.Comparison{}, false, nil
+	}
+	if err != nil {
+		return models.Comparison{}, false, fmt.Errorf("failed to compare %s...%s in %s/%s: %v", base, head, owner, repo, err)
+	}
+
+	comparison = models.Comparison{
+		Repo:    owner + "/" + repo,
+		Base:    base,
+		Head:    head,
+		Commits: result.GetAheadBy(),
+		URL:     result.GetHTMLURL(),
+	}
+	for _, file := range result.Files {
+		comparison.Files = append(comparison.Files, file.GetFilename())
+		if previous := file.GetPreviousFilename(); previous != "" {
+			comparison.Files = append(comparison.Files, previous)
+		}
+	}
+	return comparison, true, nil
+}
@@ -0,0 +1,28 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// GetFileContent returns the content of a file on the default branch, or
+// nil if the file does not exist.
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path string) ([]byte, error) {
+	file, _, resp, err := c.client.Repositories.GetContents(ctx, owner, repo, path, nil)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", path, err)
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%s is not a file", path)
+	}
+
+	content, err := file.GetContent()
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return []byte(content), nil
+}
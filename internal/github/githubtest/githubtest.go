@@ -0,0 +1,289 @@
+// Package githubtest provides a fake github.API for tests of code that
+// talks to GitHub:
+//
+//	client := &githubtest.Client{Notifications: []models.Notification{...}}
+//	pollAccount(ctx, client.Factory(), account)
+//	if len(client.Calls()) != 1 { ... }
+package githubtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// Client returns the canned responses in its fields and records the
+// methods called on it. A non-nil Err fails every call.
+type Client struct {
+	Login         string
+	Metadata      models.GitHubAccountMetadata
+	Notifications []models.Notification
+	// RepoEvents maps "owner/repo" to its pull request, issue and release
+	// notifications, returned regardless of when they happened.
+	RepoEvents      map[string][]models.Notification
+	CalendarEntries []models.CalendarEntry
+	// Files maps "owner/repo/path" to the file's content. Missing files
+	// return nil, as the real client does.
+	Files map[string][]byte
+	// PullRequests maps "owner/repo" to its pull requests, returned
+	// regardless of when they were closed.
+	PullRequests  map[string][]models.PullRequest
+	RateLimit     github.RateLimit
+	RateLimitSize int
+	// PollInterval is recorded in the state passed to GetNotifications,
+	// like GitHub's X-Poll-Interval.
+	PollInterval int
+	// ReviewStatuses maps pull request API URLs to the status of their
+	// review requests. Other pull requests are open and wait for the
+	// review.
+	ReviewStatuses map[string]github.ReviewStatus
+	// ReplyStatuses maps issue and pull request API URLs to whether the
+	// user replied there. Others are open and wait for a reply.
+	ReplyStatuses map[string]github.ReplyStatus
+	// Issues maps "owner/repo" to its open issues, returned regardless of
+	// when they were created.
+	Issues map[string][]models.Issue
+	// Threads maps issue and pull request API URLs to their conversation,
+	// returned with its comments regardless of minComments.
+	Threads map[string]models.Thread
+	// Releases maps "owner/repo" to its releases, returned regardless of
+	// when they were published.
+	Releases map[string][]models.Release
+	// Quotas maps user and organization logins to their Actions quotas.
+	Quotas map[string][]models.Quota
+	// StorageQuotas maps user and organization logins to their repository
+	// size and Git LFS quotas.
+	StorageQuotas map[string][]models.Quota
+	// Commits maps "owner/repo" to its commits, newest first, returned
+	// regardless of when they were committed. The first is the head.
+	Commits map[string][]models.Commit
+	// Comparisons maps "owner/repo" to the changes returned by Compare,
+	// regardless of base and head. Compare reports repositories without
+	// one as lacking the base.
+	Comparisons map[string]models.Comparison
+	// SecurityAlerts are the alerts on the user's repositories, of which
+	// those created after since are returned.
+	SecurityAlerts []models.SecurityAlert
+	Err            error
+
+	mu     sync.Mutex
+	calls  []string
+	tokens []string
+}
+
+var _ github.API = (*Client)(nil)
+
+// Factory returns a ClientFactory that hands out c for every token and
+// records the tokens.
+func (c *Client) Factory() github.ClientFactory {
+	return func(token string) github.API {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.tokens = append(c.tokens, token)
+		return c
+	}
+}
+
+// Calls returns the names of the methods called so far, in order.
+func (c *Client) Calls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.calls...)
+}
+
+// Tokens returns the tokens clients were created for through Factory.
+func (c *Client) Tokens() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.tokens...)
+}
+
+func (c *Client) record(method string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, method)
+}
+
+func (c *Client) GetNotifications(ctx context.Context, username string, state *models.AccountState) ([]models.Notification, error) {
+	c.record("GetNotifications")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	if state != nil {
+		state.PollInterval = c.PollInterval
+		for _, notification := range c.Notifications {
+			if notification.OccurredAt.After(state.LastNotificationAt) {
+				state.LastNotificationAt = notification.OccurredAt
+			}
+		}
+	}
+	return append([]models.Notification(nil), c.Notifications...), nil
+}
+
+func (c *Client) GetRepoEvents(ctx context.Context, owner, repo string) ([]models.Notification, error) {
+	c.record("GetRepoEvents")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Notification(nil), c.RepoEvents[owner+"/"+repo]...), nil
+}
+
+func (c *Client) GetCalendarEntries(ctx context.Context, since time.Time) ([]models.CalendarEntry, error) {
+	c.record("GetCalendarEntries")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.CalendarEntry(nil), c.CalendarEntries...), nil
+}
+
+func (c *Client) GetFileContent(ctx context.Context, owner, repo, path string) ([]byte, error) {
+	c.record("GetFileContent")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return c.Files[owner+"/"+repo+"/"+path], nil
+}
+
+func (c *Client) GetTokenMetadata(ctx context.Context) (models.GitHubAccountMetadata, error) {
+	c.record("GetTokenMetadata")
+	if c.Err != nil {
+		return models.GitHubAccountMetadata{}, c.Err
+	}
+	return c.Metadata, nil
+}
+
+func (c *Client) GetAuthenticatedUser(ctx context.Context) (string, models.GitHubAccountMetadata, error) {
+	c.record("GetAuthenticatedUser")
+	if c.Err != nil {
+		return "", models.GitHubAccountMetadata{}, c.Err
+	}
+	return c.Login, c.Metadata, nil
+}
+
+func (c *Client) GetRateLimit(ctx context.Context) (github.RateLimit, int, error) {
+	c.record("GetRateLimit")
+	if c.Err != nil {
+		return github.RateLimit{}, 0, c.Err
+	}
+	return c.RateLimit, c.RateLimitSize, nil
+}
+
+func (c *Client) GetReviewStatus(ctx context.Context, pullURL, username string, requestedAt time.Time) (github.ReviewStatus, error) {
+	c.record("GetReviewStatus")
+	if c.Err != nil {
+		return github.ReviewStatus{}, c.Err
+	}
+	status, ok := c.ReviewStatuses[pullURL]
+	if !ok {
+		return github.ReviewStatus{Requested: true, Open: true}, nil
+	}
+	return status, nil
+}
+
+func (c *Client) GetPullRequests(ctx context.Context, owner, repo string, closedSince time.Time) ([]models.PullRequest, error) {
+	c.record("GetPullRequests")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.PullRequest(nil), c.PullRequests[owner+"/"+repo]...), nil
+}
+
+func (c *Client) GetIssues(ctx context.Context, owner, repo string, since time.Time) ([]models.Issue, error) {
+	c.record("GetIssues")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Issue(nil), c.Issues[owner+"/"+repo]...), nil
+}
+
+func (c *Client) GetThread(ctx context.Context, subjectURL string, minComments int) (models.Thread, error) {
+	c.record("GetThread")
+	if c.Err != nil {
+		return models.Thread{}, c.Err
+	}
+	return c.Threads[subjectURL], nil
+}
+
+func (c *Client) GetReleases(ctx context.Context, owner, repo string, since time.Time) ([]models.Release, error) {
+	c.record("GetReleases")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Release(nil), c.Releases[owner+"/"+repo]...), nil
+}
+
+func (c *Client) GetActionsQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error) {
+	c.record("GetActionsQuotas")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Quota(nil), c.Quotas[owner]...), nil
+}
+
+func (c *Client) GetStorageQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error) {
+	c.record("GetStorageQuotas")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Quota(nil), c.StorageQuotas[owner]...), nil
+}
+
+func (c *Client) GetCommits(ctx context.Context, owner, repo string, since time.Time) ([]models.Commit, error) {
+	c.record("GetCommits")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]models.Commit(nil), c.Commits[owner+"/"+repo]...), nil
+}
+
+func (c *Client) GetHead(ctx context.Context, owner, repo string) (models.Commit, error) {
+	c.record("GetHead")
+	if c.Err != nil {
+		return models.Commit{}, c.Err
+	}
+	commits := c.Commits[owner+"/"+repo]
+	if len(commits) == 0 {
+		return models.Commit{}, fmt.Errorf("%s/%s has no commits", owner, repo)
+	}
+	return commits[0], nil
+}
+
+func (c *Client) Compare(ctx context.Context, owner, repo, base, head string) (models.Comparison, bool, error) {
+	c.record("Compare")
+	if c.Err != nil {
+		return models.Comparison{}, false, c.Err
+	}
+	comparison, ok := c.Comparisons[owner+"/"+repo]
+	comparison.Files = append([]string(nil), comparison.Files...)
+	return comparison, ok, nil
+}
+
+func (c *Client) GetReplyStatus(ctx context.Context, subjectURL, username string, since time.Time) (github.ReplyStatus, error) {
+	c.record("GetReplyStatus")
+	if c.Err != nil {
+		return github.ReplyStatus{}, c.Err
+	}
+	status, ok := c.ReplyStatuses[subjectURL]
+	if !ok {
+		return github.ReplyStatus{Open: true}, nil
+	}
+	return status, nil
+}
+
+func (c *Client) GetSecurityAlerts(ctx context.Context, since time.Time) ([]models.SecurityAlert, error) {
+	c.record("GetSecurityAlerts")
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	var alerts []models.SecurityAlert
+	for _, alert := range c.SecurityAlerts {
+		if alert.CreatedAt.After(since) {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
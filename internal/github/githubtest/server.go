@@ -0,0 +1,381 @@
+package githubtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// Scenario is the state a Server starts with, in the JSON shapes of the
+// GitHub API. Repositories are keyed by "owner/repo", and comments and
+// reviews by "owner/repo#number". Repositories without Dependabot or code
+// scanning alerts answer 404, as when the alerts are disabled.
+type Scenario struct {
+	Login         string                                 `json:"login"`
+	Scopes        []string                               `json:"scopes"`
+	Notifications []*github.Notification                 `json:"notifications"`
+	Repositories  []*github.Repository                   `json:"repositories"`
+	PullRequests  map[string][]*github.PullRequest       `json:"pull_requests"`
+	Issues        map[string][]*github.Issue             `json:"issues"`
+	Comments      map[string][]*github.IssueComment      `json:"comments"`
+	Reviews       map[string][]*github.PullRequestReview `json:"reviews"`
+	Releases      map[string][]*github.RepositoryRelease `json:"releases"`
+	Milestones    map[string][]*github.Milestone         `json:"milestones"`
+	Files         map[string]string                      `json:"files"`
+
+	DependabotAlerts   map[string][]*github.DependabotAlert `json:"dependabot_alerts"`
+	CodeScanningAlerts map[string][]*github.Alert           `json:"code_scanning_alerts"`
+}
+
+// Server is a fake GitHub API serving a scenario, for tests that run the
+// monitor against GitHub end to end. It serves notifications, the
+// authenticated user and rate limit, repositories with their pull
+// requests and reviews, Dependabot and code scanning alerts, issues,
+// comments, releases, milestones and file contents, and issue search, and
+// accepts submitted reviews. Start it with httptest or mount it as an
+// http.Handler, and point clients at it with github.SetBaseURL.
+type Server struct {
+	mu       sync.Mutex
+	scenario Scenario
+	requests []string
+}
+
+// NewServer returns a server serving the scenario.
+func NewServer(scenario Scenario) *Server {
+	if scenario.Login == "" {
+		scenario.Login = "octocat"
+	}
+	return &Server{scenario: scenario}
+}
+
+// Start serves the server on a local port until the returned server is
+// closed.
+func (s *Server) Start() *httptest.Server {
+	return httptest.NewServer(s)
+}
+
+// AddNotification adds a notification thread, or replaces the thread with
+// the same ID, as GitHub does when a thread has new activity.
+func (s *Server) AddNotification(notification *github.Notification) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.scenario.Notifications {
+		if existing.GetID() == notification.GetID() {
+			s.scenario.Notifications[i] = notification
+			return
+		}
+	}
+	s.scenario.Notifications = append(s.scenario.Notifications, notification)
+}
+
+// Requests returns the method and path of the requests served so far.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case r.Method == http.MethodGet && path == "notifications":
+		s.serveNotifications(w, r)
+	case r.Method == http.MethodPatch && len(parts) == 3 && parts[0] == "notifications" && parts[1] == "threads":
+		s.markRead(w, parts[2])
+	case r.Method == http.MethodGet && path == "user":
+		w.Header().Set("X-OAuth-Scopes", strings.Join(s.scenario.Scopes, ", "))
+		writeJSON(w, &github.User{Login: github.String(s.scenario.Login)})
+	case r.Method == http.MethodGet && path == "user/repos":
+		writeJSON(w, s.scenario.Repositories)
+	case r.Method == http.MethodGet && path == "rate_limit":
+		writeJSON(w, rateLimits())
+	case r.Method == http.MethodGet && path == "search/issues":
+		writeJSON(w, s.search(r.URL.Query().Get("q")))
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "repos":
+		s.serveRepository(w, parts[1]+"/"+parts[2])
+	case r.Method == http.MethodGet && len(parts) >= 4 && parts[0] == "repos":
+		s.serveRepo(w, r, parts[1]+"/"+parts[2], parts[3:])
+	case r.Method == http.MethodPost && len(parts) == 6 && parts[0] == "repos" && parts[3] == "pulls" && parts[5] == "reviews":
+		s.submitReview(w, r, parts[1]+"/"+parts[2]+"#"+parts[4])
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// serveNotifications lists the threads like GET /notifications: unread
+// ones unless all is set, updated after since, newest first.
+func (s *Server) serveNotifications(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	all := query.Get("all") == "true"
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		since, _ = time.Parse(time.RFC3339, value)
+	}
+
+	notifications := []*github.Notification{}
+	for _, notification := range s.scenario.Notifications {
+		if !all && !notification.GetUnread() {
+			continue
+		}
+		if !since.IsZero() && !notification.GetUpdatedAt().After(since) {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.SliceStable(notifications, func(i, j int) bool {
+		return notifications[i].GetUpdatedAt().After(notifications[j].GetUpdatedAt().Time)
+	})
+	w.Header().Set("X-Poll-Interval", "60")
+	writeJSON(w, notifications)
+}
+
+func (s *Server) markRead(w http.ResponseWriter, id string) {
+	for _, notification := range s.scenario.Notifications {
+		if notification.GetID() == id {
+			notification.Unread = github.Bool(false)
+		}
+	}
+	w.WriteHeader(http.StatusResetContent)
+}
+
+// submitReview adds the review submitted as the scenario's user to the
+// pull request's reviews.
+func (s *Server) submitReview(w http.ResponseWriter, r *http.Request, pull string) {
+	var request github.PullRequestReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	states := map[string]string{"APPROVE": "APPROVED", "COMMENT": "COMMENTED", "REQUEST_CHANGES": "CHANGES_REQUESTED"}
+	state, ok := states[request.GetEvent()]
+	if !ok || (state != "APPROVED" && request.GetBody() == "") {
+		writeError(w, http.StatusUnprocessableEntity, "Unprocessable Entity")
+		return
+	}
+
+	if s.scenario.Reviews == nil {
+		s.scenario.Reviews = make(map[string][]*github.PullRequestReview)
+	}
+	review := &github.PullRequestReview{
+		ID:          github.Int64(int64(len(s.scenario.Reviews[pull]) + 1)),
+		User:        &github.User{Login: github.String(s.scenario.Login)},
+		Body:        request.Body,
+		State:       github.String(state),
+		SubmittedAt: &github.Timestamp{Time: time.Now()},
+	}
+	s.scenario.Reviews[pull] = append(s.scenario.Reviews[pull], review)
+	writeJSON(w, review)
+}
+
+// serveRepository returns the scenario's repository with the full name,
+// matched case-insensitively like GitHub does.
+func (s *Server) serveRepository(w http.ResponseWriter, repo string) {
+	for _, repository := range s.scenario.Repositories {
+		if strings.EqualFold(repository.GetFullName(), repo) {
+			writeJSON(w, repository)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, "Not Found")
+}
+
+func (s *Server) serveRepo(w http.ResponseWriter, r *http.Request, repo string, parts []string) {
+	switch {
+	case len(parts) == 1 && parts[0] == "pulls":
+		pulls := []*github.PullRequest{}
+		for _, pull := range s.scenario.PullRequests[repo] {
+			if matchesState(r.URL.Query().Get("state"), pull.GetState()) {
+				pulls = append(pulls, pull)
+			}
+		}
+		writeJSON(w, pulls)
+	case len(parts) == 2 && parts[0] == "pulls":
+		for _, pull := range s.scenario.PullRequests[repo] {
+			if strconv.Itoa(pull.GetNumber()) == parts[1] {
+				writeJSON(w, pull)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "Not Found")
+	case len(parts) == 3 && parts[0] == "pulls" && parts[2] == "reviews":
+		reviews := s.scenario.Reviews[repo+"#"+parts[1]]
+		if reviews == nil {
+			reviews = []*github.PullRequestReview{}
+		}
+		writeJSON(w, reviews)
+	case len(parts) == 1 && parts[0] == "issues":
+		issues := []*github.Issue{}
+		for _, issue := range s.scenario.Issues[repo] {
+			if matchesState(r.URL.Query().Get("state"), issue.GetState()) {
+				issues = append(issues, issue)
+			}
+		}
+		writeJSON(w, issues)
+	case len(parts) == 2 && parts[0] == "issues":
+		for _, issue := range s.scenario.Issues[repo] {
+			if strconv.Itoa(issue.GetNumber()) == parts[1] {
+				writeJSON(w, issue)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "Not Found")
+	case len(parts) == 3 && parts[0] == "issues" && parts[2] == "comments":
+		comments := s.scenario.Comments[repo+"#"+parts[1]]
+		if comments == nil {
+			comments = []*github.IssueComment{}
+		}
+		writeJSON(w, comments)
+	case len(parts) == 2 && parts[0] == "dependabot" && parts[1] == "alerts":
+		alerts, ok := s.scenario.DependabotAlerts[repo]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, alerts)
+	case len(parts) == 2 && parts[0] == "code-scanning" && parts[1] == "alerts":
+		alerts, ok := s.scenario.CodeScanningAlerts[repo]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, alerts)
+	case len(parts) == 1 && parts[0] == "releases":
+		releases := s.scenario.Releases[repo]
+		if releases == nil {
+			releases = []*github.RepositoryRelease{}
+		}
+		writeJSON(w, releases)
+	case len(parts) == 1 && parts[0] == "milestones":
+		milestones := s.scenario.Milestones[repo]
+		if milestones == nil {
+			milestones = []*github.Milestone{}
+		}
+		writeJSON(w, milestones)
+	case len(parts) >= 2 && parts[0] == "contents":
+		path := strings.Join(parts[1:], "/")
+		content, ok := s.scenario.Files[repo+"/"+path]
+		if !ok {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, &github.RepositoryContent{
+			Type:     github.String("file"),
+			Name:     github.String(parts[len(parts)-1]),
+			Path:     github.String(path),
+			Encoding: github.String("base64"),
+			Content:  github.String(base64.StdEncoding.EncodeToString([]byte(content))),
+		})
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// search answers GET /search/issues over the scenario's issues and pull
+// requests. It understands repo:, is:pr, is:issue, is:open and is:closed
+// qualifiers; other terms must appear in the title.
+func (s *Server) search(query string) *github.IssuesSearchResult {
+	var repo, kind, state string
+	var terms []string
+	for _, field := range strings.Fields(query) {
+		switch {
+		case strings.HasPrefix(field, "repo:"):
+			repo = strings.TrimPrefix(field, "repo:")
+		case field == "is:pr" || field == "is:issue":
+			kind = strings.TrimPrefix(field, "is:")
+		case field == "is:open" || field == "is:closed":
+			state = strings.TrimPrefix(field, "is:")
+		default:
+			terms = append(terms, strings.ToLower(field))
+		}
+	}
+
+	matches := func(itemRepo, itemKind, itemState, title string) bool {
+		if (repo != "" && repo != itemRepo) || (kind != "" && kind != itemKind) || (state != "" && state != itemState) {
+			return false
+		}
+		for _, term := range terms {
+			if !strings.Contains(strings.ToLower(title), term) {
+				return false
+			}
+		}
+		return true
+	}
+
+	result := &github.IssuesSearchResult{Issues: []*github.Issue{}}
+	for _, itemRepo := range sortedKeys(s.scenario.Issues) {
+		for _, issue := range s.scenario.Issues[itemRepo] {
+			if matches(itemRepo, "issue", issue.GetState(), issue.GetTitle()) {
+				result.Issues = append(result.Issues, issue)
+			}
+		}
+	}
+	for _, itemRepo := range sortedKeys(s.scenario.PullRequests) {
+		for _, pull := range s.scenario.PullRequests[itemRepo] {
+			if matches(itemRepo, "pr", pull.GetState(), pull.GetTitle()) {
+				result.Issues = append(result.Issues, &github.Issue{
+					Number:           pull.Number,
+					Title:            pull.Title,
+					State:            pull.State,
+					HTMLURL:          pull.HTMLURL,
+					User:             pull.User,
+					UpdatedAt:        pull.UpdatedAt,
+					PullRequestLinks: &github.PullRequestLinks{HTMLURL: pull.HTMLURL},
+				})
+			}
+		}
+	}
+	result.Total = github.Int(len(result.Issues))
+	result.IncompleteResults = github.Bool(false)
+	return result
+}
+
+func matchesState(want, state string) bool {
+	if state == "" {
+		state = "open"
+	}
+	return want == "all" || (want == "" && state == "open") || want == state
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// rateLimits reports a full core rate limit resetting in an hour.
+func rateLimits() map[string]any {
+	core := map[string]any{
+		"limit":     5000,
+		"remaining": 5000,
+		"used":      0,
+		"reset":     time.Now().Add(time.Hour).Unix(),
+	}
+	return map[string]any{"resources": map[string]any{"core": core}, "rate": core}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
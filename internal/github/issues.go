@@ -0,0 +1,89 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetIssues returns the repository's open issues created since the given
+// time, newest first. Pull requests are left out.
+func (c *Client) GetIssues(ctx context.Context, owner, repo string, since time.Time) ([]models.Issue, error) {
+	var issues []models.Issue
+
+	// Since filters on the update time, so issues are listed newest first
+	// and paging stops at the first one created before since.
+	opts := &github.IssueListByRepoOptions{State: "open", Sort: "created", Direction: "desc", Since: since, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.Issues.ListByRepo(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list issues of %s/%s: %v", owner, repo, err)
+		}
+		older := false
+		for _, issue := range page {
+			if issue.GetCreatedAt().Before(since) {
+				older = true
+				continue
+			}
+			if issue.IsPullRequest() {
+				continue
+			}
+			issues = append(issues, newIssue(owner, repo, issue))
+		}
+		if older || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return issues, nil
+}
+
+// AddLabels adds the labels to the issue with the given page URL, such as
+// https://github.com/acme/api/issues/42.
+func (c *Client) AddLabels(ctx context.Context, issueURL string, labels []string) error {
+	owner, repo, number, err := parseIssueURL(issueURL)
+	if err != nil {
+		return err
+	}
+	if _, _, err := c.client.Issues.AddLabelsToIssue(ctx, owner, repo, number, labels); err != nil {
+		return fmt.Errorf("failed to label %s/%s#%d: %v", owner, repo, number, err)
+	}
+	return nil
+}
+
+func parseIssueURL(issueURL string) (owner, repo string, number int, err error) {
+	_, path, ok := strings.Cut(issueURL, "github.com/")
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	if !ok || len(parts) != 4 || parts[2] != "issues" {
+		return "", "", 0, fmt.Errorf("invalid issue URL %q", issueURL)
+	}
+	number, err = strconv.Atoi(parts[3])
+	if err != nil {
+		return "", "", 0, fmt.Errorf("invalid issue URL %q", issueURL)
+	}
+	return parts[0], parts[1], number, nil
+}
+
+func newIssue(owner, repo string, issue *github.Issue) models.Issue {
+	i := models.Issue{
+		Repo:      owner + "/" + repo,
+		Number:    issue.GetNumber(),
+		Title:     issue.GetTitle(),
+		URL:       issue.GetHTMLURL(),
+		Author:    issue.GetUser().GetLogin(),
+		CreatedAt: issue.GetCreatedAt().Time,
+	}
+	for _, label := range issue.Labels {
+		i.Labels = append(i.Labels, label.GetName())
+	}
+	for _, assignee := range issue.Assignees {
+		i.Assignees = append(i.Assignees, assignee.GetLogin())
+	}
+	return i
+}
@@ -3,37 +3,78 @@ package github
 import (
 	"context"
 	"fmt"
-	"strings"
+	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
 	"github.com/google/go-github/v57/github"
 )
 
-func (c *Client) GetNotifications(ctx context.Context, username string) ([]models.Notification, error) {
+// GetNotifications returns the unread notifications of the account. When
+// state is set, only threads updated since the last seen notification are
+// listed, the request is made conditional on the stored Last-Modified
+// header, and state is advanced for the next poll. The poll interval
+// GitHub asks for is recorded in state as well.
+func (c *Client) GetNotifications(ctx context.Context, username string, state *models.AccountState) ([]models.Notification, error) {
 	var notifications []models.Notification
 
-	opts := &github.NotificationListOptions{
-		All:           true,
-		Participating: true,
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+	params := url.Values{}
+	params.Set("all", "true")
+	params.Set("participating", "true")
+	params.Set("per_page", "100")
+	if state != nil && !state.LastNotificationAt.IsZero() {
+		params.Set("since", state.LastNotificationAt.UTC().Format(time.RFC3339))
 	}
 
-	for {
-		ghNotifications, resp, err := c.client.Activity.ListNotifications(ctx, opts)
+	var latest time.Time
+	lastModified := ""
+	for page := 1; ; page++ {
+		params.Set("page", strconv.Itoa(page))
+		req, err := c.client.NewRequest(http.MethodGet, "notifications?"+params.Encode(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		if page == 1 && state != nil && state.LastModified != "" {
+			req.Header.Set("If-Modified-Since", state.LastModified)
+		}
+
+		var ghNotifications []*github.Notification
+		resp, err := c.client.Do(ctx, req, &ghNotifications)
+		if page == 1 && resp != nil && state != nil {
+			if interval, err := strconv.Atoi(resp.Header.Get("X-Poll-Interval")); err == nil && interval > 0 {
+				state.PollInterval = interval
+			}
+		}
+		if resp != nil && resp.StatusCode == http.StatusNotModified {
+			return nil, nil
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to list notifications: %v", err)
 		}
+		if page == 1 {
+			lastModified = resp.Header.Get("Last-Modified")
+		}
 
 		for _, n := range ghNotifications {
+			if n.GetUpdatedAt().After(latest) {
+				latest = n.GetUpdatedAt().Time
+			}
 			if n.GetUnread() {
 				notification := models.Notification{
-					Type:    string(n.GetReason()),
-					Message: fmt.Sprintf("[%s] %s", n.GetRepository().GetFullName(), n.GetSubject().GetTitle()),
-					URL:     n.GetSubject().GetURL(),
+					Type:       string(n.GetReason()),
+					Repo:       n.GetRepository().GetFullName(),
+					Fields:     models.Fields{Title: n.GetSubject().GetTitle()},
+					URL:        n.GetSubject().GetURL(),
+					DedupKey:   dedupKey(n),
+					OccurredAt: n.GetUpdatedAt().Time,
+					EventID:    eventID(n),
+					ThreadID:   n.GetID(),
+					Account:    username,
 				}
+				notification.Message = render.Text(notification)
 				notifications = append(notifications, notification)
 			}
 		}
@@ -41,12 +82,80 @@ func (c *Client) GetNotifications(ctx context.Context, username string) ([]model
 		if resp.NextPage == 0 {
 			break
 		}
-		opts.Page = resp.NextPage
+	}
+
+	if state != nil {
+		if lastModified != "" {
+			state.LastModified = lastModified
+		}
+		if latest.After(state.LastNotificationAt) {
+			state.LastNotificationAt = latest
+		}
 	}
 
 	return notifications, nil
 }
 
+// dedupKey identifies the activity a notification thread reports. The
+// thread is renotified only when GitHub reports new activity on it, not
+// when the message changes. Thread IDs are per account, so the key is
+// built from the subject's activity instead: the same mention seen by two
+// accounts of a chat is sent once. Subjects without a URL fall back to the
+// thread.
+func dedupKey(n *github.Notification) string {
+	if n.GetSubject().GetURL() == "" {
+		return fmt.Sprintf("thread:%s:%d", n.GetID(), n.GetUpdatedAt().Unix())
+	}
+	return fmt.Sprintf("activity:%d", n.GetUpdatedAt().Unix())
+}
+
+// eventID identifies the activity a notification thread reports, like
+// dedupKey by its subject rather than the per-account thread ID. The
+// reason is left out as well: it differs between the accounts seeing the
+// same activity.
+func eventID(n *github.Notification) string {
+	thread := n.GetSubject().GetURL()
+	if thread == "" {
+		thread = "thread:" + n.GetID()
+	}
+	return models.NewEventID("github", thread, n.GetSubject().GetType(), n.GetUpdatedAt().Time)
+}
+
+// MarkThreadRead marks the notification thread with the given ID as read
+// for the token's user.
+func (c *Client) MarkThreadRead(ctx context.Context, threadID string) error {
+	if _, err := c.client.Activity.MarkThreadRead(ctx, threadID); err != nil {
+		return fmt.Errorf("failed to mark thread %s as read: %v", threadID, err)
+	}
+	return nil
+}
+
+// GetRepoEvents returns the pull requests opened or merged, the issues
+// updated and the releases published in the repository within the last
+// day.
+func (c *Client) GetRepoEvents(ctx context.Context, owner, repo string) ([]models.Notification, error) {
+	repository, _, err := c.client.Repositories.Get(ctx, owner, repo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository %s/%s: %v", owner, repo, err)
+	}
+
+	pulls, err := c.checkPullRequests(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests of %s/%s: %v", owner, repo, err)
+	}
+	issues, err := c.checkIssues(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issues of %s/%s: %v", owner, repo, err)
+	}
+	releases, err := c.checkReleases(ctx, repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list releases of %s/%s: %v", owner, repo, err)
+	}
+
+	notifications := append(pulls, issues...)
+	return append(notifications, releases...), nil
+}
+
 func (c *Client) checkPullRequests(ctx context.Context, repo *github.Repository) ([]models.Notification, error) {
 	var notifications []models.Notification
 
@@ -86,9 +195,14 @@ func (c *Client) checkPullRequests(ctx context.Context, repo *github.Repository)
 		if time.Since(pr.GetCreatedAt().Time) <= 24*time.Hour {
 			notification := models.Notification{
 				Type:    "new_pull_request",
-				Message: fmt.Sprintf("[%s] New PR #%d: %s by %s", repo.GetFullName(), pr.GetNumber(), pr.GetTitle(), pr.GetUser().GetLogin()),
+				Repo:    repo.GetFullName(),
+				Fields:  models.Fields{Title: pr.GetTitle(), Number: pr.GetNumber(), Actor: pr.GetUser().GetLogin()},
 				URL:     pr.GetHTMLURL(),
+				EventID: models.NewEventID("github", pr.GetHTMLURL(), "new_pull_request", pr.GetCreatedAt().Time),
+				Labels:  labelNames(pr.Labels),
+				Author:  author(pr.GetUser()),
 			}
+			notification.Message = render.Text(notification)
 			notifications = append(notifications, notification)
 		}
 	}
@@ -99,9 +213,14 @@ func (c *Client) checkPullRequests(ctx context.Context, repo *github.Repository)
 		if pr.GetMerged() && time.Since(pr.GetUpdatedAt().Time) <= 24*time.Hour {
 			notification := models.Notification{
 				Type:    "merged_pull_request",
-				Message: fmt.Sprintf("[%s] Merged PR #%d: %s by %s", repo.GetFullName(), pr.GetNumber(), pr.GetTitle(), pr.GetUser().GetLogin()),
+				Repo:    repo.GetFullName(),
+				Fields:  models.Fields{Title: pr.GetTitle(), Number: pr.GetNumber(), Actor: pr.GetUser().GetLogin()},
 				URL:     pr.GetHTMLURL(),
+				EventID: models.NewEventID("github", pr.GetHTMLURL(), "merged_pull_request", pr.GetMergedAt().Time),
+				Labels:  labelNames(pr.Labels),
+				Author:  author(pr.GetUser()),
 			}
+			notification.Message = render.Text(notification)
 			notifications = append(notifications, notification)
 		}
 	}
@@ -133,42 +252,73 @@ func (c *Client) checkIssues(ctx context.Context, repo *github.Repository) ([]mo
 
 		notification := models.Notification{
 			Type:    "issue",
-			Message: fmt.Sprintf("[%s] Issue #%d: %s", repo.GetFullName(), issue.GetNumber(), issue.GetTitle()),
+			Repo:    repo.GetFullName(),
+			Fields:  models.Fields{Title: issue.GetTitle(), Number: issue.GetNumber()},
 			URL:     issue.GetHTMLURL(),
+			EventID: models.NewEventID("github", issue.GetHTMLURL(), "issue", issue.GetUpdatedAt().Time),
+			Labels:  labelNames(issue.Labels),
+			Author:  author(issue.GetUser()),
 		}
+		notification.Message = render.Text(notification)
 		notifications = append(notifications, notification)
 	}
 
 	return notifications, nil
 }
 
+func labelNames(labels []*github.Label) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.GetName())
+	}
+	return names
+}
+
+// author returns the GitHub user as the author of a notification. Apps
+// such as Dependabot act as users of type Bot.
+func author(user *github.User) models.Author {
+	return models.Author{Login: user.GetLogin(), IsBot: user.GetType() == "Bot"}
+}
+
 func (c *Client) checkReleases(ctx context.Context, repo *github.Repository) ([]models.Notification, error) {
 	var notifications []models.Notification
 
+	// A few releases more than the day's are listed, so the newest of
+	// them has the previous release to compare its version with.
 	opts := &github.ListOptions{
-		PerPage: 5,
+		PerPage: 10,
 	}
 
-	releases, _, err := c.client.Repositories.ListReleases(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opts)
+	listed, _, err := c.client.Repositories.ListReleases(ctx, repo.GetOwner().GetLogin(), repo.GetName(), opts)
 	if err != nil {
 		return nil, err
 	}
+	var releases []*github.RepositoryRelease
+	for _, release := range listed {
+		if !release.GetDraft() {
+			releases = append(releases, release)
+		}
+	}
 
-	for _, release := range releases {
+	for i, release := range releases {
 		if time.Since(release.GetCreatedAt().Time) > 24*time.Hour {
 			continue
 		}
 
-		message := fmt.Sprintf("[%s] New release: %s", repo.GetFullName(), release.GetTagName())
-		if notes := release.GetBody(); notes != "" {
-			message += "\n" + strings.Split(notes, "\n")[0] // First line of release notes
+		fields := models.Fields{Version: release.GetTagName(), Details: firstParagraph(release.GetBody())}
+		if i+1 < len(releases) {
+			fields.Delta = releaseDelta(releases[i+1].GetTagName(), release.GetTagName())
 		}
 
 		notification := models.Notification{
 			Type:    "release",
-			Message: message,
+			Repo:    repo.GetFullName(),
+			Fields:  fields,
 			URL:     release.GetHTMLURL(),
+			EventID: models.NewEventID("github", release.GetHTMLURL(), "release", release.GetCreatedAt().Time),
+			Author:  author(release.GetAuthor()),
 		}
+		notification.Message = render.Text(notification)
 		notifications = append(notifications, notification)
 	}
 
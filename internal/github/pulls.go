@@ -0,0 +1,68 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetPullRequests returns the open pull requests of the repository and
+// those closed since the given time.
+func (c *Client) GetPullRequests(ctx context.Context, owner, repo string, closedSince time.Time) ([]models.PullRequest, error) {
+	var pulls []models.PullRequest
+
+	opts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list open pull requests of %s/%s: %v", owner, repo, err)
+		}
+		for _, pull := range page {
+			pulls = append(pulls, pullRequest(owner, repo, pull))
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	// Closed pull requests are listed most recently updated first, so
+	// paging stops at the first page reaching back before closedSince.
+	opts = &github.PullRequestListOptions{State: "closed", Sort: "updated", Direction: "desc", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.PullRequests.List(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list closed pull requests of %s/%s: %v", owner, repo, err)
+		}
+		older := false
+		for _, pull := range page {
+			if pull.GetClosedAt().Before(closedSince) {
+				older = older || pull.GetUpdatedAt().Before(closedSince)
+				continue
+			}
+			pulls = append(pulls, pullRequest(owner, repo, pull))
+		}
+		if older || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return pulls, nil
+}
+
+func pullRequest(owner, repo string, pull *github.PullRequest) models.PullRequest {
+	return models.PullRequest{
+		Repo:      owner + "/" + repo,
+		Number:    pull.GetNumber(),
+		Title:     pull.GetTitle(),
+		URL:       pull.GetHTMLURL(),
+		Author:    pull.GetUser().GetLogin(),
+		Open:      pull.GetState() == "open",
+		UpdatedAt: pull.GetUpdatedAt().Time,
+		MergedAt:  pull.GetMergedAt().Time,
+	}
+}
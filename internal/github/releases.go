@@ -0,0 +1,120 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+	"golang.org/x/mod/semver"
+)
+
+// releaseNotesLimit is how many characters of the release notes are put
+// in a release notification.
+const releaseNotesLimit = 300
+
+// GetReleases returns the releases of the repository published since the
+// given time, oldest first. Drafts are left out.
+func (c *Client) GetReleases(ctx context.Context, owner, repo string, since time.Time) ([]models.Release, error) {
+	var releases []models.Release
+
+	// Releases are listed newest first, so paging stops at the first page
+	// reaching back before since.
+	opts := &github.ListOptions{PerPage: 30}
+	for {
+		page, resp, err := c.client.Repositories.ListReleases(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list releases of %s/%s: %v", owner, repo, err)
+		}
+		older := false
+		for _, release := range page {
+			if release.GetDraft() {
+				continue
+			}
+			if release.GetPublishedAt().Before(since) {
+				older = true
+				continue
+			}
+			releases = append(releases, models.Release{
+				Repo:        owner + "/" + repo,
+				Tag:         release.GetTagName(),
+				Name:        release.GetName(),
+				URL:         release.GetHTMLURL(),
+				Author:      release.GetAuthor().GetLogin(),
+				PublishedAt: release.GetPublishedAt().Time,
+			})
+		}
+		if older || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	sort.Slice(releases, func(i, j int) bool { return releases[i].PublishedAt.Before(releases[j].PublishedAt) })
+	return releases, nil
+}
+
+// releaseDelta describes a release as a major, minor or patch update of
+// the previous one, such as "minor update from v1.2.4", for tags that are
+// semantic versions with or without the leading v. It is empty otherwise.
+// The release of the version a pre-release led up to, such as v2.0.0 after
+// v2.0.0-rc.1, is a stable release rather than an update.
+func releaseDelta(previous, tag string) string {
+	from, to := semverTag(previous), semverTag(tag)
+	if !semver.IsValid(from) || !semver.IsValid(to) || semver.Compare(to, from) <= 0 {
+		return ""
+	}
+
+	var kind string
+	switch {
+	case semver.Prerelease(to) != "":
+		kind = "pre-release"
+	case semver.Prerelease(from) != "" && strings.TrimSuffix(semver.Canonical(from), semver.Prerelease(from)) == semver.Canonical(to):
+		kind = "stable release"
+	case semver.Major(to) != semver.Major(from):
+		kind = "major update"
+	case semver.MajorMinor(to) != semver.MajorMinor(from):
+		kind = "minor update"
+	default:
+		kind = "patch update"
+	}
+	return fmt.Sprintf("%s from %s", kind, previous)
+}
+
+func semverTag(tag string) string {
+	if !strings.HasPrefix(tag, "v") {
+		return "v" + tag
+	}
+	return tag
+}
+
+// firstParagraph returns the first paragraph of release notes, skipping
+// the headings such as "## What's Changed" that generated notes start
+// with, cut to releaseNotesLimit characters.
+func firstParagraph(notes string) string {
+	notes = strings.ReplaceAll(notes, "\r\n", "\n")
+	for _, paragraph := range strings.Split(notes, "\n\n") {
+		var lines []string
+		for _, line := range strings.Split(paragraph, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+
+		text := strings.Join(lines, " ")
+		if utf8.RuneCountInString(text) > releaseNotesLimit {
+			text = string([]rune(text)[:releaseNotesLimit-1]) + "…"
+		}
+		return text
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+package github
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReleaseDelta(t *testing.T) {
+	tests := []struct {
+		previous, tag string
+		want          string
+	}{
+		{"v1.2.4", "v2.0.0", "major update from v1.2.4"},
+		{"v1.2.4", "v1.3.0", "minor update from v1.2.4"},
+		{"v1.2.4", "v1.2.5", "patch update from v1.2.4"},
+		{"1.2.4", "1.3.0", "minor update from 1.2.4"},
+		{"1.2.4", "v1.2.5", "patch update from 1.2.4"},
+		{"v1.2.4", "v2.0.0-rc.1", "pre-release from v1.2.4"},
+		{"v2.0.0-rc.1", "v2.0.0", "stable release from v2.0.0-rc.1"},
+		{"2.0.0-rc.1", "2.0.0+build.5", "stable release from 2.0.0-rc.1"},
+		{"v2.0.0-rc.1", "v2.0.1", "patch update from v2.0.0-rc.1"},
+		{"v2.0.0-rc.1", "v2.0.0-rc.2", "pre-release from v2.0.0-rc.1"},
+		// Older or equal tags, as when a backport is released, and tags
+		// that are not semantic versions get no delta.
+		{"v1.3.0", "v1.2.5", ""},
+		{"v1.2.4", "v1.2.4", ""},
+		{"", "v1.0.0", ""},
+		{"v1.2.4", "release-2024-06", ""},
+		{"nightly", "v1.3.0", ""},
+		{"v1.2", "v1.3", "minor update from v1.2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.previous+" to "+tt.tag, func(t *testing.T) {
+			if got := releaseDelta(tt.previous, tt.tag); got != tt.want {
+				t.Errorf("releaseDelta(%q, %q) = %q, want %q", tt.previous, tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFirstParagraph(t *testing.T) {
+	long := strings.Repeat("é", releaseNotesLimit+10)
+	tests := []struct {
+		name  string
+		notes string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"headings only", "## What's Changed\n\n### Fixes\n", ""},
+		{"plain", "Faster startup.\n\nOther changes.", "Faster startup."},
+		{"lines joined", "Faster startup\nand smaller images.", "Faster startup and smaller images."},
+		{"after headings", "## What's Changed\r\n\r\n* Fix crash by @alice in #12\r\n* Add flag by @bob in #13\r\n\r\nFull changelog", "* Fix crash by @alice in #12 * Add flag by @bob in #13"},
+		{"heading in paragraph", "# v1.2.0\nFaster startup.", "Faster startup."},
+		{"cut", long, strings.Repeat("é", releaseNotesLimit-1) + "…"},
+		{"at limit", long[:2*releaseNotesLimit], long[:2*releaseNotesLimit]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstParagraph(tt.notes); got != tt.want {
+				t.Errorf("firstParagraph(%q) = %q, want %q", tt.notes, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// ReplyStatus is whether a user replied on an issue or pull request.
+type ReplyStatus struct {
+	// RepliedAt is when the user first commented, or reviewed a pull
+	// request, after being mentioned, zero if they did not yet.
+	RepliedAt time.Time
+	Open      bool
+}
+
+// GetReplyStatus reports whether username replied on the issue or pull
+// request with the given API URL since being mentioned there at since.
+func (c *Client) GetReplyStatus(ctx context.Context, subjectURL, username string, since time.Time) (ReplyStatus, error) {
+	key := fmt.Sprintf("replies:%s:%s:%d", subjectURL, strings.ToLower(username), since.Unix())
+	return cachedDetail(ctx, c, key, func() (ReplyStatus, error) {
+		return c.getReplyStatus(ctx, subjectURL, username, since)
+	})
+}
+
+func (c *Client) getReplyStatus(ctx context.Context, subjectURL, username string, since time.Time) (ReplyStatus, error) {
+	var status ReplyStatus
+
+	owner, repo, kind, number, err := parseSubjectURL(subjectURL)
+	if err != nil {
+		return status, err
+	}
+
+	// Pull requests are issues too, as far as their state and
+	// conversation are concerned.
+	issue, _, err := c.client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return status, fmt.Errorf("failed to get issue: %v", err)
+	}
+	status.Open = issue.GetState() == "open"
+
+	replied := func(login string, at time.Time) {
+		if strings.EqualFold(login, username) && !at.Before(since) && (status.RepliedAt.IsZero() || at.Before(status.RepliedAt)) {
+			status.RepliedAt = at
+		}
+	}
+
+	commentOpts := &github.IssueListCommentsOptions{Since: &since, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.client.Issues.ListComments(ctx, owner, repo, number, commentOpts)
+		if err != nil {
+			return status, fmt.Errorf("failed to list comments: %v", err)
+		}
+		for _, comment := range comments {
+			replied(comment.GetUser().GetLogin(), comment.GetCreatedAt().Time)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		commentOpts.Page = resp.NextPage
+	}
+
+	if kind == "pulls" {
+		reviewOpts := &github.ListOptions{PerPage: 100}
+		for {
+			reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, reviewOpts)
+			if err != nil {
+				return status, fmt.Errorf("failed to list reviews: %v", err)
+			}
+			for _, review := range reviews {
+				if review.GetState() != "PENDING" {
+					replied(review.GetUser().GetLogin(), review.GetSubmittedAt().Time)
+				}
+			}
+			if resp.NextPage == 0 {
+				break
+			}
+			reviewOpts.Page = resp.NextPage
+		}
+	}
+
+	return status, nil
+}
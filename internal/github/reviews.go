@@ -0,0 +1,116 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v57/github"
+)
+
+// ReviewStatus is where a request for a user to review a pull request
+// stands.
+type ReviewStatus struct {
+	// ReviewedAt is when the user first submitted a review after being
+	// requested, zero if they did not yet.
+	ReviewedAt time.Time
+	// Requested reports whether the user is still asked for a review. A
+	// request through a team counts while any team is requested.
+	Requested bool
+	Open      bool
+}
+
+// GetReviewStatus returns the status of the request for username to review
+// the pull request with the given API URL, made at requestedAt.
+func (c *Client) GetReviewStatus(ctx context.Context, pullURL, username string, requestedAt time.Time) (ReviewStatus, error) {
+	key := fmt.Sprintf("reviews:%s:%s:%d", pullURL, strings.ToLower(username), requestedAt.Unix())
+	return cachedDetail(ctx, c, key, func() (ReviewStatus, error) {
+		return c.getReviewStatus(ctx, pullURL, username, requestedAt)
+	})
+}
+
+func (c *Client) getReviewStatus(ctx context.Context, pullURL, username string, requestedAt time.Time) (ReviewStatus, error) {
+	var status ReviewStatus
+
+	owner, repo, kind, number, err := parseSubjectURL(pullURL)
+	if err != nil {
+		return status, err
+	}
+	if kind != "pulls" {
+		return status, fmt.Errorf("%s is not a pull request", pullURL)
+	}
+
+	pull, _, err := c.client.PullRequests.Get(ctx, owner, repo, number)
+	if err != nil {
+		return status, fmt.Errorf("failed to get pull request: %v", err)
+	}
+	status.Open = pull.GetState() == "open"
+	status.Requested = len(pull.RequestedTeams) > 0
+	for _, reviewer := range pull.RequestedReviewers {
+		if strings.EqualFold(reviewer.GetLogin(), username) {
+			status.Requested = true
+		}
+	}
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := c.client.PullRequests.ListReviews(ctx, owner, repo, number, opts)
+		if err != nil {
+			return status, fmt.Errorf("failed to list reviews: %v", err)
+		}
+		for _, review := range reviews {
+			submittedAt := review.GetSubmittedAt().Time
+			if !strings.EqualFold(review.GetUser().GetLogin(), username) || review.GetState() == "PENDING" || submittedAt.Before(requestedAt) {
+				continue
+			}
+			if status.ReviewedAt.IsZero() || submittedAt.Before(status.ReviewedAt) {
+				status.ReviewedAt = submittedAt
+			}
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return status, nil
+}
+
+// SubmitReview reviews the pull request with the given API URL. The event
+// is APPROVE, COMMENT or REQUEST_CHANGES, the last two needing a body.
+func (c *Client) SubmitReview(ctx context.Context, pullURL, event, body string) error {
+	owner, repo, kind, number, err := parseSubjectURL(pullURL)
+	if err != nil {
+		return err
+	}
+	if kind != "pulls" {
+		return fmt.Errorf("%s is not a pull request", pullURL)
+	}
+
+	review := &github.PullRequestReviewRequest{Event: github.String(event)}
+	if body != "" {
+		review.Body = github.String(body)
+	}
+	if _, _, err := c.client.PullRequests.CreateReview(ctx, owner, repo, number, review); err != nil {
+		return fmt.Errorf("failed to review %s/%s#%d: %v", owner, repo, number, err)
+	}
+	return nil
+}
+
+// parseSubjectURL splits the API URL of an issue or pull request such as
+// https://api.github.com/repos/acme/api/pulls/42, where kind is issues or
+// pulls.
+func parseSubjectURL(subjectURL string) (owner, repo, kind string, number int, err error) {
+	_, path, ok := strings.Cut(subjectURL, "/repos/")
+	parts := strings.Split(path, "/")
+	if !ok || len(parts) != 4 || (parts[2] != "pulls" && parts[2] != "issues") {
+		return "", "", "", 0, fmt.Errorf("invalid issue or pull request URL %q", subjectURL)
+	}
+	number, err = strconv.Atoi(parts[3])
+	if err != nil {
+		return "", "", "", 0, fmt.Errorf("invalid issue or pull request URL %q", subjectURL)
+	}
+	return parts[0], parts[1], parts[2], number, nil
+}
@@ -0,0 +1,136 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetSecurityAlerts returns the open Dependabot and code scanning alerts
+// created after since on the repositories the token's user owns. Reading
+// them needs the security_events scope, or for fine-grained tokens read
+// access to Dependabot and code scanning alerts. Repositories without the
+// alerts enabled, or whose alerts the token may not read, are skipped.
+func (c *Client) GetSecurityAlerts(ctx context.Context, since time.Time) ([]models.SecurityAlert, error) {
+	var repos []*github.Repository
+	opts := &github.RepositoryListByAuthenticatedUserOptions{Affiliation: "owner", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := c.client.Repositories.ListByAuthenticatedUser(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories: %v", err)
+		}
+		repos = append(repos, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var alerts []models.SecurityAlert
+	for _, repo := range repos {
+		if repo.GetArchived() {
+			continue
+		}
+		owner, name := repo.GetOwner().GetLogin(), repo.GetName()
+
+		found, err := c.dependabotAlerts(ctx, owner, name, since)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, found...)
+
+		found, err = c.codeScanningAlerts(ctx, owner, name, since)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, found...)
+	}
+	return alerts, nil
+}
+
+// dependabotAlerts returns the open Dependabot alerts of the repository
+// created after since. The alerts are listed newest first, so the first
+// page holds the new ones unless a hundred were opened at once.
+func (c *Client) dependabotAlerts(ctx context.Context, owner, repo string, since time.Time) ([]models.SecurityAlert, error) {
+	opts := &github.ListAlertsOptions{
+		State:       github.String("open"),
+		Sort:        github.String("created"),
+		Direction:   github.String("desc"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	found, resp, err := c.client.Dependabot.ListRepoAlerts(ctx, owner, repo, opts)
+	if alertsUnavailable(resp) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Dependabot alerts of %s/%s: %v", owner, repo, err)
+	}
+
+	var alerts []models.SecurityAlert
+	for _, alert := range found {
+		if !alert.GetCreatedAt().After(since) {
+			continue
+		}
+		advisory := alert.GetSecurityAdvisory()
+		pkg := alert.GetDependency().GetPackage()
+		alerts = append(alerts, models.SecurityAlert{
+			Kind:      models.SecurityAlertDependabot,
+			Repo:      owner + "/" + repo,
+			Number:    alert.GetNumber(),
+			Severity:  advisory.GetSeverity(),
+			Package:   pkg.GetName(),
+			Ecosystem: pkg.GetEcosystem(),
+			Summary:   advisory.GetSummary(),
+			URL:       alert.GetHTMLURL(),
+			CreatedAt: alert.GetCreatedAt().Time,
+		})
+	}
+	return alerts, nil
+}
+
+// codeScanningAlerts returns the open code scanning alerts of the
+// repository created after since, listed newest first like Dependabot's.
+func (c *Client) codeScanningAlerts(ctx context.Context, owner, repo string, since time.Time) ([]models.SecurityAlert, error) {
+	opts := &github.AlertListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	found, resp, err := c.client.CodeScanning.ListAlertsForRepo(ctx, owner, repo, opts)
+	if alertsUnavailable(resp) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list code scanning alerts of %s/%s: %v", owner, repo, err)
+	}
+
+	var alerts []models.SecurityAlert
+	for _, alert := range found {
+		if !alert.GetCreatedAt().After(since) {
+			continue
+		}
+		rule := alert.GetRule()
+		severity := rule.GetSecuritySeverityLevel()
+		if severity == "" {
+			severity = rule.GetSeverity()
+		}
+		alerts = append(alerts, models.SecurityAlert{
+			Kind:      models.SecurityAlertCodeScanning,
+			Repo:      owner + "/" + repo,
+			Number:    alert.GetNumber(),
+			Severity:  severity,
+			Summary:   rule.GetDescription(),
+			Path:      alert.GetMostRecentInstance().GetLocation().GetPath(),
+			URL:       alert.GetHTMLURL(),
+			CreatedAt: alert.GetCreatedAt().Time,
+		})
+	}
+	return alerts, nil
+}
+
+// alertsUnavailable reports whether GitHub answered that the repository's
+// alerts are disabled or not readable with the token, which it does with
+// 403 or 404.
+func alertsUnavailable(resp *github.Response) bool {
+	return resp != nil && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound)
+}
@@ -0,0 +1,178 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// repoSizeLimitGB is the size GitHub strongly recommends repositories stay
+// below. Pushes are not rejected beyond it, but clones and fetches slow
+// down and GitHub may ask to shrink the repository.
+const repoSizeLimitGB = 5
+
+// includedLFSGB is the Git LFS storage, and the Git LFS bandwidth per
+// month, each plan includes.
+var includedLFSGB = map[string]float64{
+	"free":          10,
+	"pro":           10,
+	"team":          250,
+	"business":      250,
+	"business_plus": 250,
+	"enterprise":    250,
+}
+
+// GetStorageQuotas returns the size of each repository the user or
+// organization owns against the size GitHub recommends, and the Git LFS
+// storage and bandwidth used this month against what the plan includes.
+// LFS usage is read from the billing usage report, which only accounts on
+// GitHub's enhanced billing platform have; for others it is left out.
+func (c *Client) GetStorageQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error) {
+	quotas, err := c.repoSizeQuotas(ctx, owner, org)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := c.plan(ctx, owner, org)
+	if err != nil {
+		return nil, err
+	}
+	included, ok := includedLFSGB[plan]
+	if !ok {
+		return quotas, nil
+	}
+	storage, bandwidth, found, err := c.lfsUsage(ctx, owner, org, time.Now().UTC())
+	if err != nil || !found {
+		return quotas, err
+	}
+	billingURL := billingSummaryURL(owner, org)
+	daysLeft := daysLeftInMonth(time.Now().UTC())
+	return append(quotas,
+		models.Quota{
+			Owner:    owner,
+			Name:     "Git LFS storage",
+			Used:     storage,
+			Included: included,
+			Unit:     "GB",
+			URL:      billingURL,
+			DaysLeft: daysLeft,
+		},
+		models.Quota{
+			Owner:    owner,
+			Name:     "Git LFS bandwidth",
+			Used:     bandwidth,
+			Included: included,
+			Unit:     "GB",
+			URL:      billingURL,
+			DaysLeft: daysLeft,
+		},
+	), nil
+}
+
+// repoSizeQuotas returns the sizes of the owner's repositories, forks
+// included, as quotas of the repository's full name.
+func (c *Client) repoSizeQuotas(ctx context.Context, owner string, org bool) ([]models.Quota, error) {
+	var repos []*github.Repository
+	listOpts := github.ListOptions{PerPage: 100}
+	for {
+		var page []*github.Repository
+		var resp *github.Response
+		var err error
+		if org {
+			opts := &github.RepositoryListByOrgOptions{Type: "all", ListOptions: listOpts}
+			page, resp, err = c.client.Repositories.ListByOrg(ctx, owner, opts)
+		} else {
+			opts := &github.RepositoryListByAuthenticatedUserOptions{Affiliation: "owner", ListOptions: listOpts}
+			page, resp, err = c.client.Repositories.ListByAuthenticatedUser(ctx, opts)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of %s: %v", owner, err)
+		}
+		repos = append(repos, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		listOpts.Page = resp.NextPage
+	}
+
+	var quotas []models.Quota
+	for _, repo := range repos {
+		quotas = append(quotas, models.Quota{
+			Owner: repo.GetFullName(),
+			Name:  "Repository size",
+			// GitHub reports the size in kilobytes.
+			Used:     float64(repo.GetSize()) / (1024 * 1024),
+			Included: repoSizeLimitGB,
+			Unit:     "GB",
+			URL:      repo.GetHTMLURL(),
+			DaysLeft: -1,
+		})
+	}
+	return quotas, nil
+}
+
+type billingUsageReport struct {
+	UsageItems []struct {
+		Product  string  `json:"product"`
+		SKU      string  `json:"sku"`
+		Quantity float64 `json:"quantity"`
+		UnitType string  `json:"unitType"`
+	} `json:"usageItems"`
+}
+
+// lfsUsage returns the Git LFS storage, averaged over the month so far,
+// and the bandwidth of the month, in gigabytes. found is false when the
+// owner has no billing usage report.
+func (c *Client) lfsUsage(ctx context.Context, owner string, org bool, now time.Time) (storage, bandwidth float64, found bool, err error) {
+	path := fmt.Sprintf("users/%s/settings/billing/usage?year=%d&month=%d", owner, now.Year(), now.Month())
+	if org {
+		path = fmt.Sprintf("organizations/%s/settings/billing/usage?year=%d&month=%d", owner, now.Year(), now.Month())
+	}
+	req, err := c.client.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to create billing usage request: %v", err)
+	}
+	var report billingUsageReport
+	resp, err := c.client.Do(ctx, req, &report)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("failed to get billing usage of %s: %v", owner, err)
+	}
+
+	// Storage is billed in gigabyte-hours, so the month's hours so far
+	// turn it into the average stored.
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	hours := max(now.Sub(monthStart).Hours(), 1)
+	for _, item := range report.UsageItems {
+		product := strings.ReplaceAll(strings.ToLower(item.Product), "_", "")
+		if product != "gitlfs" {
+			continue
+		}
+		sku := strings.ToLower(item.SKU)
+		switch {
+		case strings.Contains(sku, "storage"):
+			if strings.EqualFold(item.UnitType, "GigabyteHours") {
+				storage += item.Quantity / hours
+			} else {
+				storage += item.Quantity
+			}
+		case strings.Contains(sku, "bandwidth"):
+			bandwidth += item.Quantity
+		}
+	}
+	return storage, bandwidth, true, nil
+}
+
+// daysLeftInMonth returns the days left until the LFS usage resets at the
+// start of the next month.
+func daysLeftInMonth(now time.Time) int {
+	next := time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	return int(next.Sub(now).Hours() / 24)
+}
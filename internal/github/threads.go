@@ -0,0 +1,57 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/google/go-github/v57/github"
+)
+
+// GetThread returns the conversation on the issue or pull request with the
+// given API URL. Its comments are only listed when there are at least
+// minComments, saving the calls for threads too short to summarize. Like
+// the reply and review statuses, threads are cached for detailTTL.
+func (c *Client) GetThread(ctx context.Context, subjectURL string, minComments int) (models.Thread, error) {
+	key := fmt.Sprintf("thread:%s:%d", subjectURL, minComments)
+	return cachedDetail(ctx, c, key, func() (models.Thread, error) {
+		return c.getThread(ctx, subjectURL, minComments)
+	})
+}
+
+func (c *Client) getThread(ctx context.Context, subjectURL string, minComments int) (models.Thread, error) {
+	var thread models.Thread
+
+	owner, repo, _, number, err := parseSubjectURL(subjectURL)
+	if err != nil {
+		return thread, err
+	}
+
+	issue, _, err := c.client.Issues.Get(ctx, owner, repo, number)
+	if err != nil {
+		return thread, fmt.Errorf("failed to get issue: %v", err)
+	}
+	thread.Title = issue.GetTitle()
+	thread.Body = issue.GetBody()
+	thread.CommentCount = issue.GetComments()
+	if thread.CommentCount < minComments {
+		return thread, nil
+	}
+
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return thread, fmt.Errorf("failed to list comments: %v", err)
+		}
+		for _, comment := range comments {
+			thread.Comments = append(thread.Comments, models.Comment{Author: comment.GetUser().GetLogin(), Body: comment.GetBody()})
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return thread, nil
+}
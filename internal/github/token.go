@@ -0,0 +1,65 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// expirationLayouts are the formats GitHub uses in the
+// GitHub-Authentication-Token-Expiration header.
+var expirationLayouts = []string{
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+}
+
+// GetTokenMetadata returns the scopes and expiry date GitHub reports for the
+// client's token. Fine-grained tokens have no scopes, and tokens without an
+// expiry date have a zero ExpiresAt.
+func (c *Client) GetTokenMetadata(ctx context.Context) (models.GitHubAccountMetadata, error) {
+	_, metadata, err := c.GetAuthenticatedUser(ctx)
+	return metadata, err
+}
+
+// GetAuthenticatedUser returns the login of the token's owner along with
+// the token's metadata.
+func (c *Client) GetAuthenticatedUser(ctx context.Context) (string, models.GitHubAccountMetadata, error) {
+	var metadata models.GitHubAccountMetadata
+
+	user, resp, err := c.client.Users.Get(ctx, "")
+	if err != nil {
+		return "", metadata, fmt.Errorf("failed to get authenticated user: %v", err)
+	}
+
+	for _, scope := range strings.Split(resp.Header.Get("X-OAuth-Scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			metadata.Scopes = append(metadata.Scopes, scope)
+		}
+	}
+
+	if expiration := resp.Header.Get("GitHub-Authentication-Token-Expiration"); expiration != "" {
+		for _, layout := range expirationLayouts {
+			if expiresAt, err := time.Parse(layout, expiration); err == nil {
+				metadata.ExpiresAt = expiresAt
+				break
+			}
+		}
+	}
+
+	return user.GetLogin(), metadata, nil
+}
+
+// GetRateLimit returns the core API rate limit of the client's token and
+// its size. Checking it does not count against the limit.
+func (c *Client) GetRateLimit(ctx context.Context) (RateLimit, int, error) {
+	limits, _, err := c.client.RateLimit.Get(ctx)
+	if err != nil {
+		return RateLimit{}, 0, fmt.Errorf("failed to get rate limit: %v", err)
+	}
+
+	core := limits.GetCore()
+	return RateLimit{Remaining: core.Remaining, Reset: core.Reset.Time}, core.Limit, nil
+}
@@ -0,0 +1,123 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Client struct {
+	baseURL  string
+	email    string
+	apiToken string
+	http     *http.Client
+}
+
+type Issue struct {
+	Key string
+	URL string
+}
+
+type createIssueRequest struct {
+	Fields createIssueFields `json:"fields"`
+}
+
+type createIssueFields struct {
+	Project     keyField  `json:"project"`
+	Summary     string    `json:"summary"`
+	Description string    `json:"description"`
+	IssueType   nameField `json:"issuetype"`
+}
+
+type keyField struct {
+	Key string `json:"key"`
+}
+
+type nameField struct {
+	Name string `json:"name"`
+}
+
+type createIssueResponse struct {
+	Key string `json:"key"`
+}
+
+type errorResponse struct {
+	ErrorMessages []string          `json:"errorMessages"`
+	Errors        map[string]string `json:"errors"`
+}
+
+func NewClient(baseURL, email, apiToken string) *Client {
+	return &Client{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		email:    email,
+		apiToken: apiToken,
+		http:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CreateIssue files a new issue through the Jira REST API v2, which accepts
+// plain-text descriptions unlike v3's document format.
+func (c *Client) CreateIssue(ctx context.Context, projectKey, issueType, summary, description string) (*Issue, error) {
+	body, err := json.Marshal(createIssueRequest{
+		Fields: createIssueFields{
+			Project:     keyField{Key: projectKey},
+			Summary:     summary,
+			Description: description,
+			IssueType:   nameField{Name: issueType},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode issue: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/rest/api/2/issue", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.SetBasicAuth(c.email, c.apiToken)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Jira issue: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Jira response: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("Jira returned %s: %s", resp.Status, errorMessage(respBody))
+	}
+
+	var created createIssueResponse
+	if err := json.Unmarshal(respBody, &created); err != nil {
+		return nil, fmt.Errorf("failed to decode Jira response: %v", err)
+	}
+
+	return &Issue{
+		Key: created.Key,
+		URL: fmt.Sprintf("%s/browse/%s", c.baseURL, created.Key),
+	}, nil
+}
+
+func errorMessage(body []byte) string {
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return strings.TrimSpace(string(body))
+	}
+
+	messages := errResp.ErrorMessages
+	for field, message := range errResp.Errors {
+		messages = append(messages, fmt.Sprintf("%s: %s", field, message))
+	}
+	return strings.Join(messages, "; ")
+}
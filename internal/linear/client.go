@@ -0,0 +1,117 @@
+package linear
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const apiURL = "https://api.linear.app/graphql"
+
+const issueCreateMutation = `mutation IssueCreate($input: IssueCreateInput!) {
+  issueCreate(input: $input) {
+    success
+    issue { identifier url }
+  }
+}`
+
+type Client struct {
+	apiKey string
+	http   *http.Client
+}
+
+type Issue struct {
+	Identifier string
+	URL        string
+}
+
+type graphqlRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type issueCreateResponse struct {
+	Data struct {
+		IssueCreate struct {
+			Success bool `json:"success"`
+			Issue   struct {
+				Identifier string `json:"identifier"`
+				URL        string `json:"url"`
+			} `json:"issue"`
+		} `json:"issueCreate"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func NewClient(apiKey string) *Client {
+	return &Client{
+		apiKey: apiKey,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *Client) CreateIssue(ctx context.Context, teamID, projectID, title, description string) (*Issue, error) {
+	input := map[string]interface{}{
+		"teamId":      teamID,
+		"title":       title,
+		"description": description,
+	}
+	if projectID != "" {
+		input["projectId"] = projectID
+	}
+
+	body, err := json.Marshal(graphqlRequest{
+		Query:     issueCreateMutation,
+		Variables: map[string]interface{}{"input": input},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Linear issue: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Linear response: %v", err)
+	}
+
+	var result issueCreateResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, fmt.Errorf("Linear returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	if len(result.Errors) > 0 {
+		messages := make([]string, 0, len(result.Errors))
+		for _, e := range result.Errors {
+			messages = append(messages, e.Message)
+		}
+		return nil, fmt.Errorf("Linear returned errors: %s", strings.Join(messages, "; "))
+	}
+
+	if !result.Data.IssueCreate.Success {
+		return nil, fmt.Errorf("Linear did not create the issue")
+	}
+
+	return &Issue{
+		Identifier: result.Data.IssueCreate.Issue.Identifier,
+		URL:        result.Data.IssueCreate.Issue.URL,
+	}, nil
+}
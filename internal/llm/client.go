@@ -0,0 +1,116 @@
+// Package llm summarizes text with a language model behind an
+// OpenAI-compatible chat completions API, such as OpenAI's own, Azure
+// OpenAI, or a local server like Ollama or vLLM.
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/redact"
+)
+
+const summaryPrompt = "You summarize GitHub issue and pull request discussions for a busy developer. " +
+	"Reply with 2 to 3 plain sentences on what the thread is about, where the discussion stands and what is being asked for. " +
+	"Do not use markdown, lists or headings."
+
+type Client struct {
+	baseURL string
+	apiKey  string
+	model   string
+	http    *http.Client
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+	MaxTokens   int           `json:"max_tokens"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewClient returns a client of the API at baseURL, such as
+// https://api.openai.com/v1, using the model. The API key may be empty for
+// servers that do not need one.
+func NewClient(baseURL, apiKey, model string) *Client {
+	redact.Add(apiKey)
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+		http:    &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Summarize returns a summary of the thread transcript in 2 to 3
+// sentences.
+func (c *Client) Summarize(ctx context.Context, transcript string) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model: c.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: summaryPrompt},
+			{Role: "user", Content: transcript},
+		},
+		Temperature: 0.2,
+		MaxTokens:   200,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request summary: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read summary response: %v", err)
+	}
+
+	var result chatResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("summary endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	if result.Error != nil {
+		return "", fmt.Errorf("summary endpoint returned an error: %s", result.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK || len(result.Choices) == 0 {
+		return "", fmt.Errorf("summary endpoint returned %s without a summary", resp.Status)
+	}
+
+	summary := strings.Join(strings.Fields(result.Choices[0].Message.Content), " ")
+	if summary == "" {
+		return "", fmt.Errorf("summary endpoint returned an empty summary")
+	}
+	return summary, nil
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	StoreDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "repository_monitor_store_duration_seconds",
+		Help:    "Latency of store operations.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"method"})
+
+	StoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_monitor_store_errors_total",
+		Help: "Store operations that returned an error.",
+	}, []string{"method"})
+
+	StoreRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_monitor_store_rows_total",
+		Help: "Rows returned by store operations that list records.",
+	}, []string{"method"})
+
+	Leader = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "repository_monitor_leader",
+		Help: "Whether this instance runs the job, 1 for the elected instance and 0 for standbys.",
+	}, []string{"job"})
+
+	DeliveryLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "repository_monitor_delivery_latency_seconds",
+		Help:    "Time from the activity on the source to the notification's delivery to Telegram.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 16),
+	}, []string{"type"})
+
+	QueueLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "repository_monitor_queue_latency_seconds",
+		Help:    "Time from queueing a notification in the outbox to its delivery to Telegram.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 16),
+	}, []string{"type"})
+
+	OutboxBacklog = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "repository_monitor_outbox_backlog",
+		Help: "Notifications waiting in the outbox for delivery.",
+	})
+
+	Shedding = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "repository_monitor_shedding",
+		Help: "Whether delivery sheds load because of the backlog, 1 while it does.",
+	})
+
+	SinkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_monitor_sink_errors_total",
+		Help: "Notifications a sink failed to deliver, which are not retried for it.",
+	}, []string{"sink"})
+
+	WorkerPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "repository_monitor_worker_panics_total",
+		Help: "Panics recovered in workers, which are restarted or skip the failed item.",
+	}, []string{"worker"})
+)
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -1,7 +1,74 @@
 package models
 
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
 type GitHubAccount struct {
 	Token    string `json:"token"`
 	Username string `json:"username"`
 	IsActive bool   `json:"is_active"`
+	GitHubAccountMetadata
+	AddedAt time.Time `json:"added_at"`
+}
+
+// GitHubAccountMetadata describes the token of a GitHub account. Every field
+// is optional; ExpiresAt is zero for tokens without an expiry date.
+type GitHubAccountMetadata struct {
+	Scopes    []string  `json:"scopes,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Note      string    `json:"note,omitempty"`
+}
+
+type GerritAccount struct {
+	BaseURL  string `json:"base_url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	IsActive bool   `json:"is_active"`
+}
+
+// Key identifies the account among a user's Gerrit accounts.
+func (a GerritAccount) Key() string {
+	return a.Username + "@" + a.BaseURL
+}
+
+// NotificationReasons are the reasons GitHub notifies an account for,
+// which are the types of its notifications.
+var NotificationReasons = []string{
+	"approval_requested", "assign", "author", "ci_activity", "comment",
+	"invitation", "manual", "member_feature_requested", "mention",
+	"review_requested", "security_advisory_credit", "security_alert",
+	"state_change", "subscribed", "team_mention",
+}
+
+// AccountPreferences are the settings of one of a chat's GitHub accounts.
+type AccountPreferences struct {
+	ChatID   int64
+	Username string
+	// DisabledReasons are the notification reasons, see
+	// NotificationReasons, whose notifications of the account are not
+	// sent. Every other reason is.
+	DisabledReasons []string
+	// SecurityAlerts turns on notifications of new Dependabot and code
+	// scanning alerts on the account's repositories. It is off by default
+	// because reading the alerts needs scopes tokens usually lack.
+	SecurityAlerts bool
+}
+
+// Allows reports whether the account's notifications of the reason are
+// sent.
+func (p AccountPreferences) Allows(reason string) bool {
+	return !slices.Contains(p.DisabledReasons, reason)
+}
+
+func (p AccountPreferences) Validate() error {
+	for _, reason := range p.DisabledReasons {
+		if !slices.Contains(NotificationReasons, reason) {
+			return fmt.Errorf("unknown notification reason %q, expected one of %s", reason, strings.Join(NotificationReasons, ", "))
+		}
+	}
+	return nil
 }
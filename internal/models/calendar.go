@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type CalendarEntry struct {
+	Kind        string
+	ID          int64
+	Repo        string
+	Title       string
+	Description string
+	URL         string
+	Date        time.Time
+}
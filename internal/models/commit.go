@@ -0,0 +1,76 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Commit is a commit on a repository's default branch.
+type Commit struct {
+	Repo    string
+	SHA     string
+	Message string
+	// Author is the GitHub login of the commit's author, empty when the
+	// author's email belongs to no GitHub account.
+	Author string
+	// URL is the commit's page on GitHub.
+	URL         string
+	CommittedAt time.Time
+}
+
+// Subject returns the first line of the commit message.
+func (c Commit) Subject() string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return strings.TrimSpace(subject)
+}
+
+// ShortSHA returns the abbreviated commit hash, as GitHub shows it.
+func (c Commit) ShortSHA() string {
+	if len(c.SHA) > 7 {
+		return c.SHA[:7]
+	}
+	return c.SHA
+}
+
+// Mentions reports whether the commit message @mentions the GitHub login,
+// ignoring case. Email addresses such as ci@login.dev are not mentions.
+func (c Commit) Mentions(login string) bool {
+	message := strings.ToLower(c.Message)
+	mention := "@" + strings.ToLower(login)
+	for offset := 0; ; {
+		i := strings.Index(message[offset:], mention)
+		if i < 0 {
+			return false
+		}
+		start, end := offset+i, offset+i+len(mention)
+		if (start == 0 || !isEmailRune(message[start-1])) && (end == len(message) || !isLoginRune(message[end])) {
+			return true
+		}
+		offset = end
+	}
+}
+
+// isLoginRune reports whether the byte may continue a GitHub login.
+func isLoginRune(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '-' || b == '_'
+}
+
+// isEmailRune reports whether the byte may end the local part of an email
+// address, before its @.
+func isEmailRune(b byte) bool {
+	return isLoginRune(b) || b == '.' || b == '+'
+}
+
+// Comparison is what changed on a branch between two commits.
+type Comparison struct {
+	Repo string
+	Base string
+	Head string
+	// Commits is how many commits Head is ahead of Base.
+	Commits int
+	// Files are the paths of the changed files, including the previous
+	// paths of renamed files. GitHub lists at most 300.
+	Files []string
+	// URL is the comparison's page on GitHub.
+	URL string
+}
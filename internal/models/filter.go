@@ -0,0 +1,52 @@
+package models
+
+import "github.com/erkineren/repository-monitor/internal/filter"
+
+// filterFields are the fields of a notification filters can use.
+var filterFields = map[string]filter.Kind{
+	"notification.Type":         filter.String,
+	"notification.Repo":         filter.String,
+	"notification.Message":      filter.String,
+	"notification.URL":          filter.String,
+	"notification.Severity":     filter.String,
+	"notification.Labels":       filter.List,
+	"notification.Author.Login": filter.String,
+	"notification.Author.IsBot": filter.Bool,
+}
+
+// ParseFilter parses a filter expression over the fields of a
+// notification, such as
+//
+//	notification.Repo startsWith "acme/" && !notification.Author.IsBot
+//
+// See package filter for the syntax.
+func ParseFilter(expression string) (*filter.Expr, error) {
+	return filter.Parse(expression, filterFields)
+}
+
+// MatchesFilter reports whether the notification matches the filter.
+func (n Notification) MatchesFilter(f *filter.Expr) bool {
+	return f.Match(n.filterField)
+}
+
+func (n Notification) filterField(name string) any {
+	switch name {
+	case "notification.Type":
+		return n.Type
+	case "notification.Repo":
+		return n.Repo
+	case "notification.Message":
+		return n.Message
+	case "notification.URL":
+		return n.URL
+	case "notification.Severity":
+		return n.Severity
+	case "notification.Labels":
+		return n.Labels
+	case "notification.Author.Login":
+		return n.Author.Login
+	case "notification.Author.IsBot":
+		return n.Author.IsBot
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// FeatureFlag gates a feature for a subset of users. A chat has the
+// feature when the flag is enabled for everyone, when the chat is listed
+// in ChatIDs, or when it falls into the first Percent of chats.
+type FeatureFlag struct {
+	Name      string
+	Enabled   bool
+	Percent   int
+	ChatIDs   []int64
+	UpdatedAt time.Time
+}
+
+func (f FeatureFlag) Validate() error {
+	if f.Name == "" {
+		return fmt.Errorf("feature flag name is required")
+	}
+	if f.Percent < 0 || f.Percent > 100 {
+		return fmt.Errorf("invalid rollout percentage %d, must be between 0 and 100", f.Percent)
+	}
+	return nil
+}
+
+// EnabledFor reports whether the flag is on for the chat. A chat stays in
+// or out of a percentage rollout for as long as the percentage is not
+// lowered, and raising it only adds chats.
+func (f FeatureFlag) EnabledFor(chatID int64) bool {
+	if f.Enabled || slices.Contains(f.ChatIDs, chatID) {
+		return true
+	}
+	if f.Percent <= 0 {
+		return false
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(f.Name + ":" + strconv.FormatInt(chatID, 10)))
+	return int(h.Sum32()%100) < f.Percent
+}
@@ -0,0 +1,31 @@
+package models
+
+type JiraConfig struct {
+	ChatID     int64
+	BaseURL    string
+	Email      string
+	APIToken   string
+	ProjectKey string
+	IssueType  string
+}
+
+type LinearTarget struct {
+	TeamID    string
+	ProjectID string
+}
+
+type LinearConfig struct {
+	ChatID   int64
+	APIKey   string
+	Default  LinearTarget
+	Mappings map[string]LinearTarget
+}
+
+// Target returns the team and project a notification from repo should be
+// filed under, falling back to the default when the repo is not mapped.
+func (c *LinearConfig) Target(repo string) LinearTarget {
+	if target, ok := c.Mappings[repo]; ok {
+		return target
+	}
+	return c.Default
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Issue is an open issue as considered for triage.
+type Issue struct {
+	Repo   string
+	Number int
+	Title  string
+	// URL is the issue's page on GitHub.
+	URL       string
+	Author    string
+	Labels    []string
+	Assignees []string
+	CreatedAt time.Time
+}
+
+// Triaged reports whether a maintainer labeled or assigned the issue.
+func (i Issue) Triaged() bool {
+	return len(i.Labels) > 0 || len(i.Assignees) > 0
+}
+
+// PossibleDuplicates returns up to limit of the candidates opened before
+// the issue whose titles share most words with its title, most similar
+// first. Titles are compared by their words, ignoring case, common words
+// and plural and -ing/-ed endings, and match when at least two words and
+// half of all their words are shared.
+func (i Issue) PossibleDuplicates(candidates []Issue, limit int) []Issue {
+	type match struct {
+		issue Issue
+		score float64
+	}
+	var matches []match
+	words := titleWords(i.Title)
+	for _, candidate := range candidates {
+		if candidate.Repo != i.Repo || candidate.Number == i.Number || !candidate.CreatedAt.Before(i.CreatedAt) {
+			continue
+		}
+		other := titleWords(candidate.Title)
+		shared := 0
+		for word := range words {
+			if other[word] {
+				shared++
+			}
+		}
+		score := float64(shared) / float64(len(words)+len(other)-shared)
+		if shared >= 2 && score >= 0.5 {
+			matches = append(matches, match{candidate, score})
+		}
+	}
+	sort.SliceStable(matches, func(a, b int) bool { return matches[a].score > matches[b].score })
+
+	duplicates := make([]Issue, 0, min(len(matches), limit))
+	for _, m := range matches[:min(len(matches), limit)] {
+		duplicates = append(duplicates, m.issue)
+	}
+	return duplicates
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "when": true, "not": true,
+	"does": true, "doesn": true, "can": true, "cannot": true, "from": true, "into": true,
+	"after": true, "should": true, "this": true, "that": true, "are": true, "was": true,
+	"have": true, "has": true, "use": true, "using": true, "add": true, "support": true,
+}
+
+// titleWords returns the distinct words of an issue title worth comparing,
+// reduced to a rough stem.
+func titleWords(title string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(word) < 3 || stopWords[word] {
+			continue
+		}
+		for _, suffix := range []string{"ing", "ed", "es", "s"} {
+			if stem, ok := strings.CutSuffix(word, suffix); ok && len(stem) >= 3 {
+				word = stem
+				break
+			}
+		}
+		words[word] = true
+	}
+	return words
+}
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Business hours count towards mention SLAs: 09:00 to 17:00 on weekdays,
+// in the chat's timezone.
+const (
+	businessDayStart = 9
+	businessDayEnd   = 17
+)
+
+// Mention is a mention of one of a chat's GitHub accounts on an issue or
+// pull request that the account has not replied to yet.
+type Mention struct {
+	ID      int64
+	ChatID  int64
+	Account string
+	Repo    string
+	// Message is the text of the notification reporting the mention.
+	Message string
+	// URL is the issue's or pull request's API URL, as in the subject of
+	// GitHub's mention notifications.
+	URL         string
+	MentionedAt time.Time
+}
+
+// BusinessHoursBetween returns the business hours, in the chat's timezone,
+// from start to end.
+func (p Preferences) BusinessHoursBetween(start, end time.Time) time.Duration {
+	location := p.location()
+	start, end = start.In(location), end.In(location)
+
+	var total time.Duration
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, location); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), businessDayStart, 0, 0, 0, location)
+		to := time.Date(day.Year(), day.Month(), day.Day(), businessDayEnd, 0, 0, 0, location)
+		if start.After(from) {
+			from = start
+		}
+		if end.Before(to) {
+			to = end
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+		}
+	}
+	return total
+}
+
+// BreachesSLA reports whether the mention went without a reply for more
+// than the chat's MentionSLA at now.
+func (p Preferences) BreachesSLA(mention Mention, now time.Time) bool {
+	return p.MentionSLA > 0 && p.BusinessHoursBetween(mention.MentionedAt, now) > time.Duration(p.MentionSLA)*time.Hour
+}
@@ -1,11 +1,133 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"fmt"
+	"time"
+)
 
 type Notification struct {
-	Type    string
+	Type string
+	Repo string
+	// Message is the notification as plain text, which filters, summaries
+	// and deduplication read. Sources that set Fields compose it from them
+	// with render.Text.
 	Message string
 	URL     string
+	// Fields are the details of the activity the message templates word
+	// the notification from. Zero for sources that compose Message
+	// themselves.
+	Fields Fields
+	// DedupKey identifies this version of the item for deduplication. When
+	// empty, a hash of Message is used instead.
+	DedupKey string
+	// OccurredAt is when the activity happened on the source, used to
+	// measure delivery latency. Zero when the source does not say.
+	OccurredAt time.Time
+
+	// EventID identifies the event the notification reports, the same for
+	// every chat, account, retry and sink it is delivered to, see
+	// NewEventID. When empty, IdempotencyKey derives one.
+	EventID string
+	// ThreadID is the GitHub notification thread the notification was
+	// read from, for marking it read from the chat. Empty for the other
+	// sources.
+	ThreadID string
+	// Account is the GitHub account the notification was read for, which
+	// the buttons acting on the notification use.
+	Account string
+
+	// Severity is the notification's classification, one of the Severity
+	// constants, set when it is queued. Empty for notifications queued
+	// before severities were introduced.
+	Severity string
+	// Labels are the labels of the issue or pull request, when the source
+	// knows them, for classifying and routing the notification.
+	Labels []string
+	// Author is who opened the pull request or issue or published the
+	// release, when the source knows. It is only used by filters and is
+	// not kept in the outbox.
+	Author Author
+}
+
+// Fields describe the item and activity a notification reports. Each
+// source sets the ones it knows; the templates of package render word the
+// notification per type from them.
+type Fields struct {
+	// Title names the item: the title of the pull request, issue or
+	// change, or the package of a dependency.
+	Title string `json:"title,omitempty"`
+	// Number is the number of the pull request, issue or change.
+	Number int `json:"number,omitempty"`
+	// Actor is who opened, merged, uploaded or asked for what is reported.
+	Actor string `json:"actor,omitempty"`
+	// Version is the released version, image tag or patch set.
+	Version string `json:"version,omitempty"`
+	// Previous is the version of a dependency in use.
+	Previous string `json:"previous,omitempty"`
+	// Delta describes a release against the previous one, such as "minor
+	// update from v1.2.4".
+	Delta string `json:"delta,omitempty"`
+	// Advisory identifies a vulnerability with its aliases.
+	Advisory string `json:"advisory,omitempty"`
+	// Details are more about the activity: the release notes, the votes
+	// on a change or the summary of a vulnerability.
+	Details string `json:"details,omitempty"`
+	// Summary is the summary of the conversation, when summaries are on.
+	Summary string `json:"summary,omitempty"`
+}
+
+// IsZero reports whether no field is set, as for notifications of sources
+// that compose their message themselves.
+func (f Fields) IsZero() bool {
+	return f == Fields{}
+}
+
+type Author struct {
+	Login string
+	IsBot bool
+}
+
+const (
+	SeverityCritical = "critical"
+	SeverityHigh     = "high"
+	SeverityNormal   = "normal"
+	SeverityLow      = "low"
+)
+
+// ValidSeverity reports whether severity is one of the Severity constants.
+func ValidSeverity(severity string) bool {
+	switch severity {
+	case SeverityCritical, SeverityHigh, SeverityNormal, SeverityLow:
+		return true
+	}
+	return false
+}
+
+// NewEventID derives the ID of an event from the provider reporting it,
+// the thread it happened on, the kind of event and when the thread was
+// updated, so reporting the same event again yields the same ID.
+func NewEventID(provider, thread, kind string, updatedAt time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%s\x00%d", provider, thread, kind, updatedAt.UnixNano())))
+	return fmt.Sprintf("%s-%x", provider, sum[:12])
+}
+
+func (n Notification) ContentHash() string {
+	if n.DedupKey != "" {
+		return n.DedupKey
+	}
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(n.Message)))
+}
+
+// IdempotencyKey returns the event ID, or for sources that do not set one,
+// an ID derived from the URL, type and content hash. Receivers use it to
+// recognize a notification delivered again after a retry.
+func (n Notification) IdempotencyKey() string {
+	if n.EventID != "" {
+		return n.EventID
+	}
+	sum := sha256.Sum256([]byte(n.URL + "\x00" + n.Type + "\x00" + n.ContentHash()))
+	return fmt.Sprintf("event-%x", sum[:12])
 }
 
 type NotificationRecord struct {
@@ -15,4 +137,6 @@ type NotificationRecord struct {
 	NotificationType string
 	ContentHash      string
 	CreatedAt        time.Time
+
+	EventID string
 }
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// States of an outbox entry.
+const (
+	OutboxPending = "pending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxEntry is a notification waiting in the outbox to be delivered.
+type OutboxEntry struct {
+	ID           int64
+	ChatID       int64
+	TenantID     string
+	Notification Notification
+	Attempts     int
+	CreatedAt    time.Time
+
+	// The delivery status is only filled in when listing the outbox.
+	LastError     string
+	NextAttemptAt time.Time
+	SentAt        time.Time
+	DeadAt        time.Time
+
+	// Routing is decided by the dispatcher from the chat's rules, see
+	// Apply, and is not stored.
+	DestinationChatID int64
+	Silent            bool
+}
+
+// Destination returns the chat the entry is delivered to.
+func (e OutboxEntry) Destination() int64 {
+	if e.DestinationChatID != 0 {
+		return e.DestinationChatID
+	}
+	return e.ChatID
+}
+
+// State returns whether the entry is pending, sent or dead.
+func (e OutboxEntry) State() string {
+	switch {
+	case !e.SentAt.IsZero():
+		return OutboxSent
+	case !e.DeadAt.IsZero():
+		return OutboxDead
+	default:
+		return OutboxPending
+	}
+}
@@ -0,0 +1,335 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/cron"
+)
+
+const (
+	DigestOff    = "off"
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+// DefaultDigestTime is when daily digests are sent unless the chat chose
+// another time.
+const DefaultDigestTime = "09:00"
+
+// RenotifyNever is the renotify interval of notification types whose items
+// are sent once and never again.
+const RenotifyNever = -1
+
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// defaultPriorities are the priorities of notification types the chat did
+// not set: activity the user did not take part in is low priority.
+var defaultPriorities = map[string]string{
+	"ci_activity":        PriorityLow,
+	"subscribed":         PriorityLow,
+	"image_tag":          PriorityLow,
+	"dependency_release": PriorityLow,
+}
+
+// Preferences are the per-chat settings that shape how notifications are
+// delivered.
+type Preferences struct {
+	ChatID int64
+	// QuietHoursStart and QuietHoursEnd are "HH:MM" times in Timezone.
+	// Quiet hours are disabled when either is empty.
+	QuietHoursStart string
+	QuietHoursEnd   string
+	Timezone        string
+	// DigestMode batches the chat's notifications into a digest sent
+	// every hour, or every day at DigestTime, an "HH:MM" time in Timezone
+	// that is DefaultDigestTime when empty.
+	DigestMode string
+	DigestTime string
+	Language   string
+	ParseMode  string
+	// Priorities maps a notification type or an "owner/repo" to a priority.
+	Priorities map[string]string
+	// RenotifyIntervals maps a notification type to the hours before the
+	// same item is sent again, or RenotifyNever. Other types use
+	// RENOTIFY_INTERVAL.
+	RenotifyIntervals map[string]int
+	// Paused holds back delivery to the chat until it is resumed, or until
+	// PausedUntil when that is set. Notifications are still queued while
+	// delivery is paused and are sent once it resumes.
+	Paused      bool
+	PausedUntil time.Time
+	// PollWindow is a cron expression in Timezone selecting the minutes in
+	// which the chat's accounts are polled and notifications delivered, or
+	// empty for always. Activity from outside the window is delivered as a
+	// digest once it opens.
+	PollWindow string
+	// Filter is an expression notifications must match to be queued, see
+	// ParseFilter, or empty to queue all.
+	Filter string
+	// TeamDigest is a cron expression in Timezone selecting when a group
+	// chat is sent a summary of its members' pending reviews and of the
+	// pull requests of TeamRepos, or empty for none.
+	TeamDigest string
+	// TeamRepos are the "owner/repo" repositories of the team digest.
+	TeamRepos []string
+	// MentionSLA is how many business hours the chat's accounts have to
+	// reply to a mention before a reminder is sent, or 0 for no SLA.
+	MentionSLA int
+	Triage     Triage
+	// Summaries opts in to summaries of long issue and pull request
+	// threads, when the operator configured a language model.
+	Summaries bool
+	Changelog Changelog
+	Usage     UsageAlerts
+	// CommitMentionRepos are the "owner/repo" repositories whose new
+	// commits are scanned for @mentions of the chat's accounts.
+	CommitMentionRepos []string
+}
+
+func DefaultPreferences(chatID int64) Preferences {
+	return Preferences{
+		ChatID:            chatID,
+		Timezone:          "UTC",
+		DigestMode:        DigestOff,
+		Language:          "en",
+		ParseMode:         "MarkdownV2",
+		Priorities:        map[string]string{},
+		RenotifyIntervals: map[string]int{},
+	}
+}
+
+// Validate reports the first invalid setting, if any.
+func (p Preferences) Validate() error {
+	if (p.QuietHoursStart == "") != (p.QuietHoursEnd == "") {
+		return fmt.Errorf("quiet hours need both a start and an end")
+	}
+	for _, value := range []string{p.QuietHoursStart, p.QuietHoursEnd, p.DigestTime} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", value); err != nil {
+			return fmt.Errorf("invalid time %q, expected HH:MM", value)
+		}
+	}
+	if p.PollWindow != "" {
+		if _, err := cron.Parse(p.PollWindow); err != nil {
+			return fmt.Errorf("invalid poll window: %v", err)
+		}
+	}
+	if p.TeamDigest != "" {
+		if _, err := cron.Parse(p.TeamDigest); err != nil {
+			return fmt.Errorf("invalid team digest schedule: %v", err)
+		}
+	}
+	for _, repo := range p.TeamRepos {
+		if err := validateRepo(repo); err != nil {
+			return err
+		}
+	}
+	if err := p.Triage.Validate(); err != nil {
+		return err
+	}
+	if err := p.Changelog.Validate(); err != nil {
+		return err
+	}
+	if err := p.Usage.Validate(); err != nil {
+		return err
+	}
+	for _, repo := range p.CommitMentionRepos {
+		if err := validateRepo(repo); err != nil {
+			return err
+		}
+	}
+	if p.Filter != "" {
+		if _, err := ParseFilter(p.Filter); err != nil {
+			return err
+		}
+	}
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q", p.Timezone)
+	}
+	switch p.DigestMode {
+	case DigestOff, DigestHourly, DigestDaily:
+	default:
+		return fmt.Errorf("invalid digest mode %q, expected off, hourly or daily", p.DigestMode)
+	}
+	switch p.ParseMode {
+	case "MarkdownV2", "HTML", "":
+	default:
+		return fmt.Errorf("invalid parse mode %q, expected MarkdownV2, HTML or empty", p.ParseMode)
+	}
+	for key, priority := range p.Priorities {
+		switch priority {
+		case PriorityLow, PriorityNormal, PriorityHigh:
+		default:
+			return fmt.Errorf("invalid priority %q for %s, expected low, normal or high", priority, key)
+		}
+	}
+	if p.MentionSLA < 0 {
+		return fmt.Errorf("invalid mention SLA %d, expected a positive number of business hours", p.MentionSLA)
+	}
+	for notificationType, hours := range p.RenotifyIntervals {
+		if hours < 1 && hours != RenotifyNever {
+			return fmt.Errorf("invalid renotify interval %d for %s, expected a positive number of hours or never", hours, notificationType)
+		}
+	}
+	return nil
+}
+
+func validateRepo(repo string) error {
+	if owner, name, ok := strings.Cut(repo, "/"); !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid repository %q, expected owner/repo", repo)
+	}
+	return nil
+}
+
+// InQuietHours reports whether t falls within the quiet hours. Ranges that
+// cross midnight, e.g. 22:00-07:00, are supported.
+func (p Preferences) InQuietHours(t time.Time) bool {
+	if p.QuietHoursStart == "" || p.QuietHoursEnd == "" {
+		return false
+	}
+
+	now := t.In(p.location()).Format("15:04")
+
+	if p.QuietHoursStart <= p.QuietHoursEnd {
+		return now >= p.QuietHoursStart && now < p.QuietHoursEnd
+	}
+	return now >= p.QuietHoursStart || now < p.QuietHoursEnd
+}
+
+// DeliveryPaused reports whether delivery to the chat is held back at t,
+// by a pause, quiet hours or the poll window, and when it resumes. The time is zero for
+// a pause that lasts until the chat resumes delivery.
+func (p Preferences) DeliveryPaused(t time.Time) (time.Time, bool) {
+	if p.PausedAt(t) {
+		return p.PausedUntil, true
+	}
+	if p.InQuietHours(t) {
+		return p.quietHoursEnd(t), true
+	}
+	if !p.InPollWindow(t) {
+		schedule, _ := cron.Parse(p.PollWindow)
+		return schedule.Next(t.In(p.location())), true
+	}
+	return time.Time{}, false
+}
+
+// NextDigest returns when the first digest after t is sent, or zero when
+// the chat gets its notifications right away. Hourly digests are sent at
+// the top of every hour in Timezone.
+func (p Preferences) NextDigest(t time.Time) time.Time {
+	location := p.location()
+	local := t.In(location)
+	switch p.DigestMode {
+	case DigestHourly:
+		return time.Date(local.Year(), local.Month(), local.Day(), local.Hour()+1, 0, 0, 0, location)
+	case DigestDaily:
+		at, err := time.Parse("15:04", p.DigestTime)
+		if err != nil {
+			at, _ = time.Parse("15:04", DefaultDigestTime)
+		}
+		next := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, location)
+		if !next.After(local) {
+			next = time.Date(local.Year(), local.Month(), local.Day()+1, at.Hour(), at.Minute(), 0, 0, location)
+		}
+		return next
+	}
+	return time.Time{}
+}
+
+// InPollWindow reports whether t falls within the poll window. Windows
+// that do not parse are treated as always open.
+func (p Preferences) InPollWindow(t time.Time) bool {
+	if p.PollWindow == "" {
+		return true
+	}
+	schedule, err := cron.Parse(p.PollWindow)
+	if err != nil {
+		return true
+	}
+	return schedule.Matches(t.In(p.location()))
+}
+
+// TeamDigestDue reports whether the team digest schedule selects a minute
+// after last, when the digest was last sent, up to now.
+func (p Preferences) TeamDigestDue(last, now time.Time) bool {
+	if p.TeamDigest == "" {
+		return false
+	}
+	schedule, err := cron.Parse(p.TeamDigest)
+	if err != nil {
+		return false
+	}
+	next := schedule.Next(last.In(p.location()))
+	return !next.IsZero() && !next.After(now)
+}
+
+func (p Preferences) location() *time.Location {
+	location, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// PausedAt reports whether the chat paused delivery at t, not counting
+// quiet hours.
+func (p Preferences) PausedAt(t time.Time) bool {
+	return p.Paused && (p.PausedUntil.IsZero() || t.Before(p.PausedUntil))
+}
+
+// quietHoursEnd returns the first end of the quiet hours after t.
+func (p Preferences) quietHoursEnd(t time.Time) time.Time {
+	end, err := time.Parse("15:04", p.QuietHoursEnd)
+	if err != nil {
+		return time.Time{}
+	}
+
+	local := t.In(p.location())
+	resume := time.Date(local.Year(), local.Month(), local.Day(), end.Hour(), end.Minute(), 0, 0, local.Location())
+	if !resume.After(t) {
+		resume = resume.AddDate(0, 0, 1)
+	}
+	return resume
+}
+
+// Priority returns the priority of a notification, preferring a repository
+// setting over a notification type setting over the notification's
+// severity, or the type's default for notifications without one.
+func (p Preferences) Priority(n Notification) string {
+	if priority, ok := p.Priorities[n.Repo]; ok {
+		return priority
+	}
+	if priority, ok := p.Priorities[n.Type]; ok {
+		return priority
+	}
+	switch n.Severity {
+	case SeverityCritical, SeverityHigh:
+		return PriorityHigh
+	case SeverityNormal:
+		return PriorityNormal
+	case SeverityLow:
+		return PriorityLow
+	}
+	if priority, ok := defaultPriorities[n.Type]; ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// RenotifyInterval returns how long until an item of the notification type
+// is sent again, falling back to defaultInterval. It is negative for types
+// that are never renotified.
+func (p Preferences) RenotifyInterval(notificationType string, defaultInterval time.Duration) time.Duration {
+	if hours, ok := p.RenotifyIntervals[notificationType]; ok {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultInterval
+}
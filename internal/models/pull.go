@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PullRequest is a pull request as summarized in team digests.
+type PullRequest struct {
+	Repo   string
+	Number int
+	Title  string
+	// URL is the pull request's page on GitHub.
+	URL       string
+	Author    string
+	Open      bool
+	UpdatedAt time.Time
+	// MergedAt is zero for pull requests that were not merged.
+	MergedAt time.Time
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Release is a published release as listed in changelogs.
+type Release struct {
+	Repo string
+	Tag  string
+	Name string
+	// URL is the release's page on GitHub.
+	URL         string
+	Author      string
+	PublishedAt time.Time
+}
+
+// Changelog is a chat's daily changelog: the releases published in Repos
+// since the previous changelog are combined into one message sent every
+// day at At.
+type Changelog struct {
+	Repos []string
+	// At is an "HH:MM" time in the chat's timezone, empty when the
+	// changelog is off.
+	At string
+}
+
+func (c Changelog) Validate() error {
+	if c.At == "" {
+		return nil
+	}
+	if _, err := time.Parse("15:04", c.At); err != nil {
+		return fmt.Errorf("invalid changelog time %q, expected HH:MM", c.At)
+	}
+	if len(c.Repos) == 0 {
+		return fmt.Errorf("the changelog needs at least one repository")
+	}
+	for _, repo := range c.Repos {
+		if err := validateRepo(repo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChangelogDue reports whether the daily changelog time passed after last,
+// when the changelog was last sent, up to now.
+func (p Preferences) ChangelogDue(last, now time.Time) bool {
+	at, err := time.Parse("15:04", p.Changelog.At)
+	if err != nil {
+		return false
+	}
+	local := now.In(p.location())
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, local.Location())
+	if scheduled.After(now) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	return last.Before(scheduled)
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// ReviewRequest is a request for one of a chat's GitHub accounts to review
+// a pull request, tracked to measure how long reviews take.
+type ReviewRequest struct {
+	ID      int64
+	ChatID  int64
+	Account string
+	Repo    string
+	// URL is the pull request's API URL, as in the subject of GitHub's
+	// review_requested notifications.
+	URL         string
+	RequestedAt time.Time
+	// ReviewedAt is when the account submitted a review, zero while the
+	// request is pending.
+	ReviewedAt time.Time
+}
+
+// Pending reports whether the review was not submitted yet.
+func (r ReviewRequest) Pending() bool {
+	return r.ReviewedAt.IsZero()
+}
+
+// ReviewStats summarize a chat's review requests over a period.
+type ReviewStats struct {
+	// Reviewed is how many requests of the period were reviewed, taking
+	// MedianTurnaround from request to review.
+	Reviewed         int
+	MedianTurnaround time.Duration
+	// Pending is how many requests wait for a review, regardless of when
+	// they arrived, the oldest for OldestPending.
+	Pending       int
+	OldestPending time.Duration
+	// BusiestRepos are the repositories with the most requests in the
+	// period, most first, at most three.
+	BusiestRepos []RepoCount
+}
+
+type RepoCount struct {
+	Repo  string
+	Count int
+}
+
+// SummarizeReviews computes the stats of the requests that arrived since
+// the given time and of those still pending at now.
+func SummarizeReviews(requests []ReviewRequest, since, now time.Time) ReviewStats {
+	var stats ReviewStats
+	var turnarounds []time.Duration
+	counts := map[string]int{}
+	for _, request := range requests {
+		if request.Pending() {
+			stats.Pending++
+			stats.OldestPending = max(stats.OldestPending, now.Sub(request.RequestedAt))
+		}
+		if request.RequestedAt.Before(since) {
+			continue
+		}
+		counts[request.Repo]++
+		if !request.Pending() {
+			turnarounds = append(turnarounds, request.ReviewedAt.Sub(request.RequestedAt))
+		}
+	}
+
+	stats.Reviewed = len(turnarounds)
+	if len(turnarounds) > 0 {
+		sort.Slice(turnarounds, func(i, j int) bool { return turnarounds[i] < turnarounds[j] })
+		middle := len(turnarounds) / 2
+		stats.MedianTurnaround = turnarounds[middle]
+		if len(turnarounds)%2 == 0 {
+			stats.MedianTurnaround = (turnarounds[middle-1] + turnarounds[middle]) / 2
+		}
+	}
+
+	for repo, count := range counts {
+		stats.BusiestRepos = append(stats.BusiestRepos, RepoCount{Repo: repo, Count: count})
+	}
+	sort.Slice(stats.BusiestRepos, func(i, j int) bool {
+		a, b := stats.BusiestRepos[i], stats.BusiestRepos[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Repo < b.Repo
+	})
+	if len(stats.BusiestRepos) > 3 {
+		stats.BusiestRepos = stats.BusiestRepos[:3]
+	}
+	return stats
+}
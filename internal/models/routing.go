@@ -0,0 +1,107 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Conditions select notifications. A notification matches when it meets
+// all non-empty conditions.
+type Conditions struct {
+	// Type is the notification type, such as ci_activity.
+	Type string
+	// Repo is an "owner/repo" pattern as in path.Match, such as acme/*.
+	Repo string
+	// Label is one of the issue or pull request labels, ignoring case.
+	Label string
+	// Keyword is text contained in the message, ignoring case.
+	Keyword string
+}
+
+// Matches reports whether the notification meets the conditions.
+func (c Conditions) Matches(n Notification) bool {
+	if c.Type != "" && c.Type != n.Type {
+		return false
+	}
+	if c.Repo != "" {
+		if ok, _ := path.Match(c.Repo, n.Repo); !ok {
+			return false
+		}
+	}
+	if c.Label != "" && !hasLabel(n.Labels, c.Label) {
+		return false
+	}
+	if c.Keyword != "" && !strings.Contains(strings.ToLower(n.Message), strings.ToLower(c.Keyword)) {
+		return false
+	}
+	return true
+}
+
+// Validate reports an invalid repository pattern.
+func (c Conditions) Validate() error {
+	if _, err := path.Match(c.Repo, ""); err != nil {
+		return fmt.Errorf("invalid repository pattern %q", c.Repo)
+	}
+	return nil
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// RoutingRule changes how a chat's notifications matching its conditions
+// are delivered. Every matching rule applies, in the order the rules were
+// added, so later rules override the actions of earlier ones.
+type RoutingRule struct {
+	ID     int64
+	ChatID int64
+	Conditions
+
+	// Severity replaces the notification's severity when set.
+	Severity string
+	// DestinationChatID receives the notifications instead of ChatID when
+	// set, e.g. a group the user administers.
+	DestinationChatID int64
+	// Silent delivers the notifications without sound.
+	Silent    bool
+	CreatedAt time.Time
+}
+
+func (r RoutingRule) Validate() error {
+	if err := r.Conditions.Validate(); err != nil {
+		return err
+	}
+	if r.Severity != "" && !ValidSeverity(r.Severity) {
+		return fmt.Errorf("invalid severity %q, expected critical, high, normal or low", r.Severity)
+	}
+	if r.Severity == "" && r.DestinationChatID == 0 && !r.Silent {
+		return fmt.Errorf("a rule needs an action: a severity, a destination chat or silent delivery")
+	}
+	return nil
+}
+
+// Apply applies the actions of the rules matching the entry's notification.
+func Apply(rules []RoutingRule, entry OutboxEntry) OutboxEntry {
+	for _, rule := range rules {
+		if !rule.Matches(entry.Notification) {
+			continue
+		}
+		if rule.Severity != "" {
+			entry.Notification.Severity = rule.Severity
+		}
+		if rule.DestinationChatID != 0 {
+			entry.DestinationChatID = rule.DestinationChatID
+		}
+		if rule.Silent {
+			entry.Silent = true
+		}
+	}
+	return entry
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+const (
+	SecurityAlertDependabot   = "dependabot"
+	SecurityAlertCodeScanning = "code_scanning"
+)
+
+// SecurityAlert is a Dependabot or code scanning alert on a repository.
+type SecurityAlert struct {
+	// Kind is SecurityAlertDependabot or SecurityAlertCodeScanning.
+	Kind   string
+	Repo   string
+	Number int
+	// Severity is GitHub's severity of the alert, such as critical, high,
+	// medium or low, or for code scanning rules without a security
+	// severity error, warning or note.
+	Severity string
+	// Package and Ecosystem are the vulnerable dependency of Dependabot
+	// alerts, such as "lodash" and "npm". Empty for code scanning alerts.
+	Package   string
+	Ecosystem string
+	// Summary is the advisory's summary, or the code scanning rule's
+	// description.
+	Summary string
+	// Path is the file the code scanning alert was found in.
+	Path      string
+	URL       string
+	CreatedAt time.Time
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+const (
+	AccountKindGitHub = "github"
+	AccountKindGerrit = "gerrit"
+	// AccountKindTeamDigest is the state of a chat's team digest, which
+	// records in LastCheckedAt when the digest was last sent.
+	AccountKindTeamDigest = "team_digest"
+	// AccountKindChangelog is the state of a chat's daily changelog, which
+	// records in LastCheckedAt when the changelog was last sent.
+	AccountKindChangelog = "changelog"
+	// AccountKindCommits is the state of a repository whose commits are
+	// scanned for a chat, keyed by "owner/repo", which records in
+	// LastModified the newest commit scanned.
+	AccountKindCommits = "commits"
+	// AccountKindPaths is the state of a repository with path
+	// subscriptions of a chat, keyed by "owner/repo", which records in
+	// LastModified the commit its changes were last compared from.
+	AccountKindPaths = "paths"
+	// AccountKindSecurity is the state of a GitHub account's security
+	// alerts, which records in LastCheckedAt since when alerts are new.
+	AccountKindSecurity = "security"
+)
+
+// AccountState is what the poller remembers about an account between
+// cycles, so polling can resume incrementally after a restart.
+type AccountState struct {
+	ChatID             int64
+	Kind               string
+	Account            string
+	LastCheckedAt      time.Time
+	LastModified       string
+	LastNotificationAt time.Time
+	ConsecutiveErrors  int
+	LastError          string
+
+	// PollInterval is the minimum number of seconds between polls GitHub
+	// asked for in its last X-Poll-Interval header, or 0.
+	PollInterval int
+}
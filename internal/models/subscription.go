@@ -0,0 +1,172 @@
+package models
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+type ImageSubscription struct {
+	ID        int64
+	ChatID    int64
+	Image     string
+	TagFilter string
+}
+
+type DependencySubscription struct {
+	ID            int64
+	ChatID        int64
+	Repo          string
+	LastCheckedAt time.Time
+}
+
+const (
+	EventPullRequest = "pull_request"
+	EventIssue       = "issue"
+	EventRelease     = "release"
+	EventPush        = "push"
+	EventWorkflow    = "workflow"
+)
+
+// EventTypes lists the events a repository subscription can select.
+var EventTypes = []string{EventPullRequest, EventIssue, EventRelease, EventPush, EventWorkflow}
+
+// RepoSubscription watches events of a repository, or of every repository
+// of an owner when Repo is "owner/*".
+type RepoSubscription struct {
+	ID     int64
+	ChatID int64
+	Repo   string
+	// EventTypes selects the events to notify about; empty means all.
+	EventTypes []string
+	Filters    RepoFilters
+	// DestinationChatID receives the notifications instead of ChatID when
+	// set, e.g. a group the subscriber administers.
+	DestinationChatID int64
+	CreatedAt         time.Time
+}
+
+// RepoFilters narrow down the events of a subscription. Empty fields don't
+// filter.
+type RepoFilters struct {
+	Labels   []string `json:"labels,omitempty"`
+	Authors  []string `json:"authors,omitempty"`
+	Branches []string `json:"branches,omitempty"`
+}
+
+// IsOrgWide reports whether the subscription covers every repository of
+// the owner.
+func (s RepoSubscription) IsOrgWide() bool {
+	return strings.HasSuffix(s.Repo, "/*")
+}
+
+// Covers reports whether the subscription includes the repository.
+func (s RepoSubscription) Covers(repo string) bool {
+	if s.IsOrgWide() {
+		owner, _, _ := strings.Cut(repo, "/")
+		return strings.EqualFold(owner, strings.TrimSuffix(s.Repo, "/*"))
+	}
+	return strings.EqualFold(s.Repo, repo)
+}
+
+// Wants reports whether the subscription selects the event type.
+func (s RepoSubscription) Wants(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Destination returns the chat notifications are delivered to.
+func (s RepoSubscription) Destination() int64 {
+	if s.DestinationChatID != 0 {
+		return s.DestinationChatID
+	}
+	return s.ChatID
+}
+
+func (s RepoSubscription) Validate() error {
+	owner, name, ok := strings.Cut(s.Repo, "/")
+	if !ok || owner == "" || name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid repository %q, expected owner/repo or owner/*", s.Repo)
+	}
+	for _, t := range s.EventTypes {
+		if !slices.Contains(EventTypes, t) {
+			return fmt.Errorf("unknown event type %q, expected one of %s", t, strings.Join(EventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// PathSubscription watches commits on a repository's default branch that
+// change files matching Pattern.
+type PathSubscription struct {
+	ID     int64
+	ChatID int64
+	Repo   string
+	// Pattern is a path within the repository as in path.Match, where a
+	// ** element matches any number of directories, such as api/**/*.proto.
+	// A pattern matching a directory covers the files below it, so
+	// deploy/helm/ watches the whole directory.
+	Pattern   string
+	CreatedAt time.Time
+}
+
+func (s PathSubscription) Validate() error {
+	if err := validateRepo(s.Repo); err != nil {
+		return err
+	}
+	pattern := strings.Trim(s.Pattern, "/")
+	if pattern == "" {
+		return fmt.Errorf("invalid path pattern %q", s.Pattern)
+	}
+	for _, element := range strings.Split(pattern, "/") {
+		if _, err := path.Match(element, ""); err != nil {
+			return fmt.Errorf("invalid path pattern %q", s.Pattern)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether the file, a path within the repository such as
+// api/v1/user.proto, is the pattern's file or below its directory.
+func (s PathSubscription) Matches(file string) bool {
+	pattern := strings.Split(strings.Trim(s.Pattern, "/"), "/")
+	elements := strings.Split(file, "/")
+	for i := 1; i <= len(elements); i++ {
+		if matchElements(pattern, elements[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchElements matches the path elements against the pattern elements,
+// where ** matches zero or more elements.
+func matchElements(pattern, elements []string) bool {
+	if len(pattern) == 0 {
+		return len(elements) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(elements); i++ {
+			if matchElements(pattern[1:], elements[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elements) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], elements[0]); !ok {
+		return false
+	}
+	return matchElements(pattern[1:], elements[1:])
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Tenant is an isolated workspace with its own Telegram bot and,
+// optionally, its own API token.
+type Tenant struct {
+	ID        string
+	Name      string
+	BotToken  string
+	APIToken  string
+	CreatedAt time.Time
+}
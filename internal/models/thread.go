@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Thread is the conversation on an issue or pull request.
+type Thread struct {
+	Title string
+	Body  string
+	// CommentCount is the number of comments, which are only listed in
+	// Comments, oldest first, for threads long enough to be summarized.
+	CommentCount int
+	Comments     []Comment
+}
+
+type Comment struct {
+	Author string
+	Body   string
+}
+
+// Transcript renders the thread as text of at most limit bytes. When the
+// thread is longer, the oldest comments after the opening post are left
+// out, as the latest ones tell where the discussion stands.
+func (t Thread) Transcript(limit int) string {
+	head := fmt.Sprintf("Title: %s\n\n%s\n", t.Title, t.Body)
+	if len(head) > limit/2 {
+		head = strings.ToValidUTF8(head[:limit/2], "") + "\n"
+	}
+
+	var comments []string
+	// Leave room for the note on the comments left out.
+	size := len(head) + 40
+	for i := len(t.Comments) - 1; i >= 0; i-- {
+		comment := fmt.Sprintf("\n%s: %s\n", t.Comments[i].Author, t.Comments[i].Body)
+		if size+len(comment) > limit {
+			break
+		}
+		size += len(comment)
+		comments = append(comments, comment)
+	}
+
+	var text strings.Builder
+	text.WriteString(head)
+	if skipped := len(t.Comments) - len(comments); skipped > 0 {
+		fmt.Fprintf(&text, "\n[%d earlier comments left out]\n", skipped)
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		text.WriteString(comments[i])
+	}
+	return text.String()
+}
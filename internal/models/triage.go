@@ -0,0 +1,43 @@
+package models
+
+import "fmt"
+
+// maxTriageLabels keeps the triage buttons, together with the integration
+// buttons, on one row of a Telegram message.
+const maxTriageLabels = 5
+
+// Triage is a chat's maintainer triage mode: new issues of Repos that go
+// without labels and assignees for After hours are sent with buttons
+// applying Labels.
+type Triage struct {
+	Repos []string
+	// After is how many hours an issue may wait for triage, 0 when
+	// triage mode is off.
+	After  int
+	Labels []string
+}
+
+func (t Triage) Validate() error {
+	if t.After < 0 {
+		return fmt.Errorf("invalid triage delay %d, expected a positive number of hours", t.After)
+	}
+	if t.After > 0 && len(t.Repos) == 0 {
+		return fmt.Errorf("triage mode needs at least one repository")
+	}
+	for _, repo := range t.Repos {
+		if err := validateRepo(repo); err != nil {
+			return err
+		}
+	}
+	if len(t.Labels) > maxTriageLabels {
+		return fmt.Errorf("at most %d triage labels are supported", maxTriageLabels)
+	}
+	for _, label := range t.Labels {
+		// Labels travel in the callback data of the buttons, which
+		// Telegram limits to 64 bytes.
+		if label == "" || len(label) > 50 {
+			return fmt.Errorf("invalid triage label %q, expected 1 to 50 characters", label)
+		}
+	}
+	return nil
+}
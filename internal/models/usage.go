@@ -0,0 +1,68 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quota is an allowance of a GitHub plan, such as the Actions minutes
+// included per billing cycle, and how much of it is used.
+type Quota struct {
+	// Owner is the user or organization login the quota belongs to.
+	Owner string
+	// Name is what is measured, such as "Actions minutes".
+	Name     string
+	Used     float64
+	Included float64
+	// Unit is what Used and Included count, such as "minutes" or "GB".
+	Unit string
+	// URL is the billing page showing the usage.
+	URL string
+	// DaysLeft is how many days are left in the billing cycle, or -1 for
+	// quotas not reset each cycle.
+	DaysLeft int
+}
+
+// Percent returns how much of the quota is used, in percent.
+func (q Quota) Percent() int {
+	if q.Included <= 0 {
+		return 0
+	}
+	return int(q.Used * 100 / q.Included)
+}
+
+// Level returns 100 when the quota is used up, threshold when the usage
+// reached threshold percent, and 0 otherwise.
+func (q Quota) Level(threshold int) int {
+	switch percent := q.Percent(); {
+	case q.Included <= 0:
+		return 0
+	case percent >= 100:
+		return 100
+	case percent >= threshold:
+		return threshold
+	default:
+		return 0
+	}
+}
+
+// UsageAlerts are a chat's warnings about plan quotas running out: the
+// quotas of the chat's accounts and of Orgs are checked against Threshold.
+type UsageAlerts struct {
+	// Threshold is the usage in percent warned about, 0 when usage alerts
+	// are off.
+	Threshold int
+	Orgs      []string
+}
+
+func (u UsageAlerts) Validate() error {
+	if u.Threshold < 0 || u.Threshold > 99 {
+		return fmt.Errorf("invalid usage threshold %d, expected a percentage from 1 to 99", u.Threshold)
+	}
+	for _, org := range u.Orgs {
+		if org == "" || strings.Contains(org, "/") {
+			return fmt.Errorf("invalid organization %q", org)
+		}
+	}
+	return nil
+}
@@ -1,6 +1,9 @@
 package models
 
 type User struct {
-	ChatID   int64
-	Accounts map[string]*GitHubAccount
+	ChatID         int64
+	TenantID       string
+	Accounts       map[string]*GitHubAccount
+	GerritAccounts []*GerritAccount
+	MutedRepos     map[string]bool
 }
@@ -0,0 +1,7 @@
+package pipelinetest
+
+import "testing"
+
+func TestPipeline(t *testing.T) {
+	Run(t)
+}
@@ -0,0 +1,195 @@
+// Package pipelinetest runs the notification pipeline end to end against
+// recorded GitHub API responses, so changes to filtering, enrichment,
+// deduplication or rendering show up as a diff of golden files. Run it
+// from a test:
+//
+//	func TestPipeline(t *testing.T) {
+//		pipelinetest.Run(t)
+//	}
+//
+// Every directory in testdata is a case:
+//
+//   - notifications.json is GitHub's response to GET /notifications.
+//   - case.json optionally sets up the chat: {"muted": ["owner/repo"],
+//     "disabled_types": ["ci_activity"], "format": "plain"}.
+//   - golden.txt is what the chat is sent, in order, followed by how many
+//     notifications a second poll of the same response queued.
+//
+// Set UPDATE_GOLDEN=1 to rewrite the golden files from the current
+// behavior, then review the diff.
+package pipelinetest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/features"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/source/githubsrc"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+)
+
+const (
+	chatID   = 1
+	username = "octocat"
+	token    = "pipelinetest-token"
+)
+
+// Case is the setup of a fixture's chat, read from case.json.
+type Case struct {
+	// Muted repositories are dropped before queueing.
+	Muted []string `json:"muted"`
+	// DisabledTypes get a feature flag that is off for every chat.
+	DisabledTypes []string `json:"disabled_types"`
+	// Format is the chat's parse mode, MarkdownV2 by default.
+	Format string `json:"format"`
+}
+
+// Run runs every case in testdata as a subtest.
+func Run(t *testing.T) {
+	dir := testdata(t)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read fixtures: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			caseDir := filepath.Join(dir, entry.Name())
+			t.Run(entry.Name(), func(t *testing.T) { runCase(t, caseDir) })
+		}
+	}
+}
+
+// testdata returns the fixture directory next to this file, so cases are
+// found and golden files updated wherever Run is called from.
+func testdata(t *testing.T) string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		t.Fatal("failed to locate fixtures")
+	}
+	return filepath.Join(filepath.Dir(file), "testdata")
+}
+
+func runCase(t *testing.T, dir string) {
+	ctx := context.Background()
+	response, err := os.ReadFile(filepath.Join(dir, "notifications.json"))
+	if err != nil {
+		t.Fatalf("failed to read notifications.json: %v", err)
+	}
+	setup := Case{Format: render.MarkdownV2}
+	if data, err := os.ReadFile(filepath.Join(dir, "case.json")); err == nil {
+		if err := json.Unmarshal(data, &setup); err != nil {
+			t.Fatalf("invalid case.json: %v", err)
+		}
+	} else if !os.IsNotExist(err) {
+		t.Fatalf("failed to read case.json: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/notifications" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response)
+	}))
+	t.Cleanup(server.Close)
+	if err := github.SetBaseURL(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { github.SetBaseURL("") })
+
+	s := memory.New()
+	if err := s.AddGitHubAccount(ctx, chatID, token, username, models.GitHubAccountMetadata{}); err != nil {
+		t.Fatalf("failed to add account: %v", err)
+	}
+	for _, repo := range setup.Muted {
+		if err := s.MuteRepo(ctx, chatID, repo); err != nil {
+			t.Fatalf("failed to mute %s: %v", repo, err)
+		}
+	}
+	for _, notificationType := range setup.DisabledTypes {
+		flag := models.FeatureFlag{Name: features.NotificationFlag(notificationType)}
+		if err := s.SetFeatureFlag(ctx, flag); err != nil {
+			t.Fatalf("failed to disable %s: %v", notificationType, err)
+		}
+	}
+	format, err := render.Lookup(setup.Format)
+	if err != nil {
+		t.Fatalf("invalid case.json: %v", err)
+	}
+
+	flags, err := features.Load(ctx, s)
+	if err != nil {
+		t.Fatalf("failed to load flags: %v", err)
+	}
+	env := &source.Env{
+		Store:  s,
+		Config: &config.Config{PollTimeout: 10 * time.Second, RenotifyInterval: 24 * time.Hour},
+		Flags:  flags,
+		GitHub: github.NewAPI,
+	}
+	limiter := githubsrc.NewRateLimiter()
+	poll := func() int {
+		user, _ := s.GetUser(ctx, chatID)
+		state, err := s.GetAccountState(ctx, chatID, models.AccountKindGitHub, username)
+		if err != nil {
+			t.Fatalf("failed to get polling state: %v", err)
+		}
+		queued, err := githubsrc.Fetch(ctx, env, limiter, user, user.Accounts[username], state)
+		if err != nil {
+			t.Fatalf("poll failed: %v", err)
+		}
+		return queued
+	}
+
+	poll()
+	var got strings.Builder
+	entries, err := s.ClaimOutbox(ctx, 1000, time.Minute)
+	if err != nil {
+		t.Fatalf("failed to claim outbox: %v", err)
+	}
+	for _, entry := range entries {
+		text, err := format.Notification(entry.Notification)
+		if err != nil {
+			t.Fatalf("failed to render %s: %v", entry.Notification.URL, err)
+		}
+		fmt.Fprintf(&got, "== %s %s\n%s\n", entry.Notification.Type, entry.Notification.Repo, text)
+		if err := s.MarkOutboxSent(ctx, entry.ID); err != nil {
+			t.Fatalf("failed to mark %d sent: %v", entry.ID, err)
+		}
+	}
+	fmt.Fprintf(&got, "== second poll queued %d\n", poll())
+
+	compare(t, filepath.Join(dir, "golden.txt"), got.String())
+}
+
+func compare(t *testing.T, path, got string) {
+	if os.Getenv("UPDATE_GOLDEN") != "" {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("failed to update %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file, run with UPDATE_GOLDEN=1 to create it: %v", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the pipeline's output, run with UPDATE_GOLDEN=1 to update it if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
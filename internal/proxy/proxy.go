@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Parse checks a proxy URL. HTTP, HTTPS and SOCKS5 proxies are supported,
+// with credentials in the URL's user info. An empty URL yields nil.
+func Parse(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, want http, https or socks5", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL has no host")
+	}
+	return u, nil
+}
+
+// Transport returns a transport sending every request through proxyURL.
+// Without a proxy URL it returns the default transport, which follows
+// the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+func Transport(proxyURL *url.URL) http.RoundTripper {
+	if proxyURL == nil {
+		return http.DefaultTransport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	return transport
+}
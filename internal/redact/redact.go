@@ -0,0 +1,126 @@
+// Package redact keeps GitHub and Telegram tokens out of logs, error
+// messages and traces.
+package redact
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const placeholder = "[REDACTED]"
+
+// minSecretLength keeps short values, which would match unrelated text,
+// from being registered.
+const minSecretLength = 8
+
+var (
+	mu      sync.RWMutex
+	secrets = map[string]bool{}
+)
+
+// patterns match tokens that were never registered, e.g. one pasted into
+// a command that failed before it was used.
+var patterns = []*regexp.Regexp{
+	// GitHub personal access, OAuth, app and refresh tokens
+	regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,}\b`),
+	// Telegram bot tokens, which Telegram API URLs carry in their path
+	regexp.MustCompile(`[0-9]{5,}:[A-Za-z0-9_-]{30,}`),
+}
+
+// Add registers a secret to be redacted from now on.
+func Add(secret string) {
+	if len(secret) < minSecretLength {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	secrets[secret] = true
+}
+
+// Remove stops redacting a secret that is no longer used, e.g. the token
+// of a removed account. Tokens matching the patterns stay redacted.
+func Remove(secret string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(secrets, secret)
+}
+
+// String replaces registered secrets and anything that looks like a
+// GitHub or Telegram token in s.
+func String(s string) string {
+	mu.RLock()
+	for secret := range secrets {
+		if strings.Contains(s, secret) {
+			s = strings.ReplaceAll(s, secret, placeholder)
+		}
+	}
+	mu.RUnlock()
+
+	for _, pattern := range patterns {
+		s = pattern.ReplaceAllString(s, placeholder)
+	}
+	return s
+}
+
+// Error returns err with its message redacted. The original error is kept
+// for errors.Is and errors.As.
+func Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := String(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{err: err, message: message}
+}
+
+type redactedError struct {
+	err     error
+	message string
+}
+
+func (e *redactedError) Error() string { return e.message }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// maxPending bounds how much of an unfinished line Writer holds back.
+const maxPending = 64 << 10
+
+// Writer redacts everything written to w. Output is passed on a line at a
+// time, so a secret split across writes is still redacted; the log package
+// ends every entry with a newline, so entries are never held back.
+func Writer(w io.Writer) io.Writer {
+	return &writer{w: w}
+}
+
+type writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending []byte
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	end := bytes.LastIndexByte(w.pending, '\n') + 1
+	if end == 0 && len(w.pending) < maxPending {
+		return len(p), nil
+	}
+	if end == 0 {
+		end = len(w.pending)
+	}
+
+	_, err := io.WriteString(w.w, String(string(w.pending[:end])))
+	w.pending = append(w.pending[:0], w.pending[end:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
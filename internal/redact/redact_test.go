@@ -0,0 +1,114 @@
+package redact
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestStringPatterns(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"personal access token", "token ghp_" + strings.Repeat("a", 36) + " rejected", "token [REDACTED] rejected"},
+		{"oauth token", "gho_" + strings.Repeat("B", 40), "[REDACTED]"},
+		{"app token", "ghs_" + strings.Repeat("1", 36), "[REDACTED]"},
+		{"refresh token", "ghr_" + strings.Repeat("x", 36), "[REDACTED]"},
+		{"fine-grained token", "github_pat_" + strings.Repeat("A1_", 10), "[REDACTED]"},
+		{"telegram url", "https://api.telegram.org/bot123456:" + strings.Repeat("Ab-_", 9) + "/sendMessage", "https://api.telegram.org/bot[REDACTED]/sendMessage"},
+		{"short github prefix", "ghp_short", "ghp_short"},
+		{"unknown prefix", "ghx_" + strings.Repeat("a", 36), "ghx_" + strings.Repeat("a", 36)},
+		{"short telegram secret", "1234:" + strings.Repeat("a", 40), "1234:" + strings.Repeat("a", 40)},
+		{"plain text", "no secrets here", "no secrets here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := String(tt.in); got != tt.want {
+				t.Errorf("String(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+	secret := "registered-secret-value"
+	Add(secret)
+	if got := String("using " + secret); got != "using [REDACTED]" {
+		t.Errorf("registered secret: got %q", got)
+	}
+
+	Remove(secret)
+	if got := String("using " + secret); got != "using "+secret {
+		t.Errorf("removed secret: got %q", got)
+	}
+
+	Add("short")
+	defer Remove("short")
+	if got := String("short"); got != "short" {
+		t.Errorf("short values must not be registered, got %q", got)
+	}
+}
+
+func TestError(t *testing.T) {
+	if Error(nil) != nil {
+		t.Error("Error(nil) must be nil")
+	}
+
+	plain := errors.New("nothing to hide")
+	if Error(plain) != plain {
+		t.Error("errors without secrets must be returned as they are")
+	}
+
+	err := Error(&fs.PathError{Op: "open", Path: "ghp_" + strings.Repeat("a", 36), Err: fs.ErrNotExist})
+	if got := err.Error(); got != "open [REDACTED]: file does not exist" {
+		t.Errorf("message = %q", got)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Error("the redacted error must wrap the original")
+	}
+}
+
+// TestWriterSplitSecret writes a registered secret in two halves, as a
+// writer flushing a partial buffer would, and checks it is still redacted.
+func TestWriterSplitSecret(t *testing.T) {
+	secret := "split-across-writes"
+	Add(secret)
+	defer Remove(secret)
+
+	var out strings.Builder
+	w := Writer(&out)
+	for _, part := range []string{"first " + secret[:6], secret[6:] + " line\nsecond", " line\n"} {
+		if n, err := w.Write([]byte(part)); err != nil || n != len(part) {
+			t.Fatalf("Write(%q) = %d, %v", part, n, err)
+		}
+	}
+	if got, want := out.String(), "first [REDACTED] line\nsecond line\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// TestWriterHoldsUnfinishedLine checks that a line is passed on once it
+// ends, and that a line without an end is passed on once it grows too long.
+func TestWriterHoldsUnfinishedLine(t *testing.T) {
+	var out strings.Builder
+	w := Writer(&out)
+
+	w.Write([]byte("unfinished"))
+	if out.Len() != 0 {
+		t.Errorf("unfinished line written: %q", out.String())
+	}
+	w.Write([]byte("\n"))
+	if got := out.String(); got != "unfinished\n" {
+		t.Errorf("got %q", got)
+	}
+
+	out.Reset()
+	long := strings.Repeat("x", maxPending)
+	w.Write([]byte(long))
+	if out.Len() != len(long) {
+		t.Errorf("wrote %d bytes of a long line, want %d", out.Len(), len(long))
+	}
+}
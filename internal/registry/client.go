@@ -0,0 +1,181 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHub = "docker.io"
+	ghcr      = "ghcr.io"
+)
+
+// Image is a parsed image reference such as "golang", "grafana/grafana"
+// or "ghcr.io/owner/name".
+type Image struct {
+	Registry string
+	Path     string
+}
+
+type Client struct {
+	http *http.Client
+}
+
+func NewClient() *Client {
+	return &Client{
+		http: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func ParseImage(ref string) (Image, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.ContainsAny(ref, ":@ ") {
+		return Image{}, fmt.Errorf("invalid image reference %q, expected a repository without tag", ref)
+	}
+
+	first, rest, found := strings.Cut(ref, "/")
+	switch {
+	case !found:
+		return Image{Registry: dockerHub, Path: "library/" + ref}, nil
+	case first == dockerHub || first == "index.docker.io":
+		if !strings.Contains(rest, "/") {
+			rest = "library/" + rest
+		}
+		return Image{Registry: dockerHub, Path: rest}, nil
+	case first == ghcr:
+		return Image{Registry: ghcr, Path: rest}, nil
+	case strings.ContainsAny(first, ".:"):
+		return Image{}, fmt.Errorf("unsupported registry %q, only Docker Hub and GHCR are supported", first)
+	default:
+		return Image{Registry: dockerHub, Path: ref}, nil
+	}
+}
+
+func (i Image) String() string {
+	return i.Registry + "/" + i.Path
+}
+
+// TagURL links to a page describing the tag.
+func (i Image) TagURL(tag string) string {
+	if i.Registry == dockerHub {
+		path := i.Path
+		if name, ok := strings.CutPrefix(path, "library/"); ok {
+			return fmt.Sprintf("https://hub.docker.com/_/%s/tags?name=%s", name, url.QueryEscape(tag))
+		}
+		return fmt.Sprintf("https://hub.docker.com/r/%s/tags?name=%s", path, url.QueryEscape(tag))
+	}
+	return fmt.Sprintf("https://%s/%s", i.Registry, i.Path)
+}
+
+func (c *Client) ListTags(ctx context.Context, image Image) ([]string, error) {
+	switch image.Registry {
+	case dockerHub:
+		return c.listDockerHubTags(ctx, image)
+	case ghcr:
+		return c.listGHCRTags(ctx, image)
+	default:
+		return nil, fmt.Errorf("unsupported registry %q", image.Registry)
+	}
+}
+
+// listDockerHubTags returns the most recently pushed tags. Only the first
+// page is fetched since new tags always sort first.
+func (c *Client) listDockerHubTags(ctx context.Context, image Image) ([]string, error) {
+	var result struct {
+		Results []struct {
+			Name string `json:"name"`
+		} `json:"results"`
+	}
+
+	endpoint := fmt.Sprintf("https://hub.docker.com/v2/repositories/%s/tags?page_size=100&ordering=last_updated", image.Path)
+	if err := c.getJSON(ctx, endpoint, "", &result); err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0, len(result.Results))
+	for _, tag := range result.Results {
+		tags = append(tags, tag.Name)
+	}
+	return tags, nil
+}
+
+func (c *Client) listGHCRTags(ctx context.Context, image Image) ([]string, error) {
+	var auth struct {
+		Token string `json:"token"`
+	}
+	tokenURL := fmt.Sprintf("https://ghcr.io/token?scope=repository:%s:pull", image.Path)
+	if err := c.getJSON(ctx, tokenURL, "", &auth); err != nil {
+		return nil, fmt.Errorf("failed to get registry token: %v", err)
+	}
+
+	var tags []string
+	next := fmt.Sprintf("https://ghcr.io/v2/%s/tags/list?n=1000", image.Path)
+	for next != "" {
+		var page struct {
+			Tags []string `json:"tags"`
+		}
+		link, err := c.getJSONWithLink(ctx, next, auth.Token, &page)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, page.Tags...)
+		next = link
+	}
+
+	return tags, nil
+}
+
+func (c *Client) getJSON(ctx context.Context, endpoint, token string, v interface{}) error {
+	_, err := c.getJSONWithLink(ctx, endpoint, token, v)
+	return err
+}
+
+// getJSONWithLink decodes the response into v and returns the absolute URL
+// of the next page from the Link header, if any.
+func (c *Client) getJSONWithLink(ctx context.Context, endpoint, token string, v interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query registry: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("registry returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", fmt.Errorf("failed to decode registry response: %v", err)
+	}
+
+	return nextLink(req.URL, resp.Header.Get("Link")), nil
+}
+
+func nextLink(base *url.URL, header string) string {
+	for _, part := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		ref, err := url.Parse(strings.Trim(strings.TrimSpace(target), "<>"))
+		if err != nil {
+			return ""
+		}
+		return base.ResolveReference(ref).String()
+	}
+	return ""
+}
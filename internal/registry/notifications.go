@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+)
+
+// MatchTags returns the tags matching the glob filter, e.g. "v1.*". An empty
+// filter matches every tag.
+func MatchTags(tags []string, filter string) []string {
+	if filter == "" {
+		return tags
+	}
+
+	var matched []string
+	for _, tag := range tags {
+		if ok, _ := path.Match(filter, tag); ok {
+			matched = append(matched, tag)
+		}
+	}
+	return matched
+}
+
+// GetNotifications returns a notification for every matching tag of the
+// subscribed image that is not in seen.
+func (c *Client) GetNotifications(ctx context.Context, subscription models.ImageSubscription, seen map[string]bool) ([]models.Notification, []string, error) {
+	image, err := ParseImage(subscription.Image)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags, err := c.ListTags(ctx, image)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tags for %s: %v", image, err)
+	}
+
+	var notifications []models.Notification
+	var newTags []string
+	for _, tag := range MatchTags(tags, subscription.TagFilter) {
+		if seen[tag] {
+			continue
+		}
+
+		notification := models.Notification{
+			Type:   "image_tag",
+			Repo:   image.String(),
+			Fields: models.Fields{Version: tag},
+			URL:    image.TagURL(tag),
+		}
+		notification.Message = render.Text(notification)
+		notifications = append(notifications, notification)
+		newTags = append(newTags, tag)
+	}
+
+	return notifications, newTags, nil
+}
@@ -0,0 +1,262 @@
+// Package render turns notifications into message text for Telegram and
+// the other sinks. Messages are text/template templates over the
+// notification and its fields, with helpers for the formats channels
+// expect:
+//
+//	{{emoji .Type}} {{.Fields.Title | truncate 200 | escapeHTML}}
+//	{{.URL}} ({{relativeTime .OccurredAt}})
+//
+// Plain, MarkdownV2 and HTML are built in; they correspond to Telegram's
+// parse modes and can be picked per chat with the parse mode preference
+// and per sink where the sink supports it. They word each type of
+// notification from its fields the way Text does.
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// The built-in formats.
+const (
+	Plain      = "plain"
+	MarkdownV2 = "MarkdownV2"
+	HTML       = "HTML"
+)
+
+// Funcs are the helpers available to every template.
+var Funcs = template.FuncMap{
+	"truncate":       Truncate,
+	"escapeHTML":     html.EscapeString,
+	"escapeMarkdown": EscapeMarkdown,
+	"relativeTime":   relativeTime,
+	"emoji":          Emoji,
+	"text":           Text,
+}
+
+// wording words notifications per type from their fields, showing up to
+// 300 characters of the details, such as release notes. Notifications
+// without fields are their message.
+var wording = template.Must(template.New("wording").Funcs(template.FuncMap{"truncate": Truncate}).Parse(`
+{{- if .Fields.IsZero}}{{.Message}}{{else}}[{{.Repo}}] {{$f := .Fields}}
+{{- if eq .Type "new_pull_request"}}New PR #{{$f.Number}}: {{$f.Title}} by {{$f.Actor}}
+{{- else if eq .Type "merged_pull_request"}}Merged PR #{{$f.Number}}: {{$f.Title}} by {{$f.Actor}}
+{{- else if eq .Type "issue"}}Issue #{{$f.Number}}: {{$f.Title}}
+{{- else if eq .Type "release"}}New release: {{$f.Version}}{{with $f.Delta}} ({{.}}){{end}}
+{{- else if eq .Type "dependency_release"}}{{$f.Title}} {{$f.Version}} released (using {{$f.Previous}})
+{{- else if eq .Type "dependency_vulnerability"}}{{$f.Title}} {{$f.Version}} is affected by {{$f.Advisory}}{{with $f.Details}}: {{truncate 300 .}}{{end}}
+{{- else if eq .Type "image_tag"}}New image tag: {{$f.Version}}
+{{- else if eq .Type "gerrit_review_requested"}}Review requested on change {{$f.Number}}: {{$f.Title}} by {{$f.Actor}}
+{{- else if eq .Type "gerrit_patch_set"}}Patch set {{$f.Version}} uploaded on change {{$f.Number}}: {{$f.Title}} by {{$f.Actor}}
+{{- else if eq .Type "gerrit_label"}}Labels on change {{$f.Number}}: {{$f.Title}}
+{{- else}}{{$f.Title}}
+{{- end}}
+{{- if and $f.Details (ne .Type "dependency_vulnerability")}}
+{{truncate 300 $f.Details}}{{end}}
+{{- with $f.Summary}}
+
+Summary: {{.}}{{end}}
+{{- end}}`))
+
+// Text words the notification as plain text from its fields, as the
+// built-in formats do without the emoji and the link. Sources set Message
+// with it. Notifications without fields are their message.
+func Text(notification models.Notification) string {
+	var text strings.Builder
+	if err := wording.Execute(&text, notification); err != nil {
+		// The wording only reads fields every notification has.
+		return notification.Message
+	}
+	return text.String()
+}
+
+// New parses a template with the helpers in Funcs.
+func New(name, text string) (*template.Template, error) {
+	return template.New(name).Funcs(Funcs).Parse(text)
+}
+
+// Format renders notifications for one kind of channel.
+type Format struct {
+	name     string
+	template *template.Template
+	escape   func(string) string
+}
+
+var formats = map[string]*Format{
+	Plain: {
+		name:     Plain,
+		template: template.Must(New(Plain, "{{emoji .Type}} {{text .}}\n{{.URL}}")),
+		escape:   func(text string) string { return text },
+	},
+	MarkdownV2: {
+		name:     MarkdownV2,
+		template: template.Must(New(MarkdownV2, "{{emoji .Type}} {{text . | escapeMarkdown}}\n{{escapeMarkdown .URL}}")),
+		escape:   EscapeMarkdown,
+	},
+	HTML: {
+		name:     HTML,
+		template: template.Must(New(HTML, "{{emoji .Type}} {{text . | escapeHTML}}\n{{escapeHTML .URL}}")),
+		escape:   html.EscapeString,
+	},
+}
+
+// Lookup returns the built-in format with the name. An empty name is
+// Plain, the format of chats without a parse mode.
+func Lookup(name string) (*Format, error) {
+	if name == "" {
+		name = Plain
+	}
+	format, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q, expected %s, %s or %s", name, Plain, MarkdownV2, HTML)
+	}
+	return format, nil
+}
+
+// NewFormat parses a custom template rendering notifications in the
+// built-in format base, whose parse mode and escaping the format takes.
+// The template escapes what it prints itself, as in
+// {{escapeHTML .Fields.Title}} for HTML.
+func NewFormat(base, text string) (*Format, error) {
+	builtin, err := Lookup(base)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := New(builtin.name, text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %v", err)
+	}
+	return &Format{name: builtin.name, template: tmpl, escape: builtin.escape}, nil
+}
+
+// Name returns the format's name.
+func (f *Format) Name() string {
+	return f.name
+}
+
+// ParseMode returns the Telegram parse mode of messages in the format,
+// empty for plain text.
+func (f *Format) ParseMode() string {
+	if f.name == Plain {
+		return ""
+	}
+	return f.name
+}
+
+// Notification renders one notification.
+func (f *Format) Notification(notification models.Notification) (string, error) {
+	var text strings.Builder
+	if err := f.template.Execute(&text, notification); err != nil {
+		return "", fmt.Errorf("failed to render notification: %v", err)
+	}
+	return text.String(), nil
+}
+
+// Escape makes text safe to include in a message of the format as is.
+func (f *Format) Escape(text string) string {
+	return f.escape(text)
+}
+
+// Truncate shortens text to at most n characters, ending it with an
+// ellipsis when it was cut. It takes the length first so templates can
+// pipe into it: {{.Message | truncate 100}}.
+func Truncate(n int, text string) string {
+	if n <= 0 || utf8.RuneCountInString(text) <= n {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:n-1]) + "…"
+}
+
+var markdownEscaper = strings.NewReplacer(
+	"_", "\\_",
+	"*", "\\*",
+	"[", "\\[",
+	"]", "\\]",
+	"(", "\\(",
+	")", "\\)",
+	"~", "\\~",
+	"`", "\\`",
+	">", "\\>",
+	"#", "\\#",
+	"+", "\\+",
+	"-", "\\-",
+	"=", "\\=",
+	"|", "\\|",
+	"{", "\\{",
+	"}", "\\}",
+	".", "\\.",
+	"!", "\\!",
+)
+
+// EscapeMarkdown escapes the characters Telegram's MarkdownV2 reserves.
+func EscapeMarkdown(text string) string {
+	return markdownEscaper.Replace(text)
+}
+
+// relativeTime describes how long ago t was, such as "5 minutes ago", or
+// returns "" for the zero time of sources without event times.
+func relativeTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	elapsed := time.Since(t)
+	switch {
+	case elapsed < time.Minute:
+		return "just now"
+	case elapsed < time.Hour:
+		return ago(int(elapsed/time.Minute), "minute")
+	case elapsed < 24*time.Hour:
+		return ago(int(elapsed/time.Hour), "hour")
+	default:
+		return ago(int(elapsed/(24*time.Hour)), "day")
+	}
+}
+
+func ago(count int, unit string) string {
+	if count == 1 {
+		return "1 " + unit + " ago"
+	}
+	return fmt.Sprintf("%d %ss ago", count, unit)
+}
+
+var emojis = map[string]string{
+	"review_requested":         "👀",
+	"gerrit_review_requested":  "👀",
+	"mention":                  "💬",
+	"team_mention":             "💬",
+	"commit_mention":           "💬",
+	"comment":                  "💬",
+	"assign":                   "📌",
+	"author":                   "✍️",
+	"state_change":             "🔄",
+	"ci_activity":              "⚙️",
+	"security_alert":           "🚨",
+	"new_pull_request":         "🆕",
+	"merged_pull_request":      "✅",
+	"gerrit_patch_set":         "🆕",
+	"gerrit_label":             "🏷️",
+	"issue":                    "🐛",
+	"release":                  "🚀",
+	"image_tag":                "🐳",
+	"dependency_release":       "📦",
+	"path_change":              "📁",
+	"dependency_vulnerability": "🛡️",
+	"dependabot_alert":         "🛡️",
+	"code_scanning_alert":      "🛡️",
+}
+
+// Emoji returns an emoji for the notification type, a bell for types
+// without one of their own.
+func Emoji(notificationType string) string {
+	if emoji, ok := emojis[notificationType]; ok {
+		return emoji
+	}
+	return "🔔"
+}
@@ -0,0 +1,232 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification models.Notification
+		want         string
+	}{
+		{
+			"without fields",
+			models.Notification{Type: "commit_mention", Message: "[octo/app] Commit abc mentions alice"},
+			"[octo/app] Commit abc mentions alice",
+		},
+		{
+			"thread",
+			models.Notification{Type: "mention", Repo: "octo/app", Fields: models.Fields{Title: "Crash on start"}},
+			"[octo/app] Crash on start",
+		},
+		{
+			"thread with a summary",
+			models.Notification{Type: "mention", Repo: "octo/app", Fields: models.Fields{Title: "Crash on start", Summary: "A nil map."}},
+			"[octo/app] Crash on start\n\nSummary: A nil map.",
+		},
+		{
+			"new pull request",
+			models.Notification{Type: "new_pull_request", Repo: "octo/app", Fields: models.Fields{Title: "Add retries", Number: 42, Actor: "alice"}},
+			"[octo/app] New PR #42: Add retries by alice",
+		},
+		{
+			"merged pull request",
+			models.Notification{Type: "merged_pull_request", Repo: "octo/app", Fields: models.Fields{Title: "Add retries", Number: 42, Actor: "alice"}},
+			"[octo/app] Merged PR #42: Add retries by alice",
+		},
+		{
+			"issue",
+			models.Notification{Type: "issue", Repo: "octo/app", Fields: models.Fields{Title: "Flaky test", Number: 7}},
+			"[octo/app] Issue #7: Flaky test",
+		},
+		{
+			"release",
+			models.Notification{Type: "release", Repo: "octo/app", Fields: models.Fields{Version: "v1.3.0", Delta: "minor update from v1.2.4", Details: "Faster startup."}},
+			"[octo/app] New release: v1.3.0 (minor update from v1.2.4)\nFaster startup.",
+		},
+		{
+			"first release",
+			models.Notification{Type: "release", Repo: "octo/app", Fields: models.Fields{Version: "v0.1.0"}},
+			"[octo/app] New release: v0.1.0",
+		},
+		{
+			"dependency release",
+			models.Notification{Type: "dependency_release", Repo: "octo/app", Fields: models.Fields{Title: "lodash", Version: "4.17.21", Previous: "4.17.20"}},
+			"[octo/app] lodash 4.17.21 released (using 4.17.20)",
+		},
+		{
+			"vulnerability",
+			models.Notification{Type: "dependency_vulnerability", Repo: "octo/app", Fields: models.Fields{Title: "lodash", Version: "4.17.20", Advisory: "GHSA-1 (CVE-2)", Details: "Prototype pollution"}},
+			"[octo/app] lodash 4.17.20 is affected by GHSA-1 (CVE-2): Prototype pollution",
+		},
+		{
+			"image tag",
+			models.Notification{Type: "image_tag", Repo: "library/nginx", Fields: models.Fields{Version: "1.27"}},
+			"[library/nginx] New image tag: 1.27",
+		},
+		{
+			"gerrit patch set",
+			models.Notification{Type: "gerrit_patch_set", Repo: "infra", Fields: models.Fields{Title: "Bump", Number: 12, Actor: "bob", Version: "3"}},
+			"[infra] Patch set 3 uploaded on change 12: Bump by bob",
+		},
+		{
+			"gerrit labels",
+			models.Notification{Type: "gerrit_label", Repo: "infra", Fields: models.Fields{Title: "Bump", Number: 12, Details: "Code-Review+2"}},
+			"[infra] Labels on change 12: Bump\nCode-Review+2",
+		},
+		{
+			"long details",
+			models.Notification{Type: "release", Repo: "octo/app", Fields: models.Fields{Version: "v2", Details: strings.Repeat("a", 400)}},
+			"[octo/app] New release: v2\n" + strings.Repeat("a", 299) + "…",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.notification); got != tt.want {
+				t.Errorf("Text = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFormatsEscape checks that each built-in format escapes what its
+// parse mode reserves, in the fields as well as in the URL.
+func TestFormatsEscape(t *testing.T) {
+	notification := models.Notification{
+		Type:   "issue",
+		Repo:   "octo/app",
+		Fields: models.Fields{Title: "Fix <b> & [links] (v1.2)!", Number: 7},
+		URL:    "https://github.com/octo/app/issues/7?a=1&b=2",
+	}
+	tests := []struct {
+		format    string
+		parseMode string
+		want      string
+	}{
+		{Plain, "", "🐛 [octo/app] Issue #7: Fix <b> & [links] (v1.2)!\nhttps://github.com/octo/app/issues/7?a=1&b=2"},
+		{MarkdownV2, MarkdownV2, "🐛 \\[octo/app\\] Issue \\#7: Fix <b\\> & \\[links\\] \\(v1\\.2\\)\\!\nhttps://github\\.com/octo/app/issues/7?a\\=1&b\\=2"},
+		{HTML, HTML, "🐛 [octo/app] Issue #7: Fix &lt;b&gt; &amp; [links] (v1.2)!\nhttps://github.com/octo/app/issues/7?a=1&amp;b=2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			format, err := Lookup(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format.ParseMode() != tt.parseMode {
+				t.Errorf("ParseMode = %q, want %q", format.ParseMode(), tt.parseMode)
+			}
+			got, err := format.Notification(notification)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Notification =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if format, err := Lookup(""); err != nil || format.Name() != Plain {
+		t.Errorf("Lookup(\"\") = %v, %v, want plain", format, err)
+	}
+	if _, err := Lookup("markdown"); err == nil {
+		t.Error("Lookup must reject unknown formats")
+	}
+}
+
+func TestNewFormat(t *testing.T) {
+	format, err := NewFormat(HTML, `<b>{{escapeHTML .Fields.Title}}</b> {{relativeTime .OccurredAt}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := format.Notification(models.Notification{Fields: models.Fields{Title: "a < b"}, OccurredAt: time.Now().Add(-2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<b>a &lt; b</b> 2 hours ago"; got != want {
+		t.Errorf("Notification = %q, want %q", got, want)
+	}
+	if format.ParseMode() != HTML || format.Escape("<") != "&lt;" {
+		t.Error("a custom format must take the parse mode and escaping of its base")
+	}
+}
+
+// TestNewFormatErrors checks the errors of custom templates: unknown base
+// formats and templates that do not parse are rejected up front, and
+// templates that fail on a notification fail its rendering.
+func TestNewFormatErrors(t *testing.T) {
+	if _, err := NewFormat("markdown", "{{.Message}}"); err == nil {
+		t.Error("NewFormat must reject unknown base formats")
+	}
+	if _, err := NewFormat(Plain, "{{.Message"); err == nil || !strings.Contains(err.Error(), "invalid template") {
+		t.Errorf("NewFormat of a malformed template = %v, want an invalid template error", err)
+	}
+	if _, err := NewFormat(Plain, "{{shout .Message}}"); err == nil {
+		t.Error("NewFormat must reject unknown functions")
+	}
+
+	for _, text := range []string{"{{.Fields.Missing}}", `{{truncate "ten" .Message}}`} {
+		format, err := NewFormat(Plain, text)
+		if err != nil {
+			t.Fatalf("NewFormat(%q): %v", text, err)
+		}
+		if _, err := format.Notification(models.Notification{Message: "hello"}); err == nil || !strings.Contains(err.Error(), "failed to render notification") {
+			t.Errorf("rendering %q = %v, want a render error", text, err)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		n    int
+		text string
+		want string
+	}{
+		{5, "short", "short"},
+		{5, "longer text", "long…"},
+		{3, "äöüß", "äö…"},
+		{0, "unlimited", "unlimited"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.n, tt.text); got != tt.want {
+			t.Errorf("Truncate(%d, %q) = %q, want %q", tt.n, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRelativeTime(t *testing.T) {
+	tests := []struct {
+		ago  time.Duration
+		want string
+	}{
+		{10 * time.Second, "just now"},
+		{time.Minute + time.Second, "1 minute ago"},
+		{5*time.Minute + time.Second, "5 minutes ago"},
+		{3*time.Hour + time.Second, "3 hours ago"},
+		{49 * time.Hour, "2 days ago"},
+	}
+	for _, tt := range tests {
+		if got := relativeTime(time.Now().Add(-tt.ago)); got != tt.want {
+			t.Errorf("relativeTime(-%v) = %q, want %q", tt.ago, got, tt.want)
+		}
+	}
+	if got := relativeTime(time.Time{}); got != "" {
+		t.Errorf("relativeTime of the zero time = %q, want empty", got)
+	}
+}
+
+func TestEmoji(t *testing.T) {
+	if got := Emoji("review_requested"); got != "👀" {
+		t.Errorf("Emoji(review_requested) = %q", got)
+	}
+	if got := Emoji("unknown"); got != "🔔" {
+		t.Errorf("Emoji of an unknown type = %q, want the bell", got)
+	}
+}
@@ -0,0 +1,271 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	vaultScheme = "vault://"
+	awsScheme   = "awssm://"
+)
+
+// Manager resolves configuration values that reference a secrets manager
+// instead of holding the secret itself:
+//
+//	vault://secret/data/monitor#telegram_token
+//	awssm://prod/monitor#database_url
+//
+// The part after "#" selects a key of a JSON/KV secret; without it the whole
+// secret value is used. Plain values are returned unchanged.
+type Manager struct {
+	mu       sync.Mutex
+	vault    *vaultClient
+	aws      *awsClient
+	leases   []lease
+	onChange func(ref string)
+}
+
+// lease is a leased Vault secret, with the reference it was resolved from
+// and the value it had, to read it again when the lease runs out.
+type lease struct {
+	id        string
+	ref       string
+	value     string
+	duration  time.Duration
+	expires   time.Time
+	renewable bool
+}
+
+// reresolveBefore is how long before its lease expires a secret is read
+// again. Renewal passes are at least a minute apart, so a lease that has
+// less left might expire before the next pass.
+const reresolveBefore = 2 * time.Minute
+
+func NewManager() *Manager {
+	return &Manager{}
+}
+
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, vaultScheme) || strings.HasPrefix(value, awsScheme)
+}
+
+// OnChange registers f to be called with the reference of a leased secret
+// whose value changed when it was read again on lease expiry. Values
+// already resolved are not updated, so f typically restarts the process.
+func (m *Manager) OnChange(f func(ref string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = f
+}
+
+func (m *Manager) Resolve(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, vaultScheme):
+		vault, err := m.vaultClient()
+		if err != nil {
+			return "", err
+		}
+		resolved, l, err := readVault(ctx, vault, value)
+		if err != nil {
+			return "", err
+		}
+		if l.id != "" {
+			m.mu.Lock()
+			m.leases = append(m.leases, l)
+			m.mu.Unlock()
+		}
+		return resolved, nil
+	case strings.HasPrefix(value, awsScheme):
+		id, key := splitReference(strings.TrimPrefix(value, awsScheme))
+		aws, err := m.awsClient(ctx)
+		if err != nil {
+			return "", err
+		}
+		secret, err := aws.read(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if key == "" {
+			return secret, nil
+		}
+		data, err := decodeJSONSecret(secret)
+		if err != nil {
+			return "", fmt.Errorf("secret %s is not a JSON object: %v", id, err)
+		}
+		return pick(data, key, value)
+	default:
+		return value, nil
+	}
+}
+
+// readVault resolves a vault:// reference, returning the secret's lease
+// if it has one.
+func readVault(ctx context.Context, vault *vaultClient, ref string) (string, lease, error) {
+	path, key := splitReference(strings.TrimPrefix(ref, vaultScheme))
+	secret, err := vault.read(ctx, path)
+	if err != nil {
+		return "", lease{}, err
+	}
+	value, err := pick(secret.Data, key, ref)
+	if err != nil {
+		return "", lease{}, err
+	}
+	l := lease{
+		id:        secret.LeaseID,
+		ref:       ref,
+		value:     value,
+		duration:  secret.LeaseDuration,
+		expires:   time.Now().Add(secret.LeaseDuration),
+		renewable: secret.Renewable,
+	}
+	return value, l, nil
+}
+
+// RenewLeases keeps the Vault token and any leased secrets alive until ctx
+// is cancelled. Secrets whose lease can no longer be renewed, because it
+// is not renewable, reached its maximum TTL or renewing failed, are read
+// again before it expires, reporting changed values to the OnChange
+// function. It returns immediately when Vault is not in use.
+func (m *Manager) RenewLeases(ctx context.Context) {
+	m.mu.Lock()
+	vault := m.vault
+	m.mu.Unlock()
+	if vault == nil {
+		return
+	}
+
+	for {
+		next := m.renew(ctx, vault)
+
+		// Renew at two thirds of the shortest TTL so a failed attempt still
+		// leaves time for a retry before anything expires.
+		wait := next * 2 / 3
+		if wait < time.Minute {
+			wait = time.Minute
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// renew makes one renewal pass over the Vault token and the leases, and
+// returns the shortest time any of them stays valid.
+func (m *Manager) renew(ctx context.Context, vault *vaultClient) time.Duration {
+	next := vault.renewSelf(ctx)
+
+	m.mu.Lock()
+	leases := append([]lease(nil), m.leases...)
+	onChange := m.onChange
+	m.mu.Unlock()
+
+	var changed []string
+	kept := leases[:0]
+	for _, l := range leases {
+		if l.renewable {
+			duration, err := vault.renewLease(ctx, l.id, l.duration)
+			if err != nil {
+				log.Printf("Warning: failed to renew Vault lease: %v", err)
+			} else {
+				l.duration = duration
+				l.expires = time.Now().Add(duration)
+			}
+		}
+
+		if time.Until(l.expires) < reresolveBefore {
+			value, renewed, err := readVault(ctx, vault, l.ref)
+			if err != nil {
+				log.Printf("Warning: failed to read secret %s again: %v", redact(l.ref), err)
+			} else {
+				if value != l.value {
+					changed = append(changed, l.ref)
+				}
+				l = renewed
+			}
+		}
+
+		if l.id == "" {
+			// Read again without a lease, there is nothing left to renew.
+			continue
+		}
+		if remaining := time.Until(l.expires); remaining < next {
+			next = remaining
+		}
+		kept = append(kept, l)
+	}
+
+	m.mu.Lock()
+	m.leases = kept
+	m.mu.Unlock()
+
+	for _, ref := range changed {
+		log.Printf("Secret %s changed on lease expiry", redact(ref))
+		if onChange != nil {
+			onChange(ref)
+		}
+	}
+	return next
+}
+
+func (m *Manager) vaultClient() (*vaultClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.vault == nil {
+		vault, err := newVaultClient()
+		if err != nil {
+			return nil, err
+		}
+		m.vault = vault
+	}
+	return m.vault, nil
+}
+
+func (m *Manager) awsClient(ctx context.Context) (*awsClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.aws == nil {
+		aws, err := newAWSClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.aws = aws
+	}
+	return m.aws, nil
+}
+
+func splitReference(ref string) (path, key string) {
+	path, key, _ = strings.Cut(ref, "#")
+	return path, key
+}
+
+func pick(data map[string]interface{}, key, ref string) (string, error) {
+	if key == "" {
+		if len(data) != 1 {
+			return "", fmt.Errorf("secret %s has %d keys, select one with #key", redact(ref), len(data))
+		}
+		for _, value := range data {
+			return fmt.Sprint(value), nil
+		}
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %q", redact(ref), key)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// redact keeps references readable in errors without the selected key.
+func redact(ref string) string {
+	path, _ := splitReference(ref)
+	return path
+}
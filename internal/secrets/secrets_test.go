@@ -0,0 +1,189 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVault serves the secrets in its map, keyed by path, and renews
+// leases for renewal.
+type fakeVault struct {
+	mu       sync.Mutex
+	secrets  map[string]vaultResponse
+	reads    map[string]int
+	renewal  int
+	renewals int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if r.Header.Get("X-Vault-Token") != "test-token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(vaultResponse{Errors: []string{"permission denied"}})
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch path {
+	case "auth/token/renew-self":
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(vaultResponse{Errors: []string{"token not renewable"}})
+		return
+	case "sys/leases/renew":
+		v.renewals++
+		json.NewEncoder(w).Encode(vaultResponse{LeaseDuration: v.renewal})
+		return
+	}
+
+	secret, ok := v.secrets[path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(vaultResponse{Errors: []string{}})
+		return
+	}
+	v.reads[path]++
+	json.NewEncoder(w).Encode(secret)
+}
+
+func (v *fakeVault) set(path string, secret vaultResponse) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.secrets[path] = secret
+}
+
+func newFakeVault(t *testing.T) *fakeVault {
+	t.Helper()
+	vault := &fakeVault{
+		secrets: map[string]vaultResponse{
+			"secret/data/monitor": {Data: map[string]interface{}{
+				"data":     map[string]interface{}{"telegram_token": "123:abc", "api_token": "s3cret"},
+				"metadata": map[string]interface{}{"version": 3},
+			}},
+			"kv/monitor": {Data: map[string]interface{}{"redis_url": "redis://cache:6379/0"}},
+		},
+		reads: map[string]int{},
+	}
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL+"/")
+	t.Setenv("VAULT_TOKEN", "test-token")
+	return vault
+}
+
+func TestResolve(t *testing.T) {
+	newFakeVault(t)
+	tests := []struct {
+		value   string
+		want    string
+		wantErr string
+	}{
+		{value: "plain-value", want: "plain-value"},
+		{value: "vault://secret/data/monitor#telegram_token", want: "123:abc"},
+		{value: "vault://kv/monitor#redis_url", want: "redis://cache:6379/0"},
+		{value: "vault://kv/monitor", want: "redis://cache:6379/0"},
+		{value: "vault://secret/data/monitor", wantErr: "secret vault://secret/data/monitor has 2 keys, select one with #key"},
+		{value: "vault://secret/data/monitor#missing", wantErr: `secret vault://secret/data/monitor has no key "missing"`},
+		{value: "vault://secret/data/other#key", wantErr: "Vault returned 404 Not Found for secret/data/other"},
+	}
+	manager := NewManager()
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := manager.Resolve(context.Background(), tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve = %q, %v, want error %q", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("Resolve = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveVaultWithoutConfig(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+	if _, err := NewManager().Resolve(context.Background(), "vault://kv/monitor"); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR and VAULT_TOKEN") {
+		t.Errorf("Resolve without Vault settings = %v, want an error naming them", err)
+	}
+}
+
+// TestRenewReresolves checks that a renewable lease is renewed, while a
+// secret whose lease runs out is read again and reported when its value
+// changed.
+func TestRenewReresolves(t *testing.T) {
+	vault := newFakeVault(t)
+	vault.renewal = 3600
+	vault.set("database/creds/monitor", vaultResponse{
+		Data: map[string]interface{}{"password": "first"}, LeaseID: "database/creds/monitor/1", LeaseDuration: 60,
+	})
+	vault.set("database/creds/renewable", vaultResponse{
+		Data: map[string]interface{}{"password": "kept"}, LeaseID: "database/creds/renewable/1", LeaseDuration: 60, Renewable: true,
+	})
+
+	ctx := context.Background()
+	manager := NewManager()
+	var changed []string
+	manager.OnChange(func(ref string) { changed = append(changed, ref) })
+	for _, ref := range []string{"vault://database/creds/monitor#password", "vault://database/creds/renewable#password"} {
+		if _, err := manager.Resolve(ctx, ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vault.set("database/creds/monitor", vaultResponse{
+		Data: map[string]interface{}{"password": "second"}, LeaseID: "database/creds/monitor/2", LeaseDuration: 3600,
+	})
+	next := manager.renew(ctx, manager.vault)
+
+	if vault.reads["database/creds/monitor"] != 2 || vault.reads["database/creds/renewable"] != 1 {
+		t.Errorf("reads = %v, want the expiring secret read again and the renewed one not", vault.reads)
+	}
+	if vault.renewals != 1 {
+		t.Errorf("renewed %d leases, want 1", vault.renewals)
+	}
+	if len(changed) != 1 || changed[0] != "vault://database/creds/monitor#password" {
+		t.Errorf("changed = %v, want the expired secret", changed)
+	}
+	if next < time.Hour-time.Minute || next > time.Hour {
+		t.Errorf("next renewal in %v, want about an hour", next)
+	}
+	if len(manager.leases) != 2 || manager.leases[0].id != "database/creds/monitor/2" {
+		t.Errorf("leases = %+v, want the new lease of the secret read again", manager.leases)
+	}
+
+	// Read again with the same value, nothing is reported.
+	vault.set("database/creds/monitor", vaultResponse{
+		Data: map[string]interface{}{"password": "second"}, LeaseID: "database/creds/monitor/3", LeaseDuration: 60,
+	})
+	manager.leases[0].expires = time.Now()
+	manager.renew(ctx, manager.vault)
+	if len(changed) != 1 {
+		t.Errorf("changed = %v after reading an unchanged secret", changed)
+	}
+}
+
+func TestPickJSONSecret(t *testing.T) {
+	data, err := decodeJSONSecret(`{"database_url": "postgres://db/monitor", "port": 5432}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := pick(data, "port", "awssm://prod/monitor#port"); err != nil || got != "5432" {
+		t.Errorf("pick(port) = %q, %v", got, err)
+	}
+	if _, err := pick(data, "missing", "awssm://prod/monitor#missing"); err == nil || strings.Contains(err.Error(), "#missing") {
+		t.Errorf("pick(missing) = %v, want an error without the key in the reference", err)
+	}
+	if _, err := decodeJSONSecret("not json"); err == nil {
+		t.Error("decodeJSONSecret must reject values that are not JSON objects")
+	}
+}
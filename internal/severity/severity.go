@@ -0,0 +1,188 @@
+// Package severity classifies notifications as critical, high, normal or
+// low, so delivery can treat them accordingly: low severity notifications
+// are held back first when delivery falls behind.
+//
+// A notification gets the severity of the first rule it matches, or the
+// default of its type. Rules are configured with SEVERITY_RULES, separated
+// by semicolons, each a comma-separated list of conditions followed by a
+// colon and the severity:
+//
+//	label=security:critical; repo=acme/*,type=ci_activity:high; keyword=prod down:critical
+//
+// A rule matches when all of its conditions do: type is the notification
+// type, repo an "owner/repo" pattern as in path.Match, label one of the
+// issue or pull request labels, and keyword text contained in the message,
+// ignoring case.
+//
+// Notifications no rule matches are raised above the default of their type
+// when their message carries urgency markers such as "prod down" or
+// "hotfix": one level for one marker, two levels for more.
+package severity
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// defaults are the severities of notification types no rule matches.
+// Other types are normal.
+var defaults = map[string]string{
+	"security_alert":           models.SeverityCritical,
+	"review_requested":         models.SeverityHigh,
+	"mention":                  models.SeverityHigh,
+	"team_mention":             models.SeverityHigh,
+	"commit_mention":           models.SeverityHigh,
+	"assign":                   models.SeverityHigh,
+	"gerrit_review_requested":  models.SeverityHigh,
+	"sla_breach":               models.SeverityHigh,
+	"quota_warning":            models.SeverityHigh,
+	"dependency_vulnerability": models.SeverityHigh,
+	"dependabot_alert":         models.SeverityHigh,
+	"code_scanning_alert":      models.SeverityHigh,
+	"ci_activity":              models.SeverityLow,
+	"subscribed":               models.SeverityLow,
+	"image_tag":                models.SeverityLow,
+	"dependency_release":       models.SeverityLow,
+}
+
+// DefaultUrgencyMarkers are the urgency markers used unless
+// URGENCY_MARKERS replaces them.
+var DefaultUrgencyMarkers = []string{
+	"prod down", "production down", "outage", "security", "vulnerability",
+	"blocker", "hotfix", "urgent", "data loss",
+}
+
+// levels are the severities from lowest to highest.
+var levels = []string{models.SeverityLow, models.SeverityNormal, models.SeverityHigh, models.SeverityCritical}
+
+// Rule assigns a severity to the notifications matching its conditions.
+type Rule struct {
+	models.Conditions
+	Severity string
+}
+
+// Classify returns the severity of the first rule the notification
+// matches, or the default of its type raised by the urgency markers in its
+// message.
+func Classify(rules []Rule, markers []string, n models.Notification) string {
+	for _, rule := range rules {
+		if rule.Matches(n) {
+			return rule.Severity
+		}
+	}
+	severity, ok := defaults[n.Type]
+	if !ok {
+		severity = models.SeverityNormal
+	}
+	return raise(severity, min(Urgency(markers, n.Message), 2))
+}
+
+// Urgency returns how many of the markers the text contains as whole
+// words, ignoring case.
+func Urgency(markers []string, text string) int {
+	text = strings.ToLower(text)
+	found := 0
+	for _, marker := range markers {
+		if containsWords(text, strings.ToLower(marker)) {
+			found++
+		}
+	}
+	return found
+}
+
+// containsWords reports whether phrase, or its plural, occurs in text not
+// surrounded by letters or digits, so "security" is not found in
+// "insecurity".
+func containsWords(text, phrase string) bool {
+	for start := 0; phrase != ""; {
+		i := strings.Index(text[start:], phrase)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(phrase)
+		if end < len(text) && text[end] == 's' {
+			// Plurals such as "blockers" count too.
+			end++
+		}
+		if !isWordByte(text, i-1) && !isWordByte(text, end) {
+			return true
+		}
+		start = i + 1
+	}
+	return false
+}
+
+func isWordByte(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	r := rune(text[i])
+	return r >= 0x80 || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// raise returns the severity the given number of levels higher, at most
+// critical.
+func raise(severity string, by int) string {
+	for i, level := range levels {
+		if level == severity {
+			return levels[min(i+by, len(levels)-1)]
+		}
+	}
+	return severity
+}
+
+// ParseRules parses rules in the format of SEVERITY_RULES.
+func ParseRules(value string) ([]Rule, error) {
+	var rules []Rule
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		rule, err := parseRule(entry)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseRule(entry string) (Rule, error) {
+	i := strings.LastIndex(entry, ":")
+	if i < 0 {
+		return Rule{}, fmt.Errorf("rule %q has no severity, as in label=security:critical", entry)
+	}
+	rule := Rule{Severity: strings.TrimSpace(entry[i+1:])}
+	if !models.ValidSeverity(rule.Severity) {
+		return Rule{}, fmt.Errorf("rule %q has severity %q, expected critical, high, normal or low", entry, rule.Severity)
+	}
+
+	for _, condition := range strings.Split(entry[:i], ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(condition), "=")
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("rule %q has condition %q, expected type=, repo=, label= or keyword=", entry, condition)
+		}
+		switch key {
+		case "type":
+			rule.Type = value
+		case "repo":
+			rule.Repo = value
+		case "label":
+			rule.Label = value
+		case "keyword":
+			rule.Keyword = value
+		default:
+			return Rule{}, fmt.Errorf("rule %q has condition %q, expected type=, repo=, label= or keyword=", entry, condition)
+		}
+	}
+	if err := rule.Validate(); err != nil {
+		return Rule{}, fmt.Errorf("rule %q: %v", entry, err)
+	}
+	return rule, nil
+}
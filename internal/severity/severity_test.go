@@ -0,0 +1,137 @@
+package severity
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+func TestClassify(t *testing.T) {
+	rules, err := ParseRules("label=security:critical; repo=acme/*,type=ci_activity:high; keyword=Release Train:low")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		notification models.Notification
+		want         string
+	}{
+		{"critical default", models.Notification{Type: "security_alert"}, models.SeverityCritical},
+		{"high default", models.Notification{Type: "review_requested"}, models.SeverityHigh},
+		{"low default", models.Notification{Type: "ci_activity", Repo: "other/api"}, models.SeverityLow},
+		{"unknown type", models.Notification{Type: "state_change"}, models.SeverityNormal},
+		{"label rule", models.Notification{Type: "ci_activity", Labels: []string{"Security"}}, models.SeverityCritical},
+		{"all conditions", models.Notification{Type: "ci_activity", Repo: "acme/api"}, models.SeverityHigh},
+		{"one condition of two", models.Notification{Type: "issue", Repo: "acme/api"}, models.SeverityNormal},
+		{"keyword ignoring case", models.Notification{Type: "mention", Message: "[acme/web] Next release train"}, models.SeverityLow},
+		// The first matching rule wins, here over the repo rule.
+		{"first rule", models.Notification{Type: "ci_activity", Repo: "acme/api", Labels: []string{"security"}}, models.SeverityCritical},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(rules, nil, tt.notification); got != tt.want {
+				t.Errorf("Classify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules(" type=mention , repo=acme/* : high ;; keyword=prod down:critical;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Rule{
+		{Conditions: models.Conditions{Type: "mention", Repo: "acme/*"}, Severity: models.SeverityHigh},
+		{Conditions: models.Conditions{Keyword: "prod down"}, Severity: models.SeverityCritical},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("ParseRules = %+v, want %+v", rules, want)
+	}
+	if rules, err := ParseRules(""); err != nil || rules != nil {
+		t.Errorf("ParseRules(\"\") = %v, %v, want no rules", rules, err)
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"label=security", `rule "label=security" has no severity, as in label=security:critical`},
+		{"label=security:urgent", `rule "label=security:urgent" has severity "urgent", expected critical, high, normal or low`},
+		{"author=bot:low", `rule "author=bot:low" has condition "author=bot", expected type=, repo=, label= or keyword=`},
+		{"type=:low", `rule "type=:low" has condition "type=", expected type=, repo=, label= or keyword=`},
+		{"security:low", `rule "security:low" has condition "security", expected type=, repo=, label= or keyword=`},
+		{"repo=acme/[:low", `rule "repo=acme/[:low": invalid repository pattern "acme/["`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			_, err := ParseRules("type=mention:high;" + tt.value)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseRules error = %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUrgency(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"Fix typo in README", 0},
+		{"URGENT: deploy is failing", 1},
+		{"Two blockers left before the release", 1},
+		{"Reduce insecurity in the setup guide", 0},
+		{"Rename securityContext in the chart", 0},
+		{"Outages in eu-west", 1},
+		{"Production is down", 0},
+		{"Prod down after the hotfix", 2},
+		{"(security) vulnerability in the parser, urgent", 3},
+		{"Restore data-loss check", 0},
+		{"Recover from data loss", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			if got := Urgency(DefaultUrgencyMarkers, tt.text); got != tt.want {
+				t.Errorf("Urgency = %d, want %d", got, tt.want)
+			}
+		})
+	}
+	if got := Urgency(nil, "prod down"); got != 0 {
+		t.Errorf("Urgency without markers = %d, want 0", got)
+	}
+}
+
+func TestClassifyEscalation(t *testing.T) {
+	rules, err := ParseRules("repo=acme/docs:low")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		notification models.Notification
+		markers      []string
+		want         string
+	}{
+		{"no markers", models.Notification{Type: "issue", Message: "Typo"}, DefaultUrgencyMarkers, models.SeverityNormal},
+		{"one marker", models.Notification{Type: "issue", Message: "Hotfix needed"}, DefaultUrgencyMarkers, models.SeverityHigh},
+		{"two markers", models.Notification{Type: "ci_activity", Message: "Urgent hotfix failed"}, DefaultUrgencyMarkers, models.SeverityHigh},
+		{"at most two levels", models.Notification{Type: "ci_activity", Message: "Urgent: outage, prod down"}, DefaultUrgencyMarkers, models.SeverityHigh},
+		{"capped at critical", models.Notification{Type: "mention", Message: "Urgent hotfix"}, DefaultUrgencyMarkers, models.SeverityCritical},
+		{"markers off", models.Notification{Type: "issue", Message: "Urgent hotfix"}, nil, models.SeverityNormal},
+		{"custom markers", models.Notification{Type: "issue", Message: "Sev1 in billing"}, []string{"sev1"}, models.SeverityHigh},
+		// A matching rule decides the severity, markers or not.
+		{"rule wins", models.Notification{Type: "issue", Repo: "acme/docs", Message: "Urgent hotfix"}, DefaultUrgencyMarkers, models.SeverityLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(rules, tt.markers, tt.notification); got != tt.want {
+				t.Errorf("Classify = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+// Package execsink delivers notifications to an external program, so
+// channels can be added in any language. The sink is enabled with
+// exec:[format=<format>] [template=<file>] <command> in SINKS; the
+// command is split on spaces and run without a shell, once per
+// notification. It gets the notification as a JSON object on stdin, with
+// text rendered in the format, plain by default, or with the
+// text/template in the file, see package render:
+//
+//	{"id": "github-5f0c...", "chat_id": 123, "type": "review_requested", "repo": "owner/name",
+//	 "message": "...", "url": "https://...", "occurred_at": "2024-01-02T15:04:05Z",
+//	 "severity": "high", "text": "..."}
+//
+// The id is the same whenever the event is delivered again, such as after
+// a retry, so the program can use it as an idempotency key. The program
+// reports success by exiting with status 0. Anything else is a failed
+// delivery, described by the first line of what it wrote to stderr.
+package execsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/sink"
+)
+
+// timeout bounds a single run of the program.
+const timeout = 30 * time.Second
+
+func init() {
+	sink.Register("exec", New)
+}
+
+// Message is what the program reads from stdin.
+type Message struct {
+	ID         string     `json:"id"`
+	ChatID     int64      `json:"chat_id"`
+	Type       string     `json:"type"`
+	Repo       string     `json:"repo"`
+	Message    string     `json:"message"`
+	URL        string     `json:"url"`
+	OccurredAt *time.Time `json:"occurred_at,omitempty"`
+	Severity   string     `json:"severity,omitempty"`
+	Text       string     `json:"text"`
+}
+
+type execSink struct {
+	path   string
+	args   []string
+	format *render.Format
+}
+
+// New returns a sink running the command line in config.
+func New(config string) (sink.Sink, error) {
+	fields := strings.Fields(config)
+	formatName, templatePath := render.Plain, ""
+	for len(fields) > 0 {
+		if name, ok := strings.CutPrefix(fields[0], "format="); ok {
+			formatName = name
+		} else if path, ok := strings.CutPrefix(fields[0], "template="); ok {
+			templatePath = path
+		} else {
+			break
+		}
+		fields = fields[1:]
+	}
+	format, err := render.Lookup(formatName)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec sink: %v", err)
+	}
+	if templatePath != "" {
+		text, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("invalid exec sink: %v", err)
+		}
+		if format, err = render.NewFormat(formatName, string(text)); err != nil {
+			return nil, fmt.Errorf("invalid exec sink: %s: %v", templatePath, err)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("invalid exec sink: a command is required, as in exec:/path/to/program")
+	}
+	path, err := exec.LookPath(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid exec sink: %v", err)
+	}
+	return &execSink{path: path, args: fields[1:], format: format}, nil
+}
+
+func (s *execSink) Deliver(ctx context.Context, chatID int64, notification models.Notification) error {
+	message := Message{
+		ID:       notification.IdempotencyKey(),
+		ChatID:   chatID,
+		Type:     notification.Type,
+		Repo:     notification.Repo,
+		Message:  notification.Message,
+		URL:      notification.URL,
+		Severity: notification.Severity,
+	}
+	if !notification.OccurredAt.IsZero() {
+		message.OccurredAt = &notification.OccurredAt
+	}
+	text, err := s.format.Notification(notification)
+	if err != nil {
+		return err
+	}
+	message.Text = text
+	input, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, s.path, s.args...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if line, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); line != "" {
+			return fmt.Errorf("%s: %v: %s", s.path, err, line)
+		}
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	return nil
+}
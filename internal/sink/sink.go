@@ -0,0 +1,95 @@
+// Package sink lets delivery channels plug into the dispatcher, the way
+// the source package does for event sources. A sink is a package that
+// registers itself from an init function:
+//
+//	func init() {
+//		sink.Register("slack", func(config string) (sink.Sink, error) {
+//			return newSlack(config)
+//		})
+//	}
+//
+// and is compiled in with a blank import in cmd/monitor/sinks.go. Sinks
+// are enabled with SINKS, a semicolon-separated list of name or
+// name:config entries, such as "exec:/usr/local/bin/notify --team ops".
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// Sink delivers notifications to a channel besides Telegram. It is also
+// the Sink of the embeddable engine in pkg/monitor, so sinks written for
+// one work with the other.
+type Sink interface {
+	Deliver(ctx context.Context, chatID int64, notification models.Notification) error
+}
+
+// Factory creates a sink from the configuration after its name in SINKS,
+// which is empty when there is none.
+type Factory func(config string) (Sink, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a sink available to SINKS. It panics when the name is
+// empty, contains a colon or is already registered, as that is a
+// programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || strings.Contains(name, ":") || factory == nil {
+		panic("sink: Register needs a name without colons and a factory")
+	}
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("sink: %s registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of the registered sinks, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Named is an enabled sink with the name it was registered under.
+type Named struct {
+	Name string
+	Sink
+}
+
+// Open creates the sinks of the entries, which are name or name:config.
+func Open(entries []string) ([]Named, error) {
+	var sinks []Named
+	for _, entry := range entries {
+		name, config, _ := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+
+		mu.Lock()
+		factory, ok := factories[name]
+		mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("unknown sink %q, available: %s", name, strings.Join(Names(), ", "))
+		}
+		s, err := factory(strings.TrimSpace(config))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create sink %s: %v", name, err)
+		}
+		sinks = append(sinks, Named{Name: name, Sink: s})
+	}
+	return sinks, nil
+}
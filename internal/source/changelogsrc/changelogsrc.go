@@ -0,0 +1,138 @@
+// Package changelogsrc sends chats their daily changelog: the releases
+// published in the chat's changelog repositories since the previous one,
+// combined into one message grouped by repository.
+package changelogsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// maxReleases caps the releases listed per repository, keeping the
+// changelog within one message.
+const maxReleases = 10
+
+func init() {
+	source.Register("changelog", func() source.Source { return &changelogSource{} })
+}
+
+type changelogSource struct{}
+
+// Jobs returns a job for chats with an active GitHub account, whose token
+// lists the releases. Whether the changelog is due is up to the job, as it
+// needs the chat's preferences.
+func (c *changelogSource) Jobs(user *models.User) []source.Job {
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("changelog of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			c.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+func (c *changelogSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if preferences.Changelog.At == "" {
+		return
+	}
+
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindChangelog, "")
+	if err != nil {
+		log.Printf("Error getting changelog state of chat %d: %v", user.ChatID, err)
+		return
+	}
+	now := time.Now()
+	if state.LastCheckedAt.IsZero() {
+		// Collect releases from now rather than reaching back to
+		// releases published before the changelog was set up.
+		state.LastCheckedAt = now
+		env.SaveState(ctx, state)
+		return
+	}
+	if !preferences.ChangelogDue(state.LastCheckedAt, now) {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.changelog", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	var sections []string
+	githubClient := env.GitHub(account.Token)
+	for _, repo := range preferences.Changelog.Repos {
+		owner, name, _ := strings.Cut(repo, "/")
+		fetchCtx, cancel := env.Timeout(ctx)
+		releases, err := githubClient.GetReleases(fetchCtx, owner, name, state.LastCheckedAt)
+		cancel()
+		if err != nil {
+			// Try again on the next cycle rather than leaving the
+			// repository's releases out of the changelog.
+			log.Printf("Error getting releases of %s for the changelog of chat %d: %v", repo, user.ChatID, err)
+			return
+		}
+		if len(releases) > 0 {
+			sections = append(sections, section(repo, releases))
+		}
+	}
+
+	if len(sections) > 0 {
+		local := now
+		if location, err := time.LoadLocation(preferences.Timezone); err == nil {
+			local = now.In(location)
+		}
+		notification := models.Notification{
+			Type:       "changelog",
+			Message:    fmt.Sprintf("Changelog of %s\n\n%s", local.Format("2 Jan 2006"), strings.Join(sections, "\n\n")),
+			DedupKey:   fmt.Sprintf("changelog:%d", now.Unix()),
+			OccurredAt: now,
+			EventID:    models.NewEventID("changelog", strconv.FormatInt(user.ChatID, 10), "changelog", now),
+		}
+		if _, failed := env.Enqueue(ctx, user, []models.Notification{notification}); failed > 0 {
+			// Try again on the next cycle.
+			return
+		}
+	}
+
+	state.LastCheckedAt = now
+	env.SaveState(ctx, state)
+}
+
+// section lists a repository's releases, oldest first, each with a link.
+func section(repo string, releases []models.Release) string {
+	lines := []string{repo}
+	for i, release := range releases {
+		if i == maxReleases {
+			lines = append(lines, fmt.Sprintf("and %d more", len(releases)-maxReleases))
+			break
+		}
+		line := release.Tag
+		if release.Name != "" && release.Name != release.Tag {
+			line += " " + release.Name
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", line, release.URL))
+	}
+	return strings.Join(lines, "\n")
+}
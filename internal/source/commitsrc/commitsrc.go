@@ -0,0 +1,167 @@
+// Package commitsrc scans the new commits of the chat's commit mention
+// repositories for @mentions of the chat's accounts, which GitHub does not
+// notify about.
+package commitsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// checkInterval limits how often a chat's repositories are scanned.
+	checkInterval = 15 * time.Minute
+	// lookback is how far before the previous scan commits are listed, as
+	// commits pushed late keep the earlier time they were committed at.
+	lookback = 24 * time.Hour
+)
+
+func init() {
+	source.Register("commits", func() source.Source {
+		return &commitSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type commitSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+func (c *commitSource) Jobs(user *models.User) []source.Job {
+	var accounts []*models.GitHubAccount
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("commit mentions of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			c.poll(ctx, env, user, accounts)
+		},
+	}}
+}
+
+// due reports whether the chat's repositories were not scanned within
+// checkInterval, and marks them scanned.
+func (c *commitSource) due(chatID int64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked[chatID]) < checkInterval {
+		return false
+	}
+	c.checked[chatID] = time.Now()
+	return true
+}
+
+func (c *commitSource) poll(ctx context.Context, env *source.Env, user *models.User, accounts []*models.GitHubAccount) {
+	if !c.due(user.ChatID) {
+		return
+	}
+
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if len(preferences.CommitMentionRepos) == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.commits", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	githubClient := env.GitHub(accounts[0].Token)
+	for _, repo := range preferences.CommitMentionRepos {
+		state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindCommits, repo)
+		if err != nil {
+			log.Printf("Error getting commit state of %s for chat %d: %v", repo, user.ChatID, err)
+			continue
+		}
+
+		now := time.Now()
+		since := now.Add(-lookback)
+		if !state.LastCheckedAt.IsZero() {
+			since = state.LastCheckedAt.Add(-lookback)
+		}
+		owner, name, _ := strings.Cut(repo, "/")
+		fetchCtx, cancel := env.Timeout(ctx)
+		commits, err := githubClient.GetCommits(fetchCtx, owner, name, since)
+		cancel()
+		if err != nil {
+			log.Printf("Error getting commits of %s for chat %d: %v", repo, user.ChatID, err)
+			continue
+		}
+
+		// Commits are listed newest first, so the new ones are those
+		// before the newest commit of the previous scan. The first scan
+		// only remembers where to start, rather than reporting mentions
+		// made before the repository was added.
+		var fresh []models.Commit
+		for _, commit := range commits {
+			if commit.SHA == state.LastModified {
+				break
+			}
+			fresh = append(fresh, commit)
+		}
+		if !state.LastCheckedAt.IsZero() {
+			if _, failed := env.Enqueue(ctx, user, mentions(fresh, accounts)); failed > 0 {
+				// Scan the commits again on the next cycle.
+				continue
+			}
+		}
+
+		if len(commits) > 0 {
+			state.LastModified = commits[0].SHA
+		}
+		state.LastCheckedAt = now
+		env.SaveState(ctx, state)
+	}
+}
+
+// mentions returns a notification for each commit mentioning any of the
+// accounts, other than the commit's author, oldest first.
+func mentions(commits []models.Commit, accounts []*models.GitHubAccount) []models.Notification {
+	var notifications []models.Notification
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		var mentioned []string
+		for _, account := range accounts {
+			if !strings.EqualFold(account.Username, commit.Author) && commit.Mentions(account.Username) {
+				mentioned = append(mentioned, "@"+account.Username)
+			}
+		}
+		if len(mentioned) == 0 {
+			continue
+		}
+
+		by := ""
+		if commit.Author != "" {
+			by = " by " + commit.Author
+		}
+		notifications = append(notifications, models.Notification{
+			Type:       "commit_mention",
+			Repo:       commit.Repo,
+			Message:    fmt.Sprintf("[%s] Commit %s%s mentions %s: %s", commit.Repo, commit.ShortSHA(), by, strings.Join(mentioned, ", "), commit.Subject()),
+			URL:        commit.URL,
+			DedupKey:   "commit_mention",
+			OccurredAt: commit.CommittedAt,
+			EventID:    models.NewEventID("github", commit.URL, "commit_mention", commit.CommittedAt),
+			Author:     models.Author{Login: commit.Author},
+		})
+	}
+	return notifications
+}
@@ -0,0 +1,104 @@
+// Package depsrc reports new releases of the dependencies of the
+// repositories users subscribed to, and vulnerabilities affecting the
+// versions they pin.
+package depsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/deps"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often manifests, package registries and OSV
+// are queried for a watched repository.
+const checkInterval = 6 * time.Hour
+
+func init() {
+	source.Register("dependencies", func() source.Source {
+		return &dependencySource{client: deps.NewClient()}
+	})
+}
+
+type dependencySource struct {
+	client *deps.Client
+}
+
+// Jobs returns no job for users without an active GitHub account, whose
+// manifests cannot be read.
+func (d *dependencySource) Jobs(user *models.User) []source.Job {
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("dependency subscriptions of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			d.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+func (d *dependencySource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	ctx, span := tracing.Start(ctx, "poll.dependency_subscriptions", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	subscriptions, err := env.Store.GetDependencySubscriptions(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting dependency subscriptions for user %d: %v", user.ChatID, err)
+		return
+	}
+
+	githubClient := env.GitHub(account.Token)
+	for _, subscription := range subscriptions {
+		if time.Since(subscription.LastCheckedAt) < checkInterval {
+			continue
+		}
+
+		seen, err := env.Store.GetSeenDependencyVersions(ctx, subscription.ID)
+		if err != nil {
+			log.Printf("Error getting seen versions for %s: %v", subscription.Repo, err)
+			continue
+		}
+
+		fetchCtx, cancel := env.Timeout(ctx)
+		dependencies, err := deps.FetchDependencies(fetchCtx, githubClient, subscription.Repo)
+		if err != nil {
+			cancel()
+			log.Printf("Error reading dependencies of %s: %v", subscription.Repo, err)
+			continue
+		}
+		updates := d.client.Updates(fetchCtx, dependencies)
+		vulnerabilities, err := d.client.Vulnerabilities(fetchCtx, dependencies, seen)
+		cancel()
+		if err != nil {
+			log.Printf("Error looking up vulnerabilities of %s: %v", subscription.Repo, err)
+		}
+
+		notifications, keys := deps.GetNotifications(subscription.Repo, updates, seen)
+		vulnerabilityNotifications, vulnerabilityKeys := deps.GetVulnerabilityNotifications(subscription.Repo, vulnerabilities)
+		notifications = append(vulnerabilityNotifications, notifications...)
+		keys = append(vulnerabilityKeys, keys...)
+		notificationsQueued, notificationsFailed := env.Enqueue(ctx, user, notifications)
+		log.Printf("Queued %d dependency notifications for %s", notificationsQueued, subscription.Repo)
+		if notificationsFailed > 0 {
+			continue
+		}
+		if err := env.Store.MarkDependencyVersionsSeen(ctx, subscription.ID, keys); err != nil {
+			log.Printf("Error marking versions as seen for %s: %v", subscription.Repo, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package gerritsrc polls the changes of every active Gerrit account.
+package gerritsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/gerrit"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func init() {
+	source.Register("gerrit", func() source.Source { return gerritSource{} })
+}
+
+type gerritSource struct{}
+
+func (gerritSource) Jobs(user *models.User) []source.Job {
+	var jobs []source.Job
+	for _, account := range user.GerritAccounts {
+		if account.IsActive {
+			jobs = append(jobs, source.Job{
+				Name: fmt.Sprintf("poll of Gerrit account %s of chat %d", account.Key(), user.ChatID),
+				Run: func(ctx context.Context, env *source.Env) {
+					poll(ctx, env, user, account)
+				},
+			})
+		}
+	}
+	return jobs
+}
+
+func poll(ctx context.Context, env *source.Env, user *models.User, account *models.GerritAccount) {
+	ctx, span := tracing.Start(ctx, "poll.gerrit_account", attribute.Int64("chat_id", user.ChatID), attribute.String("account", account.Key()))
+	defer span.End()
+
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindGerrit, account.Key())
+	if err != nil {
+		log.Printf("Error getting polling state for %s: %v", account.Key(), err)
+	}
+	if !source.AccountDue(state, env.Config.PollInterval) {
+		log.Printf("Skipping Gerrit account %s after %d consecutive errors", account.Key(), state.ConsecutiveErrors)
+		return
+	}
+
+	log.Printf("Checking Gerrit changes for user %s on %s", account.Username, account.BaseURL)
+	gerritClient := gerrit.NewClient(account.BaseURL, account.Username, account.Password)
+	fetchCtx, cancel := env.Timeout(ctx)
+	fetchCtx, fetchSpan := tracing.Start(fetchCtx, "gerrit.notifications")
+	notifications, err := gerritClient.GetNotifications(fetchCtx)
+	tracing.End(fetchSpan, err)
+	cancel()
+	state.LastCheckedAt = time.Now()
+	if err != nil {
+		log.Printf("Error getting Gerrit changes for %s: %v", account.Username, err)
+		state.ConsecutiveErrors++
+		state.LastError = redact.String(err.Error())
+		env.SaveState(ctx, state)
+		return
+	}
+	state.ConsecutiveErrors = 0
+	state.LastError = ""
+	log.Printf("Found %d Gerrit notifications for user %s", len(notifications), account.Username)
+
+	notificationsQueued, _ := env.Enqueue(ctx, user, notifications)
+	log.Printf("Queued %d new Gerrit notifications for user %s", notificationsQueued, account.Username)
+	env.SaveState(ctx, state)
+}
@@ -0,0 +1,201 @@
+// Package githubsrc polls the GitHub notifications of every active
+// account.
+package githubsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func init() {
+	source.Register("github", func() source.Source {
+		return &githubSource{limiter: NewRateLimiter()}
+	})
+}
+
+type githubSource struct {
+	limiter *RateLimiter
+}
+
+func (g *githubSource) Jobs(user *models.User) []source.Job {
+	var jobs []source.Job
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			jobs = append(jobs, source.Job{
+				Name: fmt.Sprintf("poll of GitHub account %s of chat %d", account.Username, user.ChatID),
+				Run: func(ctx context.Context, env *source.Env) {
+					g.poll(ctx, env, user, account)
+				},
+			})
+		}
+	}
+	return jobs
+}
+
+func (g *githubSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	ctx, span := tracing.Start(ctx, "poll.github_account", attribute.Int64("chat_id", user.ChatID), attribute.String("account", account.Username))
+	defer span.End()
+
+	pollInterval := env.Config.PollInterval
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindGitHub, account.Username)
+	if err != nil {
+		log.Printf("Error getting polling state for %s: %v", account.Username, err)
+	}
+	if !source.AccountDue(state, pollInterval) {
+		log.Printf("Skipping GitHub account %s after %d consecutive errors", account.Username, state.ConsecutiveErrors)
+		return
+	}
+	if due, next := pollDue(state, pollInterval); !due {
+		log.Printf("Deferring GitHub account %s until %s as asked by GitHub's X-Poll-Interval", account.Username, next.Format(time.RFC3339))
+		return
+	}
+	if due, next := g.limiter.due(ctx, user.ChatID, account, state.LastCheckedAt, pollInterval); !due {
+		log.Printf("Deferring GitHub account %s until %s to stay within its rate limit", account.Username, next.Format(time.RFC3339))
+		return
+	}
+
+	Fetch(ctx, env, g.limiter, user, account, state)
+}
+
+// Fetch polls the account right away, regardless of its error backoff and
+// rate-limit pacing, and queues its new notifications, returning how many
+// were queued.
+func Fetch(ctx context.Context, env *source.Env, limiter *RateLimiter, user *models.User, account *models.GitHubAccount, state models.AccountState) (int, error) {
+	previous := state
+
+	log.Printf("Checking GitHub notifications for user %s", account.Username)
+	githubClient := env.GitHub(account.Token)
+	before, haveBefore := github.CachedRateLimit(ctx, account.Token)
+	fetchCtx, cancel := env.Timeout(ctx)
+	fetchCtx, fetchSpan := tracing.Start(fetchCtx, "github.notifications")
+	notifications, err := githubClient.GetNotifications(fetchCtx, account.Username, &state)
+	tracing.End(fetchSpan, err)
+	cancel()
+	if after, ok := github.CachedRateLimit(ctx, account.Token); ok && haveBefore {
+		limiter.record(user.ChatID, account.Username, before, after)
+	}
+	state.LastCheckedAt = time.Now()
+	if err != nil {
+		log.Printf("Error getting notifications for %s: %v", account.Username, err)
+		state.ConsecutiveErrors++
+		state.LastError = redact.String(err.Error())
+		env.SaveState(ctx, state)
+		return 0, err
+	}
+	state.ConsecutiveErrors = 0
+	state.LastError = ""
+	log.Printf("Found %d notifications for user %s", len(notifications), account.Username)
+	recordReviewRequests(ctx, env, user, account, notifications)
+	notifications = allowedReasons(ctx, env, user, account, notifications)
+	recordMentions(ctx, env, user, account, notifications)
+	summarizeThreads(ctx, env, user, githubClient, notifications)
+
+	notificationsQueued, notificationsFailed := env.Enqueue(ctx, user, notifications)
+	log.Printf("Queued %d new notifications for user %s", notificationsQueued, account.Username)
+	if notificationsFailed > 0 {
+		// Fetch the same threads again on the next cycle.
+		state.LastModified = previous.LastModified
+		state.LastNotificationAt = previous.LastNotificationAt
+		state.LastError = fmt.Sprintf("failed to queue %d notifications", notificationsFailed)
+	}
+	env.SaveState(ctx, state)
+	return notificationsQueued, nil
+}
+
+// allowedReasons drops the notifications whose reason the account's
+// preferences disable. Without the preferences, every notification is
+// kept.
+func allowedReasons(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount, notifications []models.Notification) []models.Notification {
+	preferences, err := env.Store.GetAccountPreferences(ctx, user.ChatID, account.Username)
+	if err != nil {
+		log.Printf("Error getting preferences of account %s: %v", account.Username, err)
+		return notifications
+	}
+	if len(preferences.DisabledReasons) == 0 {
+		return notifications
+	}
+
+	var allowed []models.Notification
+	for _, notification := range notifications {
+		if preferences.Allows(notification.Type) {
+			allowed = append(allowed, notification)
+		}
+	}
+	return allowed
+}
+
+// recordReviewRequests tracks the pull requests the account is asked to
+// review, for /analytics, whether or not the notifications are sent.
+func recordReviewRequests(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount, notifications []models.Notification) {
+	for _, notification := range notifications {
+		if notification.Type != "review_requested" || !strings.Contains(notification.URL, "/pulls/") {
+			continue
+		}
+		request := models.ReviewRequest{
+			ChatID:      user.ChatID,
+			Account:     account.Username,
+			Repo:        notification.Repo,
+			URL:         notification.URL,
+			RequestedAt: notification.OccurredAt,
+		}
+		if err := env.Store.RecordReviewRequest(ctx, request); err != nil {
+			log.Printf("Error recording review request %s for %s: %v", notification.URL, account.Username, err)
+		}
+	}
+}
+
+// recordMentions tracks the issues and pull requests the account was
+// mentioned on, when the chat has a mention SLA.
+func recordMentions(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount, notifications []models.Notification) {
+	var preferences *models.Preferences
+	for _, notification := range notifications {
+		if notification.Type != "mention" || (!strings.Contains(notification.URL, "/issues/") && !strings.Contains(notification.URL, "/pulls/")) {
+			continue
+		}
+		if preferences == nil {
+			loaded, err := env.Store.GetPreferences(ctx, user.ChatID)
+			if err != nil {
+				log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+				return
+			}
+			preferences = &loaded
+		}
+		if preferences.MentionSLA == 0 {
+			return
+		}
+
+		mention := models.Mention{
+			ChatID:      user.ChatID,
+			Account:     account.Username,
+			Repo:        notification.Repo,
+			Message:     notification.Message,
+			URL:         notification.URL,
+			MentionedAt: notification.OccurredAt,
+		}
+		if err := env.Store.RecordMention(ctx, mention); err != nil {
+			log.Printf("Error recording mention %s for %s: %v", notification.URL, account.Username, err)
+		}
+	}
+}
+
+// pollDue reports whether GitHub's X-Poll-Interval allows polling the
+// account again, and otherwise when it does. Intervals up to
+// POLL_INTERVAL are already honored by the poll cycle itself.
+func pollDue(state models.AccountState, pollInterval time.Duration) (bool, time.Time) {
+	requested := time.Duration(state.PollInterval) * time.Second
+	if requested <= pollInterval || state.LastCheckedAt.IsZero() {
+		return true, time.Time{}
+	}
+	next := state.LastCheckedAt.Add(requested)
+	return !time.Now().Before(next), next
+}
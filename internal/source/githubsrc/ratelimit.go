@@ -0,0 +1,89 @@
+package githubsrc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+const (
+	// rateLimitReserve is how many API calls are left untouched for bot
+	// commands such as /add and for dependency checks.
+	rateLimitReserve = 50
+
+	// defaultPollCost is the assumed cost of an account's first poll.
+	defaultPollCost = 10
+)
+
+// RateLimiter slows down GitHub accounts whose token would run out
+// of API calls before the rate limit resets. It learns what a poll of each
+// account costs from the X-RateLimit-Remaining header before and after
+// the poll, and spaces polls so the remaining calls last until X-RateLimit-
+// Reset. Accounts that cannot afford a single poll wait for the reset.
+type RateLimiter struct {
+	mu    sync.Mutex
+	costs map[string]int
+}
+
+// NewRateLimiter returns a rate limiter that has not seen any poll yet.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{costs: make(map[string]int)}
+}
+
+func rateLimitKey(chatID int64, username string) string {
+	return fmt.Sprintf("%d:%s", chatID, username)
+}
+
+// due reports whether the account can be polled now. When it cannot, the
+// returned time is when it will be.
+func (r *RateLimiter) due(ctx context.Context, chatID int64, account *models.GitHubAccount, lastChecked time.Time, interval time.Duration) (bool, time.Time) {
+	limit, ok := github.CachedRateLimit(ctx, account.Token)
+	if !ok || !time.Now().Before(limit.Reset) {
+		return true, time.Time{}
+	}
+
+	r.mu.Lock()
+	cost, ok := r.costs[rateLimitKey(chatID, account.Username)]
+	r.mu.Unlock()
+	if !ok {
+		cost = defaultPollCost
+	}
+
+	available := limit.Remaining - rateLimitReserve
+	if available < cost {
+		return false, limit.Reset
+	}
+
+	// Stretch the interval when polling every interval until the reset
+	// would use up more calls than are left.
+	untilReset := time.Until(limit.Reset)
+	affordable := available / cost
+	if wanted := int(untilReset/interval) + 1; affordable >= wanted {
+		return true, time.Time{}
+	}
+	next := lastChecked.Add(untilReset / time.Duration(affordable))
+	if time.Now().Before(next) {
+		return false, next
+	}
+	return true, time.Time{}
+}
+
+// record learns the cost of a poll from the rate limit seen before and
+// after it. Polls that crossed a reset tell nothing and are ignored.
+func (r *RateLimiter) record(chatID int64, username string, before, after github.RateLimit) {
+	if !before.Reset.Equal(after.Reset) {
+		return
+	}
+	cost := before.Remaining - after.Remaining
+	if cost < 1 {
+		cost = 1
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.costs[rateLimitKey(chatID, username)] = cost
+}
@@ -0,0 +1,67 @@
+package githubsrc
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/llm"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+)
+
+// transcriptLimit bounds the thread text sent to the language model, well
+// within the context of small models.
+const transcriptLimit = 24000
+
+// summarizeThreads appends a summary to the notifications about long issue
+// and pull request threads, when the operator configured a language model
+// and the chat opted in. Notifications whose thread cannot be summarized
+// are sent as they are.
+func summarizeThreads(ctx context.Context, env *source.Env, user *models.User, githubClient github.API, notifications []models.Notification) {
+	if env.Config.LLMURL == "" {
+		return
+	}
+
+	var client *llm.Client
+	for i, notification := range notifications {
+		if !strings.Contains(notification.URL, "/issues/") && !strings.Contains(notification.URL, "/pulls/") {
+			continue
+		}
+		if client == nil {
+			preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+			if err != nil {
+				log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+				return
+			}
+			if !preferences.Summaries {
+				return
+			}
+			client = llm.NewClient(env.Config.LLMURL, env.Config.LLMAPIKey, env.Config.LLMModel)
+		}
+
+		fetchCtx, cancel := env.Timeout(ctx)
+		thread, err := githubClient.GetThread(fetchCtx, notification.URL, env.Config.SummaryMinComments)
+		cancel()
+		if err != nil {
+			log.Printf("Error getting the thread of %s for a summary: %v", notification.URL, err)
+			continue
+		}
+		if thread.CommentCount < env.Config.SummaryMinComments {
+			continue
+		}
+
+		summaryCtx, span := tracing.Start(ctx, "llm.summarize")
+		summary, err := client.Summarize(summaryCtx, thread.Transcript(transcriptLimit))
+		tracing.End(span, err)
+		if err != nil {
+			log.Printf("Error summarizing %s: %v", notification.URL, err)
+			continue
+		}
+		notifications[i].Fields.Summary = summary
+		notifications[i].Message = render.Text(notifications[i])
+	}
+}
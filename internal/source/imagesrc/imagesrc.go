@@ -0,0 +1,75 @@
+// Package imagesrc reports new tags of the container images users
+// subscribed to.
+package imagesrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/registry"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func init() {
+	source.Register("images", func() source.Source {
+		return &imageSource{client: registry.NewClient()}
+	})
+}
+
+type imageSource struct {
+	client *registry.Client
+}
+
+func (i *imageSource) Jobs(user *models.User) []source.Job {
+	return []source.Job{{
+		Name: fmt.Sprintf("image subscriptions of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			i.poll(ctx, env, user)
+		},
+	}}
+}
+
+func (i *imageSource) poll(ctx context.Context, env *source.Env, user *models.User) {
+	ctx, span := tracing.Start(ctx, "poll.image_subscriptions", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	subscriptions, err := env.Store.GetImageSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting image subscriptions for user %d: %v", user.ChatID, err)
+		return
+	}
+
+	for _, subscription := range subscriptions {
+		seen, err := env.Store.GetSeenImageTags(ctx, subscription.ID)
+		if err != nil {
+			log.Printf("Error getting seen tags for %s: %v", subscription.Image, err)
+			continue
+		}
+
+		fetchCtx, cancel := env.Timeout(ctx)
+		notifications, newTags, err := i.client.GetNotifications(fetchCtx, subscription, seen)
+		cancel()
+		if err != nil {
+			log.Printf("Error checking image %s: %v", subscription.Image, err)
+			continue
+		}
+		if len(notifications) == 0 {
+			continue
+		}
+
+		// Tags are only marked as seen once every notification went out,
+		// otherwise the next cycle retries the ones that failed.
+		notificationsQueued, notificationsFailed := env.Enqueue(ctx, user, notifications)
+		log.Printf("Queued %d new image tag notifications for %s", notificationsQueued, subscription.Image)
+		if notificationsFailed > 0 {
+			continue
+		}
+		if err := env.Store.MarkImageTagsSeen(ctx, subscription.ID, newTags); err != nil {
+			log.Printf("Error marking tags as seen for %s: %v", subscription.Image, err)
+		}
+	}
+}
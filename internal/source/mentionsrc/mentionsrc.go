@@ -0,0 +1,147 @@
+// Package mentionsrc follows up on the mentions of chats with a mention
+// SLA, forgetting those the account replied to and reminding the chat of
+// those left unanswered for longer than the SLA.
+package mentionsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often a chat's mentions are looked up, as each
+// costs up to three API calls.
+const checkInterval = 15 * time.Minute
+
+func init() {
+	source.Register("mentions", func() source.Source {
+		return &mentionSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type mentionSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+func (m *mentionSource) Jobs(user *models.User) []source.Job {
+	if len(user.Accounts) == 0 {
+		return nil
+	}
+	return []source.Job{{
+		Name: fmt.Sprintf("mention SLA of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			m.poll(ctx, env, user)
+		},
+	}}
+}
+
+// due reports whether the chat's mentions were not checked within
+// checkInterval, and marks them checked.
+func (m *mentionSource) due(chatID int64) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if time.Since(m.checked[chatID]) < checkInterval {
+		return false
+	}
+	m.checked[chatID] = time.Now()
+	return true
+}
+
+func (m *mentionSource) poll(ctx context.Context, env *source.Env, user *models.User) {
+	if !m.due(user.ChatID) {
+		return
+	}
+
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if preferences.MentionSLA == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.mentions", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	mentions, err := env.Store.GetMentions(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting mentions of chat %d: %v", user.ChatID, err)
+		return
+	}
+
+	now := time.Now()
+	var breached []models.Mention
+	for _, mention := range mentions {
+		account, ok := user.Accounts[mention.Account]
+		if !ok {
+			m.remove(ctx, env, mention)
+			continue
+		}
+		if !account.IsActive {
+			continue
+		}
+
+		fetchCtx, cancel := env.Timeout(ctx)
+		status, err := env.GitHub(account.Token).GetReplyStatus(fetchCtx, mention.URL, account.Username, mention.MentionedAt)
+		cancel()
+		if err != nil {
+			log.Printf("Error checking replies to %s for %s: %v", mention.URL, account.Username, err)
+			continue
+		}
+		if !status.RepliedAt.IsZero() || !status.Open {
+			m.remove(ctx, env, mention)
+			continue
+		}
+		if preferences.BreachesSLA(mention, now) {
+			breached = append(breached, mention)
+		}
+	}
+
+	if len(breached) > 0 {
+		env.Enqueue(ctx, user, []models.Notification{reminder(preferences, breached, now)})
+	}
+}
+
+func (m *mentionSource) remove(ctx context.Context, env *source.Env, mention models.Mention) {
+	if err := env.Store.RemoveMention(ctx, mention.ID); err != nil {
+		log.Printf("Error removing mention %s for %s: %v", mention.URL, mention.Account, err)
+	}
+}
+
+// reminder is the message listing the mentions breaching the SLA. It is
+// deduplicated on the set of mentions, so it is sent again when the set
+// changes or the sla_breach renotify interval passes.
+func reminder(preferences models.Preferences, breached []models.Mention, now time.Time) models.Notification {
+	var text strings.Builder
+	if len(breached) == 1 {
+		fmt.Fprintf(&text, "A mention is waiting for a reply for longer than your SLA of %d business hours:\n", preferences.MentionSLA)
+	} else {
+		fmt.Fprintf(&text, "%d mentions are waiting for a reply for longer than your SLA of %d business hours:\n", len(breached), preferences.MentionSLA)
+	}
+	ids := make([]string, len(breached))
+	for i, mention := range breached {
+		hours := int(preferences.BusinessHoursBetween(mention.MentionedAt, now) / time.Hour)
+		fmt.Fprintf(&text, "\n%s (%s, %d business hours)\n%s", mention.Message, mention.Account, hours, mention.URL)
+		ids[i] = strconv.FormatInt(mention.ID, 10)
+	}
+
+	key := "sla_breach:" + strings.Join(ids, ",")
+	return models.Notification{
+		Type:       "sla_breach",
+		Message:    text.String(),
+		DedupKey:   key,
+		OccurredAt: now,
+		EventID:    models.NewEventID("sla", strconv.FormatInt(preferences.ChatID, 10), key, now),
+	}
+}
@@ -0,0 +1,194 @@
+// Package pathsrc reports commits changing the files of the chat's path
+// subscriptions, listing the changed files with a link comparing the
+// commits.
+package pathsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// checkInterval limits how often a chat's repositories are compared.
+	checkInterval = 15 * time.Minute
+	// maxFiles caps the files listed in a notification.
+	maxFiles = 20
+)
+
+func init() {
+	source.Register("paths", func() source.Source {
+		return &pathSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type pathSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+// Jobs returns no job for users without an active GitHub account, whose
+// token compares the commits.
+func (p *pathSource) Jobs(user *models.User) []source.Job {
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("path subscriptions of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			p.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+// due reports whether the chat's repositories were not compared within
+// checkInterval, and marks them compared.
+func (p *pathSource) due(chatID int64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if time.Since(p.checked[chatID]) < checkInterval {
+		return false
+	}
+	p.checked[chatID] = time.Now()
+	return true
+}
+
+func (p *pathSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	if !p.due(user.ChatID) {
+		return
+	}
+
+	subscriptions, err := env.Store.GetPathSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting path subscriptions of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.paths", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	// Subscriptions are ordered by repository, so each repository is
+	// compared once for all of its patterns.
+	for start := 0; start < len(subscriptions); {
+		end := start
+		for end < len(subscriptions) && subscriptions[end].Repo == subscriptions[start].Repo {
+			end++
+		}
+		p.check(ctx, env, user, account, subscriptions[start:end])
+		start = end
+	}
+}
+
+// check compares the repository's default branch with the commit it was
+// last compared from, and reports the files matching its subscriptions.
+func (p *pathSource) check(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount, subscriptions []models.PathSubscription) {
+	repo := subscriptions[0].Repo
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindPaths, repo)
+	if err != nil {
+		log.Printf("Error getting path state of %s for chat %d: %v", repo, user.ChatID, err)
+		return
+	}
+
+	owner, name, _ := strings.Cut(repo, "/")
+	githubClient := env.GitHub(account.Token)
+	fetchCtx, cancel := env.Timeout(ctx)
+	defer cancel()
+	head, err := githubClient.GetHead(fetchCtx, owner, name)
+	if err != nil {
+		log.Printf("Error getting the head of %s for chat %d: %v", repo, user.ChatID, err)
+		return
+	}
+	if head.SHA == state.LastModified {
+		return
+	}
+
+	// The first check only remembers where to compare from, rather than
+	// reporting changes made before the subscription.
+	if state.LastModified != "" {
+		comparison, ok, err := githubClient.Compare(fetchCtx, owner, name, state.LastModified, head.SHA)
+		if err != nil {
+			log.Printf("Error comparing %s for chat %d: %v", repo, user.ChatID, err)
+			return
+		}
+		if !ok {
+			log.Printf("Commit %s of %s is gone, comparing from %s on", state.LastModified, repo, head.SHA)
+		} else if notification, ok := notify(comparison, head, subscriptions); ok {
+			if _, failed := env.Enqueue(ctx, user, []models.Notification{notification}); failed > 0 {
+				// Compare again on the next cycle.
+				return
+			}
+		}
+	}
+
+	state.LastModified = head.SHA
+	state.LastCheckedAt = time.Now()
+	env.SaveState(ctx, state)
+}
+
+// notify returns the notification listing the changed files matching any
+// of the subscriptions, and false when none does.
+func notify(comparison models.Comparison, head models.Commit, subscriptions []models.PathSubscription) (models.Notification, bool) {
+	var files, patterns []string
+	matchedPatterns := make(map[string]bool)
+	listed := make(map[string]bool)
+	for _, file := range comparison.Files {
+		for _, subscription := range subscriptions {
+			if !subscription.Matches(file) {
+				continue
+			}
+			if !listed[file] {
+				listed[file] = true
+				files = append(files, file)
+			}
+			if !matchedPatterns[subscription.Pattern] {
+				matchedPatterns[subscription.Pattern] = true
+				patterns = append(patterns, subscription.Pattern)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return models.Notification{}, false
+	}
+
+	commits := "1 commit"
+	if comparison.Commits != 1 {
+		commits = fmt.Sprintf("%d commits", comparison.Commits)
+	}
+	lines := []string{fmt.Sprintf("[%s] %s changed %s:", comparison.Repo, commits, strings.Join(patterns, ", "))}
+	for i, file := range files {
+		if i == maxFiles {
+			lines = append(lines, fmt.Sprintf("and %d more files", len(files)-maxFiles))
+			break
+		}
+		lines = append(lines, file)
+	}
+
+	return models.Notification{
+		Type:       "path_change",
+		Repo:       comparison.Repo,
+		Message:    strings.Join(lines, "\n"),
+		URL:        comparison.URL,
+		DedupKey:   "path:" + head.SHA,
+		OccurredAt: head.CommittedAt,
+		EventID:    models.NewEventID("github", comparison.URL, "path_change", head.CommittedAt),
+	}, true
+}
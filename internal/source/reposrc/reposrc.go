@@ -0,0 +1,146 @@
+// Package reposrc reports the pull requests, issues and releases of the
+// repositories the chat watches with /watch, whether or not the user is
+// involved in them.
+package reposrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often a chat's repositories are checked. The
+// events of the last day are fetched every time, and those already sent
+// are left out when queued.
+const checkInterval = 15 * time.Minute
+
+func init() {
+	source.Register("repos", func() source.Source {
+		return &repoSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type repoSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+// Jobs returns no job for users without an active GitHub account, whose
+// token fetches the events.
+func (r *repoSource) Jobs(user *models.User) []source.Job {
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("repository subscriptions of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			r.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+// due reports whether the chat's repositories were not checked within
+// checkInterval, and marks them checked.
+func (r *repoSource) due(chatID int64) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked[chatID]) < checkInterval {
+		return false
+	}
+	r.checked[chatID] = time.Now()
+	return true
+}
+
+func (r *repoSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	if !r.due(user.ChatID) {
+		return
+	}
+
+	subscriptions, err := env.Store.GetRepoSubscriptions(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting repository subscriptions of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if len(subscriptions) == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.repos", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	githubClient := env.GitHub(account.Token)
+	for _, subscription := range subscriptions {
+		// Listing every repository of an owner each cycle is too costly,
+		// so subscriptions restored with owner/* are not polled.
+		if subscription.IsOrgWide() {
+			continue
+		}
+
+		owner, name, _ := strings.Cut(subscription.Repo, "/")
+		fetchCtx, cancel := env.Timeout(ctx)
+		events, err := githubClient.GetRepoEvents(fetchCtx, owner, name)
+		cancel()
+		if err != nil {
+			log.Printf("Error getting events of %s for chat %d: %v", subscription.Repo, user.ChatID, err)
+			continue
+		}
+
+		var notifications []models.Notification
+		for _, event := range events {
+			if selects(subscription, event) {
+				notifications = append(notifications, event)
+			}
+		}
+		env.Enqueue(ctx, user, notifications)
+	}
+}
+
+// selects reports whether the notification is of an event type the
+// subscription wants and passes its label and author filters. Releases
+// have no labels, so label filters only apply to pull requests and
+// issues. Branch filters only apply to pushes and workflow runs, which are
+// not reported here.
+func selects(subscription models.RepoSubscription, notification models.Notification) bool {
+	if !subscription.Wants(eventType(notification.Type)) {
+		return false
+	}
+	filters := subscription.Filters
+	if len(filters.Labels) > 0 && notification.Type != "release" && !slices.ContainsFunc(notification.Labels, func(label string) bool {
+		return slices.ContainsFunc(filters.Labels, func(want string) bool { return strings.EqualFold(want, label) })
+	}) {
+		return false
+	}
+	if len(filters.Authors) > 0 && !slices.ContainsFunc(filters.Authors, func(want string) bool {
+		return strings.EqualFold(want, notification.Author.Login)
+	}) {
+		return false
+	}
+	return true
+}
+
+// eventType returns the subscription event type of a notification type.
+func eventType(notificationType string) string {
+	switch notificationType {
+	case "new_pull_request", "merged_pull_request":
+		return models.EventPullRequest
+	default:
+		return notificationType
+	}
+}
@@ -0,0 +1,102 @@
+// Package reviewsrc follows up on the review requests of every active
+// GitHub account, recording when the account submitted its review so
+// /analytics can report review turnaround.
+package reviewsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often the pending requests of an account are
+// looked up, as each costs two API calls.
+const checkInterval = 15 * time.Minute
+
+func init() {
+	source.Register("reviews", func() source.Source {
+		return &reviewSource{checked: make(map[string]time.Time)}
+	})
+}
+
+type reviewSource struct {
+	mu      sync.Mutex
+	checked map[string]time.Time
+}
+
+func (r *reviewSource) Jobs(user *models.User) []source.Job {
+	var jobs []source.Job
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			jobs = append(jobs, source.Job{
+				Name: fmt.Sprintf("review requests of GitHub account %s of chat %d", account.Username, user.ChatID),
+				Run: func(ctx context.Context, env *source.Env) {
+					r.poll(ctx, env, user, account)
+				},
+			})
+		}
+	}
+	return jobs
+}
+
+// due reports whether the account's requests were not checked within
+// checkInterval, and marks them checked.
+func (r *reviewSource) due(chatID int64, account string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := fmt.Sprintf("%d/%s", chatID, account)
+	if time.Since(r.checked[key]) < checkInterval {
+		return false
+	}
+	r.checked[key] = time.Now()
+	return true
+}
+
+func (r *reviewSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	if !r.due(user.ChatID, account.Username) {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.review_requests", attribute.Int64("chat_id", user.ChatID), attribute.String("account", account.Username))
+	defer span.End()
+
+	requests, err := env.Store.GetReviewRequests(ctx, user.ChatID, time.Now())
+	if err != nil {
+		log.Printf("Error getting review requests for %s: %v", account.Username, err)
+		return
+	}
+
+	githubClient := env.GitHub(account.Token)
+	for _, request := range requests {
+		if request.Account != account.Username || !request.Pending() {
+			continue
+		}
+
+		fetchCtx, cancel := env.Timeout(ctx)
+		status, err := githubClient.GetReviewStatus(fetchCtx, request.URL, account.Username, request.RequestedAt)
+		cancel()
+		if err != nil {
+			log.Printf("Error checking review request %s for %s: %v", request.URL, account.Username, err)
+			continue
+		}
+
+		switch {
+		case !status.ReviewedAt.IsZero():
+			err = env.Store.CompleteReviewRequest(ctx, request.ID, status.ReviewedAt)
+		case !status.Open || !status.Requested:
+			// Closed without a review, or the request was withdrawn:
+			// there is no turnaround to measure.
+			err = env.Store.RemoveReviewRequest(ctx, request.ID)
+		}
+		if err != nil {
+			log.Printf("Error updating review request %s for %s: %v", request.URL, account.Username, err)
+		}
+	}
+}
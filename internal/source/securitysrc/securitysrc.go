@@ -0,0 +1,149 @@
+// Package securitysrc reports the Dependabot and code scanning alerts
+// opened on the repositories of the GitHub accounts with security alerts
+// turned on with /security.
+package securitysrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often an account's alerts are looked up. Each
+// check costs two requests per repository the account owns.
+const checkInterval = time.Hour
+
+func init() {
+	source.Register("security", func() source.Source {
+		return &securitySource{checked: make(map[string]time.Time)}
+	})
+}
+
+type securitySource struct {
+	mu      sync.Mutex
+	checked map[string]time.Time
+}
+
+func (s *securitySource) Jobs(user *models.User) []source.Job {
+	var jobs []source.Job
+	for _, account := range user.Accounts {
+		if !account.IsActive {
+			continue
+		}
+		account := account
+		jobs = append(jobs, source.Job{
+			Name: fmt.Sprintf("security alerts of %s for chat %d", account.Username, user.ChatID),
+			Run: func(ctx context.Context, env *source.Env) {
+				s.poll(ctx, env, user, account)
+			},
+		})
+	}
+	return jobs
+}
+
+// due reports whether the account's alerts were not checked within
+// checkInterval, and marks them checked.
+func (s *securitySource) due(chatID int64, username string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := fmt.Sprintf("%d/%s", chatID, username)
+	if time.Since(s.checked[key]) < checkInterval {
+		return false
+	}
+	s.checked[key] = time.Now()
+	return true
+}
+
+func (s *securitySource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	if !s.due(user.ChatID, account.Username) {
+		return
+	}
+
+	preferences, err := env.Store.GetAccountPreferences(ctx, user.ChatID, account.Username)
+	if err != nil {
+		log.Printf("Error getting preferences of account %s: %v", account.Username, err)
+		return
+	}
+	if !preferences.SecurityAlerts {
+		return
+	}
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindSecurity, account.Username)
+	if err != nil {
+		log.Printf("Error getting security alert state of %s for chat %d: %v", account.Username, user.ChatID, err)
+		return
+	}
+
+	// The first check only remembers where to start, rather than
+	// reporting every alert already open.
+	now := time.Now()
+	if state.LastCheckedAt.IsZero() {
+		state.LastCheckedAt = now
+		env.SaveState(ctx, state)
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.security", attribute.Int64("chat_id", user.ChatID), attribute.String("account", account.Username))
+	defer span.End()
+
+	fetchCtx, cancel := env.Timeout(ctx)
+	alerts, err := env.GitHub(account.Token).GetSecurityAlerts(fetchCtx, state.LastCheckedAt)
+	cancel()
+	if err != nil {
+		log.Printf("Error getting security alerts of %s for chat %d: %v", account.Username, user.ChatID, err)
+		return
+	}
+
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.Before(alerts[j].CreatedAt) })
+	var notifications []models.Notification
+	for _, alert := range alerts {
+		notifications = append(notifications, notification(alert))
+	}
+	if _, failed := env.Enqueue(ctx, user, notifications); failed > 0 {
+		// Look the alerts up again on the next check.
+		return
+	}
+
+	state.LastCheckedAt = now
+	env.SaveState(ctx, state)
+}
+
+// notification reports the alert, with its severity as a label for
+// SEVERITY_RULES and routing rules such as label=critical.
+func notification(alert models.SecurityAlert) models.Notification {
+	var notificationType, message string
+	switch alert.Kind {
+	case models.SecurityAlertCodeScanning:
+		notificationType = "code_scanning_alert"
+		message = fmt.Sprintf("[%s] Code scanning alert #%d (%s): %s", alert.Repo, alert.Number, alert.Severity, alert.Summary)
+		if alert.Path != "" {
+			message += " in " + alert.Path
+		}
+	default:
+		notificationType = "dependabot_alert"
+		message = fmt.Sprintf("[%s] Dependabot alert #%d (%s): %s", alert.Repo, alert.Number, alert.Severity, alert.Summary)
+		if alert.Package != "" {
+			message += fmt.Sprintf(" in %s (%s)", alert.Package, alert.Ecosystem)
+		}
+	}
+
+	n := models.Notification{
+		Type:       notificationType,
+		Repo:       alert.Repo,
+		Message:    message,
+		URL:        alert.URL,
+		OccurredAt: alert.CreatedAt,
+		EventID:    models.NewEventID("github", alert.URL, notificationType, alert.CreatedAt),
+	}
+	if alert.Severity != "" {
+		n.Labels = []string{alert.Severity}
+	}
+	return n
+}
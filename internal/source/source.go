@@ -0,0 +1,235 @@
+// Package source lets event sources plug into the poll cycle. A source is
+// a self-contained package that registers itself from an init function:
+//
+//	func init() {
+//		source.Register("searches", func() source.Source { return &searches{} })
+//	}
+//
+// and is compiled in with a blank import in cmd/monitor/sources.go. The
+// poll loop of each shard creates one instance of every registered source,
+// which lives as long as the loop, so a source can keep state across
+// cycles. The loop asks the instance for the jobs of each user it polls
+// and runs them on a pool of workers shared with the other sources.
+//
+// An instance is therefore used concurrently: Jobs is called from the loop
+// while jobs returned earlier are still running, and those jobs run in
+// parallel with each other. Whatever state a source keeps beyond a single
+// job must be guarded, typically by a mutex in the source, as the sources
+// in this repository do.
+package source
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/features"
+	"github.com/erkineren/repository-monitor/internal/filter"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/severity"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Source produces notifications for the users it is asked about. Its
+// methods and the jobs it returns must be safe for concurrent use.
+type Source interface {
+	// Jobs returns the work to do for the user this cycle, such as one
+	// job per account. Jobs is called without I/O to pace the cycle, so
+	// anything that needs the store belongs in the jobs themselves.
+	Jobs(user *models.User) []Job
+}
+
+// Job is one unit of polling work. Jobs of all sources run concurrently
+// on the poll workers; a job that panics is logged and skipped.
+type Job struct {
+	Name string
+	Run  func(ctx context.Context, env *Env)
+}
+
+// Factory creates an instance of a source.
+type Factory func() Source
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a source available to the poll cycle. It panics when the
+// name is empty or already registered, as that is a programming error.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if name == "" || factory == nil {
+		panic("source: Register needs a name and a factory")
+	}
+	if _, exists := factories[name]; exists {
+		panic(fmt.Sprintf("source: %s registered twice", name))
+	}
+	factories[name] = factory
+}
+
+// Names returns the names of the registered sources, sorted.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Instance is a source created for the poll loop of one shard.
+type Instance struct {
+	Name string
+	Source
+}
+
+// New creates an instance of every registered source, sorted by name.
+func New() []Instance {
+	names := Names()
+	mu.Lock()
+	defer mu.Unlock()
+	instances := make([]Instance, 0, len(names))
+	for _, name := range names {
+		instances = append(instances, Instance{Name: name, Source: factories[name]()})
+	}
+	return instances
+}
+
+// Env is what jobs need from the monitor during one poll cycle.
+type Env struct {
+	Store  store.Store
+	Config *config.Config
+	// Flags are read once per cycle, so changes apply from the next poll.
+	Flags  features.Set
+	GitHub github.ClientFactory
+}
+
+// Timeout bounds how long fetching from an external service may take.
+func (e *Env) Timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, e.Config.PollTimeout)
+}
+
+// Enqueue classifies the notifications and writes those the user has not
+// been told about yet to the outbox, returning how many were queued and
+// how many failed.
+// Sources should only mark what they fetched as seen when none failed, so
+// the next cycle retries them.
+func (e *Env) Enqueue(ctx context.Context, user *models.User, notifications []models.Notification) (queued, failed int) {
+	ctx, span := tracing.Start(ctx, "notifications.enqueue", attribute.Int64("chat_id", user.ChatID), attribute.Int("notifications", len(notifications)))
+	defer func() {
+		span.SetAttributes(attribute.Int("queued", queued), attribute.Int("failed", failed))
+		span.End()
+	}()
+	classified := make([]models.Notification, len(notifications))
+	for i, notification := range notifications {
+		notification.Severity = severity.Classify(e.Config.SeverityRules, e.Config.UrgencyMarkers, notification)
+		classified[i] = notification
+	}
+	allow := func(notification models.Notification) bool {
+		return e.Flags.Allowed(features.NotificationFlag(notification.Type), user.ChatID)
+	}
+	return Enqueue(ctx, e.Store, user, classified, e.Config.RenotifyInterval, allow)
+}
+
+// Enqueue queues the user's notifications that were not delivered within
+// their renotify interval, skipping muted repositories, notifications the
+// chat's filter does not match and notifications allow rejects; a nil
+// allow accepts all. It returns how many were queued and how many failed
+// to be checked or queued, which should be fetched again.
+func Enqueue(ctx context.Context, s store.Store, user *models.User, notifications []models.Notification, renotifyInterval time.Duration, allow func(models.Notification) bool) (queued, failed int) {
+	if len(notifications) == 0 {
+		return 0, 0
+	}
+
+	// Without the preferences every type is renotified after
+	// renotifyInterval.
+	preferences, err := s.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+	}
+	// Filters are validated when saved, so one that does not parse only
+	// comes from an older version and lets everything through.
+	var chatFilter *filter.Expr
+	if preferences.Filter != "" {
+		if chatFilter, err = models.ParseFilter(preferences.Filter); err != nil {
+			log.Printf("Ignoring the filter of chat %d: %v", user.ChatID, err)
+		}
+	}
+
+	for _, notification := range notifications {
+		if user.MutedRepos[notification.Repo] {
+			continue
+		}
+		if chatFilter != nil && !notification.MatchesFilter(chatFilter) {
+			continue
+		}
+		if allow != nil && !allow(notification) {
+			continue
+		}
+
+		contentHash := notification.ContentHash()
+		interval := preferences.RenotifyInterval(notification.Type, renotifyInterval)
+		shouldNotify, err := s.ShouldNotify(ctx, user.ChatID, notification.URL, contentHash, interval)
+		if err != nil {
+			log.Printf("Error checking notification status: %v", err)
+			failed++
+			continue
+		}
+
+		if shouldNotify {
+			notification.EventID = notification.IdempotencyKey()
+			enqueued, err := s.EnqueueNotification(ctx, user.ChatID, notification, contentHash, interval)
+			if err != nil {
+				log.Printf("Error queueing notification: %v", err)
+				failed++
+				continue
+			}
+			if enqueued {
+				queued++
+			}
+		}
+	}
+	return queued, failed
+}
+
+// InPollWindow reports whether the chat's accounts are polled now. Chats
+// whose preferences cannot be read are polled.
+func InPollWindow(ctx context.Context, s store.Store, chatID int64) bool {
+	preferences, err := s.GetPreferences(ctx, chatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", chatID, err)
+		return true
+	}
+	return preferences.InPollWindow(time.Now())
+}
+
+// SaveState saves the polling state of an account, logging failures.
+func (e *Env) SaveState(ctx context.Context, state models.AccountState) {
+	if err := e.Store.SaveAccountState(ctx, state); err != nil {
+		log.Printf("Error saving polling state for %s: %v", state.Account, err)
+	}
+}
+
+// AccountDue reports whether an account should be polled this cycle.
+// Accounts that keep failing are backed off exponentially, up to an hour.
+func AccountDue(state models.AccountState, pollInterval time.Duration) bool {
+	if state.ConsecutiveErrors == 0 {
+		return true
+	}
+
+	backoff := pollInterval << min(state.ConsecutiveErrors, 6)
+	if backoff > time.Hour {
+		backoff = time.Hour
+	}
+	return time.Since(state.LastCheckedAt) >= backoff
+}
@@ -0,0 +1,102 @@
+package source_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/features"
+	"github.com/erkineren/repository-monitor/internal/github/githubtest"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+
+	_ "github.com/erkineren/repository-monitor/internal/source/changelogsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/commitsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/depsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/gerritsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/githubsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/imagesrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/mentionsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/pathsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/reposrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/reviewsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/securitysrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/teamsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/triagesrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/usagesrc"
+)
+
+// TestConcurrentJobs runs the jobs of every registered source the way the
+// poll loop does: Jobs is called while earlier jobs run, and the jobs run
+// in parallel. Run it with -race to check the sources guard their state.
+func TestConcurrentJobs(t *testing.T) {
+	ctx := context.Background()
+	s := memory.New()
+	for chatID := int64(1); chatID <= 4; chatID++ {
+		for _, username := range []string{"alice", "bob"} {
+			if err := s.AddGitHubAccount(ctx, chatID, "token-"+username, username, models.GitHubAccountMetadata{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SetAccountPreferences(ctx, models.AccountPreferences{ChatID: chatID, Username: username, SecurityAlerts: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		preferences := models.DefaultPreferences(chatID)
+		preferences.MentionSLA = 8
+		if err := s.SetPreferences(ctx, preferences); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.AddRepoSubscription(ctx, models.RepoSubscription{ChatID: chatID, Repo: "acme/api"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := &githubtest.Client{
+		Login: "alice",
+		Notifications: []models.Notification{
+			{Type: "mention", Repo: "acme/api", Message: "[acme/api] Crash", URL: "https://api.github.com/repos/acme/api/issues/1", OccurredAt: time.Now()},
+		},
+	}
+	env := &source.Env{
+		Store:  s,
+		Config: &config.Config{PollInterval: time.Minute, PollTimeout: time.Second, RenotifyInterval: time.Hour},
+		Flags:  features.Set{},
+		GitHub: client.Factory(),
+	}
+
+	var users []*models.User
+	err := store.ForEachUser(ctx, s, func(user *models.User) error {
+		users = append(users, user)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, instance := range source.New() {
+		t.Run(instance.Name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for cycle := 0; cycle < 2; cycle++ {
+				for _, user := range users {
+					for _, job := range instance.Jobs(user) {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							defer func() {
+								if r := recover(); r != nil {
+									t.Errorf("%s panicked: %v", job.Name, r)
+								}
+							}()
+							job.Run(ctx, env)
+						}()
+					}
+				}
+			}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Package teamsrc sends group chats their scheduled team digest: the
+// review requests waiting on each member, stale pull requests and recent
+// merges in the team's repositories.
+package teamsrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// staleAfter is how long an open pull request goes without activity
+	// before the digest lists it as stale.
+	staleAfter = 7 * 24 * time.Hour
+
+	// maxLines caps each section, keeping the digest within one message.
+	maxLines = 10
+)
+
+func init() {
+	source.Register("team", func() source.Source { return &teamSource{} })
+}
+
+type teamSource struct{}
+
+// Jobs returns a job for group chats with an active GitHub account, whose
+// members are the chat's accounts. Whether the digest is due is up to the
+// job, as it needs the chat's preferences.
+func (t *teamSource) Jobs(user *models.User) []source.Job {
+	if user.ChatID >= 0 {
+		return nil
+	}
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("team digest of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			t.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+func (t *teamSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if preferences.TeamDigest == "" {
+		return
+	}
+
+	state, err := env.Store.GetAccountState(ctx, user.ChatID, models.AccountKindTeamDigest, "")
+	if err != nil {
+		log.Printf("Error getting team digest state of chat %d: %v", user.ChatID, err)
+		return
+	}
+	now := time.Now()
+	if state.LastCheckedAt.IsZero() {
+		// Start the schedule from now rather than sending a digest right
+		// away.
+		state.LastCheckedAt = now
+		env.SaveState(ctx, state)
+		return
+	}
+	if !preferences.TeamDigestDue(state.LastCheckedAt, now) {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.team_digest", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	message, ok := t.digest(ctx, env, user, account, preferences.TeamRepos, state.LastCheckedAt, now)
+	if ok {
+		notification := models.Notification{
+			Type:       "team_digest",
+			Message:    message,
+			DedupKey:   fmt.Sprintf("team_digest:%d", now.Unix()),
+			OccurredAt: now,
+			EventID:    models.NewEventID("team", strconv.FormatInt(user.ChatID, 10), "team_digest", now),
+		}
+		if _, failed := env.Enqueue(ctx, user, []models.Notification{notification}); failed > 0 {
+			// Try again on the next cycle.
+			return
+		}
+	}
+
+	state.LastCheckedAt = now
+	env.SaveState(ctx, state)
+}
+
+// digest renders the digest of the activity since the previous one, and
+// reports whether there is anything to tell.
+func (t *teamSource) digest(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount, repos []string, since, now time.Time) (string, bool) {
+	var sections []string
+
+	requests, err := env.Store.GetReviewRequests(ctx, user.ChatID, now)
+	if err != nil {
+		log.Printf("Error getting review requests of chat %d: %v", user.ChatID, err)
+	}
+	if lines := pendingReviews(requests, now); len(lines) > 0 {
+		sections = append(sections, section("Review requests waiting:", lines))
+	}
+
+	var stale, merged []models.PullRequest
+	githubClient := env.GitHub(account.Token)
+	for _, repo := range repos {
+		owner, name, _ := strings.Cut(repo, "/")
+		fetchCtx, cancel := env.Timeout(ctx)
+		pulls, err := githubClient.GetPullRequests(fetchCtx, owner, name, since)
+		cancel()
+		if err != nil {
+			log.Printf("Error getting pull requests of %s for the team digest of chat %d: %v", repo, user.ChatID, err)
+			continue
+		}
+		for _, pull := range pulls {
+			switch {
+			case pull.Open && now.Sub(pull.UpdatedAt) >= staleAfter:
+				stale = append(stale, pull)
+			case !pull.MergedAt.IsZero() && pull.MergedAt.After(since):
+				merged = append(merged, pull)
+			}
+		}
+	}
+
+	sort.Slice(stale, func(i, j int) bool { return stale[i].UpdatedAt.Before(stale[j].UpdatedAt) })
+	var lines []string
+	for _, pull := range stale {
+		lines = append(lines, fmt.Sprintf("%s#%d %s (%s, idle %s)", pull.Repo, pull.Number, pull.Title, pull.Author, age(now.Sub(pull.UpdatedAt))))
+	}
+	if len(lines) > 0 {
+		sections = append(sections, section("Stale pull requests, no activity for a week:", lines))
+	}
+
+	sort.Slice(merged, func(i, j int) bool { return merged[i].MergedAt.Before(merged[j].MergedAt) })
+	lines = nil
+	for _, pull := range merged {
+		lines = append(lines, fmt.Sprintf("%s#%d %s (%s)", pull.Repo, pull.Number, pull.Title, pull.Author))
+	}
+	if len(lines) > 0 {
+		sections = append(sections, section("Merged since the last digest:", lines))
+	}
+
+	if len(sections) == 0 {
+		return "", false
+	}
+	return "Team digest\n\n" + strings.Join(sections, "\n\n"), true
+}
+
+// pendingReviews lists the members with review requests waiting, most
+// requests first.
+func pendingReviews(requests []models.ReviewRequest, now time.Time) []string {
+	counts := map[string]int{}
+	oldest := map[string]time.Time{}
+	var members []string
+	for _, request := range requests {
+		if !request.Pending() {
+			continue
+		}
+		if counts[request.Account] == 0 {
+			members = append(members, request.Account)
+			oldest[request.Account] = request.RequestedAt
+		}
+		counts[request.Account]++
+		if request.RequestedAt.Before(oldest[request.Account]) {
+			oldest[request.Account] = request.RequestedAt
+		}
+	}
+	sort.SliceStable(members, func(i, j int) bool { return counts[members[i]] > counts[members[j]] })
+
+	lines := make([]string, len(members))
+	for i, member := range members {
+		lines[i] = fmt.Sprintf("%s: %d, oldest waiting %s", member, counts[member], age(now.Sub(oldest[member])))
+	}
+	return lines
+}
+
+func section(heading string, lines []string) string {
+	if len(lines) > maxLines {
+		lines = append(lines[:maxLines:maxLines], fmt.Sprintf("and %d more", len(lines)-maxLines))
+	}
+	return heading + "\n" + strings.Join(lines, "\n")
+}
+
+// age shows a duration in days, or hours when shorter than a day.
+func age(d time.Duration) string {
+	switch days := int(d / (24 * time.Hour)); {
+	case days == 1:
+		return "1 day"
+	case days > 1:
+		return fmt.Sprintf("%d days", days)
+	case d >= 2*time.Hour:
+		return fmt.Sprintf("%d hours", int(d/time.Hour))
+	case d >= time.Hour:
+		return "1 hour"
+	default:
+		return "under an hour"
+	}
+}
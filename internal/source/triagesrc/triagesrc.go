@@ -0,0 +1,147 @@
+// Package triagesrc surfaces the issues of a chat's triage repositories
+// that went without labels and assignees for longer than the chat allows,
+// so maintainers can triage them from the notification. Open issues with
+// similar titles are hinted at as possible duplicates.
+package triagesrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// checkInterval limits how often a chat's repositories are listed, as
+	// each costs an API call.
+	checkInterval = 15 * time.Minute
+
+	// lookback is how old an issue may be and still be surfaced, so
+	// enabling triage mode does not flood the chat with the backlog.
+	lookback = 7 * 24 * time.Hour
+
+	// duplicateLookback is how far back open issues are compared with an
+	// untriaged one for possible duplicates, and maxDuplicates how many
+	// are hinted at.
+	duplicateLookback = 30 * 24 * time.Hour
+	maxDuplicates     = 3
+)
+
+func init() {
+	source.Register("triage", func() source.Source {
+		return &triageSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type triageSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+// Jobs returns a job for chats with an active GitHub account, whose token
+// lists the issues. Whether triage mode is on is up to the job, as it
+// needs the chat's preferences.
+func (t *triageSource) Jobs(user *models.User) []source.Job {
+	var account *models.GitHubAccount
+	for _, candidate := range user.Accounts {
+		if candidate.IsActive {
+			account = candidate
+			break
+		}
+	}
+	if account == nil {
+		return nil
+	}
+
+	return []source.Job{{
+		Name: fmt.Sprintf("triage of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			t.poll(ctx, env, user, account)
+		},
+	}}
+}
+
+// due reports whether the chat's repositories were not checked within
+// checkInterval, and marks them checked.
+func (t *triageSource) due(chatID int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.checked[chatID]) < checkInterval {
+		return false
+	}
+	t.checked[chatID] = time.Now()
+	return true
+}
+
+func (t *triageSource) poll(ctx context.Context, env *source.Env, user *models.User, account *models.GitHubAccount) {
+	if !t.due(user.ChatID) {
+		return
+	}
+
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if preferences.Triage.After == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.triage", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	now := time.Now()
+	after := time.Duration(preferences.Triage.After) * time.Hour
+	githubClient := env.GitHub(account.Token)
+	var untriaged []models.Issue
+	var duplicates [][]models.Issue
+	for _, repo := range preferences.Triage.Repos {
+		owner, name, _ := strings.Cut(repo, "/")
+		fetchCtx, cancel := env.Timeout(ctx)
+		issues, err := githubClient.GetIssues(fetchCtx, owner, name, now.Add(-duplicateLookback))
+		cancel()
+		if err != nil {
+			log.Printf("Error getting issues of %s for the triage of chat %d: %v", repo, user.ChatID, err)
+			continue
+		}
+		for _, issue := range issues {
+			if !issue.Triaged() && now.Sub(issue.CreatedAt) >= after && now.Sub(issue.CreatedAt) < lookback {
+				untriaged = append(untriaged, issue)
+				duplicates = append(duplicates, issue.PossibleDuplicates(issues, maxDuplicates))
+			}
+		}
+	}
+
+	notifications := make([]models.Notification, len(untriaged))
+	for i, issue := range untriaged {
+		message := fmt.Sprintf("[%s] Untriaged issue #%d: %s by %s", issue.Repo, issue.Number, issue.Title, issue.Author)
+		if len(duplicates[i]) > 0 {
+			numbers := make([]string, len(duplicates[i]))
+			for j, duplicate := range duplicates[i] {
+				numbers[j] = fmt.Sprintf("#%d", duplicate.Number)
+			}
+			message += "\nPossibly duplicates " + strings.Join(numbers, ", ")
+		}
+		notifications[i] = models.Notification{
+			Type:       "triage",
+			Repo:       issue.Repo,
+			Message:    message,
+			URL:        issue.URL,
+			DedupKey:   "triage",
+			OccurredAt: issue.CreatedAt,
+			EventID:    models.NewEventID("github", issue.URL, "triage", issue.CreatedAt),
+			Author:     models.Author{Login: issue.Author},
+		}
+	}
+	// Oldest first, so the issues waiting longest are sent first.
+	sort.Slice(notifications, func(i, j int) bool { return notifications[i].OccurredAt.Before(notifications[j].OccurredAt) })
+	env.Enqueue(ctx, user, notifications)
+}
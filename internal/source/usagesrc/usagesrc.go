@@ -0,0 +1,165 @@
+// Package usagesrc warns chats with usage alerts when the GitHub plan
+// quotas of their accounts and organizations, such as the included Actions
+// minutes or Git LFS storage, are about to run out, and when their
+// repositories grow past the size GitHub recommends.
+package usagesrc
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// checkInterval limits how often a chat's quotas are looked up. GitHub
+// updates billing data a few times a day.
+const checkInterval = 6 * time.Hour
+
+func init() {
+	source.Register("usage", func() source.Source {
+		return &usageSource{checked: make(map[int64]time.Time)}
+	})
+}
+
+type usageSource struct {
+	mu      sync.Mutex
+	checked map[int64]time.Time
+}
+
+func (u *usageSource) Jobs(user *models.User) []source.Job {
+	var accounts []*models.GitHubAccount
+	for _, account := range user.Accounts {
+		if account.IsActive {
+			accounts = append(accounts, account)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Username < accounts[j].Username })
+
+	return []source.Job{{
+		Name: fmt.Sprintf("usage alerts of chat %d", user.ChatID),
+		Run: func(ctx context.Context, env *source.Env) {
+			u.poll(ctx, env, user, accounts)
+		},
+	}}
+}
+
+// due reports whether the chat's quotas were not checked within
+// checkInterval, and marks them checked.
+func (u *usageSource) due(chatID int64) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if time.Since(u.checked[chatID]) < checkInterval {
+		return false
+	}
+	u.checked[chatID] = time.Now()
+	return true
+}
+
+func (u *usageSource) poll(ctx context.Context, env *source.Env, user *models.User, accounts []*models.GitHubAccount) {
+	if !u.due(user.ChatID) {
+		return
+	}
+
+	preferences, err := env.Store.GetPreferences(ctx, user.ChatID)
+	if err != nil {
+		log.Printf("Error getting preferences of chat %d: %v", user.ChatID, err)
+		return
+	}
+	if preferences.Usage.Threshold == 0 {
+		return
+	}
+
+	ctx, span := tracing.Start(ctx, "poll.usage", attribute.Int64("chat_id", user.ChatID))
+	defer span.End()
+
+	var quotas []models.Quota
+	for _, account := range accounts {
+		quotas = append(quotas, lookup(ctx, env, []*models.GitHubAccount{account}, account.Username, false)...)
+	}
+	for _, org := range preferences.Usage.Orgs {
+		// Any of the chat's accounts may be the organization's billing
+		// manager.
+		quotas = append(quotas, lookup(ctx, env, accounts, org, true)...)
+	}
+
+	var notifications []models.Notification
+	now := time.Now()
+	for _, quota := range quotas {
+		if level := quota.Level(preferences.Usage.Threshold); level > 0 {
+			notifications = append(notifications, warning(quota, level, now))
+		}
+	}
+	env.Enqueue(ctx, user, notifications)
+}
+
+// lookup returns the Actions and storage quotas of the user or
+// organization, each read with the first of the accounts allowed to.
+func lookup(ctx context.Context, env *source.Env, accounts []*models.GitHubAccount, owner string, org bool) []models.Quota {
+	var quotas []models.Quota
+	for _, get := range []func(github.API, context.Context, string, bool) ([]models.Quota, error){
+		github.API.GetActionsQuotas,
+		github.API.GetStorageQuotas,
+	} {
+		var found []models.Quota
+		var err error
+		for _, account := range accounts {
+			fetchCtx, cancel := env.Timeout(ctx)
+			found, err = get(env.GitHub(account.Token), fetchCtx, owner, org)
+			cancel()
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			log.Printf("Error getting the quotas of %s: %v", owner, err)
+			continue
+		}
+		quotas = append(quotas, found...)
+	}
+	return quotas
+}
+
+// warning is the notification about a quota that reached level percent.
+// It is identified by the level and the billing cycle, so each level is
+// reported again in the next cycle, and only once for quotas without one.
+func warning(quota models.Quota, level int, now time.Time) models.Notification {
+	var cycleEnd time.Time
+	if quota.DaysLeft >= 0 {
+		cycleEnd = now.AddDate(0, 0, quota.DaysLeft).Truncate(24 * time.Hour)
+	}
+
+	amount := "%.0f of %.0f %s"
+	if quota.Unit == "GB" {
+		amount = "%.2f of %.2f %s"
+	}
+	message := fmt.Sprintf("[%s] %s at %d%%: "+amount, quota.Owner, quota.Name, quota.Percent(), quota.Used, quota.Included, quota.Unit)
+	switch {
+	case level == 100 && quota.DaysLeft < 0:
+		message = fmt.Sprintf("[%s] %s over the limit: "+amount, quota.Owner, quota.Name, quota.Used, quota.Included, quota.Unit)
+	case level == 100:
+		message = fmt.Sprintf("[%s] %s used up: "+amount, quota.Owner, quota.Name, quota.Used, quota.Included, quota.Unit)
+	}
+	if quota.DaysLeft >= 0 {
+		message += fmt.Sprintf(", %d days left in the billing cycle", quota.DaysLeft)
+	}
+
+	return models.Notification{
+		Type:       "quota_warning",
+		Message:    message,
+		URL:        quota.URL,
+		DedupKey:   fmt.Sprintf("quota:%s:%s:%d:%s", quota.Owner, quota.Name, level, cycleEnd.Format("2006-01-02")),
+		OccurredAt: now,
+		EventID:    models.NewEventID("github", quota.URL+"#"+quota.Name, fmt.Sprintf("quota_%d", level), cycleEnd),
+	}
+}
@@ -0,0 +1,90 @@
+package cached
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/cache"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// Store answers dedup lookups from a cache before falling back to the
+// wrapped store, so items that were already delivered don't hit the
+// database on every poll cycle. All other methods pass through.
+type Store struct {
+	store.Store
+	cache            cache.Cache
+	renotifyInterval time.Duration
+}
+
+func New(next store.Store, cache cache.Cache, renotifyInterval time.Duration) *Store {
+	return &Store{
+		Store:            next,
+		cache:            cache,
+		renotifyInterval: renotifyInterval,
+	}
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	key := notifiedKey(chatID, itemURL, contentHash)
+	if data, found, err := s.cache.Get(ctx, key); err != nil {
+		log.Printf("Warning: dedup cache lookup failed, falling back to store: %v", err)
+	} else if found && recentlyNotified(data, renotifyInterval) {
+		return false, nil
+	}
+
+	return s.Store.ShouldNotify(ctx, chatID, itemURL, contentHash, renotifyInterval)
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	if err := s.Store.RecordNotification(ctx, chatID, itemURL, notificationType, contentHash); err != nil {
+		return err
+	}
+
+	s.markNotified(ctx, chatID, itemURL, contentHash, s.renotifyInterval)
+	return nil
+}
+
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	queued, err := s.Store.EnqueueNotification(ctx, chatID, notification, contentHash, renotifyInterval)
+	if err != nil {
+		return false, err
+	}
+
+	// A false result means another worker queued it first, which is just
+	// as good a reason to skip it until the renotify interval passes.
+	s.markNotified(ctx, chatID, notification.URL, contentHash, max(s.renotifyInterval, renotifyInterval))
+	return queued, nil
+}
+
+// markNotified remembers when the notification was sent. The entry may
+// outlive a shorter renotify interval of its type, which ShouldNotify
+// checks against the time.
+func (s *Store) markNotified(ctx context.Context, chatID int64, itemURL, contentHash string, ttl time.Duration) {
+	key := notifiedKey(chatID, itemURL, contentHash)
+	sentAt := strconv.FormatInt(time.Now().Unix(), 10)
+	if err := s.cache.Set(ctx, key, []byte(sentAt), ttl); err != nil {
+		log.Printf("Warning: failed to cache sent notification: %v", err)
+	}
+}
+
+// recentlyNotified reports whether a notification cached as sent is still
+// within the renotify interval. Entries without a time are trusted for
+// as long as they are cached.
+func recentlyNotified(data []byte, renotifyInterval time.Duration) bool {
+	sentAt, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil || renotifyInterval < 0 {
+		return true
+	}
+	return time.Since(time.Unix(sentAt, 0)) <= renotifyInterval
+}
+
+func notifiedKey(chatID int64, itemURL, contentHash string) string {
+	sum := sha256.Sum256([]byte(itemURL + "\x00" + contentHash))
+	return fmt.Sprintf("notified:%d:%x", chatID, sum[:16])
+}
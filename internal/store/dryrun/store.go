@@ -0,0 +1,179 @@
+package dryrun
+
+import (
+	"context"
+	"log"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// Store keeps what polling writes in memory instead of the wrapped store,
+// so a dry run can poll against production data without changing it.
+// Notifications that would be queued are logged instead. Later cycles see
+// the in-memory state on top of the wrapped store, so each notification is
+// logged once per run. Review request and mention tracking is dropped, as
+// it only feeds /analytics and SLA reminders. All other methods pass
+// through.
+type Store struct {
+	store.Store
+
+	mu           sync.Mutex
+	notified     map[notifiedKey]bool
+	states       map[stateKey]models.AccountState
+	imageTags    map[int64]map[string]bool
+	depsVersions map[int64]map[string]bool
+}
+
+type notifiedKey struct {
+	chatID      int64
+	itemURL     string
+	contentHash string
+}
+
+type stateKey struct {
+	chatID  int64
+	kind    string
+	account string
+}
+
+func New(next store.Store) *Store {
+	return &Store{
+		Store:        next,
+		notified:     make(map[notifiedKey]bool),
+		states:       make(map[stateKey]models.AccountState),
+		imageTags:    make(map[int64]map[string]bool),
+		depsVersions: make(map[int64]map[string]bool),
+	}
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	s.mu.Lock()
+	notified := s.notified[notifiedKey{chatID, itemURL, contentHash}]
+	s.mu.Unlock()
+	if notified {
+		return false, nil
+	}
+
+	return s.Store.ShouldNotify(ctx, chatID, itemURL, contentHash, renotifyInterval)
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.notified[notifiedKey{chatID, itemURL, contentHash}] = true
+	return nil
+}
+
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := notifiedKey{chatID, notification.URL, contentHash}
+	if s.notified[key] {
+		return false, nil
+	}
+	s.notified[key] = true
+
+	log.Printf("[dry-run] Would send %s notification to chat %d: %s\n%s", notification.Type, chatID, notification.URL, notification.Message)
+	return true, nil
+}
+
+func (s *Store) GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error) {
+	s.mu.Lock()
+	state, ok := s.states[stateKey{chatID, kind, account}]
+	s.mu.Unlock()
+	if ok {
+		return state, nil
+	}
+
+	return s.Store.GetAccountState(ctx, chatID, kind, account)
+}
+
+func (s *Store) SaveAccountState(ctx context.Context, state models.AccountState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.states[stateKey{state.ChatID, state.Kind, state.Account}] = state
+	return nil
+}
+
+func (s *Store) GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	seen, err := s.Store.GetSeenImageTags(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return merge(seen, s.imageTags[subscriptionID]), nil
+}
+
+func (s *Store) MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.imageTags[subscriptionID] = add(s.imageTags[subscriptionID], tags)
+	return nil
+}
+
+func (s *Store) GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	seen, err := s.Store.GetSeenDependencyVersions(ctx, subscriptionID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return merge(seen, s.depsVersions[subscriptionID]), nil
+}
+
+func (s *Store) MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.depsVersions[subscriptionID] = add(s.depsVersions[subscriptionID], keys)
+	return nil
+}
+
+func (s *Store) RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error {
+	return nil
+}
+
+func (s *Store) CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error {
+	return nil
+}
+
+func (s *Store) RemoveReviewRequest(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (s *Store) RecordMention(ctx context.Context, mention models.Mention) error {
+	return nil
+}
+
+func (s *Store) RemoveMention(ctx context.Context, id int64) error {
+	return nil
+}
+
+func merge(seen, overlay map[string]bool) map[string]bool {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	maps.Copy(seen, overlay)
+	return seen
+}
+
+func add(seen map[string]bool, keys []string) map[string]bool {
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	for _, key := range keys {
+		seen[key] = true
+	}
+	return seen
+}
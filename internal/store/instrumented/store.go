@@ -0,0 +1,628 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/metrics"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Store records the latency, errors and returned rows of every operation
+// of the wrapped store as metrics and as a trace span.
+type Store struct {
+	next store.Store
+}
+
+var _ store.Store = (*Store)(nil)
+
+func New(next store.Store) *Store {
+	return &Store{next: next}
+}
+
+func observe(span trace.Span, method string, start time.Time, err error, rows int) {
+	if rows >= 0 {
+		span.SetAttributes(attribute.Int("rows", rows))
+	}
+	tracing.End(span, err)
+
+	metrics.StoreDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.StoreErrors.WithLabelValues(method).Inc()
+	}
+	if rows >= 0 {
+		metrics.StoreRows.WithLabelValues(method).Add(float64(rows))
+	}
+}
+
+func (s *Store) Close() error {
+	return s.next.Close()
+}
+
+func (s *Store) AddGitHubAccount(ctx context.Context, chatID int64, githubToken, githubUsername string, metadata models.GitHubAccountMetadata) error {
+	ctx, span := tracing.Start(ctx, "store.AddGitHubAccount")
+	start := time.Now()
+	err := s.next.AddGitHubAccount(ctx, chatID, githubToken, githubUsername, metadata)
+	observe(span, "AddGitHubAccount", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveGitHubAccount")
+	start := time.Now()
+	err := s.next.RemoveGitHubAccount(ctx, chatID, githubUsername)
+	observe(span, "RemoveGitHubAccount", start, err, -1)
+	return err
+}
+
+func (s *Store) ToggleGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	ctx, span := tracing.Start(ctx, "store.ToggleGitHubAccount")
+	start := time.Now()
+	err := s.next.ToggleGitHubAccount(ctx, chatID, githubUsername)
+	observe(span, "ToggleGitHubAccount", start, err, -1)
+	return err
+}
+
+func (s *Store) GetAccountPreferences(ctx context.Context, chatID int64, githubUsername string) (models.AccountPreferences, error) {
+	ctx, span := tracing.Start(ctx, "store.GetAccountPreferences")
+	start := time.Now()
+	result, err := s.next.GetAccountPreferences(ctx, chatID, githubUsername)
+	observe(span, "GetAccountPreferences", start, err, -1)
+	return result, err
+}
+
+func (s *Store) SetAccountPreferences(ctx context.Context, preferences models.AccountPreferences) error {
+	ctx, span := tracing.Start(ctx, "store.SetAccountPreferences")
+	start := time.Now()
+	err := s.next.SetAccountPreferences(ctx, preferences)
+	observe(span, "SetAccountPreferences", start, err, -1)
+	return err
+}
+
+func (s *Store) AddGerritAccount(ctx context.Context, chatID int64, account models.GerritAccount) error {
+	ctx, span := tracing.Start(ctx, "store.AddGerritAccount")
+	start := time.Now()
+	err := s.next.AddGerritAccount(ctx, chatID, account)
+	observe(span, "AddGerritAccount", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveGerritAccount(ctx context.Context, chatID int64, baseURL, username string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveGerritAccount")
+	start := time.Now()
+	err := s.next.RemoveGerritAccount(ctx, chatID, baseURL, username)
+	observe(span, "RemoveGerritAccount", start, err, -1)
+	return err
+}
+
+func (s *Store) GetUser(ctx context.Context, chatID int64) (*models.User, bool) {
+	ctx, span := tracing.Start(ctx, "store.GetUser")
+	start := time.Now()
+	result, ok := s.next.GetUser(ctx, chatID)
+	observe(span, "GetUser", start, nil, -1)
+	return result, ok
+}
+
+func (s *Store) ListUsers(ctx context.Context, afterChatID int64, limit int) ([]*models.User, error) {
+	ctx, span := tracing.Start(ctx, "store.ListUsers")
+	start := time.Now()
+	result, err := s.next.ListUsers(ctx, afterChatID, limit)
+	observe(span, "ListUsers", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) AddImageSubscription(ctx context.Context, chatID int64, image, tagFilter string, seenTags []string) error {
+	ctx, span := tracing.Start(ctx, "store.AddImageSubscription")
+	start := time.Now()
+	err := s.next.AddImageSubscription(ctx, chatID, image, tagFilter, seenTags)
+	observe(span, "AddImageSubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveImageSubscription(ctx context.Context, chatID int64, image string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveImageSubscription")
+	start := time.Now()
+	err := s.next.RemoveImageSubscription(ctx, chatID, image)
+	observe(span, "RemoveImageSubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) GetImageSubscriptions(ctx context.Context, chatID int64) ([]models.ImageSubscription, error) {
+	ctx, span := tracing.Start(ctx, "store.GetImageSubscriptions")
+	start := time.Now()
+	result, err := s.next.GetImageSubscriptions(ctx, chatID)
+	observe(span, "GetImageSubscriptions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "store.GetSeenImageTags")
+	start := time.Now()
+	result, err := s.next.GetSeenImageTags(ctx, subscriptionID)
+	observe(span, "GetSeenImageTags", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error {
+	ctx, span := tracing.Start(ctx, "store.MarkImageTagsSeen")
+	start := time.Now()
+	err := s.next.MarkImageTagsSeen(ctx, subscriptionID, tags)
+	observe(span, "MarkImageTagsSeen", start, err, -1)
+	return err
+}
+
+func (s *Store) AddDependencySubscription(ctx context.Context, chatID int64, repo string, seenVersions []string) error {
+	ctx, span := tracing.Start(ctx, "store.AddDependencySubscription")
+	start := time.Now()
+	err := s.next.AddDependencySubscription(ctx, chatID, repo, seenVersions)
+	observe(span, "AddDependencySubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveDependencySubscription(ctx context.Context, chatID int64, repo string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveDependencySubscription")
+	start := time.Now()
+	err := s.next.RemoveDependencySubscription(ctx, chatID, repo)
+	observe(span, "RemoveDependencySubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) GetDependencySubscriptions(ctx context.Context, chatID int64) ([]models.DependencySubscription, error) {
+	ctx, span := tracing.Start(ctx, "store.GetDependencySubscriptions")
+	start := time.Now()
+	result, err := s.next.GetDependencySubscriptions(ctx, chatID)
+	observe(span, "GetDependencySubscriptions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	ctx, span := tracing.Start(ctx, "store.GetSeenDependencyVersions")
+	start := time.Now()
+	result, err := s.next.GetSeenDependencyVersions(ctx, subscriptionID)
+	observe(span, "GetSeenDependencyVersions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error {
+	ctx, span := tracing.Start(ctx, "store.MarkDependencyVersionsSeen")
+	start := time.Now()
+	err := s.next.MarkDependencyVersionsSeen(ctx, subscriptionID, keys)
+	observe(span, "MarkDependencyVersionsSeen", start, err, -1)
+	return err
+}
+
+func (s *Store) GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error) {
+	ctx, span := tracing.Start(ctx, "store.GetAccountState")
+	start := time.Now()
+	result, err := s.next.GetAccountState(ctx, chatID, kind, account)
+	observe(span, "GetAccountState", start, err, -1)
+	return result, err
+}
+
+func (s *Store) SaveAccountState(ctx context.Context, state models.AccountState) error {
+	ctx, span := tracing.Start(ctx, "store.SaveAccountState")
+	start := time.Now()
+	err := s.next.SaveAccountState(ctx, state)
+	observe(span, "SaveAccountState", start, err, -1)
+	return err
+}
+
+func (s *Store) GetPreferences(ctx context.Context, chatID int64) (models.Preferences, error) {
+	ctx, span := tracing.Start(ctx, "store.GetPreferences")
+	start := time.Now()
+	result, err := s.next.GetPreferences(ctx, chatID)
+	observe(span, "GetPreferences", start, err, -1)
+	return result, err
+}
+
+func (s *Store) SetPreferences(ctx context.Context, preferences models.Preferences) error {
+	ctx, span := tracing.Start(ctx, "store.SetPreferences")
+	start := time.Now()
+	err := s.next.SetPreferences(ctx, preferences)
+	observe(span, "SetPreferences", start, err, -1)
+	return err
+}
+
+func (s *Store) AddRepoSubscription(ctx context.Context, subscription models.RepoSubscription) (int64, error) {
+	ctx, span := tracing.Start(ctx, "store.AddRepoSubscription")
+	start := time.Now()
+	result, err := s.next.AddRepoSubscription(ctx, subscription)
+	observe(span, "AddRepoSubscription", start, err, -1)
+	return result, err
+}
+
+func (s *Store) RemoveRepoSubscription(ctx context.Context, chatID int64, repo string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveRepoSubscription")
+	start := time.Now()
+	err := s.next.RemoveRepoSubscription(ctx, chatID, repo)
+	observe(span, "RemoveRepoSubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) GetRepoSubscriptions(ctx context.Context, chatID int64) ([]models.RepoSubscription, error) {
+	ctx, span := tracing.Start(ctx, "store.GetRepoSubscriptions")
+	start := time.Now()
+	result, err := s.next.GetRepoSubscriptions(ctx, chatID)
+	observe(span, "GetRepoSubscriptions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) AddPathSubscription(ctx context.Context, subscription models.PathSubscription) (int64, error) {
+	ctx, span := tracing.Start(ctx, "store.AddPathSubscription")
+	start := time.Now()
+	result, err := s.next.AddPathSubscription(ctx, subscription)
+	observe(span, "AddPathSubscription", start, err, -1)
+	return result, err
+}
+
+func (s *Store) RemovePathSubscription(ctx context.Context, chatID int64, repo, pattern string) error {
+	ctx, span := tracing.Start(ctx, "store.RemovePathSubscription")
+	start := time.Now()
+	err := s.next.RemovePathSubscription(ctx, chatID, repo, pattern)
+	observe(span, "RemovePathSubscription", start, err, -1)
+	return err
+}
+
+func (s *Store) GetPathSubscriptions(ctx context.Context, chatID int64) ([]models.PathSubscription, error) {
+	ctx, span := tracing.Start(ctx, "store.GetPathSubscriptions")
+	start := time.Now()
+	result, err := s.next.GetPathSubscriptions(ctx, chatID)
+	observe(span, "GetPathSubscriptions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) AddRoutingRule(ctx context.Context, rule models.RoutingRule) (int64, error) {
+	ctx, span := tracing.Start(ctx, "store.AddRoutingRule")
+	start := time.Now()
+	result, err := s.next.AddRoutingRule(ctx, rule)
+	observe(span, "AddRoutingRule", start, err, -1)
+	return result, err
+}
+
+func (s *Store) RemoveRoutingRule(ctx context.Context, chatID, id int64) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveRoutingRule")
+	start := time.Now()
+	err := s.next.RemoveRoutingRule(ctx, chatID, id)
+	observe(span, "RemoveRoutingRule", start, err, -1)
+	return err
+}
+
+func (s *Store) GetRoutingRules(ctx context.Context, chatID int64) ([]models.RoutingRule, error) {
+	ctx, span := tracing.Start(ctx, "store.GetRoutingRules")
+	start := time.Now()
+	result, err := s.next.GetRoutingRules(ctx, chatID)
+	observe(span, "GetRoutingRules", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error {
+	ctx, span := tracing.Start(ctx, "store.RecordReviewRequest")
+	start := time.Now()
+	err := s.next.RecordReviewRequest(ctx, request)
+	observe(span, "RecordReviewRequest", start, err, -1)
+	return err
+}
+
+func (s *Store) GetReviewRequests(ctx context.Context, chatID int64, since time.Time) ([]models.ReviewRequest, error) {
+	ctx, span := tracing.Start(ctx, "store.GetReviewRequests")
+	start := time.Now()
+	result, err := s.next.GetReviewRequests(ctx, chatID, since)
+	observe(span, "GetReviewRequests", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error {
+	ctx, span := tracing.Start(ctx, "store.CompleteReviewRequest")
+	start := time.Now()
+	err := s.next.CompleteReviewRequest(ctx, id, reviewedAt)
+	observe(span, "CompleteReviewRequest", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveReviewRequest(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveReviewRequest")
+	start := time.Now()
+	err := s.next.RemoveReviewRequest(ctx, id)
+	observe(span, "RemoveReviewRequest", start, err, -1)
+	return err
+}
+
+func (s *Store) RecordMention(ctx context.Context, mention models.Mention) error {
+	ctx, span := tracing.Start(ctx, "store.RecordMention")
+	start := time.Now()
+	err := s.next.RecordMention(ctx, mention)
+	observe(span, "RecordMention", start, err, -1)
+	return err
+}
+
+func (s *Store) GetMentions(ctx context.Context, chatID int64) ([]models.Mention, error) {
+	ctx, span := tracing.Start(ctx, "store.GetMentions")
+	start := time.Now()
+	result, err := s.next.GetMentions(ctx, chatID)
+	observe(span, "GetMentions", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) RemoveMention(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveMention")
+	start := time.Now()
+	err := s.next.RemoveMention(ctx, id)
+	observe(span, "RemoveMention", start, err, -1)
+	return err
+}
+
+func (s *Store) AddTenant(ctx context.Context, tenant models.Tenant) error {
+	ctx, span := tracing.Start(ctx, "store.AddTenant")
+	start := time.Now()
+	err := s.next.AddTenant(ctx, tenant)
+	observe(span, "AddTenant", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveTenant(ctx context.Context, tenantID string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveTenant")
+	start := time.Now()
+	err := s.next.RemoveTenant(ctx, tenantID)
+	observe(span, "RemoveTenant", start, err, -1)
+	return err
+}
+
+func (s *Store) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	ctx, span := tracing.Start(ctx, "store.GetTenants")
+	start := time.Now()
+	result, err := s.next.GetTenants(ctx)
+	observe(span, "GetTenants", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) ChatTenant(ctx context.Context, chatID int64) (string, bool, error) {
+	ctx, span := tracing.Start(ctx, "store.ChatTenant")
+	start := time.Now()
+	tenantID, found, err := s.next.ChatTenant(ctx, chatID)
+	observe(span, "ChatTenant", start, err, -1)
+	return tenantID, found, err
+}
+
+func (s *Store) SetFeatureFlag(ctx context.Context, flag models.FeatureFlag) error {
+	ctx, span := tracing.Start(ctx, "store.SetFeatureFlag")
+	start := time.Now()
+	err := s.next.SetFeatureFlag(ctx, flag)
+	observe(span, "SetFeatureFlag", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveFeatureFlag(ctx context.Context, name string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveFeatureFlag")
+	start := time.Now()
+	err := s.next.RemoveFeatureFlag(ctx, name)
+	observe(span, "RemoveFeatureFlag", start, err, -1)
+	return err
+}
+
+func (s *Store) GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	ctx, span := tracing.Start(ctx, "store.GetFeatureFlags")
+	start := time.Now()
+	result, err := s.next.GetFeatureFlags(ctx)
+	observe(span, "GetFeatureFlags", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) MuteRepo(ctx context.Context, chatID int64, repo string) error {
+	ctx, span := tracing.Start(ctx, "store.MuteRepo")
+	start := time.Now()
+	err := s.next.MuteRepo(ctx, chatID, repo)
+	observe(span, "MuteRepo", start, err, -1)
+	return err
+}
+
+func (s *Store) UnmuteRepo(ctx context.Context, chatID int64, repo string) error {
+	ctx, span := tracing.Start(ctx, "store.UnmuteRepo")
+	start := time.Now()
+	err := s.next.UnmuteRepo(ctx, chatID, repo)
+	observe(span, "UnmuteRepo", start, err, -1)
+	return err
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	ctx, span := tracing.Start(ctx, "store.ShouldNotify")
+	start := time.Now()
+	result, err := s.next.ShouldNotify(ctx, chatID, itemURL, contentHash, renotifyInterval)
+	observe(span, "ShouldNotify", start, err, -1)
+	return result, err
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	ctx, span := tracing.Start(ctx, "store.RecordNotification")
+	start := time.Now()
+	err := s.next.RecordNotification(ctx, chatID, itemURL, notificationType, contentHash)
+	observe(span, "RecordNotification", start, err, -1)
+	return err
+}
+
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	ctx, span := tracing.Start(ctx, "store.EnqueueNotification")
+	start := time.Now()
+	queued, err := s.next.EnqueueNotification(ctx, chatID, notification, contentHash, renotifyInterval)
+	observe(span, "EnqueueNotification", start, err, -1)
+	return queued, err
+}
+
+func (s *Store) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	ctx, span := tracing.Start(ctx, "store.ClaimOutbox")
+	start := time.Now()
+	result, err := s.next.ClaimOutbox(ctx, limit, lease)
+	observe(span, "ClaimOutbox", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) MarkOutboxSent(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "store.MarkOutboxSent")
+	start := time.Now()
+	err := s.next.MarkOutboxSent(ctx, id)
+	observe(span, "MarkOutboxSent", start, err, -1)
+	return err
+}
+
+func (s *Store) MarkOutboxRetry(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error {
+	ctx, span := tracing.Start(ctx, "store.MarkOutboxRetry")
+	start := time.Now()
+	err := s.next.MarkOutboxRetry(ctx, id, lastError, nextAttempt)
+	observe(span, "MarkOutboxRetry", start, err, -1)
+	return err
+}
+
+func (s *Store) DeferOutbox(ctx context.Context, id int64, until time.Time) error {
+	ctx, span := tracing.Start(ctx, "store.DeferOutbox")
+	start := time.Now()
+	err := s.next.DeferOutbox(ctx, id, until)
+	observe(span, "DeferOutbox", start, err, -1)
+	return err
+}
+
+func (s *Store) CountPendingOutbox(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "store.CountPendingOutbox")
+	start := time.Now()
+	result, err := s.next.CountPendingOutbox(ctx)
+	observe(span, "CountPendingOutbox", start, err, -1)
+	return result, err
+}
+
+func (s *Store) MarkOutboxDead(ctx context.Context, id int64, lastError string) error {
+	ctx, span := tracing.Start(ctx, "store.MarkOutboxDead")
+	start := time.Now()
+	err := s.next.MarkOutboxDead(ctx, id, lastError)
+	observe(span, "MarkOutboxDead", start, err, -1)
+	return err
+}
+
+func (s *Store) GetOutbox(ctx context.Context, query store.OutboxQuery) ([]models.OutboxEntry, error) {
+	ctx, span := tracing.Start(ctx, "store.GetOutbox")
+	start := time.Now()
+	result, err := s.next.GetOutbox(ctx, query)
+	observe(span, "GetOutbox", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) GetOutboxEntry(ctx context.Context, id int64) (models.OutboxEntry, error) {
+	ctx, span := tracing.Start(ctx, "store.GetOutboxEntry")
+	start := time.Now()
+	result, err := s.next.GetOutboxEntry(ctx, id)
+	observe(span, "GetOutboxEntry", start, err, -1)
+	return result, err
+}
+
+func (s *Store) RequeueOutbox(ctx context.Context, id int64) error {
+	ctx, span := tracing.Start(ctx, "store.RequeueOutbox")
+	start := time.Now()
+	err := s.next.RequeueOutbox(ctx, id)
+	observe(span, "RequeueOutbox", start, err, -1)
+	return err
+}
+
+func (s *Store) CleanOldNotifications(ctx context.Context, before time.Time) error {
+	ctx, span := tracing.Start(ctx, "store.CleanOldNotifications")
+	start := time.Now()
+	err := s.next.CleanOldNotifications(ctx, before)
+	observe(span, "CleanOldNotifications", start, err, -1)
+	return err
+}
+
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "store.PurgeDeletedUsers")
+	start := time.Now()
+	result, err := s.next.PurgeDeletedUsers(ctx, before)
+	observe(span, "PurgeDeletedUsers", start, err, -1)
+	return result, err
+}
+
+func (s *Store) GetNotificationHistory(ctx context.Context, query store.HistoryQuery) ([]models.NotificationRecord, error) {
+	ctx, span := tracing.Start(ctx, "store.GetNotificationHistory")
+	start := time.Now()
+	result, err := s.next.GetNotificationHistory(ctx, query)
+	observe(span, "GetNotificationHistory", start, err, len(result))
+	return result, err
+}
+
+func (s *Store) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	ctx, span := tracing.Start(ctx, "store.GetCalendarToken")
+	start := time.Now()
+	result, err := s.next.GetCalendarToken(ctx, chatID)
+	observe(span, "GetCalendarToken", start, err, -1)
+	return result, err
+}
+
+func (s *Store) GetUserByCalendarToken(ctx context.Context, token string) (*models.User, bool) {
+	ctx, span := tracing.Start(ctx, "store.GetUserByCalendarToken")
+	start := time.Now()
+	result, ok := s.next.GetUserByCalendarToken(ctx, token)
+	observe(span, "GetUserByCalendarToken", start, nil, -1)
+	return result, ok
+}
+
+func (s *Store) SetJiraConfig(ctx context.Context, config models.JiraConfig) error {
+	ctx, span := tracing.Start(ctx, "store.SetJiraConfig")
+	start := time.Now()
+	err := s.next.SetJiraConfig(ctx, config)
+	observe(span, "SetJiraConfig", start, err, -1)
+	return err
+}
+
+func (s *Store) GetJiraConfig(ctx context.Context, chatID int64) (*models.JiraConfig, bool) {
+	ctx, span := tracing.Start(ctx, "store.GetJiraConfig")
+	start := time.Now()
+	result, ok := s.next.GetJiraConfig(ctx, chatID)
+	observe(span, "GetJiraConfig", start, nil, -1)
+	return result, ok
+}
+
+func (s *Store) RemoveJiraConfig(ctx context.Context, chatID int64) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveJiraConfig")
+	start := time.Now()
+	err := s.next.RemoveJiraConfig(ctx, chatID)
+	observe(span, "RemoveJiraConfig", start, err, -1)
+	return err
+}
+
+func (s *Store) SetLinearConfig(ctx context.Context, chatID int64, apiKey string, target models.LinearTarget) error {
+	ctx, span := tracing.Start(ctx, "store.SetLinearConfig")
+	start := time.Now()
+	err := s.next.SetLinearConfig(ctx, chatID, apiKey, target)
+	observe(span, "SetLinearConfig", start, err, -1)
+	return err
+}
+
+func (s *Store) GetLinearConfig(ctx context.Context, chatID int64) (*models.LinearConfig, bool) {
+	ctx, span := tracing.Start(ctx, "store.GetLinearConfig")
+	start := time.Now()
+	result, ok := s.next.GetLinearConfig(ctx, chatID)
+	observe(span, "GetLinearConfig", start, nil, -1)
+	return result, ok
+}
+
+func (s *Store) RemoveLinearConfig(ctx context.Context, chatID int64) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveLinearConfig")
+	start := time.Now()
+	err := s.next.RemoveLinearConfig(ctx, chatID)
+	observe(span, "RemoveLinearConfig", start, err, -1)
+	return err
+}
+
+func (s *Store) SetLinearMapping(ctx context.Context, chatID int64, repo string, target models.LinearTarget) error {
+	ctx, span := tracing.Start(ctx, "store.SetLinearMapping")
+	start := time.Now()
+	err := s.next.SetLinearMapping(ctx, chatID, repo, target)
+	observe(span, "SetLinearMapping", start, err, -1)
+	return err
+}
+
+func (s *Store) RemoveLinearMapping(ctx context.Context, chatID int64, repo string) error {
+	ctx, span := tracing.Start(ctx, "store.RemoveLinearMapping")
+	start := time.Now()
+	err := s.next.RemoveLinearMapping(ctx, chatID, repo)
+	observe(span, "RemoveLinearMapping", start, err, -1)
+	return err
+}
@@ -0,0 +1,90 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+// fileState is the part of a Store that Open keeps in its file: what was
+// sent, for deduplication, and how far each account was polled.
+type fileState struct {
+	Notifications []models.NotificationRecord `json:"notifications"`
+	AccountStates []models.AccountState       `json:"account_states"`
+}
+
+// Open returns a Store that keeps its dedup records and account state in
+// the file at path, so a restart neither repeats notifications nor polls
+// accounts from scratch. Everything else is still lost on restart. The
+// file is rewritten whenever that state changes.
+func Open(path string) (*Store, error) {
+	s := New()
+	s.path = path
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %v", err)
+	}
+
+	var state fileState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to decode state file %s: %v", path, err)
+	}
+
+	s.notifications = state.Notifications
+	for _, record := range s.notifications {
+		s.nextID = max(s.nextID, record.ID)
+	}
+	for _, accountState := range state.AccountStates {
+		s.states[stateKey{accountState.ChatID, accountState.Kind, accountState.Account}] = accountState
+	}
+
+	return s, nil
+}
+
+// persist writes the state file of a Store created with Open. The caller
+// must hold s.mu.
+func (s *Store) persist() error {
+	if s.path == "" {
+		return nil
+	}
+
+	state := fileState{Notifications: s.notifications}
+	for _, accountState := range s.states {
+		state.AccountStates = append(state.AccountStates, accountState)
+	}
+	sort.Slice(state.AccountStates, func(i, j int) bool {
+		a, b := state.AccountStates[i], state.AccountStates[j]
+		if a.ChatID != b.ChatID {
+			return a.ChatID < b.ChatID
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Account < b.Account
+	})
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %v", err)
+	}
+
+	// Write a temporary file first so a crash never leaves a truncated
+	// state file behind
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state file: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %v", err)
+	}
+	return nil
+}
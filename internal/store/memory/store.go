@@ -0,0 +1,1479 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// Store keeps everything in memory. It behaves like the Postgres store,
+// as checked by the storetest contract suite, and is meant for tests and
+// for running without a database. Nothing survives a restart unless the
+// store was created with Open.
+type Store struct {
+	mu sync.Mutex
+
+	// path is the state file of a store created with Open.
+	path string
+
+	nextID        int64
+	tenants       map[string]models.Tenant
+	flags         map[string]models.FeatureFlag
+	users         map[int64]*user
+	states        map[stateKey]models.AccountState
+	images        map[int64]*imageSubscription
+	dependencies  map[int64]*dependencySubscription
+	repos         map[int64]models.RepoSubscription
+	paths         map[int64]models.PathSubscription
+	rules         []models.RoutingRule
+	reviews       []models.ReviewRequest
+	mentions      []models.Mention
+	notifications []models.NotificationRecord
+	outbox        []*outboxEntry
+}
+
+var _ store.Store = (*Store)(nil)
+
+type user struct {
+	chatID        int64
+	tenantID      string
+	deletedAt     time.Time
+	calendarToken string
+	accounts      map[string]models.GitHubAccount
+	// accountPreferences are keyed by the account's username.
+	accountPreferences map[string]models.AccountPreferences
+	gerrit             []models.GerritAccount
+	muted              map[string]bool
+	preferences        *models.Preferences
+	jira               *models.JiraConfig
+	linear             *models.LinearConfig
+}
+
+type stateKey struct {
+	chatID  int64
+	kind    string
+	account string
+}
+
+type imageSubscription struct {
+	subscription models.ImageSubscription
+	seen         map[string]bool
+}
+
+type dependencySubscription struct {
+	subscription models.DependencySubscription
+	seen         map[string]bool
+}
+
+type outboxEntry struct {
+	entry         models.OutboxEntry
+	nextAttemptAt time.Time
+	lastError     string
+	sentAt        time.Time
+	deadAt        time.Time
+}
+
+func New() *Store {
+	return &Store{
+		tenants:      make(map[string]models.Tenant),
+		flags:        make(map[string]models.FeatureFlag),
+		users:        make(map[int64]*user),
+		states:       make(map[stateKey]models.AccountState),
+		images:       make(map[int64]*imageSubscription),
+		dependencies: make(map[int64]*dependencySubscription),
+		repos:        make(map[int64]models.RepoSubscription),
+		paths:        make(map[int64]models.PathSubscription),
+	}
+}
+
+func (s *Store) Close() error {
+	return nil
+}
+
+func (s *Store) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// activeUser returns the user if it exists, is not deleted and belongs to
+// the context's tenant.
+func (s *Store) activeUser(ctx context.Context, chatID int64) (*user, error) {
+	u, ok := s.users[chatID]
+	if !ok || !u.deletedAt.IsZero() {
+		return nil, store.ErrUserNotFound
+	}
+	if tenantID, scoped := store.TenantFromContext(ctx); scoped && u.tenantID != tenantID {
+		return nil, store.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// ensureUser creates the user in the context's tenant or restores it.
+func (s *Store) ensureUser(ctx context.Context, chatID int64) (*user, error) {
+	tenantID, scoped := store.TenantFromContext(ctx)
+	if !scoped {
+		tenantID = store.DefaultTenant
+	}
+
+	u, ok := s.users[chatID]
+	if !ok {
+		u = &user{
+			chatID:   chatID,
+			tenantID: tenantID,
+			accounts: make(map[string]models.GitHubAccount),
+			muted:    make(map[string]bool),
+		}
+		s.users[chatID] = u
+		return u, nil
+	}
+	if scoped && u.tenantID != tenantID {
+		return nil, store.ErrTenantMismatch
+	}
+
+	u.deletedAt = time.Time{}
+	return u, nil
+}
+
+func (s *Store) removeUserIfEmpty(u *user) {
+	if len(u.accounts)+len(u.gerrit) == 0 {
+		u.deletedAt = time.Now()
+	}
+}
+
+func (s *Store) AddTenant(ctx context.Context, tenant models.Tenant) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tenant.ID == "" || tenant.ID == store.DefaultTenant {
+		return fmt.Errorf("invalid tenant ID %q", tenant.ID)
+	}
+
+	if existing, ok := s.tenants[tenant.ID]; ok {
+		tenant.CreatedAt = existing.CreatedAt
+	} else {
+		tenant.CreatedAt = time.Now()
+	}
+	s.tenants[tenant.ID] = tenant
+	return nil
+}
+
+func (s *Store) RemoveTenant(ctx context.Context, tenantID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := 0
+	for _, u := range s.users {
+		if u.tenantID == tenantID {
+			users++
+		}
+	}
+	if users > 0 {
+		return fmt.Errorf("tenant %s still has %d users", tenantID, users)
+	}
+	if _, ok := s.tenants[tenantID]; !ok {
+		return fmt.Errorf("tenant not found")
+	}
+
+	delete(s.tenants, tenantID)
+	return nil
+}
+
+func (s *Store) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tenants []models.Tenant
+	for _, tenant := range s.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (s *Store) SetFeatureFlag(ctx context.Context, flag models.FeatureFlag) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+
+	flag.ChatIDs = slices.Clone(flag.ChatIDs)
+	flag.UpdatedAt = time.Now()
+	s.flags[flag.Name] = flag
+	return nil
+}
+
+func (s *Store) RemoveFeatureFlag(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.flags[name]; !ok {
+		return fmt.Errorf("feature flag not found")
+	}
+	delete(s.flags, name)
+	return nil
+}
+
+func (s *Store) GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var flags []models.FeatureFlag
+	for _, flag := range s.flags {
+		flag.ChatIDs = slices.Clone(flag.ChatIDs)
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+func (s *Store) ChatTenant(ctx context.Context, chatID int64) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok {
+		return "", false, nil
+	}
+	return u.tenantID, true, nil
+}
+
+func (s *Store) AddGitHubAccount(ctx context.Context, chatID int64, githubToken, githubUsername string, metadata models.GitHubAccountMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.ensureUser(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	account := models.GitHubAccount{Username: githubUsername, Token: githubToken, IsActive: true, AddedAt: time.Now()}
+	account.GitHubAccountMetadata = metadata
+	account.Scopes = slices.Clone(metadata.Scopes)
+	if existing, ok := u.accounts[githubUsername]; ok {
+		account.AddedAt = existing.AddedAt
+		if account.Note == "" {
+			account.Note = existing.Note
+		}
+	}
+	u.accounts[githubUsername] = account
+	return nil
+}
+
+func (s *Store) RemoveGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, chatID)
+	if err != nil {
+		return nil
+	}
+
+	delete(u.accounts, githubUsername)
+	delete(u.accountPreferences, githubUsername)
+	delete(s.states, stateKey{chatID, models.AccountKindGitHub, githubUsername})
+	delete(s.states, stateKey{chatID, models.AccountKindSecurity, githubUsername})
+	s.removeUserIfEmpty(u)
+	return nil
+}
+
+func (s *Store) GetAccountPreferences(ctx context.Context, chatID int64, githubUsername string) (models.AccountPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	preferences := models.AccountPreferences{ChatID: chatID, Username: githubUsername}
+	if u, ok := s.users[chatID]; ok && inTenant(ctx, u) {
+		if saved, ok := u.accountPreferences[githubUsername]; ok {
+			preferences = saved
+			preferences.DisabledReasons = slices.Clone(saved.DisabledReasons)
+		}
+	}
+	return preferences, nil
+}
+
+func (s *Store) SetAccountPreferences(ctx context.Context, preferences models.AccountPreferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[preferences.ChatID]
+	if !ok || !inTenant(ctx, u) {
+		return store.ErrAccountNotFound
+	}
+	if _, ok := u.accounts[preferences.Username]; !ok {
+		return store.ErrAccountNotFound
+	}
+
+	if u.accountPreferences == nil {
+		u.accountPreferences = make(map[string]models.AccountPreferences)
+	}
+	preferences.DisabledReasons = slices.Clone(preferences.DisabledReasons)
+	u.accountPreferences[preferences.Username] = preferences
+	return nil
+}
+
+func (s *Store) ToggleGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || !inTenant(ctx, u) {
+		return store.ErrAccountNotFound
+	}
+	account, ok := u.accounts[githubUsername]
+	if !ok {
+		return store.ErrAccountNotFound
+	}
+
+	account.IsActive = !account.IsActive
+	u.accounts[githubUsername] = account
+	return nil
+}
+
+func (s *Store) AddGerritAccount(ctx context.Context, chatID int64, account models.GerritAccount) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.ensureUser(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	account.IsActive = true
+	for i, existing := range u.gerrit {
+		if existing.BaseURL == account.BaseURL && existing.Username == account.Username {
+			u.gerrit[i] = account
+			return nil
+		}
+	}
+	u.gerrit = append(u.gerrit, account)
+	return nil
+}
+
+func (s *Store) RemoveGerritAccount(ctx context.Context, chatID int64, baseURL, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, chatID)
+	if err != nil {
+		return store.ErrAccountNotFound
+	}
+
+	for i, account := range u.gerrit {
+		if account.BaseURL == baseURL && account.Username == username {
+			u.gerrit = slices.Delete(u.gerrit, i, i+1)
+			delete(s.states, stateKey{chatID, models.AccountKindGerrit, account.Key()})
+			s.removeUserIfEmpty(u)
+			return nil
+		}
+	}
+	return store.ErrAccountNotFound
+}
+
+func (s *Store) GetUser(ctx context.Context, chatID int64) (*models.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || !s.listed(ctx, u) {
+		return nil, false
+	}
+	return u.model(), true
+}
+
+func (s *Store) ListUsers(ctx context.Context, afterChatID int64, limit int) ([]*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chatIDs []int64
+	for chatID, u := range s.users {
+		if chatID > afterChatID && s.listed(ctx, u) {
+			chatIDs = append(chatIDs, chatID)
+		}
+	}
+	slices.Sort(chatIDs)
+
+	var users []*models.User
+	for _, chatID := range chatIDs[:min(limit, len(chatIDs))] {
+		users = append(users, s.users[chatID].model())
+	}
+	return users, nil
+}
+
+// inTenant reports whether u belongs to the context's tenant. Unscoped
+// contexts see every user.
+func inTenant(ctx context.Context, u *user) bool {
+	tenantID, scoped := store.TenantFromContext(ctx)
+	return !scoped || tenantID == "" || u.tenantID == tenantID
+}
+
+// chatInTenant reports whether the chat's rows may be read or changed from
+// ctx: the chat must belong to the context's tenant, unless ctx is
+// unscoped.
+func (s *Store) chatInTenant(ctx context.Context, chatID int64) bool {
+	if tenantID, scoped := store.TenantFromContext(ctx); !scoped || tenantID == "" {
+		return true
+	}
+	u, ok := s.users[chatID]
+	return ok && inTenant(ctx, u)
+}
+
+// listed reports whether the user shows up in user lookups: it must have
+// an account and belong to the context's tenant.
+func (s *Store) listed(ctx context.Context, u *user) bool {
+	if len(u.accounts)+len(u.gerrit) == 0 {
+		return false
+	}
+	tenantID, scoped := store.TenantFromContext(ctx)
+	return !scoped || u.tenantID == tenantID
+}
+
+func (u *user) model() *models.User {
+	user := &models.User{
+		ChatID:     u.chatID,
+		TenantID:   u.tenantID,
+		Accounts:   make(map[string]*models.GitHubAccount),
+		MutedRepos: maps.Clone(u.muted),
+	}
+	for username, account := range u.accounts {
+		account := account
+		account.Scopes = slices.Clone(account.Scopes)
+		user.Accounts[username] = &account
+	}
+	for _, account := range u.gerrit {
+		account := account
+		user.GerritAccounts = append(user.GerritAccounts, &account)
+	}
+	return user
+}
+
+func (s *Store) AddImageSubscription(ctx context.Context, chatID int64, image, tagFilter string, seenTags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	// Re-subscribing resets the seen tags, like the Postgres store.
+	for id, existing := range s.images {
+		if existing.subscription.ChatID == chatID && existing.subscription.Image == image {
+			delete(s.images, id)
+		}
+	}
+
+	id := s.newID()
+	subscription := &imageSubscription{
+		subscription: models.ImageSubscription{ID: id, ChatID: chatID, Image: image, TagFilter: tagFilter},
+		seen:         make(map[string]bool),
+	}
+	for _, tag := range seenTags {
+		subscription.seen[tag] = true
+	}
+	s.images[id] = subscription
+	return nil
+}
+
+func (s *Store) RemoveImageSubscription(ctx context.Context, chatID int64, image string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return fmt.Errorf("subscription not found")
+	}
+
+	for id, existing := range s.images {
+		if existing.subscription.ChatID == chatID && existing.subscription.Image == image {
+			delete(s.images, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription not found")
+}
+
+func (s *Store) GetImageSubscriptions(ctx context.Context, chatID int64) ([]models.ImageSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []models.ImageSubscription
+	for _, existing := range s.images {
+		if existing.subscription.ChatID == chatID {
+			subscriptions = append(subscriptions, existing.subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Image < subscriptions[j].Image })
+	return subscriptions, nil
+}
+
+func (s *Store) GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscription, ok := s.images[subscriptionID]; ok {
+		return maps.Clone(subscription.seen), nil
+	}
+	return make(map[string]bool), nil
+}
+
+func (s *Store) MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.images[subscriptionID]
+	if !ok {
+		if len(tags) == 0 {
+			return nil
+		}
+		return fmt.Errorf("failed to record seen tag: subscription %d not found", subscriptionID)
+	}
+	for _, tag := range tags {
+		subscription.seen[tag] = true
+	}
+	return nil
+}
+
+func (s *Store) AddDependencySubscription(ctx context.Context, chatID int64, repo string, seenVersions []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	var subscription *dependencySubscription
+	for _, existing := range s.dependencies {
+		if existing.subscription.ChatID == chatID && existing.subscription.Repo == repo {
+			subscription = existing
+		}
+	}
+	if subscription == nil {
+		id := s.newID()
+		subscription = &dependencySubscription{
+			subscription: models.DependencySubscription{ID: id, ChatID: chatID, Repo: repo},
+			seen:         make(map[string]bool),
+		}
+		s.dependencies[id] = subscription
+	}
+
+	subscription.subscription.LastCheckedAt = time.Now()
+	for _, key := range seenVersions {
+		subscription.seen[key] = true
+	}
+	return nil
+}
+
+func (s *Store) RemoveDependencySubscription(ctx context.Context, chatID int64, repo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return fmt.Errorf("subscription not found")
+	}
+
+	for id, existing := range s.dependencies {
+		if existing.subscription.ChatID == chatID && existing.subscription.Repo == repo {
+			delete(s.dependencies, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription not found")
+}
+
+func (s *Store) GetDependencySubscriptions(ctx context.Context, chatID int64) ([]models.DependencySubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []models.DependencySubscription
+	for _, existing := range s.dependencies {
+		if existing.subscription.ChatID == chatID {
+			subscriptions = append(subscriptions, existing.subscription)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Repo < subscriptions[j].Repo })
+	return subscriptions, nil
+}
+
+func (s *Store) GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if subscription, ok := s.dependencies[subscriptionID]; ok {
+		return maps.Clone(subscription.seen), nil
+	}
+	return make(map[string]bool), nil
+}
+
+func (s *Store) MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subscription, ok := s.dependencies[subscriptionID]
+	if !ok {
+		if len(keys) == 0 {
+			return nil
+		}
+		return fmt.Errorf("failed to record seen version: subscription %d not found", subscriptionID)
+	}
+	for _, key := range keys {
+		subscription.seen[key] = true
+	}
+	subscription.subscription.LastCheckedAt = time.Now()
+	return nil
+}
+
+func (s *Store) GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if state, ok := s.states[stateKey{chatID, kind, account}]; ok {
+		return state, nil
+	}
+	return models.AccountState{ChatID: chatID, Kind: kind, Account: account}, nil
+}
+
+func (s *Store) SaveAccountState(ctx context.Context, state models.AccountState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[state.ChatID]; !ok {
+		return store.ErrUserNotFound
+	}
+	s.states[stateKey{state.ChatID, state.Kind, state.Account}] = state
+	return s.persist()
+}
+
+func (s *Store) GetPreferences(ctx context.Context, chatID int64) (models.Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || u.preferences == nil {
+		return models.DefaultPreferences(chatID), nil
+	}
+
+	preferences := *u.preferences
+	preferences.Priorities = maps.Clone(u.preferences.Priorities)
+	preferences.RenotifyIntervals = maps.Clone(u.preferences.RenotifyIntervals)
+	preferences.TeamRepos = slices.Clone(u.preferences.TeamRepos)
+	preferences.Triage.Repos = slices.Clone(u.preferences.Triage.Repos)
+	preferences.Triage.Labels = slices.Clone(u.preferences.Triage.Labels)
+	preferences.Changelog.Repos = slices.Clone(u.preferences.Changelog.Repos)
+	preferences.Usage.Orgs = slices.Clone(u.preferences.Usage.Orgs)
+	preferences.CommitMentionRepos = slices.Clone(u.preferences.CommitMentionRepos)
+	return preferences, nil
+}
+
+func (s *Store) SetPreferences(ctx context.Context, preferences models.Preferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, preferences.ChatID)
+	if err != nil {
+		return err
+	}
+
+	preferences.Priorities = maps.Clone(preferences.Priorities)
+	if preferences.Priorities == nil {
+		preferences.Priorities = map[string]string{}
+	}
+	preferences.RenotifyIntervals = maps.Clone(preferences.RenotifyIntervals)
+	if preferences.RenotifyIntervals == nil {
+		preferences.RenotifyIntervals = map[string]int{}
+	}
+	preferences.TeamRepos = slices.Clone(preferences.TeamRepos)
+	if preferences.TeamRepos == nil {
+		preferences.TeamRepos = []string{}
+	}
+	preferences.Triage.Repos = slices.Clone(preferences.Triage.Repos)
+	preferences.Triage.Labels = slices.Clone(preferences.Triage.Labels)
+	preferences.Changelog.Repos = slices.Clone(preferences.Changelog.Repos)
+	preferences.Usage.Orgs = slices.Clone(preferences.Usage.Orgs)
+	preferences.CommitMentionRepos = slices.Clone(preferences.CommitMentionRepos)
+	if preferences.CommitMentionRepos == nil {
+		preferences.CommitMentionRepos = []string{}
+	}
+	u.preferences = &preferences
+	return nil
+}
+
+func (s *Store) AddRepoSubscription(ctx context.Context, subscription models.RepoSubscription) (int64, error) {
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	subscription.EventTypes = slices.Clone(subscription.EventTypes)
+	if subscription.EventTypes == nil {
+		subscription.EventTypes = []string{}
+	}
+	subscription.Filters = models.RepoFilters{
+		Labels:   slices.Clone(subscription.Filters.Labels),
+		Authors:  slices.Clone(subscription.Filters.Authors),
+		Branches: slices.Clone(subscription.Filters.Branches),
+	}
+
+	for id, existing := range s.repos {
+		if existing.ChatID == subscription.ChatID && existing.Repo == subscription.Repo {
+			subscription.ID = id
+			subscription.CreatedAt = existing.CreatedAt
+			s.repos[id] = subscription
+			return id, nil
+		}
+	}
+
+	subscription.ID = s.newID()
+	subscription.CreatedAt = time.Now()
+	s.repos[subscription.ID] = subscription
+	return subscription.ID, nil
+}
+
+func (s *Store) RemoveRepoSubscription(ctx context.Context, chatID int64, repo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return fmt.Errorf("subscription not found")
+	}
+
+	for id, existing := range s.repos {
+		if existing.ChatID == chatID && existing.Repo == repo {
+			delete(s.repos, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription not found")
+}
+
+func (s *Store) GetRepoSubscriptions(ctx context.Context, chatID int64) ([]models.RepoSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []models.RepoSubscription
+	for _, existing := range s.repos {
+		if existing.ChatID == chatID {
+			existing.EventTypes = slices.Clone(existing.EventTypes)
+			subscriptions = append(subscriptions, existing)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool { return subscriptions[i].Repo < subscriptions[j].Repo })
+	return subscriptions, nil
+}
+
+func (s *Store) AddPathSubscription(ctx context.Context, subscription models.PathSubscription) (int64, error) {
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	for id, existing := range s.paths {
+		if existing.ChatID == subscription.ChatID && existing.Repo == subscription.Repo && existing.Pattern == subscription.Pattern {
+			return id, nil
+		}
+	}
+
+	subscription.ID = s.newID()
+	subscription.CreatedAt = time.Now()
+	s.paths[subscription.ID] = subscription
+	return subscription.ID, nil
+}
+
+func (s *Store) RemovePathSubscription(ctx context.Context, chatID int64, repo, pattern string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return fmt.Errorf("subscription not found")
+	}
+
+	for id, existing := range s.paths {
+		if existing.ChatID == chatID && existing.Repo == repo && existing.Pattern == pattern {
+			delete(s.paths, id)
+			return nil
+		}
+	}
+	return fmt.Errorf("subscription not found")
+}
+
+func (s *Store) GetPathSubscriptions(ctx context.Context, chatID int64) ([]models.PathSubscription, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var subscriptions []models.PathSubscription
+	for _, existing := range s.paths {
+		if existing.ChatID == chatID {
+			subscriptions = append(subscriptions, existing)
+		}
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		a, b := subscriptions[i], subscriptions[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		return a.Pattern < b.Pattern
+	})
+	return subscriptions, nil
+}
+
+func (s *Store) AddRoutingRule(ctx context.Context, rule models.RoutingRule) (int64, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, rule.ChatID); err != nil {
+		return 0, err
+	}
+
+	rule.ID = s.newID()
+	rule.CreatedAt = time.Now()
+	s.rules = append(s.rules, rule)
+	return rule.ID, nil
+}
+
+func (s *Store) RemoveRoutingRule(ctx context.Context, chatID, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return store.ErrRuleNotFound
+	}
+
+	for i, rule := range s.rules {
+		if rule.ChatID == chatID && rule.ID == id {
+			s.rules = slices.Delete(s.rules, i, i+1)
+			return nil
+		}
+	}
+	return store.ErrRuleNotFound
+}
+
+func (s *Store) GetRoutingRules(ctx context.Context, chatID int64) ([]models.RoutingRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.chatInTenant(ctx, chatID) {
+		return nil, nil
+	}
+
+	var rules []models.RoutingRule
+	for _, rule := range s.rules {
+		if rule.ChatID == chatID {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+func (s *Store) RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, request.ChatID); err != nil {
+		return err
+	}
+
+	for _, review := range s.reviews {
+		if review.ChatID == request.ChatID && review.Account == request.Account && review.URL == request.URL && review.Pending() {
+			return nil
+		}
+	}
+	request.ID = s.newID()
+	request.ReviewedAt = time.Time{}
+	s.reviews = append(s.reviews, request)
+	return nil
+}
+
+func (s *Store) GetReviewRequests(ctx context.Context, chatID int64, since time.Time) ([]models.ReviewRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var reviews []models.ReviewRequest
+	for _, review := range s.reviews {
+		if review.ChatID == chatID && (review.Pending() || !review.RequestedAt.Before(since)) {
+			reviews = append(reviews, review)
+		}
+	}
+	sort.SliceStable(reviews, func(i, j int) bool { return reviews[i].RequestedAt.Before(reviews[j].RequestedAt) })
+	return reviews, nil
+}
+
+func (s *Store) CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.reviews {
+		if s.reviews[i].ID == id {
+			s.reviews[i].ReviewedAt = reviewedAt
+		}
+	}
+	return nil
+}
+
+func (s *Store) RemoveReviewRequest(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reviews = slices.DeleteFunc(s.reviews, func(review models.ReviewRequest) bool { return review.ID == id })
+	return nil
+}
+
+func (s *Store) RecordMention(ctx context.Context, mention models.Mention) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.activeUser(ctx, mention.ChatID); err != nil {
+		return err
+	}
+
+	for _, existing := range s.mentions {
+		if existing.ChatID == mention.ChatID && existing.Account == mention.Account && existing.URL == mention.URL {
+			return nil
+		}
+	}
+	mention.ID = s.newID()
+	s.mentions = append(s.mentions, mention)
+	return nil
+}
+
+func (s *Store) GetMentions(ctx context.Context, chatID int64) ([]models.Mention, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var mentions []models.Mention
+	for _, mention := range s.mentions {
+		if mention.ChatID == chatID {
+			mentions = append(mentions, mention)
+		}
+	}
+	sort.SliceStable(mentions, func(i, j int) bool { return mentions[i].MentionedAt.Before(mentions[j].MentionedAt) })
+	return mentions, nil
+}
+
+func (s *Store) RemoveMention(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.mentions = slices.DeleteFunc(s.mentions, func(mention models.Mention) bool { return mention.ID == id })
+	return nil
+}
+
+func (s *Store) MuteRepo(ctx context.Context, chatID int64, repo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	u.muted[repo] = true
+	return nil
+}
+
+func (s *Store) UnmuteRepo(ctx context.Context, chatID int64, repo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[chatID]; ok && inTenant(ctx, u) {
+		delete(u.muted, repo)
+	}
+	return nil
+}
+
+// lastNotified returns when the item was last recorded, whatever its
+// type.
+func (s *Store) lastNotified(chatID int64, itemURL, contentHash string) (time.Time, bool) {
+	var last time.Time
+	found := false
+	for _, record := range s.notifications {
+		if record.ChatID == chatID && record.ItemURL == itemURL && record.ContentHash == contentHash &&
+			(!found || record.CreatedAt.After(last)) {
+			last, found = record.CreatedAt, true
+		}
+	}
+	return last, found
+}
+
+func (s *Store) record(chatID int64, itemURL, notificationType, contentHash, eventID string) {
+	s.notifications = append(s.notifications, models.NotificationRecord{
+		ID:               s.newID(),
+		ChatID:           chatID,
+		ItemURL:          itemURL,
+		NotificationType: notificationType,
+		ContentHash:      contentHash,
+		CreatedAt:        time.Now(),
+		EventID:          eventID,
+	})
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	last, found := s.lastNotified(chatID, itemURL, contentHash)
+	if found && renotifyInterval < 0 {
+		return false, nil
+	}
+	return !found || time.Since(last) > renotifyInterval, nil
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[chatID]; !ok {
+		return store.ErrUserNotFound
+	}
+	s.record(chatID, itemURL, notificationType, contentHash, "")
+	return s.persist()
+}
+
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[chatID]; !ok {
+		return false, store.ErrUserNotFound
+	}
+	last, found := s.lastNotified(chatID, notification.URL, contentHash)
+	if found && (renotifyInterval < 0 || time.Since(last) <= renotifyInterval) {
+		return false, nil
+	}
+
+	now := time.Now()
+	s.outbox = append(s.outbox, &outboxEntry{
+		entry: models.OutboxEntry{
+			ID:           s.newID(),
+			ChatID:       chatID,
+			Notification: models.Notification{Type: notification.Type, Repo: notification.Repo, Message: notification.Message, URL: notification.URL, Fields: notification.Fields, OccurredAt: notification.OccurredAt, EventID: notification.EventID, ThreadID: notification.ThreadID, Account: notification.Account, Severity: notification.Severity, Labels: slices.Clone(notification.Labels)},
+			CreatedAt:    now,
+		},
+		nextAttemptAt: now,
+	})
+	s.record(chatID, notification.URL, notification.Type, contentHash, notification.EventID)
+	if err := s.persist(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Store) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var entries []models.OutboxEntry
+	for _, pending := range s.outbox {
+		if len(entries) >= limit {
+			break
+		}
+		u, ok := s.users[pending.entry.ChatID]
+		if !ok || !pending.sentAt.IsZero() || !pending.deadAt.IsZero() || pending.nextAttemptAt.After(now) {
+			continue
+		}
+
+		pending.entry.Attempts++
+		pending.entry.TenantID = u.tenantID
+		pending.nextAttemptAt = now.Add(lease)
+		entries = append(entries, pending.entry)
+	}
+	return entries, nil
+}
+
+func (s *Store) outboxEntry(id int64) *outboxEntry {
+	for _, pending := range s.outbox {
+		if pending.entry.ID == id {
+			return pending
+		}
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxSent(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending := s.outboxEntry(id); pending != nil {
+		pending.sentAt = time.Now()
+		pending.lastError = ""
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxRetry(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending := s.outboxEntry(id); pending != nil {
+		pending.lastError = lastError
+		pending.nextAttemptAt = nextAttempt
+	}
+	return nil
+}
+
+func (s *Store) DeferOutbox(ctx context.Context, id int64, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending := s.outboxEntry(id); pending != nil {
+		pending.entry.Attempts = max(pending.entry.Attempts-1, 0)
+		pending.nextAttemptAt = until
+	}
+	return nil
+}
+
+func (s *Store) CountPendingOutbox(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for _, pending := range s.outbox {
+		if _, ok := s.users[pending.entry.ChatID]; ok && pending.sentAt.IsZero() && pending.deadAt.IsZero() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *Store) MarkOutboxDead(ctx context.Context, id int64, lastError string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if pending := s.outboxEntry(id); pending != nil {
+		pending.lastError = lastError
+		pending.deadAt = time.Now()
+	}
+	return nil
+}
+
+func (s *Store) GetOutbox(ctx context.Context, query store.OutboxQuery) ([]models.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch query.State {
+	case "", models.OutboxPending, models.OutboxSent, models.OutboxDead:
+	default:
+		return nil, fmt.Errorf("invalid outbox state %q", query.State)
+	}
+
+	var entries []models.OutboxEntry
+	for i := len(s.outbox) - 1; i >= 0; i-- {
+		pending := s.outbox[i]
+		u, ok := s.users[pending.entry.ChatID]
+		if !ok || !inTenant(ctx, u) {
+			continue
+		}
+		if query.ChatID != 0 && pending.entry.ChatID != query.ChatID {
+			continue
+		}
+
+		entry := pending.entry
+		entry.TenantID = u.tenantID
+		entry.LastError = pending.lastError
+		entry.NextAttemptAt = pending.nextAttemptAt
+		entry.SentAt = pending.sentAt
+		entry.DeadAt = pending.deadAt
+		if query.State != "" && entry.State() != query.State {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if query.Offset >= len(entries) {
+		return nil, nil
+	}
+	entries = entries[query.Offset:]
+	if len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+	}
+	return entries, nil
+}
+
+func (s *Store) GetOutboxEntry(ctx context.Context, id int64) (models.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.outboxEntry(id)
+	if pending == nil {
+		return models.OutboxEntry{}, store.ErrOutboxNotFound
+	}
+	u, ok := s.users[pending.entry.ChatID]
+	if !ok || !inTenant(ctx, u) {
+		return models.OutboxEntry{}, store.ErrOutboxNotFound
+	}
+
+	entry := pending.entry
+	entry.TenantID = u.tenantID
+	entry.LastError = pending.lastError
+	entry.NextAttemptAt = pending.nextAttemptAt
+	entry.SentAt = pending.sentAt
+	entry.DeadAt = pending.deadAt
+	entry.Notification.Labels = slices.Clone(entry.Notification.Labels)
+	return entry, nil
+}
+
+func (s *Store) RequeueOutbox(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.outboxEntry(id)
+	if pending == nil || pending.deadAt.IsZero() {
+		return store.ErrOutboxNotFound
+	}
+	if u, ok := s.users[pending.entry.ChatID]; !ok || !inTenant(ctx, u) {
+		return store.ErrOutboxNotFound
+	}
+
+	pending.entry.Attempts = 0
+	pending.deadAt = time.Time{}
+	pending.nextAttemptAt = time.Now()
+	return nil
+}
+
+func (s *Store) CleanOldNotifications(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.notifications = slices.DeleteFunc(s.notifications, func(record models.NotificationRecord) bool {
+		if !record.CreatedAt.Before(before) {
+			return false
+		}
+		if u, ok := s.users[record.ChatID]; ok && u.preferences != nil {
+			if hours, ok := u.preferences.RenotifyIntervals[record.NotificationType]; ok {
+				return hours >= 0 && now.Sub(record.CreatedAt) >= time.Duration(hours)*time.Hour
+			}
+		}
+		return true
+	})
+	s.outbox = slices.DeleteFunc(s.outbox, func(pending *outboxEntry) bool {
+		finished := !pending.sentAt.IsZero() || !pending.deadAt.IsZero()
+		return finished && pending.entry.CreatedAt.Before(before)
+	})
+	s.reviews = slices.DeleteFunc(s.reviews, func(review models.ReviewRequest) bool {
+		return !review.Pending() && review.RequestedAt.Before(before)
+	})
+	return s.persist()
+}
+
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var purged int64
+	for chatID, u := range s.users {
+		if u.deletedAt.IsZero() || !u.deletedAt.Before(before) {
+			continue
+		}
+
+		delete(s.users, chatID)
+		maps.DeleteFunc(s.states, func(key stateKey, _ models.AccountState) bool { return key.chatID == chatID })
+		maps.DeleteFunc(s.images, func(_ int64, sub *imageSubscription) bool { return sub.subscription.ChatID == chatID })
+		maps.DeleteFunc(s.dependencies, func(_ int64, sub *dependencySubscription) bool { return sub.subscription.ChatID == chatID })
+		maps.DeleteFunc(s.repos, func(_ int64, sub models.RepoSubscription) bool { return sub.ChatID == chatID })
+		maps.DeleteFunc(s.paths, func(_ int64, sub models.PathSubscription) bool { return sub.ChatID == chatID })
+		s.rules = slices.DeleteFunc(s.rules, func(rule models.RoutingRule) bool { return rule.ChatID == chatID })
+		s.reviews = slices.DeleteFunc(s.reviews, func(review models.ReviewRequest) bool { return review.ChatID == chatID })
+		s.mentions = slices.DeleteFunc(s.mentions, func(mention models.Mention) bool { return mention.ChatID == chatID })
+		s.notifications = slices.DeleteFunc(s.notifications, func(record models.NotificationRecord) bool { return record.ChatID == chatID })
+		s.outbox = slices.DeleteFunc(s.outbox, func(pending *outboxEntry) bool { return pending.entry.ChatID == chatID })
+		purged++
+	}
+	return purged, nil
+}
+
+func (s *Store) GetNotificationHistory(ctx context.Context, query store.HistoryQuery) ([]models.NotificationRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tenantID, scoped := store.TenantFromContext(ctx)
+	if u, ok := s.users[query.ChatID]; scoped && tenantID != "" && (!ok || u.tenantID != tenantID) {
+		return nil, nil
+	}
+
+	var records []models.NotificationRecord
+	for _, record := range s.notifications {
+		if record.ChatID != query.ChatID || record.CreatedAt.Before(query.Since) {
+			continue
+		}
+		if query.Type != "" && record.NotificationType != query.Type {
+			continue
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].CreatedAt.Equal(records[j].CreatedAt) {
+			return records[i].CreatedAt.After(records[j].CreatedAt)
+		}
+		return records[i].ID > records[j].ID
+	})
+
+	if query.Offset >= len(records) {
+		return nil, nil
+	}
+	records = records[query.Offset:]
+	if len(records) > query.Limit {
+		records = records[:query.Limit]
+	}
+	return records, nil
+}
+
+func (s *Store) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok {
+		return "", store.ErrUserNotFound
+	}
+	if u.calendarToken != "" {
+		return u.calendarToken, nil
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate calendar token: %v", err)
+	}
+	u.calendarToken = hex.EncodeToString(buf)
+	return u.calendarToken, nil
+}
+
+func (s *Store) GetUserByCalendarToken(ctx context.Context, token string) (*models.User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, u := range s.users {
+		if token != "" && u.calendarToken == token && s.listed(ctx, u) {
+			return u.model(), true
+		}
+	}
+	return nil, false
+}
+
+func (s *Store) SetJiraConfig(ctx context.Context, config models.JiraConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, config.ChatID)
+	if err != nil {
+		return err
+	}
+
+	u.jira = &config
+	return nil
+}
+
+func (s *Store) GetJiraConfig(ctx context.Context, chatID int64) (*models.JiraConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || u.jira == nil {
+		return nil, false
+	}
+	config := *u.jira
+	return &config, true
+}
+
+func (s *Store) RemoveJiraConfig(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[chatID]; ok && inTenant(ctx, u) {
+		u.jira = nil
+	}
+	return nil
+}
+
+func (s *Store) SetLinearConfig(ctx context.Context, chatID int64, apiKey string, target models.LinearTarget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, err := s.activeUser(ctx, chatID)
+	if err != nil {
+		return err
+	}
+
+	if u.linear == nil {
+		u.linear = &models.LinearConfig{ChatID: chatID, Mappings: make(map[string]models.LinearTarget)}
+	}
+	u.linear.APIKey = apiKey
+	u.linear.Default = target
+	return nil
+}
+
+func (s *Store) GetLinearConfig(ctx context.Context, chatID int64) (*models.LinearConfig, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || u.linear == nil {
+		return nil, false
+	}
+	config := *u.linear
+	config.Mappings = maps.Clone(u.linear.Mappings)
+	return &config, true
+}
+
+func (s *Store) RemoveLinearConfig(ctx context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[chatID]; ok && inTenant(ctx, u) {
+		u.linear = nil
+	}
+	return nil
+}
+
+func (s *Store) SetLinearMapping(ctx context.Context, chatID int64, repo string, target models.LinearTarget) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.users[chatID]
+	if !ok || !inTenant(ctx, u) || u.linear == nil {
+		return fmt.Errorf("Linear is not configured")
+	}
+	u.linear.Mappings[repo] = target
+	return nil
+}
+
+func (s *Store) RemoveLinearMapping(ctx context.Context, chatID int64, repo string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u, ok := s.users[chatID]; ok && inTenant(ctx, u) && u.linear != nil {
+		delete(u.linear.Mappings, repo)
+	}
+	return nil
+}
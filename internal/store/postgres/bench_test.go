@@ -0,0 +1,161 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// seedUsers inserts users with two GitHub accounts and a muted repository
+// each in bulk, which is much faster than adding them one by one.
+func seedUsers(b *testing.B, s *Store, users int) {
+	b.Helper()
+	ctx := context.Background()
+	for _, query := range []string{
+		"INSERT INTO users (chat_id) SELECT generate_series(1, $1)",
+		`INSERT INTO github_accounts (chat_id, username, token)
+			SELECT chat_id, login, 'token-' || login
+			FROM generate_series(1, $1) AS chat_id, unnest(ARRAY['alice', 'bob']) AS login`,
+		"INSERT INTO muted_repos (chat_id, repo) SELECT generate_series(1, $1), 'octo/repo'",
+	} {
+		if _, err := s.db.ExecContext(ctx, query, users); err != nil {
+			b.Fatalf("failed to seed users: %v", err)
+		}
+	}
+}
+
+// BenchmarkListUsers loads 10k users a page at a time, the way every poll
+// cycle does.
+func BenchmarkListUsers(b *testing.B) {
+	s := newTestStore(b)
+	defer s.Close()
+	seedUsers(b, s, 10000)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		loaded := 0
+		err := store.ForEachUser(ctx, s, func(user *models.User) error {
+			loaded++
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if loaded != 10000 {
+			b.Fatalf("loaded %d users, want 10000", loaded)
+		}
+	}
+}
+
+// benchmarkStatements runs bench against a store that prepares its hot
+// queries and against one connecting with default_query_exec_mode=exec,
+// which sends every query unprepared.
+func benchmarkStatements(b *testing.B, bench func(b *testing.B, s *Store)) {
+	dbURL := testDatabaseURL(b)
+	unprepared, err := url.Parse(dbURL)
+	if err != nil {
+		b.Fatalf("failed to parse TEST_DATABASE_URL: %v", err)
+	}
+	query := unprepared.Query()
+	query.Set("default_query_exec_mode", "exec")
+	unprepared.RawQuery = query.Encode()
+
+	for _, mode := range []struct {
+		name string
+		url  string
+	}{
+		{"prepared", dbURL},
+		{"unprepared", unprepared.String()},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			s := openTestStore(b, mode.url)
+			defer s.Close()
+			bench(b, s)
+		})
+	}
+}
+
+// BenchmarkShouldNotify looks up notifications already sent, in a history
+// of 100k records over 1000 users.
+func BenchmarkShouldNotify(b *testing.B) {
+	benchmarkStatements(b, func(b *testing.B, s *Store) {
+		seedUsers(b, s, 1000)
+		query := `
+			INSERT INTO sent_notifications (chat_id, item_url, notification_type, content_hash)
+			SELECT chat_id, 'https://github.com/octo/repo/issues/' || issue, 'mention', 'hash'
+			FROM generate_series(1, 1000) AS chat_id, generate_series(1, 100) AS issue
+		`
+		if _, err := s.db.ExecContext(context.Background(), query); err != nil {
+			b.Fatalf("failed to seed notifications: %v", err)
+		}
+		ctx := context.Background()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			itemURL := fmt.Sprintf("https://github.com/octo/repo/issues/%d", i%100+1)
+			notify, err := s.ShouldNotify(ctx, int64(i%1000+1), itemURL, "hash", time.Hour)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if notify {
+				b.Fatal("ShouldNotify = true for a notification just sent")
+			}
+		}
+	})
+}
+
+// BenchmarkEnqueueNotification queues a new notification for one of 1000
+// users each iteration, through the lock, the dedup check and both inserts.
+func BenchmarkEnqueueNotification(b *testing.B) {
+	benchmarkStatements(b, func(b *testing.B, s *Store) {
+		seedUsers(b, s, 1000)
+		ctx := context.Background()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			notification := models.Notification{Type: "mention", Repo: "octo/repo", Message: "hello", URL: fmt.Sprintf("https://github.com/octo/repo/issues/%d", i), Account: "alice"}
+			queued, err := s.EnqueueNotification(ctx, int64(i%1000+1), notification, notification.ContentHash(), time.Hour)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if !queued {
+				b.Fatal("EnqueueNotification did not queue a new notification")
+			}
+		}
+	})
+}
+
+// BenchmarkClaimOutbox claims batches of 100 from 10k pending entries.
+// The lease has already run out when it is taken, so the entries stay
+// claimable.
+func BenchmarkClaimOutbox(b *testing.B) {
+	benchmarkStatements(b, func(b *testing.B, s *Store) {
+		seedUsers(b, s, 1000)
+		query := `
+			INSERT INTO notification_outbox (chat_id, notification_type, repo, message, item_url)
+			SELECT i % 1000 + 1, 'mention', 'octo/repo', 'hello', 'https://github.com/octo/repo/issues/' || i
+			FROM generate_series(1, 10000) AS i
+		`
+		if _, err := s.db.ExecContext(context.Background(), query); err != nil {
+			b.Fatalf("failed to seed the outbox: %v", err)
+		}
+		ctx := context.Background()
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			entries, err := s.ClaimOutbox(ctx, 100, -time.Minute)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(entries) != 100 {
+				b.Fatalf("ClaimOutbox claimed %d entries, want 100", len(entries))
+			}
+		}
+	})
+}
@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	connectAttempts   = 5
+	reconnectDelayMin = time.Second
+	reconnectDelayMax = 30 * time.Second
+	pingTimeout       = 5 * time.Second
+)
+
+// Health is the database connectivity as last seen by Monitor.
+type Health struct {
+	Degraded bool
+	Since    time.Time
+	Error    string
+}
+
+// Health reports whether the database was reachable on the last check.
+func (s *Store) Health() Health {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+	return s.health
+}
+
+// Monitor pings the database every interval. When a ping fails the store
+// is marked degraded and pinged again with exponential backoff until it
+// answers, at which point idle connections are reset so the pool does not
+// hand out connections that died with the old server.
+func (s *Store) Monitor(ctx context.Context, interval time.Duration) {
+	delay := reconnectDelayMin
+	for {
+		wait := interval
+		if err := s.ping(ctx); err == nil {
+			s.setHealthy()
+			delay = reconnectDelayMin
+		} else if ctx.Err() == nil {
+			s.setDegraded(err)
+			wait = delay
+			delay = min(delay*2, reconnectDelayMax)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (s *Store) ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := s.pool.Ping(ctx); err != nil {
+		return err
+	}
+	if s.readerPool != nil {
+		if err := s.readerPool.Ping(ctx); err != nil {
+			return fmt.Errorf("read replica: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) setDegraded(err error) {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if !s.health.Degraded {
+		log.Printf("Database connection lost, retrying: %v", err)
+		s.health.Degraded = true
+		s.health.Since = time.Now()
+	}
+	s.health.Error = err.Error()
+}
+
+func (s *Store) setHealthy() {
+	s.healthMu.Lock()
+	defer s.healthMu.Unlock()
+
+	if !s.health.Degraded {
+		return
+	}
+	log.Printf("Database connection restored after %v", time.Since(s.health.Since).Round(time.Second))
+	s.health = Health{}
+
+	s.pool.Reset()
+	if s.readerPool != nil {
+		s.readerPool.Reset()
+	}
+}
+
+// connect pings a new pool, retrying with backoff so the monitor can start
+// while the database is still coming up.
+func connect(ctx context.Context, pool *pgxpool.Pool) error {
+	delay := reconnectDelayMin
+	var err error
+	for attempt := 1; attempt <= connectAttempts; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err = pool.Ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt == connectAttempts {
+			break
+		}
+
+		log.Printf("Database not reachable (attempt %d/%d), retrying in %v: %v", attempt, connectAttempts, delay, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, reconnectDelayMax)
+	}
+	return err
+}
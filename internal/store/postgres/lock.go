@@ -0,0 +1,119 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+var _ store.Locker = (*Store)(nil)
+
+// lockNamespace is the first key of every advisory lock taken by the
+// monitor, so its locks cannot collide with other users of the database.
+const lockNamespace = 0x524d // "RM"
+
+// Lock is a session-level advisory lock. All locks of a store share one
+// dedicated connection and are held for as long as it lives, so a crashed
+// instance loses its locks as soon as the server notices the connection
+// is gone.
+type Lock struct {
+	conn *sql.Conn
+	id   int32
+}
+
+// TryLock takes the advisory lock with the given ID without waiting. It
+// returns nil when another session holds the lock.
+func (s *Store) TryLock(ctx context.Context, id int32) (store.Lock, error) {
+	conn, err := s.lockConnection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", lockNamespace, id).Scan(&acquired); err != nil {
+		return nil, fmt.Errorf("failed to take advisory lock: %v", err)
+	}
+	if !acquired {
+		return nil, nil
+	}
+
+	return &Lock{conn: conn, id: id}, nil
+}
+
+// lockConnection returns the connection that holds the advisory locks,
+// replacing it when it broke. Locks taken on a broken connection are gone
+// on the server, and their Check fails once the connection is closed.
+func (s *Store) lockConnection(ctx context.Context) (*sql.Conn, error) {
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+
+	if s.lockConn != nil {
+		if err := s.lockConn.PingContext(ctx); err == nil {
+			return s.lockConn, nil
+		}
+		s.lockConn.Close()
+		s.lockConn = nil
+	}
+
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get lock connection: %v", err)
+	}
+	s.lockConn = conn
+	return conn, nil
+}
+
+func (s *Store) closeLockConnection() {
+	s.lockMu.Lock()
+	defer s.lockMu.Unlock()
+
+	if s.lockConn != nil {
+		s.lockConn.Close()
+		s.lockConn = nil
+	}
+}
+
+// Check verifies that the lock is still held. It fails when the
+// connection holding the lock broke.
+func (l *Lock) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if err := l.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("lost advisory lock %d: %v", l.id, err)
+	}
+	return nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+
+	if _, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1, $2)", lockNamespace, l.id); err != nil {
+		return fmt.Errorf("failed to release advisory lock: %v", err)
+	}
+	return nil
+}
+
+// LockHeld reports whether any session holds the advisory lock with the
+// given ID.
+func (s *Store) LockHeld(ctx context.Context, id int32) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Locks taken with two int4 keys are listed with the keys in classid
+	// and objid, and objsubid 2
+	var held bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND classid::bigint = $1 AND objid::bigint = $2 AND objsubid = 2 AND granted
+		)`, lockNamespace, id).Scan(&held)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up advisory lock: %v", err)
+	}
+	return held, nil
+}
@@ -0,0 +1,215 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationLock serializes migrations of concurrently starting instances.
+// The locks of the monitor's jobs start at 1.
+const migrationLock int32 = 0
+
+// Migration is one versioned change of the schema. Expand migrations only
+// add to the schema, so instances still running the previous version keep
+// working while they are applied. Contract migrations remove what only
+// previous versions use, and are applied with 'monitor migrate --contract'
+// once every instance runs a version that knows about them.
+type Migration struct {
+	Version    int
+	Name       string
+	Contract   bool
+	Statements []string
+}
+
+func expand(version int, name string, statements ...string) Migration {
+	return Migration{Version: version, Name: name, Statements: statements}
+}
+
+// addColumn adds a column in an expand migration. The definition must have
+// a default or allow NULL, so the previous version can keep inserting rows
+// without it.
+func addColumn(table, column, definition string) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", table, column, definition)
+}
+
+// SchemaStatus compares the migrations applied to the database with the
+// ones this version knows.
+type SchemaStatus struct {
+	// Version is the highest applied migration, 0 for an empty database.
+	Version int
+	// Pending are the migrations of this version that are not applied.
+	Pending []Migration
+	// Unknown are applied migrations this version does not know, written
+	// by a newer version. They have no statements.
+	Unknown []Migration
+}
+
+// Check returns an error when this version cannot serve on the schema:
+// when one of its expand migrations is missing, or when a newer version
+// already contracted the schema.
+func (st SchemaStatus) Check() error {
+	for _, migration := range st.Unknown {
+		if migration.Contract {
+			return fmt.Errorf("schema version %d is newer than this version: migration %d (%s) removed parts of the schema it uses", st.Version, migration.Version, migration.Name)
+		}
+	}
+	for _, migration := range st.Pending {
+		if !migration.Contract {
+			return fmt.Errorf("schema version %d is older than this version: migration %d (%s) is not applied, run 'monitor migrate'", st.Version, migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+// Migrator applies migrations without opening the store, which needs the
+// schema to match.
+type Migrator struct {
+	pool *pgxpool.Pool
+	db   *sql.DB
+}
+
+// OpenMigrator connects to the database for migrating it.
+func OpenMigrator(dbURL string, poolConfig PoolConfig) (*Migrator, error) {
+	pool, db, err := openDB(dbURL, poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, db: db}, nil
+}
+
+func (m *Migrator) Close() error {
+	err := m.db.Close()
+	m.pool.Close()
+	return err
+}
+
+// Status compares the database with this version's migrations without
+// changing anything.
+func (m *Migrator) Status(ctx context.Context) (SchemaStatus, error) {
+	return schemaStatus(ctx, m.db)
+}
+
+// Migrate applies the pending expand migrations, and with contract also
+// the pending contract migrations. It returns the applied migrations.
+func (m *Migrator) Migrate(ctx context.Context, contract bool) ([]Migration, error) {
+	return applyMigrations(ctx, m.db, contract)
+}
+
+func schemaStatus(ctx context.Context, db *sql.DB) (SchemaStatus, error) {
+	var status SchemaStatus
+
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL").Scan(&exists); err != nil {
+		return status, fmt.Errorf("failed to look up schema_migrations: %v", err)
+	}
+
+	applied := map[int]bool{}
+	if exists {
+		rows, err := db.QueryContext(ctx, "SELECT version, name, contract FROM schema_migrations ORDER BY version")
+		if err != nil {
+			return status, fmt.Errorf("failed to get applied migrations: %v", err)
+		}
+		defer rows.Close()
+
+		known := map[int]bool{}
+		for _, migration := range migrations {
+			known[migration.Version] = true
+		}
+		for rows.Next() {
+			var migration Migration
+			if err := rows.Scan(&migration.Version, &migration.Name, &migration.Contract); err != nil {
+				return status, fmt.Errorf("failed to scan applied migration: %v", err)
+			}
+			applied[migration.Version] = true
+			status.Version = max(status.Version, migration.Version)
+			if !known[migration.Version] {
+				status.Unknown = append(status.Unknown, migration)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return status, fmt.Errorf("failed to get applied migrations: %v", err)
+		}
+	}
+
+	for _, migration := range migrations {
+		if !applied[migration.Version] {
+			status.Pending = append(status.Pending, migration)
+		}
+	}
+	return status, nil
+}
+
+func applyMigrations(ctx context.Context, db *sql.DB, contract bool) ([]Migration, error) {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		contract BOOLEAN NOT NULL DEFAULT FALSE,
+		applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	pending := make([]Migration, len(migrations))
+	copy(pending, migrations)
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+
+	var applied []Migration
+	for _, migration := range pending {
+		if migration.Contract && !contract {
+			continue
+		}
+		ok, err := applyMigration(ctx, db, migration)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %v", migration.Version, migration.Name, err)
+		}
+		if ok {
+			applied = append(applied, migration)
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration applies the migration in a transaction, unless another
+// instance already did. It reports whether it applied the migration.
+func applyMigration(ctx context.Context, db *sql.DB, migration Migration) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1, $2)", lockNamespace, migrationLock); err != nil {
+		return false, fmt.Errorf("failed to take migration lock: %v", err)
+	}
+
+	var done bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)", migration.Version).Scan(&done); err != nil {
+		return false, fmt.Errorf("failed to check migration: %v", err)
+	}
+	if done {
+		return false, nil
+	}
+
+	for _, statement := range migration.Statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return false, fmt.Errorf("failed to execute query %q: %v", strings.Join(strings.Fields(statement), " "), err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, contract) VALUES ($1, $2, $3)",
+		migration.Version, migration.Name, migration.Contract,
+	); err != nil {
+		return false, fmt.Errorf("failed to record migration: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit migration: %v", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const shouldNotifyQuery = `
+	SELECT created_at
+	FROM sent_notifications
+	WHERE chat_id = $1 AND item_url = $2 AND content_hash = $3
+	ORDER BY created_at DESC
+	LIMIT 1
+`
+
+const recordNotificationQuery = `
+	INSERT INTO sent_notifications (chat_id, item_url, notification_type, content_hash, event_id)
+	VALUES ($1, $2, $3, $4, $5)
+`
+
+const lockNotificationQuery = "SELECT pg_advisory_xact_lock(hashtextextended($1, 0))"
+
+const recentNotificationQuery = `
+	SELECT EXISTS(
+		SELECT 1 FROM sent_notifications
+		WHERE chat_id = $1 AND item_url = $2 AND content_hash = $3
+			AND ($4::float8 < 0 OR created_at > CURRENT_TIMESTAMP - make_interval(secs => $4::float8))
+	)
+`
+
+const enqueueNotificationQuery = `
+	INSERT INTO notification_outbox (chat_id, notification_type, repo, message, item_url, occurred_at, event_id, severity, labels, thread_id, account, fields)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
+const claimOutboxQuery = `
+	UPDATE notification_outbox o
+	SET attempts = o.attempts + 1, next_attempt_at = $2
+	FROM users u
+	WHERE u.chat_id = o.chat_id AND o.id IN (
+		SELECT id FROM notification_outbox
+		WHERE sent_at IS NULL AND dead_at IS NULL AND next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING o.id, o.chat_id, u.tenant_id, o.notification_type, o.repo, o.message, o.item_url, o.occurred_at, o.event_id, o.severity, o.labels, o.thread_id, o.account, o.attempts, o.created_at, o.fields
+`
+
+// queryer runs queries on a database or within a transaction.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// statement is a query that runs for every user or notification on every
+// poll cycle. It is prepared once, unless the database URL sets a
+// default_query_exec_mode that does without prepared statements, as
+// needed behind PgBouncer in transaction mode.
+type statement struct {
+	q     queryer
+	query string
+	stmt  *sql.Stmt
+}
+
+func (st *statement) ExecContext(ctx context.Context, args ...any) (sql.Result, error) {
+	if st.stmt != nil {
+		return st.stmt.ExecContext(ctx, args...)
+	}
+	return st.q.ExecContext(ctx, st.query, args...)
+}
+
+func (st *statement) QueryContext(ctx context.Context, args ...any) (*sql.Rows, error) {
+	if st.stmt != nil {
+		return st.stmt.QueryContext(ctx, args...)
+	}
+	return st.q.QueryContext(ctx, st.query, args...)
+}
+
+func (st *statement) QueryRowContext(ctx context.Context, args ...any) *sql.Row {
+	if st.stmt != nil {
+		return st.stmt.QueryRowContext(ctx, args...)
+	}
+	return st.q.QueryRowContext(ctx, st.query, args...)
+}
+
+// Tx returns the statement bound to tx. It must be prepared on the
+// database tx belongs to.
+func (st *statement) Tx(ctx context.Context, tx *sql.Tx) *statement {
+	bound := &statement{q: tx, query: st.query}
+	if st.stmt != nil {
+		bound.stmt = tx.StmtContext(ctx, st.stmt)
+	}
+	return bound
+}
+
+// statements are the hot queries, prepared on the primary or, for reads
+// that tolerate replication lag, on the reader.
+type statements struct {
+	userRows            *statement
+	readerUserRows      *statement
+	shouldNotify        *statement
+	recordNotification  *statement
+	lockNotification    *statement
+	recentNotification  *statement
+	enqueueNotification *statement
+	claimOutbox         *statement
+}
+
+// usesPreparedStatements reports whether the pool's connections may use
+// prepared statements. Only the default exec modes, which prepare and
+// cache statements themselves, do.
+func usesPreparedStatements(pool *pgxpool.Pool) bool {
+	switch pool.Config().ConnConfig.DefaultQueryExecMode {
+	case pgx.QueryExecModeCacheStatement, pgx.QueryExecModeCacheDescribe:
+		return true
+	default:
+		return false
+	}
+}
+
+func prepareStatements(pool *pgxpool.Pool, db *sql.DB, readerPool *pgxpool.Pool, reader *sql.DB) (*statements, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	var err error
+	prepare := func(pool *pgxpool.Pool, db *sql.DB, query string) *statement {
+		st := &statement{q: db, query: query}
+		if err != nil || !usesPreparedStatements(pool) {
+			return st
+		}
+		st.stmt, err = db.PrepareContext(ctx, query)
+		return st
+	}
+
+	st := &statements{
+		userRows:            prepare(pool, db, userRowsQuery),
+		readerUserRows:      prepare(readerPool, reader, userRowsQuery),
+		shouldNotify:        prepare(readerPool, reader, shouldNotifyQuery),
+		recordNotification:  prepare(pool, db, recordNotificationQuery),
+		lockNotification:    prepare(pool, db, lockNotificationQuery),
+		recentNotification:  prepare(pool, db, recentNotificationQuery),
+		enqueueNotification: prepare(pool, db, enqueueNotificationQuery),
+		claimOutbox:         prepare(pool, db, claimOutboxQuery),
+	}
+	if err != nil {
+		st.Close()
+		return nil, fmt.Errorf("failed to prepare statements: %v", err)
+	}
+
+	return st, nil
+}
+
+func (st *statements) Close() {
+	for _, statement := range []*statement{st.userRows, st.readerUserRows, st.shouldNotify, st.recordNotification,
+		st.lockNotification, st.recentNotification, st.enqueueNotification, st.claimOutbox} {
+		if statement.stmt != nil {
+			statement.stmt.Close()
+		}
+	}
+}
@@ -1,43 +1,174 @@
 package postgres
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/erkineren/repository-monitor/internal/models"
-	_ "github.com/lib/pq"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// PoolConfig controls the pgx connection pool. Zero values keep the pgx
+// defaults.
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnLifetime   time.Duration
+	MaxConnIdleTime   time.Duration
+	HealthCheckPeriod time.Duration
+	// StatementTimeout bounds every store operation, both on the server
+	// and on the client, so a hung database can't block the callers.
+	StatementTimeout time.Duration
+}
+
 type Store struct {
-	db *sql.DB
-	mu sync.RWMutex
+	pool             *pgxpool.Pool
+	db               *sql.DB
+	statementTimeout time.Duration
+
+	healthMu sync.Mutex
+	health   Health
+
+	// reader serves read-heavy queries that tolerate replication lag. It
+	// is db unless a read replica is configured.
+	readerPool *pgxpool.Pool
+	reader     *sql.DB
+
+	stmts *statements
+
+	// lockConn holds the advisory locks taken with TryLock.
+	lockMu   sync.Mutex
+	lockConn *sql.Conn
 }
 
-func New(dbURL string) (*Store, error) {
-	db, err := sql.Open("postgres", dbURL)
+// New opens the store. With migrate, pending expand migrations are applied
+// first. It fails when the schema does not match this version, see
+// SchemaStatus.Check.
+func New(dbURL string, poolConfig PoolConfig, migrate bool) (*Store, error) {
+	pool, db, err := openDB(dbURL, poolConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	ctx := context.Background()
+	if migrate {
+		if _, err := applyMigrations(ctx, db, false); err != nil {
+			db.Close()
+			pool.Close()
+			return nil, fmt.Errorf("failed to migrate database: %v", err)
+		}
+	}
+	status, err := schemaStatus(ctx, db)
+	if err == nil {
+		err = status.Check()
+	}
+	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to ping database: %v", err)
+		pool.Close()
+		return nil, err
 	}
 
-	if err := initDatabase(db); err != nil {
+	stmts, err := prepareStatements(pool, db, pool, db)
+	if err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to initialize database: %v", err)
+		pool.Close()
+		return nil, err
 	}
 
 	return &Store{
-		db: db,
+		pool:             pool,
+		db:               db,
+		statementTimeout: poolConfig.StatementTimeout,
+		reader:           db,
+		stmts:            stmts,
 	}, nil
 }
 
-func initDatabase(db *sql.DB) error {
-	queries := []string{
+// OpenReadReplica routes the poll cycle's user listing, dedup lookups and
+// history queries to a read-only replica. Everything else, including reads
+// that must see the caller's own writes, stays on the primary.
+func (s *Store) OpenReadReplica(readURL string, poolConfig PoolConfig) error {
+	pool, db, err := openDB(readURL, poolConfig)
+	if err != nil {
+		return fmt.Errorf("read replica: %v", err)
+	}
+
+	stmts, err := prepareStatements(s.pool, s.db, pool, db)
+	if err != nil {
+		db.Close()
+		pool.Close()
+		return err
+	}
+
+	s.stmts.Close()
+	s.stmts = stmts
+	s.readerPool = pool
+	s.reader = db
+	return nil
+}
+
+func openDB(dbURL string, poolConfig PoolConfig) (*pgxpool.Pool, *sql.DB, error) {
+	config, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse database URL: %v", err)
+	}
+	if poolConfig.MaxConns > 0 {
+		config.MaxConns = poolConfig.MaxConns
+	}
+	if poolConfig.MinConns > 0 {
+		config.MinConns = poolConfig.MinConns
+	}
+	if poolConfig.MaxConnLifetime > 0 {
+		config.MaxConnLifetime = poolConfig.MaxConnLifetime
+	}
+	if poolConfig.MaxConnIdleTime > 0 {
+		config.MaxConnIdleTime = poolConfig.MaxConnIdleTime
+	}
+	if poolConfig.HealthCheckPeriod > 0 {
+		config.HealthCheckPeriod = poolConfig.HealthCheckPeriod
+	}
+	if poolConfig.StatementTimeout > 0 {
+		config.ConnConfig.RuntimeParams["statement_timeout"] = fmt.Sprintf("%d", poolConfig.StatementTimeout.Milliseconds())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	pool, err := pgxpool.NewWithConfig(ctx, config)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	if err := connect(ctx, pool); err != nil {
+		pool.Close()
+		return nil, nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	// Queries go through database/sql on top of the pool, so connection
+	// limits and health checks are managed by pgxpool.
+	return pool, stdlib.OpenDBFromPool(pool), nil
+}
+
+func (s *Store) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.statementTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.statementTimeout)
+}
+
+// migrations is the schema, oldest first. Never change an applied
+// migration; add a new one with the next version instead.
+var migrations = []Migration{
+	expand(1, "initial schema",
 		`CREATE TABLE IF NOT EXISTS users (
 			chat_id BIGINT PRIMARY KEY
 		)`,
@@ -61,203 +192,2348 @@ func initDatabase(db *sql.DB) error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_notifications_chat_url_type 
 			ON sent_notifications(chat_id, item_url, notification_type, content_hash)`,
+	),
+	expand(2, "muted repos",
+		`CREATE TABLE IF NOT EXISTS muted_repos (
+			chat_id BIGINT NOT NULL,
+			repo TEXT NOT NULL,
+			PRIMARY KEY (chat_id, repo),
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+	),
+	expand(3, "calendar tokens",
+		addColumn("users", "calendar_token", "TEXT UNIQUE"),
+	),
+	expand(4, "jira",
+		`CREATE TABLE IF NOT EXISTS jira_configs (
+			chat_id BIGINT PRIMARY KEY,
+			base_url TEXT NOT NULL,
+			email TEXT NOT NULL,
+			api_token TEXT NOT NULL,
+			project_key TEXT NOT NULL,
+			issue_type TEXT NOT NULL DEFAULT 'Task',
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+	),
+	expand(5, "linear",
+		`CREATE TABLE IF NOT EXISTS linear_configs (
+			chat_id BIGINT PRIMARY KEY,
+			api_key TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			project_id TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS linear_mappings (
+			chat_id BIGINT NOT NULL,
+			repo TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			project_id TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (chat_id, repo),
+			FOREIGN KEY (chat_id) REFERENCES linear_configs(chat_id) ON DELETE CASCADE
+		)`,
+	),
+	expand(6, "gerrit accounts",
+		`CREATE TABLE IF NOT EXISTS gerrit_accounts (
+			id SERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			base_url TEXT NOT NULL,
+			username TEXT NOT NULL,
+			password TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT true,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id),
+			UNIQUE(chat_id, base_url, username)
+		)`,
+	),
+	expand(7, "image subscriptions",
+		`CREATE TABLE IF NOT EXISTS image_subscriptions (
+			id SERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			image TEXT NOT NULL,
+			tag_filter TEXT NOT NULL DEFAULT '',
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE,
+			UNIQUE(chat_id, image)
+		)`,
+		`CREATE TABLE IF NOT EXISTS image_tags_seen (
+			subscription_id INTEGER NOT NULL,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (subscription_id, tag),
+			FOREIGN KEY (subscription_id) REFERENCES image_subscriptions(id) ON DELETE CASCADE
+		)`,
+	),
+	expand(8, "dependency subscriptions",
+		`CREATE TABLE IF NOT EXISTS dependency_subscriptions (
+			id SERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			repo TEXT NOT NULL,
+			last_checked_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE,
+			UNIQUE(chat_id, repo)
+		)`,
+		`CREATE TABLE IF NOT EXISTS dependency_versions_seen (
+			subscription_id INTEGER NOT NULL,
+			version_key TEXT NOT NULL,
+			PRIMARY KEY (subscription_id, version_key),
+			FOREIGN KEY (subscription_id) REFERENCES dependency_subscriptions(id) ON DELETE CASCADE
+		)`,
+	),
+	expand(9, "account state",
+		`CREATE TABLE IF NOT EXISTS account_state (
+			chat_id BIGINT NOT NULL,
+			kind TEXT NOT NULL,
+			account TEXT NOT NULL,
+			last_checked_at TIMESTAMP WITH TIME ZONE,
+			last_modified TEXT NOT NULL DEFAULT '',
+			last_notification_at TIMESTAMP WITH TIME ZONE,
+			consecutive_errors INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (chat_id, kind, account),
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+	),
+	expand(10, "notification outbox",
+		`CREATE TABLE IF NOT EXISTS notification_outbox (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			notification_type TEXT NOT NULL,
+			repo TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL,
+			item_url TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			sent_at TIMESTAMP WITH TIME ZONE,
+			dead_at TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_outbox_pending
+			ON notification_outbox(next_attempt_at) WHERE sent_at IS NULL AND dead_at IS NULL`,
+	),
+	expand(11, "soft delete",
+		addColumn("users", "deleted_at", "TIMESTAMP WITH TIME ZONE"),
+	),
+	expand(12, "user preferences",
+		`CREATE TABLE IF NOT EXISTS user_preferences (
+			chat_id BIGINT PRIMARY KEY,
+			quiet_hours_start TEXT NOT NULL DEFAULT '',
+			quiet_hours_end TEXT NOT NULL DEFAULT '',
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			digest_mode TEXT NOT NULL DEFAULT 'off',
+			language TEXT NOT NULL DEFAULT 'en',
+			parse_mode TEXT NOT NULL DEFAULT 'MarkdownV2',
+			priorities JSONB NOT NULL DEFAULT '{}',
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+	),
+	expand(13, "repo subscriptions",
+		`CREATE TABLE IF NOT EXISTS repo_subscriptions (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			repo TEXT NOT NULL,
+			event_types JSONB NOT NULL DEFAULT '[]',
+			filters JSONB NOT NULL DEFAULT '{}',
+			destination_chat_id BIGINT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE,
+			UNIQUE(chat_id, repo)
+		)`,
+	),
+	expand(14, "tenants",
+		addColumn("users", "tenant_id", "TEXT NOT NULL DEFAULT 'default'"),
+		`CREATE INDEX IF NOT EXISTS idx_users_tenant ON users(tenant_id)`,
+		`CREATE TABLE IF NOT EXISTS tenants (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			bot_token TEXT NOT NULL,
+			api_token TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	),
+	expand(15, "account metadata",
+		addColumn("github_accounts", "scopes", "JSONB NOT NULL DEFAULT '[]'"),
+		addColumn("github_accounts", "expires_at", "TIMESTAMP WITH TIME ZONE"),
+		addColumn("github_accounts", "note", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("github_accounts", "added_at", "TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP"),
+	),
+	expand(16, "feature flags",
+		`CREATE TABLE IF NOT EXISTS feature_flags (
+			name TEXT PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT false,
+			percent INTEGER NOT NULL DEFAULT 0,
+			chat_ids JSONB NOT NULL DEFAULT '[]',
+			updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+	),
+	expand(17, "poll intervals",
+		addColumn("account_state", "poll_interval", "INTEGER NOT NULL DEFAULT 0"),
+	),
+	expand(18, "event times",
+		addColumn("notification_outbox", "occurred_at", "TIMESTAMP WITH TIME ZONE"),
+	),
+	expand(19, "account errors",
+		addColumn("account_state", "last_error", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(20, "renotify intervals",
+		addColumn("user_preferences", "renotify_intervals", "JSONB NOT NULL DEFAULT '{}'"),
+	),
+	expand(21, "delivery pause",
+		addColumn("user_preferences", "paused", "BOOLEAN NOT NULL DEFAULT FALSE"),
+		addColumn("user_preferences", "paused_until", "TIMESTAMP WITH TIME ZONE"),
+	),
+	expand(22, "poll windows",
+		addColumn("user_preferences", "poll_window", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(23, "event ids",
+		addColumn("notification_outbox", "event_id", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("sent_notifications", "event_id", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(24, "severities",
+		addColumn("notification_outbox", "severity", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(25, "routing rules",
+		`CREATE TABLE IF NOT EXISTS routing_rules (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			notification_type TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT '',
+			label TEXT NOT NULL DEFAULT '',
+			keyword TEXT NOT NULL DEFAULT '',
+			severity TEXT NOT NULL DEFAULT '',
+			destination_chat_id BIGINT NOT NULL DEFAULT 0,
+			silent BOOLEAN NOT NULL DEFAULT FALSE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_routing_rules_chat ON routing_rules(chat_id)`,
+		addColumn("notification_outbox", "labels", "JSONB NOT NULL DEFAULT '[]'"),
+	),
+	expand(26, "filters",
+		addColumn("user_preferences", "filter", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(27, "review requests",
+		`CREATE TABLE IF NOT EXISTS review_requests (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			account TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			url TEXT NOT NULL,
+			requested_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			reviewed_at TIMESTAMP WITH TIME ZONE,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_review_requests_chat ON review_requests(chat_id, requested_at)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_review_requests_pending ON review_requests(chat_id, account, url) WHERE reviewed_at IS NULL`,
+	),
+	expand(28, "team digests",
+		addColumn("user_preferences", "team_digest", "TEXT NOT NULL DEFAULT ''"),
+		addColumn("user_preferences", "team_repos", "JSONB NOT NULL DEFAULT '[]'"),
+	),
+	expand(29, "mention slas",
+		`CREATE TABLE IF NOT EXISTS mentions (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			account TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			message TEXT NOT NULL,
+			url TEXT NOT NULL,
+			mentioned_at TIMESTAMP WITH TIME ZONE NOT NULL,
+			UNIQUE (chat_id, account, url),
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE
+		)`,
+		addColumn("user_preferences", "mention_sla", "INTEGER NOT NULL DEFAULT 0"),
+	),
+	expand(30, "triage",
+		addColumn("user_preferences", "triage", "JSONB NOT NULL DEFAULT '{}'"),
+	),
+	expand(31, "thread summaries",
+		addColumn("user_preferences", "summaries", "BOOLEAN NOT NULL DEFAULT FALSE"),
+	),
+	expand(32, "release changelogs",
+		addColumn("user_preferences", "changelog", "JSONB NOT NULL DEFAULT '{}'"),
+	),
+	expand(33, "usage alerts",
+		addColumn("user_preferences", "usage_alerts", "JSONB NOT NULL DEFAULT '{}'"),
+	),
+	expand(34, "commit mentions",
+		addColumn("user_preferences", "commit_mention_repos", "JSONB NOT NULL DEFAULT '[]'"),
+	),
+	expand(35, "path subscriptions",
+		`CREATE TABLE IF NOT EXISTS path_subscriptions (
+			id BIGSERIAL PRIMARY KEY,
+			chat_id BIGINT NOT NULL,
+			repo TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (chat_id) REFERENCES users(chat_id) ON DELETE CASCADE,
+			UNIQUE(chat_id, repo, pattern)
+		)`,
+	),
+	expand(36, "account preferences",
+		`CREATE TABLE IF NOT EXISTS account_preferences (
+			chat_id BIGINT NOT NULL,
+			username TEXT NOT NULL,
+			disabled_reasons JSONB NOT NULL DEFAULT '[]',
+			PRIMARY KEY (chat_id, username),
+			FOREIGN KEY (chat_id, username) REFERENCES github_accounts(chat_id, username) ON DELETE CASCADE
+		)`,
+	),
+	expand(37, "digest times",
+		addColumn("user_preferences", "digest_time", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(38, "notification threads",
+		addColumn("notification_outbox", "thread_id", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(39, "notification accounts",
+		addColumn("notification_outbox", "account", "TEXT NOT NULL DEFAULT ''"),
+	),
+	expand(40, "security alerts",
+		addColumn("account_preferences", "security_alerts", "BOOLEAN NOT NULL DEFAULT FALSE"),
+	),
+	expand(41, "notification fields",
+		addColumn("notification_outbox", "fields", "JSONB NOT NULL DEFAULT '{}'"),
+	),
+}
+
+func (s *Store) Close() error {
+	s.closeLockConnection()
+	s.stmts.Close()
+	if s.readerPool != nil {
+		s.reader.Close()
+		s.readerPool.Close()
 	}
+	err := s.db.Close()
+	s.pool.Close()
+	return err
+}
 
-	for _, query := range queries {
-		if _, err := db.Exec(query); err != nil {
-			return fmt.Errorf("failed to execute query %q: %v", query, err)
-		}
+func (s *Store) AddTenant(ctx context.Context, tenant models.Tenant) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if tenant.ID == "" || tenant.ID == store.DefaultTenant {
+		return fmt.Errorf("invalid tenant ID %q", tenant.ID)
+	}
+
+	query := `
+		INSERT INTO tenants (id, name, bot_token, api_token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET name = $2, bot_token = $3, api_token = $4
+	`
+	if _, err := s.db.ExecContext(ctx, query, tenant.ID, tenant.Name, tenant.BotToken, tenant.APIToken); err != nil {
+		return fmt.Errorf("failed to save tenant: %v", err)
 	}
 
 	return nil
 }
 
-func (s *Store) Close() error {
-	return s.db.Close()
-}
+// RemoveTenant deletes a tenant that no longer has users.
+func (s *Store) RemoveTenant(ctx context.Context, tenantID string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-func (s *Store) AddGitHubAccount(chatID int64, githubToken, githubUsername string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	query := "DELETE FROM tenants WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE tenant_id = $1)"
+	result, err := s.db.ExecContext(ctx, query, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove tenant: %v", err)
+	}
 
-	tx, err := s.db.Begin()
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %v", err)
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows > 0 {
+		return nil
 	}
-	defer tx.Rollback()
 
-	if _, err := tx.Exec("INSERT INTO users (chat_id) VALUES ($1) ON CONFLICT DO NOTHING", chatID); err != nil {
-		return fmt.Errorf("failed to insert user: %v", err)
+	var users int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE tenant_id = $1", tenantID).Scan(&users); err != nil {
+		return fmt.Errorf("failed to count tenant users: %v", err)
 	}
+	if users > 0 {
+		return fmt.Errorf("tenant %s still has %d users", tenantID, users)
+	}
+	return fmt.Errorf("tenant not found")
+}
 
-	query := `
-		INSERT INTO github_accounts (chat_id, username, token, is_active)
-		VALUES ($1, $2, $3, true)
-		ON CONFLICT (chat_id, username) DO UPDATE SET token = $3, is_active = true
-	`
-	if _, err := tx.Exec(query, chatID, githubUsername, githubToken); err != nil {
-		return fmt.Errorf("failed to insert GitHub account: %v", err)
+func (s *Store) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, bot_token, api_token, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %v", err)
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &tenant.BotToken, &tenant.APIToken, &tenant.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %v", err)
+		}
+		tenants = append(tenants, tenant)
 	}
 
-	return tx.Commit()
+	return tenants, rows.Err()
 }
 
-func (s *Store) RemoveGitHubAccount(chatID int64, githubUsername string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// SetFeatureFlag creates or replaces a feature flag.
+func (s *Store) SetFeatureFlag(ctx context.Context, flag models.FeatureFlag) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	query := "DELETE FROM github_accounts WHERE chat_id = $1 AND username = $2"
-	if _, err := s.db.Exec(query, chatID, githubUsername); err != nil {
-		return fmt.Errorf("failed to remove GitHub account: %v", err)
+	if err := flag.Validate(); err != nil {
+		return err
 	}
 
-	var count int
-	if err := s.db.QueryRow("SELECT COUNT(*) FROM github_accounts WHERE chat_id = $1", chatID).Scan(&count); err != nil {
-		return fmt.Errorf("failed to count remaining accounts: %v", err)
+	chatIDs := flag.ChatIDs
+	if chatIDs == nil {
+		chatIDs = []int64{}
+	}
+	encoded, err := json.Marshal(chatIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode chat IDs: %v", err)
 	}
 
-	if count == 0 {
-		if _, err := s.db.Exec("DELETE FROM users WHERE chat_id = $1", chatID); err != nil {
-			return fmt.Errorf("failed to remove user: %v", err)
-		}
+	query := `
+		INSERT INTO feature_flags (name, enabled, percent, chat_ids, updated_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (name) DO UPDATE SET enabled = $2, percent = $3, chat_ids = $4, updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := s.db.ExecContext(ctx, query, flag.Name, flag.Enabled, flag.Percent, string(encoded)); err != nil {
+		return fmt.Errorf("failed to save feature flag: %v", err)
 	}
 
 	return nil
 }
 
-func (s *Store) ToggleGitHubAccount(chatID int64, githubUsername string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Store) RemoveFeatureFlag(ctx context.Context, name string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	query := `
-		UPDATE github_accounts
-		SET is_active = NOT is_active
-		WHERE chat_id = $1 AND username = $2
-	`
-	result, err := s.db.Exec(query, chatID, githubUsername)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM feature_flags WHERE name = $1", name)
 	if err != nil {
-		return fmt.Errorf("failed to toggle GitHub account: %v", err)
+		return fmt.Errorf("failed to remove feature flag: %v", err)
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get rows affected: %v", err)
 	}
-
 	if rows == 0 {
-		return fmt.Errorf("account not found")
+		return fmt.Errorf("feature flag not found")
 	}
 
 	return nil
 }
 
-func (s *Store) GetUser(chatID int64) (*models.User, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	user := &models.User{
-		ChatID:   chatID,
-		Accounts: make(map[string]*models.GitHubAccount),
-	}
+func (s *Store) GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	query := `
-		SELECT username, token, is_active
-		FROM github_accounts
-		WHERE chat_id = $1
-	`
-	rows, err := s.db.Query(query, chatID)
+	rows, err := s.db.QueryContext(ctx, "SELECT name, enabled, percent, chat_ids, updated_at FROM feature_flags ORDER BY name")
 	if err != nil {
-		return nil, false
+		return nil, fmt.Errorf("failed to query feature flags: %v", err)
 	}
 	defer rows.Close()
 
-	exists := false
+	var flags []models.FeatureFlag
 	for rows.Next() {
-		exists = true
-		var account models.GitHubAccount
-		if err := rows.Scan(&account.Username, &account.Token, &account.IsActive); err != nil {
-			continue
+		var flag models.FeatureFlag
+		var chatIDs []byte
+		if err := rows.Scan(&flag.Name, &flag.Enabled, &flag.Percent, &chatIDs, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %v", err)
+		}
+		if err := json.Unmarshal(chatIDs, &flag.ChatIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode chat IDs: %v", err)
 		}
-		user.Accounts[account.Username] = &account
+		flags = append(flags, flag)
 	}
 
-	return user, exists
+	return flags, rows.Err()
 }
 
-func (s *Store) GetAllUsers() ([]*models.User, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// ChatTenant returns the tenant a chat is registered with.
+func (s *Store) ChatTenant(ctx context.Context, chatID int64) (string, bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	rows, err := s.db.Query("SELECT DISTINCT chat_id FROM users")
+	var tenantID string
+	err := s.db.QueryRowContext(ctx, "SELECT tenant_id FROM users WHERE chat_id = $1", chatID).Scan(&tenantID)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to query users: %v", err)
+		return "", false, fmt.Errorf("failed to get chat tenant: %v", err)
 	}
-	defer rows.Close()
 
-	var users []*models.User
-	for rows.Next() {
-		var chatID int64
-		if err := rows.Scan(&chatID); err != nil {
-			return nil, fmt.Errorf("failed to scan chat_id: %v", err)
-		}
+	return tenantID, true, nil
+}
 
-		if user, exists := s.GetUser(chatID); exists {
-			users = append(users, user)
-		}
+func (s *Store) AddGitHubAccount(ctx context.Context, chatID int64, githubToken, githubUsername string, metadata models.GitHubAccountMetadata) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	scopes := metadata.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	encodedScopes, err := json.Marshal(scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode scopes: %v", err)
+	}
+	var expiresAt sql.NullTime
+	if !metadata.ExpiresAt.IsZero() {
+		expiresAt = sql.NullTime{Time: metadata.ExpiresAt, Valid: true}
 	}
 
-	return users, nil
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	// Scopes and expiry belong to the token and are replaced with it, while
+	// the note is kept unless a new one is given.
+	query := `
+		INSERT INTO github_accounts (chat_id, username, token, is_active, scopes, expires_at, note)
+		VALUES ($1, $2, $3, true, $4, $5, $6)
+		ON CONFLICT (chat_id, username) DO UPDATE
+		SET token = $3, is_active = true, scopes = $4, expires_at = $5,
+			note = COALESCE(NULLIF($6, ''), github_accounts.note)
+	`
+	if _, err := tx.ExecContext(ctx, query, chatID, githubUsername, githubToken, string(encodedScopes), expiresAt, metadata.Note); err != nil {
+		return fmt.Errorf("failed to insert GitHub account: %v", err)
+	}
+
+	return tx.Commit()
 }
 
-func (s *Store) ShouldNotify(chatID int64, itemURL string, notificationType string, contentHash string, renotifyInterval int) (bool, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *Store) RemoveGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
 
-	var lastNotification time.Time
-	err := s.db.QueryRow(`
-		SELECT created_at 
-		FROM sent_notifications 
-		WHERE chat_id = $1 AND item_url = $2 AND notification_type = $3 AND content_hash = $4
-		ORDER BY created_at DESC 
-		LIMIT 1
-	`, chatID, itemURL, notificationType, contentHash).Scan(&lastNotification)
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-	if err == sql.ErrNoRows {
-		return true, nil
+	if err := lockUser(ctx, tx, chatID); err == store.ErrUserNotFound {
+		return nil
 	} else if err != nil {
-		return false, fmt.Errorf("failed to query notification: %v", err)
+		return err
+	}
+
+	query := "DELETE FROM github_accounts WHERE chat_id = $1 AND username = $2"
+	if _, err := tx.ExecContext(ctx, query, chatID, githubUsername); err != nil {
+		return fmt.Errorf("failed to remove GitHub account: %v", err)
 	}
 
-	return time.Since(lastNotification) > time.Duration(renotifyInterval)*time.Hour, nil
+	for _, kind := range []string{models.AccountKindGitHub, models.AccountKindSecurity} {
+		if err := removeAccountState(ctx, tx, chatID, kind, githubUsername); err != nil {
+			return err
+		}
+	}
+
+	if err := removeUserIfEmpty(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *Store) RecordNotification(chatID int64, itemURL string, notificationType string, contentHash string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ensureUser creates the user in the context's tenant, restoring it if it
+// was soft-deleted. Chats that belong to another tenant are rejected.
+func ensureUser(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	tenantID, ok := store.TenantFromContext(ctx)
+	if !ok {
+		tenantID = store.DefaultTenant
+	}
 
-	_, err := s.db.Exec(`
-		INSERT INTO sent_notifications (chat_id, item_url, notification_type, content_hash)
-		VALUES ($1, $2, $3, $4)
-	`, chatID, itemURL, notificationType, contentHash)
+	var owner string
+	query := `
+		INSERT INTO users (chat_id, tenant_id) VALUES ($1, $2)
+		ON CONFLICT (chat_id) DO UPDATE SET deleted_at = NULL
+		RETURNING tenant_id
+	`
+	if err := tx.QueryRowContext(ctx, query, chatID, tenantID).Scan(&owner); err != nil {
+		return fmt.Errorf("failed to insert user: %v", err)
+	}
+	if ok && owner != tenantID {
+		return store.ErrTenantMismatch
+	}
+
+	return nil
+}
 
+// lockUser locks the user's row until the transaction ends, so changes
+// that read and then write a user's rows, like removing the last account,
+// are applied one after the other instead of interleaving.
+func lockUser(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := "SELECT chat_id FROM users WHERE chat_id = $1 AND deleted_at IS NULL AND ($2 = '' OR tenant_id = $2) FOR UPDATE"
+	err := tx.QueryRowContext(ctx, query, chatID, tenantID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return store.ErrUserNotFound
+	}
 	if err != nil {
-		return fmt.Errorf("failed to record notification: %v", err)
+		return fmt.Errorf("failed to lock user: %v", err)
+	}
+	return nil
+}
+
+// removeUserIfEmpty soft-deletes the user once the last account is gone.
+// Preferences, mutes and subscriptions are kept until the user is purged,
+// so adding an account again within the grace period restores them. The
+// user must be locked with lockUser, otherwise an account added
+// concurrently could be missed by the count.
+func removeUserIfEmpty(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	var count int
+	countQuery := `
+		SELECT (SELECT COUNT(*) FROM github_accounts WHERE chat_id = $1)
+			+ (SELECT COUNT(*) FROM gerrit_accounts WHERE chat_id = $1)
+	`
+	if err := tx.QueryRowContext(ctx, countQuery, chatID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count remaining accounts: %v", err)
+	}
+
+	if count == 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET deleted_at = CURRENT_TIMESTAMP WHERE chat_id = $1", chatID); err != nil {
+			return fmt.Errorf("failed to remove user: %v", err)
+		}
 	}
 
 	return nil
 }
 
-func (s *Store) CleanOldNotifications(renotifyInterval int) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+func (s *Store) AddGerritAccount(ctx context.Context, chatID int64, account models.GerritAccount) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO gerrit_accounts (chat_id, base_url, username, password, is_active)
+		VALUES ($1, $2, $3, $4, true)
+		ON CONFLICT (chat_id, base_url, username) DO UPDATE SET password = $4, is_active = true
+	`
+	if _, err := tx.ExecContext(ctx, query, chatID, account.BaseURL, account.Username, account.Password); err != nil {
+		return fmt.Errorf("failed to insert Gerrit account: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveGerritAccount(ctx context.Context, chatID int64, baseURL, username string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
 
-	_, err := s.db.Exec(`
-		DELETE FROM sent_notifications 
-		WHERE created_at < $1
-	`, time.Now().Add(-time.Duration(renotifyInterval)*time.Hour))
+	if err := lockUser(ctx, tx, chatID); err == store.ErrUserNotFound {
+		return store.ErrAccountNotFound
+	} else if err != nil {
+		return err
+	}
 
+	query := "DELETE FROM gerrit_accounts WHERE chat_id = $1 AND base_url = $2 AND username = $3"
+	result, err := tx.ExecContext(ctx, query, chatID, baseURL, username)
 	if err != nil {
-		return fmt.Errorf("failed to clean old notifications: %v", err)
+		return fmt.Errorf("failed to remove Gerrit account: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	account := models.GerritAccount{BaseURL: baseURL, Username: username}
+	if err := removeAccountState(ctx, tx, chatID, models.AccountKindGerrit, account.Key()); err != nil {
+		return err
+	}
+
+	if err := removeUserIfEmpty(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ToggleGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		UPDATE github_accounts
+		SET is_active = NOT is_active
+		WHERE chat_id = $1 AND username = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))
+	`
+	result, err := s.db.ExecContext(ctx, query, chatID, githubUsername, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to toggle GitHub account: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) GetAccountPreferences(ctx context.Context, chatID int64, githubUsername string) (models.AccountPreferences, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	preferences := models.AccountPreferences{ChatID: chatID, Username: githubUsername}
+	var disabledReasons []byte
+	tenantID, _ := store.TenantFromContext(ctx)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT disabled_reasons, security_alerts
+		FROM account_preferences
+		WHERE chat_id = $1 AND username = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))
+	`, chatID, githubUsername, tenantID).Scan(&disabledReasons, &preferences.SecurityAlerts)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, fmt.Errorf("failed to get account preferences: %v", err)
+	}
+
+	if err := json.Unmarshal(disabledReasons, &preferences.DisabledReasons); err != nil {
+		return preferences, fmt.Errorf("failed to decode disabled reasons: %v", err)
+	}
+	return preferences, nil
+}
+
+// SetAccountPreferences saves the preferences of an existing account, and
+// returns store.ErrAccountNotFound for others.
+func (s *Store) SetAccountPreferences(ctx context.Context, preferences models.AccountPreferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	disabledReasons := preferences.DisabledReasons
+	if disabledReasons == nil {
+		disabledReasons = []string{}
+	}
+	encodedDisabledReasons, err := json.Marshal(disabledReasons)
+	if err != nil {
+		return fmt.Errorf("failed to encode disabled reasons: %v", err)
+	}
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		INSERT INTO account_preferences (chat_id, username, disabled_reasons, security_alerts)
+		SELECT chat_id, username, $3::jsonb, $4
+		FROM github_accounts
+		WHERE chat_id = $1 AND username = $2 AND ($5 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $5))
+		ON CONFLICT (chat_id, username) DO UPDATE SET
+			disabled_reasons = EXCLUDED.disabled_reasons,
+			security_alerts = EXCLUDED.security_alerts
+	`
+	result, err := s.db.ExecContext(ctx, query, preferences.ChatID, preferences.Username, string(encodedDisabledReasons), preferences.SecurityAlerts, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to save account preferences: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+// userRowsQuery materializes a page of users with their accounts and muted
+// repositories in a single round trip. The page holds up to $4 users with
+// at least one account and a chat ID above $2; $1 narrows it to a single
+// chat. Each row carries one item, tagged by kind, so accounts and mutes
+// don't multiply into a cross product.
+const userRowsQuery = `
+	WITH page AS (
+		SELECT u.chat_id, u.tenant_id
+		FROM users u
+		WHERE ($1 = 0 OR u.chat_id = $1) AND u.chat_id > $2 AND ($3 = '' OR u.tenant_id = $3)
+			AND (EXISTS (SELECT 1 FROM github_accounts g WHERE g.chat_id = u.chat_id)
+				OR EXISTS (SELECT 1 FROM gerrit_accounts g WHERE g.chat_id = u.chat_id))
+		ORDER BY u.chat_id
+		LIMIT $4
+	)
+	SELECT items.chat_id, page.tenant_id, kind, name, secret, base_url, is_active, scopes, expires_at, note, added_at FROM (
+		SELECT chat_id, 'github' AS kind, username AS name, token AS secret, '' AS base_url, is_active,
+			scopes, expires_at, note, added_at
+		FROM github_accounts
+		WHERE chat_id IN (SELECT chat_id FROM page)
+		UNION ALL
+		SELECT chat_id, 'gerrit', username, password, base_url, is_active, '[]', NULL, '', NULL
+		FROM gerrit_accounts
+		WHERE chat_id IN (SELECT chat_id FROM page)
+		UNION ALL
+		SELECT chat_id, 'muted', repo, '', '', false, '[]', NULL, '', NULL
+		FROM muted_repos
+		WHERE chat_id IN (SELECT chat_id FROM page)
+	) items
+	JOIN page ON page.chat_id = items.chat_id
+	ORDER BY items.chat_id
+`
+
+func (s *Store) GetUser(ctx context.Context, chatID int64) (*models.User, bool) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	users, err := s.loadUsers(ctx, s.stmts.userRows, chatID, store.FirstChatID, 1)
+	if err != nil || len(users) == 0 {
+		return nil, false
+	}
+
+	return users[0], true
+}
+
+func (s *Store) ListUsers(ctx context.Context, afterChatID int64, limit int) ([]*models.User, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	users, err := s.loadUsers(ctx, s.stmts.readerUserRows, 0, afterChatID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+
+	return users, nil
+}
+
+// loadUsers returns a page of the context's tenant's users that have at
+// least one account, in chat ID order. A chatID of 0 loads every user.
+func (s *Store) loadUsers(ctx context.Context, stmt *statement, chatID, afterChatID int64, limit int) ([]*models.User, error) {
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := stmt.QueryContext(ctx, chatID, afterChatID, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	var user *models.User
+	hasAccounts := false
+	for rows.Next() {
+		var chatID int64
+		var tenantID, kind, name, secret, baseURL, note string
+		var isActive bool
+		var scopes []byte
+		var expiresAt, addedAt sql.NullTime
+		if err := rows.Scan(&chatID, &tenantID, &kind, &name, &secret, &baseURL, &isActive, &scopes, &expiresAt, &note, &addedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %v", err)
+		}
+
+		if user == nil || user.ChatID != chatID {
+			if user != nil && hasAccounts {
+				users = append(users, user)
+			}
+			user = &models.User{
+				ChatID:     chatID,
+				TenantID:   tenantID,
+				Accounts:   make(map[string]*models.GitHubAccount),
+				MutedRepos: make(map[string]bool),
+			}
+			hasAccounts = false
+		}
+
+		switch kind {
+		case "github":
+			hasAccounts = true
+			account := &models.GitHubAccount{Username: name, Token: secret, IsActive: isActive, AddedAt: addedAt.Time}
+			account.Note = note
+			account.ExpiresAt = expiresAt.Time
+			if err := json.Unmarshal(scopes, &account.Scopes); err != nil {
+				return nil, fmt.Errorf("failed to decode scopes: %v", err)
+			}
+			user.Accounts[name] = account
+		case "gerrit":
+			hasAccounts = true
+			user.GerritAccounts = append(user.GerritAccounts, &models.GerritAccount{BaseURL: baseURL, Username: name, Password: secret, IsActive: isActive})
+		case "muted":
+			user.MutedRepos[name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if user != nil && hasAccounts {
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (s *Store) AddImageSubscription(ctx context.Context, chatID int64, image, tagFilter string, seenTags []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	// Re-subscribing resets the seen tags so the new filter starts from the
+	// current state of the registry.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM image_subscriptions WHERE chat_id = $1 AND image = $2", chatID, image); err != nil {
+		return fmt.Errorf("failed to replace image subscription: %v", err)
+	}
+
+	var id int64
+	query := "INSERT INTO image_subscriptions (chat_id, image, tag_filter) VALUES ($1, $2, $3) RETURNING id"
+	if err := tx.QueryRowContext(ctx, query, chatID, image, tagFilter).Scan(&id); err != nil {
+		return fmt.Errorf("failed to insert image subscription: %v", err)
+	}
+
+	for _, tag := range seenTags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO image_tags_seen (subscription_id, tag) VALUES ($1, $2)", id, tag); err != nil {
+			return fmt.Errorf("failed to record seen tag: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveImageSubscription(ctx context.Context, chatID int64, image string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM image_subscriptions WHERE chat_id = $1 AND image = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, image, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove image subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetImageSubscriptions(ctx context.Context, chatID int64) ([]models.ImageSubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, image, tag_filter FROM image_subscriptions WHERE chat_id = $1 ORDER BY image", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.ImageSubscription
+	for rows.Next() {
+		subscription := models.ImageSubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Image, &subscription.TagFilter); err != nil {
+			return nil, fmt.Errorf("failed to scan image subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT tag FROM image_tags_seen WHERE subscription_id = $1", subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen tags: %v", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan seen tag: %v", err)
+		}
+		seen[tag] = true
+	}
+
+	return seen, rows.Err()
+}
+
+func (s *Store) MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	for _, tag := range tags {
+		query := "INSERT INTO image_tags_seen (subscription_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := s.db.ExecContext(ctx, query, subscriptionID, tag); err != nil {
+			return fmt.Errorf("failed to record seen tag: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) AddDependencySubscription(ctx context.Context, chatID int64, repo string, seenVersions []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	query := `
+		INSERT INTO dependency_subscriptions (chat_id, repo)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id, repo) DO UPDATE SET last_checked_at = CURRENT_TIMESTAMP
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, chatID, repo).Scan(&id); err != nil {
+		return fmt.Errorf("failed to insert dependency subscription: %v", err)
+	}
+
+	for _, key := range seenVersions {
+		query := "INSERT INTO dependency_versions_seen (subscription_id, version_key) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := tx.ExecContext(ctx, query, id, key); err != nil {
+			return fmt.Errorf("failed to record seen version: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveDependencySubscription(ctx context.Context, chatID int64, repo string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM dependency_subscriptions WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove dependency subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetDependencySubscriptions(ctx context.Context, chatID int64) ([]models.DependencySubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT id, repo, last_checked_at FROM dependency_subscriptions WHERE chat_id = $1 ORDER BY repo", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependency subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.DependencySubscription
+	for rows.Next() {
+		subscription := models.DependencySubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &subscription.LastCheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, "SELECT version_key FROM dependency_versions_seen WHERE subscription_id = $1", subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen versions: %v", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan seen version: %v", err)
+		}
+		seen[key] = true
+	}
+
+	return seen, rows.Err()
+}
+
+// MarkDependencyVersionsSeen records announced versions and stamps the
+// subscription as checked, even when keys is empty.
+func (s *Store) MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	for _, key := range keys {
+		query := "INSERT INTO dependency_versions_seen (subscription_id, version_key) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := s.db.ExecContext(ctx, query, subscriptionID, key); err != nil {
+			return fmt.Errorf("failed to record seen version: %v", err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE dependency_subscriptions SET last_checked_at = CURRENT_TIMESTAMP WHERE id = $1", subscriptionID); err != nil {
+		return fmt.Errorf("failed to update last check time: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	state := models.AccountState{ChatID: chatID, Kind: kind, Account: account}
+	var lastCheckedAt, lastNotificationAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT last_checked_at, last_modified, last_notification_at, consecutive_errors, last_error, poll_interval
+		FROM account_state
+		WHERE chat_id = $1 AND kind = $2 AND account = $3
+	`, chatID, kind, account).Scan(&lastCheckedAt, &state.LastModified, &lastNotificationAt, &state.ConsecutiveErrors, &state.LastError, &state.PollInterval)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to get account state: %v", err)
+	}
+
+	state.LastCheckedAt = lastCheckedAt.Time
+	state.LastNotificationAt = lastNotificationAt.Time
+	return state, nil
+}
+
+func (s *Store) SaveAccountState(ctx context.Context, state models.AccountState) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		INSERT INTO account_state (chat_id, kind, account, last_checked_at, last_modified, last_notification_at, consecutive_errors, last_error, poll_interval)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chat_id, kind, account) DO UPDATE
+		SET last_checked_at = $4, last_modified = $5, last_notification_at = $6, consecutive_errors = $7, last_error = $8, poll_interval = $9
+	`
+	_, err := s.db.ExecContext(ctx, query, state.ChatID, state.Kind, state.Account,
+		nullTime(state.LastCheckedAt), state.LastModified, nullTime(state.LastNotificationAt), state.ConsecutiveErrors, state.LastError, state.PollInterval)
+	if err != nil {
+		return fmt.Errorf("failed to save account state: %v", err)
+	}
+
+	return nil
+}
+
+func removeAccountState(ctx context.Context, tx *sql.Tx, chatID int64, kind, account string) error {
+	query := "DELETE FROM account_state WHERE chat_id = $1 AND kind = $2 AND account = $3"
+	if _, err := tx.ExecContext(ctx, query, chatID, kind, account); err != nil {
+		return fmt.Errorf("failed to remove account state: %v", err)
+	}
+	return nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// GetPreferences returns the chat's preferences, or the defaults when none
+// were saved.
+func (s *Store) GetPreferences(ctx context.Context, chatID int64) (models.Preferences, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	preferences := models.DefaultPreferences(chatID)
+	var priorities, renotifyIntervals, teamRepos, triage, changelog, usage, commitMentionRepos []byte
+	var pausedUntil sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT quiet_hours_start, quiet_hours_end, timezone, digest_mode, language, parse_mode, priorities, renotify_intervals,
+			paused, paused_until, poll_window, filter, team_digest, team_repos, mention_sla, triage, summaries, changelog, usage_alerts,
+			commit_mention_repos, digest_time
+		FROM user_preferences
+		WHERE chat_id = $1
+	`, chatID).Scan(&preferences.QuietHoursStart, &preferences.QuietHoursEnd, &preferences.Timezone,
+		&preferences.DigestMode, &preferences.Language, &preferences.ParseMode, &priorities, &renotifyIntervals,
+		&preferences.Paused, &pausedUntil, &preferences.PollWindow, &preferences.Filter, &preferences.TeamDigest, &teamRepos, &preferences.MentionSLA, &triage, &preferences.Summaries, &changelog, &usage,
+		&commitMentionRepos, &preferences.DigestTime)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, fmt.Errorf("failed to get preferences: %v", err)
+	}
+	preferences.PausedUntil = pausedUntil.Time
+
+	if err := json.Unmarshal(priorities, &preferences.Priorities); err != nil {
+		return preferences, fmt.Errorf("failed to decode priorities: %v", err)
+	}
+	if err := json.Unmarshal(renotifyIntervals, &preferences.RenotifyIntervals); err != nil {
+		return preferences, fmt.Errorf("failed to decode renotify intervals: %v", err)
+	}
+	if err := json.Unmarshal(teamRepos, &preferences.TeamRepos); err != nil {
+		return preferences, fmt.Errorf("failed to decode team repositories: %v", err)
+	}
+	if err := json.Unmarshal(triage, &preferences.Triage); err != nil {
+		return preferences, fmt.Errorf("failed to decode triage settings: %v", err)
+	}
+	if err := json.Unmarshal(changelog, &preferences.Changelog); err != nil {
+		return preferences, fmt.Errorf("failed to decode changelog settings: %v", err)
+	}
+	if err := json.Unmarshal(usage, &preferences.Usage); err != nil {
+		return preferences, fmt.Errorf("failed to decode usage alerts: %v", err)
+	}
+	if err := json.Unmarshal(commitMentionRepos, &preferences.CommitMentionRepos); err != nil {
+		return preferences, fmt.Errorf("failed to decode commit mention repositories: %v", err)
+	}
+
+	return preferences, nil
+}
+
+func (s *Store) SetPreferences(ctx context.Context, preferences models.Preferences) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	priorities := preferences.Priorities
+	if priorities == nil {
+		priorities = map[string]string{}
+	}
+	encoded, err := json.Marshal(priorities)
+	if err != nil {
+		return fmt.Errorf("failed to encode priorities: %v", err)
+	}
+	renotifyIntervals := preferences.RenotifyIntervals
+	if renotifyIntervals == nil {
+		renotifyIntervals = map[string]int{}
+	}
+	encodedIntervals, err := json.Marshal(renotifyIntervals)
+	if err != nil {
+		return fmt.Errorf("failed to encode renotify intervals: %v", err)
+	}
+	teamRepos := preferences.TeamRepos
+	if teamRepos == nil {
+		teamRepos = []string{}
+	}
+	encodedRepos, err := json.Marshal(teamRepos)
+	if err != nil {
+		return fmt.Errorf("failed to encode team repositories: %v", err)
+	}
+	encodedTriage, err := json.Marshal(preferences.Triage)
+	if err != nil {
+		return fmt.Errorf("failed to encode triage settings: %v", err)
+	}
+	encodedChangelog, err := json.Marshal(preferences.Changelog)
+	if err != nil {
+		return fmt.Errorf("failed to encode changelog settings: %v", err)
+	}
+	encodedUsage, err := json.Marshal(preferences.Usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage alerts: %v", err)
+	}
+	commitMentionRepos := preferences.CommitMentionRepos
+	if commitMentionRepos == nil {
+		commitMentionRepos = []string{}
+	}
+	encodedCommitMentionRepos, err := json.Marshal(commitMentionRepos)
+	if err != nil {
+		return fmt.Errorf("failed to encode commit mention repositories: %v", err)
+	}
+
+	if err := s.requireUser(ctx, preferences.ChatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_preferences (chat_id, quiet_hours_start, quiet_hours_end, timezone, digest_mode, language, parse_mode, priorities, renotify_intervals,
+			paused, paused_until, poll_window, filter, team_digest, team_repos, mention_sla, triage, summaries, changelog, usage_alerts,
+			commit_mention_repos, digest_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET quiet_hours_start = $2, quiet_hours_end = $3, timezone = $4, digest_mode = $5,
+			language = $6, parse_mode = $7, priorities = $8, renotify_intervals = $9,
+			paused = $10, paused_until = $11, poll_window = $12, filter = $13,
+			team_digest = $14, team_repos = $15, mention_sla = $16, triage = $17, summaries = $18, changelog = $19, usage_alerts = $20,
+			commit_mention_repos = $21, digest_time = $22
+	`
+	_, err = s.db.ExecContext(ctx, query, preferences.ChatID, preferences.QuietHoursStart, preferences.QuietHoursEnd,
+		preferences.Timezone, preferences.DigestMode, preferences.Language, preferences.ParseMode, string(encoded), string(encodedIntervals),
+		preferences.Paused, nullTime(preferences.PausedUntil), preferences.PollWindow, preferences.Filter, preferences.TeamDigest, string(encodedRepos), preferences.MentionSLA,
+		string(encodedTriage), preferences.Summaries, string(encodedChangelog), string(encodedUsage), string(encodedCommitMentionRepos), preferences.DigestTime)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %v", err)
+	}
+
+	return nil
+}
+
+// AddRepoSubscription creates the subscription or replaces the existing
+// one for the same repository.
+func (s *Store) AddRepoSubscription(ctx context.Context, subscription models.RepoSubscription) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+
+	eventTypes := subscription.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	encodedEventTypes, err := json.Marshal(eventTypes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event types: %v", err)
+	}
+	encodedFilters, err := json.Marshal(subscription.Filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode filters: %v", err)
+	}
+
+	if err := s.requireUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	query := `
+		INSERT INTO repo_subscriptions (chat_id, repo, event_types, filters, destination_chat_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, repo) DO UPDATE
+		SET event_types = $3, filters = $4, destination_chat_id = $5
+		RETURNING id
+	`
+	err = s.db.QueryRowContext(ctx, query, subscription.ChatID, subscription.Repo, string(encodedEventTypes),
+		string(encodedFilters), subscription.DestinationChatID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save repository subscription: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemoveRepoSubscription(ctx context.Context, chatID int64, repo string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM repo_subscriptions WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove repository subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetRepoSubscriptions(ctx context.Context, chatID int64) ([]models.RepoSubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, repo, event_types, filters, destination_chat_id, created_at
+		FROM repo_subscriptions
+		WHERE chat_id = $1
+		ORDER BY repo
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query repository subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.RepoSubscription
+	for rows.Next() {
+		subscription := models.RepoSubscription{ChatID: chatID}
+		var eventTypes, filters []byte
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &eventTypes, &filters, &subscription.DestinationChatID, &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan repository subscription: %v", err)
+		}
+		if err := json.Unmarshal(eventTypes, &subscription.EventTypes); err != nil {
+			return nil, fmt.Errorf("failed to decode event types: %v", err)
+		}
+		if err := json.Unmarshal(filters, &subscription.Filters); err != nil {
+			return nil, fmt.Errorf("failed to decode filters: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// AddPathSubscription creates the subscription, or returns the ID of the
+// existing one for the same repository and pattern.
+func (s *Store) AddPathSubscription(ctx context.Context, subscription models.PathSubscription) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+	if err := s.requireUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	query := `
+		INSERT INTO path_subscriptions (chat_id, repo, pattern)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, repo, pattern) DO UPDATE SET pattern = EXCLUDED.pattern
+		RETURNING id
+	`
+	if err := s.db.QueryRowContext(ctx, query, subscription.ChatID, subscription.Repo, subscription.Pattern).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to save path subscription: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemovePathSubscription(ctx context.Context, chatID int64, repo, pattern string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM path_subscriptions WHERE chat_id = $1 AND repo = $2 AND pattern = $3 AND ($4 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $4))", chatID, repo, pattern, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove path subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetPathSubscriptions(ctx context.Context, chatID int64) ([]models.PathSubscription, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, repo, pattern, created_at
+		FROM path_subscriptions
+		WHERE chat_id = $1
+		ORDER BY repo, pattern
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query path subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.PathSubscription
+	for rows.Next() {
+		subscription := models.PathSubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &subscription.Pattern, &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan path subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) AddRoutingRule(ctx context.Context, rule models.RoutingRule) (int64, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, rule.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO routing_rules (chat_id, notification_type, repo, label, keyword, severity, destination_chat_id, silent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, rule.ChatID, rule.Type, rule.Repo, rule.Label, rule.Keyword, rule.Severity, rule.DestinationChatID, rule.Silent).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save routing rule: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemoveRoutingRule(ctx context.Context, chatID, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM routing_rules WHERE chat_id = $1 AND id = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove routing rule: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrRuleNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) GetRoutingRules(ctx context.Context, chatID int64) ([]models.RoutingRule, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, notification_type, repo, label, keyword, severity, destination_chat_id, silent, created_at
+		FROM routing_rules
+		WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))
+		ORDER BY id
+	`, chatID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routing rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []models.RoutingRule
+	for rows.Next() {
+		rule := models.RoutingRule{ChatID: chatID}
+		if err := rows.Scan(&rule.ID, &rule.Type, &rule.Repo, &rule.Label, &rule.Keyword, &rule.Severity,
+			&rule.DestinationChatID, &rule.Silent, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *Store) RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, request.ChatID); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO review_requests (chat_id, account, repo, url, requested_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, account, url) WHERE reviewed_at IS NULL DO NOTHING
+	`, request.ChatID, request.Account, request.Repo, request.URL, request.RequestedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetReviewRequests(ctx context.Context, chatID int64, since time.Time) ([]models.ReviewRequest, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account, repo, url, requested_at, reviewed_at
+		FROM review_requests
+		WHERE chat_id = $1 AND (reviewed_at IS NULL OR requested_at >= $2)
+		ORDER BY requested_at, id
+	`, chatID, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review requests: %v", err)
+	}
+	defer rows.Close()
+
+	var requests []models.ReviewRequest
+	for rows.Next() {
+		request := models.ReviewRequest{ChatID: chatID}
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&request.ID, &request.Account, &request.Repo, &request.URL, &request.RequestedAt, &reviewedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan review request: %v", err)
+		}
+		request.ReviewedAt = reviewedAt.Time
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+func (s *Store) CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE review_requests SET reviewed_at = $2 WHERE id = $1", id, reviewedAt); err != nil {
+		return fmt.Errorf("failed to complete review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveReviewRequest(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM review_requests WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to remove review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RecordMention(ctx context.Context, mention models.Mention) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, mention.ChatID); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO mentions (chat_id, account, repo, message, url, mentioned_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id, account, url) DO NOTHING
+	`, mention.ChatID, mention.Account, mention.Repo, mention.Message, mention.URL, mention.MentionedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save mention: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetMentions(ctx context.Context, chatID int64) ([]models.Mention, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account, repo, message, url, mentioned_at
+		FROM mentions
+		WHERE chat_id = $1
+		ORDER BY mentioned_at, id
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mentions: %v", err)
+	}
+	defer rows.Close()
+
+	var mentions []models.Mention
+	for rows.Next() {
+		mention := models.Mention{ChatID: chatID}
+		if err := rows.Scan(&mention.ID, &mention.Account, &mention.Repo, &mention.Message, &mention.URL, &mention.MentionedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %v", err)
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+func (s *Store) RemoveMention(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM mentions WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to remove mention: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) MuteRepo(ctx context.Context, chatID int64, repo string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO muted_repos (chat_id, repo) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := s.db.ExecContext(ctx, query, chatID, repo); err != nil {
+		return fmt.Errorf("failed to mute repository: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UnmuteRepo(ctx context.Context, chatID int64, repo string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM muted_repos WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID); err != nil {
+		return fmt.Errorf("failed to unmute repository: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) requireUser(ctx context.Context, chatID int64) error {
+	var exists bool
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := "SELECT EXISTS(SELECT 1 FROM users WHERE chat_id = $1 AND deleted_at IS NULL AND ($2 = '' OR tenant_id = $2))"
+	if err := s.db.QueryRowContext(ctx, query, chatID, tenantID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check user: %v", err)
+	}
+	if !exists {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var lastNotification time.Time
+	err := s.stmts.shouldNotify.QueryRowContext(ctx, chatID, itemURL, contentHash).Scan(&lastNotification)
+
+	if err == sql.ErrNoRows {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to query notification: %v", err)
+	}
+
+	if renotifyInterval < 0 {
+		return false, nil
+	}
+	return time.Since(lastNotification) > renotifyInterval, nil
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.stmts.recordNotification.ExecContext(ctx, chatID, itemURL, notificationType, contentHash, "")
+
+	if err != nil {
+		return fmt.Errorf("failed to record notification: %v", err)
+	}
+
+	return nil
+}
+
+// EnqueueNotification writes the notification to the outbox and records it
+// as sent in the same transaction, so it is neither lost nor queued twice
+// when the process restarts mid-cycle. The renotify check is repeated
+// under a transaction-scoped advisory lock on the notification, so
+// concurrent workers that both passed ShouldNotify queue it only once; it
+// reports whether this call queued it.
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	lockKey := fmt.Sprintf("%d:%s:%s", chatID, notification.URL, contentHash)
+	if _, err := s.stmts.lockNotification.Tx(ctx, tx).ExecContext(ctx, lockKey); err != nil {
+		return false, fmt.Errorf("failed to lock notification: %v", err)
+	}
+
+	// Checked on the primary, a replica may not have the other worker's
+	// record yet.
+	var recent bool
+	err = s.stmts.recentNotification.Tx(ctx, tx).QueryRowContext(ctx, chatID, notification.URL, contentHash, renotifyInterval.Seconds()).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("failed to query notification: %v", err)
+	}
+	if recent {
+		return false, nil
+	}
+
+	labels := notification.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	encodedLabels, err := json.Marshal(labels)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode labels: %v", err)
+	}
+	encodedFields, err := json.Marshal(notification.Fields)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode fields: %v", err)
+	}
+	_, err = s.stmts.enqueueNotification.Tx(ctx, tx).ExecContext(ctx, chatID, notification.Type, notification.Repo, notification.Message, notification.URL, nullTime(notification.OccurredAt),
+		notification.EventID, notification.Severity, string(encodedLabels), notification.ThreadID, notification.Account, string(encodedFields))
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue notification: %v", err)
+	}
+
+	_, err = s.stmts.recordNotification.Tx(ctx, tx).ExecContext(ctx, chatID, notification.URL, notification.Type, contentHash, notification.EventID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record notification: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return true, nil
+}
+
+// ClaimOutbox returns up to limit entries that are due for delivery and
+// hides them from other claims for the lease duration.
+func (s *Store) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := s.stmts.claimOutbox.QueryContext(ctx, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		var occurredAt sql.NullTime
+		var labels, fields []byte
+		n := &entry.Notification
+		if err := rows.Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &occurredAt, &n.EventID, &n.Severity, &labels, &n.ThreadID, &n.Account, &entry.Attempts, &entry.CreatedAt, &fields); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %v", err)
+		}
+		if err := json.Unmarshal(labels, &n.Labels); err != nil {
+			return nil, fmt.Errorf("failed to decode labels: %v", err)
+		}
+		if err := json.Unmarshal(fields, &n.Fields); err != nil {
+			return nil, fmt.Errorf("failed to decode fields: %v", err)
+		}
+		n.OccurredAt = occurredAt.Time
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox entries: %v", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func (s *Store) MarkOutboxSent(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE notification_outbox SET sent_at = CURRENT_TIMESTAMP, last_error = '' WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as sent: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxRetry(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE notification_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, lastError, nextAttempt); err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeferOutbox(ctx context.Context, id int64, until time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE notification_outbox SET attempts = GREATEST(attempts - 1, 0), next_attempt_at = $2 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, until); err != nil {
+		return fmt.Errorf("failed to defer outbox entry: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) CountPendingOutbox(ctx context.Context) (int, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var count int
+	query := "SELECT COUNT(*) FROM notification_outbox WHERE sent_at IS NULL AND dead_at IS NULL"
+	if err := s.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox entries: %v", err)
+	}
+	return count, nil
+}
+
+func (s *Store) MarkOutboxDead(ctx context.Context, id int64, lastError string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := "UPDATE notification_outbox SET last_error = $2, dead_at = CURRENT_TIMESTAMP WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, lastError); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as dead: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetOutbox(ctx context.Context, query store.OutboxQuery) ([]models.OutboxEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	switch query.State {
+	case "", models.OutboxPending, models.OutboxSent, models.OutboxDead:
+	default:
+		return nil, fmt.Errorf("invalid outbox state %q", query.State)
+	}
+
+	sqlQuery := `
+		SELECT o.id, o.chat_id, u.tenant_id, o.notification_type, o.repo, o.message, o.item_url, o.event_id, o.severity,
+			o.attempts, o.last_error, o.next_attempt_at, o.sent_at, o.dead_at, o.created_at
+		FROM notification_outbox o
+		JOIN users u ON u.chat_id = o.chat_id
+		WHERE ($1 = 0 OR o.chat_id = $1)
+			AND ($2 = ''
+				OR ($2 = 'pending' AND o.sent_at IS NULL AND o.dead_at IS NULL)
+				OR ($2 = 'sent' AND o.sent_at IS NOT NULL)
+				OR ($2 = 'dead' AND o.dead_at IS NOT NULL))
+			AND ($5 = '' OR u.tenant_id = $5)
+		ORDER BY o.id DESC
+		LIMIT $3 OFFSET $4
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.reader.QueryContext(ctx, sqlQuery, query.ChatID, query.State, query.Limit, query.Offset, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		var sentAt, deadAt sql.NullTime
+		n := &entry.Notification
+		if err := rows.Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &n.EventID, &n.Severity,
+			&entry.Attempts, &entry.LastError, &entry.NextAttemptAt, &sentAt, &deadAt, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %v", err)
+		}
+		entry.SentAt = sentAt.Time
+		entry.DeadAt = deadAt.Time
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *Store) GetOutboxEntry(ctx context.Context, id int64) (models.OutboxEntry, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	// Read from the primary, as buttons can be pressed right after the
+	// entry is delivered.
+	query := `
+		SELECT o.id, o.chat_id, u.tenant_id, o.notification_type, o.repo, o.message, o.item_url, o.occurred_at, o.event_id, o.severity,
+			o.labels, o.thread_id, o.account, o.attempts, o.last_error, o.next_attempt_at, o.sent_at, o.dead_at, o.created_at, o.fields
+		FROM notification_outbox o
+		JOIN users u ON u.chat_id = o.chat_id
+		WHERE o.id = $1 AND ($2 = '' OR u.tenant_id = $2)
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	var entry models.OutboxEntry
+	var occurredAt, sentAt, deadAt sql.NullTime
+	var labels, fields []byte
+	n := &entry.Notification
+	err := s.db.QueryRowContext(ctx, query, id, tenantID).Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &occurredAt, &n.EventID, &n.Severity,
+		&labels, &n.ThreadID, &n.Account, &entry.Attempts, &entry.LastError, &entry.NextAttemptAt, &sentAt, &deadAt, &entry.CreatedAt, &fields)
+	if err == sql.ErrNoRows {
+		return models.OutboxEntry{}, store.ErrOutboxNotFound
+	}
+	if err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to get outbox entry: %v", err)
+	}
+	if err := json.Unmarshal(labels, &n.Labels); err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to decode labels: %v", err)
+	}
+	if err := json.Unmarshal(fields, &n.Fields); err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to decode fields: %v", err)
+	}
+	n.OccurredAt = occurredAt.Time
+	entry.SentAt = sentAt.Time
+	entry.DeadAt = deadAt.Time
+	return entry, nil
+}
+
+func (s *Store) RequeueOutbox(ctx context.Context, id int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	query := `
+		UPDATE notification_outbox o
+		SET attempts = 0, dead_at = NULL, next_attempt_at = CURRENT_TIMESTAMP
+		FROM users u
+		WHERE o.id = $1 AND o.dead_at IS NOT NULL AND u.chat_id = o.chat_id
+			AND ($2 = '' OR u.tenant_id = $2)
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, query, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue outbox entry: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrOutboxNotFound
+	}
+	return nil
+}
+
+func (s *Store) CleanOldNotifications(ctx context.Context, before time.Time) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM sent_notifications n
+		WHERE n.created_at < $1 AND NOT EXISTS(
+			SELECT 1 FROM user_preferences p
+			WHERE p.chat_id = n.chat_id AND p.renotify_intervals->>n.notification_type IS NOT NULL
+				AND ((p.renotify_intervals->>n.notification_type)::int < 0
+					OR n.created_at > CURRENT_TIMESTAMP - make_interval(hours => (p.renotify_intervals->>n.notification_type)::int))
+		)
+	`, before)
+
+	if err != nil {
+		return fmt.Errorf("failed to clean old notifications: %v", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM notification_outbox
+		WHERE (sent_at IS NOT NULL OR dead_at IS NOT NULL) AND created_at < $1
+	`, before)
+	if err != nil {
+		return fmt.Errorf("failed to clean outbox: %v", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM review_requests
+		WHERE reviewed_at IS NOT NULL AND requested_at < $1
+	`, before)
+	if err != nil {
+		return fmt.Errorf("failed to clean review requests: %v", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given
+// time, together with all their data.
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the users first, so one that is restored concurrently keeps its
+	// history.
+	if _, err := tx.ExecContext(ctx, "SELECT chat_id FROM users WHERE deleted_at < $1 FOR UPDATE", before); err != nil {
+		return 0, fmt.Errorf("failed to lock deleted users: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM sent_notifications
+		WHERE chat_id IN (SELECT chat_id FROM users WHERE deleted_at < $1)
+	`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove notification history: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge users: %v", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return purged, nil
+}
+
+func (s *Store) GetNotificationHistory(ctx context.Context, query store.HistoryQuery) ([]models.NotificationRecord, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	sqlQuery := `
+		SELECT id, chat_id, item_url, notification_type, content_hash, created_at, event_id
+		FROM sent_notifications
+		WHERE chat_id = $1
+			AND ($2 = '' OR notification_type = $2)
+			AND created_at >= $3
+			AND ($6 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $6))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.reader.QueryContext(ctx, sqlQuery, query.ChatID, query.Type, query.Since, query.Limit, query.Offset, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification history: %v", err)
+	}
+	defer rows.Close()
+
+	var records []models.NotificationRecord
+	for rows.Next() {
+		var record models.NotificationRecord
+		if err := rows.Scan(&record.ID, &record.ChatID, &record.ItemURL, &record.NotificationType, &record.ContentHash, &record.CreatedAt, &record.EventID); err != nil {
+			return nil, fmt.Errorf("failed to scan notification record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (s *Store) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate calendar token: %v", err)
+	}
+
+	// Concurrent callers all get the token that was stored first.
+	var token string
+	query := "UPDATE users SET calendar_token = COALESCE(calendar_token, $1) WHERE chat_id = $2 RETURNING calendar_token"
+	err := s.db.QueryRowContext(ctx, query, hex.EncodeToString(buf), chatID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", store.ErrUserNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to store calendar token: %v", err)
+	}
+
+	return token, nil
+}
+
+func (s *Store) GetUserByCalendarToken(ctx context.Context, token string) (*models.User, bool) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	var chatID int64
+	err := s.db.QueryRowContext(ctx, "SELECT chat_id FROM users WHERE calendar_token = $1", token).Scan(&chatID)
+	if err != nil {
+		return nil, false
+	}
+
+	return s.GetUser(ctx, chatID)
+}
+
+func (s *Store) SetJiraConfig(ctx context.Context, config models.JiraConfig) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, config.ChatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO jira_configs (chat_id, base_url, email, api_token, project_key, issue_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET base_url = $2, email = $3, api_token = $4, project_key = $5, issue_type = $6
+	`
+	_, err := s.db.ExecContext(ctx, query, config.ChatID, config.BaseURL, config.Email, config.APIToken, config.ProjectKey, config.IssueType)
+	if err != nil {
+		return fmt.Errorf("failed to save Jira config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetJiraConfig(ctx context.Context, chatID int64) (*models.JiraConfig, bool) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	config := &models.JiraConfig{ChatID: chatID}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT base_url, email, api_token, project_key, issue_type
+		FROM jira_configs
+		WHERE chat_id = $1
+	`, chatID).Scan(&config.BaseURL, &config.Email, &config.APIToken, &config.ProjectKey, &config.IssueType)
+	if err != nil {
+		return nil, false
+	}
+
+	return config, true
+}
+
+func (s *Store) RemoveJiraConfig(ctx context.Context, chatID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM jira_configs WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))", chatID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Jira config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) SetLinearConfig(ctx context.Context, chatID int64, apiKey string, target models.LinearTarget) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO linear_configs (chat_id, api_key, team_id, project_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id) DO UPDATE SET api_key = $2, team_id = $3, project_id = $4
+	`
+	if _, err := s.db.ExecContext(ctx, query, chatID, apiKey, target.TeamID, target.ProjectID); err != nil {
+		return fmt.Errorf("failed to save Linear config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetLinearConfig(ctx context.Context, chatID int64) (*models.LinearConfig, bool) {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	config := &models.LinearConfig{
+		ChatID:   chatID,
+		Mappings: make(map[string]models.LinearTarget),
+	}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT api_key, team_id, project_id
+		FROM linear_configs
+		WHERE chat_id = $1
+	`, chatID).Scan(&config.APIKey, &config.Default.TeamID, &config.Default.ProjectID)
+	if err != nil {
+		return nil, false
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT repo, team_id, project_id FROM linear_mappings WHERE chat_id = $1", chatID)
+	if err != nil {
+		return config, true
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var repo string
+		var target models.LinearTarget
+		if err := rows.Scan(&repo, &target.TeamID, &target.ProjectID); err != nil {
+			continue
+		}
+		config.Mappings[repo] = target
+	}
+
+	return config, true
+}
+
+func (s *Store) RemoveLinearConfig(ctx context.Context, chatID int64) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM linear_configs WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))", chatID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Linear config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) SetLinearMapping(ctx context.Context, chatID int64, repo string, target models.LinearTarget) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		INSERT INTO linear_mappings (chat_id, repo, team_id, project_id)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM linear_configs WHERE chat_id = $1 AND ($5 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $5)))
+		ON CONFLICT (chat_id, repo) DO UPDATE SET team_id = $3, project_id = $4
+	`
+	result, err := s.db.ExecContext(ctx, query, chatID, repo, target.TeamID, target.ProjectID, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to save Linear mapping: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("Linear is not configured")
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveLinearMapping(ctx context.Context, chatID int64, repo string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM linear_mappings WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Linear mapping: %v", err)
 	}
 
 	return nil
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/storetest"
+)
+
+// testDatabaseURL returns the database the tests run against, which they
+// wipe. Without TEST_DATABASE_URL they are skipped.
+func testDatabaseURL(tb testing.TB) string {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+	return url
+}
+
+// newTestStore returns a store on an empty, freshly migrated schema.
+func newTestStore(tb testing.TB) *Store {
+	return openTestStore(tb, testDatabaseURL(tb))
+}
+
+// openTestStore is newTestStore connecting with url, which must point to
+// the test database.
+func openTestStore(tb testing.TB, url string) *Store {
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		tb.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+	for _, query := range []string{"DROP SCHEMA public CASCADE", "CREATE SCHEMA public"} {
+		if _, err := db.ExecContext(context.Background(), query); err != nil {
+			tb.Fatalf("failed to reset schema: %v", err)
+		}
+	}
+
+	s, err := New(url, PoolConfig{}, true)
+	if err != nil {
+		tb.Fatalf("failed to open store: %v", err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	testDatabaseURL(t)
+	storetest.Run(t, func(t *testing.T) store.Store { return newTestStore(t) })
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// lockTTL is how long a lock outlives its last Check. SQLite has no
+// sessions to tie locks to, so locks are leases that a crashed instance
+// loses when they expire, while a live leader keeps renewing them.
+const lockTTL = 30 * time.Second
+
+var _ store.Locker = (*Store)(nil)
+
+// Lock is a lease on a row of leader_locks, held by one store.
+type Lock struct {
+	store *Store
+	id    int32
+}
+
+// TryLock takes the lock with the given ID without waiting. It returns nil
+// when another store holds an unexpired lease on it.
+func (s *Store) TryLock(ctx context.Context, id int32) (store.Lock, error) {
+	now := time.Now()
+	query := `
+		INSERT INTO leader_locks (id, holder, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leader_locks.holder = excluded.holder OR leader_locks.expires_at < $4
+	`
+	result, err := s.db.ExecContext(ctx, query, id, s.holder, timestamp(now.Add(lockTTL)), timestamp(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to take lock: %v", err)
+	}
+	acquired, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to take lock: %v", err)
+	}
+	if acquired == 0 {
+		return nil, nil
+	}
+
+	return &Lock{store: s, id: id}, nil
+}
+
+// Check verifies that the lock is still held and renews its lease. It
+// fails when the lease expired and another store took the lock.
+func (l *Lock) Check(ctx context.Context) error {
+	query := `UPDATE leader_locks SET expires_at = $3 WHERE id = $1 AND holder = $2`
+	result, err := l.store.db.ExecContext(ctx, query, l.id, l.store.holder, timestamp(time.Now().Add(lockTTL)))
+	if err != nil {
+		return fmt.Errorf("failed to renew lock %d: %v", l.id, err)
+	}
+	renewed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to renew lock %d: %v", l.id, err)
+	}
+	if renewed == 0 {
+		return fmt.Errorf("lost lock %d", l.id)
+	}
+	return nil
+}
+
+// Release gives up the lock.
+func (l *Lock) Release(ctx context.Context) error {
+	query := `DELETE FROM leader_locks WHERE id = $1 AND holder = $2`
+	if _, err := l.store.db.ExecContext(ctx, query, l.id, l.store.holder); err != nil {
+		return fmt.Errorf("failed to release lock: %v", err)
+	}
+	return nil
+}
+
+// LockHeld reports whether any store holds an unexpired lease on the lock
+// with the given ID.
+func (s *Store) LockHeld(ctx context.Context, id int32) (bool, error) {
+	var held bool
+	query := `SELECT EXISTS (SELECT 1 FROM leader_locks WHERE id = $1 AND expires_at >= $2)`
+	if err := s.db.QueryRowContext(ctx, query, id, timestamp(time.Now())).Scan(&held); err != nil {
+		return false, fmt.Errorf("failed to look up lock: %v", err)
+	}
+	return held, nil
+}
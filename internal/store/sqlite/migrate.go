@@ -0,0 +1,324 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// nowDefault is the default of timestamp columns, the current time in
+// timeFormat. SQLite has millisecond precision, padded to nanoseconds.
+const nowDefault = "(strftime('%Y-%m-%d %H:%M:%f000000', 'now'))"
+
+// migrations is the schema, oldest first, each applied in a transaction
+// that sets PRAGMA user_version to its index plus one. Never change an
+// applied migration; append a new one instead. A SQLite database serves a
+// single instance, so there is no need for expand and contract phases.
+var migrations = [][]string{
+	{
+		`CREATE TABLE users (
+			chat_id INTEGER PRIMARY KEY,
+			calendar_token TEXT UNIQUE,
+			deleted_at TIMESTAMP,
+			tenant_id TEXT NOT NULL DEFAULT 'default'
+		)`,
+		`CREATE INDEX idx_users_tenant ON users(tenant_id)`,
+		`CREATE TABLE tenants (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			bot_token TEXT NOT NULL,
+			api_token TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `
+		)`,
+		`CREATE TABLE feature_flags (
+			name TEXT PRIMARY KEY,
+			enabled BOOLEAN NOT NULL DEFAULT false,
+			percent INTEGER NOT NULL DEFAULT 0,
+			chat_ids TEXT NOT NULL DEFAULT '[]',
+			updated_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `
+		)`,
+		`CREATE TABLE github_accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER REFERENCES users(chat_id),
+			username TEXT NOT NULL,
+			token TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT true,
+			scopes TEXT NOT NULL DEFAULT '[]',
+			expires_at TIMESTAMP,
+			note TEXT NOT NULL DEFAULT '',
+			added_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `,
+			UNIQUE(chat_id, username)
+		)`,
+		`CREATE TABLE account_preferences (
+			chat_id INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			disabled_reasons TEXT NOT NULL DEFAULT '[]',
+			security_alerts BOOLEAN NOT NULL DEFAULT false,
+			PRIMARY KEY (chat_id, username),
+			FOREIGN KEY (chat_id, username) REFERENCES github_accounts(chat_id, username) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE gerrit_accounts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id),
+			base_url TEXT NOT NULL,
+			username TEXT NOT NULL,
+			password TEXT NOT NULL,
+			is_active BOOLEAN DEFAULT true,
+			UNIQUE(chat_id, base_url, username)
+		)`,
+		`CREATE TABLE sent_notifications (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id),
+			item_url TEXT NOT NULL,
+			notification_type TEXT NOT NULL,
+			content_hash TEXT NOT NULL,
+			created_at TIMESTAMP DEFAULT ` + nowDefault + `,
+			event_id TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX idx_notifications_chat_url_type
+			ON sent_notifications(chat_id, item_url, notification_type, content_hash)`,
+		`CREATE TABLE muted_repos (
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			repo TEXT NOT NULL,
+			PRIMARY KEY (chat_id, repo)
+		)`,
+		`CREATE TABLE jira_configs (
+			chat_id INTEGER PRIMARY KEY REFERENCES users(chat_id) ON DELETE CASCADE,
+			base_url TEXT NOT NULL,
+			email TEXT NOT NULL,
+			api_token TEXT NOT NULL,
+			project_key TEXT NOT NULL,
+			issue_type TEXT NOT NULL DEFAULT 'Task'
+		)`,
+		`CREATE TABLE linear_configs (
+			chat_id INTEGER PRIMARY KEY REFERENCES users(chat_id) ON DELETE CASCADE,
+			api_key TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			project_id TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE linear_mappings (
+			chat_id INTEGER NOT NULL REFERENCES linear_configs(chat_id) ON DELETE CASCADE,
+			repo TEXT NOT NULL,
+			team_id TEXT NOT NULL,
+			project_id TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (chat_id, repo)
+		)`,
+		`CREATE TABLE image_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			image TEXT NOT NULL,
+			tag_filter TEXT NOT NULL DEFAULT '',
+			UNIQUE(chat_id, image)
+		)`,
+		`CREATE TABLE image_tags_seen (
+			subscription_id INTEGER NOT NULL REFERENCES image_subscriptions(id) ON DELETE CASCADE,
+			tag TEXT NOT NULL,
+			PRIMARY KEY (subscription_id, tag)
+		)`,
+		`CREATE TABLE dependency_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			repo TEXT NOT NULL,
+			last_checked_at TIMESTAMP DEFAULT ` + nowDefault + `,
+			UNIQUE(chat_id, repo)
+		)`,
+		`CREATE TABLE dependency_versions_seen (
+			subscription_id INTEGER NOT NULL REFERENCES dependency_subscriptions(id) ON DELETE CASCADE,
+			version_key TEXT NOT NULL,
+			PRIMARY KEY (subscription_id, version_key)
+		)`,
+		`CREATE TABLE account_state (
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			kind TEXT NOT NULL,
+			account TEXT NOT NULL,
+			last_checked_at TIMESTAMP,
+			last_modified TEXT NOT NULL DEFAULT '',
+			last_notification_at TIMESTAMP,
+			consecutive_errors INTEGER NOT NULL DEFAULT 0,
+			poll_interval INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY (chat_id, kind, account)
+		)`,
+		`CREATE TABLE notification_outbox (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			notification_type TEXT NOT NULL,
+			repo TEXT NOT NULL DEFAULT '',
+			message TEXT NOT NULL,
+			item_url TEXT NOT NULL,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT NOT NULL DEFAULT '',
+			next_attempt_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `,
+			sent_at TIMESTAMP,
+			dead_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `,
+			occurred_at TIMESTAMP,
+			event_id TEXT NOT NULL DEFAULT '',
+			severity TEXT NOT NULL DEFAULT '',
+			labels TEXT NOT NULL DEFAULT '[]',
+			thread_id TEXT NOT NULL DEFAULT '',
+			account TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX idx_outbox_pending
+			ON notification_outbox(next_attempt_at) WHERE sent_at IS NULL AND dead_at IS NULL`,
+		`CREATE TABLE user_preferences (
+			chat_id INTEGER PRIMARY KEY REFERENCES users(chat_id) ON DELETE CASCADE,
+			quiet_hours_start TEXT NOT NULL DEFAULT '',
+			quiet_hours_end TEXT NOT NULL DEFAULT '',
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			digest_mode TEXT NOT NULL DEFAULT 'off',
+			language TEXT NOT NULL DEFAULT 'en',
+			parse_mode TEXT NOT NULL DEFAULT 'MarkdownV2',
+			priorities TEXT NOT NULL DEFAULT '{}',
+			renotify_intervals TEXT NOT NULL DEFAULT '{}',
+			paused BOOLEAN NOT NULL DEFAULT false,
+			paused_until TIMESTAMP,
+			poll_window TEXT NOT NULL DEFAULT '',
+			filter TEXT NOT NULL DEFAULT '',
+			team_digest TEXT NOT NULL DEFAULT '',
+			team_repos TEXT NOT NULL DEFAULT '[]',
+			mention_sla INTEGER NOT NULL DEFAULT 0,
+			triage TEXT NOT NULL DEFAULT '{}',
+			summaries BOOLEAN NOT NULL DEFAULT false,
+			changelog TEXT NOT NULL DEFAULT '{}',
+			usage_alerts TEXT NOT NULL DEFAULT '{}',
+			commit_mention_repos TEXT NOT NULL DEFAULT '[]',
+			digest_time TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE TABLE repo_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			repo TEXT NOT NULL,
+			event_types TEXT NOT NULL DEFAULT '[]',
+			filters TEXT NOT NULL DEFAULT '{}',
+			destination_chat_id INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `,
+			UNIQUE(chat_id, repo)
+		)`,
+		`CREATE TABLE path_subscriptions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			repo TEXT NOT NULL,
+			pattern TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `,
+			UNIQUE(chat_id, repo, pattern)
+		)`,
+		`CREATE TABLE routing_rules (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			notification_type TEXT NOT NULL DEFAULT '',
+			repo TEXT NOT NULL DEFAULT '',
+			label TEXT NOT NULL DEFAULT '',
+			keyword TEXT NOT NULL DEFAULT '',
+			severity TEXT NOT NULL DEFAULT '',
+			destination_chat_id INTEGER NOT NULL DEFAULT 0,
+			silent BOOLEAN NOT NULL DEFAULT false,
+			created_at TIMESTAMP NOT NULL DEFAULT ` + nowDefault + `
+		)`,
+		`CREATE INDEX idx_routing_rules_chat ON routing_rules(chat_id)`,
+		`CREATE TABLE review_requests (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			account TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			url TEXT NOT NULL,
+			requested_at TIMESTAMP NOT NULL,
+			reviewed_at TIMESTAMP
+		)`,
+		`CREATE INDEX idx_review_requests_chat ON review_requests(chat_id, requested_at)`,
+		`CREATE UNIQUE INDEX idx_review_requests_pending ON review_requests(chat_id, account, url) WHERE reviewed_at IS NULL`,
+		`CREATE TABLE mentions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chat_id INTEGER NOT NULL REFERENCES users(chat_id) ON DELETE CASCADE,
+			account TEXT NOT NULL,
+			repo TEXT NOT NULL,
+			message TEXT NOT NULL,
+			url TEXT NOT NULL,
+			mentioned_at TIMESTAMP NOT NULL,
+			UNIQUE (chat_id, account, url)
+		)`,
+	},
+	{
+		`ALTER TABLE notification_outbox ADD COLUMN fields TEXT NOT NULL DEFAULT '{}'`,
+	},
+	{
+		`CREATE TABLE leader_locks (
+			id INTEGER PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		)`,
+	},
+}
+
+// SchemaStatus compares the schema version of the database with the one
+// this version creates.
+type SchemaStatus struct {
+	// Version is the number of applied migrations, 0 for an empty file.
+	Version int
+	// Latest is the number of migrations this version knows.
+	Latest int
+}
+
+// Check returns an error when this version cannot serve on the schema.
+func (st SchemaStatus) Check() error {
+	switch {
+	case st.Version < st.Latest:
+		return fmt.Errorf("schema version %d is behind %d, run 'monitor migrate' or enable MIGRATE_ON_START", st.Version, st.Latest)
+	case st.Version > st.Latest:
+		return fmt.Errorf("schema version %d is newer than this version, which knows up to %d", st.Version, st.Latest)
+	}
+	return nil
+}
+
+// ReadSchemaStatus reports the schema version of the database without
+// migrating it.
+func ReadSchemaStatus(dbURL string) (SchemaStatus, error) {
+	db, err := openDB(dbURL)
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	defer db.Close()
+	return schemaStatus(context.Background(), db)
+}
+
+func schemaStatus(ctx context.Context, db *sql.DB) (SchemaStatus, error) {
+	status := SchemaStatus{Latest: len(migrations)}
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&status.Version); err != nil {
+		return status, fmt.Errorf("failed to read schema version: %v", err)
+	}
+	return status, nil
+}
+
+// applyMigrations applies the migrations the database is missing. The
+// transactions take the write lock first, so two processes starting on
+// the same file apply each migration once.
+func applyMigrations(ctx context.Context, db *sql.DB) error {
+	for {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		var version int
+		if err := tx.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if version >= len(migrations) {
+			return tx.Rollback()
+		}
+
+		for _, statement := range migrations[version] {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("migration %d: %v", version+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %d: %v", version+1, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package sqlite
+
+const shouldNotifyQuery = `
+	SELECT created_at
+	FROM sent_notifications
+	WHERE chat_id = $1 AND item_url = $2 AND content_hash = $3
+	ORDER BY created_at DESC
+	LIMIT 1
+`
+
+const recordNotificationQuery = `
+	INSERT INTO sent_notifications (chat_id, item_url, notification_type, content_hash, event_id)
+	VALUES ($1, $2, $3, $4, $5)
+`
+
+// recentNotificationQuery reports whether the notification was recorded
+// after $5, or ever when $4 is set for a negative renotify interval.
+const recentNotificationQuery = `
+	SELECT EXISTS(
+		SELECT 1 FROM sent_notifications
+		WHERE chat_id = $1 AND item_url = $2 AND content_hash = $3
+			AND ($4 OR created_at > $5)
+	)
+`
+
+const enqueueNotificationQuery = `
+	INSERT INTO notification_outbox (chat_id, notification_type, repo, message, item_url, occurred_at, event_id, severity, labels, thread_id, account, fields)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
+// claimOutboxQuery leases up to $1 entries due at $3 until $2. A single
+// statement runs alone, so no other claim can take the same entries.
+const claimOutboxQuery = `
+	UPDATE notification_outbox
+	SET attempts = attempts + 1, next_attempt_at = $2
+	WHERE id IN (
+		SELECT id FROM notification_outbox
+		WHERE sent_at IS NULL AND dead_at IS NULL AND next_attempt_at <= $3
+		ORDER BY id
+		LIMIT $1
+	)
+	RETURNING id, chat_id, (SELECT tenant_id FROM users u WHERE u.chat_id = notification_outbox.chat_id),
+		notification_type, repo, message, item_url, occurred_at, event_id, severity, labels, thread_id, account, attempts, created_at, fields
+`
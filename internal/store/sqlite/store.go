@@ -0,0 +1,1935 @@
+// Package sqlite stores everything in a single SQLite file, for small
+// installations that don't want to run Postgres. The driver is pure Go, so
+// the monitor still builds without cgo.
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// Scheme prefixes the DATABASE_URL of a SQLite database, as in
+// sqlite:///var/lib/monitor/monitor.db or sqlite://monitor.db for a path
+// relative to the working directory.
+const Scheme = "sqlite://"
+
+// IsURL reports whether dbURL names a SQLite database.
+func IsURL(dbURL string) bool {
+	return strings.HasPrefix(dbURL, Scheme)
+}
+
+type Store struct {
+	db *sql.DB
+	// holder identifies the locks this store takes with TryLock.
+	holder string
+}
+
+// New opens the database file, creating it when it does not exist. With
+// migrate, the schema is created or upgraded first. It fails when the
+// schema does not match this version, see SchemaStatus.Check.
+func New(dbURL string, migrate bool) (*Store, error) {
+	db, err := openDB(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	if migrate {
+		if err := applyMigrations(ctx, db); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate database: %v", err)
+		}
+	}
+	status, err := schemaStatus(ctx, db)
+	if err == nil {
+		err = status.Check()
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	holder := make([]byte, 16)
+	if _, err := rand.Read(holder); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to generate lock holder: %v", err)
+	}
+
+	return &Store{db: db, holder: hex.EncodeToString(holder)}, nil
+}
+
+// openDB opens the file named by dbURL. Every connection enforces foreign
+// keys and waits for the write lock instead of failing, and transactions
+// take the write lock when they begin, so a transaction that reads before
+// it writes can't be overtaken by another writer.
+func openDB(dbURL string) (*sql.DB, error) {
+	if !IsURL(dbURL) {
+		return nil, fmt.Errorf("database URL must start with %s", Scheme)
+	}
+	path, params, _ := strings.Cut(strings.TrimPrefix(dbURL, Scheme), "?")
+	if path == "" {
+		return nil, fmt.Errorf("database URL names no file")
+	}
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	if params != "" {
+		dsn += "&" + params
+	}
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+	return db, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// timeFormat is how timestamps are stored: in UTC with every fractional
+// digit, so they sort as text in chronological order. The driver reads
+// it back into a time.Time from columns declared TIMESTAMP.
+const timeFormat = "2006-01-02 15:04:05.000000000"
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+// nullTimestamp stores the zero time as NULL.
+func nullTimestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamp(t)
+}
+
+// nullTime scans a timestamp the driver returns as text, because it can't
+// tell the declared type of a column computed by a query, like one of a
+// UNION.
+type nullTime struct {
+	sql.NullTime
+}
+
+func (t *nullTime) Scan(value any) error {
+	text, ok := value.(string)
+	if !ok {
+		return t.NullTime.Scan(value)
+	}
+	parsed, err := time.Parse(timeFormat, text)
+	if err != nil {
+		return fmt.Errorf("failed to parse timestamp %q: %v", text, err)
+	}
+	t.Time, t.Valid = parsed, true
+	return nil
+}
+
+func (s *Store) AddTenant(ctx context.Context, tenant models.Tenant) error {
+	if tenant.ID == "" || tenant.ID == store.DefaultTenant {
+		return fmt.Errorf("invalid tenant ID %q", tenant.ID)
+	}
+
+	query := `
+		INSERT INTO tenants (id, name, bot_token, api_token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET name = $2, bot_token = $3, api_token = $4
+	`
+	if _, err := s.db.ExecContext(ctx, query, tenant.ID, tenant.Name, tenant.BotToken, tenant.APIToken); err != nil {
+		return fmt.Errorf("failed to save tenant: %v", err)
+	}
+
+	return nil
+}
+
+// RemoveTenant deletes a tenant that no longer has users.
+func (s *Store) RemoveTenant(ctx context.Context, tenantID string) error {
+	query := "DELETE FROM tenants WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE tenant_id = $1)"
+	result, err := s.db.ExecContext(ctx, query, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove tenant: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	var users int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE tenant_id = $1", tenantID).Scan(&users); err != nil {
+		return fmt.Errorf("failed to count tenant users: %v", err)
+	}
+	if users > 0 {
+		return fmt.Errorf("tenant %s still has %d users", tenantID, users)
+	}
+	return fmt.Errorf("tenant not found")
+}
+
+func (s *Store) GetTenants(ctx context.Context) ([]models.Tenant, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, name, bot_token, api_token, created_at FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %v", err)
+	}
+	defer rows.Close()
+
+	var tenants []models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Name, &tenant.BotToken, &tenant.APIToken, &tenant.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %v", err)
+		}
+		tenants = append(tenants, tenant)
+	}
+
+	return tenants, rows.Err()
+}
+
+// SetFeatureFlag creates or replaces a feature flag.
+func (s *Store) SetFeatureFlag(ctx context.Context, flag models.FeatureFlag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+
+	chatIDs := flag.ChatIDs
+	if chatIDs == nil {
+		chatIDs = []int64{}
+	}
+	encoded, err := json.Marshal(chatIDs)
+	if err != nil {
+		return fmt.Errorf("failed to encode chat IDs: %v", err)
+	}
+
+	query := `
+		INSERT INTO feature_flags (name, enabled, percent, chat_ids, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (name) DO UPDATE SET enabled = $2, percent = $3, chat_ids = $4, updated_at = $5
+	`
+	if _, err := s.db.ExecContext(ctx, query, flag.Name, flag.Enabled, flag.Percent, string(encoded), timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to save feature flag: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveFeatureFlag(ctx context.Context, name string) error {
+	result, err := s.db.ExecContext(ctx, "DELETE FROM feature_flags WHERE name = $1", name)
+	if err != nil {
+		return fmt.Errorf("failed to remove feature flag: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("feature flag not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT name, enabled, percent, chat_ids, updated_at FROM feature_flags ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feature flags: %v", err)
+	}
+	defer rows.Close()
+
+	var flags []models.FeatureFlag
+	for rows.Next() {
+		var flag models.FeatureFlag
+		var chatIDs []byte
+		if err := rows.Scan(&flag.Name, &flag.Enabled, &flag.Percent, &chatIDs, &flag.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan feature flag: %v", err)
+		}
+		if err := json.Unmarshal(chatIDs, &flag.ChatIDs); err != nil {
+			return nil, fmt.Errorf("failed to decode chat IDs: %v", err)
+		}
+		flags = append(flags, flag)
+	}
+
+	return flags, rows.Err()
+}
+
+// ChatTenant returns the tenant a chat is registered with.
+func (s *Store) ChatTenant(ctx context.Context, chatID int64) (string, bool, error) {
+	var tenantID string
+	err := s.db.QueryRowContext(ctx, "SELECT tenant_id FROM users WHERE chat_id = $1", chatID).Scan(&tenantID)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get chat tenant: %v", err)
+	}
+
+	return tenantID, true, nil
+}
+
+func (s *Store) AddGitHubAccount(ctx context.Context, chatID int64, githubToken, githubUsername string, metadata models.GitHubAccountMetadata) error {
+	scopes := metadata.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	encodedScopes, err := json.Marshal(scopes)
+	if err != nil {
+		return fmt.Errorf("failed to encode scopes: %v", err)
+	}
+	expiresAt := nullTimestamp(metadata.ExpiresAt)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	// Scopes and expiry belong to the token and are replaced with it, while
+	// the note is kept unless a new one is given.
+	query := `
+		INSERT INTO github_accounts (chat_id, username, token, is_active, scopes, expires_at, note)
+		VALUES ($1, $2, $3, true, $4, $5, $6)
+		ON CONFLICT (chat_id, username) DO UPDATE
+		SET token = $3, is_active = true, scopes = $4, expires_at = $5,
+			note = COALESCE(NULLIF($6, ''), github_accounts.note)
+	`
+	if _, err := tx.ExecContext(ctx, query, chatID, githubUsername, githubToken, string(encodedScopes), expiresAt, metadata.Note); err != nil {
+		return fmt.Errorf("failed to insert GitHub account: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, chatID); err == store.ErrUserNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM github_accounts WHERE chat_id = $1 AND username = $2"
+	if _, err := tx.ExecContext(ctx, query, chatID, githubUsername); err != nil {
+		return fmt.Errorf("failed to remove GitHub account: %v", err)
+	}
+
+	for _, kind := range []string{models.AccountKindGitHub, models.AccountKindSecurity} {
+		if err := removeAccountState(ctx, tx, chatID, kind, githubUsername); err != nil {
+			return err
+		}
+	}
+
+	if err := removeUserIfEmpty(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ensureUser creates the user in the context's tenant, restoring it if it
+// was soft-deleted. Chats that belong to another tenant are rejected.
+func ensureUser(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	tenantID, ok := store.TenantFromContext(ctx)
+	if !ok {
+		tenantID = store.DefaultTenant
+	}
+
+	var owner string
+	query := `
+		INSERT INTO users (chat_id, tenant_id) VALUES ($1, $2)
+		ON CONFLICT (chat_id) DO UPDATE SET deleted_at = NULL
+		RETURNING tenant_id
+	`
+	if err := tx.QueryRowContext(ctx, query, chatID, tenantID).Scan(&owner); err != nil {
+		return fmt.Errorf("failed to insert user: %v", err)
+	}
+	if ok && owner != tenantID {
+		return store.ErrTenantMismatch
+	}
+
+	return nil
+}
+
+// lockUser checks that the user is active. Every transaction holds the
+// database's write lock from its start, so changes that read and then
+// write a user's rows, like removing the last account, are applied one
+// after the other instead of interleaving.
+func lockUser(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := "SELECT chat_id FROM users WHERE chat_id = $1 AND deleted_at IS NULL AND ($2 = '' OR tenant_id = $2)"
+	err := tx.QueryRowContext(ctx, query, chatID, tenantID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return store.ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock user: %v", err)
+	}
+	return nil
+}
+
+// removeUserIfEmpty soft-deletes the user once the last account is gone.
+// Preferences, mutes and subscriptions are kept until the user is purged,
+// so adding an account again within the grace period restores them.
+func removeUserIfEmpty(ctx context.Context, tx *sql.Tx, chatID int64) error {
+	var count int
+	countQuery := `
+		SELECT (SELECT COUNT(*) FROM github_accounts WHERE chat_id = $1)
+			+ (SELECT COUNT(*) FROM gerrit_accounts WHERE chat_id = $1)
+	`
+	if err := tx.QueryRowContext(ctx, countQuery, chatID).Scan(&count); err != nil {
+		return fmt.Errorf("failed to count remaining accounts: %v", err)
+	}
+
+	if count == 0 {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET deleted_at = $2 WHERE chat_id = $1", chatID, timestamp(time.Now())); err != nil {
+			return fmt.Errorf("failed to remove user: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) AddGerritAccount(ctx context.Context, chatID int64, account models.GerritAccount) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := ensureUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO gerrit_accounts (chat_id, base_url, username, password, is_active)
+		VALUES ($1, $2, $3, $4, true)
+		ON CONFLICT (chat_id, base_url, username) DO UPDATE SET password = $4, is_active = true
+	`
+	if _, err := tx.ExecContext(ctx, query, chatID, account.BaseURL, account.Username, account.Password); err != nil {
+		return fmt.Errorf("failed to insert Gerrit account: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveGerritAccount(ctx context.Context, chatID int64, baseURL, username string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, chatID); err == store.ErrUserNotFound {
+		return store.ErrAccountNotFound
+	} else if err != nil {
+		return err
+	}
+
+	query := "DELETE FROM gerrit_accounts WHERE chat_id = $1 AND base_url = $2 AND username = $3"
+	result, err := tx.ExecContext(ctx, query, chatID, baseURL, username)
+	if err != nil {
+		return fmt.Errorf("failed to remove Gerrit account: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	account := models.GerritAccount{BaseURL: baseURL, Username: username}
+	if err := removeAccountState(ctx, tx, chatID, models.AccountKindGerrit, account.Key()); err != nil {
+		return err
+	}
+
+	if err := removeUserIfEmpty(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) ToggleGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		UPDATE github_accounts
+		SET is_active = NOT is_active
+		WHERE chat_id = $1 AND username = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))
+	`
+	result, err := s.db.ExecContext(ctx, query, chatID, githubUsername, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to toggle GitHub account: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) GetAccountPreferences(ctx context.Context, chatID int64, githubUsername string) (models.AccountPreferences, error) {
+	preferences := models.AccountPreferences{ChatID: chatID, Username: githubUsername}
+	var disabledReasons []byte
+	tenantID, _ := store.TenantFromContext(ctx)
+	err := s.db.QueryRowContext(ctx, `
+		SELECT disabled_reasons, security_alerts
+		FROM account_preferences
+		WHERE chat_id = $1 AND username = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))
+	`, chatID, githubUsername, tenantID).Scan(&disabledReasons, &preferences.SecurityAlerts)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, fmt.Errorf("failed to get account preferences: %v", err)
+	}
+
+	if err := json.Unmarshal(disabledReasons, &preferences.DisabledReasons); err != nil {
+		return preferences, fmt.Errorf("failed to decode disabled reasons: %v", err)
+	}
+	return preferences, nil
+}
+
+// SetAccountPreferences saves the preferences of an existing account, and
+// returns store.ErrAccountNotFound for others.
+func (s *Store) SetAccountPreferences(ctx context.Context, preferences models.AccountPreferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	disabledReasons := preferences.DisabledReasons
+	if disabledReasons == nil {
+		disabledReasons = []string{}
+	}
+	encodedDisabledReasons, err := json.Marshal(disabledReasons)
+	if err != nil {
+		return fmt.Errorf("failed to encode disabled reasons: %v", err)
+	}
+
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		INSERT INTO account_preferences (chat_id, username, disabled_reasons, security_alerts)
+		SELECT chat_id, username, $3, $4
+		FROM github_accounts
+		WHERE chat_id = $1 AND username = $2 AND ($5 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $5))
+		ON CONFLICT (chat_id, username) DO UPDATE SET
+			disabled_reasons = EXCLUDED.disabled_reasons,
+			security_alerts = EXCLUDED.security_alerts
+	`
+	result, err := s.db.ExecContext(ctx, query, preferences.ChatID, preferences.Username, string(encodedDisabledReasons), preferences.SecurityAlerts, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to save account preferences: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrAccountNotFound
+	}
+
+	return nil
+}
+
+// userRowsQuery materializes a page of users with their accounts and muted
+// repositories in a single query. The page holds up to $4 users with
+// at least one account and a chat ID above $2; $1 narrows it to a single
+// chat. Each row carries one item, tagged by kind, so accounts and mutes
+// don't multiply into a cross product.
+const userRowsQuery = `
+	WITH page AS (
+		SELECT u.chat_id, u.tenant_id
+		FROM users u
+		WHERE ($1 = 0 OR u.chat_id = $1) AND u.chat_id > $2 AND ($3 = '' OR u.tenant_id = $3)
+			AND (EXISTS (SELECT 1 FROM github_accounts g WHERE g.chat_id = u.chat_id)
+				OR EXISTS (SELECT 1 FROM gerrit_accounts g WHERE g.chat_id = u.chat_id))
+		ORDER BY u.chat_id
+		LIMIT $4
+	)
+	SELECT items.chat_id, page.tenant_id, kind, name, secret, base_url, is_active, scopes, expires_at, note, added_at FROM (
+		SELECT chat_id, 'github' AS kind, username AS name, token AS secret, '' AS base_url, is_active,
+			scopes, expires_at, note, added_at
+		FROM github_accounts
+		WHERE chat_id IN (SELECT chat_id FROM page)
+		UNION ALL
+		SELECT chat_id, 'gerrit', username, password, base_url, is_active, '[]', NULL, '', NULL
+		FROM gerrit_accounts
+		WHERE chat_id IN (SELECT chat_id FROM page)
+		UNION ALL
+		SELECT chat_id, 'muted', repo, '', '', false, '[]', NULL, '', NULL
+		FROM muted_repos
+		WHERE chat_id IN (SELECT chat_id FROM page)
+	) items
+	JOIN page ON page.chat_id = items.chat_id
+	ORDER BY items.chat_id
+`
+
+func (s *Store) GetUser(ctx context.Context, chatID int64) (*models.User, bool) {
+	users, err := s.loadUsers(ctx, chatID, store.FirstChatID, 1)
+	if err != nil || len(users) == 0 {
+		return nil, false
+	}
+
+	return users[0], true
+}
+
+func (s *Store) ListUsers(ctx context.Context, afterChatID int64, limit int) ([]*models.User, error) {
+	users, err := s.loadUsers(ctx, 0, afterChatID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+
+	return users, nil
+}
+
+// loadUsers returns a page of the context's tenant's users that have at
+// least one account, in chat ID order. A chatID of 0 loads every user.
+func (s *Store) loadUsers(ctx context.Context, chatID, afterChatID int64, limit int) ([]*models.User, error) {
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.db.QueryContext(ctx, userRowsQuery, chatID, afterChatID, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	var user *models.User
+	hasAccounts := false
+	for rows.Next() {
+		var chatID int64
+		var tenantID, kind, name, secret, baseURL, note string
+		var isActive bool
+		var scopes []byte
+		var expiresAt, addedAt nullTime
+		if err := rows.Scan(&chatID, &tenantID, &kind, &name, &secret, &baseURL, &isActive, &scopes, &expiresAt, &note, &addedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan user row: %v", err)
+		}
+
+		if user == nil || user.ChatID != chatID {
+			if user != nil && hasAccounts {
+				users = append(users, user)
+			}
+			user = &models.User{
+				ChatID:     chatID,
+				TenantID:   tenantID,
+				Accounts:   make(map[string]*models.GitHubAccount),
+				MutedRepos: make(map[string]bool),
+			}
+			hasAccounts = false
+		}
+
+		switch kind {
+		case "github":
+			hasAccounts = true
+			account := &models.GitHubAccount{Username: name, Token: secret, IsActive: isActive, AddedAt: addedAt.Time}
+			account.Note = note
+			account.ExpiresAt = expiresAt.Time
+			if err := json.Unmarshal(scopes, &account.Scopes); err != nil {
+				return nil, fmt.Errorf("failed to decode scopes: %v", err)
+			}
+			user.Accounts[name] = account
+		case "gerrit":
+			hasAccounts = true
+			user.GerritAccounts = append(user.GerritAccounts, &models.GerritAccount{BaseURL: baseURL, Username: name, Password: secret, IsActive: isActive})
+		case "muted":
+			user.MutedRepos[name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if user != nil && hasAccounts {
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func (s *Store) AddImageSubscription(ctx context.Context, chatID int64, image, tagFilter string, seenTags []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := lockUser(ctx, tx, chatID); err != nil {
+		return err
+	}
+
+	// Re-subscribing resets the seen tags so the new filter starts from the
+	// current state of the registry.
+	if _, err := tx.ExecContext(ctx, "DELETE FROM image_subscriptions WHERE chat_id = $1 AND image = $2", chatID, image); err != nil {
+		return fmt.Errorf("failed to replace image subscription: %v", err)
+	}
+
+	var id int64
+	query := "INSERT INTO image_subscriptions (chat_id, image, tag_filter) VALUES ($1, $2, $3) RETURNING id"
+	if err := tx.QueryRowContext(ctx, query, chatID, image, tagFilter).Scan(&id); err != nil {
+		return fmt.Errorf("failed to insert image subscription: %v", err)
+	}
+
+	for _, tag := range seenTags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO image_tags_seen (subscription_id, tag) VALUES ($1, $2)", id, tag); err != nil {
+			return fmt.Errorf("failed to record seen tag: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveImageSubscription(ctx context.Context, chatID int64, image string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM image_subscriptions WHERE chat_id = $1 AND image = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, image, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove image subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetImageSubscriptions(ctx context.Context, chatID int64) ([]models.ImageSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, image, tag_filter FROM image_subscriptions WHERE chat_id = $1 ORDER BY image", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.ImageSubscription
+	for rows.Next() {
+		subscription := models.ImageSubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Image, &subscription.TagFilter); err != nil {
+			return nil, fmt.Errorf("failed to scan image subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT tag FROM image_tags_seen WHERE subscription_id = $1", subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen tags: %v", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, fmt.Errorf("failed to scan seen tag: %v", err)
+		}
+		seen[tag] = true
+	}
+
+	return seen, rows.Err()
+}
+
+func (s *Store) MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error {
+	for _, tag := range tags {
+		query := "INSERT INTO image_tags_seen (subscription_id, tag) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := s.db.ExecContext(ctx, query, subscriptionID, tag); err != nil {
+			return fmt.Errorf("failed to record seen tag: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) AddDependencySubscription(ctx context.Context, chatID int64, repo string, seenVersions []string) error {
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	query := `
+		INSERT INTO dependency_subscriptions (chat_id, repo)
+		VALUES ($1, $2)
+		ON CONFLICT (chat_id, repo) DO UPDATE SET last_checked_at = $3
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, chatID, repo, timestamp(time.Now())).Scan(&id); err != nil {
+		return fmt.Errorf("failed to insert dependency subscription: %v", err)
+	}
+
+	for _, key := range seenVersions {
+		query := "INSERT INTO dependency_versions_seen (subscription_id, version_key) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := tx.ExecContext(ctx, query, id, key); err != nil {
+			return fmt.Errorf("failed to record seen version: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) RemoveDependencySubscription(ctx context.Context, chatID int64, repo string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM dependency_subscriptions WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove dependency subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetDependencySubscriptions(ctx context.Context, chatID int64) ([]models.DependencySubscription, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT id, repo, last_checked_at FROM dependency_subscriptions WHERE chat_id = $1 ORDER BY repo", chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dependency subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.DependencySubscription
+	for rows.Next() {
+		subscription := models.DependencySubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &subscription.LastCheckedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan dependency subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT version_key FROM dependency_versions_seen WHERE subscription_id = $1", subscriptionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seen versions: %v", err)
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, fmt.Errorf("failed to scan seen version: %v", err)
+		}
+		seen[key] = true
+	}
+
+	return seen, rows.Err()
+}
+
+// MarkDependencyVersionsSeen records announced versions and stamps the
+// subscription as checked, even when keys is empty.
+func (s *Store) MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error {
+	for _, key := range keys {
+		query := "INSERT INTO dependency_versions_seen (subscription_id, version_key) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+		if _, err := s.db.ExecContext(ctx, query, subscriptionID, key); err != nil {
+			return fmt.Errorf("failed to record seen version: %v", err)
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, "UPDATE dependency_subscriptions SET last_checked_at = $2 WHERE id = $1", subscriptionID, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to update last check time: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error) {
+	state := models.AccountState{ChatID: chatID, Kind: kind, Account: account}
+	var lastCheckedAt, lastNotificationAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT last_checked_at, last_modified, last_notification_at, consecutive_errors, last_error, poll_interval
+		FROM account_state
+		WHERE chat_id = $1 AND kind = $2 AND account = $3
+	`, chatID, kind, account).Scan(&lastCheckedAt, &state.LastModified, &lastNotificationAt, &state.ConsecutiveErrors, &state.LastError, &state.PollInterval)
+	if err == sql.ErrNoRows {
+		return state, nil
+	}
+	if err != nil {
+		return state, fmt.Errorf("failed to get account state: %v", err)
+	}
+
+	state.LastCheckedAt = lastCheckedAt.Time
+	state.LastNotificationAt = lastNotificationAt.Time
+	return state, nil
+}
+
+func (s *Store) SaveAccountState(ctx context.Context, state models.AccountState) error {
+	query := `
+		INSERT INTO account_state (chat_id, kind, account, last_checked_at, last_modified, last_notification_at, consecutive_errors, last_error, poll_interval)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (chat_id, kind, account) DO UPDATE
+		SET last_checked_at = $4, last_modified = $5, last_notification_at = $6, consecutive_errors = $7, last_error = $8, poll_interval = $9
+	`
+	_, err := s.db.ExecContext(ctx, query, state.ChatID, state.Kind, state.Account,
+		nullTimestamp(state.LastCheckedAt), state.LastModified, nullTimestamp(state.LastNotificationAt), state.ConsecutiveErrors, state.LastError, state.PollInterval)
+	if err != nil {
+		return fmt.Errorf("failed to save account state: %v", err)
+	}
+
+	return nil
+}
+
+func removeAccountState(ctx context.Context, tx *sql.Tx, chatID int64, kind, account string) error {
+	query := "DELETE FROM account_state WHERE chat_id = $1 AND kind = $2 AND account = $3"
+	if _, err := tx.ExecContext(ctx, query, chatID, kind, account); err != nil {
+		return fmt.Errorf("failed to remove account state: %v", err)
+	}
+	return nil
+}
+
+// GetPreferences returns the chat's preferences, or the defaults when none
+// were saved.
+func (s *Store) GetPreferences(ctx context.Context, chatID int64) (models.Preferences, error) {
+	preferences := models.DefaultPreferences(chatID)
+	var priorities, renotifyIntervals, teamRepos, triage, changelog, usage, commitMentionRepos []byte
+	var pausedUntil sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT quiet_hours_start, quiet_hours_end, timezone, digest_mode, language, parse_mode, priorities, renotify_intervals,
+			paused, paused_until, poll_window, filter, team_digest, team_repos, mention_sla, triage, summaries, changelog, usage_alerts,
+			commit_mention_repos, digest_time
+		FROM user_preferences
+		WHERE chat_id = $1
+	`, chatID).Scan(&preferences.QuietHoursStart, &preferences.QuietHoursEnd, &preferences.Timezone,
+		&preferences.DigestMode, &preferences.Language, &preferences.ParseMode, &priorities, &renotifyIntervals,
+		&preferences.Paused, &pausedUntil, &preferences.PollWindow, &preferences.Filter, &preferences.TeamDigest, &teamRepos, &preferences.MentionSLA, &triage, &preferences.Summaries, &changelog, &usage,
+		&commitMentionRepos, &preferences.DigestTime)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, fmt.Errorf("failed to get preferences: %v", err)
+	}
+	preferences.PausedUntil = pausedUntil.Time
+
+	if err := json.Unmarshal(priorities, &preferences.Priorities); err != nil {
+		return preferences, fmt.Errorf("failed to decode priorities: %v", err)
+	}
+	if err := json.Unmarshal(renotifyIntervals, &preferences.RenotifyIntervals); err != nil {
+		return preferences, fmt.Errorf("failed to decode renotify intervals: %v", err)
+	}
+	if err := json.Unmarshal(teamRepos, &preferences.TeamRepos); err != nil {
+		return preferences, fmt.Errorf("failed to decode team repositories: %v", err)
+	}
+	if err := json.Unmarshal(triage, &preferences.Triage); err != nil {
+		return preferences, fmt.Errorf("failed to decode triage settings: %v", err)
+	}
+	if err := json.Unmarshal(changelog, &preferences.Changelog); err != nil {
+		return preferences, fmt.Errorf("failed to decode changelog settings: %v", err)
+	}
+	if err := json.Unmarshal(usage, &preferences.Usage); err != nil {
+		return preferences, fmt.Errorf("failed to decode usage alerts: %v", err)
+	}
+	if err := json.Unmarshal(commitMentionRepos, &preferences.CommitMentionRepos); err != nil {
+		return preferences, fmt.Errorf("failed to decode commit mention repositories: %v", err)
+	}
+
+	return preferences, nil
+}
+
+func (s *Store) SetPreferences(ctx context.Context, preferences models.Preferences) error {
+	if err := preferences.Validate(); err != nil {
+		return err
+	}
+
+	priorities := preferences.Priorities
+	if priorities == nil {
+		priorities = map[string]string{}
+	}
+	encoded, err := json.Marshal(priorities)
+	if err != nil {
+		return fmt.Errorf("failed to encode priorities: %v", err)
+	}
+	renotifyIntervals := preferences.RenotifyIntervals
+	if renotifyIntervals == nil {
+		renotifyIntervals = map[string]int{}
+	}
+	encodedIntervals, err := json.Marshal(renotifyIntervals)
+	if err != nil {
+		return fmt.Errorf("failed to encode renotify intervals: %v", err)
+	}
+	teamRepos := preferences.TeamRepos
+	if teamRepos == nil {
+		teamRepos = []string{}
+	}
+	encodedRepos, err := json.Marshal(teamRepos)
+	if err != nil {
+		return fmt.Errorf("failed to encode team repositories: %v", err)
+	}
+	encodedTriage, err := json.Marshal(preferences.Triage)
+	if err != nil {
+		return fmt.Errorf("failed to encode triage settings: %v", err)
+	}
+	encodedChangelog, err := json.Marshal(preferences.Changelog)
+	if err != nil {
+		return fmt.Errorf("failed to encode changelog settings: %v", err)
+	}
+	encodedUsage, err := json.Marshal(preferences.Usage)
+	if err != nil {
+		return fmt.Errorf("failed to encode usage alerts: %v", err)
+	}
+	commitMentionRepos := preferences.CommitMentionRepos
+	if commitMentionRepos == nil {
+		commitMentionRepos = []string{}
+	}
+	encodedCommitMentionRepos, err := json.Marshal(commitMentionRepos)
+	if err != nil {
+		return fmt.Errorf("failed to encode commit mention repositories: %v", err)
+	}
+
+	if err := s.requireUser(ctx, preferences.ChatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_preferences (chat_id, quiet_hours_start, quiet_hours_end, timezone, digest_mode, language, parse_mode, priorities, renotify_intervals,
+			paused, paused_until, poll_window, filter, team_digest, team_repos, mention_sla, triage, summaries, changelog, usage_alerts,
+			commit_mention_repos, digest_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET quiet_hours_start = $2, quiet_hours_end = $3, timezone = $4, digest_mode = $5,
+			language = $6, parse_mode = $7, priorities = $8, renotify_intervals = $9,
+			paused = $10, paused_until = $11, poll_window = $12, filter = $13,
+			team_digest = $14, team_repos = $15, mention_sla = $16, triage = $17, summaries = $18, changelog = $19, usage_alerts = $20,
+			commit_mention_repos = $21, digest_time = $22
+	`
+	_, err = s.db.ExecContext(ctx, query, preferences.ChatID, preferences.QuietHoursStart, preferences.QuietHoursEnd,
+		preferences.Timezone, preferences.DigestMode, preferences.Language, preferences.ParseMode, string(encoded), string(encodedIntervals),
+		preferences.Paused, nullTimestamp(preferences.PausedUntil), preferences.PollWindow, preferences.Filter, preferences.TeamDigest, string(encodedRepos), preferences.MentionSLA,
+		string(encodedTriage), preferences.Summaries, string(encodedChangelog), string(encodedUsage), string(encodedCommitMentionRepos), preferences.DigestTime)
+	if err != nil {
+		return fmt.Errorf("failed to save preferences: %v", err)
+	}
+
+	return nil
+}
+
+// AddRepoSubscription creates the subscription or replaces the existing
+// one for the same repository.
+func (s *Store) AddRepoSubscription(ctx context.Context, subscription models.RepoSubscription) (int64, error) {
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+
+	eventTypes := subscription.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+	encodedEventTypes, err := json.Marshal(eventTypes)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event types: %v", err)
+	}
+	encodedFilters, err := json.Marshal(subscription.Filters)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode filters: %v", err)
+	}
+
+	if err := s.requireUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	query := `
+		INSERT INTO repo_subscriptions (chat_id, repo, event_types, filters, destination_chat_id)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, repo) DO UPDATE
+		SET event_types = $3, filters = $4, destination_chat_id = $5
+		RETURNING id
+	`
+	err = s.db.QueryRowContext(ctx, query, subscription.ChatID, subscription.Repo, string(encodedEventTypes),
+		string(encodedFilters), subscription.DestinationChatID).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save repository subscription: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemoveRepoSubscription(ctx context.Context, chatID int64, repo string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM repo_subscriptions WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove repository subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetRepoSubscriptions(ctx context.Context, chatID int64) ([]models.RepoSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, repo, event_types, filters, destination_chat_id, created_at
+		FROM repo_subscriptions
+		WHERE chat_id = $1
+		ORDER BY repo
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query repository subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.RepoSubscription
+	for rows.Next() {
+		subscription := models.RepoSubscription{ChatID: chatID}
+		var eventTypes, filters []byte
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &eventTypes, &filters, &subscription.DestinationChatID, &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan repository subscription: %v", err)
+		}
+		if err := json.Unmarshal(eventTypes, &subscription.EventTypes); err != nil {
+			return nil, fmt.Errorf("failed to decode event types: %v", err)
+		}
+		if err := json.Unmarshal(filters, &subscription.Filters); err != nil {
+			return nil, fmt.Errorf("failed to decode filters: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// AddPathSubscription creates the subscription, or returns the ID of the
+// existing one for the same repository and pattern.
+func (s *Store) AddPathSubscription(ctx context.Context, subscription models.PathSubscription) (int64, error) {
+	if err := subscription.Validate(); err != nil {
+		return 0, err
+	}
+	if err := s.requireUser(ctx, subscription.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	query := `
+		INSERT INTO path_subscriptions (chat_id, repo, pattern)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (chat_id, repo, pattern) DO UPDATE SET pattern = EXCLUDED.pattern
+		RETURNING id
+	`
+	if err := s.db.QueryRowContext(ctx, query, subscription.ChatID, subscription.Repo, subscription.Pattern).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to save path subscription: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemovePathSubscription(ctx context.Context, chatID int64, repo, pattern string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM path_subscriptions WHERE chat_id = $1 AND repo = $2 AND pattern = $3 AND ($4 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $4))", chatID, repo, pattern, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove path subscription: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("subscription not found")
+	}
+
+	return nil
+}
+
+func (s *Store) GetPathSubscriptions(ctx context.Context, chatID int64) ([]models.PathSubscription, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, repo, pattern, created_at
+		FROM path_subscriptions
+		WHERE chat_id = $1
+		ORDER BY repo, pattern
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query path subscriptions: %v", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []models.PathSubscription
+	for rows.Next() {
+		subscription := models.PathSubscription{ChatID: chatID}
+		if err := rows.Scan(&subscription.ID, &subscription.Repo, &subscription.Pattern, &subscription.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan path subscription: %v", err)
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+func (s *Store) AddRoutingRule(ctx context.Context, rule models.RoutingRule) (int64, error) {
+	if err := rule.Validate(); err != nil {
+		return 0, err
+	}
+
+	if err := s.requireUser(ctx, rule.ChatID); err != nil {
+		return 0, err
+	}
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO routing_rules (chat_id, notification_type, repo, label, keyword, severity, destination_chat_id, silent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`, rule.ChatID, rule.Type, rule.Repo, rule.Label, rule.Keyword, rule.Severity, rule.DestinationChatID, rule.Silent).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to save routing rule: %v", err)
+	}
+
+	return id, nil
+}
+
+func (s *Store) RemoveRoutingRule(ctx context.Context, chatID, id int64) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, "DELETE FROM routing_rules WHERE chat_id = $1 AND id = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to remove routing rule: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrRuleNotFound
+	}
+
+	return nil
+}
+
+func (s *Store) GetRoutingRules(ctx context.Context, chatID int64) ([]models.RoutingRule, error) {
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, notification_type, repo, label, keyword, severity, destination_chat_id, silent, created_at
+		FROM routing_rules
+		WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))
+		ORDER BY id
+	`, chatID, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routing rules: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []models.RoutingRule
+	for rows.Next() {
+		rule := models.RoutingRule{ChatID: chatID}
+		if err := rows.Scan(&rule.ID, &rule.Type, &rule.Repo, &rule.Label, &rule.Keyword, &rule.Severity,
+			&rule.DestinationChatID, &rule.Silent, &rule.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan routing rule: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *Store) RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error {
+	if err := s.requireUser(ctx, request.ChatID); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO review_requests (chat_id, account, repo, url, requested_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (chat_id, account, url) WHERE reviewed_at IS NULL DO NOTHING
+	`, request.ChatID, request.Account, request.Repo, request.URL, timestamp(request.RequestedAt))
+	if err != nil {
+		return fmt.Errorf("failed to save review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetReviewRequests(ctx context.Context, chatID int64, since time.Time) ([]models.ReviewRequest, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account, repo, url, requested_at, reviewed_at
+		FROM review_requests
+		WHERE chat_id = $1 AND (reviewed_at IS NULL OR requested_at >= $2)
+		ORDER BY requested_at, id
+	`, chatID, timestamp(since))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query review requests: %v", err)
+	}
+	defer rows.Close()
+
+	var requests []models.ReviewRequest
+	for rows.Next() {
+		request := models.ReviewRequest{ChatID: chatID}
+		var reviewedAt sql.NullTime
+		if err := rows.Scan(&request.ID, &request.Account, &request.Repo, &request.URL, &request.RequestedAt, &reviewedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan review request: %v", err)
+		}
+		request.ReviewedAt = reviewedAt.Time
+		requests = append(requests, request)
+	}
+
+	return requests, rows.Err()
+}
+
+func (s *Store) CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE review_requests SET reviewed_at = $2 WHERE id = $1", id, timestamp(reviewedAt)); err != nil {
+		return fmt.Errorf("failed to complete review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveReviewRequest(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM review_requests WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to remove review request: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) RecordMention(ctx context.Context, mention models.Mention) error {
+	if err := s.requireUser(ctx, mention.ChatID); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO mentions (chat_id, account, repo, message, url, mentioned_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id, account, url) DO NOTHING
+	`, mention.ChatID, mention.Account, mention.Repo, mention.Message, mention.URL, timestamp(mention.MentionedAt))
+	if err != nil {
+		return fmt.Errorf("failed to save mention: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetMentions(ctx context.Context, chatID int64) ([]models.Mention, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, account, repo, message, url, mentioned_at
+		FROM mentions
+		WHERE chat_id = $1
+		ORDER BY mentioned_at, id
+	`, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query mentions: %v", err)
+	}
+	defer rows.Close()
+
+	var mentions []models.Mention
+	for rows.Next() {
+		mention := models.Mention{ChatID: chatID}
+		if err := rows.Scan(&mention.ID, &mention.Account, &mention.Repo, &mention.Message, &mention.URL, &mention.MentionedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan mention: %v", err)
+		}
+		mentions = append(mentions, mention)
+	}
+
+	return mentions, rows.Err()
+}
+
+func (s *Store) RemoveMention(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM mentions WHERE id = $1", id); err != nil {
+		return fmt.Errorf("failed to remove mention: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) MuteRepo(ctx context.Context, chatID int64, repo string) error {
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	query := "INSERT INTO muted_repos (chat_id, repo) VALUES ($1, $2) ON CONFLICT DO NOTHING"
+	if _, err := s.db.ExecContext(ctx, query, chatID, repo); err != nil {
+		return fmt.Errorf("failed to mute repository: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) UnmuteRepo(ctx context.Context, chatID int64, repo string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM muted_repos WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID); err != nil {
+		return fmt.Errorf("failed to unmute repository: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) requireUser(ctx context.Context, chatID int64) error {
+	var exists bool
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := "SELECT EXISTS(SELECT 1 FROM users WHERE chat_id = $1 AND deleted_at IS NULL AND ($2 = '' OR tenant_id = $2))"
+	if err := s.db.QueryRowContext(ctx, query, chatID, tenantID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check user: %v", err)
+	}
+	if !exists {
+		return store.ErrUserNotFound
+	}
+	return nil
+}
+
+func (s *Store) ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	var lastNotification time.Time
+	err := s.db.QueryRowContext(ctx, shouldNotifyQuery, chatID, itemURL, contentHash).Scan(&lastNotification)
+
+	if err == sql.ErrNoRows {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to query notification: %v", err)
+	}
+
+	if renotifyInterval < 0 {
+		return false, nil
+	}
+	return time.Since(lastNotification) > renotifyInterval, nil
+}
+
+func (s *Store) RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error {
+	_, err := s.db.ExecContext(ctx, recordNotificationQuery, chatID, itemURL, notificationType, contentHash, "")
+
+	if err != nil {
+		return fmt.Errorf("failed to record notification: %v", err)
+	}
+
+	return nil
+}
+
+// EnqueueNotification writes the notification to the outbox and records it
+// as sent in the same transaction, so it is neither lost nor queued twice
+// when the process restarts mid-cycle. The renotify check is repeated
+// while the transaction holds the write lock, so concurrent workers that
+// both passed ShouldNotify queue it only once; it reports whether this
+// call queued it.
+func (s *Store) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var recent bool
+	since := timestamp(time.Now().Add(-renotifyInterval))
+	err = tx.QueryRowContext(ctx, recentNotificationQuery, chatID, notification.URL, contentHash, renotifyInterval < 0, since).Scan(&recent)
+	if err != nil {
+		return false, fmt.Errorf("failed to query notification: %v", err)
+	}
+	if recent {
+		return false, nil
+	}
+
+	labels := notification.Labels
+	if labels == nil {
+		labels = []string{}
+	}
+	encodedLabels, err := json.Marshal(labels)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode labels: %v", err)
+	}
+	encodedFields, err := json.Marshal(notification.Fields)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode fields: %v", err)
+	}
+	_, err = tx.ExecContext(ctx, enqueueNotificationQuery, chatID, notification.Type, notification.Repo, notification.Message, notification.URL, nullTimestamp(notification.OccurredAt),
+		notification.EventID, notification.Severity, string(encodedLabels), notification.ThreadID, notification.Account, string(encodedFields))
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue notification: %v", err)
+	}
+
+	_, err = tx.ExecContext(ctx, recordNotificationQuery, chatID, notification.URL, notification.Type, contentHash, notification.EventID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record notification: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return true, nil
+}
+
+// ClaimOutbox returns up to limit entries that are due for delivery and
+// hides them from other claims for the lease duration.
+func (s *Store) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error) {
+	now := time.Now()
+	rows, err := s.db.QueryContext(ctx, claimOutboxQuery, limit, timestamp(now.Add(lease)), timestamp(now))
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		var occurredAt sql.NullTime
+		var labels, fields []byte
+		n := &entry.Notification
+		if err := rows.Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &occurredAt, &n.EventID, &n.Severity, &labels, &n.ThreadID, &n.Account, &entry.Attempts, &entry.CreatedAt, &fields); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %v", err)
+		}
+		if err := json.Unmarshal(labels, &n.Labels); err != nil {
+			return nil, fmt.Errorf("failed to decode labels: %v", err)
+		}
+		if err := json.Unmarshal(fields, &n.Fields); err != nil {
+			return nil, fmt.Errorf("failed to decode fields: %v", err)
+		}
+		n.OccurredAt = occurredAt.Time
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox entries: %v", err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	return entries, nil
+}
+
+func (s *Store) MarkOutboxSent(ctx context.Context, id int64) error {
+	if _, err := s.db.ExecContext(ctx, "UPDATE notification_outbox SET sent_at = $2, last_error = '' WHERE id = $1", id, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as sent: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) MarkOutboxRetry(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error {
+	query := "UPDATE notification_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, lastError, timestamp(nextAttempt)); err != nil {
+		return fmt.Errorf("failed to reschedule outbox entry: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) DeferOutbox(ctx context.Context, id int64, until time.Time) error {
+	query := "UPDATE notification_outbox SET attempts = MAX(attempts - 1, 0), next_attempt_at = $2 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, timestamp(until)); err != nil {
+		return fmt.Errorf("failed to defer outbox entry: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) CountPendingOutbox(ctx context.Context) (int, error) {
+	var count int
+	query := "SELECT COUNT(*) FROM notification_outbox WHERE sent_at IS NULL AND dead_at IS NULL"
+	if err := s.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox entries: %v", err)
+	}
+	return count, nil
+}
+
+func (s *Store) MarkOutboxDead(ctx context.Context, id int64, lastError string) error {
+	query := "UPDATE notification_outbox SET last_error = $2, dead_at = $3 WHERE id = $1"
+	if _, err := s.db.ExecContext(ctx, query, id, lastError, timestamp(time.Now())); err != nil {
+		return fmt.Errorf("failed to mark outbox entry as dead: %v", err)
+	}
+	return nil
+}
+
+func (s *Store) GetOutbox(ctx context.Context, query store.OutboxQuery) ([]models.OutboxEntry, error) {
+	switch query.State {
+	case "", models.OutboxPending, models.OutboxSent, models.OutboxDead:
+	default:
+		return nil, fmt.Errorf("invalid outbox state %q", query.State)
+	}
+
+	sqlQuery := `
+		SELECT o.id, o.chat_id, u.tenant_id, o.notification_type, o.repo, o.message, o.item_url, o.event_id, o.severity,
+			o.attempts, o.last_error, o.next_attempt_at, o.sent_at, o.dead_at, o.created_at
+		FROM notification_outbox o
+		JOIN users u ON u.chat_id = o.chat_id
+		WHERE ($1 = 0 OR o.chat_id = $1)
+			AND ($2 = ''
+				OR ($2 = 'pending' AND o.sent_at IS NULL AND o.dead_at IS NULL)
+				OR ($2 = 'sent' AND o.sent_at IS NOT NULL)
+				OR ($2 = 'dead' AND o.dead_at IS NOT NULL))
+			AND ($5 = '' OR u.tenant_id = $5)
+		ORDER BY o.id DESC
+		LIMIT $3 OFFSET $4
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.db.QueryContext(ctx, sqlQuery, query.ChatID, query.State, query.Limit, query.Offset, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.OutboxEntry
+	for rows.Next() {
+		var entry models.OutboxEntry
+		var sentAt, deadAt sql.NullTime
+		n := &entry.Notification
+		if err := rows.Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &n.EventID, &n.Severity,
+			&entry.Attempts, &entry.LastError, &entry.NextAttemptAt, &sentAt, &deadAt, &entry.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan outbox entry: %v", err)
+		}
+		entry.SentAt = sentAt.Time
+		entry.DeadAt = deadAt.Time
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *Store) GetOutboxEntry(ctx context.Context, id int64) (models.OutboxEntry, error) {
+	query := `
+		SELECT o.id, o.chat_id, u.tenant_id, o.notification_type, o.repo, o.message, o.item_url, o.occurred_at, o.event_id, o.severity,
+			o.labels, o.thread_id, o.account, o.attempts, o.last_error, o.next_attempt_at, o.sent_at, o.dead_at, o.created_at, o.fields
+		FROM notification_outbox o
+		JOIN users u ON u.chat_id = o.chat_id
+		WHERE o.id = $1 AND ($2 = '' OR u.tenant_id = $2)
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	var entry models.OutboxEntry
+	var occurredAt, sentAt, deadAt sql.NullTime
+	var labels, fields []byte
+	n := &entry.Notification
+	err := s.db.QueryRowContext(ctx, query, id, tenantID).Scan(&entry.ID, &entry.ChatID, &entry.TenantID, &n.Type, &n.Repo, &n.Message, &n.URL, &occurredAt, &n.EventID, &n.Severity,
+		&labels, &n.ThreadID, &n.Account, &entry.Attempts, &entry.LastError, &entry.NextAttemptAt, &sentAt, &deadAt, &entry.CreatedAt, &fields)
+	if err == sql.ErrNoRows {
+		return models.OutboxEntry{}, store.ErrOutboxNotFound
+	}
+	if err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to get outbox entry: %v", err)
+	}
+	if err := json.Unmarshal(labels, &n.Labels); err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to decode labels: %v", err)
+	}
+	if err := json.Unmarshal(fields, &n.Fields); err != nil {
+		return models.OutboxEntry{}, fmt.Errorf("failed to decode fields: %v", err)
+	}
+	n.OccurredAt = occurredAt.Time
+	entry.SentAt = sentAt.Time
+	entry.DeadAt = deadAt.Time
+	return entry, nil
+}
+
+func (s *Store) RequeueOutbox(ctx context.Context, id int64) error {
+	query := `
+		UPDATE notification_outbox
+		SET attempts = 0, dead_at = NULL, next_attempt_at = $3
+		WHERE id = $1 AND dead_at IS NOT NULL
+			AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	result, err := s.db.ExecContext(ctx, query, id, tenantID, timestamp(time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to requeue outbox entry: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return store.ErrOutboxNotFound
+	}
+	return nil
+}
+
+func (s *Store) CleanOldNotifications(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM sent_notifications AS n
+		WHERE n.created_at < $1 AND NOT EXISTS(
+			SELECT 1 FROM user_preferences p
+			WHERE p.chat_id = n.chat_id AND p.renotify_intervals ->> n.notification_type IS NOT NULL
+				AND (CAST(p.renotify_intervals ->> n.notification_type AS INTEGER) < 0
+					OR julianday(n.created_at) > julianday($2) - CAST(p.renotify_intervals ->> n.notification_type AS INTEGER) / 24.0)
+		)
+	`, timestamp(before), timestamp(time.Now()))
+
+	if err != nil {
+		return fmt.Errorf("failed to clean old notifications: %v", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM notification_outbox
+		WHERE (sent_at IS NOT NULL OR dead_at IS NOT NULL) AND created_at < $1
+	`, timestamp(before))
+	if err != nil {
+		return fmt.Errorf("failed to clean outbox: %v", err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM review_requests
+		WHERE reviewed_at IS NOT NULL AND requested_at < $1
+	`, timestamp(before))
+	if err != nil {
+		return fmt.Errorf("failed to clean review requests: %v", err)
+	}
+
+	return nil
+}
+
+// PurgeDeletedUsers permanently removes users soft-deleted before the given
+// time, together with all their data.
+func (s *Store) PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The transaction holds the write lock, so a user that is restored
+	// concurrently is either restored first and kept, or purged first and
+	// created anew.
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM sent_notifications
+		WHERE chat_id IN (SELECT chat_id FROM users WHERE deleted_at < $1)
+	`, timestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove notification history: %v", err)
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users WHERE deleted_at < $1", timestamp(before))
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge users: %v", err)
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	return purged, nil
+}
+
+func (s *Store) GetNotificationHistory(ctx context.Context, query store.HistoryQuery) ([]models.NotificationRecord, error) {
+	sqlQuery := `
+		SELECT id, chat_id, item_url, notification_type, content_hash, created_at, event_id
+		FROM sent_notifications
+		WHERE chat_id = $1
+			AND ($2 = '' OR notification_type = $2)
+			AND created_at >= $3
+			AND ($6 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $6))
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4 OFFSET $5
+	`
+	tenantID, _ := store.TenantFromContext(ctx)
+	rows, err := s.db.QueryContext(ctx, sqlQuery, query.ChatID, query.Type, timestamp(query.Since), query.Limit, query.Offset, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification history: %v", err)
+	}
+	defer rows.Close()
+
+	var records []models.NotificationRecord
+	for rows.Next() {
+		var record models.NotificationRecord
+		if err := rows.Scan(&record.ID, &record.ChatID, &record.ItemURL, &record.NotificationType, &record.ContentHash, &record.CreatedAt, &record.EventID); err != nil {
+			return nil, fmt.Errorf("failed to scan notification record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+func (s *Store) GetCalendarToken(ctx context.Context, chatID int64) (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate calendar token: %v", err)
+	}
+
+	// Concurrent callers all get the token that was stored first.
+	var token string
+	query := "UPDATE users SET calendar_token = COALESCE(calendar_token, $1) WHERE chat_id = $2 RETURNING calendar_token"
+	err := s.db.QueryRowContext(ctx, query, hex.EncodeToString(buf), chatID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", store.ErrUserNotFound
+	} else if err != nil {
+		return "", fmt.Errorf("failed to store calendar token: %v", err)
+	}
+
+	return token, nil
+}
+
+func (s *Store) GetUserByCalendarToken(ctx context.Context, token string) (*models.User, bool) {
+	var chatID int64
+	err := s.db.QueryRowContext(ctx, "SELECT chat_id FROM users WHERE calendar_token = $1", token).Scan(&chatID)
+	if err != nil {
+		return nil, false
+	}
+
+	return s.GetUser(ctx, chatID)
+}
+
+func (s *Store) SetJiraConfig(ctx context.Context, config models.JiraConfig) error {
+	if err := s.requireUser(ctx, config.ChatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO jira_configs (chat_id, base_url, email, api_token, project_key, issue_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (chat_id) DO UPDATE
+		SET base_url = $2, email = $3, api_token = $4, project_key = $5, issue_type = $6
+	`
+	_, err := s.db.ExecContext(ctx, query, config.ChatID, config.BaseURL, config.Email, config.APIToken, config.ProjectKey, config.IssueType)
+	if err != nil {
+		return fmt.Errorf("failed to save Jira config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetJiraConfig(ctx context.Context, chatID int64) (*models.JiraConfig, bool) {
+	config := &models.JiraConfig{ChatID: chatID}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT base_url, email, api_token, project_key, issue_type
+		FROM jira_configs
+		WHERE chat_id = $1
+	`, chatID).Scan(&config.BaseURL, &config.Email, &config.APIToken, &config.ProjectKey, &config.IssueType)
+	if err != nil {
+		return nil, false
+	}
+
+	return config, true
+}
+
+func (s *Store) RemoveJiraConfig(ctx context.Context, chatID int64) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM jira_configs WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))", chatID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Jira config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) SetLinearConfig(ctx context.Context, chatID int64, apiKey string, target models.LinearTarget) error {
+	if err := s.requireUser(ctx, chatID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO linear_configs (chat_id, api_key, team_id, project_id)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (chat_id) DO UPDATE SET api_key = $2, team_id = $3, project_id = $4
+	`
+	if _, err := s.db.ExecContext(ctx, query, chatID, apiKey, target.TeamID, target.ProjectID); err != nil {
+		return fmt.Errorf("failed to save Linear config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) GetLinearConfig(ctx context.Context, chatID int64) (*models.LinearConfig, bool) {
+	config := &models.LinearConfig{
+		ChatID:   chatID,
+		Mappings: make(map[string]models.LinearTarget),
+	}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT api_key, team_id, project_id
+		FROM linear_configs
+		WHERE chat_id = $1
+	`, chatID).Scan(&config.APIKey, &config.Default.TeamID, &config.Default.ProjectID)
+	if err != nil {
+		return nil, false
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT repo, team_id, project_id FROM linear_mappings WHERE chat_id = $1", chatID)
+	if err != nil {
+		return config, true
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var repo string
+		var target models.LinearTarget
+		if err := rows.Scan(&repo, &target.TeamID, &target.ProjectID); err != nil {
+			continue
+		}
+		config.Mappings[repo] = target
+	}
+
+	return config, true
+}
+
+func (s *Store) RemoveLinearConfig(ctx context.Context, chatID int64) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM linear_configs WHERE chat_id = $1 AND ($2 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $2))", chatID, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Linear config: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Store) SetLinearMapping(ctx context.Context, chatID int64, repo string, target models.LinearTarget) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	query := `
+		INSERT INTO linear_mappings (chat_id, repo, team_id, project_id)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (SELECT 1 FROM linear_configs WHERE chat_id = $1 AND ($5 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $5)))
+		ON CONFLICT (chat_id, repo) DO UPDATE SET team_id = $3, project_id = $4
+	`
+	result, err := s.db.ExecContext(ctx, query, chatID, repo, target.TeamID, target.ProjectID, tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to save Linear mapping: %v", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("Linear is not configured")
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveLinearMapping(ctx context.Context, chatID int64, repo string) error {
+	tenantID, _ := store.TenantFromContext(ctx)
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM linear_mappings WHERE chat_id = $1 AND repo = $2 AND ($3 = '' OR chat_id IN (SELECT chat_id FROM users WHERE tenant_id = $3))", chatID, repo, tenantID); err != nil {
+		return fmt.Errorf("failed to remove Linear mapping: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/storetest"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := New(Scheme+filepath.Join(t.TempDir(), "monitor.db"), true)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	return s
+}
+
+func TestStore(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) store.Store {
+		return newTestStore(t)
+	})
+}
+
+func TestSchemaStatus(t *testing.T) {
+	dbURL := Scheme + filepath.Join(t.TempDir(), "monitor.db")
+	if _, err := New(dbURL, false); err == nil {
+		t.Fatal("New without migrate must fail on an empty database")
+	}
+
+	s, err := New(dbURL, true)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	s.Close()
+
+	status, err := ReadSchemaStatus(dbURL)
+	if err != nil {
+		t.Fatalf("failed to read schema status: %v", err)
+	}
+	if status.Version != len(migrations) || status.Check() != nil {
+		t.Errorf("schema status = %+v, want version %d", status, len(migrations))
+	}
+
+	// Reopening applies nothing and finds the schema current.
+	s, err = New(dbURL, false)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	s.Close()
+}
+
+func TestIsURL(t *testing.T) {
+	for url, want := range map[string]bool{
+		"sqlite:///var/lib/monitor/monitor.db": true,
+		"sqlite://monitor.db":                  true,
+		"postgres://localhost/monitor":         false,
+		"monitor.db":                           false,
+	} {
+		if got := IsURL(url); got != want {
+			t.Errorf("IsURL(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+// TestLock checks the leader locks of two stores sharing a file: one lock
+// has one holder, it can be taken over once released or once its lease
+// expired, and the previous holder then notices it lost it.
+func TestLock(t *testing.T) {
+	ctx := context.Background()
+	dbURL := Scheme + filepath.Join(t.TempDir(), "monitor.db")
+	first, err := New(dbURL, true)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer first.Close()
+	second, err := New(dbURL, false)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	defer second.Close()
+
+	lock, err := first.TryLock(ctx, 1)
+	if err != nil || lock == nil {
+		t.Fatalf("TryLock = %v, %v, want the lock", lock, err)
+	}
+	if other, err := second.TryLock(ctx, 1); err != nil || other != nil {
+		t.Fatalf("TryLock of a held lock = %v, %v, want nil", other, err)
+	}
+	if other, err := second.TryLock(ctx, 2); err != nil || other == nil {
+		t.Fatalf("TryLock of another lock = %v, %v, want the lock", other, err)
+	}
+	if held, err := second.LockHeld(ctx, 1); err != nil || !held {
+		t.Errorf("LockHeld = %v, %v, want true", held, err)
+	}
+	if err := lock.Check(ctx); err != nil {
+		t.Errorf("Check of a held lock: %v", err)
+	}
+
+	if err := lock.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if held, err := second.LockHeld(ctx, 1); err != nil || held {
+		t.Errorf("LockHeld after Release = %v, %v, want false", held, err)
+	}
+	taken, err := second.TryLock(ctx, 1)
+	if err != nil || taken == nil {
+		t.Fatalf("TryLock of a released lock = %v, %v, want the lock", taken, err)
+	}
+
+	// Expire the lease, as if second crashed.
+	if _, err := second.db.ExecContext(ctx, `UPDATE leader_locks SET expires_at = $1 WHERE id = 1`, timestamp(time.Now().Add(-time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := first.LockHeld(ctx, 1); err != nil || held {
+		t.Errorf("LockHeld of an expired lock = %v, %v, want false", held, err)
+	}
+	if lock, err = first.TryLock(ctx, 1); err != nil || lock == nil {
+		t.Fatalf("TryLock of an expired lock = %v, %v, want the lock", lock, err)
+	}
+	if err := taken.Check(ctx); err == nil {
+		t.Error("Check of a lock taken over must fail")
+	}
+}
@@ -1,15 +1,167 @@
 package store
 
-import "github.com/erkineren/repository-monitor/internal/models"
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrAccountNotFound = errors.New("account not found")
+	ErrOutboxNotFound  = errors.New("outbox entry not found")
+	ErrRuleNotFound    = errors.New("routing rule not found")
+)
+
+// HistoryQuery filters and paginates sent notification records.
+type HistoryQuery struct {
+	ChatID int64
+	Type   string
+	Since  time.Time
+	Limit  int
+	Offset int
+}
+
+// OutboxQuery filters and paginates outbox entries. A zero ChatID lists
+// the entries of every chat and an empty State those in every state.
+type OutboxQuery struct {
+	ChatID int64
+	State  string
+	Limit  int
+	Offset int
+}
 
 type Store interface {
 	Close() error
-	AddGitHubAccount(chatID int64, githubToken, githubUsername string) error
-	RemoveGitHubAccount(chatID int64, githubUsername string) error
-	ToggleGitHubAccount(chatID int64, githubUsername string) error
-	GetUser(chatID int64) (*models.User, bool)
-	GetAllUsers() ([]*models.User, error)
-	ShouldNotify(chatID int64, itemURL string, notificationType string, contentHash string, renotifyInterval int) (bool, error)
-	RecordNotification(chatID int64, itemURL string, notificationType string, contentHash string) error
-	CleanOldNotifications(renotifyInterval int) error
+	AddTenant(ctx context.Context, tenant models.Tenant) error
+	RemoveTenant(ctx context.Context, tenantID string) error
+	GetTenants(ctx context.Context) ([]models.Tenant, error)
+	ChatTenant(ctx context.Context, chatID int64) (string, bool, error)
+	SetFeatureFlag(ctx context.Context, flag models.FeatureFlag) error
+	RemoveFeatureFlag(ctx context.Context, name string) error
+	GetFeatureFlags(ctx context.Context) ([]models.FeatureFlag, error)
+	AddGitHubAccount(ctx context.Context, chatID int64, githubToken, githubUsername string, metadata models.GitHubAccountMetadata) error
+	RemoveGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error
+	ToggleGitHubAccount(ctx context.Context, chatID int64, githubUsername string) error
+	// GetAccountPreferences returns no disabled reasons for accounts
+	// whose preferences were never set. Removing an account removes its
+	// preferences.
+	GetAccountPreferences(ctx context.Context, chatID int64, githubUsername string) (models.AccountPreferences, error)
+	SetAccountPreferences(ctx context.Context, preferences models.AccountPreferences) error
+	AddGerritAccount(ctx context.Context, chatID int64, account models.GerritAccount) error
+	RemoveGerritAccount(ctx context.Context, chatID int64, baseURL, username string) error
+	GetUser(ctx context.Context, chatID int64) (*models.User, bool)
+	// ListUsers returns up to limit users with a chat ID above afterChatID,
+	// in chat ID order. Pass FirstChatID to start at the first user, or use
+	// ForEachUser to visit every user.
+	ListUsers(ctx context.Context, afterChatID int64, limit int) ([]*models.User, error)
+	AddImageSubscription(ctx context.Context, chatID int64, image, tagFilter string, seenTags []string) error
+	RemoveImageSubscription(ctx context.Context, chatID int64, image string) error
+	GetImageSubscriptions(ctx context.Context, chatID int64) ([]models.ImageSubscription, error)
+	GetSeenImageTags(ctx context.Context, subscriptionID int64) (map[string]bool, error)
+	MarkImageTagsSeen(ctx context.Context, subscriptionID int64, tags []string) error
+	AddDependencySubscription(ctx context.Context, chatID int64, repo string, seenVersions []string) error
+	RemoveDependencySubscription(ctx context.Context, chatID int64, repo string) error
+	GetDependencySubscriptions(ctx context.Context, chatID int64) ([]models.DependencySubscription, error)
+	GetSeenDependencyVersions(ctx context.Context, subscriptionID int64) (map[string]bool, error)
+	MarkDependencyVersionsSeen(ctx context.Context, subscriptionID int64, keys []string) error
+	GetAccountState(ctx context.Context, chatID int64, kind, account string) (models.AccountState, error)
+	SaveAccountState(ctx context.Context, state models.AccountState) error
+	GetPreferences(ctx context.Context, chatID int64) (models.Preferences, error)
+	SetPreferences(ctx context.Context, preferences models.Preferences) error
+	AddRepoSubscription(ctx context.Context, subscription models.RepoSubscription) (int64, error)
+	RemoveRepoSubscription(ctx context.Context, chatID int64, repo string) error
+	GetRepoSubscriptions(ctx context.Context, chatID int64) ([]models.RepoSubscription, error)
+	AddPathSubscription(ctx context.Context, subscription models.PathSubscription) (int64, error)
+	RemovePathSubscription(ctx context.Context, chatID int64, repo, pattern string) error
+	GetPathSubscriptions(ctx context.Context, chatID int64) ([]models.PathSubscription, error)
+	// Routing rules are returned in the order they were added, which is
+	// the order the dispatcher applies them in.
+	AddRoutingRule(ctx context.Context, rule models.RoutingRule) (int64, error)
+	RemoveRoutingRule(ctx context.Context, chatID, id int64) error
+	GetRoutingRules(ctx context.Context, chatID int64) ([]models.RoutingRule, error)
+	// RecordReviewRequest does nothing when the account was already asked
+	// to review the pull request and has not reviewed it yet.
+	RecordReviewRequest(ctx context.Context, request models.ReviewRequest) error
+	// GetReviewRequests returns the chat's review requests that arrived
+	// since the given time or are still pending, oldest first.
+	GetReviewRequests(ctx context.Context, chatID int64, since time.Time) ([]models.ReviewRequest, error)
+	CompleteReviewRequest(ctx context.Context, id int64, reviewedAt time.Time) error
+	RemoveReviewRequest(ctx context.Context, id int64) error
+	// RecordMention does nothing when the account was already mentioned
+	// on the issue or pull request and did not reply yet.
+	RecordMention(ctx context.Context, mention models.Mention) error
+	// GetMentions returns the chat's unanswered mentions, oldest first.
+	GetMentions(ctx context.Context, chatID int64) ([]models.Mention, error)
+	RemoveMention(ctx context.Context, id int64) error
+	MuteRepo(ctx context.Context, chatID int64, repo string) error
+	UnmuteRepo(ctx context.Context, chatID int64, repo string) error
+	// ShouldNotify and EnqueueNotification skip items sent within the
+	// renotify interval; with a negative interval, items sent at all. An
+	// item is identified by its URL and content hash within the chat,
+	// whatever its type, so the same activity reported to several of a
+	// chat's accounts for different reasons is sent once.
+	ShouldNotify(ctx context.Context, chatID int64, itemURL string, contentHash string, renotifyInterval time.Duration) (bool, error)
+	RecordNotification(ctx context.Context, chatID int64, itemURL string, notificationType string, contentHash string) error
+	EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error)
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]models.OutboxEntry, error)
+	MarkOutboxSent(ctx context.Context, id int64) error
+	MarkOutboxRetry(ctx context.Context, id int64, lastError string, nextAttempt time.Time) error
+	MarkOutboxDead(ctx context.Context, id int64, lastError string) error
+	// DeferOutbox hands a claimed entry back until the given time without
+	// counting the claim as a delivery attempt.
+	DeferOutbox(ctx context.Context, id int64, until time.Time) error
+	// CountPendingOutbox returns how many entries wait for delivery,
+	// including those waiting for a retry or for a paused chat.
+	CountPendingOutbox(ctx context.Context) (int, error)
+	// GetOutbox lists outbox entries with their delivery status, newest
+	// first.
+	GetOutbox(ctx context.Context, query OutboxQuery) ([]models.OutboxEntry, error)
+	// GetOutboxEntry returns an outbox entry in any state with its whole
+	// notification, e.g. for the buttons sent with it. It returns
+	// ErrOutboxNotFound for entries that were purged or belong to another
+	// tenant.
+	GetOutboxEntry(ctx context.Context, id int64) (models.OutboxEntry, error)
+	// RequeueOutbox gives a dead entry a fresh set of delivery attempts.
+	RequeueOutbox(ctx context.Context, id int64) error
+	// CleanOldNotifications purges history from before the given time.
+	// Dedup records are kept while the chat's renotify interval for their
+	// type has not passed, and for good when the type is never renotified.
+	// Only delivered outbox entries and reviewed review requests are
+	// purged; mentions stay until they are answered.
+	CleanOldNotifications(ctx context.Context, before time.Time) error
+	PurgeDeletedUsers(ctx context.Context, before time.Time) (int64, error)
+	GetNotificationHistory(ctx context.Context, query HistoryQuery) ([]models.NotificationRecord, error)
+	GetCalendarToken(ctx context.Context, chatID int64) (string, error)
+	GetUserByCalendarToken(ctx context.Context, token string) (*models.User, bool)
+	SetJiraConfig(ctx context.Context, config models.JiraConfig) error
+	GetJiraConfig(ctx context.Context, chatID int64) (*models.JiraConfig, bool)
+	RemoveJiraConfig(ctx context.Context, chatID int64) error
+	SetLinearConfig(ctx context.Context, chatID int64, apiKey string, target models.LinearTarget) error
+	GetLinearConfig(ctx context.Context, chatID int64) (*models.LinearConfig, bool)
+	RemoveLinearConfig(ctx context.Context, chatID int64) error
+	SetLinearMapping(ctx context.Context, chatID int64, repo string, target models.LinearTarget) error
+	RemoveLinearMapping(ctx context.Context, chatID int64, repo string) error
+}
+
+// Locker is implemented by stores that several instances share. Jobs
+// that must run on exactly one instance are led by the instance holding
+// their lock.
+type Locker interface {
+	// TryLock takes the lock with the given ID without waiting. It
+	// returns nil when another instance holds the lock.
+	TryLock(ctx context.Context, id int32) (Lock, error)
+	// LockHeld reports whether any instance holds the lock with the given
+	// ID.
+	LockHeld(ctx context.Context, id int32) (bool, error)
+}
+
+// Lock is a lock taken with Locker.TryLock.
+type Lock interface {
+	// Check verifies that the lock is still held, and fails when it was
+	// lost.
+	Check(ctx context.Context) error
+	Release(ctx context.Context) error
 }
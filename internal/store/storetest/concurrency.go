@@ -0,0 +1,204 @@
+package storetest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+// The tests below race operations that the bot and the poller run at the
+// same time. Run them with -race.
+
+func testConcurrentAccounts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := s.RemoveGitHubAccount(ctx, 1, "alice"); err != nil {
+				t.Errorf("RemoveGitHubAccount: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}); err != nil {
+				t.Errorf("AddGitHubAccount: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Whichever call ran last wins, but the user and its accounts must
+	// agree: an active user has an account and a removed one has none.
+	user, ok := s.GetUser(ctx, 1)
+	if ok && len(user.Accounts) != 1 {
+		t.Errorf("active user has %d accounts, want 1", len(user.Accounts))
+	}
+	users, err := s.ListUsers(ctx, store.FirstChatID, 10)
+	mustNoError(t, err)
+	if (len(users) == 1) != ok {
+		t.Errorf("ListUsers returned %d users, GetUser found the user: %v", len(users), ok)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	if user, ok := s.GetUser(ctx, 1); !ok || user.Accounts["alice"].Token != "token" {
+		t.Error("adding the account after the race must restore the user")
+	}
+}
+
+func testConcurrentEnqueue(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token-a", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token-b", "bob", models.GitHubAccountMetadata{}))
+
+	// Every account of the chat sees the same event, each with its own
+	// reason, and several jobs may poll them at once.
+	notification := models.Notification{Repo: "octo/repo", Message: "hello", URL: "https://github.com/octo/repo/issues/1", OccurredAt: time.Now().Truncate(time.Second)}
+	contentHash := notification.ContentHash()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		queued int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n := notification
+			n.Type, n.Account = "mention", "alice"
+			if i%2 == 1 {
+				n.Type, n.Account = "review_requested", "bob"
+			}
+			ok, err := s.EnqueueNotification(ctx, 1, n, contentHash, renotifyInterval)
+			if err != nil {
+				t.Errorf("EnqueueNotification: %v", err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				queued++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if queued != 1 {
+		t.Errorf("EnqueueNotification queued %d times, want exactly once", queued)
+	}
+	entries, err := s.GetOutbox(ctx, store.OutboxQuery{ChatID: 1, Limit: 10})
+	mustNoError(t, err)
+	if len(entries) != 1 {
+		t.Errorf("outbox has %d entries, want 1", len(entries))
+	}
+}
+
+func testConcurrentCalendarToken(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	// The first calls issue the token; none of them may replace a token
+	// another call already handed out.
+	tokens := make([]string, 10)
+	var wg sync.WaitGroup
+	for i := range tokens {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := s.GetCalendarToken(ctx, 1)
+			if err != nil {
+				t.Errorf("GetCalendarToken: %v", err)
+			}
+			tokens[i] = token
+		}()
+	}
+	wg.Wait()
+
+	for _, token := range tokens[1:] {
+		if token != tokens[0] {
+			t.Fatalf("GetCalendarToken returned %q and %q, want a single token", tokens[0], token)
+		}
+	}
+	if user, ok := s.GetUserByCalendarToken(ctx, tokens[0]); !ok || user.ChatID != 1 {
+		t.Error("GetUserByCalendarToken did not find the user by the issued token")
+	}
+}
+
+func testPurgeDuringPoll(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	// A poll that listed the user before its last account was removed
+	// keeps writing while the retention worker purges it. Its writes may
+	// fail, but none may outlive the user.
+	for chatID := int64(1); chatID <= 10; chatID++ {
+		mustNoError(t, s.AddGitHubAccount(ctx, chatID, "token", "alice", models.GitHubAccountMetadata{}))
+		mustNoError(t, s.RemoveGitHubAccount(ctx, chatID, "alice"))
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if _, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Errorf("PurgeDeletedUsers: %v", err)
+		}
+	}()
+	for chatID := int64(1); chatID <= 10; chatID++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			notification := models.Notification{Type: "mention", Repo: "octo/repo", Message: "hello", URL: "https://github.com/octo/repo/issues/1", Account: "alice"}
+			s.EnqueueNotification(ctx, chatID, notification, notification.ContentHash(), renotifyInterval)
+			s.RecordNotification(ctx, chatID, "https://github.com/octo/repo/pull/2", "review_requested", "hash")
+			s.SaveAccountState(ctx, models.AccountState{ChatID: chatID, Kind: models.AccountKindGitHub, Account: "alice", LastCheckedAt: time.Now()})
+		}()
+	}
+	wg.Wait()
+
+	for chatID := int64(1); chatID <= 10; chatID++ {
+		if _, found, _ := s.ChatTenant(ctx, chatID); found {
+			t.Errorf("chat %d survived the purge", chatID)
+			continue
+		}
+		entries, err := s.GetOutbox(ctx, store.OutboxQuery{ChatID: chatID, Limit: 10})
+		mustNoError(t, err)
+		history, err := s.GetNotificationHistory(ctx, store.HistoryQuery{ChatID: chatID, Limit: 10})
+		mustNoError(t, err)
+		state, err := s.GetAccountState(ctx, chatID, models.AccountKindGitHub, "alice")
+		mustNoError(t, err)
+		if len(entries) != 0 || len(history) != 0 || !state.LastCheckedAt.IsZero() {
+			t.Errorf("chat %d kept %d outbox entries, %d history records and state %+v after the purge", chatID, len(entries), len(history), state)
+		}
+	}
+
+	// Adding an account while the purge runs either restores the user or
+	// creates it anew; it must not be lost.
+	mustNoError(t, s.AddGitHubAccount(ctx, 100, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 100, "alice"))
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if _, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Errorf("PurgeDeletedUsers: %v", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := s.AddGitHubAccount(ctx, 100, "token", "alice", models.GitHubAccountMetadata{}); err != nil {
+			t.Errorf("AddGitHubAccount: %v", err)
+		}
+	}()
+	wg.Wait()
+
+	if user, ok := s.GetUser(ctx, 100); !ok || len(user.Accounts) != 1 {
+		t.Error("an account added during the purge must keep its user")
+	}
+}
@@ -0,0 +1,1136 @@
+// Package storetest is the contract every store.Store implementation must
+// satisfy. Backends run it from their own tests:
+//
+//	func TestStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store { return memory.New() })
+//	}
+//
+// newStore must return an empty store for every call.
+package storetest
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/store"
+)
+
+const renotifyInterval = 24 * time.Hour
+
+// Run runs the contract suite against stores created by newStore.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s store.Store)
+	}{
+		{"GitHubAccounts", testGitHubAccounts},
+		{"GitHubAccountMetadata", testGitHubAccountMetadata},
+		{"AccountPreferences", testAccountPreferences},
+		{"ListUsers", testListUsers},
+		{"GerritAccounts", testGerritAccounts},
+		{"SoftDelete", testSoftDelete},
+		{"Tenants", testTenants},
+		{"TenantScoping", testTenantScoping},
+		{"FeatureFlags", testFeatureFlags},
+		{"Dedup", testDedup},
+		{"Outbox", testOutbox},
+		{"NotificationHistory", testNotificationHistory},
+		{"AccountState", testAccountState},
+		{"ImageSubscriptions", testImageSubscriptions},
+		{"DependencySubscriptions", testDependencySubscriptions},
+		{"RepoSubscriptions", testRepoSubscriptions},
+		{"PathSubscriptions", testPathSubscriptions},
+		{"Preferences", testPreferences},
+		{"RoutingRules", testRoutingRules},
+		{"ReviewRequests", testReviewRequests},
+		{"Mentions", testMentions},
+		{"Integrations", testIntegrations},
+		{"CalendarToken", testCalendarToken},
+		{"ConcurrentAccounts", testConcurrentAccounts},
+		{"ConcurrentEnqueue", testConcurrentEnqueue},
+		{"ConcurrentCalendarToken", testConcurrentCalendarToken},
+		{"PurgeDuringPoll", testPurgeDuringPoll},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newStore(t)
+			t.Cleanup(func() { s.Close() })
+			test.run(t, s)
+		})
+	}
+}
+
+func mustNoError(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func testGitHubAccounts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if _, ok := s.GetUser(ctx, 1); ok {
+		t.Fatal("GetUser found a user in an empty store")
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token-a", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token-b", "bob", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token-a2", "alice", models.GitHubAccountMetadata{}))
+
+	user, ok := s.GetUser(ctx, 1)
+	if !ok {
+		t.Fatal("GetUser did not find the user")
+	}
+	if user.TenantID != store.DefaultTenant {
+		t.Errorf("TenantID = %q, want %q", user.TenantID, store.DefaultTenant)
+	}
+	if len(user.Accounts) != 2 {
+		t.Fatalf("got %d accounts, want 2", len(user.Accounts))
+	}
+	if account := user.Accounts["alice"]; account.Token != "token-a2" || !account.IsActive {
+		t.Errorf("re-adding an account must replace the token and activate it, got %+v", account)
+	}
+
+	mustNoError(t, s.ToggleGitHubAccount(ctx, 1, "alice"))
+	user, _ = s.GetUser(ctx, 1)
+	if user.Accounts["alice"].IsActive {
+		t.Error("ToggleGitHubAccount did not deactivate the account")
+	}
+	if err := s.ToggleGitHubAccount(ctx, 1, "nobody"); err != store.ErrAccountNotFound {
+		t.Errorf("ToggleGitHubAccount(unknown) = %v, want ErrAccountNotFound", err)
+	}
+
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 1, "alice"))
+	user, _ = s.GetUser(ctx, 1)
+	if _, ok := user.Accounts["alice"]; ok {
+		t.Error("RemoveGitHubAccount did not remove the account")
+	}
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 2, "nobody"))
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 3, "token-c", "carol", models.GitHubAccountMetadata{}))
+	users, err := s.ListUsers(ctx, store.FirstChatID, 10)
+	mustNoError(t, err)
+	if len(users) != 2 || users[0].ChatID != 1 || users[1].ChatID != 3 {
+		t.Errorf("ListUsers must return the users in chat ID order, got %d users", len(users))
+	}
+}
+
+func testListUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	// Group chats have negative IDs and must be listed too.
+	chatIDs := []int64{-100, 1, 2, 3, 4}
+	for _, chatID := range chatIDs {
+		mustNoError(t, s.AddGitHubAccount(ctx, chatID, "token", "alice", models.GitHubAccountMetadata{}))
+	}
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 3, "alice"))
+
+	users, err := s.ListUsers(ctx, store.FirstChatID, 2)
+	mustNoError(t, err)
+	if len(users) != 2 || users[0].ChatID != -100 || users[1].ChatID != 1 {
+		t.Fatalf("first page = %d users, want chats -100 and 1", len(users))
+	}
+	users, err = s.ListUsers(ctx, users[1].ChatID, 2)
+	mustNoError(t, err)
+	if len(users) != 2 || users[0].ChatID != 2 || users[1].ChatID != 4 {
+		t.Fatalf("second page = %d users, want chats 2 and 4", len(users))
+	}
+	users, err = s.ListUsers(ctx, 4, 2)
+	mustNoError(t, err)
+	if len(users) != 0 {
+		t.Errorf("page after the last user = %d users, want none", len(users))
+	}
+
+	var visited []int64
+	err = store.ForEachUser(ctx, s, func(user *models.User) error {
+		visited = append(visited, user.ChatID)
+		return nil
+	})
+	mustNoError(t, err)
+	if len(visited) != 4 {
+		t.Errorf("ForEachUser visited %v, want every user with an account", visited)
+	}
+}
+
+func testGitHubAccountMetadata(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	expiresAt := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	metadata := models.GitHubAccountMetadata{Scopes: []string{"repo", "read:org"}, ExpiresAt: expiresAt, Note: "work laptop"}
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", metadata))
+
+	user, _ := s.GetUser(ctx, 1)
+	account := user.Accounts["alice"]
+	if len(account.Scopes) != 2 || account.Scopes[0] != "repo" || !account.ExpiresAt.Equal(expiresAt) || account.Note != "work laptop" {
+		t.Errorf("account = %+v, want the stored metadata", account)
+	}
+	if account.AddedAt.IsZero() {
+		t.Error("AddedAt must be set when the account is added")
+	}
+	addedAt := account.AddedAt
+
+	// A new token replaces scopes and expiry but keeps the note and AddedAt.
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token2", "alice", models.GitHubAccountMetadata{}))
+	user, _ = s.GetUser(ctx, 1)
+	account = user.Accounts["alice"]
+	if len(account.Scopes) != 0 || !account.ExpiresAt.IsZero() {
+		t.Errorf("re-adding must replace scopes and expiry, got %+v", account)
+	}
+	if account.Note != "work laptop" || !account.AddedAt.Equal(addedAt) {
+		t.Errorf("re-adding must keep the note and AddedAt, got %+v", account)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token3", "alice", models.GitHubAccountMetadata{Note: "ci"}))
+	user, _ = s.GetUser(ctx, 1)
+	if user.Accounts["alice"].Note != "ci" {
+		t.Error("re-adding with a note must replace the note")
+	}
+}
+
+func testGerritAccounts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	account := models.GerritAccount{BaseURL: "https://review.example.com", Username: "alice", Password: "secret"}
+	mustNoError(t, s.AddGerritAccount(ctx, 1, account))
+
+	user, ok := s.GetUser(ctx, 1)
+	if !ok || len(user.GerritAccounts) != 1 {
+		t.Fatal("GetUser did not return the Gerrit account")
+	}
+	if got := user.GerritAccounts[0]; got.Password != "secret" || !got.IsActive {
+		t.Errorf("got %+v, want an active account with its password", got)
+	}
+
+	if err := s.RemoveGerritAccount(ctx, 1, account.BaseURL, "bob"); err != store.ErrAccountNotFound {
+		t.Errorf("RemoveGerritAccount(unknown) = %v, want ErrAccountNotFound", err)
+	}
+	mustNoError(t, s.RemoveGerritAccount(ctx, 1, account.BaseURL, account.Username))
+	if _, ok := s.GetUser(ctx, 1); ok {
+		t.Error("a user without accounts must not be returned")
+	}
+}
+
+func testSoftDelete(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.MuteRepo(ctx, 1, "octo/repo"))
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 1, "alice"))
+
+	if _, ok := s.GetUser(ctx, 1); ok {
+		t.Fatal("removing the last account must remove the user")
+	}
+	if err := s.MuteRepo(ctx, 1, "octo/other"); err != store.ErrUserNotFound {
+		t.Errorf("MuteRepo on a deleted user = %v, want ErrUserNotFound", err)
+	}
+
+	// Adding an account within the grace period restores the user's data.
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	user, ok := s.GetUser(ctx, 1)
+	if !ok || !user.MutedRepos["octo/repo"] {
+		t.Fatal("restoring the user must keep its muted repositories")
+	}
+
+	if purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute)); err != nil || purged != 0 {
+		t.Errorf("PurgeDeletedUsers = %d, %v, want an active user to be kept", purged, err)
+	}
+
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 1, "alice"))
+	if purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(-time.Hour)); err != nil || purged != 0 {
+		t.Errorf("PurgeDeletedUsers = %d, %v, want users deleted after the cutoff to be kept", purged, err)
+	}
+	purged, err := s.PurgeDeletedUsers(ctx, time.Now().Add(time.Minute))
+	mustNoError(t, err)
+	if purged != 1 {
+		t.Errorf("PurgeDeletedUsers = %d, want 1", purged)
+	}
+	if _, found, _ := s.ChatTenant(ctx, 1); found {
+		t.Error("a purged user must be gone")
+	}
+}
+
+func testTenants(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.AddTenant(ctx, models.Tenant{ID: store.DefaultTenant, BotToken: "bot"}); err == nil {
+		t.Error("AddTenant must reject the default tenant ID")
+	}
+	mustNoError(t, s.AddTenant(ctx, models.Tenant{ID: "acme", Name: "Acme", BotToken: "bot-acme", APIToken: "api-acme"}))
+	mustNoError(t, s.AddTenant(ctx, models.Tenant{ID: "beta", BotToken: "bot-beta"}))
+
+	tenants, err := s.GetTenants(ctx)
+	mustNoError(t, err)
+	if len(tenants) != 2 || tenants[0].ID != "acme" || tenants[0].APIToken != "api-acme" {
+		t.Fatalf("GetTenants = %+v, want acme and beta in ID order", tenants)
+	}
+
+	acme := store.WithTenant(ctx, "acme")
+	beta := store.WithTenant(ctx, "beta")
+	mustNoError(t, s.AddGitHubAccount(acme, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 2, "token", "bob", models.GitHubAccountMetadata{}))
+
+	if tenantID, found, err := s.ChatTenant(ctx, 1); err != nil || !found || tenantID != "acme" {
+		t.Errorf("ChatTenant = %q, %v, %v, want acme", tenantID, found, err)
+	}
+	if err := s.AddGitHubAccount(beta, 1, "token", "mallory", models.GitHubAccountMetadata{}); err != store.ErrTenantMismatch {
+		t.Errorf("adding an account to another tenant's chat = %v, want ErrTenantMismatch", err)
+	}
+	if _, ok := s.GetUser(beta, 1); ok {
+		t.Error("GetUser must not return users of other tenants")
+	}
+	if err := s.MuteRepo(beta, 1, "octo/repo"); err != store.ErrUserNotFound {
+		t.Errorf("MuteRepo for another tenant's user = %v, want ErrUserNotFound", err)
+	}
+
+	users, err := s.ListUsers(acme, store.FirstChatID, 10)
+	mustNoError(t, err)
+	if len(users) != 1 || users[0].ChatID != 1 {
+		t.Errorf("ListUsers(acme) returned %d users, want only chat 1", len(users))
+	}
+	users, err = s.ListUsers(ctx, store.FirstChatID, 10)
+	mustNoError(t, err)
+	if len(users) != 2 {
+		t.Errorf("unscoped ListUsers returned %d users, want 2", len(users))
+	}
+
+	if err := s.RemoveTenant(ctx, "acme"); err == nil {
+		t.Error("RemoveTenant must refuse tenants that still have users")
+	}
+	mustNoError(t, s.RemoveTenant(ctx, "beta"))
+	if err := s.RemoveTenant(ctx, "beta"); err == nil {
+		t.Error("RemoveTenant must fail for unknown tenants")
+	}
+}
+
+// testTenantScoping checks that a context scoped to one tenant cannot
+// change or list the accounts, subscriptions, mutes, routing rules and
+// integrations of another tenant's chat.
+func testTenantScoping(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddTenant(ctx, models.Tenant{ID: "acme", BotToken: "bot-acme"}))
+	mustNoError(t, s.AddTenant(ctx, models.Tenant{ID: "beta", BotToken: "bot-beta"}))
+	acme := store.WithTenant(ctx, "acme")
+	beta := store.WithTenant(ctx, "beta")
+
+	mustNoError(t, s.AddGitHubAccount(acme, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	_, err := s.AddRepoSubscription(acme, models.RepoSubscription{ChatID: 1, Repo: "octo/repo"})
+	mustNoError(t, err)
+	_, err = s.AddPathSubscription(acme, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "api/"})
+	mustNoError(t, err)
+	ruleID, err := s.AddRoutingRule(acme, models.RoutingRule{ChatID: 1, Conditions: models.Conditions{Repo: "octo/*"}, Silent: true})
+	mustNoError(t, err)
+	mustNoError(t, s.MuteRepo(acme, 1, "octo/muted"))
+	mustNoError(t, s.SetLinearConfig(acme, 1, "key", models.LinearTarget{TeamID: "T1"}))
+
+	if err := s.ToggleGitHubAccount(beta, 1, "alice"); err != store.ErrAccountNotFound {
+		t.Errorf("ToggleGitHubAccount of another tenant's chat = %v, want ErrAccountNotFound", err)
+	}
+	if err := s.SetAccountPreferences(beta, models.AccountPreferences{ChatID: 1, Username: "alice", SecurityAlerts: true}); err != store.ErrAccountNotFound {
+		t.Errorf("SetAccountPreferences of another tenant's chat = %v, want ErrAccountNotFound", err)
+	}
+	if err := s.RemoveRepoSubscription(beta, 1, "octo/repo"); err == nil {
+		t.Error("RemoveRepoSubscription must fail for another tenant's chat")
+	}
+	if err := s.RemovePathSubscription(beta, 1, "octo/repo", "api/"); err == nil {
+		t.Error("RemovePathSubscription must fail for another tenant's chat")
+	}
+	if err := s.RemoveRoutingRule(beta, 1, ruleID); err != store.ErrRuleNotFound {
+		t.Errorf("RemoveRoutingRule of another tenant's chat = %v, want ErrRuleNotFound", err)
+	}
+	if rules, err := s.GetRoutingRules(beta, 1); err != nil || len(rules) != 0 {
+		t.Errorf("GetRoutingRules of another tenant's chat = %+v, %v, want none", rules, err)
+	}
+	mustNoError(t, s.UnmuteRepo(beta, 1, "octo/muted"))
+	mustNoError(t, s.RemoveLinearConfig(beta, 1))
+
+	user, ok := s.GetUser(acme, 1)
+	if !ok || !user.Accounts["alice"].IsActive || !user.MutedRepos["octo/muted"] {
+		t.Errorf("another tenant changed the user: %+v", user)
+	}
+	if _, ok := s.GetLinearConfig(acme, 1); !ok {
+		t.Error("another tenant removed the Linear config")
+	}
+	if rules, err := s.GetRoutingRules(acme, 1); err != nil || len(rules) != 1 {
+		t.Errorf("GetRoutingRules = %+v, %v, want the rule", rules, err)
+	}
+	mustNoError(t, s.RemoveRoutingRule(acme, 1, ruleID))
+	mustNoError(t, s.RemoveRepoSubscription(ctx, 1, "octo/repo"))
+}
+
+func testFeatureFlags(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.SetFeatureFlag(ctx, models.FeatureFlag{Name: "new-renderer", Percent: 101}); err == nil {
+		t.Error("SetFeatureFlag must reject percentages above 100")
+	}
+	mustNoError(t, s.SetFeatureFlag(ctx, models.FeatureFlag{Name: "new-renderer", Percent: 10, ChatIDs: []int64{1, -2}}))
+	mustNoError(t, s.SetFeatureFlag(ctx, models.FeatureFlag{Name: "notification.image_tag", Enabled: true}))
+	mustNoError(t, s.SetFeatureFlag(ctx, models.FeatureFlag{Name: "new-renderer", Percent: 20, ChatIDs: []int64{3}}))
+
+	flags, err := s.GetFeatureFlags(ctx)
+	mustNoError(t, err)
+	if len(flags) != 2 || flags[0].Name != "new-renderer" || flags[1].Name != "notification.image_tag" {
+		t.Fatalf("GetFeatureFlags = %+v, want new-renderer and notification.image_tag in name order", flags)
+	}
+	if flags[0].Percent != 20 || len(flags[0].ChatIDs) != 1 || flags[0].ChatIDs[0] != 3 || flags[0].UpdatedAt.IsZero() {
+		t.Errorf("SetFeatureFlag must replace the flag, got %+v", flags[0])
+	}
+	if !flags[1].Enabled {
+		t.Error("Enabled was not stored")
+	}
+
+	mustNoError(t, s.RemoveFeatureFlag(ctx, "new-renderer"))
+	if err := s.RemoveFeatureFlag(ctx, "new-renderer"); err == nil {
+		t.Error("RemoveFeatureFlag must fail for unknown flags")
+	}
+	flags, err = s.GetFeatureFlags(ctx)
+	mustNoError(t, err)
+	if len(flags) != 1 {
+		t.Errorf("GetFeatureFlags returned %d flags after removal, want 1", len(flags))
+	}
+}
+
+func testDedup(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	notification := models.Notification{Type: "mention", Repo: "octo/repo", Message: "hello", URL: "https://github.com/octo/repo/issues/1"}
+	hash := notification.ContentHash()
+
+	shouldNotify, err := s.ShouldNotify(ctx, 1, notification.URL, hash, renotifyInterval)
+	mustNoError(t, err)
+	if !shouldNotify {
+		t.Fatal("ShouldNotify must be true for a new notification")
+	}
+
+	queued, err := s.EnqueueNotification(ctx, 1, notification, hash, renotifyInterval)
+	mustNoError(t, err)
+	if !queued {
+		t.Fatal("EnqueueNotification did not queue a new notification")
+	}
+
+	queued, err = s.EnqueueNotification(ctx, 1, notification, hash, renotifyInterval)
+	mustNoError(t, err)
+	if queued {
+		t.Error("EnqueueNotification queued a notification within the renotify interval")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, notification.URL, hash, renotifyInterval); shouldNotify {
+		t.Error("ShouldNotify must be false within the renotify interval")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, notification.URL, "other-hash", renotifyInterval); !shouldNotify {
+		t.Error("ShouldNotify must be true when the content changed")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 2, notification.URL, hash, renotifyInterval); !shouldNotify {
+		t.Error("ShouldNotify must be true for another chat")
+	}
+
+	// Another of the chat's accounts sees the same activity for another
+	// reason.
+	other := notification
+	other.Type = "review_requested"
+	other.Account = "bob"
+	if queued, _ := s.EnqueueNotification(ctx, 1, other, hash, renotifyInterval); queued {
+		t.Error("EnqueueNotification queued an item already sent with another type")
+	}
+
+	mustNoError(t, s.RecordNotification(ctx, 1, "https://example.com/other", "release", "h"))
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, "https://example.com/other", "h", renotifyInterval); shouldNotify {
+		t.Error("ShouldNotify must be false after RecordNotification")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, notification.URL, hash, -1); shouldNotify {
+		t.Error("ShouldNotify must be false for a type that is never renotified")
+	}
+	if queued, _ := s.EnqueueNotification(ctx, 1, notification, hash, -1); queued {
+		t.Error("EnqueueNotification queued a notification of a type that is never renotified")
+	}
+
+	preferences := models.DefaultPreferences(1)
+	preferences.RenotifyIntervals = map[string]int{"release": models.RenotifyNever, "pull_request": 1000}
+	mustNoError(t, s.SetPreferences(ctx, preferences))
+	mustNoError(t, s.RecordNotification(ctx, 1, "https://example.com/pull", "pull_request", "h"))
+
+	mustNoError(t, s.CleanOldNotifications(ctx, time.Now().Add(time.Minute)))
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, notification.URL, hash, renotifyInterval); !shouldNotify {
+		t.Error("ShouldNotify must be true once the history was cleaned")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, "https://example.com/other", "h", models.RenotifyNever); shouldNotify {
+		t.Error("CleanOldNotifications must keep the history of types that are never renotified")
+	}
+	if shouldNotify, _ := s.ShouldNotify(ctx, 1, "https://example.com/pull", "h", 1000*time.Hour); shouldNotify {
+		t.Error("CleanOldNotifications must keep the history of types within their renotify interval")
+	}
+}
+
+func testOutbox(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddTenant(ctx, models.Tenant{ID: "acme", BotToken: "bot"}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(store.WithTenant(ctx, "acme"), 2, "token", "bob", models.GitHubAccountMetadata{}))
+
+	occurred := time.Now().Add(-time.Minute).Truncate(time.Second)
+	for i, chatID := range []int64{1, 2, 1} {
+		notification := models.Notification{Type: "mention", Repo: "octo/repo", Message: "hello", URL: "https://github.com/octo/repo/issues/" + string(rune('1'+i)), OccurredAt: occurred}
+		notification.EventID = models.NewEventID("github", notification.URL, notification.Type, occurred)
+		notification.Severity = models.SeverityHigh
+		notification.Labels = []string{"bug"}
+		notification.ThreadID = "42"
+		notification.Account = "alice"
+		notification.Fields = models.Fields{Title: "Crash on start", Number: 7}
+		if _, err := s.EnqueueNotification(ctx, chatID, notification, notification.ContentHash(), renotifyInterval); err != nil {
+			t.Fatalf("EnqueueNotification: %v", err)
+		}
+	}
+
+	entries, err := s.ClaimOutbox(ctx, 2, time.Minute)
+	mustNoError(t, err)
+	if len(entries) != 2 {
+		t.Fatalf("ClaimOutbox(2) returned %d entries", len(entries))
+	}
+	if entries[0].ID >= entries[1].ID {
+		t.Error("ClaimOutbox must return entries in queue order")
+	}
+	if entries[0].ChatID != 1 || entries[0].TenantID != store.DefaultTenant || entries[0].Attempts != 1 {
+		t.Errorf("first entry = %+v, want chat 1 of the default tenant on its first attempt", entries[0])
+	}
+	if entries[1].TenantID != "acme" {
+		t.Errorf("second entry tenant = %q, want acme", entries[1].TenantID)
+	}
+	if entries[0].Notification.Message != "hello" || entries[0].Notification.Repo != "octo/repo" || !entries[0].Notification.OccurredAt.Equal(occurred) ||
+		entries[0].Notification.EventID != models.NewEventID("github", entries[0].Notification.URL, "mention", occurred) || entries[0].Notification.Severity != models.SeverityHigh ||
+		len(entries[0].Notification.Labels) != 1 || entries[0].Notification.Labels[0] != "bug" || entries[0].Notification.ThreadID != "42" || entries[0].Notification.Account != "alice" ||
+		entries[0].Notification.Fields != (models.Fields{Title: "Crash on start", Number: 7}) {
+		t.Errorf("entry notification = %+v, want the queued notification", entries[0].Notification)
+	}
+
+	if pending, err := s.CountPendingOutbox(ctx); err != nil || pending != 3 {
+		t.Errorf("CountPendingOutbox = %d, %v, want the 3 claimed entries", pending, err)
+	}
+
+	remaining, err := s.ClaimOutbox(ctx, 10, time.Minute)
+	mustNoError(t, err)
+	if len(remaining) != 1 {
+		t.Fatalf("claimed entries must be leased, got %d entries on the second claim", len(remaining))
+	}
+
+	mustNoError(t, s.MarkOutboxSent(ctx, entries[0].ID))
+	mustNoError(t, s.MarkOutboxDead(ctx, entries[1].ID, "blocked"))
+	mustNoError(t, s.MarkOutboxRetry(ctx, remaining[0].ID, "timeout", time.Now().Add(-time.Second)))
+	if pending, err := s.CountPendingOutbox(ctx); err != nil || pending != 1 {
+		t.Errorf("CountPendingOutbox = %d, %v, want only the retried entry", pending, err)
+	}
+
+	retried, err := s.ClaimOutbox(ctx, 10, time.Minute)
+	mustNoError(t, err)
+	if len(retried) != 1 || retried[0].ID != remaining[0].ID || retried[0].Attempts != 2 {
+		t.Fatalf("ClaimOutbox after a retry = %+v, want only the retried entry on its second attempt", retried)
+	}
+
+	mustNoError(t, s.DeferOutbox(ctx, retried[0].ID, time.Now().Add(-time.Second)))
+	deferred, err := s.ClaimOutbox(ctx, 10, time.Minute)
+	mustNoError(t, err)
+	if len(deferred) != 1 || deferred[0].Attempts != 2 {
+		t.Fatalf("ClaimOutbox after DeferOutbox = %+v, want the entry still on its second attempt", deferred)
+	}
+
+	mustNoError(t, s.MarkOutboxRetry(ctx, retried[0].ID, "timeout", time.Now().Add(time.Hour)))
+	if entries, _ := s.ClaimOutbox(ctx, 10, time.Minute); len(entries) != 0 {
+		t.Errorf("entries must not be claimed before their next attempt, got %d", len(entries))
+	}
+
+	listed, err := s.GetOutbox(ctx, store.OutboxQuery{Limit: 10})
+	mustNoError(t, err)
+	if len(listed) != 3 || listed[0].ID != remaining[0].ID || listed[2].ID != entries[0].ID {
+		t.Fatalf("GetOutbox = %+v, want all three entries newest first", listed)
+	}
+	if listed[0].State() != models.OutboxPending || listed[0].LastError != "timeout" || listed[0].NextAttemptAt.IsZero() {
+		t.Errorf("pending entry = %+v, want its last error and next attempt", listed[0])
+	}
+	if listed[2].State() != models.OutboxSent {
+		t.Errorf("sent entry state = %q", listed[2].State())
+	}
+
+	dead, err := s.GetOutbox(ctx, store.OutboxQuery{State: models.OutboxDead, Limit: 10})
+	mustNoError(t, err)
+	if len(dead) != 1 || dead[0].ID != entries[1].ID || dead[0].LastError != "blocked" || dead[0].TenantID != "acme" {
+		t.Fatalf("dead entries = %+v, want the entry marked dead", dead)
+	}
+	if chat, _ := s.GetOutbox(ctx, store.OutboxQuery{ChatID: 1, Limit: 10}); len(chat) != 2 {
+		t.Errorf("GetOutbox for chat 1 returned %d entries, want 2", len(chat))
+	}
+	if scoped, _ := s.GetOutbox(store.WithTenant(ctx, "acme"), store.OutboxQuery{Limit: 10}); len(scoped) != 1 {
+		t.Errorf("GetOutbox scoped to acme returned %d entries, want 1", len(scoped))
+	}
+	if _, err := s.GetOutbox(ctx, store.OutboxQuery{State: "lost", Limit: 10}); err == nil {
+		t.Error("GetOutbox must reject unknown states")
+	}
+
+	sent, err := s.GetOutboxEntry(ctx, entries[0].ID)
+	mustNoError(t, err)
+	if sent.ID != entries[0].ID || sent.ChatID != 1 || sent.State() != models.OutboxSent || sent.Notification.URL != entries[0].Notification.URL ||
+		sent.Notification.ThreadID != "42" || sent.Notification.Account != "alice" || len(sent.Notification.Labels) != 1 || !sent.Notification.OccurredAt.Equal(occurred) ||
+		sent.Notification.Fields.Title != "Crash on start" {
+		t.Errorf("GetOutboxEntry = %+v, want the sent entry with its whole notification", sent)
+	}
+	if _, err := s.GetOutboxEntry(store.WithTenant(ctx, "acme"), entries[0].ID); err != store.ErrOutboxNotFound {
+		t.Errorf("GetOutboxEntry of another tenant's entry = %v, want ErrOutboxNotFound", err)
+	}
+	if _, err := s.GetOutboxEntry(ctx, entries[0].ID+1000); err != store.ErrOutboxNotFound {
+		t.Errorf("GetOutboxEntry of a missing entry = %v, want ErrOutboxNotFound", err)
+	}
+
+	if err := s.RequeueOutbox(ctx, entries[0].ID); err != store.ErrOutboxNotFound {
+		t.Errorf("RequeueOutbox of a sent entry = %v, want ErrOutboxNotFound", err)
+	}
+	if err := s.RequeueOutbox(store.WithTenant(ctx, store.DefaultTenant), entries[1].ID); err != store.ErrOutboxNotFound {
+		t.Errorf("RequeueOutbox of another tenant's entry = %v, want ErrOutboxNotFound", err)
+	}
+	mustNoError(t, s.RequeueOutbox(ctx, entries[1].ID))
+	requeued, err := s.ClaimOutbox(ctx, 10, time.Minute)
+	mustNoError(t, err)
+	if len(requeued) != 1 || requeued[0].ID != entries[1].ID || requeued[0].Attempts != 1 {
+		t.Errorf("ClaimOutbox after a requeue = %+v, want the requeued entry on its first attempt", requeued)
+	}
+}
+
+func testNotificationHistory(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 2, "token", "bob", models.GitHubAccountMetadata{}))
+
+	start := time.Now().Add(-time.Minute)
+	mustNoError(t, s.RecordNotification(ctx, 1, "https://example.com/1", "mention", "a"))
+	mustNoError(t, s.RecordNotification(ctx, 1, "https://example.com/2", "release", "b"))
+	mustNoError(t, s.RecordNotification(ctx, 1, "https://example.com/3", "mention", "c"))
+	mustNoError(t, s.RecordNotification(ctx, 2, "https://example.com/4", "mention", "d"))
+
+	records, err := s.GetNotificationHistory(ctx, store.HistoryQuery{ChatID: 1, Since: start, Limit: 10})
+	mustNoError(t, err)
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	if records[0].ItemURL != "https://example.com/3" {
+		t.Errorf("history must be newest first, got %s first", records[0].ItemURL)
+	}
+
+	records, err = s.GetNotificationHistory(ctx, store.HistoryQuery{ChatID: 1, Type: "mention", Since: start, Limit: 1, Offset: 1})
+	mustNoError(t, err)
+	if len(records) != 1 || records[0].ItemURL != "https://example.com/1" {
+		t.Errorf("filtered page = %+v, want only the first mention", records)
+	}
+
+	records, err = s.GetNotificationHistory(ctx, store.HistoryQuery{ChatID: 1, Since: time.Now().Add(time.Minute), Limit: 10})
+	mustNoError(t, err)
+	if len(records) != 0 {
+		t.Errorf("got %d records newer than the future, want none", len(records))
+	}
+
+	records, err = s.GetNotificationHistory(store.WithTenant(ctx, "acme"), store.HistoryQuery{ChatID: 1, Since: start, Limit: 10})
+	mustNoError(t, err)
+	if len(records) != 0 {
+		t.Errorf("got %d records of another tenant's user, want none", len(records))
+	}
+}
+
+func testAccountState(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	state, err := s.GetAccountState(ctx, 1, models.AccountKindGitHub, "alice")
+	mustNoError(t, err)
+	if state.ChatID != 1 || state.Account != "alice" || !state.LastCheckedAt.IsZero() {
+		t.Errorf("initial state = %+v, want an empty state for the account", state)
+	}
+
+	checked := time.Now().Truncate(time.Second)
+	state.LastCheckedAt = checked
+	state.LastModified = "Mon, 01 Jan 2024 00:00:00 GMT"
+	state.ConsecutiveErrors = 2
+	state.PollInterval = 120
+	state.LastError = "401 Bad credentials"
+	mustNoError(t, s.SaveAccountState(ctx, state))
+
+	saved, err := s.GetAccountState(ctx, 1, models.AccountKindGitHub, "alice")
+	mustNoError(t, err)
+	if !saved.LastCheckedAt.Equal(checked) || saved.LastModified != state.LastModified || saved.ConsecutiveErrors != 2 || saved.PollInterval != 120 || saved.LastError != state.LastError {
+		t.Errorf("saved state = %+v, want %+v", saved, state)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "bob", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 1, "alice"))
+	removed, err := s.GetAccountState(ctx, 1, models.AccountKindGitHub, "alice")
+	mustNoError(t, err)
+	if removed.ConsecutiveErrors != 0 {
+		t.Error("removing an account must remove its polling state")
+	}
+}
+
+func testImageSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if err := s.AddImageSubscription(ctx, 1, "library/nginx", "", nil); err != store.ErrUserNotFound {
+		t.Errorf("AddImageSubscription without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddImageSubscription(ctx, 1, "library/nginx", "1.*", []string{"1.25"}))
+	mustNoError(t, s.AddImageSubscription(ctx, 1, "ghcr.io/octo/app", "", nil))
+
+	subscriptions, err := s.GetImageSubscriptions(ctx, 1)
+	mustNoError(t, err)
+	if len(subscriptions) != 2 || subscriptions[0].Image != "ghcr.io/octo/app" {
+		t.Fatalf("GetImageSubscriptions = %+v, want both images ordered by name", subscriptions)
+	}
+	nginx := subscriptions[1]
+	if nginx.TagFilter != "1.*" {
+		t.Errorf("TagFilter = %q, want 1.*", nginx.TagFilter)
+	}
+
+	mustNoError(t, s.MarkImageTagsSeen(ctx, nginx.ID, []string{"1.26", "1.25"}))
+	seen, err := s.GetSeenImageTags(ctx, nginx.ID)
+	mustNoError(t, err)
+	if len(seen) != 2 || !seen["1.25"] || !seen["1.26"] {
+		t.Errorf("seen tags = %v, want 1.25 and 1.26", seen)
+	}
+
+	// Re-subscribing starts over from the given tags.
+	mustNoError(t, s.AddImageSubscription(ctx, 1, "library/nginx", "", []string{"1.27"}))
+	subscriptions, _ = s.GetImageSubscriptions(ctx, 1)
+	seen, _ = s.GetSeenImageTags(ctx, subscriptions[1].ID)
+	if len(seen) != 1 || !seen["1.27"] {
+		t.Errorf("seen tags after re-subscribing = %v, want only 1.27", seen)
+	}
+
+	mustNoError(t, s.RemoveImageSubscription(ctx, 1, "library/nginx"))
+	if err := s.RemoveImageSubscription(ctx, 1, "library/nginx"); err == nil {
+		t.Error("RemoveImageSubscription must fail for unknown subscriptions")
+	}
+}
+
+func testDependencySubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	mustNoError(t, s.AddDependencySubscription(ctx, 1, "octo/repo", []string{"go:a@v1"}))
+	mustNoError(t, s.AddDependencySubscription(ctx, 1, "octo/repo", []string{"go:b@v1"}))
+
+	subscriptions, err := s.GetDependencySubscriptions(ctx, 1)
+	mustNoError(t, err)
+	if len(subscriptions) != 1 || subscriptions[0].Repo != "octo/repo" || subscriptions[0].LastCheckedAt.IsZero() {
+		t.Fatalf("GetDependencySubscriptions = %+v, want one checked subscription", subscriptions)
+	}
+
+	mustNoError(t, s.MarkDependencyVersionsSeen(ctx, subscriptions[0].ID, []string{"go:a@v2"}))
+	seen, err := s.GetSeenDependencyVersions(ctx, subscriptions[0].ID)
+	mustNoError(t, err)
+	if len(seen) != 3 {
+		t.Errorf("seen versions = %v, want all three keys", seen)
+	}
+
+	mustNoError(t, s.RemoveDependencySubscription(ctx, 1, "octo/repo"))
+	if err := s.RemoveDependencySubscription(ctx, 1, "octo/repo"); err == nil {
+		t.Error("RemoveDependencySubscription must fail for unknown subscriptions")
+	}
+}
+
+func testRepoSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	if _, err := s.AddRepoSubscription(ctx, models.RepoSubscription{ChatID: 1, Repo: "not-a-repo"}); err == nil {
+		t.Error("AddRepoSubscription must validate the subscription")
+	}
+
+	id, err := s.AddRepoSubscription(ctx, models.RepoSubscription{ChatID: 1, Repo: "octo/repo", EventTypes: []string{models.EventRelease}})
+	mustNoError(t, err)
+	replaced, err := s.AddRepoSubscription(ctx, models.RepoSubscription{
+		ChatID:            1,
+		Repo:              "octo/repo",
+		Filters:           models.RepoFilters{Labels: []string{"bug"}},
+		DestinationChatID: -100,
+	})
+	mustNoError(t, err)
+	if replaced != id {
+		t.Errorf("re-subscribing returned ID %d, want the existing %d", replaced, id)
+	}
+	_, err = s.AddRepoSubscription(ctx, models.RepoSubscription{ChatID: 1, Repo: "octo/*"})
+	mustNoError(t, err)
+
+	subscriptions, err := s.GetRepoSubscriptions(ctx, 1)
+	mustNoError(t, err)
+	if len(subscriptions) != 2 || subscriptions[0].Repo != "octo/*" {
+		t.Fatalf("GetRepoSubscriptions = %+v, want two subscriptions ordered by repo", subscriptions)
+	}
+	got := subscriptions[1]
+	if len(got.EventTypes) != 0 || len(got.Filters.Labels) != 1 || got.DestinationChatID != -100 || got.CreatedAt.IsZero() {
+		t.Errorf("replaced subscription = %+v", got)
+	}
+
+	mustNoError(t, s.RemoveRepoSubscription(ctx, 1, "octo/repo"))
+	if err := s.RemoveRepoSubscription(ctx, 1, "octo/repo"); err == nil {
+		t.Error("RemoveRepoSubscription must fail for unknown subscriptions")
+	}
+}
+
+func testPreferences(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	preferences, err := s.GetPreferences(ctx, 1)
+	mustNoError(t, err)
+	if preferences.Timezone != models.DefaultPreferences(1).Timezone {
+		t.Errorf("GetPreferences must return the defaults, got %+v", preferences)
+	}
+
+	preferences.DigestMode = models.DigestDaily
+	preferences.DigestTime = "08:30"
+	if err := s.SetPreferences(ctx, preferences); err != store.ErrUserNotFound {
+		t.Errorf("SetPreferences without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	preferences.QuietHoursStart = "22:00"
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must validate the preferences")
+	}
+
+	preferences.QuietHoursEnd = "07:00"
+	preferences.Priorities = map[string]string{"octo/repo": models.PriorityHigh}
+	preferences.RenotifyIntervals = map[string]int{"review_requested": 0}
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject a renotify interval of 0 hours")
+	}
+
+	preferences.RenotifyIntervals = map[string]int{"review_requested": 4, "release": models.RenotifyNever}
+	preferences.Paused = true
+	preferences.PausedUntil = time.Now().Add(time.Hour).Truncate(time.Second)
+	preferences.PollWindow = "* 8-19 * * 1-5 6"
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject an invalid poll window")
+	}
+
+	preferences.PollWindow = "* 8-19 * * 1-5"
+	preferences.Filter = `notification.Repo startsWith "acme/" &&`
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject an invalid filter")
+	}
+
+	preferences.Filter = `notification.Repo startsWith "acme/" && !notification.Author.IsBot`
+	preferences.TeamDigest = "0 9 * * 1-5"
+	preferences.TeamRepos = []string{"acme"}
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject a team repository without an owner")
+	}
+
+	preferences.TeamRepos = []string{"acme/api", "acme/web"}
+	preferences.MentionSLA = 8
+	preferences.Triage = models.Triage{After: 24, Labels: []string{"bug"}}
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject triage mode without repositories")
+	}
+
+	preferences.Triage.Repos = []string{"acme/api"}
+	preferences.Summaries = true
+	preferences.Changelog = models.Changelog{Repos: []string{"acme/api", "acme/web"}, At: "noon"}
+	if err := s.SetPreferences(ctx, preferences); err == nil {
+		t.Error("SetPreferences must reject an invalid changelog time")
+	}
+
+	preferences.Changelog.At = "09:00"
+	preferences.Usage = models.UsageAlerts{Threshold: 80, Orgs: []string{"acme"}}
+	preferences.CommitMentionRepos = []string{"acme/api"}
+	mustNoError(t, s.SetPreferences(ctx, preferences))
+
+	saved, err := s.GetPreferences(ctx, 1)
+	mustNoError(t, err)
+	if saved.DigestMode != models.DigestDaily || saved.DigestTime != "08:30" || saved.QuietHoursEnd != "07:00" || saved.Priorities["octo/repo"] != models.PriorityHigh ||
+		saved.RenotifyIntervals["review_requested"] != 4 || saved.RenotifyIntervals["release"] != models.RenotifyNever ||
+		!saved.Paused || !saved.PausedUntil.Equal(preferences.PausedUntil) || saved.PollWindow != preferences.PollWindow ||
+		saved.Filter != preferences.Filter || saved.TeamDigest != preferences.TeamDigest || !slices.Equal(saved.TeamRepos, preferences.TeamRepos) ||
+		saved.MentionSLA != 8 || saved.Triage.After != 24 || !slices.Equal(saved.Triage.Repos, preferences.Triage.Repos) ||
+		!slices.Equal(saved.Triage.Labels, preferences.Triage.Labels) || !saved.Summaries ||
+		saved.Changelog.At != "09:00" || !slices.Equal(saved.Changelog.Repos, preferences.Changelog.Repos) ||
+		saved.Usage.Threshold != 80 || !slices.Equal(saved.Usage.Orgs, preferences.Usage.Orgs) ||
+		!slices.Equal(saved.CommitMentionRepos, preferences.CommitMentionRepos) {
+		t.Errorf("saved preferences = %+v, want %+v", saved, preferences)
+	}
+}
+
+func testPathSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	if _, err := s.AddPathSubscription(ctx, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "api/"}); err != store.ErrUserNotFound {
+		t.Errorf("AddPathSubscription without a user = %v, want ErrUserNotFound", err)
+	}
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	if _, err := s.AddPathSubscription(ctx, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "api/[/"}); err == nil {
+		t.Error("AddPathSubscription must validate the pattern")
+	}
+
+	id, err := s.AddPathSubscription(ctx, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "api/**/*.proto"})
+	mustNoError(t, err)
+	again, err := s.AddPathSubscription(ctx, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "api/**/*.proto"})
+	mustNoError(t, err)
+	if again != id {
+		t.Errorf("re-subscribing returned ID %d, want the existing %d", again, id)
+	}
+	_, err = s.AddPathSubscription(ctx, models.PathSubscription{ChatID: 1, Repo: "octo/repo", Pattern: "deploy/helm/"})
+	mustNoError(t, err)
+
+	subscriptions, err := s.GetPathSubscriptions(ctx, 1)
+	mustNoError(t, err)
+	if len(subscriptions) != 2 || subscriptions[0].Pattern != "api/**/*.proto" || subscriptions[1].Pattern != "deploy/helm/" || subscriptions[0].CreatedAt.IsZero() {
+		t.Fatalf("GetPathSubscriptions = %+v, want both patterns ordered", subscriptions)
+	}
+
+	mustNoError(t, s.RemovePathSubscription(ctx, 1, "octo/repo", "deploy/helm/"))
+	if err := s.RemovePathSubscription(ctx, 1, "octo/repo", "deploy/helm/"); err == nil {
+		t.Error("RemovePathSubscription must fail for unknown subscriptions")
+	}
+}
+
+func testAccountPreferences(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	preferences := models.AccountPreferences{ChatID: 1, Username: "alice", DisabledReasons: []string{"ci_activity", "subscribed"}, SecurityAlerts: true}
+	if err := s.SetAccountPreferences(ctx, preferences); err != store.ErrAccountNotFound {
+		t.Errorf("SetAccountPreferences without the account = %v, want ErrAccountNotFound", err)
+	}
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "bob", models.GitHubAccountMetadata{}))
+
+	if err := s.SetAccountPreferences(ctx, models.AccountPreferences{ChatID: 1, Username: "alice", DisabledReasons: []string{"lunch"}}); err == nil {
+		t.Error("SetAccountPreferences must validate the reasons")
+	}
+
+	got, err := s.GetAccountPreferences(ctx, 1, "alice")
+	mustNoError(t, err)
+	if len(got.DisabledReasons) != 0 || !got.Allows("ci_activity") || got.SecurityAlerts {
+		t.Errorf("GetAccountPreferences before saving = %+v, want every reason allowed and no security alerts", got)
+	}
+
+	mustNoError(t, s.SetAccountPreferences(ctx, preferences))
+	got, err = s.GetAccountPreferences(ctx, 1, "alice")
+	mustNoError(t, err)
+	if got.Allows("ci_activity") || got.Allows("subscribed") || !got.Allows("mention") || !got.SecurityAlerts {
+		t.Errorf("GetAccountPreferences = %+v, want ci_activity and subscribed disabled and security alerts on", got)
+	}
+	if other, err := s.GetAccountPreferences(ctx, 1, "bob"); err != nil || len(other.DisabledReasons) != 0 {
+		t.Errorf("GetAccountPreferences of another account = %+v, %v, want none disabled", other, err)
+	}
+
+	mustNoError(t, s.SetAccountPreferences(ctx, models.AccountPreferences{ChatID: 1, Username: "alice", DisabledReasons: []string{"mention"}}))
+	got, err = s.GetAccountPreferences(ctx, 1, "alice")
+	mustNoError(t, err)
+	if !got.Allows("ci_activity") || got.Allows("mention") || got.SecurityAlerts {
+		t.Errorf("GetAccountPreferences after replacing = %+v, want only mention disabled", got)
+	}
+
+	mustNoError(t, s.RemoveGitHubAccount(ctx, 1, "alice"))
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	got, err = s.GetAccountPreferences(ctx, 1, "alice")
+	mustNoError(t, err)
+	if len(got.DisabledReasons) != 0 || got.SecurityAlerts {
+		t.Errorf("GetAccountPreferences of a re-added account = %+v, want the removed account's preferences gone", got)
+	}
+}
+
+func testRoutingRules(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	silent := models.RoutingRule{ChatID: 1, Conditions: models.Conditions{Repo: "acme/*", Type: "ci_activity"}, DestinationChatID: -100, Silent: true}
+	if _, err := s.AddRoutingRule(ctx, silent); err != store.ErrUserNotFound {
+		t.Errorf("AddRoutingRule without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	if _, err := s.AddRoutingRule(ctx, models.RoutingRule{ChatID: 1, Conditions: models.Conditions{Label: "security"}}); err == nil {
+		t.Error("AddRoutingRule must reject a rule without an action")
+	}
+	if _, err := s.AddRoutingRule(ctx, models.RoutingRule{ChatID: 1, Severity: "urgent"}); err == nil {
+		t.Error("AddRoutingRule must reject an invalid severity")
+	}
+
+	silentID, err := s.AddRoutingRule(ctx, silent)
+	mustNoError(t, err)
+	critical := models.RoutingRule{ChatID: 1, Conditions: models.Conditions{Label: "security", Keyword: "prod down"}, Severity: models.SeverityCritical}
+	criticalID, err := s.AddRoutingRule(ctx, critical)
+	mustNoError(t, err)
+
+	rules, err := s.GetRoutingRules(ctx, 1)
+	mustNoError(t, err)
+	if len(rules) != 2 || rules[0].ID != silentID || rules[1].ID != criticalID {
+		t.Fatalf("GetRoutingRules = %+v, want the rules in the order they were added", rules)
+	}
+	if got := rules[0]; got.Conditions != silent.Conditions || got.DestinationChatID != -100 || !got.Silent || got.CreatedAt.IsZero() {
+		t.Errorf("saved rule = %+v, want %+v", got, silent)
+	}
+	if got := rules[1]; got.Conditions != critical.Conditions || got.Severity != models.SeverityCritical || got.Silent {
+		t.Errorf("saved rule = %+v, want %+v", got, critical)
+	}
+
+	if err := s.RemoveRoutingRule(ctx, 2, silentID); err != store.ErrRuleNotFound {
+		t.Errorf("RemoveRoutingRule of another chat's rule = %v, want ErrRuleNotFound", err)
+	}
+	mustNoError(t, s.RemoveRoutingRule(ctx, 1, silentID))
+	if err := s.RemoveRoutingRule(ctx, 1, silentID); err != store.ErrRuleNotFound {
+		t.Errorf("RemoveRoutingRule twice = %v, want ErrRuleNotFound", err)
+	}
+	rules, err = s.GetRoutingRules(ctx, 1)
+	mustNoError(t, err)
+	if len(rules) != 1 || rules[0].ID != criticalID {
+		t.Errorf("GetRoutingRules after removing = %+v, want only rule %d", rules, criticalID)
+	}
+}
+
+func testReviewRequests(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	old := models.ReviewRequest{ChatID: 1, Account: "alice", Repo: "acme/api", URL: "https://api.github.com/repos/acme/api/pulls/1", RequestedAt: now.Add(-48 * time.Hour)}
+	if err := s.RecordReviewRequest(ctx, old); err != store.ErrUserNotFound {
+		t.Errorf("RecordReviewRequest without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	mustNoError(t, s.RecordReviewRequest(ctx, old))
+	recent := models.ReviewRequest{ChatID: 1, Account: "alice", Repo: "acme/web", URL: "https://api.github.com/repos/acme/web/pulls/2", RequestedAt: now.Add(-time.Hour)}
+	mustNoError(t, s.RecordReviewRequest(ctx, recent))
+	// The same request seen again while pending is recorded once.
+	mustNoError(t, s.RecordReviewRequest(ctx, models.ReviewRequest{ChatID: 1, Account: "alice", Repo: "acme/web", URL: recent.URL, RequestedAt: now}))
+
+	requests, err := s.GetReviewRequests(ctx, 1, now.Add(-24*time.Hour))
+	mustNoError(t, err)
+	if len(requests) != 2 || requests[0].URL != old.URL || requests[1].URL != recent.URL {
+		t.Fatalf("GetReviewRequests = %+v, want the pending requests oldest first", requests)
+	}
+	if got := requests[1]; got.Account != "alice" || got.Repo != "acme/web" || !got.RequestedAt.Equal(recent.RequestedAt) || !got.Pending() {
+		t.Errorf("saved request = %+v, want %+v", got, recent)
+	}
+
+	mustNoError(t, s.CompleteReviewRequest(ctx, requests[0].ID, now.Add(-47*time.Hour)))
+	mustNoError(t, s.CompleteReviewRequest(ctx, requests[1].ID, now))
+	requests, err = s.GetReviewRequests(ctx, 1, now.Add(-24*time.Hour))
+	mustNoError(t, err)
+	if len(requests) != 1 || requests[0].URL != recent.URL || !requests[0].ReviewedAt.Equal(now) {
+		t.Fatalf("GetReviewRequests after reviews = %+v, want only the reviewed recent request", requests)
+	}
+
+	// A new request for a reviewed pull request is tracked again.
+	mustNoError(t, s.RecordReviewRequest(ctx, models.ReviewRequest{ChatID: 1, Account: "alice", Repo: "acme/web", URL: recent.URL, RequestedAt: now}))
+	requests, err = s.GetReviewRequests(ctx, 1, now.Add(-24*time.Hour))
+	mustNoError(t, err)
+	if len(requests) != 2 || !requests[1].Pending() {
+		t.Fatalf("GetReviewRequests after a new request = %+v, want it pending", requests)
+	}
+	mustNoError(t, s.RemoveReviewRequest(ctx, requests[1].ID))
+
+	mustNoError(t, s.CleanOldNotifications(ctx, now.Add(-24*time.Hour)))
+	requests, err = s.GetReviewRequests(ctx, 1, time.Time{})
+	mustNoError(t, err)
+	if len(requests) != 1 || requests[0].URL != recent.URL {
+		t.Errorf("GetReviewRequests after cleaning = %+v, want only the recent request", requests)
+	}
+}
+
+func testMentions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	old := models.Mention{ChatID: 1, Account: "alice", Repo: "acme/api", Message: "[acme/api] Flaky test", URL: "https://api.github.com/repos/acme/api/issues/1", MentionedAt: now.Add(-48 * time.Hour)}
+	if err := s.RecordMention(ctx, old); err != store.ErrUserNotFound {
+		t.Errorf("RecordMention without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	recent := models.Mention{ChatID: 1, Account: "alice", Repo: "acme/web", Message: "[acme/web] Add login", URL: "https://api.github.com/repos/acme/web/pulls/2", MentionedAt: now.Add(-time.Hour)}
+	mustNoError(t, s.RecordMention(ctx, recent))
+	mustNoError(t, s.RecordMention(ctx, old))
+	// Another mention on the same issue keeps waiting since the first.
+	mustNoError(t, s.RecordMention(ctx, models.Mention{ChatID: 1, Account: "alice", Repo: "acme/api", Message: "[acme/api] Flaky test", URL: old.URL, MentionedAt: now}))
+
+	mentions, err := s.GetMentions(ctx, 1)
+	mustNoError(t, err)
+	if len(mentions) != 2 || mentions[0].URL != old.URL || mentions[1].URL != recent.URL {
+		t.Fatalf("GetMentions = %+v, want the mentions oldest first", mentions)
+	}
+	if got := mentions[0]; got.Message != old.Message || got.Repo != old.Repo || !got.MentionedAt.Equal(old.MentionedAt) {
+		t.Errorf("saved mention = %+v, want %+v", got, old)
+	}
+
+	mustNoError(t, s.RemoveMention(ctx, mentions[1].ID))
+	mustNoError(t, s.CleanOldNotifications(ctx, now.Add(-24*time.Hour)))
+	mentions, err = s.GetMentions(ctx, 1)
+	mustNoError(t, err)
+	if len(mentions) != 1 || mentions[0].URL != old.URL {
+		t.Errorf("GetMentions after removing and cleaning = %+v, want the unanswered old mention", mentions)
+	}
+}
+
+func testIntegrations(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+
+	jira := models.JiraConfig{ChatID: 1, BaseURL: "https://example.atlassian.net", Email: "a@example.com", APIToken: "t", ProjectKey: "OPS", IssueType: "Bug"}
+	mustNoError(t, s.SetJiraConfig(ctx, jira))
+	if got, ok := s.GetJiraConfig(ctx, 1); !ok || *got != jira {
+		t.Errorf("GetJiraConfig = %+v, want %+v", got, jira)
+	}
+	mustNoError(t, s.RemoveJiraConfig(ctx, 1))
+	if _, ok := s.GetJiraConfig(ctx, 1); ok {
+		t.Error("RemoveJiraConfig did not remove the config")
+	}
+
+	if err := s.SetLinearMapping(ctx, 1, "octo/repo", models.LinearTarget{TeamID: "T2"}); err == nil {
+		t.Error("SetLinearMapping must fail before Linear is configured")
+	}
+	mustNoError(t, s.SetLinearConfig(ctx, 1, "key", models.LinearTarget{TeamID: "T1"}))
+	mustNoError(t, s.SetLinearMapping(ctx, 1, "octo/repo", models.LinearTarget{TeamID: "T2", ProjectID: "P"}))
+	mustNoError(t, s.SetLinearConfig(ctx, 1, "key2", models.LinearTarget{TeamID: "T3"}))
+
+	linear, ok := s.GetLinearConfig(ctx, 1)
+	if !ok {
+		t.Fatal("GetLinearConfig did not find the config")
+	}
+	if linear.APIKey != "key2" || linear.Target("other/repo").TeamID != "T3" || linear.Target("octo/repo").ProjectID != "P" {
+		t.Errorf("GetLinearConfig = %+v, want the updated default and the kept mapping", linear)
+	}
+
+	mustNoError(t, s.RemoveLinearMapping(ctx, 1, "octo/repo"))
+	linear, _ = s.GetLinearConfig(ctx, 1)
+	if len(linear.Mappings) != 0 {
+		t.Error("RemoveLinearMapping did not remove the mapping")
+	}
+	mustNoError(t, s.RemoveLinearConfig(ctx, 1))
+	if _, ok := s.GetLinearConfig(ctx, 1); ok {
+		t.Error("RemoveLinearConfig did not remove the config")
+	}
+}
+
+func testCalendarToken(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	if _, err := s.GetCalendarToken(ctx, 1); err != store.ErrUserNotFound {
+		t.Errorf("GetCalendarToken without a user = %v, want ErrUserNotFound", err)
+	}
+
+	mustNoError(t, s.AddGitHubAccount(ctx, 1, "token", "alice", models.GitHubAccountMetadata{}))
+	token, err := s.GetCalendarToken(ctx, 1)
+	mustNoError(t, err)
+	if token == "" {
+		t.Fatal("GetCalendarToken returned an empty token")
+	}
+	if again, _ := s.GetCalendarToken(ctx, 1); again != token {
+		t.Error("GetCalendarToken must keep returning the same token")
+	}
+
+	user, ok := s.GetUserByCalendarToken(ctx, token)
+	if !ok || user.ChatID != 1 {
+		t.Error("GetUserByCalendarToken did not find the user")
+	}
+	if _, ok := s.GetUserByCalendarToken(ctx, "unknown"); ok {
+		t.Error("GetUserByCalendarToken found a user for an unknown token")
+	}
+}
@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultTenant owns the users of the bot configured with
+// TELEGRAM_BOT_TOKEN and every user created before tenants existed.
+const DefaultTenant = "default"
+
+var ErrTenantMismatch = errors.New("chat belongs to another workspace")
+
+type tenantKey struct{}
+
+// WithTenant scopes store calls made with the returned context to a
+// tenant: users are created in it, and users of other tenants are neither
+// listed nor found.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant the context is scoped to, if any.
+// Unscoped contexts see every tenant.
+func TenantFromContext(ctx context.Context) (string, bool) {
+	tenantID, ok := ctx.Value(tenantKey{}).(string)
+	return tenantID, ok
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/redact"
+)
+
+// FirstChatID is the ListUsers cursor that starts at the first user. Group
+// chats have negative IDs, so the cursor starts below every valid chat ID.
+const FirstChatID int64 = math.MinInt64
+
+// userPageSize bounds how many users ForEachUser holds in memory at once.
+const userPageSize = 500
+
+// ForEachUser calls fn for every user visible to ctx in chat ID order,
+// loading them a page at a time. It stops at the first error fn returns.
+func ForEachUser(ctx context.Context, s Store, fn func(*models.User) error) error {
+	after := FirstChatID
+	for {
+		users, err := s.ListUsers(ctx, after, userPageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range users {
+			if err := fn(user); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < userPageSize {
+			return nil
+		}
+		after = users[len(users)-1].ChatID
+	}
+}
+
+// errTokenInUse stops TokenInUse at the first account using the token.
+var errTokenInUse = errors.New("token in use")
+
+// TokenInUse reports whether a GitHub account of any tenant uses the token,
+// whatever tenant ctx is scoped to.
+func TokenInUse(ctx context.Context, s Store, token string) (bool, error) {
+	err := ForEachUser(unscoped{ctx}, s, func(user *models.User) error {
+		for _, account := range user.Accounts {
+			if account.Token == token {
+				return errTokenInUse
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTokenInUse) {
+		return true, nil
+	}
+	return false, err
+}
+
+// ForgetToken stops redacting the token of a removed or replaced GitHub
+// account, unless another account still uses it. Tokens that cannot be
+// checked stay redacted.
+func ForgetToken(ctx context.Context, s Store, token string) {
+	inUse, err := TokenInUse(ctx, s, token)
+	if err != nil {
+		log.Printf("Error checking whether a removed token is still in use: %v", err)
+		return
+	}
+	if !inUse {
+		redact.Remove(token)
+	}
+}
+
+// unscoped hides the tenant of the context it wraps, so store calls see
+// every tenant.
+type unscoped struct {
+	context.Context
+}
+
+func (c unscoped) Value(key any) any {
+	if key == (tenantKey{}) {
+		return nil
+	}
+	return c.Context.Value(key)
+}
@@ -0,0 +1,40 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/render"
+)
+
+// Dispatch delivers the queued notifications that are due to the sink,
+// the way the bot delivers them to Telegram: routing rules, pauses and
+// digests apply, and failed deliveries are retried with exponential
+// backoff up to 10 attempts.
+func (e *Engine) Dispatch(ctx context.Context) error {
+	if err := e.dispatcher.Dispatch(ctx); err != nil {
+		return fmt.Errorf("failed to claim notifications: %v", err)
+	}
+	return nil
+}
+
+// sinkTransport delivers outbox entries to a sink, in the chat routing
+// rules send them to. Digests are delivered one notification at a time,
+// so a failure has the whole digest delivered again.
+type sinkTransport struct {
+	sink Sink
+}
+
+func (t sinkTransport) Send(ctx context.Context, format *render.Format, entry models.OutboxEntry) error {
+	return t.sink.Deliver(ctx, entry.Destination(), entry.Notification)
+}
+
+func (t sinkTransport) SendDigest(ctx context.Context, format *render.Format, heading string, entries []models.OutboxEntry) error {
+	for _, entry := range entries {
+		if err := t.Send(ctx, format, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package monitor embeds the repository monitor's engine in other Go
+// programs. An Engine runs the bot's GitHub and Gerrit sources and its
+// dispatcher, handing notifications to a Sink instead of Telegram:
+//
+//	engine, err := monitor.New(monitor.Options{
+//		Sink: monitor.SinkFunc(func(ctx context.Context, chatID int64, n monitor.Notification) error {
+//			fmt.Println(n.Message, n.URL)
+//			return nil
+//		}),
+//	})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	if err := engine.AddGitHubAccount(ctx, 1, token, "octocat"); err != nil {
+//		log.Fatal(err)
+//	}
+//	engine.Run(ctx)
+//
+// Accounts belong to a chat ID, which scopes their deduplication and
+// preferences. Programs without chats can use a single ID for everything.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/bot"
+	"github.com/erkineren/repository-monitor/internal/config"
+	"github.com/erkineren/repository-monitor/internal/github"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/severity"
+	"github.com/erkineren/repository-monitor/internal/sink"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/store"
+	"github.com/erkineren/repository-monitor/internal/store/memory"
+	"github.com/erkineren/repository-monitor/internal/store/postgres"
+	"github.com/erkineren/repository-monitor/internal/store/sqlite"
+)
+
+// The engine's types are aliases of the bot's own, so values pass between
+// the engine, its store and sinks without conversion. Every type a Sink
+// gets or the documented Store calls take is aliased here, so programs
+// can name them without importing the bot's internal packages.
+type (
+	// Notification is one piece of activity to deliver.
+	Notification = models.Notification
+	// Fields are what a notification is about, such as the title and
+	// number of an issue or the version of a release, as opposed to its
+	// rendered Message.
+	Fields = models.Fields
+	// Preferences shape delivery per chat: pauses, quiet hours, poll
+	// windows, priorities and renotify intervals.
+	Preferences = models.Preferences
+	// AccountPreferences are the settings of one of a chat's GitHub
+	// accounts.
+	AccountPreferences = models.AccountPreferences
+	// RoutingRule changes how a chat's notifications matching its
+	// conditions are delivered, e.g. to another chat.
+	RoutingRule = models.RoutingRule
+	// User is a chat with its accounts and muted repositories.
+	User = models.User
+	// Store keeps accounts, deduplication records and queued
+	// notifications.
+	Store = store.Store
+)
+
+// NewMemoryStore returns a store that keeps everything in memory.
+func NewMemoryStore() Store {
+	return memory.New()
+}
+
+// OpenStateFile returns an in-memory store that keeps deduplication
+// records and polling state in a JSON file across restarts.
+func OpenStateFile(path string) (Store, error) {
+	return memory.Open(path)
+}
+
+// OpenPostgres connects to a PostgreSQL database, applying pending schema
+// migrations.
+func OpenPostgres(databaseURL string) (Store, error) {
+	return postgres.New(databaseURL, postgres.PoolConfig{}, true)
+}
+
+// OpenSQLite opens a SQLite database file, creating it when it does not
+// exist and applying pending schema migrations.
+func OpenSQLite(path string) (Store, error) {
+	return sqlite.New(sqlite.Scheme+path, true)
+}
+
+// Sink delivers notifications to the chat they are routed to. A failed
+// delivery is retried with exponential backoff.
+type Sink = sink.Sink
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(ctx context.Context, chatID int64, notification Notification) error
+
+// Deliver calls f.
+func (f SinkFunc) Deliver(ctx context.Context, chatID int64, notification Notification) error {
+	return f(ctx, chatID, notification)
+}
+
+// Options configure an Engine. Only Sink is required.
+type Options struct {
+	Sink Sink
+	// Store defaults to NewMemoryStore.
+	Store Store
+	// PollInterval is the time between polls of every account, 60 seconds
+	// by default.
+	PollInterval time.Duration
+	// PollTimeout bounds fetching one account, 60 seconds by default.
+	PollTimeout time.Duration
+	// DispatchInterval is how often queued notifications are delivered
+	// when idle, 5 seconds by default.
+	DispatchInterval time.Duration
+	// RenotifyInterval is the time before an unchanged item is delivered
+	// again, 24 hours by default. Preferences can change it per type.
+	RenotifyInterval time.Duration
+}
+
+// Engine polls accounts and delivers their notifications.
+type Engine struct {
+	opts       Options
+	store      Store
+	config     *config.Config
+	sources    []source.Instance
+	dispatcher *bot.Dispatcher
+	clients    github.ClientFactory
+}
+
+// New returns an engine delivering to opts.Sink, filling in the defaults
+// of the other options. It fails without a sink. Nothing is polled until
+// Run, Poll or Dispatch is called.
+func New(opts Options) (*Engine, error) {
+	if opts.Sink == nil {
+		return nil, fmt.Errorf("invalid options: a sink is required")
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 60 * time.Second
+	}
+	if opts.PollTimeout <= 0 {
+		opts.PollTimeout = 60 * time.Second
+	}
+	if opts.DispatchInterval <= 0 {
+		opts.DispatchInterval = 5 * time.Second
+	}
+	if opts.RenotifyInterval <= 0 {
+		opts.RenotifyInterval = 24 * time.Hour
+	}
+
+	return &Engine{
+		opts:  opts,
+		store: opts.Store,
+		config: &config.Config{
+			PollInterval:     opts.PollInterval,
+			PollTimeout:      opts.PollTimeout,
+			RenotifyInterval: opts.RenotifyInterval,
+			UrgencyMarkers:   severity.DefaultUrgencyMarkers,
+		},
+		sources:    source.New(),
+		dispatcher: bot.NewTransportDispatcher(sinkTransport{sink: opts.Sink}, opts.Store, nil, 0),
+		clients:    github.NewAPI,
+	}, nil
+}
+
+// Store returns the engine's store, e.g. to set preferences.
+func (e *Engine) Store() Store {
+	return e.store
+}
+
+// AddGitHubAccount monitors the GitHub notifications of the token's user
+// for the chat.
+func (e *Engine) AddGitHubAccount(ctx context.Context, chatID int64, token, username string) error {
+	return e.store.AddGitHubAccount(ctx, chatID, token, username, models.GitHubAccountMetadata{})
+}
+
+// AddGerritAccount monitors the changes of a Gerrit user for the chat,
+// authenticating with the user's HTTP password.
+func (e *Engine) AddGerritAccount(ctx context.Context, chatID int64, baseURL, username, password string) error {
+	account := models.GerritAccount{BaseURL: strings.TrimSuffix(baseURL, "/"), Username: username, Password: password}
+	return e.store.AddGerritAccount(ctx, chatID, account)
+}
+
+// Run polls and delivers until ctx is done.
+func (e *Engine) Run(ctx context.Context) {
+	pollTicker := time.NewTicker(e.opts.PollInterval)
+	defer pollTicker.Stop()
+	dispatchTicker := time.NewTicker(e.opts.DispatchInterval)
+	defer dispatchTicker.Stop()
+
+	e.runPoll(ctx)
+	for {
+		if err := e.Dispatch(ctx); err != nil {
+			log.Printf("Error delivering notifications: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTicker.C:
+			e.runPoll(ctx)
+		case <-dispatchTicker.C:
+		}
+	}
+}
+
+func (e *Engine) runPoll(ctx context.Context) {
+	queued, err := e.Poll(ctx)
+	if err != nil {
+		log.Printf("Error polling accounts: %v", err)
+		return
+	}
+	log.Printf("Queued %d new notifications", queued)
+}
@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/github/githubtest"
+	"github.com/erkineren/repository-monitor/internal/models"
+)
+
+func TestNewRequiresSink(t *testing.T) {
+	if _, err := New(Options{}); err == nil {
+		t.Error("New must fail without a sink")
+	}
+}
+
+// TestEngine runs the GitHub source of an engine against a fake GitHub and
+// checks that its notification reaches the SinkFunc once.
+func TestEngine(t *testing.T) {
+	ctx := context.Background()
+	type delivery struct {
+		chatID       int64
+		notification Notification
+	}
+	var delivered []delivery
+	engine, err := New(Options{
+		Sink: SinkFunc(func(ctx context.Context, chatID int64, notification Notification) error {
+			delivered = append(delivered, delivery{chatID, notification})
+			return nil
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &githubtest.Client{
+		Login: "octocat",
+		Notifications: []models.Notification{{
+			Type:       "mention",
+			Repo:       "acme/api",
+			Message:    "[acme/api] Crash on start",
+			URL:        "https://github.com/acme/api/issues/1",
+			OccurredAt: time.Now(),
+			Fields:     Fields{Title: "Crash on start"},
+		}},
+	}
+	engine.clients = client.Factory()
+
+	if err := engine.AddGitHubAccount(ctx, 42, "token", "octocat"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := engine.Poll(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.Dispatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(delivered) != 1 {
+		t.Fatalf("delivered %d notifications, want 1 as the second poll finds nothing new", len(delivered))
+	}
+	got := delivered[0]
+	if got.chatID != 42 || got.notification.URL != "https://github.com/acme/api/issues/1" || got.notification.Fields.Title != "Crash on start" {
+		t.Errorf("delivered %+v to chat %d", got.notification, got.chatID)
+	}
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/erkineren/repository-monitor/internal/features"
+	"github.com/erkineren/repository-monitor/internal/models"
+	"github.com/erkineren/repository-monitor/internal/source"
+	"github.com/erkineren/repository-monitor/internal/store"
+
+	// The sources the engine polls, as in the bot.
+	_ "github.com/erkineren/repository-monitor/internal/source/gerritsrc"
+	_ "github.com/erkineren/repository-monitor/internal/source/githubsrc"
+)
+
+// Poll fetches the notifications of every active account once and queues
+// the new ones, returning how many were queued. It runs the bot's GitHub
+// and Gerrit sources, so account preferences, mutes, filters and feature
+// flags apply as in the bot. Accounts that fail are logged and skipped;
+// their error is kept in the account's polling state.
+func (e *Engine) Poll(ctx context.Context) (int, error) {
+	flags, err := features.Load(ctx, e.store)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load feature flags: %v", err)
+	}
+	counter := &countingStore{Store: e.store}
+	env := &source.Env{Store: counter, Config: e.config, Flags: flags, GitHub: e.clients}
+
+	err = store.ForEachUser(ctx, e.store, func(user *models.User) error {
+		if !source.InPollWindow(ctx, e.store, user.ChatID) {
+			return nil
+		}
+		for _, src := range e.sources {
+			for _, job := range src.Jobs(user) {
+				runJob(ctx, env, job)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return counter.queued, fmt.Errorf("failed to get users: %v", err)
+	}
+	return counter.queued, nil
+}
+
+// runJob runs a poll job, logging and skipping it when it panics.
+func runJob(ctx context.Context, env *source.Env, job source.Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic in %s: %v\n%s", job.Name, r, debug.Stack())
+		}
+	}()
+	job.Run(ctx, env)
+}
+
+// countingStore counts the notifications queued through it, as the
+// sources only log how many they queued.
+type countingStore struct {
+	store.Store
+	queued int
+}
+
+func (s *countingStore) EnqueueNotification(ctx context.Context, chatID int64, notification models.Notification, contentHash string, renotifyInterval time.Duration) (bool, error) {
+	queued, err := s.Store.EnqueueNotification(ctx, chatID, notification, contentHash, renotifyInterval)
+	if queued {
+		s.queued++
+	}
+	return queued, err
+}